@@ -17,6 +17,9 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbos/retryables"
+	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/util/statehealer"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
 	"github.com/offchainlabs/nitro/validator"
 	"github.com/pkg/errors"
 )
@@ -57,10 +60,143 @@ func (a *BlockValidatorAPI) LatestValidatedBlockHash(ctx context.Context) (commo
 	return hash, nil
 }
 
+// ValidationCosts reports percentiles of the wall time, machine steps and preimage bytes recent block validations took.
+func (a *BlockValidatorAPI) ValidationCosts(ctx context.Context) (validator.ValidationCostStats, error) {
+	return a.val.ValidationCosts(), nil
+}
+
+// ValidationStatus reports the last validated message and batch, how far validation is behind the head, the jobs
+// in flight, recent throughput and the wasm module roots being validated against.
+func (a *BlockValidatorAPI) ValidationStatus(ctx context.Context) (*validator.ValidationProgress, error) {
+	return a.val.ValidationStatus()
+}
+
+type StakerAPI struct {
+	staker *validator.Staker
+}
+
+// StakingCostProjection projects the monthly L1 gas and cost of staking, from the assertion schedule and the gas
+// recent staker transactions used.
+func (a *StakerAPI) StakingCostProjection(ctx context.Context) (*validator.StakingCostProjection, error) {
+	return a.staker.StakingCostProjection(), nil
+}
+
+// ChallengeState reports the bisection position, pending move and deadlines of the challenge the staker is in,
+// or null if it isn't in one.
+func (a *StakerAPI) ChallengeState(ctx context.Context) (*validator.ChallengeProgress, error) {
+	return a.staker.ChallengeProgress()
+}
+
+type SnapshotAPI struct {
+	publisher *SnapshotPublisher
+}
+
+// CreateSnapshot starts publishing snapshots of each configured kind in the background, returning the job's
+// status. It fails if a job is already running.
+func (a *SnapshotAPI) CreateSnapshot(ctx context.Context) (SnapshotJobStatus, error) {
+	return a.publisher.StartJob()
+}
+
+// SnapshotStatus reports the status of the latest snapshot job.
+func (a *SnapshotAPI) SnapshotStatus(ctx context.Context) (SnapshotJobStatus, error) {
+	return a.publisher.JobStatus(), nil
+}
+
+type StatePrunerAPI struct {
+	pruner *StatePruner
+}
+
+// StatePruningProgress reports the phase of the current state pruning run, how far through it is, and an
+// estimate of when the phase will finish.
+func (a *StatePrunerAPI) StatePruningProgress(ctx context.Context) (StatePruningProgress, error) {
+	return a.pruner.Progress(), nil
+}
+
+type ArbSyncAPI struct {
+	txStreamer *TransactionStreamer
+	feedURLs   []string
+}
+
+type SyncStatus struct {
+	L1Connected      bool                   `json:"l1Connected"`
+	FeedOnly         bool                   `json:"feedOnly"`
+	DataFinality     string                 `json:"dataFinality"`
+	MessageCount     uint64                 `json:"messageCount"`
+	FeedURLs         []string               `json:"feedUrls"`
+	TrustAssumptions []string               `json:"trustAssumptions"`
+	Progress         map[string]interface{} `json:"progress"`
+
+	// Messages below this count were written without L1, and no L1 batch has covered them yet
+	UnfinalizedMessageCount uint64 `json:"unfinalizedMessageCount"`
+}
+
+var feedOnlyTrustAssumptions = []string{
+	"all data comes from the sequencer feed and has not been checked against L1",
+	"sequencer or feed relay reorgs are not detected and may be followed silently",
+	"no block has been finalized on L1 from this node's point of view",
+}
+
+func (a *ArbSyncAPI) SyncStatus(ctx context.Context) (SyncStatus, error) {
+	msgCount, err := a.txStreamer.GetMessageCount()
+	if err != nil {
+		return SyncStatus{}, err
+	}
+	feedOnlyCount, err := a.txStreamer.FeedOnlyMessageCount()
+	if err != nil {
+		return SyncStatus{}, err
+	}
+	if feedOnlyCount > msgCount {
+		// messages past the current count were reorged out
+		feedOnlyCount = msgCount
+	}
+	status := SyncStatus{
+		L1Connected:             a.txStreamer.HasInboxReader(),
+		MessageCount:            uint64(msgCount),
+		UnfinalizedMessageCount: uint64(feedOnlyCount),
+		FeedURLs:                a.feedURLs,
+		TrustAssumptions:        []string{},
+		Progress:                a.txStreamer.SyncProgressMap(),
+	}
+	if status.L1Connected {
+		status.DataFinality = "l1"
+		if feedOnlyCount > 0 {
+			status.DataFinality = "l1-pending"
+			status.TrustAssumptions = []string{"messages written while following the feed without L1 are not yet covered by L1 batches"}
+		}
+	} else {
+		status.FeedOnly = len(a.feedURLs) > 0
+		status.DataFinality = "unfinalized"
+		status.TrustAssumptions = feedOnlyTrustAssumptions
+	}
+	return status, nil
+}
+
+type ArbAdminAPI struct {
+	broadcastServer *broadcaster.Broadcaster
+}
+
+// Threads lists the node's running long-lived threads, showing which haven't exited after being cancelled
+// when a shutdown is stuck.
+func (a *ArbAdminAPI) Threads(ctx context.Context) ([]stopwaiter.ThreadInfo, error) {
+	return stopwaiter.Threads(), nil
+}
+
+// RevokeFeedToken stops the feed token with the given ID from connecting to this node's feed, and disconnects
+// the clients using it.
+func (a *ArbAdminAPI) RevokeFeedToken(ctx context.Context, id string) error {
+	if a.broadcastServer == nil {
+		return errors.New("feed output not enabled")
+	}
+	a.broadcastServer.RevokeToken(id)
+	return nil
+}
+
 type ArbDebugAPI struct {
 	blockchain        *core.BlockChain
 	blockRangeBound   uint64
 	timeoutQueueBound uint64
+	limits            *DebugLimitsConfig
+	stateHealer       *statehealer.StateHealer
 }
 
 type PricingModelHistory struct {
@@ -85,8 +221,17 @@ type PricingModelHistory struct {
 	L1PricingInertia     uint64     `json:"l1PricingInertia"`
 	L1PerUnitReward      uint64     `json:"l1PerUnitReward"`
 	L1PayRewardTo        string     `json:"l1PayRewardTo"`
+
+	// Set if the request ran out of budget, in which case only the first blocks are included
+	Truncated string `json:"truncated,omitempty"`
 }
 
+// approximate sizes of debug results, used to enforce the request memory budget
+const (
+	pricingModelBytesPerBlock = 8*5 + 32*5
+	timeoutQueueBytesPerEntry = 32 + 8
+)
+
 func (api *ArbDebugAPI) PricingModel(ctx context.Context, start, end rpc.BlockNumber) (PricingModelHistory, error) {
 	start, _ = api.blockchain.ClipToPostNitroGenesis(start)
 	end, _ = api.blockchain.ClipToPostNitroGenesis(end)
@@ -114,11 +259,21 @@ func (api *ArbDebugAPI) PricingModel(ctx context.Context, start, end rpc.BlockNu
 		L1LastUpdateTime:     make([]uint64, blocks),
 	}
 
+	budget, cancel := newRequestBudget(ctx, api.limits)
+	defer cancel()
+
+	var lastState *arbosState.ArbosState
 	for i := uint64(0); i < uint64(blocks); i++ {
+		if !budget.spend(pricingModelBytesPerBlock) {
+			history.truncate(i, budget.truncated())
+			logTruncated("arbdebug_pricingModel", blocks, i, budget.truncated())
+			break
+		}
 		state, header, err := stateAndHeader(api.blockchain, i+uint64(start))
 		if err != nil {
 			return history, err
 		}
+		lastState = state
 		l1Pricing := state.L1PricingState()
 		l2Pricing := state.L2PricingState()
 
@@ -142,38 +297,55 @@ func (api *ArbDebugAPI) PricingModel(ctx context.Context, start, end rpc.BlockNu
 		history.L1UnitsSinceUpdate[i] = l1UnitsSinceUpdate
 		history.L1LastUpdateTime[i] = l1LastUpdateTime
 		history.L1LastSurplus[i] = l1LastSurplus
+	}
+
+	if lastState != nil {
+		l1Pricing := lastState.L1PricingState()
+		l2Pricing := lastState.L2PricingState()
+
+		speedLimit, _ := l2Pricing.SpeedLimitPerSecond()
+		perBlockGasLimit, _ := l2Pricing.PerBlockGasLimit()
+		minBaseFee, _ := l2Pricing.MinBaseFeeWei()
+		pricingInertia, _ := l2Pricing.PricingInertia()
+		backlogTolerance, _ := l2Pricing.BacklogTolerance()
+
+		l1PricingInertia, _ := l1Pricing.Inertia()
+		l1EquilibrationUnits, _ := l1Pricing.EquilibrationUnits()
+		l1PerUnitReward, _ := l1Pricing.PerUnitReward()
+		l1PayRewardsTo, err := l1Pricing.PayRewardsTo()
 
-		if i == uint64(blocks)-1 {
-			speedLimit, _ := l2Pricing.SpeedLimitPerSecond()
-			perBlockGasLimit, _ := l2Pricing.PerBlockGasLimit()
-			minBaseFee, _ := l2Pricing.MinBaseFeeWei()
-			pricingInertia, _ := l2Pricing.PricingInertia()
-			backlogTolerance, _ := l2Pricing.BacklogTolerance()
-
-			l1PricingInertia, _ := l1Pricing.Inertia()
-			l1EquilibrationUnits, _ := l1Pricing.EquilibrationUnits()
-			l1PerUnitReward, _ := l1Pricing.PerUnitReward()
-			l1PayRewardsTo, err := l1Pricing.PayRewardsTo()
-
-			if err != nil {
-				return history, err
-			}
-			history.MinBaseFee = minBaseFee
-			history.SpeedLimit = speedLimit
-			history.PerBlockGasLimit = perBlockGasLimit
-			history.PricingInertia = pricingInertia
-			history.BacklogTolerance = backlogTolerance
-
-			history.L1PricingInertia = l1PricingInertia
-			history.L1EquilibrationUnits = l1EquilibrationUnits
-			history.L1PerUnitReward = l1PerUnitReward
-			history.L1PayRewardTo = l1PayRewardsTo.Hex()
+		if err != nil {
+			return history, err
 		}
+		history.MinBaseFee = minBaseFee
+		history.SpeedLimit = speedLimit
+		history.PerBlockGasLimit = perBlockGasLimit
+		history.PricingInertia = pricingInertia
+		history.BacklogTolerance = backlogTolerance
+
+		history.L1PricingInertia = l1PricingInertia
+		history.L1EquilibrationUnits = l1EquilibrationUnits
+		history.L1PerUnitReward = l1PerUnitReward
+		history.L1PayRewardTo = l1PayRewardsTo.Hex()
 	}
 
 	return history, nil
 }
 
+func (h *PricingModelHistory) truncate(blocks uint64, reason string) {
+	h.Timestamp = h.Timestamp[:blocks]
+	h.BaseFee = h.BaseFee[:blocks]
+	h.GasBacklog = h.GasBacklog[:blocks]
+	h.GasUsed = h.GasUsed[:blocks]
+	h.L1BaseFeeEstimate = h.L1BaseFeeEstimate[:blocks]
+	h.L1LastSurplus = h.L1LastSurplus[:blocks]
+	h.L1FundsDue = h.L1FundsDue[:blocks]
+	h.L1FundsDueForRewards = h.L1FundsDueForRewards[:blocks]
+	h.L1UnitsSinceUpdate = h.L1UnitsSinceUpdate[:blocks]
+	h.L1LastUpdateTime = h.L1LastUpdateTime[:blocks]
+	h.Truncated = reason
+}
+
 func (api *ArbDebugAPI) TimeoutQueueHistory(ctx context.Context, start, end rpc.BlockNumber) ([]uint64, error) {
 	start, _ = api.blockchain.ClipToPostNitroGenesis(start)
 	end, _ = api.blockchain.ClipToPostNitroGenesis(end)
@@ -189,7 +361,15 @@ func (api *ArbDebugAPI) TimeoutQueueHistory(ctx context.Context, start, end rpc.
 
 	history := make([]uint64, blocks)
 
+	// A truncated history is shorter than the requested range
+	budget, cancel := newRequestBudget(ctx, api.limits)
+	defer cancel()
+
 	for i := uint64(0); i < uint64(blocks); i++ {
+		if !budget.spend(8) {
+			logTruncated("arbdebug_timeoutQueueHistory", blocks, i, budget.truncated())
+			return history[:i], nil
+		}
 		state, _, err := stateAndHeader(api.blockchain, i+uint64(start))
 		if err != nil {
 			return history, err
@@ -208,6 +388,9 @@ type TimeoutQueue struct {
 	BlockNumber uint64        `json:"blockNumber"`
 	Tickets     []common.Hash `json:"tickets"`
 	Timeouts    []uint64      `json:"timeouts"`
+
+	// Set if the request ran out of budget, in which case only the start of the queue is included
+	Truncated string `json:"truncated,omitempty"`
 }
 
 func (api *ArbDebugAPI) TimeoutQueue(ctx context.Context, blockNum rpc.BlockNumber) (TimeoutQueue, error) {
@@ -225,7 +408,15 @@ func (api *ArbDebugAPI) TimeoutQueue(ctx context.Context, blockNum rpc.BlockNumb
 		return queue, err
 	}
 
+	budget, cancel := newRequestBudget(ctx, api.limits)
+	defer cancel()
+
 	closure := func(index uint64, ticket common.Hash) (bool, error) {
+		if !budget.spend(timeoutQueueBytesPerEntry) {
+			queue.Truncated = budget.truncated()
+			logTruncated("arbdebug_timeoutQueue", api.timeoutQueueBound, len(queue.Tickets), queue.Truncated)
+			return true, nil
+		}
 
 		// we don't care if the retryable has expired
 		retryable, err := state.RetryableState().OpenRetryable(ticket, 0)
@@ -268,3 +459,25 @@ func stateAndHeader(blockchain *core.BlockChain, block uint64) (*arbosState.Arbo
 	state, err := arbosState.OpenSystemArbosState(statedb, nil, true)
 	return state, header, err
 }
+
+// HealState fetches whatever trie nodes and contract code are missing from the state of a block
+// from the configured state healing sources, up to the configured node limit per call.
+func (api *ArbDebugAPI) HealState(ctx context.Context, blockNum rpc.BlockNumberOrHash) (*statehealer.HealResult, error) {
+	if api.stateHealer == nil {
+		return nil, errors.New("no state healing peer or snapshot configured")
+	}
+	header, err := arbitrum.HeaderByNumberOrHash(api.blockchain, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	return api.stateHealer.HealState(ctx, header.Root)
+}
+
+// HealNode fetches a single missing trie node or contract code, such as one named by a "missing trie node" error.
+func (api *ArbDebugAPI) HealNode(ctx context.Context, hash common.Hash) (hexutil.Uint64, error) {
+	if api.stateHealer == nil {
+		return 0, errors.New("no state healing peer or snapshot configured")
+	}
+	preimage, err := api.stateHealer.HealPreimage(ctx, hash)
+	return hexutil.Uint64(len(preimage)), err
+}
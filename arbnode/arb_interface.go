@@ -11,7 +11,7 @@ import (
 )
 
 type TransactionPublisher interface {
-	PublishTransaction(ctx context.Context, tx *types.Transaction) error
+	PublishTransaction(ctx context.Context, tx *types.Transaction, options *ConditionalOptions) error
 	Initialize(context.Context) error
 	Start(context.Context) error
 	StopAndWait()
@@ -35,7 +35,7 @@ func (a *ArbInterface) Initialize(n *Node) {
 }
 
 func (a *ArbInterface) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
-	return a.txPublisher.PublishTransaction(ctx, tx)
+	return a.txPublisher.PublishTransaction(ctx, tx, nil)
 }
 
 func (a *ArbInterface) TransactionStreamer() *TransactionStreamer {
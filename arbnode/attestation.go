@@ -0,0 +1,193 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos/arbosState"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+type AttestationConfig struct {
+	SigningKey string `koanf:"signing-key"`
+}
+
+var DefaultAttestationConfig = AttestationConfig{
+	SigningKey: "",
+}
+
+func AttestationConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".signing-key", DefaultAttestationConfig.SigningKey, "hex private key used to sign build attestations (defaults to the key of the batch poster's or validator's L1 wallet, unless it's held by an external signer)")
+}
+
+type BuildInfo struct {
+	Revision        string        `json:"revision"`
+	VcsTime         string        `json:"vcsTime"`
+	GoVersion       string        `json:"goVersion"`
+	ChainId         *hexutil.Big  `json:"chainId"`
+	ChainConfigHash common.Hash   `json:"chainConfigHash"`
+	ArbOSVersion    uint64        `json:"arbosVersion"`
+	LatestModule    common.Hash   `json:"latestModuleRoot"`
+	ModuleRoots     []common.Hash `json:"supportedModuleRoots"`
+}
+
+type BuildAttestation struct {
+	Info      BuildInfo      `json:"info"`
+	InfoHash  common.Hash    `json:"infoHash"`
+	Signer    common.Address `json:"signer,omitempty"`
+	Signature hexutil.Bytes  `json:"signature,omitempty"`
+}
+
+// The hash signed in an attestation is the keccak256 of the info's JSON encoding.
+func (i *BuildInfo) Hash() (common.Hash, error) {
+	encoded, err := json.Marshal(i)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// Verify checks the attestation's hash and signature, returning the address that signed it.
+func (a *BuildAttestation) Verify() (common.Address, error) {
+	infoHash, err := a.Info.Hash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	if infoHash != a.InfoHash {
+		return common.Address{}, errors.New("build attestation info hash mismatch")
+	}
+	if len(a.Signature) == 0 {
+		return common.Address{}, errors.New("build attestation is unsigned")
+	}
+	pubkey, err := crypto.SigToPub(infoHash.Bytes(), a.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := crypto.PubkeyToAddress(*pubkey)
+	if signer != a.Signer {
+		return common.Address{}, errors.New("build attestation signed by unexpected address")
+	}
+	return signer, nil
+}
+
+type ArbBuildAPI struct {
+	blockchain    *core.BlockChain
+	machineConfig validator.NitroMachineConfig
+	signer        func([]byte) ([]byte, error)
+	signerAddress common.Address
+}
+
+func NewArbBuildAPI(blockchain *core.BlockChain, machineConfig validator.NitroMachineConfig, config *AttestationConfig, fallbackSigner func([]byte) ([]byte, error)) (*ArbBuildAPI, error) {
+	signer, signerAddress, err := loadHashSigner(config.SigningKey, fallbackSigner)
+	if err != nil {
+		return nil, err
+	}
+	return &ArbBuildAPI{
+		blockchain:    blockchain,
+		machineConfig: machineConfig,
+		signer:        signer,
+		signerAddress: signerAddress,
+	}, nil
+}
+
+// loadHashSigner returns a signer of 32 byte hashes using the hex private key if set, or else the fallback signer,
+// along with its address. The signer is nil if neither is available.
+func loadHashSigner(signingKey string, fallbackSigner func([]byte) ([]byte, error)) (func([]byte) ([]byte, error), common.Address, error) {
+	if signingKey != "" {
+		privateKey, err := crypto.HexToECDSA(signingKey)
+		if err != nil {
+			return nil, common.Address{}, err
+		}
+		signer := func(data []byte) ([]byte, error) {
+			return crypto.Sign(data, privateKey)
+		}
+		return signer, crypto.PubkeyToAddress(privateKey.PublicKey), nil
+	}
+	if fallbackSigner == nil {
+		return nil, common.Address{}, nil
+	}
+	// Learn the fallback signer's address by recovering it from a signature
+	probe := crypto.Keccak256([]byte("nitro signer address probe"))
+	sig, err := fallbackSigner(probe)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	pubkey, err := crypto.SigToPub(probe, sig)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return fallbackSigner, crypto.PubkeyToAddress(*pubkey), nil
+}
+
+func (a *ArbBuildAPI) buildInfo() (BuildInfo, error) {
+	revision, vcsTime := genericconf.GetVersion()
+	chainConfig := a.blockchain.Config()
+	encodedConfig, err := json.Marshal(chainConfig)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	info := BuildInfo{
+		Revision:        revision,
+		VcsTime:         vcsTime,
+		GoVersion:       runtime.Version(),
+		ChainId:         (*hexutil.Big)(chainConfig.ChainID),
+		ChainConfigHash: crypto.Keccak256Hash(encodedConfig),
+		ModuleRoots:     []common.Hash{},
+	}
+	statedb, err := a.blockchain.State()
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	info.ArbOSVersion = arbosState.ArbOSVersion(statedb)
+
+	latest, err := a.machineConfig.ReadLatestWasmModuleRoot()
+	if err != nil {
+		log.Warn("failed to read latest wasm module root for build info", "err", err)
+	} else {
+		info.LatestModule = latest
+	}
+	moduleRoots, err := a.machineConfig.AvailableModuleRoots()
+	if err != nil {
+		log.Warn("failed to list wasm module roots for build info", "err", err)
+	} else {
+		info.ModuleRoots = moduleRoots
+	}
+	return info, nil
+}
+
+func (a *ArbBuildAPI) BuildAttestation(ctx context.Context) (BuildAttestation, error) {
+	info, err := a.buildInfo()
+	if err != nil {
+		return BuildAttestation{}, err
+	}
+	infoHash, err := info.Hash()
+	if err != nil {
+		return BuildAttestation{}, err
+	}
+	attestation := BuildAttestation{
+		Info:     info,
+		InfoHash: infoHash,
+	}
+	if a.signer != nil {
+		sig, err := a.signer(infoHash.Bytes())
+		if err != nil {
+			return BuildAttestation{}, err
+		}
+		attestation.Signer = a.signerAddress
+		attestation.Signature = sig
+	}
+	return attestation, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBuildAttestationVerify(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signer := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	info := BuildInfo{
+		Revision:     "abcdef",
+		VcsTime:      "2022-06-01T00:00:00Z",
+		GoVersion:    "go1.18",
+		ChainId:      (*hexutil.Big)(big.NewInt(412346)),
+		ArbOSVersion: 6,
+		ModuleRoots:  []common.Hash{common.HexToHash("0x01")},
+	}
+	infoHash, err := info.Hash()
+	Require(t, err)
+	sig, err := crypto.Sign(infoHash.Bytes(), privateKey)
+	Require(t, err)
+	attestation := BuildAttestation{
+		Info:      info,
+		InfoHash:  infoHash,
+		Signer:    signer,
+		Signature: sig,
+	}
+
+	recovered, err := attestation.Verify()
+	Require(t, err)
+	if recovered != signer {
+		Fail(t, "recovered signer", recovered, "expected", signer)
+	}
+
+	tampered := attestation
+	tampered.Info.Revision = "123456"
+	if _, err := tampered.Verify(); err == nil {
+		Fail(t, "tampered attestation verified")
+	}
+
+	unsigned := attestation
+	unsigned.Signature = nil
+	if _, err := unsigned.Verify(); err == nil {
+		Fail(t, "unsigned attestation verified")
+	}
+}
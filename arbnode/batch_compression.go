@@ -0,0 +1,97 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/arbstate"
+)
+
+var (
+	batchUncompressedSizeGauge     = metrics.NewRegisteredGauge("arb/batchposter/compression/uncompressed", nil)
+	batchCompressedSizeGauge       = metrics.NewRegisteredGauge("arb/batchposter/compression/compressed", nil)
+	batchCompressionRatioHistogram = metrics.NewRegisteredHistogram("arb/batchposter/compression/ratio", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
+// CompressionWriter is a streaming compressor. Flush must make all data written so far
+// visible in the underlying writer, so the batch poster can track the compressed size.
+type CompressionWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compressor is a batch compression algorithm. Its header byte is prepended to the compressed
+// batch so the inbox reader knows how to decompress it.
+type Compressor interface {
+	Name() string
+	HeaderByte() byte
+	NewWriter(w io.Writer) (CompressionWriter, error)
+}
+
+type brotliCompressor struct {
+	level int
+}
+
+func (c brotliCompressor) Name() string {
+	return "brotli"
+}
+
+func (c brotliCompressor) HeaderByte() byte {
+	return arbstate.BrotliMessageHeaderByte
+}
+
+func (c brotliCompressor) NewWriter(w io.Writer) (CompressionWriter, error) {
+	return brotli.NewWriterLevel(w, c.level), nil
+}
+
+type zstdCompressor struct {
+	level zstd.EncoderLevel
+}
+
+func (c zstdCompressor) Name() string {
+	return "zstd"
+}
+
+func (c zstdCompressor) HeaderByte() byte {
+	return arbstate.ZstdMessageHeaderByte
+}
+
+func (c zstdCompressor) NewWriter(w io.Writer) (CompressionWriter, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(c.level), zstd.WithEncoderConcurrency(1))
+}
+
+// NewCompressor returns the compressor for the given algorithm and level. Brotli levels
+// range from 0 to 11 and zstd levels from 1 to 22, higher levels trading CPU for size.
+func NewCompressor(algorithm string, level int) (Compressor, error) {
+	switch algorithm {
+	case "brotli":
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			return nil, fmt.Errorf("invalid brotli compression level %v", level)
+		}
+		return brotliCompressor{level}, nil
+	case "zstd":
+		if level < 1 || level > 22 {
+			return nil, fmt.Errorf("invalid zstd compression level %v", level)
+		}
+		return zstdCompressor{zstd.EncoderLevelFromZstd(level)}, nil
+	default:
+		return nil, fmt.Errorf("unknown batch compression algorithm \"%v\"", algorithm)
+	}
+}
+
+func recordBatchCompression(uncompressedSize int, compressedSize int) {
+	batchUncompressedSizeGauge.Update(int64(uncompressedSize))
+	batchCompressedSizeGauge.Update(int64(compressedSize))
+	if compressedSize > 0 {
+		// recorded as a percentage, as histograms only take integers
+		batchCompressionRatioHistogram.Update(int64(uncompressedSize * 100 / compressedSize))
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// The most compression settings simulated in one request, as each recompresses the whole backlog
+const maxSimulatedCompressionSettings = 16
+
+// CompressionSetting is a batch compression algorithm and level to simulate.
+type CompressionSetting struct {
+	Algorithm string `json:"algorithm"`
+	Level     int    `json:"level"`
+}
+
+var defaultSimulatedCompressionSettings = []CompressionSetting{
+	{"brotli", 0},
+	{"brotli", 2},
+	{"brotli", 6},
+	{"brotli", 9},
+	{"brotli", 11},
+	{"zstd", 1},
+	{"zstd", 3},
+	{"zstd", 9},
+	{"zstd", 19},
+}
+
+// CompressionSimulation is the next batch as one compression setting would build it. Better compression fits more
+// messages in a batch, so settings are best compared by their cost per message.
+type CompressionSimulation struct {
+	CompressionSetting
+	Current                bool         `json:"current"` // whether this is the batch poster's configured setting
+	Messages               uint64       `json:"messages"`
+	CompressedSize         uint64       `json:"compressedSize"`
+	CompressionRatio       float64      `json:"compressionRatio"`
+	CalldataCost           *hexutil.Big `json:"calldataCost"`
+	Blobs                  uint64       `json:"blobs"`
+	BlobCost               *hexutil.Big `json:"blobCost"`
+	CalldataCostPerMessage *hexutil.Big `json:"calldataCostPerMessage,omitempty"`
+	BlobCostPerMessage     *hexutil.Big `json:"blobCostPerMessage,omitempty"`
+	CompressionDuration    string       `json:"compressionDuration"`
+}
+
+// CompressionSimulationReport compares how the current batch posting backlog would be posted under different
+// compression settings, as calldata and as blobs, priced at the current L1 base fee and the given excess blob gas.
+type CompressionSimulationReport struct {
+	BatchSequenceNumber uint64                  `json:"batchSequenceNumber"`
+	BacklogMessages     uint64                  `json:"backlogMessages"`
+	L1BaseFee           *hexutil.Big            `json:"l1BaseFee"`
+	ExcessBlobGas       hexutil.Uint64          `json:"excessBlobGas"`
+	BlobBaseFee         *hexutil.Big            `json:"blobBaseFee"`
+	Simulations         []CompressionSimulation `json:"simulations"`
+}
+
+// SimulateCompression builds the next batch from the backlog under each compression setting, without disturbing the
+// batch being built. The L1 client can't report the excess blob gas, so blobs are priced at the one given.
+func (b *BatchPoster) SimulateCompression(ctx context.Context, settings []CompressionSetting, excessBlobGas uint64) (*CompressionSimulationReport, error) {
+	if len(settings) == 0 {
+		settings = defaultSimulatedCompressionSettings
+	}
+	if len(settings) > maxSimulatedCompressionSettings {
+		return nil, fmt.Errorf("can simulate at most %v compression settings at once", maxSimulatedCompressionSettings)
+	}
+	compressors := make([]Compressor, len(settings))
+	for i, setting := range settings {
+		compressor, err := NewCompressor(setting.Algorithm, setting.Level)
+		if err != nil {
+			return nil, err
+		}
+		compressors[i] = compressor
+	}
+
+	batchSeqNum, err := b.inbox.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	var prevBatchMeta BatchMetadata
+	if batchSeqNum > 0 {
+		prevBatchMeta, err = b.inbox.GetBatchMetadata(batchSeqNum - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	msgCount, err := b.streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	lastHeader, err := b.l1Reader.LastHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maxBatchSize := b.config.MaxBatchSize
+	if sizing := b.dynamicSizing(); sizing.Enable {
+		baseFee, err := b.parentGasPriceInFeeToken(lastHeader.BaseFee)
+		if err != nil {
+			return nil, err
+		}
+		maxBatchSize, _ = sizing.Target(baseFee, maxBatchSize, b.config.MaxBatchPostInterval)
+	}
+
+	report := &CompressionSimulationReport{
+		BatchSequenceNumber: batchSeqNum,
+		L1BaseFee:           (*hexutil.Big)(lastHeader.BaseFee),
+		ExcessBlobGas:       hexutil.Uint64(excessBlobGas),
+		BlobBaseFee:         (*hexutil.Big)(blobBaseFee(excessBlobGas)),
+	}
+	if msgCount > prevBatchMeta.MessageCount {
+		report.BacklogMessages = uint64(msgCount - prevBatchMeta.MessageCount)
+	}
+	for i, compressor := range compressors {
+		simulation, err := simulateCompression(ctx, compressor, prevBatchMeta, msgCount, maxBatchSize, b.streamer.GetMessage, lastHeader.BaseFee, excessBlobGas)
+		if err != nil {
+			return nil, err
+		}
+		simulation.CompressionSetting = settings[i]
+		simulation.Current = settings[i].Algorithm == b.config.Compression && settings[i].Level == b.config.CompressionLevel
+		report.Simulations = append(report.Simulations, *simulation)
+	}
+	return report, nil
+}
+
+// simulateCompression builds the batch following prevBatchMeta from the messages before msgCount with the compressor,
+// and prices posting it.
+func simulateCompression(
+	ctx context.Context,
+	compressor Compressor,
+	prevBatchMeta BatchMetadata,
+	msgCount arbutil.MessageIndex,
+	maxBatchSize int,
+	getMessage func(arbutil.MessageIndex) (arbstate.MessageWithMetadata, error),
+	l1BaseFee *big.Int,
+	excessBlobGas uint64,
+) (*CompressionSimulation, error) {
+	start := time.Now()
+	segments, err := newBatchSegments(prevBatchMeta.DelayedMessageCount, maxBatchSize, compressor)
+	if err != nil {
+		return nil, err
+	}
+	simulation := &CompressionSimulation{}
+	for pos := prevBatchMeta.MessageCount; pos < msgCount; pos++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg, err := getMessage(pos)
+		if err != nil {
+			return nil, err
+		}
+		success, err := segments.AddMessage(&msg)
+		if err != nil {
+			return nil, err
+		}
+		if !success {
+			break
+		}
+		simulation.Messages++
+	}
+	uncompressedSize := segments.totalUncompressedSize
+	data, err := segments.CloseAndGetBytes()
+	if err != nil {
+		return nil, err
+	}
+	simulation.CompressionDuration = time.Since(start).String()
+	simulation.CompressedSize = uint64(len(data))
+	if len(data) > 0 {
+		simulation.CompressionRatio = float64(uncompressedSize) / float64(len(data))
+	}
+	calldataCost, blobCost := batchPostingCosts(data, l1BaseFee, excessBlobGas)
+	simulation.CalldataCost = (*hexutil.Big)(calldataCost)
+	simulation.Blobs = uint64(blobsRequired(len(data)))
+	simulation.BlobCost = (*hexutil.Big)(blobCost)
+	if simulation.Messages > 0 {
+		messages := new(big.Int).SetUint64(simulation.Messages)
+		simulation.CalldataCostPerMessage = (*hexutil.Big)(new(big.Int).Div(calldataCost, messages))
+		simulation.BlobCostPerMessage = (*hexutil.Big)(new(big.Int).Div(blobCost, messages))
+	}
+	return simulation, nil
+}
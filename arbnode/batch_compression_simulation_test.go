@@ -0,0 +1,95 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func testBacklog(count int) func(arbutil.MessageIndex) (arbstate.MessageWithMetadata, error) {
+	return func(pos arbutil.MessageIndex) (arbstate.MessageWithMetadata, error) {
+		if int(pos) >= count {
+			return arbstate.MessageWithMetadata{}, fmt.Errorf("no message %v", pos)
+		}
+		return arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:        arbos.L1MessageType_L2Message,
+					BlockNumber: 100,
+					Timestamp:   1000 + uint64(pos),
+				},
+				L2msg: bytes.Repeat([]byte(fmt.Sprintf("transaction %v ", pos)), 20),
+			},
+		}, nil
+	}
+}
+
+func TestSimulateCompression(t *testing.T) {
+	ctx := context.Background()
+	baseFee := big.NewInt(30_000_000_000)
+	prev := BatchMetadata{MessageCount: 10}
+	backlog := testBacklog(60)
+
+	simulate := func(algorithm string, level int, maxBatchSize int) *CompressionSimulation {
+		compressor, err := NewCompressor(algorithm, level)
+		Require(t, err)
+		simulation, err := simulateCompression(ctx, compressor, prev, 60, maxBatchSize, backlog, baseFee, 0)
+		Require(t, err)
+		return simulation
+	}
+
+	fast := simulate("brotli", 0, 100000)
+	best := simulate("brotli", 11, 100000)
+	for _, simulation := range []*CompressionSimulation{fast, best} {
+		if simulation.Messages != 50 {
+			Fail(t, "the whole backlog fits in the batch, but only", simulation.Messages, "messages were added")
+		}
+		if simulation.CompressionRatio <= 1 {
+			Fail(t, "repetitive messages didn't compress", simulation.CompressionRatio)
+		}
+		if simulation.Blobs != 1 {
+			Fail(t, "batch should fit in one blob", simulation.Blobs)
+		}
+		perMessage := new(big.Int).Div(simulation.CalldataCost.ToInt(), big.NewInt(50))
+		if perMessage.Cmp(simulation.CalldataCostPerMessage.ToInt()) != 0 {
+			Fail(t, "calldata cost per message", simulation.CalldataCostPerMessage, "isn't the cost split over the messages", perMessage)
+		}
+	}
+	if best.CompressedSize >= fast.CompressedSize {
+		Fail(t, "higher compression level didn't shrink the batch", best.CompressedSize, fast.CompressedSize)
+	}
+	if best.CalldataCost.ToInt().Cmp(fast.CalldataCost.ToInt()) >= 0 {
+		Fail(t, "smaller batch didn't cost less as calldata")
+	}
+
+	// a batch size limit cuts the batch short
+	limited := simulate("brotli", 0, 400)
+	if limited.Messages == 0 || limited.Messages >= 50 {
+		Fail(t, "batch size limit didn't cut the batch short", limited.Messages)
+	}
+
+	// an empty backlog builds an empty batch
+	compressor, err := NewCompressor("zstd", 3)
+	Require(t, err)
+	empty, err := simulateCompression(ctx, compressor, BatchMetadata{MessageCount: 60}, 60, 100000, backlog, baseFee, 0)
+	Require(t, err)
+	if empty.Messages != 0 || empty.CalldataCostPerMessage != nil {
+		Fail(t, "empty backlog simulated with messages", empty)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := simulateCompression(canceled, compressor, prev, 60, 100000, backlog, baseFee, 0); !errors.Is(err, context.Canceled) {
+		Fail(t, "simulation not canceled", err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbstate"
+)
+
+func TestBatchCompressors(t *testing.T) {
+	for _, algorithm := range []string{"brotli", "zstd"} {
+		config := TestBatchPosterConfig
+		config.Compression = algorithm
+		compressor, err := NewCompressor(config.Compression, config.CompressionLevel)
+		Require(t, err)
+		segments, err := newBatchSegments(0, config.MaxBatchSize, compressor)
+		Require(t, err)
+
+		var messages [][]byte
+		for i := 0; i < 10; i++ {
+			msg := bytes.Repeat([]byte{byte(i)}, 100+i)
+			messages = append(messages, msg)
+			success, err := segments.addL2Msg(msg)
+			Require(t, err)
+			if !success {
+				Fail(t, algorithm, "failed to add message", i)
+			}
+		}
+		batch, err := segments.CloseAndGetBytes()
+		Require(t, err)
+
+		var reader io.Reader
+		switch batch[0] {
+		case arbstate.BrotliMessageHeaderByte:
+			reader = brotli.NewReader(bytes.NewReader(batch[1:]))
+		case arbstate.ZstdMessageHeaderByte:
+			decoder, err := zstd.NewReader(bytes.NewReader(batch[1:]))
+			Require(t, err)
+			defer decoder.Close()
+			reader = decoder
+		default:
+			Fail(t, algorithm, "unexpected header byte", batch[0])
+		}
+		if batch[0] != compressor.HeaderByte() {
+			Fail(t, algorithm, "wrong header byte", batch[0])
+		}
+		stream := rlp.NewStream(reader, 0)
+		for i, msg := range messages {
+			var segment []byte
+			Require(t, stream.Decode(&segment))
+			if segment[0] != arbstate.BatchSegmentKindL2Message || !bytes.Equal(segment[1:], msg) {
+				Fail(t, algorithm, "segment", i, "mismatch")
+			}
+		}
+	}
+
+	if _, err := NewCompressor("zstd", 0); err == nil {
+		Fail(t, "invalid zstd level accepted")
+	}
+	if _, err := NewCompressor("lz4", 1); err == nil {
+		Fail(t, "unknown algorithm accepted")
+	}
+}
+
+func TestArbOSVersionWithoutLocalExecution(t *testing.T) {
+	streamer, err := NewTransactionStreamer(rawdb.NewMemoryDatabase(), nil, nil, &DefaultTransactionStreamerConfig)
+	Require(t, err)
+	streamer.DisableExecution()
+	version, err := streamer.arbOSVersionAfter(0)
+	Require(t, err)
+	if version != 0 {
+		Fail(t, "unexpected ArbOS version", version, "before the first message")
+	}
+	// rather than waiting for messages which will never be executed, the tracker gives up on the batch
+	if _, err := streamer.arbOSVersionAfter(1); !errors.Is(err, errArbOSVersionUnknown) {
+		Fail(t, "expected errArbOSVersionUnknown without local execution, got", err)
+	}
+}
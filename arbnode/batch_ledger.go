@@ -0,0 +1,193 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+var (
+	ledgerCostCounter      = metrics.NewRegisteredCounter("arb/batchposter/ledger/cost", nil)      // in gwei
+	ledgerCollectedCounter = metrics.NewRegisteredCounter("arb/batchposter/ledger/collected", nil) // in gwei
+	ledgerRatioGauge       = metrics.NewRegisteredGaugeFloat64("arb/batchposter/ledger/ratio", nil)
+)
+
+// At most this many batches are included in a single ledger report
+const maxBatchLedgerReportBatches = 10000
+
+// batchLedgerEntry is what posting a batch cost on L1, and what the L2 transactions in it paid for their L1 data.
+type batchLedgerEntry struct {
+	SequenceNumber   uint64
+	TxHash           common.Hash
+	L1Block          uint64
+	FromMessage      arbutil.MessageIndex
+	ToMessage        arbutil.MessageIndex
+	L1GasUsed        uint64
+	L1Cost           *big.Int
+	L1FeesCollected  *big.Int
+	MissingL2Blocks  uint64 // blocks not yet produced when the entry was recorded, so missing from the collected fees
+	FirstL2Block     uint64
+	L2Transactions   uint64
+	RecordedUnixTime uint64
+}
+
+func readBatchLedgerEntry(db ethdb.KeyValueReader, seqNum uint64) (*batchLedgerEntry, error) {
+	data, err := db.Get(dbKey(batchLedgerPrefix, seqNum))
+	if err != nil {
+		return nil, err
+	}
+	var entry batchLedgerEntry
+	err = rlp.DecodeBytes(data, &entry)
+	return &entry, err
+}
+
+// l1FeesCollected sums what the L2 transactions produced by the messages paid for their L1 data.
+func l1FeesCollected(bc interface {
+	GetBlockByNumber(uint64) *types.Block
+	GetReceiptsByHash(common.Hash) types.Receipts
+}, firstBlock uint64, blockCount uint64) (*big.Int, uint64, uint64) {
+	collected := new(big.Int)
+	var missing, txs uint64
+	for number := firstBlock; number < firstBlock+blockCount; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			missing++
+			continue
+		}
+		receipts := bc.GetReceiptsByHash(block.Hash())
+		for _, receipt := range receipts {
+			collected.Add(collected, arbmath.BigMulByUint(block.BaseFee(), receipt.GasUsedForL1))
+		}
+		txs += uint64(len(receipts))
+	}
+	return collected, missing, txs
+}
+
+// recordBatchLedger persists the cost of a mined batch and the L1 fees collected from its transactions,
+// in the background, as the batch's blocks are read to find the fees.
+func (b *BatchPoster) recordBatchLedger(batchSeqNum uint64, receipt *types.Receipt, cost *big.Int, fromMsg arbutil.MessageIndex, toMsg arbutil.MessageIndex) {
+	b.LaunchThread(func(ctx context.Context) {
+		firstBlock, err := b.streamer.MessageCountToBlockNumber(fromMsg + 1)
+		if err != nil || firstBlock < 0 {
+			log.Warn("failed to find the first block of a posted batch", "sequence nr.", batchSeqNum, "err", err)
+			return
+		}
+		collected, missing, txs := l1FeesCollected(b.streamer.bc, uint64(firstBlock), uint64(toMsg-fromMsg))
+		entry := &batchLedgerEntry{
+			SequenceNumber:   batchSeqNum,
+			TxHash:           receipt.TxHash,
+			L1Block:          receipt.BlockNumber.Uint64(),
+			FromMessage:      fromMsg,
+			ToMessage:        toMsg,
+			L1GasUsed:        receipt.GasUsed,
+			L1Cost:           cost,
+			L1FeesCollected:  collected,
+			MissingL2Blocks:  missing,
+			FirstL2Block:     uint64(firstBlock),
+			L2Transactions:   txs,
+			RecordedUnixTime: uint64(time.Now().Unix()),
+		}
+		data, err := rlp.EncodeToBytes(entry)
+		if err != nil {
+			log.Error("failed to encode batch ledger entry", "sequence nr.", batchSeqNum, "err", err)
+			return
+		}
+		err = b.streamer.db.Put(dbKey(batchLedgerPrefix, batchSeqNum), data)
+		if err != nil {
+			log.Error("failed to write batch ledger entry", "sequence nr.", batchSeqNum, "err", err)
+			return
+		}
+		ledgerCostCounter.Inc(arbmath.BigDivByUint(cost, 1e9).Int64())
+		ledgerCollectedCounter.Inc(arbmath.BigDivByUint(collected, 1e9).Int64())
+		if cost.Sign() > 0 {
+			ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(collected), new(big.Float).SetInt(cost)).Float64()
+			ledgerRatioGauge.Update(ratio)
+		}
+		log.Info("BatchPoster: recorded batch ledger entry", "sequence nr.", batchSeqNum, "cost", cost, "collected", collected, "missingBlocks", missing)
+	})
+}
+
+type BatchLedgerEntry struct {
+	SequenceNumber  hexutil.Uint64       `json:"sequenceNumber"`
+	TxHash          common.Hash          `json:"txHash"`
+	L1Block         hexutil.Uint64       `json:"l1Block"`
+	FromMessage     arbutil.MessageIndex `json:"fromMessage"`
+	ToMessage       arbutil.MessageIndex `json:"toMessage"`
+	FirstL2Block    hexutil.Uint64       `json:"firstL2Block"`
+	L2Transactions  hexutil.Uint64       `json:"l2Transactions"`
+	L1GasUsed       hexutil.Uint64       `json:"l1GasUsed"`
+	L1Cost          *hexutil.Big         `json:"l1Cost"`
+	L1FeesCollected *hexutil.Big         `json:"l1FeesCollected"`
+	MissingL2Blocks hexutil.Uint64       `json:"missingL2Blocks,omitempty"`
+	RecordedAt      time.Time            `json:"recordedAt"`
+}
+
+// BatchLedgerReport reconciles what posting batches cost on L1 with what ArbOS charged the L2 transactions
+// in them for their L1 data. A ratio persistently away from 1 means ArbOS's L1 pricing is drifting from
+// actual posting costs. Batches posted by other nodes, or before the ledger existed, are missing.
+type BatchLedgerReport struct {
+	Entries         []BatchLedgerEntry `json:"entries"`
+	MissingBatches  hexutil.Uint64     `json:"missingBatches"`
+	TotalCost       *hexutil.Big       `json:"totalCost"`
+	TotalCollected  *hexutil.Big       `json:"totalCollected"`
+	Surplus         *hexutil.Big       `json:"surplus"` // collected minus cost, negative if ArbOS undercharged
+	CollectedToCost float64            `json:"collectedToCost,omitempty"`
+}
+
+// BatchLedgerReportFor reconciles posting costs and collected fees of the batches in [from, to).
+func BatchLedgerReportFor(db ethdb.KeyValueReader, from uint64, to uint64) (*BatchLedgerReport, error) {
+	if to < from {
+		return nil, errors.New("batch ledger range end before start")
+	}
+	if to-from > maxBatchLedgerReportBatches {
+		return nil, errors.New("batch ledger range too large")
+	}
+	report := &BatchLedgerReport{Entries: []BatchLedgerEntry{}}
+	totalCost := new(big.Int)
+	totalCollected := new(big.Int)
+	for seqNum := from; seqNum < to; seqNum++ {
+		entry, err := readBatchLedgerEntry(db, seqNum)
+		if err != nil {
+			report.MissingBatches++
+			continue
+		}
+		totalCost.Add(totalCost, entry.L1Cost)
+		totalCollected.Add(totalCollected, entry.L1FeesCollected)
+		report.Entries = append(report.Entries, BatchLedgerEntry{
+			SequenceNumber:  hexutil.Uint64(entry.SequenceNumber),
+			TxHash:          entry.TxHash,
+			L1Block:         hexutil.Uint64(entry.L1Block),
+			FromMessage:     entry.FromMessage,
+			ToMessage:       entry.ToMessage,
+			FirstL2Block:    hexutil.Uint64(entry.FirstL2Block),
+			L2Transactions:  hexutil.Uint64(entry.L2Transactions),
+			L1GasUsed:       hexutil.Uint64(entry.L1GasUsed),
+			L1Cost:          (*hexutil.Big)(entry.L1Cost),
+			L1FeesCollected: (*hexutil.Big)(entry.L1FeesCollected),
+			MissingL2Blocks: hexutil.Uint64(entry.MissingL2Blocks),
+			RecordedAt:      time.Unix(int64(entry.RecordedUnixTime), 0),
+		})
+	}
+	report.TotalCost = (*hexutil.Big)(totalCost)
+	report.TotalCollected = (*hexutil.Big)(totalCollected)
+	report.Surplus = (*hexutil.Big)(new(big.Int).Sub(totalCollected, totalCost))
+	if totalCost.Sign() > 0 {
+		report.CollectedToCost, _ = new(big.Float).Quo(new(big.Float).SetInt(totalCollected), new(big.Float).SetInt(totalCost)).Float64()
+	}
+	return report, nil
+}
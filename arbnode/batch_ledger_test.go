@@ -0,0 +1,44 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestBatchLedgerReport(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	entries := []*batchLedgerEntry{
+		{SequenceNumber: 3, L1Cost: big.NewInt(1000), L1FeesCollected: big.NewInt(900)},
+		{SequenceNumber: 5, L1Cost: big.NewInt(3000), L1FeesCollected: big.NewInt(2100)},
+	}
+	for _, entry := range entries {
+		data, err := rlp.EncodeToBytes(entry)
+		Require(t, err)
+		Require(t, db.Put(dbKey(batchLedgerPrefix, entry.SequenceNumber), data))
+	}
+
+	report, err := BatchLedgerReportFor(db, 2, 6)
+	Require(t, err)
+	if len(report.Entries) != 2 || report.MissingBatches != 2 {
+		Fail(t, "unexpected entries", report.Entries, "missing", report.MissingBatches)
+	}
+	if report.TotalCost.ToInt().Int64() != 4000 || report.TotalCollected.ToInt().Int64() != 3000 {
+		Fail(t, "unexpected totals", report.TotalCost, report.TotalCollected)
+	}
+	if report.Surplus.ToInt().Int64() != -1000 || report.CollectedToCost != 0.75 {
+		Fail(t, "unexpected reconciliation", report.Surplus, report.CollectedToCost)
+	}
+
+	if _, err := BatchLedgerReportFor(db, 6, 2); err == nil {
+		Fail(t, "accepted reversed range")
+	}
+	if _, err := BatchLedgerReportFor(db, 0, maxBatchLedgerReportBatches+1); err == nil {
+		Fail(t, "accepted too large a range")
+	}
+}
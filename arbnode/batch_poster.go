@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/offchainlabs/nitro/arbos"
@@ -25,9 +26,12 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
 	"github.com/offchainlabs/nitro/das"
 	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
 	"github.com/offchainlabs/nitro/util/arbmath"
+	"github.com/offchainlabs/nitro/util/featureflags"
+	"github.com/offchainlabs/nitro/util/openmetrics"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 )
 
@@ -39,25 +43,53 @@ type BatchPoster struct {
 	config              *BatchPosterConfig
 	inboxContract       *bridgegen.SequencerInbox
 	gasRefunder         common.Address
-	transactOpts        *bind.TransactOpts
+	wallets             *batchPosterWallets
 	building            *buildingBatch
 	pendingMsgTimestamp time.Time
 	lastBatchCount      uint64
 	das                 das.DataAvailabilityService
+	compressor          Compressor
+	feeTokenPrice       *FeeTokenPriceFeed // nil if the fee token is the parent chain's gas token
+	escapeHatch         *escapeHatch       // nil if disabled
+	broadcaster         *batchBroadcaster  // nil if there are no redundant endpoints
+	safe                *batchPosterSafe   // nil if posting directly
+	dryRun              *dryRunPosition    // the end of the last batch built in dry run mode
+	featureFlags        *featureflags.Flags
+	delaySeconds        uint64 // the sequencer inbox's max time variation, lazily loaded
+
+	feeBumpMutex sync.Mutex
+	feeBumpState *FeeBumpState
+	spending     spendTracker
+	nonceGaps    nonceGapTracker
+	breaker      circuitBreaker
 }
 
 type BatchPosterConfig struct {
-	Enable                             bool          `koanf:"enable"`
-	DisableDasFallbackStoreDataOnChain bool          `koanf:"disable-das-fallback-store-data-on-chain"`
-	MaxBatchSize                       int           `koanf:"max-size"`
-	MaxBatchPostInterval               time.Duration `koanf:"max-interval"`
-	BatchPollDelay                     time.Duration `koanf:"poll-delay"`
-	PostingErrorDelay                  time.Duration `koanf:"error-delay"`
-	CompressionLevel                   int           `koanf:"compression-level"`
-	DASRetentionPeriod                 time.Duration `koanf:"das-retention-period"`
-	HighGasThreshold                   float32       `koanf:"high-gas-threshold"`
-	HighGasDelay                       time.Duration `koanf:"high-gas-delay"`
-	GasRefunderAddress                 string        `koanf:"gas-refunder-address"`
+	Enable                             bool                     `koanf:"enable"`
+	DisableDasFallbackStoreDataOnChain bool                     `koanf:"disable-das-fallback-store-data-on-chain"`
+	MaxBatchSize                       int                      `koanf:"max-size"`
+	MaxBatchPostInterval               time.Duration            `koanf:"max-interval"`
+	MaxDelay                           time.Duration            `koanf:"max-delay"`
+	MaxDelayReserve                    time.Duration            `koanf:"max-delay-reserve"`
+	BatchPollDelay                     time.Duration            `koanf:"poll-delay"`
+	PostingErrorDelay                  time.Duration            `koanf:"error-delay"`
+	Compression                        string                   `koanf:"compression"`
+	CompressionLevel                   int                      `koanf:"compression-level"`
+	DASRetentionPeriod                 time.Duration            `koanf:"das-retention-period"`
+	HighGasThreshold                   float32                  `koanf:"high-gas-threshold"`
+	HighGasDelay                       time.Duration            `koanf:"high-gas-delay"`
+	GasRefunderAddress                 string                   `koanf:"gas-refunder-address"`
+	DynamicSizing                      DynamicBatchSizingConfig `koanf:"dynamic-sizing"`
+	FallbackWallet                     genericconf.WalletConfig `koanf:"fallback-wallet"`
+	FallbackAccounts                   []string                 `koanf:"fallback-accounts"`
+	WalletMinBalance                   float64                  `koanf:"wallet-min-balance"`
+	FeeBump                            FeeBumpConfig            `koanf:"fee-bump"`
+	EscapeHatch                        EscapeHatchConfig        `koanf:"escape-hatch"`
+	RedundantPosting                   RedundantPostingConfig   `koanf:"redundant-posting"`
+	DryRun                             bool                     `koanf:"dry-run"`
+	NonceRecovery                      NonceRecoveryConfig      `koanf:"nonce-recovery"`
+	Safe                               SafeConfig               `koanf:"safe"`
+	CircuitBreaker                     CircuitBreakerConfig     `koanf:"circuit-breaker"`
 }
 
 func BatchPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -65,13 +97,27 @@ func BatchPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".disable-das-fallback-store-data-on-chain", DefaultBatchPosterConfig.DisableDasFallbackStoreDataOnChain, "If unable to batch to DAS, disable fallback storing data on chain")
 	f.Int(prefix+".max-size", DefaultBatchPosterConfig.MaxBatchSize, "maximum batch size")
 	f.Duration(prefix+".max-interval", DefaultBatchPosterConfig.MaxBatchPostInterval, "maximum batch posting interval")
+	f.Duration(prefix+".max-delay", DefaultBatchPosterConfig.MaxDelay, "hard deadline for the oldest unposted message to be in a mined batch, overriding size and gas price thresholds (0 = disabled)")
+	f.Duration(prefix+".max-delay-reserve", DefaultBatchPosterConfig.MaxDelayReserve, "how long before the max-delay deadline to post the batch, bidding gas aggressively until it's mined")
 	f.Duration(prefix+".poll-delay", DefaultBatchPosterConfig.BatchPollDelay, "how long to delay after successfully posting batch")
 	f.Duration(prefix+".error-delay", DefaultBatchPosterConfig.PostingErrorDelay, "how long to delay after error posting batch")
-	f.Int(prefix+".compression-level", DefaultBatchPosterConfig.CompressionLevel, "batch compression level")
+	f.String(prefix+".compression", DefaultBatchPosterConfig.Compression, "batch compression algorithm (brotli or zstd, which falls back to brotli until ArbOS version 5)")
+	f.Int(prefix+".compression-level", DefaultBatchPosterConfig.CompressionLevel, "batch compression level (0-11 for brotli, 1-22 for zstd), higher levels trade CPU for smaller batches")
 	f.Duration(prefix+".das-retention-period", DefaultBatchPosterConfig.DASRetentionPeriod, "In AnyTrust mode, the period which DASes are requested to retain the stored batches.")
-	f.Float32(prefix+".high-gas-threshold", DefaultBatchPosterConfig.HighGasThreshold, "If the gas price in gwei is above this amount, delay posting a batch")
+	f.Float32(prefix+".high-gas-threshold", DefaultBatchPosterConfig.HighGasThreshold, "If the gas price in gwei is above this amount, delay posting a batch (priced in the fee token if a fee token oracle is configured)")
 	f.Duration(prefix+".high-gas-delay", DefaultBatchPosterConfig.HighGasDelay, "The maximum delay while waiting for the gas price to go below the high gas threshold")
 	f.String(prefix+".gas-refunder-address", DefaultBatchPosterConfig.GasRefunderAddress, "The gas refunder contract address (optional)")
+	DynamicBatchSizingConfigAddOptions(prefix+".dynamic-sizing", f)
+	genericconf.WalletConfigAddOptions(prefix+".fallback-wallet", f, DefaultBatchPosterConfig.FallbackWallet.Pathname)
+	f.StringSlice(prefix+".fallback-accounts", DefaultBatchPosterConfig.FallbackAccounts, "accounts of the fallback wallet to fail over to in order, each of which must be an authorized batch poster (default is the fallback wallet's account)")
+	f.Float64(prefix+".wallet-min-balance", DefaultBatchPosterConfig.WalletMinBalance, "fail over to the next batch poster wallet when the active one's balance in ether is below this (0 = disabled)")
+	FeeBumpConfigAddOptions(prefix+".fee-bump", f)
+	EscapeHatchConfigAddOptions(prefix+".escape-hatch", f)
+	RedundantPostingConfigAddOptions(prefix+".redundant-posting", f)
+	f.Bool(prefix+".dry-run", DefaultBatchPosterConfig.DryRun, "build, compress and sign batches but log them instead of posting them to L1, and store them in a DAS backend which discards them")
+	NonceRecoveryConfigAddOptions(prefix+".nonce-recovery", f)
+	SafeConfigAddOptions(prefix+".safe", f)
+	CircuitBreakerConfigAddOptions(prefix+".circuit-breaker", f)
 }
 
 var DefaultBatchPosterConfig = BatchPosterConfig{
@@ -81,11 +127,25 @@ var DefaultBatchPosterConfig = BatchPosterConfig{
 	BatchPollDelay:                     time.Second * 10,
 	PostingErrorDelay:                  time.Second * 10,
 	MaxBatchPostInterval:               time.Hour,
+	MaxDelay:                           0,
+	MaxDelayReserve:                    15 * time.Minute,
+	Compression:                        "brotli",
 	CompressionLevel:                   brotli.DefaultCompression,
 	DASRetentionPeriod:                 time.Hour * 24 * 15,
 	HighGasThreshold:                   150.,
 	HighGasDelay:                       14 * time.Hour,
 	GasRefunderAddress:                 "",
+	DynamicSizing:                      DefaultDynamicBatchSizingConfig,
+	FallbackWallet:                     genericconf.WalletConfigDefault,
+	FallbackAccounts:                   []string{},
+	WalletMinBalance:                   0,
+	FeeBump:                            DefaultFeeBumpConfig,
+	EscapeHatch:                        DefaultEscapeHatchConfig,
+	RedundantPosting:                   DefaultRedundantPostingConfig,
+	DryRun:                             false,
+	NonceRecovery:                      DefaultNonceRecoveryConfig,
+	Safe:                               DefaultSafeConfig,
+	CircuitBreaker:                     DefaultCircuitBreakerConfig,
 }
 
 var TestBatchPosterConfig = BatchPosterConfig{
@@ -94,48 +154,142 @@ var TestBatchPosterConfig = BatchPosterConfig{
 	BatchPollDelay:       time.Millisecond * 10,
 	PostingErrorDelay:    time.Millisecond * 10,
 	MaxBatchPostInterval: 0,
+	MaxDelay:             0,
+	MaxDelayReserve:      15 * time.Minute,
+	Compression:          "brotli",
 	CompressionLevel:     2,
 	DASRetentionPeriod:   time.Hour * 24 * 15,
 	HighGasThreshold:     0.,
 	HighGasDelay:         0,
 	GasRefunderAddress:   "",
+	DynamicSizing:        DefaultDynamicBatchSizingConfig,
+	FeeBump:              DefaultFeeBumpConfig,
+	EscapeHatch:          DefaultEscapeHatchConfig,
+	RedundantPosting:     DefaultRedundantPostingConfig,
+	DryRun:               false,
+	NonceRecovery:        DefaultNonceRecoveryConfig,
+	Safe:                 DefaultSafeConfig,
+	CircuitBreaker:       DefaultCircuitBreakerConfig,
 }
 
-func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, streamer *TransactionStreamer, config *BatchPosterConfig, contractAddress common.Address, transactOpts *bind.TransactOpts, das das.DataAvailabilityService) (*BatchPoster, error) {
+func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, streamer *TransactionStreamer, config *BatchPosterConfig, contractAddress common.Address, delayedInboxAddress common.Address, transactOpts *bind.TransactOpts, fallbackOpts []*bind.TransactOpts, das das.DataAvailabilityService, feeTokenPrice *FeeTokenPriceFeed, featureFlags *featureflags.Flags) (*BatchPoster, error) {
 	inboxContract, err := bridgegen.NewSequencerInbox(contractAddress, l1Reader.Client())
 	if err != nil {
 		return nil, err
 	}
+	if config.DynamicSizing.Enable && config.DynamicSizing.MinSize <= 40 {
+		return nil, errors.New("dynamic batch sizing min-size too small")
+	}
+	if config.MaxDelay != 0 && config.MaxDelay <= config.MaxDelayReserve {
+		return nil, errors.New("batch poster max-delay must be longer than max-delay-reserve")
+	}
+	if (config.FeeBump.Enable || config.MaxDelay != 0) && (config.FeeBump.Factor < 1.1 || config.FeeBump.AggressiveFactor < 1.1 || config.FeeBump.Interval <= 0) {
+		return nil, errors.New("fee bump factors must be at least 1.1 and the interval positive")
+	}
 	if len(config.GasRefunderAddress) > 0 && !common.IsHexAddress(config.GasRefunderAddress) {
 		return nil, fmt.Errorf("invalid gas refunder address \"%v\"", config.GasRefunderAddress)
 	}
+	if config.CircuitBreaker.Enable && config.CircuitBreaker.MaxReverts <= 0 {
+		return nil, errors.New("batch poster circuit breaker max-reverts must be positive")
+	}
+	compressor, err := NewCompressor(config.Compression, config.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	var hatch *escapeHatch
+	if config.EscapeHatch.Enable && !config.DryRun {
+		hatch, err = newEscapeHatch(&config.EscapeHatch, delayedInboxAddress, l1Reader.Client())
+		if err != nil {
+			return nil, err
+		}
+	}
+	broadcaster, err := newBatchBroadcaster(&config.RedundantPosting, l1Reader.Client())
+	if err != nil {
+		return nil, err
+	}
+	safe, err := newBatchPosterSafe(&config.Safe, contractAddress, l1Reader.Client())
+	if err != nil {
+		return nil, err
+	}
 	return &BatchPoster{
 		l1Reader:      l1Reader,
 		inbox:         inbox,
 		streamer:      streamer,
 		config:        config,
 		inboxContract: inboxContract,
-		transactOpts:  transactOpts,
+		wallets:       newBatchPosterWallets(transactOpts, fallbackOpts),
 		gasRefunder:   common.HexToAddress(config.GasRefunderAddress),
 		das:           das,
+		compressor:    compressor,
+		feeTokenPrice: feeTokenPrice,
+		escapeHatch:   hatch,
+		broadcaster:   broadcaster,
+		safe:          safe,
+		featureFlags:  featureFlags,
+		breaker:       circuitBreaker{coolDown: config.CircuitBreaker.CoolDown},
 	}, nil
 }
 
+// dynamicSizing returns the dynamic batch sizing config in effect, which the adaptive-batching feature flag also enables.
+func (b *BatchPoster) dynamicSizing() DynamicBatchSizingConfig {
+	sizing := b.config.DynamicSizing
+	if !sizing.Enable && b.featureFlags.Enabled(featureflags.AdaptiveBatching) && sizing.MinSize > 40 {
+		sizing.Enable = true
+	}
+	return sizing
+}
+
+// nearMaxDelay returns whether a batch whose oldest message is this old must be posted now to meet the max delay.
+func (b *BatchPoster) nearMaxDelay(sinceOldestMessage time.Duration) bool {
+	return b.config.MaxDelay != 0 && sinceOldestMessage >= b.config.MaxDelay-b.config.MaxDelayReserve
+}
+
+// maxDelayAggressiveTime moves aggressiveAt earlier if needed, so fees escalate aggressively once a batch
+// whose oldest message has the given timestamp is within the reserve of its max delay deadline.
+func (b *BatchPoster) maxDelayAggressiveTime(oldestMessage time.Time, aggressiveAt time.Time) time.Time {
+	if b.config.MaxDelay == 0 {
+		return aggressiveAt
+	}
+	deadlineAggressiveAt := oldestMessage.Add(b.config.MaxDelay - b.config.MaxDelayReserve)
+	if aggressiveAt.IsZero() || deadlineAggressiveAt.Before(aggressiveAt) {
+		return deadlineAggressiveAt
+	}
+	return aggressiveAt
+}
+
+// parentGasPriceInFeeToken converts an L1 gas price into the chain's fee token, so gas price thresholds
+// are compared in the same token L2 gas estimation uses.
+func (b *BatchPoster) parentGasPriceInFeeToken(price *big.Int) (*big.Int, error) {
+	if b.feeTokenPrice == nil {
+		return price, nil
+	}
+	return b.feeTokenPrice.ToFeeToken(price)
+}
+
+// feeTokenGasPriceInParent is the inverse of parentGasPriceInFeeToken.
+func (b *BatchPoster) feeTokenGasPriceInParent(price *big.Int) (*big.Int, error) {
+	if b.feeTokenPrice == nil {
+		return price, nil
+	}
+	return b.feeTokenPrice.ToParentGasToken(price)
+}
+
 var errBatchAlreadyClosed = errors.New("batch segments already closed")
 
 type batchSegments struct {
-	compressedBuffer    *bytes.Buffer
-	compressedWriter    *brotli.Writer
-	rawSegments         [][]byte
-	timestamp           uint64
-	blockNum            uint64
-	delayedMsg          uint64
-	sizeLimit           int
-	compressionLevel    int
-	newUncompressedSize int
-	lastCompressedSize  int
-	trailingHeaders     int // how many trailing segments are headers
-	isDone              bool
+	compressedBuffer      *bytes.Buffer
+	compressedWriter      CompressionWriter
+	compressor            Compressor
+	rawSegments           [][]byte
+	timestamp             uint64
+	blockNum              uint64
+	delayedMsg            uint64
+	sizeLimit             int
+	newUncompressedSize   int
+	totalUncompressedSize int
+	lastCompressedSize    int
+	trailingHeaders       int // how many trailing segments are headers
+	isDone                bool
 }
 
 type buildingBatch struct {
@@ -144,25 +298,51 @@ type buildingBatch struct {
 	msgCount    arbutil.MessageIndex
 }
 
-func newBatchSegments(firstDelayed uint64, config *BatchPosterConfig) *batchSegments {
-	compressedBuffer := bytes.NewBuffer(make([]byte, 0, config.MaxBatchSize*2))
-	if config.MaxBatchSize <= 40 {
+// compressorFor returns the compressor to build a batch starting after count messages with. Nodes only decode zstd
+// batches once the ArbOS version before them supports it, so until then brotli is used instead.
+func (b *BatchPoster) compressorFor(count arbutil.MessageIndex) (Compressor, error) {
+	if b.compressor.HeaderByte() != arbstate.ZstdMessageHeaderByte {
+		return b.compressor, nil
+	}
+	version, err := b.streamer.arbOSVersionAfter(count)
+	if err != nil {
+		return nil, err
+	}
+	if version < arbstate.ZstdArbosVersion {
+		log.Warn("posting batch with brotli as ArbOS doesn't support zstd yet", "arbosVersion", version, "zstdArbosVersion", arbstate.ZstdArbosVersion)
+		return brotliCompressor{DefaultBatchPosterConfig.CompressionLevel}, nil
+	}
+	return b.compressor, nil
+}
+
+func newBatchSegments(firstDelayed uint64, maxSize int, compressor Compressor) (*batchSegments, error) {
+	compressedBuffer := bytes.NewBuffer(make([]byte, 0, maxSize*2))
+	if maxSize <= 40 {
 		panic("MaxBatchSize too small")
 	}
+	compressedWriter, err := compressor.NewWriter(compressedBuffer)
+	if err != nil {
+		return nil, err
+	}
 	return &batchSegments{
 		compressedBuffer: compressedBuffer,
-		compressedWriter: brotli.NewWriterLevel(compressedBuffer, config.CompressionLevel),
-		sizeLimit:        config.MaxBatchSize - 40, // TODO
-		compressionLevel: config.CompressionLevel,
+		compressedWriter: compressedWriter,
+		compressor:       compressor,
+		sizeLimit:        maxSize - 40, // TODO
 		rawSegments:      make([][]byte, 0, 128),
 		delayedMsg:       firstDelayed,
-	}
+	}, nil
 }
 
 func (s *batchSegments) recompressAll() error {
 	s.compressedBuffer = bytes.NewBuffer(make([]byte, 0, s.sizeLimit*2))
-	s.compressedWriter = brotli.NewWriterLevel(s.compressedBuffer, s.compressionLevel)
+	compressedWriter, err := s.compressor.NewWriter(s.compressedBuffer)
+	if err != nil {
+		return err
+	}
+	s.compressedWriter = compressedWriter
 	s.newUncompressedSize = 0
+	s.totalUncompressedSize = 0
 	for _, segment := range s.rawSegments {
 		err := s.addSegmentToCompressed(segment)
 		if err != nil {
@@ -211,6 +391,7 @@ func (s *batchSegments) addSegmentToCompressed(segment []byte) error {
 	}
 	lenWritten, err := s.compressedWriter.Write(encoded)
 	s.newUncompressedSize += lenWritten
+	s.totalUncompressedSize += lenWritten
 	return err
 }
 
@@ -326,7 +507,7 @@ func (s *batchSegments) CloseAndGetBytes() ([]byte, error) {
 	}
 	compressedBytes := s.compressedBuffer.Bytes()
 	fullMsg := make([]byte, 1, len(compressedBytes)+1)
-	fullMsg[0] = arbstate.BrotliMessageHeaderByte
+	fullMsg[0] = s.compressor.HeaderByte()
 	fullMsg = append(fullMsg, compressedBytes...)
 	return fullMsg, nil
 }
@@ -353,10 +534,34 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum u
 			return nil, err
 		}
 	}
+	maxBatchSize, maxBatchPostInterval := b.config.MaxBatchSize, b.config.MaxBatchPostInterval
+	if sizing := b.dynamicSizing(); sizing.Enable {
+		lastHeader, err := b.l1Reader.LastHeader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		baseFee, err := b.parentGasPriceInFeeToken(lastHeader.BaseFee)
+		if err != nil {
+			return nil, err
+		}
+		maxBatchSize, maxBatchPostInterval = sizing.Target(baseFee, maxBatchSize, maxBatchPostInterval)
+	}
+	startMsgCount, startDelayed := prevBatchMeta.MessageCount, prevBatchMeta.DelayedMessageCount
+	if dryRunMsgCount, dryRunDelayed, ok := b.dryRunStart(prevBatchMeta); ok {
+		startMsgCount, startDelayed = dryRunMsgCount, dryRunDelayed
+	}
 	if b.building == nil || b.building.batchSeqNum != batchSeqNum {
+		compressor, err := b.compressorFor(startMsgCount)
+		if err != nil {
+			return nil, err
+		}
+		segments, err := newBatchSegments(startDelayed, maxBatchSize, compressor)
+		if err != nil {
+			return nil, err
+		}
 		b.building = &buildingBatch{
-			segments:    newBatchSegments(prevBatchMeta.DelayedMessageCount, b.config),
-			msgCount:    prevBatchMeta.MessageCount,
+			segments:    segments,
+			msgCount:    startMsgCount,
 			batchSeqNum: batchSeqNum,
 		}
 	}
@@ -365,7 +570,13 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum u
 		return nil, err
 	}
 
-	forcePostBatch := timeSinceNextMessage >= b.config.MaxBatchPostInterval
+	if b.config.MaxDelay != 0 {
+		// Post early enough for the batch to be mined before the deadline, however the batch is sized
+		if postBy := b.config.MaxDelay - b.config.MaxDelayReserve; postBy < maxBatchPostInterval {
+			maxBatchPostInterval = postBy
+		}
+	}
+	forcePostBatch := timeSinceNextMessage >= maxBatchPostInterval
 	haveUsefulMessage := false
 
 	for b.building.msgCount < msgCount {
@@ -410,8 +621,10 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum u
 		b.building = nil // a closed batchSegments can't be reused
 		return nil, nil
 	}
+	recordBatchCompression(b.building.segments.totalUncompressedSize, len(sequencerMsg)-1)
 
 	if b.das != nil {
+		// in dry run mode b.das stores into a backend which discards the batch
 		cert, err := b.das.Store(ctx, sequencerMsg, uint64(time.Now().Add(b.config.DASRetentionPeriod).Unix()), []byte{}) // b.das will append signature if enabled
 		if err != nil {
 			log.Warn("Unable to batch to DAS, falling back to storing data on chain", "err", err)
@@ -423,15 +636,30 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum u
 		}
 	}
 
-	txOpts := *b.transactOpts
+	txOpts := *b.wallets.current()
 	txOpts.Context = ctx
 	txOpts.NoSend = true
-	tx, err := b.inboxContract.AddSequencerL2BatchFromOrigin(&txOpts, new(big.Int).SetUint64(batchSeqNum), sequencerMsg, new(big.Int).SetUint64(b.building.segments.delayedMsg), b.gasRefunder)
+	replacingNonce := false
+	if b.config.NonceRecovery.Enable && !b.config.DryRun {
+		replacingNonce, err = b.reconcileNonce(ctx, &txOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var tx *types.Transaction
+	if b.safe != nil {
+		tx, err = b.safe.addSequencerL2Batch(ctx, &txOpts, batchSeqNum, sequencerMsg, b.building.segments.delayedMsg, b.gasRefunder)
+	} else {
+		tx, err = b.inboxContract.AddSequencerL2BatchFromOrigin(&txOpts, new(big.Int).SetUint64(batchSeqNum), sequencerMsg, new(big.Int).SetUint64(b.building.segments.delayedMsg), b.gasRefunder)
+	}
 	if err != nil {
 		return nil, err
 	}
-	highGasThreshold := new(big.Int).SetUint64(uint64(b.config.HighGasThreshold * params.GWei))
-	if b.config.HighGasThreshold != 0 && tx.GasFeeCap().Cmp(highGasThreshold) >= 0 && timeSinceNextMessage < b.config.HighGasDelay {
+	highGasThreshold, err := b.feeTokenGasPriceInParent(new(big.Int).SetUint64(uint64(b.config.HighGasThreshold * params.GWei)))
+	if err != nil {
+		return nil, err
+	}
+	if b.config.HighGasThreshold != 0 && tx.GasFeeCap().Cmp(highGasThreshold) >= 0 && timeSinceNextMessage < b.config.HighGasDelay && !b.nearMaxDelay(timeSinceNextMessage) {
 		// The gas fee cap abigen recommended is above the high gas threshold. Check if this is necessary:
 		lastHeader, err := b.l1Reader.LastHeader(ctx)
 		if err != nil {
@@ -465,17 +693,60 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum u
 			}
 		}
 	}
-	err = b.l1Reader.Client().SendTransaction(ctx, tx)
+	if b.config.WalletMinBalance > 0 {
+		balance, err := b.l1Reader.Client().BalanceAt(ctx, txOpts.From, nil)
+		if err != nil {
+			return nil, err
+		}
+		minBalance, _ := new(big.Float).Mul(big.NewFloat(b.config.WalletMinBalance), big.NewFloat(params.Ether)).Int(nil)
+		if balance.Cmp(minBalance) < 0 {
+			b.wallets.failover(fmt.Sprintf("balance %v below minimum %v", balance, minBalance))
+			return nil, fmt.Errorf("batch poster wallet %v balance too low", txOpts.From)
+		}
+	}
+	if b.config.DryRun {
+		return nil, b.logDryRunBatch(ctx, tx, batchSeqNum, startMsgCount, msgCount, len(sequencerMsg))
+	}
+	if replacingNonce {
+		tx, err = b.sendNonceReplacement(ctx, tx, &txOpts)
+	} else {
+		err = b.sendTransaction(ctx, tx)
+	}
 	if err != nil {
+		if isInsufficientFundsError(err) {
+			b.wallets.failover(err.Error())
+		}
 		return nil, err
 	}
+	b.nonceGaps.recordSent(txOpts.From, tx.Nonce())
+	sentAt := time.Now()
+	ctx, traceID := openmetrics.StartTrace(ctx)
 	postingMsgCount := b.building.msgCount
-	log.Info("BatchPoster: batch sent", "tx", tx.Hash(), "sequence nr.", batchSeqNum, "from", prevBatchMeta.MessageCount, "to", postingMsgCount, "prev delayed", prevBatchMeta.DelayedMessageCount, "current delayed", b.building.segments.delayedMsg, "total segments", len(b.building.segments.rawSegments))
+	log.Info("BatchPoster: batch sent", "traceId", traceID, "tx", tx.Hash(), "sequence nr.", batchSeqNum, "from", prevBatchMeta.MessageCount, "to", postingMsgCount, "prev delayed", prevBatchMeta.DelayedMessageCount, "current delayed", b.building.segments.delayedMsg, "total segments", len(b.building.segments.rawSegments))
 	b.building = nil
-	_, err = b.l1Reader.WaitForTxApproval(ctx, tx)
+	if b.config.FeeBump.Enable || b.config.MaxDelay != 0 {
+		aggressiveAt, aggressiveErr := b.aggressiveFeeBumpTime(ctx, b.pendingMsgTimestamp)
+		if aggressiveErr != nil {
+			log.Warn("failed to determine when to bump batch fees aggressively", "err", aggressiveErr)
+		}
+		aggressiveAt = b.maxDelayAggressiveTime(b.pendingMsgTimestamp, aggressiveAt)
+		tx, err = b.waitWithFeeBumps(ctx, tx, &txOpts, len(sequencerMsg), aggressiveAt)
+	} else {
+		_, err = b.l1Reader.WaitForTxApproval(ctx, tx)
+	}
 	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			// The transaction is stuck. Posting the batch again from another wallet is safe,
+			// as the sequencer inbox rejects whichever copy of the batch lands second.
+			b.wallets.failover(fmt.Sprintf("transaction %v not mined in time", tx.Hash()))
+		}
 		return tx, err
 	}
+	b.wallets.recordSuccess(txOpts.From, tx.Hash())
+	if receipt, cost := b.recordPostingCost(ctx, tx); receipt != nil {
+		b.recordBatchLedger(batchSeqNum, receipt, cost, prevBatchMeta.MessageCount, postingMsgCount)
+	}
+	recordPostingLatency(ctx, batchSeqNum, tx, time.Since(sentAt))
 	if postingMsgCount < msgCount {
 		msg, err := b.streamer.GetMessage(postingMsgCount)
 		if err != nil {
@@ -532,12 +803,34 @@ func (b *BatchPoster) Start(ctxIn context.Context) {
 			}
 			b.lastBatchCount = batchSeqNum
 		}
-		_, err = b.maybePostSequencerBatch(ctx, batchSeqNum)
+		if b.config.CircuitBreaker.Enable && !b.config.DryRun {
+			err := b.checkAuthorization(ctx)
+			if err != nil {
+				log.Warn("error checking batch poster authorization", "err", err)
+			}
+			if reason := b.breaker.tripped(); reason != "" {
+				log.Warn("BatchPoster: circuit breaker tripped, not posting batch", "reason", reason)
+				b.escapeHatchPostFailed(ctx, batchSeqNum)
+				return b.config.PostingErrorDelay
+			}
+		}
+		tx, err := b.maybePostSequencerBatch(ctx, batchSeqNum)
 		if err != nil {
 			b.building = nil
 			log.Error("error posting batch", "err", err)
+			if b.config.CircuitBreaker.Enable {
+				b.breaker.postFailed(err, b.config.CircuitBreaker.MaxReverts)
+			}
+			b.escapeHatchPostFailed(ctx, batchSeqNum)
 			return b.config.PostingErrorDelay
 		}
+		if tx != nil {
+			b.breaker.postSucceeded()
+		}
+		b.escapeHatchPostSucceeded()
 		return b.config.BatchPollDelay
 	})
+	if b.config.CircuitBreaker.Enable && b.config.CircuitBreaker.HealthcheckAddr != "" {
+		b.LaunchThread(b.launchHealthcheckServer)
+	}
 }
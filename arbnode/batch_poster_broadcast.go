@@ -0,0 +1,187 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// RedundantPostingConfig lists extra L1 endpoints batch transactions are sent to alongside the L1 client,
+// so that a single censoring or lagging provider can't hold up a batch.
+type RedundantPostingConfig struct {
+	URLs             []string      `koanf:"urls"`
+	PrivateRelayURLs []string      `koanf:"private-relay-urls"`
+	SendTimeout      time.Duration `koanf:"send-timeout"`
+	TrackTimeout     time.Duration `koanf:"track-timeout"`
+}
+
+func RedundantPostingConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".urls", DefaultRedundantPostingConfig.URLs, "additional L1 RPC endpoints to send batch transactions to")
+	f.StringSlice(prefix+".private-relay-urls", DefaultRedundantPostingConfig.PrivateRelayURLs, "private transaction relays (such as Flashbots Protect) to send batch transactions to, which are never queried for receipts")
+	f.Duration(prefix+".send-timeout", DefaultRedundantPostingConfig.SendTimeout, "timeout for sending a transaction to a single endpoint")
+	f.Duration(prefix+".track-timeout", DefaultRedundantPostingConfig.TrackTimeout, "how long to look for the first endpoint to report a batch transaction mined")
+}
+
+var DefaultRedundantPostingConfig = RedundantPostingConfig{
+	URLs:             []string{},
+	PrivateRelayURLs: []string{},
+	SendTimeout:      10 * time.Second,
+	TrackTimeout:     10 * time.Minute,
+}
+
+type postingEndpoint struct {
+	name      string
+	client    arbutil.L1Interface
+	sendOnly  bool
+	sent      metrics.Counter
+	failed    metrics.Counter
+	confirmed metrics.Counter
+}
+
+func newPostingEndpoint(name string, client arbutil.L1Interface, sendOnly bool) *postingEndpoint {
+	prefix := "arb/batchposter/endpoint/" + name
+	return &postingEndpoint{
+		name:      name,
+		client:    client,
+		sendOnly:  sendOnly,
+		sent:      metrics.NewRegisteredCounter(prefix+"/sent", nil),
+		failed:    metrics.NewRegisteredCounter(prefix+"/failed", nil),
+		confirmed: metrics.NewRegisteredCounter(prefix+"/firstconfirmed", nil),
+	}
+}
+
+// batchBroadcaster sends batch transactions to every endpoint at once. The first endpoint is the L1 client.
+type batchBroadcaster struct {
+	config    *RedundantPostingConfig
+	endpoints []*postingEndpoint
+}
+
+// newBatchBroadcaster returns nil if no redundant endpoints are configured.
+func newBatchBroadcaster(config *RedundantPostingConfig, primary arbutil.L1Interface) (*batchBroadcaster, error) {
+	if len(config.URLs) == 0 && len(config.PrivateRelayURLs) == 0 {
+		return nil, nil
+	}
+	endpoints := []*postingEndpoint{newPostingEndpoint("primary", primary, false)}
+	dial := func(name string, url string, sendOnly bool) error {
+		client, err := ethclient.Dial(url)
+		if err != nil {
+			return fmt.Errorf("failed to connect to batch posting endpoint %v: %w", url, err)
+		}
+		endpoints = append(endpoints, newPostingEndpoint(name, client, sendOnly))
+		return nil
+	}
+	for i, url := range config.URLs {
+		if err := dial(fmt.Sprintf("redundant%d", i), url, false); err != nil {
+			return nil, err
+		}
+	}
+	for i, url := range config.PrivateRelayURLs {
+		if err := dial(fmt.Sprintf("relay%d", i), url, true); err != nil {
+			return nil, err
+		}
+	}
+	return &batchBroadcaster{
+		config:    config,
+		endpoints: endpoints,
+	}, nil
+}
+
+// Errors meaning the endpoint already has the transaction, most likely from another endpoint's peers
+func isAlreadyKnownError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+// sendTransaction sends tx to all endpoints concurrently, succeeding if any of them accepted it.
+// If all of them failed, the L1 client's error is returned.
+func (bb *batchBroadcaster) sendTransaction(ctx context.Context, tx *types.Transaction) error {
+	errs := make([]error, len(bb.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range bb.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *postingEndpoint) {
+			defer wg.Done()
+			sendCtx, cancel := context.WithTimeout(ctx, bb.config.SendTimeout)
+			defer cancel()
+			err := endpoint.client.SendTransaction(sendCtx, tx)
+			if err != nil && isAlreadyKnownError(err) {
+				err = nil
+			}
+			if err != nil {
+				endpoint.failed.Inc(1)
+				log.Warn("failed to send batch transaction to endpoint", "endpoint", endpoint.name, "tx", tx.Hash(), "err", err)
+			} else {
+				endpoint.sent.Inc(1)
+			}
+			errs[i] = err
+		}(i, endpoint)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errs[0]
+}
+
+// trackFirstConfirmation polls the endpoints which serve receipts until one of them reports tx mined,
+// and records which it was. A provider that's never first is lagging or censoring batches.
+func (bb *batchBroadcaster) trackFirstConfirmation(ctx context.Context, tx *types.Transaction) {
+	ctx, cancel := context.WithTimeout(ctx, bb.config.TrackTimeout)
+	defer cancel()
+	sentAt := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		for _, endpoint := range bb.endpoints {
+			if endpoint.sendOnly {
+				continue
+			}
+			receipt, err := endpoint.client.TransactionReceipt(ctx, tx.Hash())
+			if err != nil || receipt == nil {
+				continue
+			}
+			endpoint.confirmed.Inc(1)
+			log.Info("BatchPoster: batch transaction first confirmed", "endpoint", endpoint.name, "tx", tx.Hash(), "block", receipt.BlockNumber, "after", time.Since(sentAt))
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.Canceled) {
+				log.Warn("no endpoint confirmed batch transaction", "tx", tx.Hash(), "after", time.Since(sentAt))
+			}
+			return
+		}
+	}
+}
+
+// sendTransaction sends a batch transaction through the L1 client, and through any redundant endpoints.
+func (b *BatchPoster) sendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if b.broadcaster == nil {
+		return b.l1Reader.Client().SendTransaction(ctx, tx)
+	}
+	err := b.broadcaster.sendTransaction(ctx, tx)
+	if err != nil {
+		return err
+	}
+	b.LaunchThread(func(ctx context.Context) {
+		b.broadcaster.trackFirstConfirmation(ctx, tx)
+	})
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// testPostingClient is an L1 endpoint which only sends transactions and serves receipts.
+type testPostingClient struct {
+	arbutil.L1Interface
+	sendErr error
+	sends   int32 // atomic
+	mined   bool
+}
+
+func (c *testPostingClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	atomic.AddInt32(&c.sends, 1)
+	return c.sendErr
+}
+
+func (c *testPostingClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if !c.mined {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash}, nil
+}
+
+func newTestBatchBroadcaster(clients ...*testPostingClient) *batchBroadcaster {
+	config := DefaultRedundantPostingConfig
+	config.SendTimeout = time.Second
+	config.TrackTimeout = time.Second
+	bb := &batchBroadcaster{config: &config}
+	for i, client := range clients {
+		bb.endpoints = append(bb.endpoints, newPostingEndpoint(fmt.Sprint("test", i), client, false))
+	}
+	return bb
+}
+
+func TestBatchBroadcasterEndpointFailure(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{Nonce: 1})
+	primaryErr := errors.New("dial tcp: connection refused")
+	primary := &testPostingClient{sendErr: primaryErr}
+	redundant := &testPostingClient{}
+	bb := newTestBatchBroadcaster(primary, redundant)
+
+	if err := bb.sendTransaction(context.Background(), tx); err != nil {
+		Fail(t, "send failed although an endpoint accepted the transaction:", err)
+	}
+	if atomic.LoadInt32(&primary.sends) != 1 || atomic.LoadInt32(&redundant.sends) != 1 {
+		Fail(t, "transaction not sent to every endpoint", primary.sends, redundant.sends)
+	}
+
+	redundant.sendErr = errors.New("rate limited")
+	if err := bb.sendTransaction(context.Background(), tx); !errors.Is(err, primaryErr) {
+		Fail(t, "expected the L1 client's error when every endpoint failed, got", err)
+	}
+}
+
+func TestBatchBroadcasterAlreadyKnown(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{Nonce: 1})
+	// Each endpoint heard of the transaction from the others' peers before it was sent to it directly
+	primary := &testPostingClient{sendErr: errors.New("already known")}
+	redundant := &testPostingClient{sendErr: errors.New("Known transaction: 0x1234")}
+	bb := newTestBatchBroadcaster(primary, redundant)
+
+	if err := bb.sendTransaction(context.Background(), tx); err != nil {
+		Fail(t, "duplicate sends treated as a failure:", err)
+	}
+	if isAlreadyKnownError(errors.New("nonce too low")) {
+		Fail(t, "nonce too low treated as an already known transaction")
+	}
+}
+
+func TestBatchBroadcasterTrackFirstConfirmation(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{Nonce: 1})
+	relay := &testPostingClient{mined: true}
+	lagging := &testPostingClient{}
+	bb := newTestBatchBroadcaster(lagging, relay)
+	// A private relay never serves receipts, so it's never asked for one
+	bb.endpoints[1].sendOnly = true
+
+	start := time.Now()
+	bb.trackFirstConfirmation(context.Background(), tx)
+	if time.Since(start) < bb.config.TrackTimeout {
+		Fail(t, "confirmation taken from a send only endpoint")
+	}
+
+	lagging.mined = true
+	start = time.Now()
+	bb.trackFirstConfirmation(context.Background(), tx)
+	if time.Since(start) >= bb.config.TrackTimeout {
+		Fail(t, "confirmation from an endpoint serving receipts not tracked")
+	}
+}
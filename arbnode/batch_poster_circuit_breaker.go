@@ -0,0 +1,218 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	circuitBreakerTrippedGauge = metrics.NewRegisteredGauge("arb/batchposter/circuitbreaker/tripped", nil)
+	circuitBreakerTripCounter  = metrics.NewRegisteredCounter("arb/batchposter/circuitbreaker/trips", nil)
+	circuitBreakerRevertsGauge = metrics.NewRegisteredGauge("arb/batchposter/circuitbreaker/reverts", nil)
+)
+
+// CircuitBreakerConfig stops batch posting when the sequencer inbox keeps rejecting batches, rather than
+// spending gas on posts that can't succeed. The breaker trips after max-reverts consecutive reverted posts,
+// and stays tripped for the cool-down or until reset through the API, or when the poster is no longer an
+// authorized batch poster on L1, until it's authorized again.
+type CircuitBreakerConfig struct {
+	Enable                     bool          `koanf:"enable"`
+	MaxReverts                 int           `koanf:"max-reverts"`
+	CoolDown                   time.Duration `koanf:"cool-down"`
+	AuthorizationCheckInterval time.Duration `koanf:"authorization-check-interval"`
+	HealthcheckAddr            string        `koanf:"healthcheck-addr"`
+}
+
+func CircuitBreakerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultCircuitBreakerConfig.Enable, "stop posting batches when the sequencer inbox keeps reverting them or the poster is no longer authorized")
+	f.Int(prefix+".max-reverts", DefaultCircuitBreakerConfig.MaxReverts, "how many consecutive reverted batch posts trip the circuit breaker")
+	f.Duration(prefix+".cool-down", DefaultCircuitBreakerConfig.CoolDown, "how long the circuit breaker stays tripped by reverted posts before trying a post again, tripping again straight away if it reverts too (0 = until reset through the API)")
+	f.Duration(prefix+".authorization-check-interval", DefaultCircuitBreakerConfig.AuthorizationCheckInterval, "how often to check the poster is still an authorized batch poster on L1 (0 = disabled)")
+	f.String(prefix+".healthcheck-addr", DefaultCircuitBreakerConfig.HealthcheckAddr, "if non-empty, launch an HTTP service binding to this address that returns status code 200 when batch posting is healthy and 503 with the reason when the circuit breaker is tripped")
+}
+
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{
+	Enable:                     false,
+	MaxReverts:                 3,
+	CoolDown:                   10 * time.Minute,
+	AuthorizationCheckInterval: time.Minute,
+	HealthcheckAddr:            "",
+}
+
+// revertErrorCode is the JSON-RPC error code of calls and gas estimates the EVM reverted.
+const revertErrorCode = 3
+
+// isRevertError returns whether posting a batch failed because the sequencer inbox reverted it,
+// either when estimating its gas or once it was mined.
+func isRevertError(err error) bool {
+	if errors.Is(err, vm.ErrExecutionReverted) {
+		return true
+	}
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == revertErrorCode
+}
+
+type circuitBreaker struct {
+	coolDown       time.Duration
+	mutex          sync.Mutex
+	reverts        int
+	lastRevert     string
+	reason         string // empty unless tripped
+	unauthorized   bool   // whether the breaker tripped as the poster isn't authorized
+	trippedAt      time.Time
+	lastAuthorized time.Time
+}
+
+func (c *circuitBreaker) trip(reason string, unauthorized bool) {
+	if c.reason == "" {
+		log.Error("BatchPoster: circuit breaker tripped, no longer posting batches", "reason", reason)
+		c.trippedAt = time.Now()
+		circuitBreakerTripCounter.Inc(1)
+		circuitBreakerTrippedGauge.Update(1)
+	}
+	c.reason = reason
+	c.unauthorized = unauthorized
+}
+
+func (c *circuitBreaker) clear() {
+	if c.reason != "" {
+		log.Info("BatchPoster: circuit breaker reset, resuming batch posting", "reason", c.reason)
+		circuitBreakerTrippedGauge.Update(0)
+	}
+	c.reason = ""
+	c.unauthorized = false
+	c.reverts = 0
+	circuitBreakerRevertsGauge.Update(0)
+}
+
+// postFailed counts a reverted post, and trips the breaker once too many have failed in a row.
+func (c *circuitBreaker) postFailed(err error, maxReverts int) {
+	if !isRevertError(err) {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reverts++
+	c.lastRevert = err.Error()
+	circuitBreakerRevertsGauge.Update(int64(c.reverts))
+	if c.reverts >= maxReverts {
+		c.trip(fmt.Sprintf("%v consecutive batch posts reverted, the last with: %v", c.reverts, c.lastRevert), false)
+	}
+}
+
+func (c *circuitBreaker) postSucceeded() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reverts = 0
+	circuitBreakerRevertsGauge.Update(0)
+}
+
+// authorization records whether the poster is an authorized batch poster. Losing authorization trips the
+// breaker, and regaining it resets the breaker only if that's why it tripped.
+func (c *circuitBreaker) authorization(poster common.Address, authorized bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastAuthorized = time.Now()
+	if !authorized {
+		c.trip(fmt.Sprintf("%v is no longer an authorized batch poster on L1", poster), true)
+	} else if c.unauthorized {
+		c.clear()
+	}
+}
+
+func (c *circuitBreaker) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clear()
+}
+
+// tripped returns the reason the breaker tripped, or an empty string if it hasn't. A breaker tripped by reverts
+// lets a post through once the cool-down is over, keeping its count of reverts so another revert trips it again.
+func (c *circuitBreaker) tripped() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.reason != "" && !c.unauthorized && c.coolDown != 0 && time.Since(c.trippedAt) >= c.coolDown {
+		log.Info("BatchPoster: circuit breaker cooled down, trying to post again", "reason", c.reason)
+		circuitBreakerTrippedGauge.Update(0)
+		c.reason = ""
+	}
+	return c.reason
+}
+
+func (c *circuitBreaker) authorizationCheckDue(interval time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return interval != 0 && time.Since(c.lastAuthorized) >= interval
+}
+
+// checkAuthorization verifies the address batches are posted from is still an authorized batch poster.
+func (b *BatchPoster) checkAuthorization(ctx context.Context) error {
+	if !b.breaker.authorizationCheckDue(b.config.CircuitBreaker.AuthorizationCheckInterval) {
+		return nil
+	}
+	poster := b.wallets.current().From
+	if b.safe != nil {
+		poster = b.safe.address
+	}
+	authorized, err := b.inboxContract.IsBatchPoster(&bind.CallOpts{Context: ctx}, poster)
+	if err != nil {
+		return err
+	}
+	b.breaker.authorization(poster, authorized)
+	return nil
+}
+
+// ResetCircuitBreaker resumes batch posting after the circuit breaker tripped, once the cause has been fixed.
+// If the poster still isn't authorized, the breaker trips again on its next authorization check.
+func (b *BatchPoster) ResetCircuitBreaker() {
+	b.breaker.reset()
+}
+
+type batchPosterHealthcheck struct {
+	b *BatchPoster
+}
+
+func (h batchPosterHealthcheck) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if reason := h.b.breaker.tripped(); reason != "" {
+		response.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = response.Write([]byte(reason + "\n"))
+		return
+	}
+	response.WriteHeader(http.StatusOK)
+}
+
+func (b *BatchPoster) launchHealthcheckServer(ctx context.Context) {
+	server := &http.Server{
+		Addr:              b.config.CircuitBreaker.HealthcheckAddr,
+		Handler:           batchPosterHealthcheck{b},
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		err := server.Shutdown(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			log.Warn("error shutting down batch poster healthcheck server", "err", err)
+		}
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Warn("error serving batch poster healthcheck server", "err", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// testRPCError is an error as returned by an L1 node over JSON-RPC.
+type testRPCError struct {
+	code    int
+	message string
+}
+
+func (e testRPCError) Error() string {
+	return e.message
+}
+
+func (e testRPCError) ErrorCode() int {
+	return e.code
+}
+
+func TestCircuitBreakerReverts(t *testing.T) {
+	var breaker circuitBreaker
+	reverted := fmt.Errorf("estimating gas: %w", testRPCError{code: revertErrorCode, message: "execution reverted: BAD_SEQUENCER_NUMBER"})
+
+	if !isRevertError(fmt.Errorf("posting batch: %w", vm.ErrExecutionReverted)) {
+		Fail(t, "EVM revert not recognized")
+	}
+	// Only the error code identifies a revert, not the message
+	breaker.postFailed(testRPCError{code: -32000, message: "execution reverted by the node's own checks"}, 2)
+	breaker.postFailed(errors.New("insufficient funds for gas"), 2)
+	breaker.postFailed(reverted, 2)
+	if breaker.tripped() != "" {
+		Fail(t, "tripped before max reverts")
+	}
+	breaker.postSucceeded()
+	breaker.postFailed(reverted, 2)
+	if breaker.tripped() != "" {
+		Fail(t, "reverts not reset by a successful post")
+	}
+	breaker.postFailed(reverted, 2)
+	if breaker.tripped() == "" {
+		Fail(t, "not tripped after max consecutive reverts")
+	}
+
+	// Regaining authorization doesn't reset a breaker tripped by reverts
+	breaker.authorization(common.HexToAddress("0x1234"), true)
+	if breaker.tripped() == "" {
+		Fail(t, "reset by authorization check")
+	}
+	breaker.reset()
+	if breaker.tripped() != "" {
+		Fail(t, "not reset")
+	}
+}
+
+func TestCircuitBreakerCoolDown(t *testing.T) {
+	breaker := circuitBreaker{coolDown: time.Hour}
+	reverted := testRPCError{code: revertErrorCode, message: "execution reverted"}
+	breaker.postFailed(reverted, 2)
+	breaker.postFailed(reverted, 2)
+	if breaker.tripped() == "" {
+		Fail(t, "not tripped after max consecutive reverts")
+	}
+
+	breaker.trippedAt = time.Now().Add(-2 * time.Hour)
+	if breaker.tripped() != "" {
+		Fail(t, "still tripped after the cool-down")
+	}
+	// The post tried after the cool-down reverting trips the breaker straight away
+	breaker.postFailed(reverted, 2)
+	if breaker.tripped() == "" {
+		Fail(t, "not tripped again by a revert after the cool-down")
+	}
+
+	// Losing authorization doesn't cool down
+	breaker.reset()
+	breaker.authorization(common.HexToAddress("0x1234"), false)
+	breaker.trippedAt = time.Now().Add(-2 * time.Hour)
+	if breaker.tripped() == "" {
+		Fail(t, "unauthorized poster cooled down")
+	}
+}
+
+func TestCircuitBreakerAuthorization(t *testing.T) {
+	b := &BatchPoster{}
+	breaker := &b.breaker
+	poster := common.HexToAddress("0x1234")
+
+	breaker.authorization(poster, false)
+	if breaker.tripped() == "" {
+		Fail(t, "not tripped when unauthorized")
+	}
+	recorder := httptest.NewRecorder()
+	batchPosterHealthcheck{b}.ServeHTTP(recorder, nil)
+	if recorder.Code != http.StatusServiceUnavailable {
+		Fail(t, "healthcheck returned", recorder.Code, "while tripped")
+	}
+
+	breaker.authorization(poster, true)
+	if breaker.tripped() != "" {
+		Fail(t, "not reset when authorized again")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+var (
+	dryRunBatchesCounter = metrics.NewRegisteredCounter("arb/batchposter/dryrun/batches", nil)
+	dryRunCostGauge      = metrics.NewRegisteredGaugeFloat64("arb/batchposter/dryrun/cost", nil) // in ether, of the last batch
+)
+
+// dryRunPosition is where the last batch built in dry run mode ended. As those batches are never
+// posted, the next one is built from here instead of from the inbox's last batch.
+type dryRunPosition struct {
+	batchSeqNum uint64 // the inbox's batch count when the batch was built
+	batches     uint64 // batches built since the inbox's last batch
+	msgCount    arbutil.MessageIndex
+	delayedMsg  uint64
+}
+
+// dryRunStart returns where to build the next batch from, if batches have been built in
+// dry run mode past the inbox's last batch.
+func (b *BatchPoster) dryRunStart(prevBatchMeta BatchMetadata) (arbutil.MessageIndex, uint64, bool) {
+	position := b.dryRun
+	if !b.config.DryRun || position == nil || position.msgCount <= prevBatchMeta.MessageCount {
+		return 0, 0, false
+	}
+	return position.msgCount, position.delayedMsg, true
+}
+
+// logDryRunBatch reports the batch transaction that would have been sent and what it would cost at
+// the current L1 base fee, then moves on to the next batch as if it had been posted.
+func (b *BatchPoster) logDryRunBatch(ctx context.Context, tx *types.Transaction, batchSeqNum uint64, prevMsgCount arbutil.MessageIndex, msgCount arbutil.MessageIndex, dataLength int) error {
+	var batches uint64 = 1
+	if b.dryRun != nil && b.dryRun.batchSeqNum == batchSeqNum && b.dryRun.msgCount == prevMsgCount {
+		batches = b.dryRun.batches + 1
+	}
+	fields := []interface{}{
+		"tx", tx.Hash(),
+		"sequence nr.", batchSeqNum + batches - 1,
+		"from", prevMsgCount,
+		"to", b.building.msgCount,
+		"delayed", b.building.segments.delayedMsg,
+		"size", dataLength,
+		"gas", tx.Gas(),
+		"gasFeeCap", tx.GasFeeCap(),
+		"gasTipCap", tx.GasTipCap(),
+	}
+	lastHeader, err := b.l1Reader.LastHeader(ctx)
+	if err == nil && lastHeader.BaseFee != nil {
+		gasPrice := arbmath.BigMin(tx.GasFeeCap(), arbmath.BigAdd(lastHeader.BaseFee, tx.GasTipCap()))
+		cost := arbmath.BigMulByUint(gasPrice, tx.Gas())
+		costInEther, _ := new(big.Float).Quo(new(big.Float).SetInt(cost), big.NewFloat(params.Ether)).Float64()
+		fields = append(fields, "l1BaseFee", lastHeader.BaseFee, "maxCost", cost)
+		dryRunCostGauge.Update(costInEther)
+	}
+	log.Info("BatchPoster: dry run, not sending batch", fields...)
+	dryRunBatchesCounter.Inc(1)
+
+	b.dryRun = &dryRunPosition{
+		batchSeqNum: batchSeqNum,
+		batches:     batches,
+		msgCount:    b.building.msgCount,
+		delayedMsg:  b.building.segments.delayedMsg,
+	}
+	b.building = nil
+	if b.dryRun.msgCount < msgCount {
+		msg, err := b.streamer.GetMessage(b.dryRun.msgCount)
+		if err != nil {
+			return err
+		}
+		b.pendingMsgTimestamp = time.Unix(int64(msg.Message.Header.Timestamp), 0)
+	} else {
+		b.pendingMsgTimestamp = time.Now()
+	}
+	return nil
+}
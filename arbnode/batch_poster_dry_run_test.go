@@ -0,0 +1,32 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+)
+
+func TestDryRunStart(t *testing.T) {
+	b := &BatchPoster{config: &BatchPosterConfig{DryRun: true}}
+	prev := BatchMetadata{MessageCount: 10, DelayedMessageCount: 2}
+	if _, _, ok := b.dryRunStart(prev); ok {
+		Fail(t, "dry run start before any batch was built")
+	}
+
+	b.dryRun = &dryRunPosition{batchSeqNum: 4, batches: 2, msgCount: 25, delayedMsg: 5}
+	msgCount, delayed, ok := b.dryRunStart(prev)
+	if !ok || msgCount != 25 || delayed != 5 {
+		Fail(t, "didn't continue from the last dry run batch", msgCount, delayed, ok)
+	}
+
+	// once the inbox catches up, batches are built from it again
+	if _, _, ok := b.dryRunStart(BatchMetadata{MessageCount: 25, DelayedMessageCount: 5}); ok {
+		Fail(t, "dry run start behind the inbox's last batch")
+	}
+
+	b.config = &BatchPosterConfig{DryRun: false}
+	if _, _, ok := b.dryRunStart(prev); ok {
+		Fail(t, "dry run start outside of dry run mode")
+	}
+}
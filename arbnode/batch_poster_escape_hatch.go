@@ -0,0 +1,142 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/l1pricing"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+)
+
+var (
+	escapeHatchActiveGauge       = metrics.NewRegisteredGauge("arb/batchposter/escapehatch/active", nil)
+	escapeHatchForwardedCounter  = metrics.NewRegisteredCounter("arb/batchposter/escapehatch/forwarded", nil)
+	escapeHatchActivationCounter = metrics.NewRegisteredCounter("arb/batchposter/escapehatch/activations", nil)
+)
+
+// EscapeHatchConfig controls falling back to the delayed inbox when batches can't be posted.
+// Once no batch has been posted successfully for the configured duration, the L2 messages the
+// sequencer has produced since the last posted batch are forwarded to the delayed inbox, so their
+// transactions reach L1 and can be force included even if the sequencer inbox keeps failing.
+// Forwarding stops as soon as a batch is posted again.
+type EscapeHatchConfig struct {
+	Enable      bool          `koanf:"enable"`
+	After       time.Duration `koanf:"after"`
+	MaxMessages uint64        `koanf:"max-messages"`
+}
+
+func EscapeHatchConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultEscapeHatchConfig.Enable, "forward sequenced messages to the delayed inbox when batches can't be posted")
+	f.Duration(prefix+".after", DefaultEscapeHatchConfig.After, "how long batch posting must have failed before forwarding messages")
+	f.Uint64(prefix+".max-messages", DefaultEscapeHatchConfig.MaxMessages, "maximum number of messages to forward per batch poster iteration")
+}
+
+var DefaultEscapeHatchConfig = EscapeHatchConfig{
+	Enable:      false,
+	After:       30 * time.Minute,
+	MaxMessages: 10,
+}
+
+type escapeHatch struct {
+	config       *EscapeHatchConfig
+	delayedInbox *bridgegen.Inbox
+	lastSuccess  time.Time
+	active       bool
+	forwarded    arbutil.MessageIndex // messages before this have been forwarded
+}
+
+func newEscapeHatch(config *EscapeHatchConfig, delayedInboxAddress common.Address, client arbutil.L1Interface) (*escapeHatch, error) {
+	delayedInbox, err := bridgegen.NewInbox(delayedInboxAddress, client)
+	if err != nil {
+		return nil, err
+	}
+	return &escapeHatch{
+		config:       config,
+		delayedInbox: delayedInbox,
+		lastSuccess:  time.Now(),
+	}, nil
+}
+
+func (b *BatchPoster) escapeHatchPostSucceeded() {
+	hatch := b.escapeHatch
+	if hatch == nil {
+		return
+	}
+	hatch.lastSuccess = time.Now()
+	if hatch.active {
+		log.Info("batch posting resumed, no longer forwarding messages to the delayed inbox", "forwardedUpTo", hatch.forwarded)
+		hatch.active = false
+		escapeHatchActiveGauge.Update(0)
+	}
+}
+
+// escapeHatchPostFailed forwards unposted messages to the delayed inbox if posting has failed for too long.
+func (b *BatchPoster) escapeHatchPostFailed(ctx context.Context, batchCount uint64) {
+	hatch := b.escapeHatch
+	if hatch == nil || time.Since(hatch.lastSuccess) < hatch.config.After {
+		return
+	}
+	if !hatch.active {
+		log.Error("batch posting has been failing, forwarding messages to the delayed inbox", "lastSuccess", hatch.lastSuccess)
+		hatch.active = true
+		escapeHatchActiveGauge.Update(1)
+		escapeHatchActivationCounter.Inc(1)
+	}
+	err := b.forwardToDelayedInbox(ctx, batchCount)
+	if err != nil {
+		log.Error("error forwarding messages to the delayed inbox", "err", err)
+	}
+}
+
+func (b *BatchPoster) forwardToDelayedInbox(ctx context.Context, batchCount uint64) error {
+	hatch := b.escapeHatch
+	var postedMsgCount arbutil.MessageIndex
+	if batchCount > 0 {
+		var err error
+		postedMsgCount, err = b.inbox.GetBatchMessageCount(batchCount - 1)
+		if err != nil {
+			return err
+		}
+	}
+	if hatch.forwarded < postedMsgCount {
+		hatch.forwarded = postedMsgCount
+	}
+	msgCount, err := b.streamer.GetMessageCount()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < hatch.config.MaxMessages && hatch.forwarded < msgCount; i++ {
+		msg, err := b.streamer.GetMessage(hatch.forwarded)
+		if err != nil {
+			return err
+		}
+		header := msg.Message.Header
+		// Only forward what the sequencer produced; delayed messages are on L1 already
+		if header.Kind == arbos.L1MessageType_L2Message && header.Poster == l1pricing.BatchPosterAddress {
+			txOpts := *b.wallets.current()
+			txOpts.Context = ctx
+			tx, err := hatch.delayedInbox.SendL2Message(&txOpts, msg.Message.L2msg)
+			if err != nil {
+				return err
+			}
+			_, err = b.l1Reader.WaitForTxApproval(ctx, tx)
+			if err != nil {
+				return err
+			}
+			log.Warn("forwarded message to the delayed inbox", "message", hatch.forwarded, "tx", tx.Hash())
+			escapeHatchForwardedCounter.Inc(1)
+		}
+		hatch.forwarded++
+	}
+	return nil
+}
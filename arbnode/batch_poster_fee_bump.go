@@ -0,0 +1,229 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// FeeBumpConfig controls how the batch poster replaces batch transactions that aren't mined in time.
+// Fees escalate by factor for every interval the batch has been pending, capped at max-fee-per-byte
+// of batch data, unless the batch is within aggressive-window of the sequencer inbox's delay window.
+type FeeBumpConfig struct {
+	Enable           bool          `koanf:"enable"`
+	Interval         time.Duration `koanf:"interval"`
+	Factor           float64       `koanf:"factor"`
+	MaxFeePerByte    float64       `koanf:"max-fee-per-byte"`
+	AggressiveWindow time.Duration `koanf:"aggressive-window"`
+	AggressiveFactor float64       `koanf:"aggressive-factor"`
+	MaxWait          time.Duration `koanf:"max-wait"`
+}
+
+func FeeBumpConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultFeeBumpConfig.Enable, "replace batch transactions with higher fees when they aren't mined in time")
+	f.Duration(prefix+".interval", DefaultFeeBumpConfig.Interval, "how long to wait for a batch transaction to be mined before replacing it")
+	f.Float64(prefix+".factor", DefaultFeeBumpConfig.Factor, "fee multiplier per interval a batch is pending (at least 1.1 to satisfy L1 replacement rules)")
+	f.Float64(prefix+".max-fee-per-byte", DefaultFeeBumpConfig.MaxFeePerByte, "maximum total fee in gwei per byte of batch data to bump up to (0 = uncapped)")
+	f.Duration(prefix+".aggressive-window", DefaultFeeBumpConfig.AggressiveWindow, "bump aggressively and uncapped when the batch's oldest message is this close to the sequencer inbox delay window (0 = disabled)")
+	f.Float64(prefix+".aggressive-factor", DefaultFeeBumpConfig.AggressiveFactor, "fee multiplier per interval in aggressive mode")
+	f.Duration(prefix+".max-wait", DefaultFeeBumpConfig.MaxWait, "how long to wait for a batch transaction and its replacements before giving up")
+}
+
+var DefaultFeeBumpConfig = FeeBumpConfig{
+	Enable:           false,
+	Interval:         5 * time.Minute,
+	Factor:           1.25,
+	MaxFeePerByte:    0,
+	AggressiveWindow: time.Hour,
+	AggressiveFactor: 2,
+	MaxWait:          2 * time.Hour,
+}
+
+// L1 nodes only accept replacements which raise both the fee cap and tip by 10%
+const minReplacementBumpPercent = 110
+
+type FeeBumpState struct {
+	Nonce        hexutil.Uint64 `json:"nonce"`
+	TxHash       common.Hash    `json:"txHash"`
+	FirstPosted  time.Time      `json:"firstPosted"`
+	LastPosted   time.Time      `json:"lastPosted"`
+	Replacements uint64         `json:"replacements"`
+	GasFeeCap    *hexutil.Big   `json:"gasFeeCap"`
+	GasTipCap    *hexutil.Big   `json:"gasTipCap"`
+	Aggressive   bool           `json:"aggressive"`
+	Capped       bool           `json:"capped"`
+}
+
+func bigMulByFloat(value *big.Int, multiplier float64) *big.Int {
+	result, _ := new(big.Float).Mul(new(big.Float).SetInt(value), big.NewFloat(multiplier)).Int(nil)
+	return result
+}
+
+func minReplacementFee(previous *big.Int) *big.Int {
+	// round up, so the replacement is never short of the required bump
+	return arbmath.BigAddByUint(arbmath.BigMulByFrac(previous, minReplacementBumpPercent, 100), 1)
+}
+
+// nextFees computes the fees of a replacement for a transaction pending for elapsed. It returns false
+// if the transaction can't be replaced, as the bumped fees would exceed the fee cap.
+func (c *FeeBumpConfig) nextFees(initialFeeCap, initialTipCap, prevFeeCap, prevTipCap *big.Int, elapsed time.Duration, gas uint64, dataLength int, aggressive bool) (*big.Int, *big.Int, bool, bool) {
+	factor := c.Factor
+	if aggressive {
+		factor = c.AggressiveFactor
+	}
+	multiplier := math.Pow(factor, float64(elapsed/c.Interval))
+	feeCap := arbmath.BigMax(bigMulByFloat(initialFeeCap, multiplier), minReplacementFee(prevFeeCap))
+	tipCap := arbmath.BigMax(bigMulByFloat(initialTipCap, multiplier), minReplacementFee(prevTipCap))
+
+	capped := false
+	if !aggressive && c.MaxFeePerByte > 0 && gas > 0 {
+		maxTotalFee := bigMulByFloat(big.NewInt(params.GWei), c.MaxFeePerByte*float64(dataLength))
+		maxFeeCap := arbmath.BigDivByUint(maxTotalFee, gas)
+		if feeCap.Cmp(maxFeeCap) > 0 {
+			feeCap = maxFeeCap
+			capped = true
+		}
+	}
+	tipCap = arbmath.BigMin(tipCap, feeCap)
+	if feeCap.Cmp(minReplacementFee(prevFeeCap)) < 0 || tipCap.Cmp(minReplacementFee(prevTipCap)) < 0 {
+		return prevFeeCap, prevTipCap, false, capped
+	}
+	return feeCap, tipCap, true, capped
+}
+
+// waitWithFeeBumps waits for a batch transaction to be mined, replacing it with higher fees per the
+// fee bump policy. Any of the replacements may be the one mined, so all of them are checked.
+func (b *BatchPoster) waitWithFeeBumps(ctx context.Context, tx *types.Transaction, txOpts *bind.TransactOpts, dataLength int, aggressiveAt time.Time) (*types.Transaction, error) {
+	config := &b.config.FeeBump
+	client := b.l1Reader.Client()
+	headerChan, unsubscribe := b.l1Reader.Subscribe(false)
+	defer unsubscribe()
+
+	sent := []*types.Transaction{tx}
+	now := time.Now()
+	state := FeeBumpState{
+		Nonce:       hexutil.Uint64(tx.Nonce()),
+		TxHash:      tx.Hash(),
+		FirstPosted: now,
+		LastPosted:  now,
+		GasFeeCap:   (*hexutil.Big)(tx.GasFeeCap()),
+		GasTipCap:   (*hexutil.Big)(tx.GasTipCap()),
+	}
+	b.setFeeBumpState(&state)
+	defer b.setFeeBumpState(nil)
+
+	for {
+		for i := len(sent) - 1; i >= 0; i-- {
+			receipt, err := client.TransactionReceipt(ctx, sent[i].Hash())
+			if err != nil || receipt == nil {
+				continue
+			}
+			return sent[i], arbutil.DetailTxError(ctx, client, sent[i], receipt)
+		}
+
+		elapsed := time.Since(state.FirstPosted)
+		if elapsed >= config.MaxWait {
+			return sent[len(sent)-1], fmt.Errorf("batch transaction with nonce %v not mined: %w", tx.Nonce(), context.DeadlineExceeded)
+		}
+		if time.Since(state.LastPosted) >= config.Interval {
+			state.Aggressive = !aggressiveAt.IsZero() && time.Now().After(aggressiveAt)
+			latest := sent[len(sent)-1]
+			feeCap, tipCap, replace, capped := config.nextFees(tx.GasFeeCap(), tx.GasTipCap(), latest.GasFeeCap(), latest.GasTipCap(), elapsed, tx.Gas(), dataLength, state.Aggressive)
+			state.Capped = capped
+			if replace {
+				replacement, err := txOpts.Signer(txOpts.From, types.NewTx(&types.DynamicFeeTx{
+					ChainID:    tx.ChainId(),
+					Nonce:      tx.Nonce(),
+					GasTipCap:  tipCap,
+					GasFeeCap:  feeCap,
+					Gas:        tx.Gas(),
+					To:         tx.To(),
+					Value:      tx.Value(),
+					Data:       tx.Data(),
+					AccessList: tx.AccessList(),
+				}))
+				if err != nil {
+					return latest, err
+				}
+				err = b.sendTransaction(ctx, replacement)
+				if err != nil {
+					log.Warn("failed to send batch replacement transaction", "nonce", tx.Nonce(), "gasFeeCap", feeCap, "err", err)
+				} else {
+					log.Info("BatchPoster: replaced batch transaction", "tx", replacement.Hash(), "nonce", tx.Nonce(), "gasFeeCap", feeCap, "gasTipCap", tipCap, "aggressive", state.Aggressive)
+					sent = append(sent, replacement)
+					state.TxHash = replacement.Hash()
+					state.Replacements++
+					state.GasFeeCap = (*hexutil.Big)(feeCap)
+					state.GasTipCap = (*hexutil.Big)(tipCap)
+				}
+			}
+			state.LastPosted = time.Now()
+			b.setFeeBumpState(&state)
+		}
+
+		select {
+		case _, ok := <-headerChan:
+			if !ok {
+				return sent[len(sent)-1], fmt.Errorf("waiting for batch transaction with nonce %v: header channel closed", tx.Nonce())
+			}
+		case <-ctx.Done():
+			return sent[len(sent)-1], ctx.Err()
+		}
+	}
+}
+
+func (b *BatchPoster) setFeeBumpState(state *FeeBumpState) {
+	b.feeBumpMutex.Lock()
+	defer b.feeBumpMutex.Unlock()
+	if state == nil {
+		b.feeBumpState = nil
+		return
+	}
+	stateCopy := *state
+	b.feeBumpState = &stateCopy
+}
+
+// FeeBumpState returns the escalation state of the batch transaction being waited on, or nil if there isn't one.
+func (b *BatchPoster) FeeBumpState() *FeeBumpState {
+	b.feeBumpMutex.Lock()
+	defer b.feeBumpMutex.Unlock()
+	if b.feeBumpState == nil {
+		return nil
+	}
+	state := *b.feeBumpState
+	return &state
+}
+
+// aggressiveFeeBumpTime returns when a batch whose oldest message has the given timestamp should
+// switch to aggressive fee bumping, or the zero time if aggressive mode is disabled.
+func (b *BatchPoster) aggressiveFeeBumpTime(ctx context.Context, oldestMessage time.Time) (time.Time, error) {
+	if b.config.FeeBump.AggressiveWindow == 0 {
+		return time.Time{}, nil
+	}
+	if b.delaySeconds == 0 {
+		maxTimeVariation, err := b.inboxContract.MaxTimeVariation(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return time.Time{}, err
+		}
+		b.delaySeconds = maxTimeVariation.DelaySeconds.Uint64()
+	}
+	deadline := oldestMessage.Add(time.Duration(b.delaySeconds) * time.Second)
+	return deadline.Add(-b.config.FeeBump.AggressiveWindow), nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestFeeBumpNextFees(t *testing.T) {
+	config := DefaultFeeBumpConfig
+	config.Interval = time.Minute
+	config.Factor = 1.5
+	config.AggressiveFactor = 3
+	gwei := func(n int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+	}
+	initialFeeCap, initialTip := gwei(100), gwei(2)
+
+	// after two intervals, fees have escalated by 1.5^2
+	feeCap, tipCap, replace, capped := config.nextFees(initialFeeCap, initialTip, initialFeeCap, initialTip, 2*time.Minute, 100, 10000, false)
+	if !replace || capped || feeCap.Cmp(gwei(225)) != 0 || tipCap.Cmp(big.NewInt(params.GWei*9/2)) != 0 {
+		Fail(t, "unexpected escalation", feeCap, tipCap, replace, capped)
+	}
+
+	// a bump below the minimum replacement increase is raised to it
+	feeCap, _, replace, _ = config.nextFees(initialFeeCap, initialTip, gwei(300), gwei(10), 2*time.Minute, 100, 10000, false)
+	if !replace || feeCap.Cmp(minReplacementFee(gwei(300))) != 0 {
+		Fail(t, "replacement not bumped by the minimum", feeCap)
+	}
+
+	// 10000 bytes at 2 gwei per byte over 100 gas caps the fee cap at 200 gwei
+	config.MaxFeePerByte = 2
+	feeCap, _, replace, capped = config.nextFees(initialFeeCap, initialTip, initialFeeCap, initialTip, 2*time.Minute, 100, 10000, false)
+	if !replace || !capped || feeCap.Cmp(gwei(200)) != 0 {
+		Fail(t, "fee cap not capped", feeCap, replace, capped)
+	}
+	_, _, replace, _ = config.nextFees(initialFeeCap, initialTip, gwei(190), initialTip, 3*time.Minute, 100, 10000, false)
+	if replace {
+		Fail(t, "replaced beyond the fee cap")
+	}
+
+	// aggressive mode ignores the cap
+	feeCap, _, replace, capped = config.nextFees(initialFeeCap, initialTip, gwei(190), initialTip, 2*time.Minute, 100, 10000, true)
+	if !replace || capped || feeCap.Cmp(gwei(900)) != 0 {
+		Fail(t, "unexpected aggressive escalation", feeCap, replace, capped)
+	}
+}
+
+func TestMaxDelayAggressiveTime(t *testing.T) {
+	config := DefaultBatchPosterConfig
+	b := &BatchPoster{config: &config}
+	oldest := time.Unix(1_000_000, 0)
+	windowAt := oldest.Add(time.Hour)
+
+	if b.maxDelayAggressiveTime(oldest, windowAt) != windowAt || b.nearMaxDelay(24*time.Hour) {
+		Fail(t, "max delay applied while disabled")
+	}
+
+	config.MaxDelay = 30 * time.Minute
+	config.MaxDelayReserve = 10 * time.Minute
+	if at := b.maxDelayAggressiveTime(oldest, windowAt); at != oldest.Add(20*time.Minute) {
+		Fail(t, "unexpected aggressive time", at)
+	}
+	if at := b.maxDelayAggressiveTime(oldest, time.Time{}); at != oldest.Add(20*time.Minute) {
+		Fail(t, "unexpected aggressive time with the aggressive window disabled", at)
+	}
+	if at := b.maxDelayAggressiveTime(oldest, oldest.Add(time.Minute)); at != oldest.Add(time.Minute) {
+		Fail(t, "max delay postponed aggressive bumping", at)
+	}
+	if b.nearMaxDelay(19*time.Minute) || !b.nearMaxDelay(20*time.Minute) {
+		Fail(t, "unexpected max delay posting threshold")
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// NonceRecoveryConfig controls how the batch poster recovers when its wallet has pending transactions it sent
+// but is no longer waiting on, such as ones it gave up on after an error. Batches queue behind such transactions,
+// so once they've been pending for gap-timeout, the batch poster replaces them with batches, starting from the
+// wallet's confirmed nonce. Pending transactions it didn't send, such as ones sent by external use of the wallet,
+// are never replaced, and must be cleared by the operator.
+type NonceRecoveryConfig struct {
+	Enable              bool          `koanf:"enable"`
+	GapTimeout          time.Duration `koanf:"gap-timeout"`
+	ReplacementAttempts int           `koanf:"replacement-attempts"`
+}
+
+func NonceRecoveryConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultNonceRecoveryConfig.Enable, "replace pending transactions the batch poster sent but isn't waiting on with batches, when they block posting")
+	f.Duration(prefix+".gap-timeout", DefaultNonceRecoveryConfig.GapTimeout, "how long transactions the batch poster isn't waiting on may be pending from its wallet before they are replaced")
+	f.Int(prefix+".replacement-attempts", DefaultNonceRecoveryConfig.ReplacementAttempts, "how many times to raise fees when replacing a pending transaction is rejected as underpriced")
+}
+
+var DefaultNonceRecoveryConfig = NonceRecoveryConfig{
+	Enable:              false,
+	GapTimeout:          10 * time.Minute,
+	ReplacementAttempts: 5,
+}
+
+var (
+	nonceGapsCounter         = metrics.NewRegisteredCounter("arb/batchposter/nonce/gaps", nil)
+	nonceReconciledCounter   = metrics.NewRegisteredCounter("arb/batchposter/nonce/reconciled", nil)
+	nonceGapTransactionGauge = metrics.NewRegisteredGauge("arb/batchposter/nonce/gap", nil)
+)
+
+// walletNonceGap is what's known of the pending transactions of one of the batch poster's wallets.
+type walletNonceGap struct {
+	since   time.Time // zero unless there are pending transactions
+	forced  bool
+	warned  bool
+	sentMin uint64              // nonces below this are confirmed, so no longer tracked
+	sent    map[uint64]struct{} // nonces the batch poster has sent transactions with
+}
+
+// nonceGapTracker remembers since when each wallet has had pending transactions the batch poster isn't waiting on,
+// and which of them it sent.
+type nonceGapTracker struct {
+	mutex   sync.Mutex
+	wallets map[common.Address]*walletNonceGap
+}
+
+func (g *nonceGapTracker) wallet(from common.Address) *walletNonceGap {
+	if g.wallets == nil {
+		g.wallets = make(map[common.Address]*walletNonceGap)
+	}
+	wallet, ok := g.wallets[from]
+	if !ok {
+		wallet = &walletNonceGap{sent: make(map[uint64]struct{})}
+		g.wallets[from] = wallet
+	}
+	return wallet
+}
+
+// recordSent records that the batch poster sent a transaction from the wallet with the nonce.
+func (g *nonceGapTracker) recordSent(from common.Address, nonce uint64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	wallet := g.wallet(from)
+	if nonce >= wallet.sentMin {
+		wallet.sent[nonce] = struct{}{}
+	}
+}
+
+// observe records a wallet's confirmed and pending nonces, and returns whether the gap between them should be
+// reconciled now, as it has lasted for the timeout, or reconciliation was forced. Gaps with transactions the batch
+// poster didn't send are never reconciled.
+func (g *nonceGapTracker) observe(from common.Address, confirmed uint64, pending uint64, now time.Time, timeout time.Duration) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	wallet := g.wallet(from)
+	for nonce := range wallet.sent {
+		if nonce < confirmed {
+			delete(wallet.sent, nonce)
+		}
+	}
+	if confirmed > wallet.sentMin {
+		wallet.sentMin = confirmed
+	}
+	if pending <= confirmed {
+		wallet.since = time.Time{}
+		wallet.forced = false
+		wallet.warned = false
+		return false
+	}
+	if wallet.since.IsZero() {
+		wallet.since = now
+		nonceGapsCounter.Inc(1)
+	}
+	if !wallet.forced && now.Sub(wallet.since) < timeout {
+		return false
+	}
+	wallet.forced = false
+	for nonce := confirmed; nonce < pending; nonce++ {
+		if _, ok := wallet.sent[nonce]; !ok {
+			if !wallet.warned {
+				log.Warn("BatchPoster: pending transactions the batch poster didn't send are blocking it, and must be cleared from the wallet", "wallet", from, "nonce", nonce, "confirmed", confirmed, "pending", pending)
+				wallet.warned = true
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// force has the wallet's gap reconciled without waiting for the timeout.
+func (g *nonceGapTracker) force(from common.Address) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.wallet(from).forced = true
+}
+
+func (g *nonceGapTracker) since(from common.Address) *time.Time {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	wallet, ok := g.wallets[from]
+	if !ok || wallet.since.IsZero() {
+		return nil
+	}
+	since := wallet.since
+	return &since
+}
+
+// reconcileNonce sets the nonce of the next batch transaction to the wallet's confirmed nonce if pending
+// transactions the batch poster sent have blocked posting for too long. It returns whether it did.
+// As the batch poster waits for each batch to be mined before posting the next, any pending transaction
+// when a batch is about to be posted is one it isn't waiting on.
+func (b *BatchPoster) reconcileNonce(ctx context.Context, txOpts *bind.TransactOpts) (bool, error) {
+	client := b.l1Reader.Client()
+	confirmed, err := client.NonceAt(ctx, txOpts.From, nil)
+	if err != nil {
+		return false, err
+	}
+	pending, err := client.PendingNonceAt(ctx, txOpts.From)
+	if err != nil {
+		return false, err
+	}
+	if pending > confirmed {
+		nonceGapTransactionGauge.Update(int64(pending - confirmed))
+	} else {
+		nonceGapTransactionGauge.Update(0)
+	}
+	if !b.nonceGaps.observe(txOpts.From, confirmed, pending, time.Now(), b.config.NonceRecovery.GapTimeout) {
+		return false, nil
+	}
+	log.Warn("BatchPoster: replacing pending transactions from the batch poster wallet with batches", "wallet", txOpts.From, "confirmed", confirmed, "pending", pending)
+	nonceReconciledCounter.Inc(1)
+	txOpts.Nonce = new(big.Int).SetUint64(confirmed)
+	return true, nil
+}
+
+// sendNonceReplacement sends a batch transaction which replaces a pending transaction with the same nonce, raising its fees until the L1 client stops rejecting it as underpriced.
+func (b *BatchPoster) sendNonceReplacement(ctx context.Context, tx *types.Transaction, txOpts *bind.TransactOpts) (*types.Transaction, error) {
+	for attempt := 0; ; attempt++ {
+		err := b.sendTransaction(ctx, tx)
+		if err == nil || attempt >= b.config.NonceRecovery.ReplacementAttempts || !strings.Contains(err.Error(), "underpriced") {
+			return tx, err
+		}
+		feeCap := minReplacementFee(tx.GasFeeCap())
+		tipCap := minReplacementFee(tx.GasTipCap())
+		log.Info("BatchPoster: raising fees to replace pending transaction", "nonce", tx.Nonce(), "gasFeeCap", feeCap, "gasTipCap", tipCap)
+		tx, err = txOpts.Signer(txOpts.From, types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+type BatchPosterNonceStatus struct {
+	Address      common.Address `json:"address"`
+	Nonce        hexutil.Uint64 `json:"nonce"`
+	PendingNonce hexutil.Uint64 `json:"pendingNonce"`
+	GapSince     *time.Time     `json:"gapSince,omitempty"`
+}
+
+// ReconcileNonce has the next batch replace the pending transactions the batch poster sent from the active
+// wallet, without waiting for the gap timeout, and reports the wallet's nonces.
+func (b *BatchPoster) ReconcileNonce(ctx context.Context) (*BatchPosterNonceStatus, error) {
+	from := b.wallets.current().From
+	client := b.l1Reader.Client()
+	confirmed, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	b.nonceGaps.force(from)
+	log.Info("BatchPoster: nonce reconciliation requested", "wallet", from, "confirmed", confirmed, "pending", pending)
+	return &BatchPosterNonceStatus{
+		Address:      from,
+		Nonce:        hexutil.Uint64(confirmed),
+		PendingNonce: hexutil.Uint64(pending),
+		GapSince:     b.nonceGaps.since(from),
+	}, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNonceGapTracker(t *testing.T) {
+	var gaps nonceGapTracker
+	wallet := common.HexToAddress("0x1234")
+	other := common.HexToAddress("0x5678")
+	start := time.Now()
+	timeout := 10 * time.Minute
+
+	if gaps.observe(wallet, 5, 5, start, timeout) {
+		Fail(t, "reconciling without a gap")
+	}
+	gaps.recordSent(wallet, 5)
+	gaps.recordSent(wallet, 6)
+	if gaps.observe(wallet, 5, 7, start, timeout) {
+		Fail(t, "reconciling a new gap")
+	}
+	if gaps.since(wallet) == nil || !gaps.since(wallet).Equal(start) {
+		Fail(t, "gap start not recorded")
+	}
+	if gaps.observe(wallet, 5, 7, start.Add(timeout/2), timeout) {
+		Fail(t, "reconciling before the timeout")
+	}
+	if !gaps.observe(wallet, 5, 7, start.Add(timeout), timeout) {
+		Fail(t, "not reconciling after the timeout")
+	}
+
+	// Closing the gap resets it
+	if gaps.observe(wallet, 7, 7, start.Add(timeout), timeout) || gaps.since(wallet) != nil {
+		Fail(t, "gap not reset")
+	}
+
+	// Transactions the batch poster didn't send are never replaced
+	if gaps.observe(wallet, 7, 8, start, timeout) || gaps.observe(wallet, 7, 8, start.Add(2*timeout), timeout) {
+		Fail(t, "reconciling a gap the batch poster didn't send")
+	}
+	gaps.force(wallet)
+	if gaps.observe(wallet, 7, 8, start.Add(2*timeout), timeout) {
+		Fail(t, "forced reconciliation of a gap the batch poster didn't send")
+	}
+	gaps.recordSent(wallet, 8)
+	if gaps.observe(wallet, 7, 9, start.Add(2*timeout), timeout) {
+		Fail(t, "reconciling a gap partly sent by someone else")
+	}
+
+	// Forcing reconciles a new gap immediately, once, and only for its wallet
+	gaps.recordSent(wallet, 9)
+	gaps.recordSent(other, 9)
+	if gaps.observe(wallet, 9, 9, start, timeout) || gaps.observe(other, 9, 9, start, timeout) {
+		Fail(t, "reconciling without a gap")
+	}
+	gaps.force(wallet)
+	if gaps.observe(other, 9, 10, start, timeout) {
+		Fail(t, "forced reconciliation applied to another wallet")
+	}
+	if !gaps.observe(wallet, 9, 10, start, timeout) {
+		Fail(t, "forced reconciliation ignored")
+	}
+	if gaps.observe(wallet, 9, 10, start, timeout) {
+		Fail(t, "forced reconciliation repeated")
+	}
+
+	// Forcing without a gap has no lasting effect
+	gaps.force(wallet)
+	gaps.recordSent(wallet, 10)
+	if gaps.observe(wallet, 10, 10, start, timeout) || gaps.observe(wallet, 10, 11, start, timeout) {
+		Fail(t, "forced reconciliation outlived the gap")
+	}
+
+	// Confirmed nonces are no longer tracked
+	gaps.recordSent(wallet, 3)
+	if _, ok := gaps.wallets[wallet].sent[3]; ok {
+		Fail(t, "recorded a confirmed nonce")
+	}
+	gaps.observe(wallet, 11, 11, start, timeout)
+	if len(gaps.wallets[wallet].sent) != 0 {
+		Fail(t, "confirmed nonces still tracked", gaps.wallets[wallet].sent)
+	}
+}
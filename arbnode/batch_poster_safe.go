@@ -0,0 +1,178 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// SafeConfig has the batch poster post through a Gnosis Safe, which must be an authorized batch poster,
+// wrapping each batch in an execTransaction call. The batch poster wallets must be owners of the Safe,
+// and its threshold must be 1, as each batch is approved by the owner sending it.
+type SafeConfig struct {
+	Address          string `koanf:"address"`
+	GasMarginPercent uint64 `koanf:"gas-margin-percent"`
+}
+
+func SafeConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".address", DefaultSafeConfig.Address, "address of a Gnosis Safe to post batches through (empty = post directly)")
+	f.Uint64(prefix+".gas-margin-percent", DefaultSafeConfig.GasMarginPercent, "percentage added to the estimated gas of execTransaction calls, as the Safe forwards only part of the gas to the sequencer inbox")
+}
+
+var DefaultSafeConfig = SafeConfig{
+	Address:          "",
+	GasMarginPercent: 10,
+}
+
+// The subset of the Gnosis Safe interface used to post batches
+const safeABIJSON = `[
+	{"type":"function","name":"execTransaction","stateMutability":"payable","inputs":[
+		{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},
+		{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},
+		{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},
+		{"name":"signatures","type":"bytes"}],"outputs":[{"name":"success","type":"bool"}]},
+	{"type":"function","name":"nonce","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getThreshold","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"isOwner","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+var safeABI abi.ABI
+
+func init() {
+	var err error
+	safeABI, err = abi.JSON(strings.NewReader(safeABIJSON))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// safeSignature is a Safe "pre-validated" signature, which approves a Safe transaction by its sender
+// being the owner, so no Safe transaction hash (and with it the Safe's nonce) needs to be signed.
+func safeSignature(owner common.Address) []byte {
+	signature := make([]byte, 65)
+	copy(signature[12:32], owner.Bytes())
+	signature[64] = 1
+	return signature
+}
+
+type batchPosterSafe struct {
+	config       *SafeConfig
+	address      common.Address
+	inboxAddress common.Address
+	client       arbutil.L1Interface
+	contract     *bind.BoundContract
+
+	mutex   sync.Mutex
+	checked map[common.Address]bool // the wallets confirmed to be able to execute Safe transactions alone
+}
+
+// newBatchPosterSafe returns nil if no Safe is configured.
+func newBatchPosterSafe(config *SafeConfig, inboxAddress common.Address, client arbutil.L1Interface) (*batchPosterSafe, error) {
+	if config.Address == "" {
+		return nil, nil
+	}
+	if !common.IsHexAddress(config.Address) {
+		return nil, fmt.Errorf("invalid batch poster safe address \"%v\"", config.Address)
+	}
+	address := common.HexToAddress(config.Address)
+	return &batchPosterSafe{
+		config:       config,
+		address:      address,
+		inboxAddress: inboxAddress,
+		client:       client,
+		contract:     bind.NewBoundContract(address, safeABI, client, client, client),
+		checked:      make(map[common.Address]bool),
+	}, nil
+}
+
+func (s *batchPosterSafe) callUint(ctx context.Context, method string, args ...interface{}) (*big.Int, error) {
+	var result []interface{}
+	err := s.contract.Call(&bind.CallOpts{Context: ctx}, &result, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(result[0], new(*big.Int)).(**big.Int), nil
+}
+
+// checkOwner verifies the wallet can execute Safe transactions without other owners' signatures.
+func (s *batchPosterSafe) checkOwner(ctx context.Context, owner common.Address) error {
+	s.mutex.Lock()
+	checked := s.checked[owner]
+	s.mutex.Unlock()
+	if checked {
+		return nil
+	}
+	threshold, err := s.callUint(ctx, "getThreshold")
+	if err != nil {
+		return err
+	}
+	if threshold.Cmp(common.Big1) != 0 {
+		return fmt.Errorf("batch poster safe %v has threshold %v, but only a threshold of 1 is supported", s.address, threshold)
+	}
+	var result []interface{}
+	err = s.contract.Call(&bind.CallOpts{Context: ctx}, &result, "isOwner", owner)
+	if err != nil {
+		return err
+	}
+	if isOwner, ok := result[0].(bool); !ok || !isOwner {
+		return fmt.Errorf("batch poster wallet %v isn't an owner of the batch poster safe %v", owner, s.address)
+	}
+	s.mutex.Lock()
+	s.checked[owner] = true
+	s.mutex.Unlock()
+	return nil
+}
+
+// addSequencerL2Batch builds a transaction executing addSequencerL2Batch on the sequencer inbox through the Safe.
+// The sequencer inbox only accepts batches from the transaction origin through addSequencerL2BatchFromOrigin,
+// so the batch data is emitted in a separate event instead of being read from the transaction input.
+func (s *batchPosterSafe) addSequencerL2Batch(ctx context.Context, txOpts *bind.TransactOpts, batchSeqNum uint64, sequencerMsg []byte, delayedMsg uint64, gasRefunder common.Address) (*types.Transaction, error) {
+	err := s.checkOwner(ctx, txOpts.From)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := sequencerBridgeABI.Pack("addSequencerL2Batch", new(big.Int).SetUint64(batchSeqNum), sequencerMsg, new(big.Int).SetUint64(delayedMsg), gasRefunder)
+	if err != nil {
+		return nil, err
+	}
+	// With a safeTxGas and gasPrice of 0, the Safe reverts if the inner call fails, so gas estimation
+	// catches a batch the sequencer inbox would reject.
+	zero := common.Big0
+	args := []interface{}{s.inboxAddress, zero, inner, uint8(0), zero, zero, zero, common.Address{}, common.Address{}, safeSignature(txOpts.From)}
+	data, err := safeABI.Pack("execTransaction", args...)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := s.client.EstimateGas(ctx, ethereum.CallMsg{
+		From: txOpts.From,
+		To:   &s.address,
+		Data: data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("estimating gas of batch through safe %v: %w", s.address, err)
+	}
+	safeNonce, err := s.callUint(ctx, "nonce")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("BatchPoster: wrapping batch in safe transaction", "safe", s.address, "safeNonce", safeNonce, "sequence nr.", batchSeqNum, "gas", gas)
+	opts := *txOpts
+	opts.GasLimit = gas + gas*s.config.GasMarginPercent/100
+	return s.contract.Transact(&opts, "execTransaction", args...)
+}
@@ -0,0 +1,29 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSafeSignature(t *testing.T) {
+	owner := common.HexToAddress("0x00112233445566778899aabbccddeeff00112233")
+	signature := safeSignature(owner)
+	if len(signature) != 65 {
+		Fail(t, "wrong signature length", len(signature))
+	}
+	// r is the owner, s is unused and v of 1 marks the signature as pre-validated by the sender
+	if common.BytesToAddress(signature[:32]) != owner || !bytes.Equal(signature[:12], make([]byte, 12)) {
+		Fail(t, "signature doesn't start with the owner", signature)
+	}
+	if !bytes.Equal(signature[32:64], make([]byte, 32)) || signature[64] != 1 {
+		Fail(t, "signature isn't pre-validated", signature)
+	}
+
+	_, err := safeABI.Pack("execTransaction", owner, common.Big0, []byte{1}, uint8(0), common.Big0, common.Big0, common.Big0, common.Address{}, common.Address{}, signature)
+	Require(t, err)
+}
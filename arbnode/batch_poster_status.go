@@ -0,0 +1,186 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+	"github.com/offchainlabs/nitro/util/openmetrics"
+)
+
+const postingLatencyMetric = "arb/batchposter/post/latency"
+
+// How long batch transactions take to be mined after they're sent, in milliseconds
+var postingLatencyHistogram = metrics.NewRegisteredHistogram(postingLatencyMetric, nil, metrics.NewExpDecaySample(1028, 0.015))
+
+// Batch posting costs older than this don't count towards the spend rate
+const spendRateWindow = 24 * time.Hour
+
+// Unposted messages beyond this many aren't read to estimate the pending bytes; their size is extrapolated
+const maxStatusMessagesRead = 10000
+
+type postingCost struct {
+	time time.Time
+	cost *big.Int
+}
+
+// spendTracker records what recent batches cost, to estimate how long the wallets' balance will last.
+type spendTracker struct {
+	mutex sync.Mutex
+	costs []postingCost
+}
+
+func (s *spendTracker) record(now time.Time, cost *big.Int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.costs = append(s.costs, postingCost{now, cost})
+	for len(s.costs) > 0 && now.Sub(s.costs[0].time) > spendRateWindow {
+		s.costs = s.costs[1:]
+	}
+}
+
+// perDay returns the spend per day over the window, or nil if nothing has been spent yet.
+func (s *spendTracker) perDay(now time.Time) *big.Int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	total := big.NewInt(0)
+	var oldest time.Time
+	for _, cost := range s.costs {
+		if now.Sub(cost.time) > spendRateWindow {
+			continue
+		}
+		if oldest.IsZero() {
+			oldest = cost.time
+		}
+		total.Add(total, cost.cost)
+	}
+	if total.Sign() == 0 {
+		return nil
+	}
+	// Until the window has filled, measure from the first recorded post, but never over less than an hour
+	elapsed := arbmath.MinInt(arbmath.MaxInt(int64(now.Sub(oldest)), int64(time.Hour)), int64(spendRateWindow))
+	perDay := arbmath.BigMulByUint(total, uint64(24*time.Hour))
+	return perDay.Div(perDay, big.NewInt(elapsed))
+}
+
+// recordPostingCost adds a mined batch transaction's fee to the spend rate, and returns its receipt and fee.
+// It returns a nil receipt if either couldn't be looked up.
+func (b *BatchPoster) recordPostingCost(ctx context.Context, tx *types.Transaction) (*types.Receipt, *big.Int) {
+	client := b.l1Reader.Client()
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil || receipt == nil {
+		log.Warn("failed to get batch transaction receipt to record its cost", "tx", tx.Hash(), "err", err)
+		return nil, nil
+	}
+	header, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		log.Warn("failed to get batch transaction block to record its cost", "tx", tx.Hash(), "err", err)
+		return nil, nil
+	}
+	gasPrice := tx.GasPrice()
+	if header.BaseFee != nil {
+		gasPrice = arbmath.BigMin(tx.GasFeeCap(), arbmath.BigAdd(header.BaseFee, tx.GasTipCap()))
+	}
+	cost := arbmath.BigMulByUint(gasPrice, receipt.GasUsed)
+	b.spending.record(time.Now(), cost)
+	return receipt, cost
+}
+
+func recordPostingLatency(ctx context.Context, batchSeqNum uint64, tx *types.Transaction, latency time.Duration) {
+	postingLatencyHistogram.Update(latency.Milliseconds())
+	openmetrics.RecordExemplarInTrace(ctx, postingLatencyMetric, float64(latency.Milliseconds()), map[string]string{
+		"batch": strconv.FormatUint(batchSeqNum, 10),
+		"tx":    tx.Hash().Hex(),
+	})
+}
+
+// BatchPosterStatus summarizes the batch poster's backlog and health.
+type BatchPosterStatus struct {
+	BatchCount         uint64         `json:"batchCount"`
+	UnpostedMessages   uint64         `json:"unpostedMessages"`
+	OldestUnpostedTime *time.Time     `json:"oldestUnpostedTime,omitempty"`
+	OldestUnpostedAge  string         `json:"oldestUnpostedAge,omitempty"`
+	PendingBytes       hexutil.Uint64 `json:"pendingBytes"` // uncompressed, extrapolated for large backlogs
+	Balance            *hexutil.Big   `json:"balance"`      // summed over all wallets
+	SpendPerDay        *hexutil.Big   `json:"spendPerDay,omitempty"`
+	RunwayDays         *float64       `json:"runwayDays,omitempty"`
+	LastPostTime       *time.Time     `json:"lastPostTime,omitempty"`
+	ActiveWallet       string         `json:"activeWallet"`
+	CircuitBreaker     string         `json:"circuitBreaker,omitempty"` // why posting stopped, if the circuit breaker tripped
+}
+
+// Status reports the posting backlog, the wallets' balance and runway at the recent spend rate, and the last post.
+func (b *BatchPoster) Status(ctx context.Context) (*BatchPosterStatus, error) {
+	batchCount, err := b.inbox.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	var postedMsgCount uint64
+	if batchCount > 0 {
+		count, err := b.inbox.GetBatchMessageCount(batchCount - 1)
+		if err != nil {
+			return nil, err
+		}
+		postedMsgCount = uint64(count)
+	}
+	msgCount, err := b.streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	status := &BatchPosterStatus{
+		BatchCount:       batchCount,
+		UnpostedMessages: arbmath.SaturatingUSub(uint64(msgCount), postedMsgCount),
+		ActiveWallet:     b.wallets.current().From.Hex(),
+		CircuitBreaker:   b.breaker.tripped(),
+	}
+
+	var pendingBytes, read uint64
+	for pos := postedMsgCount; pos < uint64(msgCount) && read < maxStatusMessagesRead; pos++ {
+		msg, err := b.streamer.GetMessage(arbutil.MessageIndex(pos))
+		if err != nil {
+			return nil, err
+		}
+		if read == 0 {
+			oldest := time.Unix(int64(msg.Message.Header.Timestamp), 0)
+			status.OldestUnpostedTime = &oldest
+			status.OldestUnpostedAge = time.Since(oldest).Round(time.Second).String()
+		}
+		pendingBytes += uint64(len(msg.Message.L2msg))
+		read++
+	}
+	if read > 0 && read < status.UnpostedMessages {
+		pendingBytes = pendingBytes / read * status.UnpostedMessages
+	}
+	status.PendingBytes = hexutil.Uint64(pendingBytes)
+
+	wallets, err := b.wallets.status(ctx, b.l1Reader.Client())
+	if err != nil {
+		return nil, err
+	}
+	balance := big.NewInt(0)
+	for _, wallet := range wallets {
+		balance.Add(balance, wallet.Balance.ToInt())
+		if wallet.LastPostTime != nil && (status.LastPostTime == nil || wallet.LastPostTime.After(*status.LastPostTime)) {
+			status.LastPostTime = wallet.LastPostTime
+		}
+	}
+	status.Balance = (*hexutil.Big)(balance)
+	if perDay := b.spending.perDay(time.Now()); perDay != nil {
+		status.SpendPerDay = (*hexutil.Big)(perDay)
+		runway, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), new(big.Float).SetInt(perDay)).Float64()
+		status.RunwayDays = &runway
+	}
+	return status, nil
+}
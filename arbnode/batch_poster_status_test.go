@@ -0,0 +1,39 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSpendTrackerPerDay(t *testing.T) {
+	var tracker spendTracker
+	start := time.Unix(1_000_000, 0)
+	if tracker.perDay(start) != nil {
+		Fail(t, "spend rate reported before anything was spent")
+	}
+
+	// Before an hour has passed, the spend is measured over an hour
+	tracker.record(start, big.NewInt(100))
+	if perDay := tracker.perDay(start.Add(time.Minute)); perDay.Cmp(big.NewInt(2400)) != 0 {
+		Fail(t, "unexpected spend per day", perDay)
+	}
+
+	tracker.record(start.Add(12*time.Hour), big.NewInt(200))
+	if perDay := tracker.perDay(start.Add(12 * time.Hour)); perDay.Cmp(big.NewInt(600)) != 0 {
+		Fail(t, "unexpected spend per day", perDay)
+	}
+
+	// Costs older than the window are dropped
+	now := start.Add(30 * time.Hour)
+	tracker.record(now, big.NewInt(100))
+	if perDay := tracker.perDay(now); perDay.Cmp(big.NewInt(400)) != 0 {
+		Fail(t, "unexpected spend per day", perDay)
+	}
+	if len(tracker.costs) != 2 {
+		Fail(t, "expected old costs to be dropped, have", len(tracker.costs))
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util"
+)
+
+type batchPosterWallet struct {
+	opts         *bind.TransactOpts
+	lastPostTime time.Time
+	lastPostTx   common.Hash
+	lastError    string
+	failovers    uint64
+}
+
+// batchPosterWallets holds the batch poster's wallets, of which one is active at a time.
+// Each wallet signs with its own address, so their nonces are independent and a stuck
+// transaction in one wallet doesn't block posting from the others.
+type batchPosterWallets struct {
+	mutex   sync.Mutex
+	wallets []*batchPosterWallet
+	active  int
+}
+
+func newBatchPosterWallets(primary *bind.TransactOpts, fallbacks []*bind.TransactOpts) *batchPosterWallets {
+	wallets := &batchPosterWallets{}
+	for _, opts := range append([]*bind.TransactOpts{primary}, fallbacks...) {
+		wallets.wallets = append(wallets.wallets, &batchPosterWallet{opts: opts})
+	}
+	return wallets
+}
+
+// FallbackBatchPosterOpts opens the configured fallback batch poster wallet, once for each of its accounts
+// to fail over to, or returns nil if no fallback wallet is configured.
+func FallbackBatchPosterOpts(ctx context.Context, client arbutil.L1Interface, wallet *genericconf.WalletConfig, accounts []string) ([]*bind.TransactOpts, error) {
+	if !wallet.ExternalSigner.Enabled() && wallet.PrivateKey == "" && wallet.Pathname == "" {
+		return nil, nil
+	}
+	if len(accounts) > 0 && !wallet.ExternalSigner.Enabled() && wallet.PrivateKey != "" {
+		return nil, errors.New("fallback batch poster accounts need a keystore or external signer, not a private key")
+	}
+	chainIdReader, ok := client.(interface {
+		ChainID(ctx context.Context) (*big.Int, error)
+	})
+	if !ok {
+		return nil, errors.New("fallback batch poster wallets need an L1 client which reports its chain id")
+	}
+	chainId, err := chainIdReader.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		opts, err := util.GetTransactOptsFromWallet(wallet, chainId)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open fallback batch poster wallet")
+		}
+		return []*bind.TransactOpts{opts}, nil
+	}
+	var opts []*bind.TransactOpts
+	for _, account := range accounts {
+		accountWallet := *wallet
+		accountWallet.Account = account
+		accountWallet.ExternalSigner.Address = account
+		walletOpts, err := util.GetTransactOptsFromWallet(&accountWallet, chainId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open fallback batch poster account %v", account)
+		}
+		opts = append(opts, walletOpts)
+	}
+	return opts, nil
+}
+
+// isInsufficientFundsError returns whether a transaction was rejected as its sender can't pay for it. Sent over
+// RPC, the rejection is a JSON-RPC error carrying the message of core.ErrInsufficientFunds rather than the error.
+func isInsufficientFundsError(err error) bool {
+	if errors.Is(err, core.ErrInsufficientFunds) {
+		return true
+	}
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && strings.HasPrefix(rpcErr.Error(), core.ErrInsufficientFunds.Error())
+}
+
+func (w *batchPosterWallets) current() *bind.TransactOpts {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.wallets[w.active].opts
+}
+
+// failover switches to the next wallet, if there is one, recording why the active wallet failed.
+func (w *batchPosterWallets) failover(reason string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	failed := w.wallets[w.active]
+	failed.lastError = reason
+	failed.failovers++
+	if len(w.wallets) == 1 {
+		return
+	}
+	w.active = (w.active + 1) % len(w.wallets)
+	log.Warn("batch poster failing over to another wallet", "from", failed.opts.From, "to", w.wallets[w.active].opts.From, "reason", reason)
+}
+
+func (w *batchPosterWallets) recordSuccess(from common.Address, txHash common.Hash) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, wallet := range w.wallets {
+		if wallet.opts.From == from {
+			wallet.lastPostTime = time.Now()
+			wallet.lastPostTx = txHash
+			wallet.lastError = ""
+		}
+	}
+}
+
+type BatchPosterWalletStatus struct {
+	Address      common.Address `json:"address"`
+	Active       bool           `json:"active"`
+	Balance      *hexutil.Big   `json:"balance"`
+	Nonce        hexutil.Uint64 `json:"nonce"`
+	PendingNonce hexutil.Uint64 `json:"pendingNonce"`
+	LastPostTime *time.Time     `json:"lastPostTime,omitempty"`
+	LastPostTx   *common.Hash   `json:"lastPostTx,omitempty"`
+	LastError    string         `json:"lastError,omitempty"`
+	Failovers    uint64         `json:"failovers"`
+}
+
+func (w *batchPosterWallets) status(ctx context.Context, client arbutil.L1Interface) ([]BatchPosterWalletStatus, error) {
+	w.mutex.Lock()
+	statuses := make([]BatchPosterWalletStatus, len(w.wallets))
+	for i, wallet := range w.wallets {
+		statuses[i] = BatchPosterWalletStatus{
+			Address:   wallet.opts.From,
+			Active:    i == w.active,
+			LastError: wallet.lastError,
+			Failovers: wallet.failovers,
+		}
+		if !wallet.lastPostTime.IsZero() {
+			lastPostTime := wallet.lastPostTime
+			lastPostTx := wallet.lastPostTx
+			statuses[i].LastPostTime = &lastPostTime
+			statuses[i].LastPostTx = &lastPostTx
+		}
+	}
+	w.mutex.Unlock()
+
+	for i := range statuses {
+		address := statuses[i].Address
+		balance, err := client.BalanceAt(ctx, address, nil)
+		if err != nil {
+			return nil, err
+		}
+		nonce, err := client.NonceAt(ctx, address, nil)
+		if err != nil {
+			return nil, err
+		}
+		pendingNonce, err := client.PendingNonceAt(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		statuses[i].Balance = (*hexutil.Big)(balance)
+		statuses[i].Nonce = hexutil.Uint64(nonce)
+		statuses[i].PendingNonce = hexutil.Uint64(pendingNonce)
+	}
+	return statuses, nil
+}
+
+type BatchPosterAPI struct {
+	batchPoster *BatchPoster
+}
+
+// BatchPosterWallets reports the balance, nonces and last successful post of each batch poster wallet.
+func (a *BatchPosterAPI) BatchPosterWallets(ctx context.Context) ([]BatchPosterWalletStatus, error) {
+	return a.batchPoster.wallets.status(ctx, a.batchPoster.l1Reader.Client())
+}
+
+// EstimateBatchPostingCost forecasts the L1 gas and cost of the next batch, given the current backlog.
+func (a *BatchPosterAPI) EstimateBatchPostingCost(ctx context.Context) (*BatchPostingCostEstimate, error) {
+	return a.batchPoster.EstimateBatchPostingCost(ctx)
+}
+
+// BatchPosterSimulateCompression compares the size and L1 cost of the next batch under other compression settings,
+// as calldata and as blobs priced at the given excess blob gas. Without settings, a range of levels of each algorithm is tried.
+func (a *BatchPosterAPI) BatchPosterSimulateCompression(ctx context.Context, settings []CompressionSetting, excessBlobGas *hexutil.Uint64) (*CompressionSimulationReport, error) {
+	var excess uint64
+	if excessBlobGas != nil {
+		excess = uint64(*excessBlobGas)
+	}
+	return a.batchPoster.SimulateCompression(ctx, settings, excess)
+}
+
+// BatchPosterStatus reports the posting backlog, how long the wallets' balance will last, and when a batch was last posted.
+func (a *BatchPosterAPI) BatchPosterStatus(ctx context.Context) (*BatchPosterStatus, error) {
+	return a.batchPoster.Status(ctx)
+}
+
+// BatchPosterFeeBumpState reports the fee escalation of the batch transaction being waited on, if any.
+func (a *BatchPosterAPI) BatchPosterFeeBumpState(ctx context.Context) (*FeeBumpState, error) {
+	return a.batchPoster.FeeBumpState(), nil
+}
+
+// BatchPosterReconcileNonce has the next batch replace the pending transactions the batch poster sent
+// but isn't waiting on from the active wallet, and reports its nonces.
+func (a *BatchPosterAPI) BatchPosterReconcileNonce(ctx context.Context) (*BatchPosterNonceStatus, error) {
+	return a.batchPoster.ReconcileNonce(ctx)
+}
+
+// BatchPosterLedger reconciles the L1 cost of the batches in [from, to) with the L1 fees ArbOS collected
+// from their transactions, for batches posted by this node.
+func (a *BatchPosterAPI) BatchPosterLedger(ctx context.Context, from hexutil.Uint64, to hexutil.Uint64) (*BatchLedgerReport, error) {
+	return BatchLedgerReportFor(a.batchPoster.streamer.db, uint64(from), uint64(to))
+}
+
+// BatchPosterResetCircuitBreaker resumes batch posting after the circuit breaker tripped.
+func (a *BatchPosterAPI) BatchPosterResetCircuitBreaker(ctx context.Context) error {
+	a.batchPoster.ResetCircuitBreaker()
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+)
+
+func TestBatchPosterWalletFailover(t *testing.T) {
+	primary := &bind.TransactOpts{From: common.HexToAddress("0x01")}
+	fallback := &bind.TransactOpts{From: common.HexToAddress("0x02")}
+	wallets := newBatchPosterWallets(primary, []*bind.TransactOpts{fallback})
+
+	if wallets.current() != primary {
+		Fail(t, "primary wallet not active initially")
+	}
+	wallets.failover("stuck")
+	if wallets.current() != fallback {
+		Fail(t, "didn't fail over to fallback wallet")
+	}
+	wallets.recordSuccess(fallback.From, common.HexToHash("0xabcd"))
+	wallets.failover("drained")
+	if wallets.current() != primary {
+		Fail(t, "didn't cycle back to primary wallet")
+	}
+	if wallets.wallets[0].failovers != 1 || wallets.wallets[0].lastError != "stuck" {
+		Fail(t, "primary wallet failure not recorded", wallets.wallets[0])
+	}
+	if wallets.wallets[1].lastPostTx != common.HexToHash("0xabcd") {
+		Fail(t, "fallback wallet success not recorded", wallets.wallets[1])
+	}
+
+	single := newBatchPosterWallets(primary, nil)
+	single.failover("stuck")
+	if single.current() != primary {
+		Fail(t, "single wallet changed on failover")
+	}
+}
+
+func TestInsufficientFundsError(t *testing.T) {
+	local := fmt.Errorf("%w: address %v have 1 want 2", core.ErrInsufficientFunds, common.HexToAddress("0x01"))
+	remote := testRPCError{-32000, local.Error()}
+	if !isInsufficientFundsError(local) || !isInsufficientFundsError(remote) {
+		Fail(t, "insufficient funds not recognized")
+	}
+	for _, err := range []error{
+		errors.New(local.Error()),
+		testRPCError{-32000, "execution reverted: insufficient funds in contract"},
+		testRPCError{-32000, "nonce too low"},
+	} {
+		if isInsufficientFundsError(err) {
+			Fail(t, "recognized as insufficient funds", err)
+		}
+	}
+}
+
+func TestFallbackBatchPosterOpts(t *testing.T) {
+	ctx := context.Background()
+	opts, err := FallbackBatchPosterOpts(ctx, nil, &genericconf.WalletConfigDefault, nil)
+	Require(t, err)
+	if opts != nil {
+		Fail(t, "fallback wallets opened without one configured", opts)
+	}
+	keyWallet := genericconf.WalletConfigDefault
+	keyWallet.PrivateKey = "b6b15c8cb491557369f3c7d2c287b053eb229daa9c22138887752191c9520659"
+	if _, err := FallbackBatchPosterOpts(ctx, nil, &keyWallet, []string{"0x01"}); err == nil {
+		Fail(t, "several accounts opened from a single private key")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// BatchPostingCostEstimate forecasts the L1 cost of the next batch from the current backlog.
+// Gas covers the transaction's intrinsic and data costs, but not the sequencer inbox's execution.
+type BatchPostingCostEstimate struct {
+	BatchSequenceNumber uint64       `json:"batchSequenceNumber"`
+	BacklogMessages     uint64       `json:"backlogMessages"`
+	Messages            uint64       `json:"messages"`
+	Transactions        uint64       `json:"transactions"`
+	UncompressedSize    uint64       `json:"uncompressedSize"`
+	CompressedSize      uint64       `json:"compressedSize"`
+	L1BaseFee           *hexutil.Big `json:"l1BaseFee"`
+	CalldataGas         uint64       `json:"calldataGas"`
+	BlobGas             uint64       `json:"blobGas"`
+	Cost                *hexutil.Big `json:"cost"`
+	CostPerTransaction  *hexutil.Big `json:"costPerTransaction,omitempty"`
+}
+
+// EstimateBatchPostingCost compresses the unposted messages that would go in the next batch, without
+// disturbing the batch being built, and prices the result at the current L1 base fee.
+func (b *BatchPoster) EstimateBatchPostingCost(ctx context.Context) (*BatchPostingCostEstimate, error) {
+	batchSeqNum, err := b.inbox.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	var prevBatchMeta BatchMetadata
+	if batchSeqNum > 0 {
+		prevBatchMeta, err = b.inbox.GetBatchMetadata(batchSeqNum - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	msgCount, err := b.streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	lastHeader, err := b.l1Reader.LastHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maxBatchSize := b.config.MaxBatchSize
+	if sizing := b.dynamicSizing(); sizing.Enable {
+		baseFee, err := b.parentGasPriceInFeeToken(lastHeader.BaseFee)
+		if err != nil {
+			return nil, err
+		}
+		maxBatchSize, _ = sizing.Target(baseFee, maxBatchSize, b.config.MaxBatchPostInterval)
+	}
+	segments, err := newBatchSegments(prevBatchMeta.DelayedMessageCount, maxBatchSize, b.compressor)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &BatchPostingCostEstimate{
+		BatchSequenceNumber: batchSeqNum,
+		L1BaseFee:           (*hexutil.Big)(lastHeader.BaseFee),
+	}
+	if msgCount > prevBatchMeta.MessageCount {
+		estimate.BacklogMessages = uint64(msgCount - prevBatchMeta.MessageCount)
+	}
+	chainId := b.streamer.bc.Config().ChainID
+	for pos := prevBatchMeta.MessageCount; pos < msgCount; pos++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg, err := b.streamer.GetMessage(pos)
+		if err != nil {
+			return nil, err
+		}
+		success, err := segments.AddMessage(&msg)
+		if err != nil {
+			return nil, err
+		}
+		if !success {
+			break
+		}
+		estimate.Messages++
+		if msg.Message.Header.Kind == arbos.L1MessageType_L2Message {
+			txes, err := msg.Message.ParseL2Transactions(chainId, nil)
+			if err == nil {
+				estimate.Transactions += uint64(len(txes))
+			}
+		}
+	}
+	uncompressedSize := segments.totalUncompressedSize
+	data, err := segments.CloseAndGetBytes()
+	if err != nil {
+		return nil, err
+	}
+	estimate.UncompressedSize = uint64(uncompressedSize)
+	estimate.CompressedSize = uint64(len(data))
+	estimate.CalldataGas = params.TxGas + calldataGas(data) + calldataBatchOverheadBytes*params.TxDataNonZeroGasEIP2028
+	estimate.BlobGas = uint64(blobsRequired(len(data))) * blobGasPerBlob
+	cost := arbmath.BigMulByUint(lastHeader.BaseFee, estimate.CalldataGas)
+	estimate.Cost = (*hexutil.Big)(cost)
+	if estimate.Transactions > 0 {
+		estimate.CostPerTransaction = (*hexutil.Big)(new(big.Int).Div(cost, new(big.Int).SetUint64(estimate.Transactions)))
+	}
+	return estimate, nil
+}
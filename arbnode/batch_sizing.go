@@ -0,0 +1,72 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DynamicBatchSizingConfig scales the target batch size and posting interval with the L1 base fee.
+// At or below the low base fee, batches are capped at min-size and posted at least every min-interval.
+// At or above the high base fee, the batch poster's max-size and max-interval apply.
+// In between, both are interpolated linearly. With a fee token oracle, base fees are priced in the fee token.
+type DynamicBatchSizingConfig struct {
+	Enable      bool          `koanf:"enable"`
+	LowBaseFee  float64       `koanf:"low-base-fee"`
+	HighBaseFee float64       `koanf:"high-base-fee"`
+	MinSize     int           `koanf:"min-size"`
+	MinInterval time.Duration `koanf:"min-interval"`
+}
+
+func DynamicBatchSizingConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultDynamicBatchSizingConfig.Enable, "adjust batch size and posting interval based on the L1 base fee")
+	f.Float64(prefix+".low-base-fee", DefaultDynamicBatchSizingConfig.LowBaseFee, "L1 base fee in gwei at or below which the smallest, most frequent batches are posted")
+	f.Float64(prefix+".high-base-fee", DefaultDynamicBatchSizingConfig.HighBaseFee, "L1 base fee in gwei at or above which the largest, least frequent batches are posted")
+	f.Int(prefix+".min-size", DefaultDynamicBatchSizingConfig.MinSize, "batch size limit when the L1 base fee is low")
+	f.Duration(prefix+".min-interval", DefaultDynamicBatchSizingConfig.MinInterval, "maximum batch posting interval when the L1 base fee is low")
+}
+
+var DefaultDynamicBatchSizingConfig = DynamicBatchSizingConfig{
+	Enable:      false,
+	LowBaseFee:  10,
+	HighBaseFee: 100,
+	MinSize:     10000,
+	MinInterval: time.Minute,
+}
+
+// feeFraction returns where the base fee lies between the low and high base fees, clamped to [0, 1].
+func (c *DynamicBatchSizingConfig) feeFraction(baseFee *big.Int) float64 {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(baseFee), big.NewFloat(params.GWei)).Float64()
+	if gwei <= c.LowBaseFee || c.HighBaseFee <= c.LowBaseFee {
+		return 0
+	}
+	if gwei >= c.HighBaseFee {
+		return 1
+	}
+	return (gwei - c.LowBaseFee) / (c.HighBaseFee - c.LowBaseFee)
+}
+
+// Target returns the batch size limit and maximum posting interval to use at the given L1 base fee.
+func (c *DynamicBatchSizingConfig) Target(baseFee *big.Int, maxSize int, maxInterval time.Duration) (int, time.Duration) {
+	if !c.Enable || baseFee == nil {
+		return maxSize, maxInterval
+	}
+	minSize := c.MinSize
+	if minSize > maxSize {
+		minSize = maxSize
+	}
+	minInterval := c.MinInterval
+	if minInterval > maxInterval {
+		minInterval = maxInterval
+	}
+	fraction := c.feeFraction(baseFee)
+	size := minSize + int(fraction*float64(maxSize-minSize))
+	interval := minInterval + time.Duration(fraction*float64(maxInterval-minInterval))
+	return size, interval
+}
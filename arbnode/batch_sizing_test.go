@@ -0,0 +1,50 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestDynamicBatchSizingTarget(t *testing.T) {
+	config := DynamicBatchSizingConfig{
+		Enable:      true,
+		LowBaseFee:  10,
+		HighBaseFee: 110,
+		MinSize:     10000,
+		MinInterval: time.Minute,
+	}
+	maxSize := 110000
+	maxInterval := 101 * time.Minute
+	gwei := func(n int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+	}
+	cases := []struct {
+		baseFee  *big.Int
+		size     int
+		interval time.Duration
+	}{
+		{gwei(1), 10000, time.Minute},
+		{gwei(10), 10000, time.Minute},
+		{gwei(60), 60000, 51 * time.Minute},
+		{gwei(110), 110000, 101 * time.Minute},
+		{gwei(1000), 110000, 101 * time.Minute},
+	}
+	for _, c := range cases {
+		size, interval := config.Target(c.baseFee, maxSize, maxInterval)
+		if size != c.size || interval != c.interval {
+			Fail(t, "base fee", c.baseFee, "expected", c.size, c.interval, "but got", size, interval)
+		}
+	}
+
+	config.Enable = false
+	size, interval := config.Target(gwei(1), maxSize, maxInterval)
+	if size != maxSize || interval != maxInterval {
+		Fail(t, "disabled dynamic sizing changed the target", size, interval)
+	}
+}
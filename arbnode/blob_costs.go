@@ -0,0 +1,70 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// EIP-4844 blob parameters, used only to estimate what a batch would cost to post as blobs, for comparison with
+// its calldata cost. The go-ethereum version nitro builds against has no blob-carrying transactions, so batches
+// are neither posted nor read as blobs.
+const (
+	blobGasPerBlob             = 1 << 17
+	blobFieldElements          = 4096
+	blobUsableBytesPerElement  = 31 // the top byte of each field element is left empty to stay below the modulus
+	blobUsableBytes            = blobFieldElements * blobUsableBytesPerElement
+	minBlobBaseFee             = 1
+	blobBaseFeeUpdateFraction  = 3338477
+	blobBatchOverheadCalldata  = 4 + 32*5 // the batch posting call's arguments besides the data itself
+	calldataBatchOverheadBytes = 4 + 32*6 // as above, plus the offset and length of the data
+)
+
+// blobBaseFee computes the L1 blob base fee from a block's excess blob gas.
+func blobBaseFee(excessBlobGas uint64) *big.Int {
+	return fakeExponential(big.NewInt(minBlobBaseFee), new(big.Int).SetUint64(excessBlobGas), big.NewInt(blobBaseFeeUpdateFraction))
+}
+
+// fakeExponential approximates factor * e ** (numerator / denominator) using a Taylor expansion, as specified by EIP-4844.
+func fakeExponential(factor, numerator, denominator *big.Int) *big.Int {
+	output := new(big.Int)
+	accum := new(big.Int).Mul(factor, denominator)
+	for i := int64(1); accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+		accum.Mul(accum, numerator)
+		accum.Div(accum, denominator)
+		accum.Div(accum, big.NewInt(i))
+	}
+	return output.Div(output, denominator)
+}
+
+func blobsRequired(dataLength int) int {
+	return (dataLength + blobUsableBytes - 1) / blobUsableBytes
+}
+
+func calldataGas(data []byte) uint64 {
+	var gas uint64
+	for _, b := range data {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	return gas
+}
+
+// batchPostingCosts returns the L1 cost of posting a batch's data as calldata and as blobs.
+// The execution cost of the batch posting call, which both have in common, isn't included.
+func batchPostingCosts(data []byte, l1BaseFee *big.Int, excessBlobGas uint64) (*big.Int, *big.Int) {
+	calldataCost := arbmath.BigMulByUint(l1BaseFee, calldataGas(data)+calldataBatchOverheadBytes*params.TxDataNonZeroGasEIP2028)
+	blobGas := uint64(blobsRequired(len(data))) * blobGasPerBlob
+	blobCost := arbmath.BigMulByUint(blobBaseFee(excessBlobGas), blobGas)
+	blobCost.Add(blobCost, arbmath.BigMulByUint(l1BaseFee, blobBatchOverheadCalldata*params.TxDataNonZeroGasEIP2028))
+	return calldataCost, blobCost
+}
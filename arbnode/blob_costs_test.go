@@ -0,0 +1,50 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestBlobBaseFee(t *testing.T) {
+	cases := []struct {
+		excessBlobGas uint64
+		expected      int64
+	}{
+		{0, 1},
+		{2314057, 1},
+		{2314058, 2},
+		{10 * 1024 * 1024, 23},
+	}
+	for _, c := range cases {
+		fee := blobBaseFee(c.excessBlobGas)
+		if fee.Int64() != c.expected {
+			Fail(t, "excess blob gas", c.excessBlobGas, "expected blob base fee", c.expected, "but got", fee)
+		}
+	}
+}
+
+func TestBatchPostingCosts(t *testing.T) {
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i%255) + 1
+	}
+	l1BaseFee := big.NewInt(params.GWei * 20)
+
+	calldataCost, blobCost := batchPostingCosts(data, l1BaseFee, 0)
+	if blobCost.Cmp(calldataCost) >= 0 {
+		Fail(t, "blobs should be cheaper than calldata at the minimum blob base fee", blobCost, calldataCost)
+	}
+	// a blob base fee far above the calldata cost of the batch
+	calldataCost, blobCost = batchPostingCosts(data, l1BaseFee, 200_000_000)
+	if blobCost.Cmp(calldataCost) <= 0 {
+		Fail(t, "calldata should be cheaper than blobs when blob gas is expensive", blobCost, calldataCost)
+	}
+	if blobsRequired(blobUsableBytes) != 1 || blobsRequired(blobUsableBytes+1) != 2 {
+		Fail(t, "unexpected number of blobs required")
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// The most batches a single block index query returns
+const maxBlockIndexQueryBatches = 1000
+
+// The block index relates L2 blocks to the L1 blocks whose batches included them, in both directions:
+// l1BlockBatchPrefix maps an L1 block and batch sequence number to the batch's message count, and
+// batchMessageCountPrefix maps the message count of each batch which added messages to its sequence number.
+// It's kept in the same database batches as the batch metadata, so it's always consistent with it.
+
+func l1BlockBatchKey(l1Block uint64, batchSeqNum uint64) []byte {
+	return append(uint64ToKey(l1Block), uint64ToKey(batchSeqNum)...)
+}
+
+// writeBlockIndex indexes a batch, given the message count of the batch before it.
+func writeBlockIndex(dbBatch ethdb.KeyValueWriter, batchSeqNum uint64, meta BatchMetadata, prevMessageCount arbutil.MessageIndex) error {
+	countData, err := rlp.EncodeToBytes(uint64(meta.MessageCount))
+	if err != nil {
+		return err
+	}
+	err = dbBatch.Put(append(append([]byte{}, l1BlockBatchPrefix...), l1BlockBatchKey(meta.L1Block, batchSeqNum)...), countData)
+	if err != nil {
+		return err
+	}
+	if meta.MessageCount > prevMessageCount {
+		seqNumData, err := rlp.EncodeToBytes(batchSeqNum)
+		if err != nil {
+			return err
+		}
+		err = dbBatch.Put(dbKey(batchMessageCountPrefix, uint64(meta.MessageCount)), seqNumData)
+		if err != nil {
+			return err
+		}
+	}
+	countData, err = rlp.EncodeToBytes(batchSeqNum + 1)
+	if err != nil {
+		return err
+	}
+	return dbBatch.Put(blockIndexBatchCountKey, countData)
+}
+
+// deleteBlockIndexFrom removes the batches starting at batchSeqNum from the index, if they're in it.
+// Only call this with the mutex held.
+func (t *InboxTracker) deleteBlockIndexFrom(dbBatch ethdb.Batch, batchSeqNum uint64) error {
+	indexed, err := t.blockIndexBatchCount()
+	if err != nil {
+		return err
+	}
+	if batchSeqNum >= indexed {
+		return nil
+	}
+	meta, err := t.GetBatchMetadata(batchSeqNum)
+	if err != nil {
+		return err
+	}
+	var prevMessageCount arbutil.MessageIndex
+	if batchSeqNum > 0 {
+		prevMessageCount, err = t.GetBatchMessageCount(batchSeqNum - 1)
+		if err != nil {
+			return err
+		}
+	}
+	err = deleteStartingAt(t.db, dbBatch, l1BlockBatchPrefix, l1BlockBatchKey(meta.L1Block, batchSeqNum))
+	if err != nil {
+		return err
+	}
+	err = deleteStartingAt(t.db, dbBatch, batchMessageCountPrefix, uint64ToKey(uint64(prevMessageCount)+1))
+	if err != nil {
+		return err
+	}
+	countData, err := rlp.EncodeToBytes(batchSeqNum)
+	if err != nil {
+		return err
+	}
+	return dbBatch.Put(blockIndexBatchCountKey, countData)
+}
+
+func (t *InboxTracker) blockIndexBatchCount() (uint64, error) {
+	hasKey, err := t.db.Has(blockIndexBatchCountKey)
+	if err != nil || !hasKey {
+		return 0, err
+	}
+	data, err := t.db.Get(blockIndexBatchCountKey)
+	if err != nil {
+		return 0, err
+	}
+	var count uint64
+	err = rlp.DecodeBytes(data, &count)
+	return count, err
+}
+
+// backfillBlockIndex indexes the batches read before the index existed, see migrateBlockIndex.
+func (t *InboxTracker) backfillBlockIndex() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	indexed, err := t.blockIndexBatchCount()
+	if err != nil {
+		return err
+	}
+	batchCount, err := t.GetBatchCount()
+	if err != nil {
+		return err
+	}
+	if indexed >= batchCount {
+		return nil
+	}
+	log.Info("backfilling L1 block index", "from", indexed, "to", batchCount)
+	var prevMessageCount arbutil.MessageIndex
+	if indexed > 0 {
+		prevMessageCount, err = t.GetBatchMessageCount(indexed - 1)
+		if err != nil {
+			return err
+		}
+	}
+	dbBatch := t.db.NewBatch()
+	for seqNum := indexed; seqNum < batchCount; seqNum++ {
+		meta, err := t.GetBatchMetadata(seqNum)
+		if err != nil {
+			return err
+		}
+		err = writeBlockIndex(dbBatch, seqNum, meta, prevMessageCount)
+		if err != nil {
+			return err
+		}
+		prevMessageCount = meta.MessageCount
+		if dbBatch.ValueSize() >= ethdb.IdealBatchSize {
+			err = dbBatch.Write()
+			if err != nil {
+				return err
+			}
+			dbBatch.Reset()
+		}
+	}
+	return dbBatch.Write()
+}
+
+// FindBatchContainingMessage returns the sequence number of the batch which included a message, if one has.
+func (t *InboxTracker) FindBatchContainingMessage(pos arbutil.MessageIndex) (uint64, bool, error) {
+	iter := t.db.NewIterator(batchMessageCountPrefix, uint64ToKey(uint64(pos)+1))
+	defer iter.Release()
+	if !iter.Next() {
+		return 0, false, iter.Error()
+	}
+	var seqNum uint64
+	err := rlp.DecodeBytes(iter.Value(), &seqNum)
+	return seqNum, err == nil, err
+}
+
+// BatchBlockRange is a batch, the L1 block it was posted in, and the L2 blocks it included.
+// LastL2Block is below FirstL2Block if the batch added no messages.
+type BatchBlockRange struct {
+	BatchSequenceNumber hexutil.Uint64 `json:"batchSequenceNumber"`
+	L1Block             hexutil.Uint64 `json:"l1Block"`
+	FirstL2Block        hexutil.Uint64 `json:"firstL2Block"`
+	LastL2Block         hexutil.Uint64 `json:"lastL2Block"`
+}
+
+func (t *InboxTracker) batchBlockRange(seqNum uint64) (BatchBlockRange, error) {
+	meta, err := t.GetBatchMetadata(seqNum)
+	if err != nil {
+		return BatchBlockRange{}, err
+	}
+	var prevMessageCount arbutil.MessageIndex
+	if seqNum > 0 {
+		prevMessageCount, err = t.GetBatchMessageCount(seqNum - 1)
+		if err != nil {
+			return BatchBlockRange{}, err
+		}
+	}
+	genesis, err := t.txStreamer.GetGenesisBlockNumber()
+	if err != nil {
+		return BatchBlockRange{}, err
+	}
+	return BatchBlockRange{
+		BatchSequenceNumber: hexutil.Uint64(seqNum),
+		L1Block:             hexutil.Uint64(meta.L1Block),
+		FirstL2Block:        hexutil.Uint64(uint64(prevMessageCount) + genesis),
+		LastL2Block:         hexutil.Uint64(uint64(meta.MessageCount) + genesis - 1),
+	}, nil
+}
+
+// BatchesInL1Blocks returns the batches posted in the L1 blocks [from, to], up to limit of them.
+func (t *InboxTracker) BatchesInL1Blocks(ctx context.Context, from uint64, to uint64, limit int) ([]BatchBlockRange, error) {
+	iter := t.db.NewIterator(l1BlockBatchPrefix, uint64ToKey(from))
+	defer iter.Release()
+	var ranges []BatchBlockRange
+	for len(ranges) < limit && iter.Next() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		key := iter.Key()[len(l1BlockBatchPrefix):]
+		if len(key) != 16 {
+			return nil, fmt.Errorf("invalid L1 block index key %v", iter.Key())
+		}
+		if binary.BigEndian.Uint64(key[:8]) > to {
+			break
+		}
+		blockRange, err := t.batchBlockRange(binary.BigEndian.Uint64(key[8:]))
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, blockRange)
+	}
+	return ranges, iter.Error()
+}
+
+// BatchesWithL2Blocks returns the batches which included the L2 blocks [from, to], up to limit of them.
+func (t *InboxTracker) BatchesWithL2Blocks(ctx context.Context, from uint64, to uint64, limit int) ([]BatchBlockRange, error) {
+	genesis, err := t.txStreamer.GetGenesisBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	if from < genesis {
+		from = genesis
+	}
+	var ranges []BatchBlockRange
+	for from <= to && len(ranges) < limit {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		seqNum, found, err := t.FindBatchContainingMessage(arbutil.MessageIndex(from - genesis))
+		if err != nil || !found {
+			return ranges, err
+		}
+		blockRange, err := t.batchBlockRange(seqNum)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, blockRange)
+		from = uint64(blockRange.LastL2Block) + 1
+	}
+	return ranges, nil
+}
+
+// L1BlockIndexAPI relates L2 blocks to the L1 blocks whose batches included them.
+type L1BlockIndexAPI struct {
+	inboxTracker *InboxTracker
+}
+
+// L1BlockRangeBatches returns the batches posted in the L1 blocks [from, to] and the L2 blocks each included.
+func (a *L1BlockIndexAPI) L1BlockRangeBatches(ctx context.Context, from, to hexutil.Uint64) ([]BatchBlockRange, error) {
+	if from > to {
+		return nil, errors.New("invalid range: from is after to")
+	}
+	return a.inboxTracker.BatchesInL1Blocks(ctx, uint64(from), uint64(to), maxBlockIndexQueryBatches)
+}
+
+// L2BlockRangeBatches returns the batches which included the L2 blocks [from, to] and the L1 blocks they were posted in.
+func (a *L1BlockIndexAPI) L2BlockRangeBatches(ctx context.Context, from, to hexutil.Uint64) ([]BatchBlockRange, error) {
+	if from > to {
+		return nil, errors.New("invalid range: from is after to")
+	}
+	return a.inboxTracker.BatchesWithL2Blocks(ctx, uint64(from), uint64(to), maxBlockIndexQueryBatches)
+}
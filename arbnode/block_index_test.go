@@ -0,0 +1,69 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestBlockIndex(t *testing.T) {
+	tracker := &InboxTracker{db: rawdb.NewMemoryDatabase()}
+	metas := []BatchMetadata{
+		{MessageCount: 1, L1Block: 10},
+		{MessageCount: 5, L1Block: 12},
+		{MessageCount: 5, L1Block: 12}, // only delayed messages were read
+		{MessageCount: 8, L1Block: 15},
+	}
+	dbBatch := tracker.db.NewBatch()
+	var prevMessageCount arbutil.MessageIndex
+	for seqNum, meta := range metas {
+		metaBytes, err := rlp.EncodeToBytes(meta)
+		Require(t, err)
+		Require(t, dbBatch.Put(dbKey(sequencerBatchMetaPrefix, uint64(seqNum)), metaBytes))
+		Require(t, writeBlockIndex(dbBatch, uint64(seqNum), meta, prevMessageCount))
+		prevMessageCount = meta.MessageCount
+	}
+	Require(t, dbBatch.Write())
+
+	expectBatch := func(pos arbutil.MessageIndex, expected uint64, expectFound bool) {
+		t.Helper()
+		seqNum, found, err := tracker.FindBatchContainingMessage(pos)
+		Require(t, err)
+		if found != expectFound || (found && seqNum != expected) {
+			Fail(t, "message", pos, "expected in batch", expected, expectFound, "but got", seqNum, found)
+		}
+	}
+	expectBatch(0, 0, true)
+	expectBatch(1, 1, true)
+	expectBatch(4, 1, true)
+	expectBatch(5, 3, true)
+	expectBatch(7, 3, true)
+	expectBatch(8, 0, false)
+
+	// Reorging out the last two batches removes them from both directions of the index
+	dbBatch = tracker.db.NewBatch()
+	Require(t, tracker.deleteBlockIndexFrom(dbBatch, 2))
+	Require(t, dbBatch.Write())
+	expectBatch(4, 1, true)
+	expectBatch(5, 0, false)
+	count, err := tracker.blockIndexBatchCount()
+	Require(t, err)
+	if count != 2 {
+		Fail(t, "expected 2 batches indexed but got", count)
+	}
+	iter := tracker.db.NewIterator(l1BlockBatchPrefix, nil)
+	defer iter.Release()
+	entries := 0
+	for iter.Next() {
+		entries++
+	}
+	if entries != 2 {
+		Fail(t, "expected 2 L1 block index entries but got", entries)
+	}
+}
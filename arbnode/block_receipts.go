@@ -0,0 +1,281 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	flag "github.com/spf13/pflag"
+)
+
+// BlockReceiptsConfig bounds the bulk receipt endpoints indexers use to backfill, so a single
+// request can't tie up the node the way thousands of eth_getTransactionReceipt calls would.
+type BlockReceiptsConfig struct {
+	MaxBlocks       uint64        `koanf:"max-blocks"`
+	MaxStreamBlocks uint64        `koanf:"max-stream-blocks"`
+	Timeout         time.Duration `koanf:"timeout"`
+	MaxResultSize   uint64        `koanf:"max-result-size"`
+}
+
+func BlockReceiptsConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Uint64(prefix+".max-blocks", DefaultBlockReceiptsConfig.MaxBlocks, "maximum number of blocks served by a single eth_getBlockRangeReceipts request")
+	f.Uint64(prefix+".max-stream-blocks", DefaultBlockReceiptsConfig.MaxStreamBlocks, "maximum number of blocks streamed by a single blockReceipts subscription")
+	f.Duration(prefix+".timeout", DefaultBlockReceiptsConfig.Timeout, "time budget for a single eth_getBlockRangeReceipts request, after which a truncated result is returned (0 = unlimited)")
+	f.Uint64(prefix+".max-result-size", DefaultBlockReceiptsConfig.MaxResultSize, "approximate memory budget in bytes for a single eth_getBlockRangeReceipts result, after which it is truncated (0 = unlimited)")
+}
+
+var DefaultBlockReceiptsConfig = BlockReceiptsConfig{
+	MaxBlocks:       1000,
+	MaxStreamBlocks: 100000,
+	Timeout:         10 * time.Second,
+	MaxResultSize:   64 * 1024 * 1024,
+}
+
+const truncatedByBlockRange = "max-blocks"
+
+// Approximate serialized sizes used to account a receipt against the result size budget
+const (
+	receiptBytes    = 1024
+	receiptLogBytes = 512
+)
+
+type BlockReceipts struct {
+	BlockNumber hexutil.Uint64           `json:"blockNumber"`
+	BlockHash   common.Hash              `json:"blockHash"`
+	Receipts    []map[string]interface{} `json:"receipts"`
+
+	// Set on the last block of a blockReceipts subscription, after which no more are sent
+	Last bool `json:"last,omitempty"`
+}
+
+type BlockRangeReceipts struct {
+	First  hexutil.Uint64  `json:"first"`
+	Blocks []BlockReceipts `json:"blocks"`
+
+	// Set if the request ran out of budget, in which case only the first blocks are included
+	Truncated string `json:"truncated,omitempty"`
+}
+
+// ArbReceiptsAPI serves all the receipts of a block, or of a range of blocks, in one call.
+type ArbReceiptsAPI struct {
+	blockchain *core.BlockChain
+	config     *BlockReceiptsConfig
+}
+
+// GetBlockReceipts returns the receipts of every transaction in the block, in the format of
+// eth_getTransactionReceipt, or nil if the block isn't known.
+func (api *ArbReceiptsAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	block, err := api.blockByNumberOrHash(blockNrOrHash)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	receipts, err := api.blockReceipts(block)
+	if err != nil {
+		return nil, err
+	}
+	return receipts.Receipts, nil
+}
+
+// GetBlockRangeReceipts returns the receipts of every block from start to end inclusive, truncating the range
+// at the first block that doesn't fit in the request's budget.
+func (api *ArbReceiptsAPI) GetBlockRangeReceipts(ctx context.Context, start, end rpc.BlockNumber) (*BlockRangeReceipts, error) {
+	first, last, err := api.blockRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	budget, cancel := newRequestBudget(ctx, &DebugLimitsConfig{
+		Timeout:       api.config.Timeout,
+		MaxResultSize: api.config.MaxResultSize,
+	})
+	defer cancel()
+
+	result := &BlockRangeReceipts{
+		First:  hexutil.Uint64(first),
+		Blocks: []BlockReceipts{},
+	}
+	if api.config.MaxBlocks > 0 && last-first+1 > api.config.MaxBlocks {
+		log.Warn("Sanitizing block range receipts # of blocks", "requested", last-first+1, "truncated", api.config.MaxBlocks)
+		last = first + api.config.MaxBlocks - 1
+		result.Truncated = truncatedByBlockRange
+	}
+	for number := first; number <= last; number++ {
+		block := api.blockchain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block %v not found", number)
+		}
+		receipts, err := api.blockReceipts(block)
+		if err != nil {
+			return nil, err
+		}
+		if !budget.spend(receiptsSize(receipts)) {
+			result.Truncated = budget.truncated()
+			log.Warn("Truncating block range receipts", "requested", last-first+1, "served", number-first, "reason", budget.truncated())
+			break
+		}
+		result.Blocks = append(result.Blocks, *receipts)
+	}
+	return result, nil
+}
+
+// BlockReceipts streams the receipts of every block from start to end inclusive, one notification per block,
+// as eth_subscribe("blockReceipts", start, end). The stream is paced by how fast the client reads it,
+// and the last block's notification is marked as such.
+func (api *ArbReceiptsAPI) BlockReceipts(ctx context.Context, start, end rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	first, last, err := api.blockRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	if api.config.MaxStreamBlocks > 0 && last-first+1 > api.config.MaxStreamBlocks {
+		return nil, fmt.Errorf("requested %v blocks, but at most %v can be streamed at once", last-first+1, api.config.MaxStreamBlocks)
+	}
+
+	subscription := notifier.CreateSubscription()
+	go func() {
+		for number := first; number <= last; number++ {
+			select {
+			case <-subscription.Err():
+				return
+			case <-notifier.Closed():
+				return
+			default:
+			}
+			block := api.blockchain.GetBlockByNumber(number)
+			if block == nil {
+				log.Warn("block receipts subscription block not found", "block", number)
+				return
+			}
+			receipts, err := api.blockReceipts(block)
+			if err != nil {
+				log.Warn("failed to read block receipts for subscription", "block", number, "err", err)
+				return
+			}
+			receipts.Last = number == last
+			if err := notifier.Notify(subscription.ID, receipts); err != nil {
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+func (api *ArbReceiptsAPI) blockByNumberOrHash(blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block := api.blockchain.GetBlockByHash(hash)
+		if block == nil {
+			return nil, nil
+		}
+		if blockNrOrHash.RequireCanonical && api.blockchain.GetCanonicalHash(block.NumberU64()) != hash {
+			return nil, fmt.Errorf("block %v is not canonical", hash)
+		}
+		return block, nil
+	}
+	number, ok := blockNrOrHash.Number()
+	if !ok {
+		return nil, errors.New("invalid block number or hash")
+	}
+	resolved, _ := api.blockchain.ClipToPostNitroGenesis(number)
+	return api.blockchain.GetBlockByNumber(uint64(resolved)), nil
+}
+
+func (api *ArbReceiptsAPI) blockRange(start, end rpc.BlockNumber) (uint64, uint64, error) {
+	start, _ = api.blockchain.ClipToPostNitroGenesis(start)
+	end, _ = api.blockchain.ClipToPostNitroGenesis(end)
+	if start < 0 || end < start {
+		return 0, 0, fmt.Errorf("invalid block range: %v to %v", start.Int64(), end.Int64())
+	}
+	if current := api.blockchain.CurrentBlock().NumberU64(); uint64(end) > current {
+		return 0, 0, fmt.Errorf("block range ends at %v, past the latest block %v", end.Int64(), current)
+	}
+	return uint64(start), uint64(end), nil
+}
+
+func (api *ArbReceiptsAPI) blockReceipts(block *types.Block) (*BlockReceipts, error) {
+	receipts := api.blockchain.GetReceiptsByHash(block.Hash())
+	return marshalBlockReceipts(api.blockchain.Config(), block, receipts)
+}
+
+// marshalBlockReceipts formats the block's receipts as eth_getTransactionReceipt does.
+func marshalBlockReceipts(chainConfig *params.ChainConfig, block *types.Block, receipts types.Receipts) (*BlockReceipts, error) {
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("block %v has %v transactions but %v receipts", block.NumberU64(), len(txs), len(receipts))
+	}
+	signer := types.LatestSigner(chainConfig)
+	header := block.Header()
+	result := &BlockReceipts{
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		BlockHash:   block.Hash(),
+		Receipts:    make([]map[string]interface{}, len(receipts)),
+	}
+	for i, receipt := range receipts {
+		tx := txs[i]
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %v in block %v: %w", tx.Hash(), block.NumberU64(), err)
+		}
+		fields := map[string]interface{}{
+			"blockHash":         block.Hash(),
+			"blockNumber":       hexutil.Uint64(block.NumberU64()),
+			"transactionHash":   tx.Hash(),
+			"transactionIndex":  hexutil.Uint64(i),
+			"from":              from,
+			"to":                tx.To(),
+			"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+			"gasUsedForL1":      hexutil.Uint64(receipt.GasUsedForL1),
+			"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+			"contractAddress":   nil,
+			"logs":              receipt.Logs,
+			"logsBloom":         receipt.Bloom,
+			"type":              hexutil.Uint(tx.Type()),
+		}
+		if header.BaseFee == nil {
+			fields["effectiveGasPrice"] = (*hexutil.Big)(tx.GasPrice())
+		} else {
+			gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
+			fields["effectiveGasPrice"] = (*hexutil.Big)(gasPrice)
+		}
+		if len(receipt.PostState) > 0 {
+			fields["root"] = hexutil.Bytes(receipt.PostState)
+		} else {
+			fields["status"] = hexutil.Uint(receipt.Status)
+		}
+		if receipt.Logs == nil {
+			fields["logs"] = []*types.Log{}
+		}
+		if receipt.ContractAddress != (common.Address{}) {
+			fields["contractAddress"] = receipt.ContractAddress
+		}
+		result.Receipts[i] = fields
+	}
+	return result, nil
+}
+
+func receiptsSize(receipts *BlockReceipts) uint64 {
+	size := uint64(0)
+	for _, receipt := range receipts.Receipts {
+		size += receiptBytes
+		if logs, ok := receipt["logs"].([]*types.Log); ok {
+			for _, log := range logs {
+				size += receiptLogBytes + uint64(len(log.Data))
+			}
+		}
+	}
+	return size
+}
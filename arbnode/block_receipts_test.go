@@ -0,0 +1,80 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestMarshalBlockReceipts(t *testing.T) {
+	chainConfig := params.ArbitrumDevTestChainConfig()
+	signer := types.LatestSigner(chainConfig)
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	sender := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	transfer, err := types.SignNewTx(privateKey, signer, &types.DynamicFeeTx{
+		ChainID:   chainConfig.ChainID,
+		Nonce:     0,
+		GasTipCap: big.NewInt(0),
+		GasFeeCap: big.NewInt(200),
+		Gas:       21000,
+		To:        &common.Address{1},
+		Value:     big.NewInt(1),
+	})
+	Require(t, err)
+	deployment, err := types.SignNewTx(privateKey, signer, &types.DynamicFeeTx{
+		ChainID:   chainConfig.ChainID,
+		Nonce:     1,
+		GasTipCap: big.NewInt(0),
+		GasFeeCap: big.NewInt(200),
+		Gas:       100000,
+		Data:      []byte{0},
+	})
+	Require(t, err)
+	txs := []*types.Transaction{transfer, deployment}
+
+	contract := crypto.CreateAddress(sender, 1)
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, GasUsedForL1: 1000, CumulativeGasUsed: 21000},
+		{Status: types.ReceiptStatusFailed, GasUsed: 50000, CumulativeGasUsed: 71000, ContractAddress: contract},
+	}
+	header := &types.Header{Number: big.NewInt(7), BaseFee: big.NewInt(100), Difficulty: big.NewInt(1)}
+	block := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+
+	marshalled, err := marshalBlockReceipts(chainConfig, block, receipts)
+	Require(t, err)
+	if marshalled.BlockHash != block.Hash() || uint64(marshalled.BlockNumber) != 7 || len(marshalled.Receipts) != 2 {
+		Fail(t, "unexpected block receipts", marshalled)
+	}
+	for i, receipt := range marshalled.Receipts {
+		if receipt["transactionHash"] != txs[i].Hash() || receipt["transactionIndex"] != hexutil.Uint64(i) {
+			Fail(t, "receipt", i, "for unexpected transaction", receipt)
+		}
+		if receipt["from"] != sender || receipt["blockHash"] != block.Hash() {
+			Fail(t, "receipt", i, "has unexpected sender or block", receipt)
+		}
+		if price := receipt["effectiveGasPrice"].(*hexutil.Big); price.ToInt().Cmp(big.NewInt(100)) != 0 {
+			Fail(t, "receipt", i, "has unexpected effective gas price", price)
+		}
+	}
+	if marshalled.Receipts[0]["contractAddress"] != nil || marshalled.Receipts[0]["gasUsedForL1"] != hexutil.Uint64(1000) {
+		Fail(t, "unexpected transfer receipt", marshalled.Receipts[0])
+	}
+	if marshalled.Receipts[1]["contractAddress"] != contract || marshalled.Receipts[1]["status"] != hexutil.Uint(types.ReceiptStatusFailed) {
+		Fail(t, "unexpected deployment receipt", marshalled.Receipts[1])
+	}
+
+	if _, err := marshalBlockReceipts(chainConfig, block, receipts[:1]); err == nil {
+		Fail(t, "marshalled block receipts with a receipt missing")
+	}
+}
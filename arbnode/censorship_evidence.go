@@ -0,0 +1,199 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+
+	"github.com/offchainlabs/nitro/arbos"
+)
+
+// At most this many of the batches posted since a delayed message are listed in its censorship evidence
+const maxCensorshipEvidenceBatches = 100
+
+type CensorshipEvidenceBatch struct {
+	SequenceNumber      hexutil.Uint64 `json:"sequenceNumber"`
+	L1Block             hexutil.Uint64 `json:"l1Block"`
+	DelayedMessagesRead hexutil.Uint64 `json:"delayedMessagesRead"`
+	Accumulator         common.Hash    `json:"accumulator"`
+}
+
+// CensorshipEvidence shows whether a delayed message has been left out of the sequencer's batches past the
+// force-inclusion window. Batches read delayed messages in order, and the number they've read never decreases,
+// so the latest batch not reading the message shows no batch has. Its accumulator and the delayed message's
+// are included as read from L1, so the evidence can be checked against the contracts without trusting this node.
+type CensorshipEvidence struct {
+	DelayedMessageIndex hexutil.Uint64           `json:"delayedMessageIndex"`
+	Message             *arbos.L1IncomingMessage `json:"message"`
+	BeforeInboxAcc      common.Hash              `json:"beforeInboxAccumulator"`
+	AfterInboxAcc       common.Hash              `json:"afterInboxAccumulator"`
+	BridgeAcc           common.Hash              `json:"bridgeAccumulator"` // the bridge's delayed accumulator for the message, read from L1
+	AccumulatorVerified bool                     `json:"accumulatorVerified"`
+
+	// The message can be force included once L1 is past both of these
+	L1Block                 hexutil.Uint64 `json:"l1Block"`
+	L1Timestamp             hexutil.Uint64 `json:"l1Timestamp"`
+	ForceInclusionBlock     hexutil.Uint64 `json:"forceInclusionBlock"`
+	ForceInclusionTimestamp hexutil.Uint64 `json:"forceInclusionTimestamp"`
+	CurrentL1Block          hexutil.Uint64 `json:"currentL1Block"`
+	CurrentL1Timestamp      hexutil.Uint64 `json:"currentL1Timestamp"`
+
+	// The batches posted since the message, the latest last, and their count, which may be larger
+	BatchesSince       []CensorshipEvidenceBatch `json:"batchesSince"`
+	BatchCountSince    hexutil.Uint64            `json:"batchCountSince"`
+	LatestBatchAccOnL1 *common.Hash              `json:"latestBatchAccumulatorOnL1,omitempty"`
+
+	IncludedInBatch *hexutil.Uint64 `json:"includedInBatch,omitempty"`
+	Censored        bool            `json:"censored"`
+	Summary         string          `json:"summary"`
+}
+
+// searchBatches returns the first batch below count for which the predicate holds, or count if there's none.
+// The predicate must hold for every batch after the first for which it does.
+func searchBatches(count uint64, get func(uint64) (BatchMetadata, error), predicate func(BatchMetadata) bool) (uint64, error) {
+	low, high := uint64(0), count
+	for low < high {
+		mid := low + (high-low)/2
+		metadata, err := get(mid)
+		if err != nil {
+			return 0, err
+		}
+		if predicate(metadata) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+// forceInclusionPossible mirrors the sequencer inbox's check that a delayed message is past the delay window.
+func forceInclusionPossible(evidence *CensorshipEvidence) bool {
+	return evidence.CurrentL1Block > evidence.ForceInclusionBlock && evidence.CurrentL1Timestamp > evidence.ForceInclusionTimestamp
+}
+
+// CensorshipEvidence assembles the evidence of whether the delayed message with the given index has been
+// excluded from batches past the force-inclusion window.
+func (r *InboxReader) CensorshipEvidence(ctx context.Context, index uint64) (*CensorshipEvidence, error) {
+	tracker := r.tracker
+	message, afterAcc, err := tracker.GetDelayedMessageAndAccumulator(index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "delayed message %v not read from L1", index)
+	}
+	var beforeAcc common.Hash
+	if index > 0 {
+		beforeAcc, err = tracker.GetDelayedAcc(index - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	recomputed := (&DelayedInboxMessage{BeforeInboxAcc: beforeAcc, Message: message}).AfterInboxAcc()
+	bridgeAcc, err := r.delayedBridge.GetAccumulator(ctx, index, nil)
+	if err != nil {
+		return nil, err
+	}
+	maxTimeVariation, err := r.sequencerInbox.con.MaxTimeVariation(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	header, err := r.l1Reader.LastHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	evidence := &CensorshipEvidence{
+		DelayedMessageIndex:     hexutil.Uint64(index),
+		Message:                 message,
+		BeforeInboxAcc:          beforeAcc,
+		AfterInboxAcc:           afterAcc,
+		BridgeAcc:               bridgeAcc,
+		AccumulatorVerified:     recomputed == afterAcc && afterAcc == bridgeAcc,
+		L1Block:                 hexutil.Uint64(message.Header.BlockNumber),
+		L1Timestamp:             hexutil.Uint64(message.Header.Timestamp),
+		ForceInclusionBlock:     hexutil.Uint64(message.Header.BlockNumber + maxTimeVariation.DelayBlocks.Uint64()),
+		ForceInclusionTimestamp: hexutil.Uint64(message.Header.Timestamp + maxTimeVariation.DelaySeconds.Uint64()),
+		CurrentL1Block:          hexutil.Uint64(header.Number.Uint64()),
+		CurrentL1Timestamp:      hexutil.Uint64(header.Time),
+		BatchesSince:            []CensorshipEvidenceBatch{},
+	}
+
+	batchCount, err := tracker.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	includedIn, err := searchBatches(batchCount, tracker.GetBatchMetadata, func(metadata BatchMetadata) bool {
+		return metadata.DelayedMessageCount > index
+	})
+	if err != nil {
+		return nil, err
+	}
+	firstSince, err := searchBatches(batchCount, tracker.GetBatchMetadata, func(metadata BatchMetadata) bool {
+		return metadata.L1Block >= message.Header.BlockNumber
+	})
+	if err != nil {
+		return nil, err
+	}
+	lastListed := batchCount
+	if includedIn < batchCount {
+		lastListed = includedIn + 1
+		batch := hexutil.Uint64(includedIn)
+		evidence.IncludedInBatch = &batch
+	}
+	if lastListed > firstSince {
+		evidence.BatchCountSince = hexutil.Uint64(lastListed - firstSince)
+	}
+	start := firstSince
+	if lastListed > start+maxCensorshipEvidenceBatches {
+		start = lastListed - maxCensorshipEvidenceBatches
+	}
+	for seqNum := start; seqNum < lastListed; seqNum++ {
+		metadata, err := tracker.GetBatchMetadata(seqNum)
+		if err != nil {
+			return nil, err
+		}
+		evidence.BatchesSince = append(evidence.BatchesSince, CensorshipEvidenceBatch{
+			SequenceNumber:      hexutil.Uint64(seqNum),
+			L1Block:             hexutil.Uint64(metadata.L1Block),
+			DelayedMessagesRead: hexutil.Uint64(metadata.DelayedMessageCount),
+			Accumulator:         metadata.Accumulator,
+		})
+	}
+	if evidence.IncludedInBatch == nil && batchCount > 0 {
+		acc, err := r.sequencerInbox.GetAccumulator(ctx, batchCount-1, nil)
+		if err != nil {
+			return nil, err
+		}
+		evidence.LatestBatchAccOnL1 = &acc
+	}
+
+	switch {
+	case evidence.IncludedInBatch != nil:
+		evidence.Summary = fmt.Sprintf("delayed message %v was included in batch %v", index, includedIn)
+	case !forceInclusionPossible(evidence):
+		evidence.Summary = fmt.Sprintf("delayed message %v is not yet included, but is within the force-inclusion window until L1 block %v and timestamp %v", index, uint64(evidence.ForceInclusionBlock), uint64(evidence.ForceInclusionTimestamp))
+	default:
+		evidence.Censored = true
+		evidence.Summary = fmt.Sprintf("delayed message %v was excluded from all %v batches since L1 block %v, and is past the force-inclusion window since L1 block %v and timestamp %v", index, uint64(evidence.BatchCountSince), message.Header.BlockNumber, uint64(evidence.ForceInclusionBlock), uint64(evidence.ForceInclusionTimestamp))
+	}
+	if !evidence.AccumulatorVerified {
+		evidence.Summary += "; warning: the delayed accumulator doesn't match L1"
+	}
+	return evidence, nil
+}
+
+type DelayedInboxAPI struct {
+	inboxReader *InboxReader
+}
+
+// DelayedMessageCensorshipEvidence returns the evidence of whether a delayed message has been excluded
+// from batches past the force-inclusion window, suitable for monitoring or governance processes.
+func (a *DelayedInboxAPI) DelayedMessageCensorshipEvidence(ctx context.Context, index hexutil.Uint64) (*CensorshipEvidence, error) {
+	return a.inboxReader.CensorshipEvidence(ctx, uint64(index))
+}
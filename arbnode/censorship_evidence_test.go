@@ -0,0 +1,62 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestSearchBatches(t *testing.T) {
+	batches := []BatchMetadata{
+		{DelayedMessageCount: 0, L1Block: 10},
+		{DelayedMessageCount: 2, L1Block: 12},
+		{DelayedMessageCount: 2, L1Block: 15},
+		{DelayedMessageCount: 5, L1Block: 15},
+		{DelayedMessageCount: 6, L1Block: 20},
+	}
+	get := func(seqNum uint64) (BatchMetadata, error) {
+		return batches[seqNum], nil
+	}
+	count := uint64(len(batches))
+	includes := func(index uint64) uint64 {
+		batch, err := searchBatches(count, get, func(metadata BatchMetadata) bool {
+			return metadata.DelayedMessageCount > index
+		})
+		Require(t, err)
+		return batch
+	}
+	for index, expected := range []uint64{1, 1, 3, 3, 3, 4, 5} {
+		if batch := includes(uint64(index)); batch != expected {
+			Fail(t, "delayed message", index, "included in batch", batch, "expected", expected)
+		}
+	}
+	since, err := searchBatches(count, get, func(metadata BatchMetadata) bool {
+		return metadata.L1Block >= 13
+	})
+	Require(t, err)
+	if since != 2 {
+		Fail(t, "first batch since L1 block 13 is", since)
+	}
+	none, err := searchBatches(0, get, func(BatchMetadata) bool { return true })
+	Require(t, err)
+	if none != 0 {
+		Fail(t, "found batch in empty inbox", none)
+	}
+}
+
+func TestForceInclusionPossible(t *testing.T) {
+	evidence := &CensorshipEvidence{ForceInclusionBlock: 100, ForceInclusionTimestamp: 1000}
+	for _, current := range [][2]uint64{{100, 2000}, {200, 1000}, {50, 500}} {
+		evidence.CurrentL1Block, evidence.CurrentL1Timestamp = hexutil.Uint64(current[0]), hexutil.Uint64(current[1])
+		if forceInclusionPossible(evidence) {
+			Fail(t, "force inclusion possible at", current)
+		}
+	}
+	evidence.CurrentL1Block, evidence.CurrentL1Timestamp = 101, 1001
+	if !forceInclusionPossible(evidence) {
+		Fail(t, "force inclusion not possible past the window")
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var conditionalRejectedCounter = metrics.NewRegisteredCounter("arb/sequencer/conditional/rejected", nil)
+
+// The most accounts and storage slots a transaction's conditions may check, as every check costs the sequencer
+const maxConditionalCost = 1000
+
+// ErrConditionFailed is the error of a conditional transaction whose conditions don't hold.
+var ErrConditionFailed = errors.New("transaction conditions not met")
+
+// RootHashOrSlots is the expected state of a known account: either its storage root,
+// or the values of some of its storage slots.
+type RootHashOrSlots struct {
+	RootHash  *common.Hash
+	SlotValue map[common.Hash]common.Hash
+}
+
+func (r *RootHashOrSlots) UnmarshalJSON(data []byte) error {
+	var hash common.Hash
+	if err := json.Unmarshal(data, &hash); err == nil {
+		r.RootHash = &hash
+		return nil
+	}
+	return json.Unmarshal(data, &r.SlotValue)
+}
+
+func (r RootHashOrSlots) MarshalJSON() ([]byte, error) {
+	if r.RootHash != nil {
+		return json.Marshal(*r.RootHash)
+	}
+	return json.Marshal(r.SlotValue)
+}
+
+// ConditionalOptions are the conditions of eth_sendRawTransactionConditional, which must all hold when the
+// transaction is sequenced or it's dropped. The block number and timestamp bounds are of the L2 block it's
+// sequenced into.
+type ConditionalOptions struct {
+	KnownAccounts  map[common.Address]RootHashOrSlots `json:"knownAccounts"`
+	BlockNumberMin *hexutil.Uint64                    `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Uint64                    `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64                    `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64                    `json:"timestampMax,omitempty"`
+}
+
+func (o *ConditionalOptions) cost() int {
+	cost := 0
+	for _, account := range o.KnownAccounts {
+		if account.RootHash != nil {
+			cost++
+		} else {
+			cost += len(account.SlotValue)
+		}
+	}
+	return cost
+}
+
+// checkBlock verifies the conditions on the block a transaction is sequenced into.
+func (o *ConditionalOptions) checkBlock(number uint64, timestamp uint64) error {
+	if o.BlockNumberMin != nil && number < uint64(*o.BlockNumberMin) {
+		return fmt.Errorf("%w: block number %v below minimum %v", ErrConditionFailed, number, *o.BlockNumberMin)
+	}
+	if o.BlockNumberMax != nil && number > uint64(*o.BlockNumberMax) {
+		return fmt.Errorf("%w: block number %v above maximum %v", ErrConditionFailed, number, *o.BlockNumberMax)
+	}
+	if o.TimestampMin != nil && timestamp < uint64(*o.TimestampMin) {
+		return fmt.Errorf("%w: timestamp %v below minimum %v", ErrConditionFailed, timestamp, *o.TimestampMin)
+	}
+	if o.TimestampMax != nil && timestamp > uint64(*o.TimestampMax) {
+		return fmt.Errorf("%w: timestamp %v above maximum %v", ErrConditionFailed, timestamp, *o.TimestampMax)
+	}
+	return nil
+}
+
+// checkState verifies the known accounts against the state a transaction would execute on.
+func (o *ConditionalOptions) checkState(statedb *state.StateDB) error {
+	for address, expected := range o.KnownAccounts {
+		if expected.RootHash != nil {
+			root := trie.EmptyRoot
+			if storage := statedb.StorageTrie(address); storage != nil {
+				root = storage.Hash()
+			}
+			if root != *expected.RootHash {
+				return fmt.Errorf("%w: storage root of %v is %v, not %v", ErrConditionFailed, address, root, *expected.RootHash)
+			}
+			continue
+		}
+		for slot, value := range expected.SlotValue {
+			if actual := statedb.GetState(address, slot); actual != value {
+				return fmt.Errorf("%w: storage slot %v of %v is %v, not %v", ErrConditionFailed, slot, address, actual, value)
+			}
+		}
+	}
+	return nil
+}
+
+// check verifies all the conditions against a block being built and its current state.
+func (o *ConditionalOptions) check(header *types.Header, statedb *state.StateDB) error {
+	if o == nil {
+		return nil
+	}
+	err := o.checkBlock(header.Number.Uint64(), header.Time)
+	if err == nil {
+		err = o.checkState(statedb)
+	}
+	if err != nil {
+		conditionalRejectedCounter.Inc(1)
+	}
+	return err
+}
+
+// ArbTransactionAPI adds conditional transactions to the eth namespace.
+type ArbTransactionAPI struct {
+	publisher TransactionPublisher
+}
+
+func (a *ArbTransactionAPI) SendRawTransactionConditional(ctx context.Context, input hexutil.Bytes, options ConditionalOptions) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if options.cost() > maxConditionalCost {
+		return common.Hash{}, fmt.Errorf("transaction conditions check %v accounts and slots, more than the maximum of %v", options.cost(), maxConditionalCost)
+	}
+	return tx.Hash(), a.publisher.PublishTransaction(ctx, tx, &options)
+}
@@ -0,0 +1,52 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestConditionalOptions(t *testing.T) {
+	input := `{
+		"knownAccounts": {
+			"0x0000000000000000000000000000000000001111": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+			"0x0000000000000000000000000000000000002222": {"0x01": "0x0000000000000000000000000000000000000000000000000000000000000002"}
+		},
+		"blockNumberMin": "0x10",
+		"timestampMax": "0x1000"
+	}`
+	var options ConditionalOptions
+	Require(t, json.Unmarshal([]byte(input), &options))
+	if options.cost() != 2 {
+		Fail(t, "unexpected cost", options.cost())
+	}
+	root := options.KnownAccounts[common.HexToAddress("0x1111")]
+	if root.RootHash == nil || root.SlotValue != nil {
+		Fail(t, "storage root not parsed", root)
+	}
+	slots := options.KnownAccounts[common.HexToAddress("0x2222")]
+	if slots.RootHash != nil || slots.SlotValue[common.HexToHash("0x01")] != common.HexToHash("0x02") {
+		Fail(t, "storage slots not parsed", slots)
+	}
+
+	Require(t, options.checkBlock(0x10, 0x1000))
+	if err := options.checkBlock(0xf, 0x1000); !errors.Is(err, ErrConditionFailed) {
+		Fail(t, "block number below minimum accepted", err)
+	}
+	if err := options.checkBlock(0x10, 0x1001); !errors.Is(err, ErrConditionFailed) {
+		Fail(t, "timestamp above maximum accepted", err)
+	}
+
+	encoded, err := json.Marshal(&options)
+	Require(t, err)
+	var decoded ConditionalOptions
+	Require(t, json.Unmarshal(encoded, &decoded))
+	if decoded.cost() != 2 || *decoded.BlockNumberMin != 0x10 || decoded.BlockNumberMax != nil {
+		Fail(t, "options changed when forwarded", string(encoded))
+	}
+}
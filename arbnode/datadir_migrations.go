@@ -0,0 +1,258 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rlp"
+	flag "github.com/spf13/pflag"
+)
+
+type MigrationsConfig struct {
+	Backup     bool `koanf:"backup"`
+	KeepBackup bool `koanf:"keep-backup"`
+}
+
+func MigrationsConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".backup", DefaultMigrationsConfig.Backup, "back up the arbitrum database before migrating it to a newer layout, and restore the backup if migrating fails")
+	f.Bool(prefix+".keep-backup", DefaultMigrationsConfig.KeepBackup, "keep the backup of the arbitrum database once it's been migrated")
+}
+
+var DefaultMigrationsConfig = MigrationsConfig{
+	Backup:     true,
+	KeepBackup: false,
+}
+
+const (
+	arbitrumDataName    = "arbitrumdata"
+	dataDirManifestName = "nitro-manifest.json"
+)
+
+// A dataDirMigration upgrades the arbitrum database's layout by one version.
+// Migrations must be idempotent, as a node stopped while migrating reruns the interrupted migration on startup.
+type dataDirMigration struct {
+	description string
+	migrate     func(ctx context.Context, db ethdb.Database) error
+}
+
+// dataDirMigrations upgrades the layout from version i to i+1 with its i'th migration.
+// New migrations must only ever be appended.
+var dataDirMigrations = []dataDirMigration{
+	{"index batches by L1 block and message count", migrateBlockIndex},
+	{"remove messages left past the message count by interrupted reorgs", migrateTrailingMessages},
+}
+
+func latestDataDirVersion() uint64 {
+	return uint64(len(dataDirMigrations))
+}
+
+type AppliedMigration struct {
+	Version     uint64    `json:"version"`
+	Description string    `json:"description"`
+	AppliedAt   time.Time `json:"appliedAt"`
+}
+
+// DataDirManifest records the layout version of a node's data directory, and how it got there.
+type DataDirManifest struct {
+	Version    uint64             `json:"version"`
+	Migrations []AppliedMigration `json:"migrations,omitempty"`
+}
+
+func readDataDirManifest(path string) (*DataDirManifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest DataDirManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse data directory manifest %v: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// writeDataDirManifest replaces the manifest atomically, so it's never left half written.
+func writeDataDirManifest(path string, manifest *DataDirManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// MigrateDataDir brings the arbitrum database up to the latest layout before it's opened, backing it up first
+// and restoring the backup if a migration fails. A new data directory is marked as being at the latest layout,
+// and one written by a newer version of the node is refused.
+func MigrateDataDir(ctx context.Context, stack *node.Node, config *MigrationsConfig) error {
+	manifestPath := stack.ResolvePath(dataDirManifestName)
+	dbPath := stack.ResolvePath(arbitrumDataName)
+	manifest, err := readDataDirManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	latest := latestDataDirVersion()
+	if manifest == nil {
+		if _, err := os.Stat(filepath.Join(dbPath, "CURRENT")); errors.Is(err, os.ErrNotExist) {
+			return writeDataDirManifest(manifestPath, &DataDirManifest{Version: latest})
+		}
+		// The database predates the manifest
+		manifest = &DataDirManifest{}
+	}
+	if manifest.Version > latest {
+		return fmt.Errorf("data directory layout version %v is newer than the latest supported version %v", manifest.Version, latest)
+	}
+	if manifest.Version == latest {
+		return nil
+	}
+
+	backupPath := fmt.Sprintf("%v.backup-v%v", dbPath, manifest.Version)
+	if config.Backup {
+		log.Info("backing up arbitrum database before migrating it", "backup", backupPath)
+		if err := os.RemoveAll(backupPath); err != nil {
+			return err
+		}
+		if err := copyDir(dbPath, backupPath); err != nil {
+			return fmt.Errorf("failed to back up arbitrum database: %w", err)
+		}
+	}
+
+	db, err := stack.OpenDatabase(arbitrumDataName, 0, 0, "", false)
+	if err != nil {
+		return err
+	}
+	migrateErr := applyDataDirMigrations(ctx, db, manifest, manifestPath)
+	if err := db.Close(); err != nil && migrateErr == nil {
+		migrateErr = err
+	}
+	if migrateErr != nil {
+		if !config.Backup {
+			return fmt.Errorf("failed to migrate arbitrum database: %w", migrateErr)
+		}
+		if err := restoreBackup(backupPath, dbPath, manifestPath, manifest.Version); err != nil {
+			return fmt.Errorf("failed to migrate arbitrum database (%v), then failed to restore its backup from %v: %w", migrateErr, backupPath, err)
+		}
+		return fmt.Errorf("failed to migrate arbitrum database, restored it from backup: %w", migrateErr)
+	}
+	if config.Backup && !config.KeepBackup {
+		return os.RemoveAll(backupPath)
+	}
+	return nil
+}
+
+func applyDataDirMigrations(ctx context.Context, db ethdb.Database, manifest *DataDirManifest, manifestPath string) error {
+	for manifest.Version < latestDataDirVersion() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		migration := dataDirMigrations[manifest.Version]
+		log.Info("migrating arbitrum database", "from", manifest.Version, "to", manifest.Version+1, "migration", migration.description)
+		if err := migration.migrate(ctx, db); err != nil {
+			return fmt.Errorf("migration to version %v (%v): %w", manifest.Version+1, migration.description, err)
+		}
+		manifest.Version++
+		manifest.Migrations = append(manifest.Migrations, AppliedMigration{
+			Version:     manifest.Version,
+			Description: migration.description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		if err := writeDataDirManifest(manifestPath, manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restoreBackup(backupPath, dbPath, manifestPath string, version uint64) error {
+	if err := os.RemoveAll(dbPath); err != nil {
+		return err
+	}
+	if err := os.Rename(backupPath, dbPath); err != nil {
+		return err
+	}
+	manifest, err := readDataDirManifest(manifestPath)
+	if err != nil || manifest == nil {
+		return err
+	}
+	for len(manifest.Migrations) > 0 && manifest.Migrations[len(manifest.Migrations)-1].Version > version {
+		manifest.Migrations = manifest.Migrations[:len(manifest.Migrations)-1]
+	}
+	manifest.Version = version
+	return writeDataDirManifest(manifestPath, manifest)
+}
+
+func copyDir(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+		// The lock file belongs to whoever has the database open
+		if info.Name() == "LOCK" {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+}
+
+func migrateBlockIndex(ctx context.Context, db ethdb.Database) error {
+	tracker := &InboxTracker{db: db}
+	return tracker.backfillBlockIndex()
+}
+
+func migrateTrailingMessages(ctx context.Context, db ethdb.Database) error {
+	hasCount, err := db.Has(messageCountKey)
+	if err != nil || !hasCount {
+		return err
+	}
+	data, err := db.Get(messageCountKey)
+	if err != nil {
+		return err
+	}
+	var count uint64
+	if err := rlp.DecodeBytes(data, &count); err != nil {
+		return err
+	}
+	batch := db.NewBatch()
+	if err := deleteStartingAt(db, batch, messagePrefix, uint64ToKey(count)); err != nil {
+		return err
+	}
+	return batch.Write()
+}
@@ -0,0 +1,84 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestDataDirMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := rawdb.NewMemoryDatabase()
+	countData, err := rlp.EncodeToBytes(uint64(2))
+	Require(t, err)
+	Require(t, db.Put(messageCountKey, countData))
+	for i := uint64(0); i < 4; i++ {
+		Require(t, db.Put(dbKey(messagePrefix, i), []byte{byte(i)}))
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), dataDirManifestName)
+	manifest := &DataDirManifest{}
+	Require(t, applyDataDirMigrations(ctx, db, manifest, manifestPath))
+	if manifest.Version != latestDataDirVersion() || len(manifest.Migrations) != len(dataDirMigrations) {
+		Fail(t, "unexpected manifest after migrating", manifest)
+	}
+	written, err := readDataDirManifest(manifestPath)
+	Require(t, err)
+	if written == nil || written.Version != manifest.Version {
+		Fail(t, "unexpected manifest written", written)
+	}
+
+	for i := uint64(0); i < 4; i++ {
+		has, err := db.Has(dbKey(messagePrefix, i))
+		Require(t, err)
+		if has != (i < 2) {
+			Fail(t, "message", i, "present", has, "after migrating")
+		}
+	}
+
+	// migrating again is a no-op
+	Require(t, applyDataDirMigrations(ctx, db, manifest, manifestPath))
+	if len(manifest.Migrations) != len(dataDirMigrations) {
+		Fail(t, "migrations reapplied", manifest)
+	}
+}
+
+func TestDataDirBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, arbitrumDataName)
+	backupPath := dbPath + ".backup-v0"
+	manifestPath := filepath.Join(dir, dataDirManifestName)
+	Require(t, os.MkdirAll(dbPath, 0700))
+	Require(t, os.WriteFile(filepath.Join(dbPath, "CURRENT"), []byte("original"), 0600))
+	Require(t, os.WriteFile(filepath.Join(dbPath, "LOCK"), nil, 0600))
+
+	Require(t, copyDir(dbPath, backupPath))
+	if _, err := os.Stat(filepath.Join(backupPath, "LOCK")); !errors.Is(err, os.ErrNotExist) {
+		Fail(t, "backed up the database lock file")
+	}
+	Require(t, os.WriteFile(filepath.Join(dbPath, "CURRENT"), []byte("migrated"), 0600))
+	Require(t, writeDataDirManifest(manifestPath, &DataDirManifest{
+		Version:    1,
+		Migrations: []AppliedMigration{{Version: 1}},
+	}))
+
+	Require(t, restoreBackup(backupPath, dbPath, manifestPath, 0))
+	data, err := os.ReadFile(filepath.Join(dbPath, "CURRENT"))
+	Require(t, err)
+	if string(data) != "original" {
+		Fail(t, "database not restored from backup", string(data))
+	}
+	manifest, err := readDataDirManifest(manifestPath)
+	Require(t, err)
+	if manifest.Version != 0 || len(manifest.Migrations) != 0 {
+		Fail(t, "manifest not rolled back", manifest)
+	}
+}
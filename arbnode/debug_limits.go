@@ -0,0 +1,88 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+)
+
+type DebugLimitsConfig struct {
+	Timeout       time.Duration `koanf:"timeout"`
+	MaxResultSize uint64        `koanf:"max-result-size"`
+}
+
+func DebugLimitsConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".timeout", DefaultDebugLimitsConfig.Timeout, "time budget for a single arbdebug or debug_trace request, after which a truncated result is returned (0 = unlimited)")
+	f.Uint64(prefix+".max-result-size", DefaultDebugLimitsConfig.MaxResultSize, "approximate memory budget in bytes for a single arbdebug or debug_trace result, after which it is truncated (0 = unlimited)")
+}
+
+var DefaultDebugLimitsConfig = DebugLimitsConfig{
+	Timeout:       10 * time.Second,
+	MaxResultSize: 64 * 1024 * 1024,
+}
+
+const (
+	truncatedByTimeout = "timeout"
+	truncatedBySize    = "max-result-size"
+)
+
+var debugTruncatedCounter = metrics.NewRegisteredCounter("arb/debug/truncated", nil)
+
+// logTruncated records that a debug result was cut short, so operators can tell when the limits bite.
+func logTruncated(method string, requested interface{}, served interface{}, reason string) {
+	debugTruncatedCounter.Inc(1)
+	log.Warn("Truncating debug result", "method", method, "requested", requested, "served", served, "reason", reason)
+}
+
+// requestBudget tracks the time and memory spent serving a single debug request, so that
+// long loops can stop early and return what they have so far instead of failing.
+type requestBudget struct {
+	ctx       context.Context
+	remaining uint64
+	unlimited bool
+	reason    string
+}
+
+func newRequestBudget(ctx context.Context, config *DebugLimitsConfig) (*requestBudget, context.CancelFunc) {
+	cancel := func() {}
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+	}
+	return &requestBudget{
+		ctx:       ctx,
+		remaining: config.MaxResultSize,
+		unlimited: config.MaxResultSize == 0,
+	}, cancel
+}
+
+// spend accounts for the given number of result bytes, returning false if the request
+// is out of budget and the result should be truncated at this point.
+func (b *requestBudget) spend(size uint64) bool {
+	if b.reason != "" {
+		return false
+	}
+	if b.ctx.Err() != nil {
+		b.reason = truncatedByTimeout
+		return false
+	}
+	if b.unlimited {
+		return true
+	}
+	if size > b.remaining {
+		b.reason = truncatedBySize
+		return false
+	}
+	b.remaining -= size
+	return true
+}
+
+// truncated returns why the result was truncated, or the empty string if it wasn't.
+func (b *requestBudget) truncated() string {
+	return b.reason
+}
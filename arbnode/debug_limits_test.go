@@ -0,0 +1,86 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestBudget(t *testing.T) {
+	budget, cancel := newRequestBudget(context.Background(), &DebugLimitsConfig{MaxResultSize: 100})
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		if !budget.spend(10) {
+			Fail(t, "budget exhausted early at", i)
+		}
+	}
+	if budget.spend(1) {
+		Fail(t, "budget not exhausted")
+	}
+	if budget.truncated() != truncatedBySize {
+		Fail(t, "unexpected truncation reason", budget.truncated())
+	}
+
+	budget, cancel = newRequestBudget(context.Background(), &DebugLimitsConfig{Timeout: time.Millisecond})
+	defer cancel()
+	if !budget.spend(1 << 40) {
+		Fail(t, "unlimited size budget exhausted")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if budget.spend(1) {
+		Fail(t, "time budget not exhausted")
+	}
+	if budget.truncated() != truncatedByTimeout {
+		Fail(t, "unexpected truncation reason", budget.truncated())
+	}
+}
+
+func TestLimitTraceTimeout(t *testing.T) {
+	str := func(s string) *string { return &s }
+	for _, test := range []struct {
+		requested *string
+		budget    time.Duration
+		expected  *string
+	}{
+		{nil, 0, nil},
+		{str("1m"), 0, str("1m")},
+		{nil, 10 * time.Second, nil},
+		{nil, time.Second, str("1s")},
+		{str("2s"), 10 * time.Second, str("2s")},
+		{str("1m"), 10 * time.Second, str("10s")},
+	} {
+		timeout, err := limitTimeout(test.requested, test.budget)
+		Require(t, err)
+		if (timeout == nil) != (test.expected == nil) || (timeout != nil && *timeout != *test.expected) {
+			Fail(t, "unexpected timeout for", test.requested, test.budget, timeout)
+		}
+	}
+	if _, err := limitTimeout(str("soon"), time.Second); err == nil {
+		Fail(t, "invalid timeout accepted")
+	}
+}
+
+func TestLimitBlockTraces(t *testing.T) {
+	traces := []string{"aaaa", "bbbb", "cccc", "dddd"}
+	api := &DebugTraceAPI{limits: &DebugLimitsConfig{MaxResultSize: 13}}
+	err := api.limitBlockTraces("debug_traceBlockByNumber", len(traces), func(i int) interface{} {
+		return traces[i]
+	}, func(i int) {
+		traces[i] = "truncated"
+	})
+	Require(t, err)
+	// Each trace marshals to six bytes, so two fit in the budget
+	if traces[1] != "bbbb" || traces[2] != "truncated" || traces[3] != "truncated" {
+		Fail(t, "unexpected traces", traces)
+	}
+
+	if err := api.checkSize("debug_traceTransaction", "aaaa"); err != nil {
+		Fail(t, "trace within the budget failed", err)
+	}
+	if err := api.checkSize("debug_traceTransaction", "aaaaaaaaaaaaaaaa"); err == nil {
+		Fail(t, "trace over the budget succeeded")
+	}
+}
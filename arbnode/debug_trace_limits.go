@@ -0,0 +1,157 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// go-ethereum traces each transaction for up to this long unless the request asks otherwise
+const defaultTraceTimeout = 5 * time.Second
+
+// DebugTraceAPI serves go-ethereum's debug_trace methods within the debug limits, so a single massive trace
+// can't destabilize a node shared with normal traffic. It's registered after the backend's debug API, so its
+// methods take the place of the unlimited ones. debug_traceCall takes arguments of a type internal to
+// go-ethereum, so it can't be wrapped and keeps go-ethereum's own timeout.
+type DebugTraceAPI struct {
+	tracer *tracers.API
+	limits *DebugLimitsConfig
+}
+
+func NewDebugTraceAPI(backend tracers.Backend, limits *DebugLimitsConfig) *DebugTraceAPI {
+	return &DebugTraceAPI{
+		tracer: tracers.NewAPI(backend),
+		limits: limits,
+	}
+}
+
+// limitTimeout returns the timeout to trace with given the one requested, lowering it to the time budget.
+func limitTimeout(requested *string, budget time.Duration) (*string, error) {
+	if budget == 0 {
+		return requested, nil
+	}
+	if requested == nil {
+		if defaultTraceTimeout <= budget {
+			return nil, nil
+		}
+	} else {
+		timeout, err := time.ParseDuration(*requested)
+		if err != nil {
+			return nil, err
+		}
+		if timeout <= budget {
+			return requested, nil
+		}
+	}
+	limited := budget.String()
+	return &limited, nil
+}
+
+func (api *DebugTraceAPI) limitConfig(config *tracers.TraceConfig) (*tracers.TraceConfig, error) {
+	var limited tracers.TraceConfig
+	if config != nil {
+		limited = *config
+	}
+	var err error
+	limited.Timeout, err = limitTimeout(limited.Timeout, api.limits.Timeout)
+	return &limited, err
+}
+
+// checkSize fails a single trace whose result is over the memory budget, as it can't be cut short meaningfully.
+func (api *DebugTraceAPI) checkSize(method string, result interface{}) error {
+	if api.limits.MaxResultSize == 0 {
+		return nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if uint64(len(data)) > api.limits.MaxResultSize {
+		logTruncated(method, len(data), 0, truncatedBySize)
+		return fmt.Errorf("trace result of %v bytes is over the %v byte debug result budget", len(data), api.limits.MaxResultSize)
+	}
+	return nil
+}
+
+// limitBlockTraces replaces the traces of a block's transactions past the memory budget by a marker, so the
+// result still has one entry per transaction.
+func (api *DebugTraceAPI) limitBlockTraces(method string, count int, trace func(int) interface{}, truncate func(int)) error {
+	if api.limits.MaxResultSize == 0 {
+		return nil
+	}
+	budget, cancel := newRequestBudget(context.Background(), &DebugLimitsConfig{MaxResultSize: api.limits.MaxResultSize})
+	defer cancel()
+	for i := 0; i < count; i++ {
+		data, err := json.Marshal(trace(i))
+		if err != nil {
+			return err
+		}
+		if !budget.spend(uint64(len(data))) {
+			logTruncated(method, count, i, budget.truncated())
+			for ; i < count; i++ {
+				truncate(i)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func (api *DebugTraceAPI) TraceTransaction(ctx context.Context, hash common.Hash, config *tracers.TraceConfig) (interface{}, error) {
+	limited, err := api.limitConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.tracer.TraceTransaction(ctx, hash, limited)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.checkSize("debug_traceTransaction", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (api *DebugTraceAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *tracers.TraceConfig) (interface{}, error) {
+	limited, err := api.limitConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	results, err := api.tracer.TraceBlockByNumber(ctx, number, limited)
+	if err != nil {
+		return nil, err
+	}
+	err = api.limitBlockTraces("debug_traceBlockByNumber", len(results), func(i int) interface{} {
+		return results[i]
+	}, func(i int) {
+		results[i].Result = nil
+		results[i].Error = "truncated: " + truncatedBySize
+	})
+	return results, err
+}
+
+func (api *DebugTraceAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *tracers.TraceConfig) (interface{}, error) {
+	limited, err := api.limitConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	results, err := api.tracer.TraceBlockByHash(ctx, hash, limited)
+	if err != nil {
+		return nil, err
+	}
+	err = api.limitBlockTraces("debug_traceBlockByHash", len(results), func(i int) interface{} {
+		return results[i]
+	}, func(i int) {
+		results[i].Result = nil
+		results[i].Error = "truncated: " + truncatedBySize
+	})
+	return results, err
+}
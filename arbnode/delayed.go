@@ -135,7 +135,7 @@ func (b *DelayedBridge) LookupMessagesInRange(ctx context.Context, from, to *big
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return b.logsToDeliveredMessages(ctx, logs)
+	return b.logsToDeliveredMessages(ctx, dedupeLogs(logs))
 }
 
 type sortableMessageList []*DelayedInboxMessage
@@ -117,6 +117,11 @@ func TestSequencerReorgFromDelayed(t *testing.T) {
 	if batchCount != 1 {
 		Fail(t, "Unexpected tracker batch count", batchCount, "(expected 1)")
 	}
+	indexedCount, err := tracker.blockIndexBatchCount()
+	Require(t, err)
+	if indexedCount != 1 {
+		Fail(t, "Unexpected block index batch count", indexedCount, "(expected 1)")
+	}
 
 	emptyBatch = &SequencerInboxBatch{
 		BlockHash:         [32]byte{},
@@ -146,4 +151,15 @@ func TestSequencerReorgFromDelayed(t *testing.T) {
 	if batchCount != 2 {
 		Fail(t, "Unexpected tracker batch count", batchCount, "(expected 2)")
 	}
+	// The block index follows the delayed reorg, so the new batch is indexed in place of the reorged ones
+	seqNum, found, err := tracker.FindBatchContainingMessage(1)
+	Require(t, err)
+	if !found || seqNum != 1 {
+		Fail(t, "Unexpected batch containing message 1", seqNum, found, "(expected 1)")
+	}
+	indexedCount, err = tracker.blockIndexBatchCount()
+	Require(t, err)
+	if indexedCount != 2 {
+		Fail(t, "Unexpected block index batch count", indexedCount, "(expected 2)")
+	}
 }
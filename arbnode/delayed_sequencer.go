@@ -6,15 +6,21 @@ package arbnode
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"math/big"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
 	flag "github.com/spf13/pflag"
 
 	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/util/arbmath"
 	"github.com/offchainlabs/nitro/util/headerreader"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
@@ -29,43 +35,150 @@ type DelayedSequencer struct {
 	coordinator     *SeqCoordinator
 	waitingForBlock *big.Int
 	config          *DelayedSequencerConfig
+	allowedKinds    map[uint8]bool // nil if all kinds are allowed
+	run             delayedRun
+	heldBack        bool // whether MaxMessagesPerL2Block held back delayed messages in the last update
+}
+
+// How often to check whether the sequencer has created a block, while delayed messages are held back for it
+const delayedRunRecheckInterval = 100 * time.Millisecond
+
+// delayedRun is the delayed messages sequenced since the sequencer last created a block, which
+// MaxMessagesPerL2Block limits.
+type delayedRun struct {
+	messages uint64
+	msgCount arbutil.MessageIndex // the streamer's message count after the run's last delayed message
+	l1Block  uint64               // the L1 block the run's last delayed messages were sequenced at
+}
+
+// allowance returns how many more delayed messages may be sequenced before the sequencer's next block, given the
+// streamer's message count and the current L1 block. A sequencer which creates no blocks in a whole L1 block is
+// idle, with no transactions for delayed messages to hold up, so the run is over then too.
+func (r *delayedRun) allowance(limit uint64, msgCount arbutil.MessageIndex, l1Block uint64) uint64 {
+	if limit == 0 {
+		return math.MaxUint64
+	}
+	if msgCount > r.msgCount || l1Block > r.l1Block {
+		r.messages = 0
+	}
+	if r.messages >= limit {
+		return 0
+	}
+	return limit - r.messages
+}
+
+func (r *delayedRun) record(messages uint64, msgCount arbutil.MessageIndex, l1Block uint64) {
+	r.messages += messages
+	r.msgCount = msgCount
+	r.l1Block = l1Block
 }
 
 type DelayedSequencerConfig struct {
-	Enable           bool          `koanf:"enable"`
-	FinalizeDistance int64         `koanf:"finalize-distance"`
-	TimeAggregate    time.Duration `koanf:"time-aggregate"`
+	Enable                bool          `koanf:"enable"`
+	FinalizeDistance      int64         `koanf:"finalize-distance"`
+	UseL1Finality         bool          `koanf:"use-l1-finality"`
+	MaxMessagesPerL2Block uint64        `koanf:"max-messages-per-l2-block"`
+	AllowedKinds          []string      `koanf:"allowed-kinds"`
+	TimeAggregate         time.Duration `koanf:"time-aggregate"`
 }
 
 func DelayedSequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultSeqCoordinatorConfig.Enable, "enable sequence coordinator")
 	f.Int64(prefix+".finalize-distance", DefaultDelayedSequencerConfig.FinalizeDistance, "how many blocks in the past L1 block is considered final")
+	f.Bool(prefix+".use-l1-finality", DefaultDelayedSequencerConfig.UseL1Finality, "only sequence delayed messages once L1 reports their block as finalized, instead of after finalize-distance confirmations")
+	f.Uint64(prefix+".max-messages-per-l2-block", DefaultDelayedSequencerConfig.MaxMessagesPerL2Block, "maximum number of delayed messages sequenced between consecutive blocks the sequencer creates, leaving the rest until it creates another or is idle for an L1 block (0 = unlimited)")
+	f.StringSlice(prefix+".allowed-kinds", DefaultDelayedSequencerConfig.AllowedKinds, "kinds of delayed messages to sequence automatically, if set; sequencing pauses at any other kind until it's force included on L1 (options: "+strings.Join(delayedMessageKindNames(), ", ")+")")
 	f.Duration(prefix+".time-aggregate", DefaultDelayedSequencerConfig.TimeAggregate, "polling interval for the delayed sequencer")
 }
 
 var DefaultDelayedSequencerConfig = DelayedSequencerConfig{
-	Enable:           false,
-	FinalizeDistance: 12,
-	TimeAggregate:    time.Minute,
+	Enable:                false,
+	FinalizeDistance:      12,
+	UseL1Finality:         false,
+	MaxMessagesPerL2Block: 0,
+	AllowedKinds:          []string{},
+	TimeAggregate:         time.Minute,
 }
 
 var TestDelayedSequencerConfig = DelayedSequencerConfig{
-	Enable:           true,
-	FinalizeDistance: 12,
-	TimeAggregate:    time.Second,
+	Enable:                true,
+	FinalizeDistance:      12,
+	UseL1Finality:         false,
+	MaxMessagesPerL2Block: 0,
+	AllowedKinds:          []string{},
+	TimeAggregate:         time.Second,
+}
+
+var delayedMessageKinds = map[string]uint8{
+	"l2-message":           arbos.L1MessageType_L2Message,
+	"end-of-block":         arbos.L1MessageType_EndOfBlock,
+	"l2-funded-by-l1":      arbos.L1MessageType_L2FundedByL1,
+	"rollup-event":         arbos.L1MessageType_RollupEvent,
+	"submit-retryable":     arbos.L1MessageType_SubmitRetryable,
+	"initialize":           arbos.L1MessageType_Initialize,
+	"eth-deposit":          arbos.L1MessageType_EthDeposit,
+	"batch-posting-report": arbos.L1MessageType_BatchPostingReport,
+}
+
+func delayedMessageKindNames() []string {
+	names := make([]string, 0, len(delayedMessageKinds))
+	for name := range delayedMessageKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseDelayedMessageKinds(names []string) (map[uint8]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	kinds := make(map[uint8]bool)
+	for _, name := range names {
+		kind, ok := delayedMessageKinds[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown delayed message kind %v", name)
+		}
+		kinds[kind] = true
+	}
+	return kinds, nil
 }
 
 func NewDelayedSequencer(l1Reader *headerreader.HeaderReader, reader *InboxReader, txStreamer *TransactionStreamer, coordinator *SeqCoordinator, config *DelayedSequencerConfig) (*DelayedSequencer, error) {
+	allowedKinds, err := parseDelayedMessageKinds(config.AllowedKinds)
+	if err != nil {
+		return nil, err
+	}
 	return &DelayedSequencer{
-		l1Reader:    l1Reader,
-		bridge:      reader.DelayedBridge(),
-		inbox:       reader.Tracker(),
-		coordinator: coordinator,
-		txStreamer:  txStreamer,
-		config:      config,
+		l1Reader:     l1Reader,
+		bridge:       reader.DelayedBridge(),
+		inbox:        reader.Tracker(),
+		coordinator:  coordinator,
+		txStreamer:   txStreamer,
+		config:       config,
+		allowedKinds: allowedKinds,
 	}, nil
 }
 
+// finalizedBlock returns the latest L1 block whose delayed messages may be sequenced.
+func (d *DelayedSequencer) finalizedBlock(ctx context.Context, lastBlockHeader *types.Header) (*big.Int, error) {
+	if d.config.UseL1Finality {
+		header, err := d.l1Reader.Client().HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get finalized L1 block: %w", err)
+		}
+		return header.Number, nil
+	}
+	// Unless we find an unfinalized message (which sets waitingForBlock),
+	// we won't find a new finalized message until FinalizeDistance blocks in the future.
+	d.waitingForBlock = new(big.Int).Add(lastBlockHeader.Number, big.NewInt(d.config.FinalizeDistance))
+	finalized := new(big.Int).Sub(lastBlockHeader.Number, big.NewInt(d.config.FinalizeDistance))
+	if finalized.Sign() < 0 {
+		finalized.SetInt64(0)
+	}
+	return finalized, nil
+}
+
 func (d *DelayedSequencer) getDelayedMessagesRead() (uint64, error) {
 	pos, err := d.txStreamer.GetMessageCount()
 	if err != nil || pos == 0 {
@@ -86,12 +199,11 @@ func (d *DelayedSequencer) update(ctx context.Context, lastBlockHeader *types.He
 		return nil
 	}
 
-	// Unless we find an unfinalized message (which sets waitingForBlock),
-	// we won't find a new finalized message until FinalizeDistance blocks in the future.
-	d.waitingForBlock = new(big.Int).Add(lastBlockHeader.Number, big.NewInt(d.config.FinalizeDistance))
-	finalized := new(big.Int).Sub(lastBlockHeader.Number, big.NewInt(d.config.FinalizeDistance))
-	if finalized.Sign() < 0 {
-		finalized.SetInt64(0)
+	d.waitingForBlock = nil
+	d.heldBack = false
+	finalized, err := d.finalizedBlock(ctx, lastBlockHeader)
+	if err != nil {
+		return err
 	}
 
 	dbDelayedCount, err := d.inbox.GetDelayedCount()
@@ -102,12 +214,23 @@ func (d *DelayedSequencer) update(ctx context.Context, lastBlockHeader *types.He
 	if err != nil {
 		return err
 	}
+	msgCount, err := d.txStreamer.GetMessageCount()
+	if err != nil {
+		return err
+	}
+	allowance := d.run.allowance(d.config.MaxMessagesPerL2Block, msgCount, lastBlockHeader.Number.Uint64())
 
 	// Retrieve all finalized delayed messages
 	pos := startPos
 	var lastDelayedAcc common.Hash
 	var messages []*arbos.L1IncomingMessage
 	for pos < dbDelayedCount {
+		if uint64(len(messages)) >= allowance {
+			// Leave the rest until the sequencer has created a block
+			d.waitingForBlock = nil
+			d.heldBack = true
+			break
+		}
 		msg, acc, err := d.inbox.GetDelayedMessageAndAccumulator(pos)
 		if err != nil {
 			return err
@@ -115,7 +238,14 @@ func (d *DelayedSequencer) update(ctx context.Context, lastBlockHeader *types.He
 		blockNumber := arbmath.UintToBig(msg.Header.BlockNumber)
 		if blockNumber.Cmp(finalized) > 0 {
 			// Message isn't finalized yet; stop here
-			d.waitingForBlock = new(big.Int).Add(blockNumber, big.NewInt(d.config.FinalizeDistance))
+			if !d.config.UseL1Finality {
+				d.waitingForBlock = new(big.Int).Add(blockNumber, big.NewInt(d.config.FinalizeDistance))
+			}
+			break
+		}
+		if d.allowedKinds != nil && !d.allowedKinds[msg.Header.Kind] {
+			// Delayed messages must be sequenced in order, so nothing after it can be either
+			log.Warn("DelayedSequencer: pausing at delayed message of a kind not allowed, until it's force included", "pos", pos, "kind", msg.Header.Kind)
 			break
 		}
 		if lastDelayedAcc != (common.Hash{}) {
@@ -148,6 +278,7 @@ func (d *DelayedSequencer) update(ctx context.Context, lastBlockHeader *types.He
 		if err != nil {
 			return err
 		}
+		d.run.record(uint64(len(messages)), msgCount+arbutil.MessageIndex(len(messages)), lastBlockHeader.Number.Uint64())
 		log.Info("DelayedSequencer: Sequenced", "msgnum", len(messages), "startpos", startPos)
 	}
 
@@ -158,16 +289,26 @@ func (d *DelayedSequencer) run(ctx context.Context) {
 	headerChan, cancel := d.l1Reader.Subscribe(false)
 	defer cancel()
 
+	var lastHeader *types.Header
 	for {
+		var recheck <-chan time.Time
+		if d.heldBack && lastHeader != nil {
+			recheck = time.After(delayedRunRecheckInterval)
+		}
 		select {
 		case nextHeader, ok := <-headerChan:
 			if !ok {
 				log.Info("delayed sequencer: header channel close")
 				return
 			}
+			lastHeader = nextHeader
 			if err := d.update(ctx, nextHeader); err != nil {
 				log.Error("Delayed sequencer error", "err", err)
 			}
+		case <-recheck:
+			if err := d.update(ctx, lastHeader); err != nil {
+				log.Error("Delayed sequencer error", "err", err)
+			}
 		case <-ctx.Done():
 			log.Info("delayed sequencer: context done", "err", ctx.Err())
 			return
@@ -0,0 +1,60 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/offchainlabs/nitro/arbos"
+)
+
+func TestDelayedRunAllowance(t *testing.T) {
+	var run delayedRun
+	if allowance := run.allowance(0, 10, 100); allowance != math.MaxUint64 {
+		Fail(t, "no limit should allow any number of messages", allowance)
+	}
+
+	if allowance := run.allowance(3, 10, 100); allowance != 3 {
+		Fail(t, "fresh run should allow the limit", allowance)
+	}
+	run.record(2, 12, 100)
+	if allowance := run.allowance(3, 12, 100); allowance != 1 {
+		Fail(t, "expected the rest of the limit", allowance)
+	}
+	run.record(1, 13, 100)
+	if allowance := run.allowance(3, 13, 100); allowance != 0 {
+		Fail(t, "limit reached without the sequencer creating a block", allowance)
+	}
+
+	// the sequencer creating a block ends the run, within the same L1 block
+	if allowance := run.allowance(3, 14, 100); allowance != 3 {
+		Fail(t, "sequencer block didn't end the run", allowance)
+	}
+	run.record(3, 17, 100)
+	if allowance := run.allowance(3, 17, 100); allowance != 0 {
+		Fail(t, "limit not applied to the next run", allowance)
+	}
+
+	// an idle sequencer doesn't hold delayed messages back past the L1 block
+	if allowance := run.allowance(3, 17, 101); allowance != 3 {
+		Fail(t, "idle sequencer held back delayed messages", allowance)
+	}
+}
+
+func TestParseDelayedMessageKinds(t *testing.T) {
+	kinds, err := parseDelayedMessageKinds(nil)
+	Require(t, err)
+	if kinds != nil {
+		Fail(t, "no kinds should allow all of them", kinds)
+	}
+	kinds, err = parseDelayedMessageKinds([]string{"eth-deposit", "submit-retryable"})
+	Require(t, err)
+	if len(kinds) != 2 || !kinds[arbos.L1MessageType_EthDeposit] || !kinds[arbos.L1MessageType_SubmitRetryable] || kinds[arbos.L1MessageType_L2Message] {
+		Fail(t, "wrong kinds allowed", kinds)
+	}
+	if _, err := parseDelayedMessageKinds([]string{"deposit"}); err == nil {
+		Fail(t, "unknown kind accepted")
+	}
+}
@@ -0,0 +1,485 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/arbitrum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/nitro/arbos/l2pricing"
+)
+
+// The account ArbOS keeps its state in, see storage.NewGeth
+var arbosStateAccount = common.HexToAddress("0xA4B05FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF")
+
+// At most this many serialization points and contracts are listed per block or range
+const maxConflictHotspots = 20
+
+// approximate size of a transaction's conflict statistics, used to enforce the request memory budget
+const conflictBytesPerTx = 32 + 8*6
+
+type stateKeyKind uint8
+
+const (
+	stateKeyStorage stateKeyKind = iota
+	stateKeyBalance
+	stateKeyAccount // nonce and code
+)
+
+type stateKey struct {
+	address common.Address
+	kind    stateKeyKind
+	slot    common.Hash
+}
+
+func isArbOSAccount(address common.Address) bool {
+	if address == arbosStateAccount || address == types.ArbosAddress {
+		return true
+	}
+	// The ArbOS precompiles are at 0x64 and up
+	for _, b := range address[:common.AddressLength-1] {
+		if b != 0 {
+			return false
+		}
+	}
+	return address[common.AddressLength-1] >= 0x64
+}
+
+// txAccesses is the state a transaction read and wrote, as seen by an accessTracer.
+type txAccesses struct {
+	reads  map[stateKey]struct{}
+	writes map[stateKey]struct{}
+}
+
+func newTxAccesses() txAccesses {
+	return txAccesses{
+		reads:  make(map[stateKey]struct{}),
+		writes: make(map[stateKey]struct{}),
+	}
+}
+
+// accessTracer records the state a transaction accesses. Gas payments aren't traced, so the sender's
+// balance is counted as read, and transfers only as balance writes, which don't conflict with each other
+// as concurrent execution can apply balance deltas in any order.
+type accessTracer struct {
+	txAccesses
+}
+
+func (t *accessTracer) read(key stateKey) {
+	t.reads[key] = struct{}{}
+}
+
+func (t *accessTracer) write(key stateKey) {
+	t.writes[key] = struct{}{}
+}
+
+func (t *accessTracer) transfer(from, to common.Address, value *big.Int) {
+	if value == nil || value.Sign() == 0 {
+		return
+	}
+	t.write(stateKey{address: from, kind: stateKeyBalance})
+	t.write(stateKey{address: to, kind: stateKeyBalance})
+}
+
+func (t *accessTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.read(stateKey{address: from, kind: stateKeyBalance})
+	t.read(stateKey{address: from, kind: stateKeyAccount})
+	t.write(stateKey{address: from, kind: stateKeyAccount})
+	t.read(stateKey{address: to, kind: stateKeyAccount})
+	if create {
+		t.write(stateKey{address: to, kind: stateKeyAccount})
+	}
+	t.transfer(from, to, value)
+}
+
+func (t *accessTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	stack := scope.Stack.Data()
+	contract := scope.Contract.Address()
+	switch op {
+	case vm.SLOAD:
+		if len(stack) >= 1 {
+			t.read(stateKey{address: contract, slot: common.Hash(stack[len(stack)-1].Bytes32())})
+		}
+	case vm.SSTORE:
+		if len(stack) >= 1 {
+			// SSTORE's gas cost depends on the slot's current value, so it reads it too
+			slot := stateKey{address: contract, slot: common.Hash(stack[len(stack)-1].Bytes32())}
+			t.read(slot)
+			t.write(slot)
+		}
+	case vm.BALANCE:
+		if len(stack) >= 1 {
+			t.read(stateKey{address: common.Address(stack[len(stack)-1].Bytes20()), kind: stateKeyBalance})
+		}
+	case vm.SELFBALANCE:
+		t.read(stateKey{address: contract, kind: stateKeyBalance})
+	case vm.EXTCODESIZE, vm.EXTCODECOPY, vm.EXTCODEHASH:
+		if len(stack) >= 1 {
+			t.read(stateKey{address: common.Address(stack[len(stack)-1].Bytes20()), kind: stateKeyAccount})
+		}
+	case vm.SELFDESTRUCT:
+		t.write(stateKey{address: contract, kind: stateKeyAccount})
+		t.write(stateKey{address: contract, kind: stateKeyBalance})
+	}
+}
+
+func (t *accessTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.read(stateKey{address: to, kind: stateKeyAccount})
+	if typ == vm.CREATE || typ == vm.CREATE2 {
+		t.write(stateKey{address: from, kind: stateKeyAccount})
+		t.write(stateKey{address: to, kind: stateKeyAccount})
+	}
+	t.transfer(from, to, value)
+}
+
+func (t *accessTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *accessTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *accessTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+
+func (t *accessTracer) CaptureArbitrumTransfer(env *vm.EVM, from, to *common.Address, value *big.Int, before bool, purpose string) {
+	if from != nil && to != nil {
+		t.transfer(*from, *to, value)
+	} else if from != nil && value != nil && value.Sign() != 0 {
+		t.write(stateKey{address: *from, kind: stateKeyBalance})
+	} else if to != nil && value != nil && value.Sign() != 0 {
+		t.write(stateKey{address: *to, kind: stateKeyBalance})
+	}
+}
+
+func (t *accessTracer) CaptureArbitrumStorageGet(key common.Hash, depth int, before bool) {
+	t.read(stateKey{address: arbosStateAccount, slot: key})
+}
+
+func (t *accessTracer) CaptureArbitrumStorageSet(key, value common.Hash, depth int, before bool) {
+	slot := stateKey{address: arbosStateAccount, slot: key}
+	t.read(slot)
+	t.write(slot)
+}
+
+type TxConflicts struct {
+	Index     int         `json:"index"`
+	Hash      common.Hash `json:"hash"`
+	Reads     int         `json:"reads"`
+	Writes    int         `json:"writes"`
+	DependsOn []int       `json:"dependsOn,omitempty"` // earlier transactions which wrote state this one read
+	// Whether optimistic concurrent execution would abort and re-execute the transaction, as it read state
+	// an earlier transaction in the block wrote
+	Aborted bool `json:"aborted"`
+	Depth   int  `json:"depth"` // the length of the longest chain of dependencies ending with this transaction
+}
+
+// SerializationPoint is a piece of state which made transactions depend on each other.
+type SerializationPoint struct {
+	Address   common.Address `json:"address"`
+	Kind      string         `json:"kind"`
+	Slot      *common.Hash   `json:"slot,omitempty"`
+	Conflicts uint64         `json:"conflicts"`
+}
+
+type ContractConflicts struct {
+	Address      common.Address `json:"address"`
+	Conflicts    uint64         `json:"conflicts"`
+	Transactions uint64         `json:"transactions"` // transactions which accessed its state
+}
+
+type BlockConflictStats struct {
+	BlockNumber  hexutil.Uint64 `json:"blockNumber"`
+	BlockHash    common.Hash    `json:"blockHash"`
+	Transactions int            `json:"transactions"`
+	Aborts       int            `json:"aborts"`
+	// The longest chain of dependent transactions, which concurrent execution must run one after another
+	CriticalPath int `json:"criticalPath"`
+	// Transactions divided by the critical path, an upper bound on the speedup from concurrent execution
+	MaxParallelism      float64              `json:"maxParallelism"`
+	SerializationPoints []SerializationPoint `json:"serializationPoints"`
+	Contracts           []ContractConflicts  `json:"contracts"`
+	Txs                 []TxConflicts        `json:"txs,omitempty"`
+}
+
+type conflictCounts struct {
+	points    map[stateKey]uint64
+	contracts map[common.Address]*ContractConflicts
+}
+
+func newConflictCounts() *conflictCounts {
+	return &conflictCounts{
+		points:    make(map[stateKey]uint64),
+		contracts: make(map[common.Address]*ContractConflicts),
+	}
+}
+
+func (c *conflictCounts) contract(address common.Address) *ContractConflicts {
+	contract, ok := c.contracts[address]
+	if !ok {
+		contract = &ContractConflicts{Address: address}
+		c.contracts[address] = contract
+	}
+	return contract
+}
+
+func (c *conflictCounts) hotspots() ([]SerializationPoint, []ContractConflicts) {
+	points := make([]SerializationPoint, 0, len(c.points))
+	for key, conflicts := range c.points {
+		point := SerializationPoint{Address: key.address, Conflicts: conflicts}
+		switch key.kind {
+		case stateKeyStorage:
+			slot := key.slot
+			point.Kind = "storage"
+			point.Slot = &slot
+		case stateKeyBalance:
+			point.Kind = "balance"
+		case stateKeyAccount:
+			point.Kind = "account"
+		}
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Conflicts != points[j].Conflicts {
+			return points[i].Conflicts > points[j].Conflicts
+		}
+		return bytes.Compare(points[i].Address[:], points[j].Address[:]) < 0
+	})
+	if len(points) > maxConflictHotspots {
+		points = points[:maxConflictHotspots]
+	}
+	contracts := make([]ContractConflicts, 0, len(c.contracts))
+	for _, contract := range c.contracts {
+		if contract.Conflicts > 0 {
+			contracts = append(contracts, *contract)
+		}
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		if contracts[i].Conflicts != contracts[j].Conflicts {
+			return contracts[i].Conflicts > contracts[j].Conflicts
+		}
+		return bytes.Compare(contracts[i].Address[:], contracts[j].Address[:]) < 0
+	})
+	if len(contracts) > maxConflictHotspots {
+		contracts = contracts[:maxConflictHotspots]
+	}
+	return points, contracts
+}
+
+// analyzeConflicts finds which transactions read state an earlier transaction wrote. Only such
+// read-after-write dependencies serialize optimistic concurrent execution, as writes to the same state
+// are ordered by their transactions' positions in the block.
+func analyzeConflicts(accesses []txAccesses, counts *conflictCounts) []TxConflicts {
+	lastWriter := make(map[stateKey]int)
+	txs := make([]TxConflicts, len(accesses))
+	for i, access := range accesses {
+		tx := &txs[i]
+		tx.Index = i
+		tx.Reads = len(access.reads)
+		tx.Writes = len(access.writes)
+		tx.Depth = 1
+		dependsOn := make(map[int]struct{})
+		touched := make(map[common.Address]struct{})
+		for key := range access.reads {
+			touched[key.address] = struct{}{}
+			writer, ok := lastWriter[key]
+			if !ok {
+				continue
+			}
+			counts.points[key]++
+			counts.contract(key.address).Conflicts++
+			dependsOn[writer] = struct{}{}
+		}
+		for key := range access.writes {
+			touched[key.address] = struct{}{}
+		}
+		for address := range touched {
+			counts.contract(address).Transactions++
+		}
+		for writer := range dependsOn {
+			tx.DependsOn = append(tx.DependsOn, writer)
+			if txs[writer].Depth+1 > tx.Depth {
+				tx.Depth = txs[writer].Depth + 1
+			}
+		}
+		sort.Ints(tx.DependsOn)
+		tx.Aborted = len(tx.DependsOn) > 0
+		for key := range access.writes {
+			lastWriter[key] = i
+		}
+	}
+	return txs
+}
+
+func summarizeConflicts(stats *BlockConflictStats, txs []TxConflicts) {
+	stats.Transactions = len(txs)
+	for _, tx := range txs {
+		if tx.Aborted {
+			stats.Aborts++
+		}
+		if tx.Depth > stats.CriticalPath {
+			stats.CriticalPath = tx.Depth
+		}
+	}
+	if stats.CriticalPath > 0 {
+		stats.MaxParallelism = float64(stats.Transactions) / float64(stats.CriticalPath)
+	}
+}
+
+// traceBlockAccesses re-executes a block's transactions on its parent's state, recording the state each accessed.
+// Unless includeArbOS is set, the block's first transaction, which ArbOS always runs before the others to start
+// the block, and accesses to ArbOS state, such as its fee accounting, are left out.
+func traceBlockAccesses(blockchain *core.BlockChain, block *types.Block, includeArbOS bool) ([]txAccesses, types.Transactions, error) {
+	if !blockchain.Config().IsArbitrumNitro(block.Number()) {
+		return nil, nil, types.ErrUseFallback
+	}
+	parent := blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, nil, fmt.Errorf("parent of block %v not found", block.NumberU64())
+	}
+	statedb, err := blockchain.StateAt(parent.Root)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := block.Header()
+	var gasUsed uint64
+	gasPool := core.GasPool(l2pricing.GethBlockGasLimit)
+	accesses := make([]txAccesses, 0, len(block.Transactions()))
+	txs := make(types.Transactions, 0, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		tracer := &accessTracer{newTxAccesses()}
+		statedb.Prepare(tx.Hash(), i)
+		_, _, err := core.ApplyTransaction(blockchain.Config(), blockchain, &header.Coinbase, &gasPool, statedb, header, tx, &gasUsed, vm.Config{Debug: true, Tracer: tracer})
+		if err != nil {
+			return nil, nil, fmt.Errorf("re-executing transaction %v of block %v: %w", i, block.NumberU64(), err)
+		}
+		if i == 0 && !includeArbOS {
+			continue
+		}
+		if !includeArbOS {
+			for key := range tracer.reads {
+				if isArbOSAccount(key.address) {
+					delete(tracer.reads, key)
+				}
+			}
+			for key := range tracer.writes {
+				if isArbOSAccount(key.address) {
+					delete(tracer.writes, key)
+				}
+			}
+		}
+		accesses = append(accesses, tracer.txAccesses)
+		txs = append(txs, tx)
+	}
+	return accesses, txs, nil
+}
+
+// BlockExecutionConflicts re-executes a block to report which of its transactions would conflict under
+// optimistic concurrent execution, and which contracts and storage slots caused the conflicts. Accesses to
+// ArbOS state are left out unless includeArbOS is set, as ArbOS's accounting serializes every transaction.
+func (api *ArbDebugAPI) BlockExecutionConflicts(ctx context.Context, blockNum rpc.BlockNumberOrHash, includeArbOS *bool) (*BlockConflictStats, error) {
+	header, err := arbitrum.HeaderByNumberOrHash(api.blockchain, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	block := api.blockchain.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", header.Number)
+	}
+	accesses, txs, err := traceBlockAccesses(api.blockchain, block, includeArbOS != nil && *includeArbOS)
+	if err != nil {
+		return nil, err
+	}
+	counts := newConflictCounts()
+	txConflicts := analyzeConflicts(accesses, counts)
+	for i := range txConflicts {
+		txConflicts[i].Hash = txs[i].Hash()
+	}
+	stats := &BlockConflictStats{
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		BlockHash:   block.Hash(),
+		Txs:         txConflicts,
+	}
+	summarizeConflicts(stats, txConflicts)
+	stats.SerializationPoints, stats.Contracts = counts.hotspots()
+	return stats, nil
+}
+
+type ExecutionConflictsHistory struct {
+	First               uint64               `json:"first"`
+	Blocks              []BlockConflictStats `json:"blocks"` // without per-transaction statistics
+	SerializationPoints []SerializationPoint `json:"serializationPoints"`
+	Contracts           []ContractConflicts  `json:"contracts"`
+
+	// Set if the request ran out of budget, in which case only the first blocks are included
+	Truncated string `json:"truncated,omitempty"`
+}
+
+// ExecutionConflicts reports per-block conflict statistics over a range of blocks, and the contracts
+// and storage slots which caused the most conflicts across the range.
+func (api *ArbDebugAPI) ExecutionConflicts(ctx context.Context, start, end rpc.BlockNumber, includeArbOS *bool) (*ExecutionConflictsHistory, error) {
+	start, _ = api.blockchain.ClipToPostNitroGenesis(start)
+	end, _ = api.blockchain.ClipToPostNitroGenesis(end)
+
+	blocks := end.Int64() - start.Int64() + 1
+	if blocks > int64(api.blockRangeBound) {
+		log.Warn("Sanitizing execution conflicts # of blocks", "requested", blocks, "truncated", api.blockRangeBound)
+		blocks = int64(api.blockRangeBound)
+	}
+	if blocks <= 0 {
+		return nil, fmt.Errorf("invalid block range: %v to %v", start.Int64(), end.Int64())
+	}
+
+	budget, cancel := newRequestBudget(ctx, api.limits)
+	defer cancel()
+
+	history := &ExecutionConflictsHistory{
+		First:  uint64(start),
+		Blocks: []BlockConflictStats{},
+	}
+	counts := newConflictCounts()
+	for i := uint64(0); i < uint64(blocks); i++ {
+		block := api.blockchain.GetBlockByNumber(uint64(start) + i)
+		if block == nil {
+			return nil, fmt.Errorf("block %v not found", uint64(start)+i)
+		}
+		if !budget.spend(conflictBytesPerTx * uint64(len(block.Transactions()))) {
+			history.Truncated = budget.truncated()
+			log.Warn("Truncating execution conflicts", "requested", blocks, "served", i, "reason", budget.truncated())
+			break
+		}
+		accesses, _, err := traceBlockAccesses(api.blockchain, block, includeArbOS != nil && *includeArbOS)
+		if err != nil {
+			return nil, err
+		}
+		stats := BlockConflictStats{
+			BlockNumber: hexutil.Uint64(block.NumberU64()),
+			BlockHash:   block.Hash(),
+		}
+		blockCounts := newConflictCounts()
+		summarizeConflicts(&stats, analyzeConflicts(accesses, blockCounts))
+		stats.SerializationPoints, stats.Contracts = blockCounts.hotspots()
+		for key, conflicts := range blockCounts.points {
+			counts.points[key] += conflicts
+		}
+		for address, contract := range blockCounts.contracts {
+			total := counts.contract(address)
+			total.Conflicts += contract.Conflicts
+			total.Transactions += contract.Transactions
+		}
+		history.Blocks = append(history.Blocks, stats)
+	}
+	history.SerializationPoints, history.Contracts = counts.hotspots()
+	return history, nil
+}
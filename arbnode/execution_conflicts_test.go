@@ -0,0 +1,67 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAnalyzeConflicts(t *testing.T) {
+	token := common.HexToAddress("0x1234")
+	other := common.HexToAddress("0x5678")
+	slot := func(address common.Address, n int64) stateKey {
+		return stateKey{address: address, slot: common.BigToHash(big.NewInt(n))}
+	}
+	access := func(reads []stateKey, writes []stateKey) txAccesses {
+		access := newTxAccesses()
+		for _, key := range reads {
+			access.reads[key] = struct{}{}
+		}
+		for _, key := range writes {
+			access.writes[key] = struct{}{}
+		}
+		return access
+	}
+
+	accesses := []txAccesses{
+		access([]stateKey{slot(token, 1)}, []stateKey{slot(token, 1)}),
+		// Writing the same slot without reading it doesn't conflict
+		access(nil, []stateKey{slot(token, 1)}),
+		access([]stateKey{slot(other, 1)}, nil),
+		// Reads the last write, which depends on nothing
+		access([]stateKey{slot(token, 1)}, []stateKey{slot(token, 2)}),
+		access([]stateKey{slot(token, 2), slot(other, 1)}, nil),
+	}
+	counts := newConflictCounts()
+	txs := analyzeConflicts(accesses, counts)
+
+	expectedDepends := [][]int{nil, nil, nil, {1}, {3}}
+	expectedDepth := []int{1, 1, 1, 2, 3}
+	for i, tx := range txs {
+		if len(tx.DependsOn) != len(expectedDepends[i]) || tx.Depth != expectedDepth[i] {
+			Fail(t, "transaction", i, "depends on", tx.DependsOn, "with depth", tx.Depth, "expected", expectedDepends[i], expectedDepth[i])
+		}
+		for j, dependency := range tx.DependsOn {
+			if dependency != expectedDepends[i][j] {
+				Fail(t, "transaction", i, "depends on", tx.DependsOn, "expected", expectedDepends[i])
+			}
+		}
+	}
+
+	stats := &BlockConflictStats{}
+	summarizeConflicts(stats, txs)
+	if stats.Aborts != 2 || stats.CriticalPath != 3 {
+		Fail(t, "unexpected block statistics", stats.Aborts, stats.CriticalPath)
+	}
+	points, contracts := counts.hotspots()
+	if len(points) != 2 || len(contracts) != 1 {
+		Fail(t, "unexpected hotspots", points, contracts)
+	}
+	if contracts[0].Address != token || contracts[0].Conflicts != 2 || contracts[0].Transactions != 4 {
+		Fail(t, "unexpected contract conflicts", contracts[0])
+	}
+}
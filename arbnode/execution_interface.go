@@ -0,0 +1,342 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+// ExecutionInterfaceVersion is bumped on any incompatible change to the execution RPC interface. A consensus
+// node refuses to use an execution node speaking another version.
+const ExecutionInterfaceVersion uint64 = 1
+
+type ExecutionConfig struct {
+	URL            string        `koanf:"url"`
+	Serve          bool          `koanf:"serve"`
+	RequestTimeout time.Duration `koanf:"request-timeout"`
+}
+
+var DefaultExecutionConfig = ExecutionConfig{
+	URL:            "",
+	Serve:          false,
+	RequestTimeout: time.Minute,
+}
+
+func ExecutionConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".url", DefaultExecutionConfig.URL, "RPC or IPC URL of a separate execution node to execute messages on, rather than executing them in this process (empty to execute locally)")
+	f.Bool(prefix+".serve", DefaultExecutionConfig.Serve, "serve the execution interface in the execution RPC namespace, so a consensus node can execute its messages on this node (the namespace must also be enabled on the http, ws or ipc server)")
+	f.Duration(prefix+".request-timeout", DefaultExecutionConfig.RequestTimeout, "timeout of each request to the execution node")
+}
+
+// ExecutionResult is the state after executing a message.
+type ExecutionResult struct {
+	BlockHash common.Hash `json:"blockHash"`
+	SendRoot  common.Hash `json:"sendRoot"`
+}
+
+// ExecutionClient is what the consensus components need from the execution engine, letting the transaction
+// streamer execute messages in this process or on a separate execution node. Messages are numbered as they are
+// in the streamer; the execution engine keeps no messages of its own, so a restarted one is brought back up to
+// date by the consensus node digesting the messages it's missing.
+type ExecutionClient interface {
+	// DigestMessage executes the message, which must follow the last one executed, on top of the head. Digesting
+	// an already executed message returns its result. batches holds the data of the batches the message refers to.
+	DigestMessage(ctx context.Context, num arbutil.MessageIndex, msg *arbstate.MessageWithMetadata, batches map[uint64][]byte) (*ExecutionResult, error)
+	// Reorg drops the results of messages from count on, making the result of message count-1 the head.
+	Reorg(ctx context.Context, count arbutil.MessageIndex) error
+	// HeadMessageCount returns the number of messages executed.
+	HeadMessageCount(ctx context.Context) (arbutil.MessageIndex, error)
+	ResultAtMessage(ctx context.Context, num arbutil.MessageIndex) (*ExecutionResult, error)
+}
+
+// referencedBatches returns the data of the batches the message refers to, which only batch posting reports do,
+// for executing it where the batches can't be fetched.
+func referencedBatches(chainId *big.Int, msg *arbstate.MessageWithMetadata, fetch func(uint64) ([]byte, error)) (map[uint64][]byte, error) {
+	if msg.Message.Header.Kind != arbos.L1MessageType_BatchPostingReport {
+		return nil, nil
+	}
+	batches := make(map[uint64][]byte)
+	var fetchErr error
+	_, _ = msg.Message.ParseL2Transactions(chainId, func(batchNum uint64) []byte {
+		data, err := fetch(batchNum)
+		if err != nil {
+			fetchErr = err
+			return nil
+		}
+		batches[batchNum] = data
+		return data
+	})
+	return batches, fetchErr
+}
+
+func executionResultFromHeader(header *types.Header) (*ExecutionResult, error) {
+	extra, err := types.DeserializeHeaderExtraInformation(header)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecutionResult{BlockHash: header.Hash(), SendRoot: extra.SendRoot}, nil
+}
+
+// BlockchainExecution executes messages on the local blockchain. It's what an execution node serves.
+type BlockchainExecution struct {
+	bc    *core.BlockChain
+	mutex sync.Mutex
+}
+
+func NewBlockchainExecution(bc *core.BlockChain) *BlockchainExecution {
+	return &BlockchainExecution{bc: bc}
+}
+
+func (e *BlockchainExecution) genesisBlockNum() uint64 {
+	return e.bc.Config().ArbitrumChainParams.GenesisBlockNum
+}
+
+func (e *BlockchainExecution) headMessageCount() arbutil.MessageIndex {
+	return arbutil.BlockNumberToMessageCount(e.bc.CurrentHeader().Number.Uint64(), e.genesisBlockNum())
+}
+
+func (e *BlockchainExecution) resultAtMessage(num arbutil.MessageIndex) (*ExecutionResult, error) {
+	blockNum := arbutil.MessageCountToBlockNumber(num+1, e.genesisBlockNum())
+	if blockNum < 0 {
+		return nil, fmt.Errorf("message %v is before genesis", num)
+	}
+	header := e.bc.GetHeaderByNumber(uint64(blockNum))
+	if header == nil {
+		return nil, fmt.Errorf("message %v not executed", num)
+	}
+	return executionResultFromHeader(header)
+}
+
+func (e *BlockchainExecution) DigestMessage(ctx context.Context, num arbutil.MessageIndex, msg *arbstate.MessageWithMetadata, batches map[uint64][]byte) (*ExecutionResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	head := e.headMessageCount()
+	if num < head {
+		return e.resultAtMessage(num)
+	}
+	if num > head {
+		return nil, fmt.Errorf("can't digest message %v before message %v", num, head)
+	}
+	lastBlockHeader := e.bc.CurrentHeader()
+	statedb, err := e.bc.StateAt(lastBlockHeader.Root)
+	if err != nil {
+		return nil, err
+	}
+	block, receipts, err := arbos.ProduceBlock(
+		msg.Message,
+		msg.DelayedMessagesRead,
+		lastBlockHeader,
+		statedb,
+		e.bc,
+		e.bc.Config(),
+		func(batchNum uint64) ([]byte, error) {
+			data, ok := batches[batchNum]
+			if !ok {
+				return nil, fmt.Errorf("message %v refers to batch %v, which wasn't sent with it", num, batchNum)
+			}
+			return data, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		logs = append(logs, receipt.Logs...)
+	}
+	status, err := e.bc.WriteBlockAndSetHead(block, receipts, logs, statedb, true)
+	if err != nil {
+		return nil, err
+	}
+	if status == core.SideStatTy {
+		return nil, errors.New("geth rejected block as non-canonical")
+	}
+	return executionResultFromHeader(block.Header())
+}
+
+func (e *BlockchainExecution) Reorg(ctx context.Context, count arbutil.MessageIndex) error {
+	if count == 0 {
+		return errors.New("cannot reorg out init message")
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	blockNum := arbutil.MessageCountToBlockNumber(count, e.genesisBlockNum())
+	block := e.bc.GetBlockByNumber(uint64(blockNum))
+	if block == nil {
+		return fmt.Errorf("reorg target block %v not found", blockNum)
+	}
+	return e.bc.ReorgToOldBlock(block)
+}
+
+func (e *BlockchainExecution) HeadMessageCount(ctx context.Context) (arbutil.MessageIndex, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.headMessageCount(), nil
+}
+
+func (e *BlockchainExecution) ResultAtMessage(ctx context.Context, num arbutil.MessageIndex) (*ExecutionResult, error) {
+	return e.resultAtMessage(num)
+}
+
+// executionBlockSource reads the blocks of an execution client for the staker, numbering them as the blockchain
+// would.
+type executionBlockSource struct {
+	exec            ExecutionClient
+	genesisBlockNum uint64
+}
+
+func newExecutionBlockSource(exec ExecutionClient, genesisBlockNum uint64) *executionBlockSource {
+	return &executionBlockSource{exec: exec, genesisBlockNum: genesisBlockNum}
+}
+
+func (s *executionBlockSource) HeadBlockNumber(ctx context.Context) (uint64, error) {
+	count, err := s.exec.HeadMessageCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	blockNum := arbutil.MessageCountToBlockNumber(count, s.genesisBlockNum)
+	if blockNum < 0 {
+		return 0, nil
+	}
+	return uint64(blockNum), nil
+}
+
+func (s *executionBlockSource) BlockByNumber(ctx context.Context, number uint64) (*validator.L2BlockInfo, error) {
+	if number < s.genesisBlockNum {
+		return nil, fmt.Errorf("block %v is before genesis", number)
+	}
+	count := arbutil.BlockNumberToMessageCount(number, s.genesisBlockNum)
+	head, err := s.exec.HeadMessageCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if count > head {
+		return nil, nil
+	}
+	result, err := s.exec.ResultAtMessage(ctx, count-1)
+	if err != nil {
+		return nil, err
+	}
+	return &validator.L2BlockInfo{Number: number, Hash: result.BlockHash, SendRoot: result.SendRoot}, nil
+}
+
+// ExecutionServerAPI serves an ExecutionClient in the execution RPC namespace.
+type ExecutionServerAPI struct {
+	exec ExecutionClient
+}
+
+func NewExecutionServerAPI(exec ExecutionClient) *ExecutionServerAPI {
+	return &ExecutionServerAPI{exec: exec}
+}
+
+func (a *ExecutionServerAPI) Version(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(ExecutionInterfaceVersion)
+}
+
+func (a *ExecutionServerAPI) DigestMessage(ctx context.Context, num hexutil.Uint64, msg *arbstate.MessageWithMetadata, batches map[hexutil.Uint64]hexutil.Bytes) (*ExecutionResult, error) {
+	if msg == nil || msg.Message == nil || msg.Message.Header == nil {
+		return nil, errors.New("missing message")
+	}
+	batchData := make(map[uint64][]byte, len(batches))
+	for batchNum, data := range batches {
+		batchData[uint64(batchNum)] = data
+	}
+	return a.exec.DigestMessage(ctx, arbutil.MessageIndex(num), msg, batchData)
+}
+
+func (a *ExecutionServerAPI) Reorg(ctx context.Context, count hexutil.Uint64) error {
+	return a.exec.Reorg(ctx, arbutil.MessageIndex(count))
+}
+
+func (a *ExecutionServerAPI) HeadMessageCount(ctx context.Context) (hexutil.Uint64, error) {
+	count, err := a.exec.HeadMessageCount(ctx)
+	return hexutil.Uint64(count), err
+}
+
+func (a *ExecutionServerAPI) ResultAtMessage(ctx context.Context, num hexutil.Uint64) (*ExecutionResult, error) {
+	return a.exec.ResultAtMessage(ctx, arbutil.MessageIndex(num))
+}
+
+// RemoteExecutionClient executes messages on an execution node through its ExecutionServerAPI.
+type RemoteExecutionClient struct {
+	client  *rpc.Client
+	timeout time.Duration
+}
+
+func NewRemoteExecutionClient(ctx context.Context, config *ExecutionConfig) (*RemoteExecutionClient, error) {
+	client, err := rpc.DialContext(ctx, config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to execution node %v: %w", config.URL, err)
+	}
+	return newRemoteExecutionClient(ctx, client, config.RequestTimeout)
+}
+
+func newRemoteExecutionClient(ctx context.Context, client *rpc.Client, timeout time.Duration) (*RemoteExecutionClient, error) {
+	c := &RemoteExecutionClient{client: client, timeout: timeout}
+	var version hexutil.Uint64
+	if err := c.call(ctx, &version, "execution_version"); err != nil {
+		return nil, fmt.Errorf("failed to get execution interface version: %w", err)
+	}
+	if uint64(version) != ExecutionInterfaceVersion {
+		return nil, fmt.Errorf("execution node speaks interface version %v, expected %v", uint64(version), ExecutionInterfaceVersion)
+	}
+	return c, nil
+}
+
+func (c *RemoteExecutionClient) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.CallContext(callCtx, result, method, args...)
+}
+
+func (c *RemoteExecutionClient) DigestMessage(ctx context.Context, num arbutil.MessageIndex, msg *arbstate.MessageWithMetadata, batches map[uint64][]byte) (*ExecutionResult, error) {
+	batchData := make(map[hexutil.Uint64]hexutil.Bytes, len(batches))
+	for batchNum, data := range batches {
+		batchData[hexutil.Uint64(batchNum)] = data
+	}
+	var result ExecutionResult
+	if err := c.call(ctx, &result, "execution_digestMessage", hexutil.Uint64(num), msg, batchData); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *RemoteExecutionClient) Reorg(ctx context.Context, count arbutil.MessageIndex) error {
+	return c.call(ctx, nil, "execution_reorg", hexutil.Uint64(count))
+}
+
+func (c *RemoteExecutionClient) HeadMessageCount(ctx context.Context) (arbutil.MessageIndex, error) {
+	var count hexutil.Uint64
+	err := c.call(ctx, &count, "execution_headMessageCount")
+	return arbutil.MessageIndex(count), err
+}
+
+func (c *RemoteExecutionClient) ResultAtMessage(ctx context.Context, num arbutil.MessageIndex) (*ExecutionResult, error) {
+	var result ExecutionResult
+	if err := c.call(ctx, &result, "execution_resultAtMessage", hexutil.Uint64(num)); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *RemoteExecutionClient) Close() {
+	c.client.Close()
+}
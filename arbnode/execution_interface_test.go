@@ -0,0 +1,139 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// testExecution "executes" a message by hashing it onto the previous result.
+type testExecution struct {
+	results []ExecutionResult
+	batches map[uint64][]byte
+}
+
+func (e *testExecution) DigestMessage(ctx context.Context, num arbutil.MessageIndex, msg *arbstate.MessageWithMetadata, batches map[uint64][]byte) (*ExecutionResult, error) {
+	if int(num) != len(e.results) {
+		return nil, fmt.Errorf("can't digest message %v before message %v", num, len(e.results))
+	}
+	var prev common.Hash
+	if num > 0 {
+		prev = e.results[num-1].BlockHash
+	}
+	e.results = append(e.results, ExecutionResult{BlockHash: crypto.Keccak256Hash(prev.Bytes(), msg.Message.L2msg)})
+	for batchNum, data := range batches {
+		e.batches[batchNum] = data
+	}
+	return &e.results[num], nil
+}
+
+func (e *testExecution) Reorg(ctx context.Context, count arbutil.MessageIndex) error {
+	e.results = e.results[:count]
+	return nil
+}
+
+func (e *testExecution) HeadMessageCount(ctx context.Context) (arbutil.MessageIndex, error) {
+	return arbutil.MessageIndex(len(e.results)), nil
+}
+
+func (e *testExecution) ResultAtMessage(ctx context.Context, num arbutil.MessageIndex) (*ExecutionResult, error) {
+	return &e.results[num], nil
+}
+
+type futureExecutionAPI struct{}
+
+func (a *futureExecutionAPI) Version(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(ExecutionInterfaceVersion + 1)
+}
+
+func TestRemoteExecutionClient(t *testing.T) {
+	ctx := context.Background()
+	exec := &testExecution{batches: make(map[uint64][]byte)}
+	server := rpc.NewServer()
+	Require(t, server.RegisterName("execution", NewExecutionServerAPI(exec)))
+	defer server.Stop()
+	client, err := newRemoteExecutionClient(ctx, rpc.DialInProc(server), time.Second)
+	Require(t, err)
+
+	for i := 0; i < 3; i++ {
+		msg := &arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{Kind: arbos.L1MessageType_L2Message},
+				L2msg:  []byte{byte(i)},
+			},
+		}
+		result, err := client.DigestMessage(ctx, arbutil.MessageIndex(i), msg, map[uint64][]byte{uint64(i): {1, 2, 3}})
+		Require(t, err)
+		if *result != exec.results[i] {
+			Fail(t, "unexpected result", result, exec.results[i])
+		}
+	}
+	if string(exec.batches[2]) != string([]byte{1, 2, 3}) {
+		Fail(t, "batch data not sent", exec.batches)
+	}
+	if _, err := client.DigestMessage(ctx, 5, &arbstate.MessageWithMetadata{Message: &arbos.L1IncomingMessage{Header: &arbos.L1IncomingMessageHeader{}}}, nil); err == nil {
+		Fail(t, "digested a message out of order")
+	}
+
+	Require(t, client.Reorg(ctx, 2))
+	count, err := client.HeadMessageCount(ctx)
+	Require(t, err)
+	if count != 2 {
+		Fail(t, "unexpected head after reorg", count)
+	}
+	result, err := client.ResultAtMessage(ctx, 1)
+	Require(t, err)
+	if *result != exec.results[1] {
+		Fail(t, "unexpected result", result)
+	}
+
+	futureServer := rpc.NewServer()
+	Require(t, futureServer.RegisterName("execution", &futureExecutionAPI{}))
+	defer futureServer.Stop()
+	if _, err := newRemoteExecutionClient(ctx, rpc.DialInProc(futureServer), time.Second); err == nil {
+		Fail(t, "used an execution node speaking another interface version")
+	}
+}
+
+func TestExecutionBlockSource(t *testing.T) {
+	ctx := context.Background()
+	exec := &testExecution{batches: make(map[uint64][]byte)}
+	for i := 0; i < 3; i++ {
+		msg := &arbstate.MessageWithMetadata{Message: &arbos.L1IncomingMessage{L2msg: []byte{byte(i)}}}
+		_, err := exec.DigestMessage(ctx, arbutil.MessageIndex(i), msg, nil)
+		Require(t, err)
+	}
+	const genesisBlockNum = 10
+	source := newExecutionBlockSource(exec, genesisBlockNum)
+
+	head, err := source.HeadBlockNumber(ctx)
+	Require(t, err)
+	if head != genesisBlockNum+2 {
+		Fail(t, "unexpected head block", head)
+	}
+	block, err := source.BlockByNumber(ctx, genesisBlockNum+1)
+	Require(t, err)
+	if block == nil || block.Number != genesisBlockNum+1 || block.Hash != exec.results[1].BlockHash {
+		Fail(t, "unexpected block", block)
+	}
+	block, err = source.BlockByNumber(ctx, genesisBlockNum+3)
+	Require(t, err)
+	if block != nil {
+		Fail(t, "returned a block not yet executed", block)
+	}
+	if _, err := source.BlockByNumber(ctx, genesisBlockNum-1); err == nil {
+		Fail(t, "returned a block before genesis")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// FeeHistoryConfig bounds eth_feeHistory, which reads the receipts of every block it covers.
+type FeeHistoryConfig struct {
+	MaxBlockCount uint64 `koanf:"max-block-count"`
+}
+
+func FeeHistoryConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Uint64(prefix+".max-block-count", DefaultFeeHistoryConfig.MaxBlockCount, "the most blocks a single eth_feeHistory request covers, longer ranges are truncated")
+}
+
+var DefaultFeeHistoryConfig = FeeHistoryConfig{
+	MaxBlockCount: 1024,
+}
+
+// FeeHistoryAPI replaces eth_feeHistory with one that accounts for Nitro's two dimensional gas:
+// the L2 base fee and the L1 data fee charged as extra gas.
+type FeeHistoryAPI struct {
+	blockchain *core.BlockChain
+	config     *FeeHistoryConfig
+}
+
+type FeeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+
+	// The L1 price per calldata unit ArbOS charged in each block
+	L1PricePerUnit []*hexutil.Big `json:"l1PricePerUnit"`
+	// Per block percentiles of the L1 data fee per unit of L2 gas, weighted by gas used
+	L1FeePerGas [][]*hexutil.Big `json:"l1FeePerGas,omitempty"`
+}
+
+// FeeHistory extends eth_feeHistory. The reward percentiles are the priority fees paid, as on mainnet,
+// and are zero before ArbOS charges them. The L1 data fee paid per unit of gas, which a wallet needs on
+// top of the base fee, is reported at the same percentiles in a separate field. Nitro's next base fee
+// isn't known ahead of time, so the last one is repeated.
+func (api *FeeHistoryAPI) FeeHistory(ctx context.Context, blockCount rpc.DecimalOrHex, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (FeeHistory, error) {
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return FeeHistory{}, fmt.Errorf("invalid reward percentile %v", p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return FeeHistory{}, fmt.Errorf("invalid reward percentile %v after %v", p, rewardPercentiles[i-1])
+		}
+	}
+	if blockCount == 0 {
+		return FeeHistory{}, errors.New("block count must be positive")
+	}
+	blocks := uint64(blockCount)
+	if blocks > api.config.MaxBlockCount {
+		log.Warn("Sanitizing fee history # of blocks", "requested", blocks, "truncated", api.config.MaxBlockCount)
+		blocks = api.config.MaxBlockCount
+	}
+
+	end, _ := api.blockchain.ClipToPostNitroGenesis(lastBlock)
+	start, _ := api.blockchain.ClipToPostNitroGenesis(end - rpc.BlockNumber(blocks) + 1)
+	if end < start {
+		return FeeHistory{}, fmt.Errorf("invalid block range: %v to %v", start.Int64(), end.Int64())
+	}
+	blocks = uint64(end-start) + 1
+
+	history := FeeHistory{
+		OldestBlock:    (*hexutil.Big)(big.NewInt(start.Int64())),
+		BaseFee:        make([]*hexutil.Big, blocks+1),
+		GasUsedRatio:   make([]float64, blocks),
+		L1PricePerUnit: make([]*hexutil.Big, blocks),
+	}
+	if len(rewardPercentiles) > 0 {
+		history.Reward = make([][]*hexutil.Big, blocks)
+		history.L1FeePerGas = make([][]*hexutil.Big, blocks)
+	}
+
+	for i := uint64(0); i < blocks; i++ {
+		if err := ctx.Err(); err != nil {
+			return history, err
+		}
+		state, header, err := stateAndHeader(api.blockchain, i+uint64(start))
+		if err != nil {
+			return history, err
+		}
+		pricePerUnit, err := state.L1PricingState().PricePerUnit()
+		if err != nil {
+			return history, err
+		}
+		history.BaseFee[i] = (*hexutil.Big)(header.BaseFee)
+		history.GasUsedRatio[i] = float64(header.GasUsed) / float64(header.GasLimit)
+		history.L1PricePerUnit[i] = (*hexutil.Big)(pricePerUnit)
+
+		if len(rewardPercentiles) > 0 {
+			block := api.blockchain.GetBlock(header.Hash(), header.Number.Uint64())
+			if block == nil {
+				return history, fmt.Errorf("block %v not found", header.Number)
+			}
+			receipts := api.blockchain.GetReceiptsByHash(header.Hash())
+			history.Reward[i] = tipPercentiles(header, block.Transactions(), receipts, rewardPercentiles)
+			history.L1FeePerGas[i] = l1FeePerGasPercentiles(header, receipts, rewardPercentiles)
+		}
+	}
+	history.BaseFee[blocks] = history.BaseFee[blocks-1]
+
+	return history, nil
+}
+
+// ArbFeeAPI serves fee estimates in the chain's fee token.
+type ArbFeeAPI struct {
+	feeTokenPrice *FeeTokenPriceFeed // nil if the fee token is the parent chain's gas token
+}
+
+type GasEstimateL1Components struct {
+	GasForL1          hexutil.Uint64 `json:"gasForL1"`
+	L1BaseFeeEstimate *hexutil.Big   `json:"l1BaseFeeEstimate"`
+}
+
+// GasEstimateL1ComponentsInFeeToken converts the L1 parts of NodeInterface.gasEstimateComponents, which
+// ArbOS prices in the parent chain's gas token, into the chain's fee token. It fails rather than return
+// unconverted values if the fee token's price isn't known.
+func (api *ArbFeeAPI) GasEstimateL1ComponentsInFeeToken(gasForL1 hexutil.Uint64, l1BaseFeeEstimate *hexutil.Big) (GasEstimateL1Components, error) {
+	if l1BaseFeeEstimate == nil {
+		return GasEstimateL1Components{}, errors.New("missing l1 base fee estimate")
+	}
+	if api.feeTokenPrice == nil {
+		return GasEstimateL1Components{gasForL1, l1BaseFeeEstimate}, nil
+	}
+	// The L2 base fee is already in the fee token, so the gas needed to pay for L1 scales with the rate
+	gas, err := api.feeTokenPrice.ToFeeToken(new(big.Int).SetUint64(uint64(gasForL1)))
+	if err != nil {
+		return GasEstimateL1Components{}, err
+	}
+	if !gas.IsUint64() {
+		return GasEstimateL1Components{}, fmt.Errorf("gas for l1 of %v overflows", gas)
+	}
+	baseFee, err := api.feeTokenPrice.ToFeeToken(l1BaseFeeEstimate.ToInt())
+	if err != nil {
+		return GasEstimateL1Components{}, err
+	}
+	return GasEstimateL1Components{hexutil.Uint64(gas.Uint64()), (*hexutil.Big)(baseFee)}, nil
+}
+
+type feeSample struct {
+	feePerGas *big.Int
+	gasUsed   uint64
+}
+
+// tipPercentiles returns the priority fee per gas paid at each percentile of the block's gas used.
+// Tips are only charged from ArbOS version PriorityFeeArbosVersion, and only for user transactions.
+func tipPercentiles(header *types.Header, txs types.Transactions, receipts types.Receipts, percentiles []float64) []*hexutil.Big {
+	charged := types.DeserializeHeaderExtraInformation(header).ArbOSFormatVersion >= arbos.PriorityFeeArbosVersion
+	samples := make([]feeSample, 0, len(receipts))
+	for i, receipt := range receipts {
+		if receipt.GasUsed == 0 || i >= len(txs) {
+			continue
+		}
+		tip := new(big.Int)
+		if charged && txs[i].Type() <= types.DynamicFeeTxType {
+			if effective, err := txs[i].EffectiveGasTip(header.BaseFee); err == nil {
+				tip = effective
+			}
+		}
+		samples = append(samples, feeSample{tip, receipt.GasUsed})
+	}
+	return weightedPercentiles(samples, percentiles)
+}
+
+// l1FeePerGasPercentiles returns the L1 data fee paid per unit of gas at each percentile of the block's gas used.
+func l1FeePerGasPercentiles(header *types.Header, receipts types.Receipts, percentiles []float64) []*hexutil.Big {
+	samples := make([]feeSample, 0, len(receipts))
+	for _, receipt := range receipts {
+		if receipt.GasUsed == 0 {
+			continue
+		}
+		feePerGas := arbmath.BigDivByUint(arbmath.BigMulByUint(header.BaseFee, receipt.GasUsedForL1), receipt.GasUsed)
+		samples = append(samples, feeSample{feePerGas, receipt.GasUsed})
+	}
+	return weightedPercentiles(samples, percentiles)
+}
+
+func weightedPercentiles(samples []feeSample, percentiles []float64) []*hexutil.Big {
+	result := make([]*hexutil.Big, len(percentiles))
+	if len(samples) == 0 {
+		for i := range result {
+			result[i] = (*hexutil.Big)(new(big.Int))
+		}
+		return result
+	}
+	var totalGas uint64
+	for _, sample := range samples {
+		totalGas += sample.gasUsed
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].feePerGas.Cmp(samples[j].feePerGas) < 0
+	})
+
+	index := 0
+	sumGas := samples[0].gasUsed
+	for i, p := range percentiles {
+		threshold := uint64(float64(totalGas) * p / 100)
+		for sumGas < threshold && index < len(samples)-1 {
+			index++
+			sumGas += samples[index].gasUsed
+		}
+		result[i] = (*hexutil.Big)(samples[index].feePerGas)
+	}
+	return result
+}
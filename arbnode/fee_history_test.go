@@ -0,0 +1,82 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/nitro/arbos"
+)
+
+func TestL1FeePerGasPercentiles(t *testing.T) {
+	header := &types.Header{BaseFee: big.NewInt(100)}
+	receipts := types.Receipts{
+		// 50 wei of L1 fee per gas, heavily weighted
+		{GasUsed: 800, GasUsedForL1: 400},
+		// 10 wei of L1 fee per gas
+		{GasUsed: 100, GasUsedForL1: 10},
+		// no L1 component
+		{GasUsed: 100, GasUsedForL1: 0},
+		// ignored, as it used no gas
+		{GasUsed: 0, GasUsedForL1: 0},
+	}
+	percentiles := []float64{0, 10, 20, 50, 100}
+	expected := []int64{0, 0, 10, 50, 50}
+
+	result := l1FeePerGasPercentiles(header, receipts, percentiles)
+	if len(result) != len(expected) {
+		Fail(t, "expected", len(expected), "percentiles but got", len(result))
+	}
+	for i, fee := range result {
+		if fee.ToInt().Int64() != expected[i] {
+			Fail(t, "percentile", percentiles[i], "expected", expected[i], "but got", fee.ToInt())
+		}
+	}
+
+	empty := l1FeePerGasPercentiles(header, nil, percentiles)
+	for i, fee := range empty {
+		if fee.ToInt().Sign() != 0 {
+			Fail(t, "percentile", percentiles[i], "of empty block is nonzero", fee.ToInt())
+		}
+	}
+}
+
+func TestTipPercentiles(t *testing.T) {
+	header := &types.Header{BaseFee: big.NewInt(100)}
+	tx := func(feeCap, tipCap int64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(feeCap), GasTipCap: big.NewInt(tipCap)})
+	}
+	txs := types.Transactions{
+		// a 5 wei tip, heavily weighted
+		tx(200, 5),
+		// a 30 wei tip, capped at 20 by the fee cap
+		tx(120, 30),
+		// no tip
+		tx(100, 0),
+	}
+	receipts := types.Receipts{{GasUsed: 800}, {GasUsed: 100}, {GasUsed: 100}}
+	percentiles := []float64{0, 10, 50, 95, 100}
+
+	types.HeaderInfo{ArbOSFormatVersion: arbos.PriorityFeeArbosVersion - 1}.UpdateHeaderWithInfo(header)
+	for i, tip := range tipPercentiles(header, txs, receipts, percentiles) {
+		if tip.ToInt().Sign() != 0 {
+			Fail(t, "percentile", percentiles[i], "has a tip before tips are charged", tip.ToInt())
+		}
+	}
+
+	types.HeaderInfo{ArbOSFormatVersion: arbos.PriorityFeeArbosVersion}.UpdateHeaderWithInfo(header)
+	expected := []int64{0, 0, 5, 20, 20}
+	result := tipPercentiles(header, txs, receipts, percentiles)
+	if len(result) != len(expected) {
+		Fail(t, "expected", len(expected), "percentiles but got", len(result))
+	}
+	for i, tip := range result {
+		if tip.ToInt().Int64() != expected[i] {
+			Fail(t, "percentile", percentiles[i], "expected", expected[i], "but got", tip.ToInt())
+		}
+	}
+}
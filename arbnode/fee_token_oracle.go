@@ -0,0 +1,289 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+var feeTokenRateGauge = metrics.NewRegisteredGaugeFloat64("arb/feetoken/rate", nil)
+
+// FeeTokenOracleConfig selects how the chain's fee token is priced against the parent chain's gas token.
+// It's only needed when the two differ; with no source configured, amounts are used unconverted.
+type FeeTokenOracleConfig struct {
+	Source         string        `koanf:"source"`
+	StaticRate     float64       `koanf:"static-rate"`
+	URL            string        `koanf:"url"`
+	JSONField      string        `koanf:"json-field"`
+	Contract       string        `koanf:"contract"`
+	UpdateInterval time.Duration `koanf:"update-interval"`
+	RequestTimeout time.Duration `koanf:"request-timeout"`
+	MaxAge         time.Duration `koanf:"max-age"`
+}
+
+func FeeTokenOracleConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".source", DefaultFeeTokenOracleConfig.Source, "how to price the chain's fee token in the parent chain's gas token (static, http, or contract), empty if they're the same token")
+	f.Float64(prefix+".static-rate", DefaultFeeTokenOracleConfig.StaticRate, "fee tokens per parent chain gas token, for the static source")
+	f.String(prefix+".url", DefaultFeeTokenOracleConfig.URL, "URL returning a JSON object with the rate, for the http source")
+	f.String(prefix+".json-field", DefaultFeeTokenOracleConfig.JSONField, "field of the JSON object holding the rate, for the http source")
+	f.String(prefix+".contract", DefaultFeeTokenOracleConfig.Contract, "address of a parent chain price feed implementing latestRoundData() and decimals(), for the contract source")
+	f.Duration(prefix+".update-interval", DefaultFeeTokenOracleConfig.UpdateInterval, "how often to refresh the rate")
+	f.Duration(prefix+".request-timeout", DefaultFeeTokenOracleConfig.RequestTimeout, "timeout for a single rate request")
+	f.Duration(prefix+".max-age", DefaultFeeTokenOracleConfig.MaxAge, "refuse to use a rate older than this")
+}
+
+var DefaultFeeTokenOracleConfig = FeeTokenOracleConfig{
+	Source:         "",
+	StaticRate:     1,
+	URL:            "",
+	JSONField:      "rate",
+	Contract:       "",
+	UpdateInterval: time.Minute,
+	RequestTimeout: 10 * time.Second,
+	MaxAge:         time.Hour,
+}
+
+// FeeTokenOracle prices the chain's fee token against the parent chain's gas token.
+// Both tokens are assumed to have 18 decimals.
+type FeeTokenOracle interface {
+	// FeeTokenRate returns how many fee tokens one parent chain gas token is worth, and when that price was set.
+	FeeTokenRate(ctx context.Context) (float64, time.Time, error)
+}
+
+type StaticFeeTokenOracle struct {
+	rate float64
+}
+
+func NewStaticFeeTokenOracle(rate float64) *StaticFeeTokenOracle {
+	return &StaticFeeTokenOracle{rate}
+}
+
+func (o *StaticFeeTokenOracle) FeeTokenRate(ctx context.Context) (float64, time.Time, error) {
+	return o.rate, time.Now(), nil
+}
+
+// HTTPFeeTokenOracle reads the rate from a field of the JSON object an external API returns.
+// The field may be a number or a decimal string.
+type HTTPFeeTokenOracle struct {
+	url    string
+	field  string
+	client *http.Client
+}
+
+func NewHTTPFeeTokenOracle(url string, field string, timeout time.Duration) *HTTPFeeTokenOracle {
+	return &HTTPFeeTokenOracle{
+		url:    url,
+		field:  field,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *HTTPFeeTokenOracle) FeeTokenRate(ctx context.Context) (float64, time.Time, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url, nil)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	response, err := o.client.Do(request)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return 0, time.Time{}, fmt.Errorf("fee token price API returned status %v", response.StatusCode)
+	}
+	var body map[string]json.RawMessage
+	err = json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	raw, ok := body[o.field]
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("fee token price API response has no field %v", o.field)
+	}
+	rate, err := parseFeeTokenRate(raw)
+	return rate, time.Now(), err
+}
+
+func parseFeeTokenRate(raw json.RawMessage) (float64, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strconv.ParseFloat(text, 64)
+	}
+	var rate float64
+	err := json.Unmarshal(raw, &rate)
+	return rate, err
+}
+
+var (
+	latestRoundDataSelector = common.FromHex("0xfeaf968c")
+	decimalsSelector        = common.FromHex("0x313ce567")
+)
+
+// ContractFeeTokenOracle reads the rate from a Chainlink-style price feed on the parent chain.
+type ContractFeeTokenOracle struct {
+	client   ethereum.ContractCaller
+	address  common.Address
+	decimals *big.Int // lazily loaded
+}
+
+func NewContractFeeTokenOracle(client ethereum.ContractCaller, address common.Address) *ContractFeeTokenOracle {
+	return &ContractFeeTokenOracle{
+		client:  client,
+		address: address,
+	}
+}
+
+func (o *ContractFeeTokenOracle) call(ctx context.Context, data []byte, minLength int) ([]byte, error) {
+	result, err := o.client.CallContract(ctx, ethereum.CallMsg{To: &o.address, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) < minLength {
+		return nil, fmt.Errorf("fee token price feed %v returned %v bytes, expected %v", o.address, len(result), minLength)
+	}
+	return result, nil
+}
+
+func (o *ContractFeeTokenOracle) FeeTokenRate(ctx context.Context) (float64, time.Time, error) {
+	if o.decimals == nil {
+		result, err := o.call(ctx, decimalsSelector, 32)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		o.decimals = new(big.Int).SetBytes(result[:32])
+	}
+	// latestRoundData returns (roundId, answer, startedAt, updatedAt, answeredInRound)
+	result, err := o.call(ctx, latestRoundDataSelector, 5*32)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	answer := new(big.Int).SetBytes(result[32:64])
+	if answer.Sign() == 0 || result[32]&0x80 != 0 {
+		return 0, time.Time{}, fmt.Errorf("fee token price feed %v returned a non-positive answer", o.address)
+	}
+	updatedAt := new(big.Int).SetBytes(result[96:128])
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), o.decimals, nil))
+	rate, _ := new(big.Float).Quo(new(big.Float).SetInt(answer), scale).Float64()
+	return rate, time.Unix(updatedAt.Int64(), 0), nil
+}
+
+// FeeTokenPriceFeed periodically refreshes the rate from an oracle, so that the batch poster and gas
+// estimation convert between the fee token and the parent chain's gas token with the same price.
+type FeeTokenPriceFeed struct {
+	stopwaiter.StopWaiter
+	oracle FeeTokenOracle
+	config *FeeTokenOracleConfig
+
+	mutex     sync.Mutex
+	rate      float64
+	updatedAt time.Time
+}
+
+func NewFeeTokenPriceFeed(config *FeeTokenOracleConfig, parentClient ethereum.ContractCaller) (*FeeTokenPriceFeed, error) {
+	var oracle FeeTokenOracle
+	switch config.Source {
+	case "static":
+		if config.StaticRate <= 0 {
+			return nil, errors.New("fee token oracle static-rate must be positive")
+		}
+		oracle = NewStaticFeeTokenOracle(config.StaticRate)
+	case "http":
+		if config.URL == "" {
+			return nil, errors.New("fee token oracle http source needs a url")
+		}
+		oracle = NewHTTPFeeTokenOracle(config.URL, config.JSONField, config.RequestTimeout)
+	case "contract":
+		if !common.IsHexAddress(config.Contract) {
+			return nil, fmt.Errorf("invalid fee token price feed address \"%v\"", config.Contract)
+		}
+		if parentClient == nil {
+			return nil, errors.New("fee token oracle contract source needs an L1 reader")
+		}
+		oracle = NewContractFeeTokenOracle(parentClient, common.HexToAddress(config.Contract))
+	default:
+		return nil, fmt.Errorf("unknown fee token oracle source \"%v\"", config.Source)
+	}
+	return &FeeTokenPriceFeed{
+		oracle: oracle,
+		config: config,
+	}, nil
+}
+
+func (f *FeeTokenPriceFeed) update(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, f.config.RequestTimeout)
+	defer cancel()
+	rate, updatedAt, err := f.oracle.FeeTokenRate(ctx)
+	if err != nil {
+		return err
+	}
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return fmt.Errorf("fee token oracle returned invalid rate %v", rate)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rate = rate
+	f.updatedAt = updatedAt
+	feeTokenRateGauge.Update(rate)
+	return nil
+}
+
+func (f *FeeTokenPriceFeed) Start(ctxIn context.Context) {
+	f.StopWaiter.Start(ctxIn)
+	f.CallIteratively(func(ctx context.Context) time.Duration {
+		err := f.update(ctx)
+		if err != nil {
+			log.Warn("failed to update fee token price", "err", err)
+		}
+		return f.config.UpdateInterval
+	})
+}
+
+// Rate returns the latest fee tokens per parent chain gas token, failing if it's missing or stale.
+func (f *FeeTokenPriceFeed) Rate() (float64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.rate == 0 {
+		return 0, errors.New("fee token price not yet known")
+	}
+	if f.config.MaxAge > 0 && time.Since(f.updatedAt) > f.config.MaxAge {
+		return 0, fmt.Errorf("fee token price is stale, last updated %v", f.updatedAt)
+	}
+	return f.rate, nil
+}
+
+// ToFeeToken converts an amount of the parent chain's gas token into the chain's fee token.
+func (f *FeeTokenPriceFeed) ToFeeToken(amount *big.Int) (*big.Int, error) {
+	rate, err := f.Rate()
+	if err != nil {
+		return nil, err
+	}
+	return bigMulByFloat(amount, rate), nil
+}
+
+// ToParentGasToken converts an amount of the chain's fee token into the parent chain's gas token.
+func (f *FeeTokenPriceFeed) ToParentGasToken(amount *big.Int) (*big.Int, error) {
+	rate, err := f.Rate()
+	if err != nil {
+		return nil, err
+	}
+	return bigMulByFloat(amount, 1/rate), nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestFeeTokenPriceFeedConversion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"rate": "2500.5", "other": 1}`))
+	}))
+	defer server.Close()
+
+	config := DefaultFeeTokenOracleConfig
+	config.Source = "http"
+	config.URL = server.URL
+	feed, err := NewFeeTokenPriceFeed(&config, nil)
+	Require(t, err)
+	if _, err := feed.Rate(); err == nil {
+		Fail(t, "rate available before the first update")
+	}
+	Require(t, feed.update(ctx))
+
+	converted, err := feed.ToFeeToken(big.NewInt(1000))
+	Require(t, err)
+	if converted.Cmp(big.NewInt(2500500)) != 0 {
+		Fail(t, "unexpected fee token amount", converted)
+	}
+	back, err := feed.ToParentGasToken(big.NewInt(2500500))
+	Require(t, err)
+	if back.Cmp(big.NewInt(999)) < 0 || back.Cmp(big.NewInt(1000)) > 0 {
+		Fail(t, "unexpected parent gas token amount", back)
+	}
+
+	api := &ArbFeeAPI{feeTokenPrice: feed}
+	components, err := api.GasEstimateL1ComponentsInFeeToken(1000, (*hexutil.Big)(big.NewInt(1000)))
+	Require(t, err)
+	if components.GasForL1 != 2500500 || components.L1BaseFeeEstimate.ToInt().Cmp(big.NewInt(2500500)) != 0 {
+		Fail(t, "unexpected converted gas estimate", components)
+	}
+
+	feed.updatedAt = time.Now().Add(-2 * config.MaxAge)
+	if _, err := feed.Rate(); err == nil {
+		Fail(t, "stale rate used")
+	}
+	if _, err := api.GasEstimateL1ComponentsInFeeToken(1000, (*hexutil.Big)(big.NewInt(1000))); err == nil {
+		Fail(t, "gas estimate converted at a stale rate")
+	}
+	components, err = (&ArbFeeAPI{}).GasEstimateL1ComponentsInFeeToken(1000, (*hexutil.Big)(big.NewInt(1000)))
+	Require(t, err)
+	if components.GasForL1 != 1000 {
+		Fail(t, "gas estimate converted without a fee token oracle", components)
+	}
+
+	config.JSONField = "missing"
+	feed, err = NewFeeTokenPriceFeed(&config, nil)
+	Require(t, err)
+	if err := feed.update(ctx); err == nil {
+		Fail(t, "missing field accepted")
+	}
+
+	config.Source = "contract"
+	config.Contract = "0x0000000000000000000000000000000000000001"
+	if _, err := NewFeeTokenPriceFeed(&config, nil); err == nil {
+		Fail(t, "contract source accepted without an L1 client")
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcastclient"
+	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+// Advisory kinds
+const (
+	AdvisoryBatchPostingDeadline = "batch-posting-deadline"
+	AdvisoryForceInclusion       = "force-inclusion"
+	AdvisoryMaintenance          = "maintenance"
+)
+
+// AdvisoriesConfig has the sequencer warn feed clients of limits it's approaching: the sequencer inbox's max
+// delay for messages not yet posted, the force-inclusion deadline of delayed messages not yet sequenced,
+// and scheduled maintenance windows.
+type AdvisoriesConfig struct {
+	Enable             bool          `koanf:"enable"`
+	Interval           time.Duration `koanf:"interval"`
+	DeadlineNotice     time.Duration `koanf:"deadline-notice"`
+	MaintenanceNotice  time.Duration `koanf:"maintenance-notice"`
+	MaintenanceWindows []string      `koanf:"maintenance-windows"`
+}
+
+func AdvisoriesConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultAdvisoriesConfig.Enable, "broadcast advisories on the feed as the sequencer approaches its limits")
+	f.Duration(prefix+".interval", DefaultAdvisoriesConfig.Interval, "how often to recompute the advisories")
+	f.Duration(prefix+".deadline-notice", DefaultAdvisoriesConfig.DeadlineNotice, "how far ahead of a max delay or force-inclusion deadline to escalate its advisory to a warning")
+	f.Duration(prefix+".maintenance-notice", DefaultAdvisoriesConfig.MaintenanceNotice, "how far ahead of a maintenance window to start advising of it")
+	f.StringSlice(prefix+".maintenance-windows", DefaultAdvisoriesConfig.MaintenanceWindows, "scheduled maintenance windows, each as start/end/description with RFC 3339 times")
+}
+
+var DefaultAdvisoriesConfig = AdvisoriesConfig{
+	Enable:             false,
+	Interval:           30 * time.Second,
+	DeadlineNotice:     time.Hour,
+	MaintenanceNotice:  24 * time.Hour,
+	MaintenanceWindows: []string{},
+}
+
+type maintenanceWindow struct {
+	start       time.Time
+	end         time.Time
+	description string
+}
+
+func parseMaintenanceWindows(windows []string) ([]maintenanceWindow, error) {
+	var parsed []maintenanceWindow
+	for _, window := range windows {
+		parts := strings.SplitN(window, "/", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("maintenance window \"%v\" must be start/end/description", window)
+		}
+		start, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window \"%v\" start: %w", window, err)
+		}
+		end, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window \"%v\" end: %w", window, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("maintenance window \"%v\" ends before it starts", window)
+		}
+		description := "scheduled sequencer maintenance"
+		if len(parts) == 3 && parts[2] != "" {
+			description = parts[2]
+		}
+		parsed = append(parsed, maintenanceWindow{start, end, description})
+	}
+	return parsed, nil
+}
+
+// maintenanceAdvisories advises of the windows ongoing at now or starting within the notice.
+func maintenanceAdvisories(windows []maintenanceWindow, now time.Time, notice time.Duration) []broadcaster.Advisory {
+	var advisories []broadcaster.Advisory
+	for _, window := range windows {
+		if !now.Before(window.end) || window.start.Sub(now) > notice {
+			continue
+		}
+		severity := broadcaster.AdvisoryInfo
+		if !now.Before(window.start) {
+			severity = broadcaster.AdvisoryWarning
+		}
+		advisories = append(advisories, broadcaster.Advisory{
+			Kind:     AdvisoryMaintenance,
+			Severity: severity,
+			Message:  window.description,
+			Deadline: uint64(window.start.Unix()),
+			Until:    uint64(window.end.Unix()),
+		})
+	}
+	return advisories
+}
+
+// deadlineSeverity escalates an advisory as the L1 timestamp approaches its deadline.
+func deadlineSeverity(deadline uint64, l1Timestamp uint64, notice time.Duration) string {
+	if l1Timestamp >= deadline {
+		return broadcaster.AdvisoryCritical
+	}
+	if time.Duration(deadline-l1Timestamp)*time.Second <= notice {
+		return broadcaster.AdvisoryWarning
+	}
+	return broadcaster.AdvisoryInfo
+}
+
+// FeedAdvisories holds the latest advisories, which the sequencer computes and broadcasts, and other nodes
+// follow from the feed and pass on to their own feed clients.
+type FeedAdvisories struct {
+	stopwaiter.StopWaiter
+	config          *AdvisoriesConfig
+	maintenance     []maintenanceWindow
+	broadcastServer *broadcaster.Broadcaster
+	listener        chan *broadcaster.AdvisoryMessage
+
+	// set if this node computes the advisories
+	inboxReader *InboxReader
+	streamer    *TransactionStreamer
+
+	mutex  sync.Mutex
+	latest *broadcaster.AdvisoryMessage
+}
+
+func NewFeedAdvisories(config *AdvisoriesConfig, broadcastServer *broadcaster.Broadcaster, broadcastClients []*broadcastclient.BroadcastClient) (*FeedAdvisories, error) {
+	maintenance, err := parseMaintenanceWindows(config.MaintenanceWindows)
+	if err != nil {
+		return nil, err
+	}
+	a := &FeedAdvisories{
+		config:          config,
+		maintenance:     maintenance,
+		broadcastServer: broadcastServer,
+	}
+	if len(broadcastClients) > 0 {
+		a.listener = make(chan *broadcaster.AdvisoryMessage, 10)
+		for _, client := range broadcastClients {
+			client.AdvisoryListener = a.listener
+		}
+	}
+	return a, nil
+}
+
+// computeFrom has the advisories computed from this node's view of L1, as its sequencer's.
+func (a *FeedAdvisories) computeFrom(inboxReader *InboxReader, streamer *TransactionStreamer) {
+	a.inboxReader = inboxReader
+	a.streamer = streamer
+}
+
+// Latest returns the latest advisories, or nil if there have been none.
+func (a *FeedAdvisories) Latest() *broadcaster.AdvisoryMessage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.latest
+}
+
+// update records the advisories and passes them on to feed clients, if they've changed.
+func (a *FeedAdvisories) update(advisory *broadcaster.AdvisoryMessage) {
+	a.mutex.Lock()
+	changed := a.latest == nil || !reflect.DeepEqual(a.latest.Advisories, advisory.Advisories)
+	a.latest = advisory
+	a.mutex.Unlock()
+	if changed && a.broadcastServer != nil {
+		a.broadcastServer.Advise(advisory)
+	}
+}
+
+func (a *FeedAdvisories) compute(ctx context.Context) (*broadcaster.AdvisoryMessage, error) {
+	now := time.Now()
+	advisories := []broadcaster.Advisory{}
+	header, err := a.inboxReader.l1Reader.LastHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maxTimeVariation, err := a.inboxReader.sequencerInbox.con.MaxTimeVariation(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	delayBlocks := maxTimeVariation.DelayBlocks.Uint64()
+	delaySeconds := maxTimeVariation.DelaySeconds.Uint64()
+
+	tracker := a.inboxReader.tracker
+	batchCount, err := tracker.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	var postedCount arbutil.MessageIndex
+	if batchCount > 0 {
+		postedCount, err = tracker.GetBatchMessageCount(batchCount - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	msgCount, err := a.streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	if msgCount > postedCount {
+		// The oldest unposted message must be posted before its L1 block and timestamp fall out of the max delay
+		oldest, err := a.streamer.GetMessage(postedCount)
+		if err != nil {
+			return nil, err
+		}
+		deadline := oldest.Message.Header.Timestamp + delaySeconds
+		advisories = append(advisories, broadcaster.Advisory{
+			Kind:            AdvisoryBatchPostingDeadline,
+			Severity:        deadlineSeverity(deadline, header.Time, a.config.DeadlineNotice),
+			Message:         fmt.Sprintf("%v sequenced messages not yet posted to L1 must be posted before the sequencer inbox max delay", msgCount-postedCount),
+			Deadline:        deadline,
+			DeadlineL1Block: oldest.Message.Header.BlockNumber + delayBlocks,
+		})
+	}
+
+	if msgCount > 0 {
+		last, err := a.streamer.GetMessage(msgCount - 1)
+		if err != nil {
+			return nil, err
+		}
+		delayedCount, err := tracker.GetDelayedCount()
+		if err != nil {
+			return nil, err
+		}
+		if delayedCount > last.DelayedMessagesRead {
+			// Once the oldest unsequenced delayed message is past the delay window, anyone can force include it
+			oldest, err := tracker.GetDelayedMessage(last.DelayedMessagesRead)
+			if err != nil {
+				return nil, err
+			}
+			deadline := oldest.Header.Timestamp + delaySeconds
+			advisories = append(advisories, broadcaster.Advisory{
+				Kind:            AdvisoryForceInclusion,
+				Severity:        deadlineSeverity(deadline, header.Time, a.config.DeadlineNotice),
+				Message:         fmt.Sprintf("%v delayed messages not yet sequenced can be force included after the deadline", delayedCount-last.DelayedMessagesRead),
+				Deadline:        deadline,
+				DeadlineL1Block: oldest.Header.BlockNumber + delayBlocks,
+			})
+		}
+	}
+
+	advisories = append(advisories, maintenanceAdvisories(a.maintenance, now, a.config.MaintenanceNotice)...)
+	return &broadcaster.AdvisoryMessage{
+		Timestamp:  uint64(now.Unix()),
+		Advisories: advisories,
+	}, nil
+}
+
+func (a *FeedAdvisories) Start(ctxIn context.Context) {
+	a.StopWaiter.Start(ctxIn)
+	if a.config.Enable && a.inboxReader != nil {
+		a.CallIteratively(func(ctx context.Context) time.Duration {
+			advisory, err := a.compute(ctx)
+			if err != nil {
+				log.Warn("failed to compute feed advisories", "err", err)
+			} else {
+				a.update(advisory)
+			}
+			return a.config.Interval
+		})
+	}
+	if a.listener != nil {
+		a.LaunchThread(func(ctx context.Context) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case advisory := <-a.listener:
+					a.update(advisory)
+				}
+			}
+		})
+	}
+}
+
+// ArbAdvisoryAPI serves the sequencer's latest advisories, as computed or received from the feed.
+type ArbAdvisoryAPI struct {
+	advisories *FeedAdvisories
+}
+
+func (a *ArbAdvisoryAPI) SequencerAdvisories(ctx context.Context) (*broadcaster.AdvisoryMessage, error) {
+	latest := a.advisories.Latest()
+	if latest == nil {
+		return &broadcaster.AdvisoryMessage{Advisories: []broadcaster.Advisory{}}, nil
+	}
+	return latest, nil
+}
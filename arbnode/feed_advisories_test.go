@@ -0,0 +1,52 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+func TestMaintenanceAdvisories(t *testing.T) {
+	windows, err := parseMaintenanceWindows([]string{
+		"2022-10-01T00:00:00Z/2022-10-01T02:00:00Z/node upgrade",
+		"2022-11-01T00:00:00Z/2022-11-01T01:00:00Z",
+	})
+	Require(t, err)
+	if windows[0].description != "node upgrade" || windows[1].description == "" {
+		Fail(t, "unexpected descriptions", windows)
+	}
+
+	upcoming := maintenanceAdvisories(windows, time.Date(2022, 9, 30, 12, 0, 0, 0, time.UTC), 24*time.Hour)
+	if len(upcoming) != 1 || upcoming[0].Severity != broadcaster.AdvisoryInfo || upcoming[0].Until != uint64(windows[0].end.Unix()) {
+		Fail(t, "unexpected advisories ahead of maintenance", upcoming)
+	}
+	ongoing := maintenanceAdvisories(windows, time.Date(2022, 10, 1, 1, 0, 0, 0, time.UTC), 24*time.Hour)
+	if len(ongoing) != 1 || ongoing[0].Severity != broadcaster.AdvisoryWarning {
+		Fail(t, "unexpected advisories during maintenance", ongoing)
+	}
+	if over := maintenanceAdvisories(windows, time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC), 24*time.Hour); len(over) != 0 {
+		Fail(t, "advised of finished maintenance", over)
+	}
+
+	for _, invalid := range []string{"2022-10-01T00:00:00Z", "2022-10-01T02:00:00Z/2022-10-01T00:00:00Z", "yesterday/today"} {
+		if _, err := parseMaintenanceWindows([]string{invalid}); err == nil {
+			Fail(t, "invalid maintenance window accepted", invalid)
+		}
+	}
+}
+
+func TestDeadlineSeverity(t *testing.T) {
+	if severity := deadlineSeverity(10000, 1000, time.Hour); severity != broadcaster.AdvisoryInfo {
+		Fail(t, "distant deadline has severity", severity)
+	}
+	if severity := deadlineSeverity(4000, 1000, time.Hour); severity != broadcaster.AdvisoryWarning {
+		Fail(t, "close deadline has severity", severity)
+	}
+	if severity := deadlineSeverity(1000, 1000, time.Hour); severity != broadcaster.AdvisoryCritical {
+		Fail(t, "reached deadline has severity", severity)
+	}
+}
@@ -5,15 +5,26 @@ package arbnode
 
 import (
 	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/stopwaiter"
 )
 
 type TxForwarder struct {
-	target string
-	client *ethclient.Client
+	target    string
+	rpcClient *rpc.Client
+	client    *ethclient.Client
 }
 
 func NewForwarder(target string) *TxForwarder {
@@ -22,23 +33,32 @@ func NewForwarder(target string) *TxForwarder {
 	}
 }
 
-func (f *TxForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (f *TxForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction, options *ConditionalOptions) error {
 	if f.client == nil {
 		return errors.New("sequencer temporarily unavailable")
 	}
-	return f.client.SendTransaction(ctx, tx)
+	if options == nil {
+		return f.client.SendTransaction(ctx, tx)
+	}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return f.rpcClient.CallContext(ctx, nil, "eth_sendRawTransactionConditional", hexutil.Bytes(data), options)
 }
 
 func (f *TxForwarder) Initialize(ctx context.Context) error {
 	if f.target == "" {
+		f.rpcClient = nil
 		f.client = nil
 		return nil
 	}
-	client, err := ethclient.DialContext(ctx, f.target)
+	rpcClient, err := rpc.DialContext(ctx, f.target)
 	if err != nil {
 		return err
 	}
-	f.client = client
+	f.rpcClient = rpcClient
+	f.client = ethclient.NewClient(rpcClient)
 	return nil
 }
 
@@ -54,7 +74,7 @@ func NewTxDropper() *TxDropper {
 	return &TxDropper{}
 }
 
-func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transaction, options *ConditionalOptions) error {
 	return errors.New("transactions not supported by this endpoint")
 }
 
@@ -63,3 +83,152 @@ func (f *TxDropper) Initialize(ctx context.Context) error { return nil }
 func (f *TxDropper) Start(ctx context.Context) error { return nil }
 
 func (f *TxDropper) StopAndWait() {}
+
+type ForwarderConfig struct {
+	SecondaryTargets    []string      `koanf:"secondary-targets"`
+	HealthCheckInterval time.Duration `koanf:"health-check-interval"`
+	HealthCheckTimeout  time.Duration `koanf:"health-check-timeout"`
+	MaxAttempts         int           `koanf:"max-attempts"`
+}
+
+func ForwarderConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".secondary-targets", DefaultForwarderConfig.SecondaryTargets, "further forwarding target URLs, used alongside forwarding-target by health and latency")
+	f.Duration(prefix+".health-check-interval", DefaultForwarderConfig.HealthCheckInterval, "how often to check the health and latency of each forwarding target, if there are several")
+	f.Duration(prefix+".health-check-timeout", DefaultForwarderConfig.HealthCheckTimeout, "how long a forwarding target may take to answer a health check before it's unhealthy")
+	f.Int(prefix+".max-attempts", DefaultForwarderConfig.MaxAttempts, "most forwarding targets to try a transaction against when they fail to answer")
+}
+
+var DefaultForwarderConfig = ForwarderConfig{
+	SecondaryTargets:    []string{},
+	HealthCheckInterval: 5 * time.Second,
+	HealthCheckTimeout:  2 * time.Second,
+	MaxAttempts:         2,
+}
+
+type forwardingTarget struct {
+	mutex     sync.RWMutex // held for writing while the forwarder connects
+	forwarder *TxForwarder
+	healthy   int32 // atomic
+	latency   int64 // atomic, a moving average of health check round trips in nanoseconds
+}
+
+func (t *forwardingTarget) isHealthy() bool {
+	return atomic.LoadInt32(&t.healthy) != 0
+}
+
+func (t *forwardingTarget) setHealthy(healthy bool) {
+	var value int32
+	if healthy {
+		value = 1
+	}
+	if atomic.SwapInt32(&t.healthy, value) != value {
+		log.Info("forwarding target health changed", "target", t.forwarder.target, "healthy", healthy)
+	}
+}
+
+func (t *forwardingTarget) recordLatency(latency time.Duration) {
+	previous := atomic.LoadInt64(&t.latency)
+	if previous == 0 {
+		atomic.StoreInt64(&t.latency, int64(latency))
+	} else {
+		atomic.StoreInt64(&t.latency, (previous*3+int64(latency))/4)
+	}
+}
+
+// RoutedForwarder forwards transactions to one of several targets, preferring healthy targets with the lowest
+// latency, and retrying against the next when a target fails to answer. Targets which answer with an error,
+// such as a rejection of the transaction, aren't retried, as the others would only reject it too.
+type RoutedForwarder struct {
+	stopwaiter.StopWaiter
+	config  *ForwarderConfig
+	targets []*forwardingTarget
+}
+
+func NewRoutedForwarder(targets []string, config *ForwarderConfig) *RoutedForwarder {
+	forwarder := &RoutedForwarder{config: config}
+	for _, target := range targets {
+		forwarder.targets = append(forwarder.targets, &forwardingTarget{forwarder: NewForwarder(target)})
+	}
+	return forwarder
+}
+
+// candidates returns the targets in the order to try them: healthy ones by latency, then the rest.
+func (f *RoutedForwarder) candidates() []*forwardingTarget {
+	candidates := make([]*forwardingTarget, len(f.targets))
+	copy(candidates, f.targets)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].isHealthy() != candidates[j].isHealthy() {
+			return candidates[i].isHealthy()
+		}
+		return atomic.LoadInt64(&candidates[i].latency) < atomic.LoadInt64(&candidates[j].latency)
+	})
+	return candidates
+}
+
+// answered returns whether the target answered, so the error comes from it rather than reaching it.
+func answered(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
+
+func (f *RoutedForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction, options *ConditionalOptions) error {
+	var err error
+	for attempt, target := range f.candidates() {
+		if attempt > 0 && attempt >= f.config.MaxAttempts {
+			break
+		}
+		target.mutex.RLock()
+		err = target.forwarder.PublishTransaction(ctx, tx, options)
+		target.mutex.RUnlock()
+		if err == nil || answered(err) || ctx.Err() != nil {
+			return err
+		}
+		log.Warn("failed to forward transaction", "target", target.forwarder.target, "tx", tx.Hash(), "err", err)
+		target.setHealthy(false)
+	}
+	return err
+}
+
+func (f *RoutedForwarder) checkHealth(ctx context.Context, target *forwardingTarget) {
+	ctx, cancel := context.WithTimeout(ctx, f.config.HealthCheckTimeout)
+	defer cancel()
+	target.mutex.Lock()
+	rpcClient := target.forwarder.rpcClient
+	if rpcClient == nil {
+		if err := target.forwarder.Initialize(ctx); err != nil {
+			target.mutex.Unlock()
+			log.Debug("failed to connect to forwarding target", "target", target.forwarder.target, "err", err)
+			target.setHealthy(false)
+			return
+		}
+		rpcClient = target.forwarder.rpcClient
+	}
+	target.mutex.Unlock()
+	start := time.Now()
+	var chainId hexutil.Big
+	if err := rpcClient.CallContext(ctx, &chainId, "eth_chainId"); err != nil {
+		log.Debug("forwarding target health check failed", "target", target.forwarder.target, "err", err)
+		target.setHealthy(false)
+		return
+	}
+	target.recordLatency(time.Since(start))
+	target.setHealthy(true)
+}
+
+func (f *RoutedForwarder) Initialize(ctx context.Context) error {
+	for _, target := range f.targets {
+		f.checkHealth(ctx, target)
+	}
+	return nil
+}
+
+func (f *RoutedForwarder) Start(ctxIn context.Context) error {
+	f.StopWaiter.Start(ctxIn)
+	f.CallIteratively(func(ctx context.Context) time.Duration {
+		for _, target := range f.targets {
+			f.checkHealth(ctx, target)
+		}
+		return f.config.HealthCheckInterval
+	})
+	return nil
+}
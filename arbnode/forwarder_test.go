@@ -0,0 +1,92 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type forwardingTargetService struct {
+	received int32
+	reject   bool
+}
+
+func (s *forwardingTargetService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(412346))
+}
+
+func (s *forwardingTargetService) SendRawTransaction(ctx context.Context, data hexutil.Bytes) (common.Hash, error) {
+	atomic.AddInt32(&s.received, 1)
+	if s.reject {
+		return common.Hash{}, errors.New("nonce too low")
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(data); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+func startForwardingTarget(t *testing.T, service *forwardingTargetService) *httptest.Server {
+	server := rpc.NewServer()
+	Require(t, server.RegisterName("eth", service))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+func TestRoutedForwarder(t *testing.T) {
+	ctx := context.Background()
+	down := startForwardingTarget(t, &forwardingTargetService{})
+	down.Close()
+	up := &forwardingTargetService{}
+	upServer := startForwardingTarget(t, up)
+	rejecting := &forwardingTargetService{reject: true}
+	rejectingServer := startForwardingTarget(t, rejecting)
+
+	config := DefaultForwarderConfig
+	forwarder := NewRoutedForwarder([]string{down.URL, upServer.URL}, &config)
+	Require(t, forwarder.Initialize(ctx))
+	if forwarder.targets[0].isHealthy() || !forwarder.targets[1].isHealthy() {
+		Fail(t, "unexpected target health")
+	}
+	if candidates := forwarder.candidates(); candidates[0] != forwarder.targets[1] {
+		Fail(t, "unhealthy target preferred")
+	}
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1), Gas: 21000})
+	Require(t, forwarder.PublishTransaction(ctx, tx, nil))
+	if atomic.LoadInt32(&up.received) != 1 {
+		Fail(t, "transaction not forwarded to the healthy target")
+	}
+
+	// if the healthy target fails, the other is retried
+	forwarder.targets[0].setHealthy(true)
+	forwarder.targets[1].setHealthy(false)
+	Require(t, forwarder.PublishTransaction(ctx, tx, nil))
+	if atomic.LoadInt32(&up.received) != 2 || forwarder.targets[0].isHealthy() {
+		Fail(t, "transaction not retried against the secondary target")
+	}
+
+	// a target rejecting the transaction isn't retried against
+	forwarder = NewRoutedForwarder([]string{rejectingServer.URL, upServer.URL}, &config)
+	Require(t, forwarder.Initialize(ctx))
+	forwarder.targets[1].setHealthy(false)
+	if err := forwarder.PublishTransaction(ctx, tx, nil); err == nil {
+		Fail(t, "rejection not returned")
+	}
+	if atomic.LoadInt32(&rejecting.received) != 1 || atomic.LoadInt32(&up.received) != 2 {
+		Fail(t, "rejected transaction was retried")
+	}
+}
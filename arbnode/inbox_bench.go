@@ -0,0 +1,377 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/l1pricing"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+	"github.com/offchainlabs/nitro/statetransfer"
+)
+
+// InboxBenchConfig shapes the synthetic L1 the inbox benchmark ingests: each L1 block carries some delayed
+// messages and one sequencer batch, which reads them along with the batch's own messages.
+type InboxBenchConfig struct {
+	L1Blocks         uint64 `koanf:"l1-blocks"`
+	MessagesPerBatch uint64 `koanf:"messages-per-batch"`
+	DelayedPerBatch  uint64 `koanf:"delayed-per-batch"`
+	MessageSize      int    `koanf:"message-size"`
+	BlocksPerRead    uint64 `koanf:"blocks-per-read"`
+	Compression      string `koanf:"compression"`
+	CompressionLevel int    `koanf:"compression-level"`
+	MaxBatchSize     int    `koanf:"max-batch-size"`
+	Execute          bool   `koanf:"execute"`
+	DataDir          string `koanf:"data-dir"`
+	Seed             int64  `koanf:"seed"`
+}
+
+func InboxBenchConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Uint64(prefix+".l1-blocks", DefaultInboxBenchConfig.L1Blocks, "number of synthetic L1 blocks, each with one sequencer batch")
+	f.Uint64(prefix+".messages-per-batch", DefaultInboxBenchConfig.MessagesPerBatch, "number of L2 messages in each sequencer batch")
+	f.Uint64(prefix+".delayed-per-batch", DefaultInboxBenchConfig.DelayedPerBatch, "number of delayed messages in each L1 block, read by its sequencer batch")
+	f.Int(prefix+".message-size", DefaultInboxBenchConfig.MessageSize, "bytes of random calldata in each L2 message")
+	f.Uint64(prefix+".blocks-per-read", DefaultInboxBenchConfig.BlocksPerRead, "number of L1 blocks ingested at once, as the inbox reader would read them")
+	f.String(prefix+".compression", DefaultInboxBenchConfig.Compression, "batch compression algorithm (brotli or zstd)")
+	f.Int(prefix+".compression-level", DefaultInboxBenchConfig.CompressionLevel, "batch compression level")
+	f.Int(prefix+".max-batch-size", DefaultInboxBenchConfig.MaxBatchSize, "maximum compressed size of a sequencer batch")
+	f.Bool(prefix+".execute", DefaultInboxBenchConfig.Execute, "also execute the ingested messages into L2 blocks")
+	f.String(prefix+".data-dir", DefaultInboxBenchConfig.DataDir, "directory to store the databases in (in memory if empty)")
+	f.Int64(prefix+".seed", DefaultInboxBenchConfig.Seed, "seed of the synthetic messages")
+}
+
+var DefaultInboxBenchConfig = InboxBenchConfig{
+	L1Blocks:         1000,
+	MessagesPerBatch: 100,
+	DelayedPerBatch:  2,
+	MessageSize:      100,
+	BlocksPerRead:    100,
+	Compression:      DefaultBatchPosterConfig.Compression,
+	CompressionLevel: DefaultBatchPosterConfig.CompressionLevel,
+	MaxBatchSize:     DefaultBatchPosterConfig.MaxBatchSize,
+	Execute:          false,
+	DataDir:          "",
+	Seed:             1,
+}
+
+// InboxBenchResult reports how long each stage of the pipeline took, with the tracker's throughput measured
+// over the ingestion alone, as generating the synthetic batches is dominated by their compression.
+type InboxBenchResult struct {
+	Config              InboxBenchConfig `json:"config"`
+	Batches             uint64           `json:"batches"`
+	Messages            uint64           `json:"messages"`
+	DelayedMessages     uint64           `json:"delayedMessages"`
+	BatchBytes          uint64           `json:"batchBytes"`
+	GenerateDuration    string           `json:"generateDuration"`
+	DelayedDuration     string           `json:"delayedDuration"`
+	BatchDuration       string           `json:"batchDuration"`
+	IngestDuration      string           `json:"ingestDuration"`
+	MessagesPerSecond   float64          `json:"messagesPerSecond"`
+	BatchBytesPerSecond float64          `json:"batchBytesPerSecond"`
+	ExecuteDuration     string           `json:"executeDuration,omitempty"`
+	BlocksPerSecond     float64          `json:"blocksPerSecond,omitempty"`
+}
+
+// syntheticL1Block is what the inbox reader would have read from one L1 block.
+type syntheticL1Block struct {
+	delayed []*DelayedInboxMessage
+	batch   *SequencerInboxBatch
+}
+
+type syntheticInbox struct {
+	config     *InboxBenchConfig
+	compressor Compressor
+	rand       *rand.Rand
+	chainId    *big.Int
+
+	delayedCount uint64
+	delayedAcc   common.Hash
+	batchAcc     common.Hash
+	messages     uint64
+	batchBytes   uint64
+}
+
+func (s *syntheticInbox) delayedMessage(l1Block uint64, timestamp uint64) *DelayedInboxMessage {
+	requestId := common.BigToHash(new(big.Int).SetUint64(s.delayedCount))
+	message := &arbos.L1IncomingMessage{
+		Header: &arbos.L1IncomingMessageHeader{
+			Kind:        arbos.L1MessageType_EthDeposit,
+			Poster:      common.BigToAddress(big.NewInt(s.rand.Int63())),
+			BlockNumber: l1Block,
+			Timestamp:   timestamp,
+			RequestId:   &requestId,
+			L1BaseFee:   big.NewInt(params.GWei),
+		},
+	}
+	if s.delayedCount == 0 {
+		// the first delayed message initializes the chain
+		message.Header.Kind = arbos.L1MessageType_Initialize
+		message.Header.L1BaseFee = common.Big0
+		message.L2msg = ethmath.U256Bytes(new(big.Int).Set(s.chainId))
+	} else {
+		to := common.BigToAddress(big.NewInt(s.rand.Int63()))
+		message.L2msg = append(to.Bytes(), ethmath.U256Bytes(big.NewInt(s.rand.Int63n(params.Ether)))...)
+	}
+	delayed := &DelayedInboxMessage{
+		BlockHash:      syntheticBlockHash(l1Block),
+		BeforeInboxAcc: s.delayedAcc,
+		Message:        message,
+	}
+	s.delayedAcc = delayed.AfterInboxAcc()
+	s.delayedCount++
+	return delayed
+}
+
+func (s *syntheticInbox) l2Message(l1Block uint64, timestamp uint64) *arbos.L1IncomingMessage {
+	var to common.Address
+	s.rand.Read(to[:])
+	var l2Message []byte
+	l2Message = append(l2Message, arbos.L2MessageKind_ContractTx)
+	l2Message = append(l2Message, ethmath.U256Bytes(big.NewInt(100000))...)
+	l2Message = append(l2Message, ethmath.U256Bytes(big.NewInt(params.GWei))...)
+	l2Message = append(l2Message, to.Hash().Bytes()...)
+	l2Message = append(l2Message, ethmath.U256Bytes(big.NewInt(s.rand.Int63n(params.Ether)))...)
+	calldata := make([]byte, s.config.MessageSize)
+	s.rand.Read(calldata)
+	l2Message = append(l2Message, calldata...)
+	return &arbos.L1IncomingMessage{
+		Header: &arbos.L1IncomingMessageHeader{
+			Kind:        arbos.L1MessageType_L2Message,
+			Poster:      l1pricing.BatchPosterAddress,
+			BlockNumber: l1Block,
+			Timestamp:   timestamp,
+		},
+		L2msg: l2Message,
+	}
+}
+
+func syntheticBlockHash(l1Block uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("synthetic L1 block"), new(big.Int).SetUint64(l1Block).Bytes())
+}
+
+// block generates the next L1 block, with its delayed messages and a batch reading them.
+func (s *syntheticInbox) block(seqNum uint64) (*syntheticL1Block, error) {
+	l1Block := seqNum + 1
+	timestamp := uint64(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC).Unix()) + l1Block*12
+	block := &syntheticL1Block{}
+	segments, err := newBatchSegments(s.delayedCount, s.config.MaxBatchSize, s.compressor)
+	if err != nil {
+		return nil, err
+	}
+	delayedCount := s.config.DelayedPerBatch
+	if seqNum == 0 {
+		delayedCount++
+	}
+	for i := uint64(0); i < delayedCount; i++ {
+		delayed := s.delayedMessage(l1Block, timestamp)
+		block.delayed = append(block.delayed, delayed)
+		success, err := segments.AddMessage(&arbstate.MessageWithMetadata{
+			Message:             delayed.Message,
+			DelayedMessagesRead: s.delayedCount,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !success {
+			return nil, errors.New("synthetic batch overflows the max batch size; lower the messages or delayed messages per batch")
+		}
+	}
+	for i := uint64(0); i < s.config.MessagesPerBatch; i++ {
+		success, err := segments.AddMessage(&arbstate.MessageWithMetadata{
+			Message:             s.l2Message(l1Block, timestamp),
+			DelayedMessagesRead: s.delayedCount,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !success {
+			return nil, errors.New("synthetic batch overflows the max batch size; lower the messages per batch or their size")
+		}
+	}
+	data, err := segments.CloseAndGetBytes()
+	if err != nil {
+		return nil, err
+	}
+	batch := &SequencerInboxBatch{
+		BlockHash:         syntheticBlockHash(l1Block),
+		BlockNumber:       l1Block,
+		SequenceNumber:    seqNum,
+		BeforeInboxAcc:    s.batchAcc,
+		AfterInboxAcc:     crypto.Keccak256Hash(s.batchAcc[:], crypto.Keccak256(data)),
+		AfterDelayedAcc:   s.delayedAcc,
+		AfterDelayedCount: s.delayedCount,
+		TimeBounds: bridgegen.ISequencerInboxTimeBounds{
+			MinTimestamp:   0,
+			MaxTimestamp:   math.MaxUint64,
+			MinBlockNumber: 0,
+			MaxBlockNumber: math.MaxUint64,
+		},
+		dataLocation: batchDataTxInput,
+	}
+	batch.setData(data)
+	block.batch = batch
+	s.batchAcc = batch.AfterInboxAcc
+	s.messages += delayedCount + s.config.MessagesPerBatch
+	s.batchBytes += uint64(len(data))
+	return block, nil
+}
+
+func openBenchDatabase(dataDir string, name string) (ethdb.Database, error) {
+	if dataDir == "" {
+		return rawdb.NewMemoryDatabase(), nil
+	}
+	return rawdb.NewLevelDBDatabase(filepath.Join(dataDir, name), 2048, 512, "", false)
+}
+
+func perSecond(count uint64, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(count) / duration.Seconds()
+}
+
+// BenchInbox ingests synthetic delayed messages and sequencer batches through the inbox tracker and transaction
+// streamer, as the inbox reader would read them from L1, measuring the throughput of each stage.
+func BenchInbox(ctx context.Context, config *InboxBenchConfig) (*InboxBenchResult, error) {
+	if config.L1Blocks == 0 || config.BlocksPerRead == 0 {
+		return nil, errors.New("the number of L1 blocks and of blocks per read must be positive")
+	}
+	compressor, err := NewCompressor(config.Compression, config.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	chainConfig := params.ArbitrumDevTestChainConfig()
+	chainDb, err := openBenchDatabase(config.DataDir, "l2chaindata")
+	if err != nil {
+		return nil, err
+	}
+	defer chainDb.Close()
+	arbDb, err := openBenchDatabase(config.DataDir, "arbitrumdata")
+	if err != nil {
+		return nil, err
+	}
+	defer arbDb.Close()
+	initReader := statetransfer.NewMemoryInitDataReader(&statetransfer.ArbosInitializationInfo{})
+	bc, err := WriteOrTestBlockChain(chainDb, nil, initReader, chainConfig, ConfigDefaultL2Test(), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer bc.Stop()
+	streamer, err := NewTransactionStreamer(arbDb, bc, nil, &DefaultTransactionStreamerConfig)
+	if err != nil {
+		return nil, err
+	}
+	tracker, err := NewInboxTracker(arbDb, streamer, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := tracker.Initialize(); err != nil {
+		return nil, err
+	}
+	if count, err := tracker.GetBatchCount(); err != nil || count > 0 {
+		return nil, fmt.Errorf("inbox benchmark needs an empty data directory (found %v batches, err %v)", count, err)
+	}
+
+	result := &InboxBenchResult{Config: *config}
+	synthetic := &syntheticInbox{
+		config:     config,
+		compressor: compressor,
+		rand:       rand.New(rand.NewSource(config.Seed)),
+		chainId:    chainConfig.ChainID,
+	}
+	start := time.Now()
+	blocks := make([]*syntheticL1Block, 0, config.L1Blocks)
+	for seqNum := uint64(0); seqNum < config.L1Blocks; seqNum++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		block, err := synthetic.block(seqNum)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	result.GenerateDuration = time.Since(start).String()
+	result.Batches = config.L1Blocks
+	result.Messages = synthetic.messages
+	result.DelayedMessages = synthetic.delayedCount
+	result.BatchBytes = synthetic.batchBytes
+
+	if config.Execute {
+		streamer.Start(ctx)
+		defer streamer.StopAndWait()
+	}
+	var delayedDuration, batchDuration time.Duration
+	ingestStart := time.Now()
+	for len(blocks) > 0 {
+		read := blocks
+		if uint64(len(read)) > config.BlocksPerRead {
+			read = read[:config.BlocksPerRead]
+		}
+		blocks = blocks[len(read):]
+		var delayed []*DelayedInboxMessage
+		batches := make([]*SequencerInboxBatch, 0, len(read))
+		for _, block := range read {
+			delayed = append(delayed, block.delayed...)
+			batches = append(batches, block.batch)
+		}
+		start := time.Now()
+		if err := tracker.AddDelayedMessages(delayed); err != nil {
+			return nil, fmt.Errorf("adding delayed messages: %w", err)
+		}
+		delayedDuration += time.Since(start)
+		start = time.Now()
+		if err := tracker.AddSequencerBatches(ctx, nil, batches); err != nil {
+			return nil, fmt.Errorf("adding sequencer batches: %w", err)
+		}
+		batchDuration += time.Since(start)
+	}
+	ingestDuration := time.Since(ingestStart)
+	result.DelayedDuration = delayedDuration.String()
+	result.BatchDuration = batchDuration.String()
+	result.IngestDuration = ingestDuration.String()
+	result.MessagesPerSecond = perSecond(result.Messages, ingestDuration)
+	result.BatchBytesPerSecond = perSecond(result.BatchBytes, ingestDuration)
+
+	msgCount, err := streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(msgCount) != result.Messages {
+		return nil, fmt.Errorf("streamer has %v messages after ingesting %v", msgCount, result.Messages)
+	}
+	if config.Execute {
+		lastBlock := uint64(arbutil.MessageCountToBlockNumber(msgCount, 0))
+		for bc.CurrentBlock().NumberU64() < lastBlock {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		// execution overlaps ingestion, as it does in a node
+		executeDuration := time.Since(ingestStart)
+		result.ExecuteDuration = executeDuration.String()
+		result.BlocksPerSecond = perSecond(lastBlock, executeDuration)
+	}
+	return result, nil
+}
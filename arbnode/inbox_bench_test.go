@@ -0,0 +1,30 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBenchInbox(t *testing.T) {
+	config := DefaultInboxBenchConfig
+	config.L1Blocks = 6
+	config.MessagesPerBatch = 10
+	config.BlocksPerRead = 4
+	config.Execute = true
+	result, err := BenchInbox(context.Background(), &config)
+	Require(t, err)
+	// the first batch also reads the init message
+	expectedDelayed := config.L1Blocks*config.DelayedPerBatch + 1
+	if result.Batches != config.L1Blocks || result.DelayedMessages != expectedDelayed {
+		Fail(t, "unexpected batches or delayed messages", result.Batches, result.DelayedMessages)
+	}
+	if result.Messages != expectedDelayed+config.L1Blocks*config.MessagesPerBatch {
+		Fail(t, "unexpected messages", result.Messages)
+	}
+	if result.BatchBytes == 0 || result.MessagesPerSecond <= 0 || result.ExecuteDuration == "" {
+		Fail(t, "incomplete result", result)
+	}
+}
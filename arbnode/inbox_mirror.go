@@ -0,0 +1,407 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/arbmath"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+type InboxMirrorConfig struct {
+	Enable         bool          `koanf:"enable"`
+	WebhookURL     string        `koanf:"webhook-url"`
+	MaxBatchSize   uint64        `koanf:"max-batch-size"`
+	PollInterval   time.Duration `koanf:"poll-interval"`
+	RequestTimeout time.Duration `koanf:"request-timeout"`
+	MaxRetryDelay  time.Duration `koanf:"max-retry-delay"`
+	ReorgDepth     uint64        `koanf:"reorg-depth"`
+}
+
+func InboxMirrorConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultInboxMirrorConfig.Enable, "mirror every ingested delayed message and sequencer batch to an external sink")
+	f.String(prefix+".webhook-url", DefaultInboxMirrorConfig.WebhookURL, "URL to POST mirrored inbox messages to as a JSON array")
+	f.Uint64(prefix+".max-batch-size", DefaultInboxMirrorConfig.MaxBatchSize, "maximum number of inbox messages to deliver to the sink at once")
+	f.Duration(prefix+".poll-interval", DefaultInboxMirrorConfig.PollInterval, "how often to check the inbox for messages to mirror")
+	f.Duration(prefix+".request-timeout", DefaultInboxMirrorConfig.RequestTimeout, "timeout for a single delivery to the sink")
+	f.Duration(prefix+".max-retry-delay", DefaultInboxMirrorConfig.MaxRetryDelay, "maximum delay between retries of a failed delivery")
+	f.Uint64(prefix+".reorg-depth", DefaultInboxMirrorConfig.ReorgDepth, "number of the latest mirrored delayed messages and batches to keep accumulators of to detect reorgs replacing them (0 = keep all)")
+}
+
+var DefaultInboxMirrorConfig = InboxMirrorConfig{
+	Enable:         false,
+	WebhookURL:     "",
+	MaxBatchSize:   100,
+	PollInterval:   time.Second,
+	RequestTimeout: 10 * time.Second,
+	MaxRetryDelay:  time.Minute,
+	ReorgDepth:     1000,
+}
+
+const (
+	MirroredMessageKindDelayed = "delayed"
+	MirroredMessageKindBatch   = "batch"
+	MirroredMessageKindReorg   = "reorg"
+)
+
+// MirroredMessage is a delayed message or batch, or a reorg. A reorg invalidates the delayed messages and batches
+// mirrored past its DelayedCount and BatchCount, which are then mirrored again as they're now in the inbox.
+type MirroredMessage struct {
+	Kind           string        `json:"kind"`
+	SequenceNumber uint64        `json:"sequenceNumber"`
+	Accumulator    common.Hash   `json:"accumulator"`
+	L1Block        uint64        `json:"l1Block"`
+	MessageCount   uint64        `json:"messageCount"`
+	DelayedCount   uint64        `json:"delayedCount"`
+	BatchCount     uint64        `json:"batchCount"`
+	Data           hexutil.Bytes `json:"data,omitempty"`
+}
+
+// InboxMirrorSink receives mirrored inbox messages. Send must only return nil once the messages
+// are durably accepted, as the mirror advances its cursor afterwards. Messages may be delivered
+// more than once, so sinks should deduplicate on kind and sequence number.
+type InboxMirrorSink interface {
+	Send(ctx context.Context, messages []*MirroredMessage) error
+}
+
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Send(ctx context.Context, messages []*MirroredMessage) error {
+	body, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("webhook returned status %v: %v", response.StatusCode, string(responseBody))
+	}
+	return nil
+}
+
+type inboxMirrorCursor struct {
+	DelayedCount uint64
+	BatchCount   uint64
+}
+
+// inboxMirrorSource is the inbox the mirror reads from, an inboxReaderSource outside of tests.
+type inboxMirrorSource interface {
+	GetDelayedCount() (uint64, error)
+	GetBatchCount() (uint64, error)
+	GetDelayedAcc(seqNum uint64) (common.Hash, error)
+	GetBatchAcc(seqNum uint64) (common.Hash, error)
+	GetBatchMetadata(seqNum uint64) (BatchMetadata, error)
+	getDelayedMessageBytesAndAccumulator(seqNum uint64) ([]byte, common.Hash, error)
+	GetSequencerMessageBytes(ctx context.Context, seqNum uint64) ([]byte, error)
+}
+
+type inboxReaderSource struct {
+	*InboxTracker
+	*InboxReader
+}
+
+// InboxMirror streams the contents of the InboxTracker to a sink. It keeps a persistent cursor
+// which is only advanced after the sink acknowledges a delivery, so delivery is at-least-once.
+// A slow sink only makes the mirror lag behind; it never blocks inbox processing.
+type InboxMirror struct {
+	stopwaiter.StopWaiter
+	db         ethdb.Database
+	source     inboxMirrorSource
+	sink       InboxMirrorSink
+	config     *InboxMirrorConfig
+	retryDelay time.Duration
+}
+
+func NewInboxMirror(db ethdb.Database, inboxReader *InboxReader, sink InboxMirrorSink, config *InboxMirrorConfig) (*InboxMirror, error) {
+	if sink == nil {
+		if config.WebhookURL == "" {
+			return nil, errors.New("inbox mirror enabled but no sink configured")
+		}
+		sink = NewWebhookSink(config.WebhookURL, config.RequestTimeout)
+	}
+	if config.MaxBatchSize == 0 {
+		return nil, errors.New("inbox mirror max-batch-size must be positive")
+	}
+	return newInboxMirror(db, inboxReaderSource{inboxReader.Tracker(), inboxReader}, sink, config), nil
+}
+
+func newInboxMirror(db ethdb.Database, source inboxMirrorSource, sink InboxMirrorSink, config *InboxMirrorConfig) *InboxMirror {
+	return &InboxMirror{
+		db:     db,
+		source: source,
+		sink:   sink,
+		config: config,
+	}
+}
+
+func (m *InboxMirror) readCursor() (inboxMirrorCursor, error) {
+	var cursor inboxMirrorCursor
+	hasCursor, err := m.db.Has(inboxMirrorCursorKey)
+	if err != nil || !hasCursor {
+		return cursor, err
+	}
+	data, err := m.db.Get(inboxMirrorCursorKey)
+	if err != nil {
+		return cursor, err
+	}
+	err = rlp.DecodeBytes(data, &cursor)
+	return cursor, err
+}
+
+func (m *InboxMirror) writeCursor(batch ethdb.KeyValueWriter, cursor inboxMirrorCursor) error {
+	data, err := rlp.EncodeToBytes(cursor)
+	if err != nil {
+		return err
+	}
+	return batch.Put(inboxMirrorCursorKey, data)
+}
+
+// matchingCount walks back from count to the number of mirrored messages which are still in the inbox,
+// comparing the accumulators they were mirrored with to the inbox's. As accumulators commit to every
+// message before them, this catches reorgs which replace messages without changing the count.
+func (m *InboxMirror) matchingCount(prefix []byte, count uint64, inboxAcc func(uint64) (common.Hash, error)) (uint64, error) {
+	for count > 0 {
+		key := dbKey(prefix, count-1)
+		hasAcc, err := m.db.Has(key)
+		if err != nil {
+			return 0, err
+		}
+		if !hasAcc {
+			// Mirrored before accumulators were recorded, or pruned as deeper than any reorg, so assumed to match
+			return count, nil
+		}
+		mirrored, err := m.db.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		acc, err := inboxAcc(count - 1)
+		if err != nil {
+			return 0, err
+		}
+		if acc == common.BytesToHash(mirrored) {
+			return count, nil
+		}
+		count--
+	}
+	return 0, nil
+}
+
+// pruneAccumulators deletes the mirrored accumulators more than ReorgDepth before count.
+func (m *InboxMirror) pruneAccumulators(batch ethdb.KeyValueWriter, prefix []byte, count uint64) error {
+	if m.config.ReorgDepth == 0 || count <= m.config.ReorgDepth {
+		return nil
+	}
+	limit := dbKey(prefix, count-m.config.ReorgDepth)
+	iter := m.db.NewIterator(prefix, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if bytes.Compare(iter.Key(), limit) >= 0 {
+			break
+		}
+		err := batch.Delete(common.CopyBytes(iter.Key()))
+		if err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// Lag returns how many delayed messages and batches are yet to be mirrored.
+func (m *InboxMirror) Lag() (uint64, uint64, error) {
+	cursor, err := m.readCursor()
+	if err != nil {
+		return 0, 0, err
+	}
+	delayedCount, err := m.source.GetDelayedCount()
+	if err != nil {
+		return 0, 0, err
+	}
+	batchCount, err := m.source.GetBatchCount()
+	if err != nil {
+		return 0, 0, err
+	}
+	var delayedLag, batchLag uint64
+	if delayedCount > cursor.DelayedCount {
+		delayedLag = delayedCount - cursor.DelayedCount
+	}
+	if batchCount > cursor.BatchCount {
+		batchLag = batchCount - cursor.BatchCount
+	}
+	return delayedLag, batchLag, nil
+}
+
+// mirror delivers one chunk of messages, returning true if there may be more to deliver.
+func (m *InboxMirror) mirror(ctx context.Context) (bool, error) {
+	cursor, err := m.readCursor()
+	if err != nil {
+		return false, err
+	}
+	delayedCount, err := m.source.GetDelayedCount()
+	if err != nil {
+		return false, err
+	}
+	batchCount, err := m.source.GetBatchCount()
+	if err != nil {
+		return false, err
+	}
+
+	delayedMatching, err := m.matchingCount(mirroredDelayedPrefix, arbmath.MinUint(cursor.DelayedCount, delayedCount), m.source.GetDelayedAcc)
+	if err != nil {
+		return false, err
+	}
+	batchMatching, err := m.matchingCount(mirroredBatchPrefix, arbmath.MinUint(cursor.BatchCount, batchCount), m.source.GetBatchAcc)
+	if err != nil {
+		return false, err
+	}
+	if delayedMatching < cursor.DelayedCount || batchMatching < cursor.BatchCount {
+		reorg := &MirroredMessage{
+			Kind:         MirroredMessageKindReorg,
+			DelayedCount: delayedMatching,
+			BatchCount:   batchMatching,
+		}
+		err = m.sink.Send(ctx, []*MirroredMessage{reorg})
+		if err != nil {
+			return false, err
+		}
+		log.Warn("inbox mirror rewinding after reorg", "delayedCount", delayedMatching, "batchCount", batchMatching)
+		cursor.DelayedCount = delayedMatching
+		cursor.BatchCount = batchMatching
+		return true, m.writeCursor(m.db, cursor)
+	}
+
+	var messages []*MirroredMessage
+	newCursor := cursor
+	for newCursor.DelayedCount < delayedCount && uint64(len(messages)) < m.config.MaxBatchSize {
+		seqNum := newCursor.DelayedCount
+		data, acc, err := m.source.getDelayedMessageBytesAndAccumulator(seqNum)
+		if err != nil {
+			return false, err
+		}
+		messages = append(messages, &MirroredMessage{
+			Kind:           MirroredMessageKindDelayed,
+			SequenceNumber: seqNum,
+			Accumulator:    acc,
+			Data:           data,
+		})
+		newCursor.DelayedCount++
+	}
+	for newCursor.BatchCount < batchCount && uint64(len(messages)) < m.config.MaxBatchSize {
+		seqNum := newCursor.BatchCount
+		metadata, err := m.source.GetBatchMetadata(seqNum)
+		if err != nil {
+			return false, err
+		}
+		data, err := m.source.GetSequencerMessageBytes(ctx, seqNum)
+		if err != nil {
+			return false, err
+		}
+		messages = append(messages, &MirroredMessage{
+			Kind:           MirroredMessageKindBatch,
+			SequenceNumber: seqNum,
+			Accumulator:    metadata.Accumulator,
+			L1Block:        metadata.L1Block,
+			MessageCount:   uint64(metadata.MessageCount),
+			DelayedCount:   metadata.DelayedMessageCount,
+			Data:           data,
+		})
+		newCursor.BatchCount++
+	}
+	if len(messages) == 0 {
+		return false, nil
+	}
+	err = m.sink.Send(ctx, messages)
+	if err != nil {
+		return false, err
+	}
+	batch := m.db.NewBatch()
+	for _, message := range messages {
+		prefix, count := mirroredDelayedPrefix, newCursor.DelayedCount
+		if message.Kind == MirroredMessageKindBatch {
+			prefix, count = mirroredBatchPrefix, newCursor.BatchCount
+		}
+		if m.config.ReorgDepth != 0 && message.SequenceNumber+m.config.ReorgDepth < count {
+			// Already deeper than any reorg, so it'd only be pruned
+			continue
+		}
+		err = batch.Put(dbKey(prefix, message.SequenceNumber), message.Accumulator.Bytes())
+		if err != nil {
+			return false, err
+		}
+	}
+	err = m.pruneAccumulators(batch, mirroredDelayedPrefix, newCursor.DelayedCount)
+	if err != nil {
+		return false, err
+	}
+	err = m.pruneAccumulators(batch, mirroredBatchPrefix, newCursor.BatchCount)
+	if err != nil {
+		return false, err
+	}
+	err = m.writeCursor(batch, newCursor)
+	if err != nil {
+		return false, err
+	}
+	err = batch.Write()
+	if err != nil {
+		return false, err
+	}
+	return newCursor.DelayedCount < delayedCount || newCursor.BatchCount < batchCount, nil
+}
+
+func (m *InboxMirror) Start(ctxIn context.Context) {
+	m.StopWaiter.Start(ctxIn)
+	m.CallIteratively(func(ctx context.Context) time.Duration {
+		more, err := m.mirror(ctx)
+		if err != nil {
+			if m.retryDelay == 0 {
+				m.retryDelay = m.config.PollInterval
+			} else {
+				m.retryDelay *= 2
+			}
+			if m.retryDelay > m.config.MaxRetryDelay {
+				m.retryDelay = m.config.MaxRetryDelay
+			}
+			log.Warn("error mirroring inbox messages", "err", err, "retryIn", m.retryDelay)
+			return m.retryDelay
+		}
+		m.retryDelay = 0
+		if more {
+			return 0
+		}
+		return m.config.PollInterval
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+type testMirrorSource struct {
+	delayed      [][]byte
+	delayedAccs  []common.Hash
+	batches      [][]byte
+	batchMetas   []BatchMetadata
+	messageCount arbutil.MessageIndex
+}
+
+func (s *testMirrorSource) addDelayed(data []byte) {
+	var prev common.Hash
+	if len(s.delayedAccs) > 0 {
+		prev = s.delayedAccs[len(s.delayedAccs)-1]
+	}
+	s.delayed = append(s.delayed, data)
+	s.delayedAccs = append(s.delayedAccs, crypto.Keccak256Hash(prev[:], data))
+}
+
+func (s *testMirrorSource) addBatch(data []byte) {
+	var prev common.Hash
+	if len(s.batchMetas) > 0 {
+		prev = s.batchMetas[len(s.batchMetas)-1].Accumulator
+	}
+	s.messageCount += 2
+	s.batches = append(s.batches, data)
+	s.batchMetas = append(s.batchMetas, BatchMetadata{
+		Accumulator:         crypto.Keccak256Hash(prev[:], data),
+		MessageCount:        s.messageCount,
+		DelayedMessageCount: uint64(len(s.delayed)),
+		L1Block:             uint64(len(s.batchMetas)),
+	})
+}
+
+func (s *testMirrorSource) reorgBatchesTo(count int) {
+	s.batches = s.batches[:count]
+	s.batchMetas = s.batchMetas[:count]
+	s.messageCount = arbutil.MessageIndex(count * 2)
+}
+
+func (s *testMirrorSource) GetDelayedCount() (uint64, error) {
+	return uint64(len(s.delayed)), nil
+}
+
+func (s *testMirrorSource) GetBatchCount() (uint64, error) {
+	return uint64(len(s.batches)), nil
+}
+
+func (s *testMirrorSource) GetDelayedAcc(seqNum uint64) (common.Hash, error) {
+	if seqNum >= uint64(len(s.delayedAccs)) {
+		return common.Hash{}, accumulatorNotFound
+	}
+	return s.delayedAccs[seqNum], nil
+}
+
+func (s *testMirrorSource) GetBatchAcc(seqNum uint64) (common.Hash, error) {
+	metadata, err := s.GetBatchMetadata(seqNum)
+	return metadata.Accumulator, err
+}
+
+func (s *testMirrorSource) GetBatchMetadata(seqNum uint64) (BatchMetadata, error) {
+	if seqNum >= uint64(len(s.batchMetas)) {
+		return BatchMetadata{}, accumulatorNotFound
+	}
+	return s.batchMetas[seqNum], nil
+}
+
+func (s *testMirrorSource) getDelayedMessageBytesAndAccumulator(seqNum uint64) ([]byte, common.Hash, error) {
+	acc, err := s.GetDelayedAcc(seqNum)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	return s.delayed[seqNum], acc, nil
+}
+
+func (s *testMirrorSource) GetSequencerMessageBytes(ctx context.Context, seqNum uint64) ([]byte, error) {
+	if seqNum >= uint64(len(s.batches)) {
+		return nil, accumulatorNotFound
+	}
+	return s.batches[seqNum], nil
+}
+
+type testMirrorSink struct {
+	received []*MirroredMessage
+	fail     bool
+}
+
+func (s *testMirrorSink) Send(ctx context.Context, messages []*MirroredMessage) error {
+	if s.fail {
+		return errors.New("sink unavailable")
+	}
+	s.received = append(s.received, messages...)
+	return nil
+}
+
+// mirrorAll mirrors until the mirror is caught up, returning what the sink received.
+func mirrorAll(t *testing.T, mirror *InboxMirror, sink *testMirrorSink) []*MirroredMessage {
+	t.Helper()
+	sink.received = nil
+	for {
+		more, err := mirror.mirror(context.Background())
+		Require(t, err)
+		if !more {
+			return sink.received
+		}
+	}
+}
+
+func checkMirrored(t *testing.T, messages []*MirroredMessage, expected ...string) {
+	t.Helper()
+	if len(messages) != len(expected) {
+		Fail(t, "expected", len(expected), "mirrored messages, got", len(messages))
+	}
+	for i, message := range messages {
+		if message.Kind+string(message.Data) != expected[i] {
+			Fail(t, "unexpected mirrored message", i, message.Kind, string(message.Data), "expected", expected[i])
+		}
+	}
+}
+
+func TestInboxMirror(t *testing.T) {
+	source := &testMirrorSource{}
+	sink := &testMirrorSink{}
+	config := DefaultInboxMirrorConfig
+	config.MaxBatchSize = 2
+	mirror := newInboxMirror(rawdb.NewMemoryDatabase(), source, sink, &config)
+
+	source.addDelayed([]byte("d0"))
+	source.addDelayed([]byte("d1"))
+	source.addDelayed([]byte("d2"))
+	source.addBatch([]byte("b0"))
+	source.addBatch([]byte("b1"))
+
+	delayedLag, batchLag, err := mirror.Lag()
+	Require(t, err)
+	if delayedLag != 3 || batchLag != 2 {
+		Fail(t, "unexpected lag", delayedLag, batchLag)
+	}
+	received := mirrorAll(t, mirror, sink)
+	checkMirrored(t, received, "delayedd0", "delayedd1", "delayedd2", "batchb0", "batchb1")
+	if received[4].SequenceNumber != 1 || received[4].MessageCount != 4 || received[4].DelayedCount != 3 || received[4].L1Block != 1 {
+		Fail(t, "unexpected batch metadata", received[4])
+	}
+	delayedLag, batchLag, err = mirror.Lag()
+	Require(t, err)
+	if delayedLag != 0 || batchLag != 0 {
+		Fail(t, "unexpected lag once caught up", delayedLag, batchLag)
+	}
+
+	// A failed delivery isn't acknowledged, so it's delivered again
+	source.addBatch([]byte("b2"))
+	sink.fail = true
+	if _, err := mirror.mirror(context.Background()); err == nil {
+		Fail(t, "failed delivery succeeded")
+	}
+	sink.fail = false
+	checkMirrored(t, mirrorAll(t, mirror, sink), "batchb2")
+
+	// A reorg which shortens the inbox
+	source.reorgBatchesTo(1)
+	received = mirrorAll(t, mirror, sink)
+	checkMirrored(t, received, "reorg")
+	if received[0].BatchCount != 1 || received[0].DelayedCount != 3 || received[0].MessageCount != 0 {
+		Fail(t, "unexpected reorg", received[0])
+	}
+
+	// A reorg which replaces batches without changing their count
+	source.addBatch([]byte("b1"))
+	source.addBatch([]byte("b2"))
+	checkMirrored(t, mirrorAll(t, mirror, sink), "batchb1", "batchb2")
+	source.reorgBatchesTo(1)
+	source.addBatch([]byte("b1'"))
+	source.addBatch([]byte("b2'"))
+	received = mirrorAll(t, mirror, sink)
+	checkMirrored(t, received, "reorg", "batchb1'", "batchb2'")
+	if received[0].BatchCount != 1 || received[0].DelayedCount != 3 {
+		Fail(t, "unexpected reorg", received[0])
+	}
+
+	// The same for delayed messages
+	source.delayed = source.delayed[:2]
+	source.delayedAccs = source.delayedAccs[:2]
+	source.addDelayed([]byte("d2'"))
+	received = mirrorAll(t, mirror, sink)
+	checkMirrored(t, received, "reorg", "delayedd2'")
+	if received[0].DelayedCount != 2 || received[0].BatchCount != 3 {
+		Fail(t, "unexpected reorg", received[0])
+	}
+}
+
+func TestInboxMirrorPrunesAccumulators(t *testing.T) {
+	source := &testMirrorSource{}
+	sink := &testMirrorSink{}
+	config := DefaultInboxMirrorConfig
+	config.ReorgDepth = 2
+	db := rawdb.NewMemoryDatabase()
+	mirror := newInboxMirror(db, source, sink, &config)
+
+	for _, data := range []string{"b0", "b1", "b2", "b3", "b4"} {
+		source.addBatch([]byte(data))
+	}
+	mirrorAll(t, mirror, sink)
+	for seqNum := uint64(0); seqNum < 5; seqNum++ {
+		hasAcc, err := db.Has(dbKey(mirroredBatchPrefix, seqNum))
+		Require(t, err)
+		if hasAcc != (seqNum >= 3) {
+			Fail(t, "batch", seqNum, "has accumulator", hasAcc, "with a reorg depth of", config.ReorgDepth)
+		}
+	}
+
+	// Reorgs within the reorg depth are still detected
+	source.reorgBatchesTo(3)
+	source.addBatch([]byte("b3'"))
+	source.addBatch([]byte("b4'"))
+	received := mirrorAll(t, mirror, sink)
+	checkMirrored(t, received, "reorg", "batchb3'", "batchb4'")
+	if received[0].BatchCount != 3 {
+		Fail(t, "unexpected reorg", received[0])
+	}
+}
+
+func TestMirroredMessageZeroCounts(t *testing.T) {
+	// A reorg back to the start of the inbox must be distinguishable from one which doesn't set the counts
+	encoded, err := json.Marshal(&MirroredMessage{Kind: MirroredMessageKindReorg})
+	Require(t, err)
+	var fields map[string]interface{}
+	Require(t, json.Unmarshal(encoded, &fields))
+	for _, field := range []string{"l1Block", "messageCount", "delayedCount", "batchCount"} {
+		if _, ok := fields[field]; !ok {
+			Fail(t, "zero", field, "omitted from", string(encoded))
+		}
+	}
+}
@@ -28,6 +28,7 @@ type InboxReaderConfig struct {
 	CheckDelay      time.Duration `koanf:"check-delay"`
 	HardReorg       bool          `koanf:"hard-reorg"`
 	MinBlocksToRead uint64        `koanf:"min-blocks-to-read"`
+	ArchiveURL      string        `koanf:"archive-url"`
 }
 
 func InboxReaderConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -35,6 +36,7 @@ func InboxReaderConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Duration(prefix+".check-delay", DefaultInboxReaderConfig.CheckDelay, "the maximum time to wait between inbox checks (if not enough new blocks are found)")
 	f.Bool(prefix+".hard-reorg", DefaultInboxReaderConfig.HardReorg, "erase future transactions in addition to overwriting existing ones on reorg")
 	f.Uint64(prefix+".min-blocks-to-read", DefaultInboxReaderConfig.MinBlocksToRead, "the minimum number of blocks to read at once (when caught up lowers load on L1)")
+	f.String(prefix+".archive-url", DefaultInboxReaderConfig.ArchiveURL, "L1 archive node to read blocks from when the L1 endpoint has pruned them")
 }
 
 var DefaultInboxReaderConfig = InboxReaderConfig{
@@ -42,6 +44,7 @@ var DefaultInboxReaderConfig = InboxReaderConfig{
 	CheckDelay:      time.Minute,
 	HardReorg:       false,
 	MinBlocksToRead: 1,
+	ArchiveURL:      "",
 }
 
 var TestInboxReaderConfig = InboxReaderConfig{
@@ -49,15 +52,17 @@ var TestInboxReaderConfig = InboxReaderConfig{
 	CheckDelay:      time.Millisecond * 10,
 	HardReorg:       false,
 	MinBlocksToRead: 1,
+	ArchiveURL:      "",
 }
 
 type InboxReader struct {
 	stopwaiter.StopWaiter
 
 	// Only in run thread
-	caughtUp          bool
-	firstMessageBlock *big.Int
-	config            *InboxReaderConfig
+	caughtUp           bool
+	firstMessageBlock  *big.Int
+	config             *InboxReaderConfig
+	oldestPrimaryBlock *big.Int // nil unless older blocks are read from the archive
 
 	// Thread safe
 	tracker        *InboxTracker
@@ -66,6 +71,7 @@ type InboxReader struct {
 	caughtUpChan   chan bool
 	client         arbutil.L1Interface
 	l1Reader       *headerreader.HeaderReader
+	archive        *inboxArchive // nil if not configured
 
 	// Atomic
 	lastSeenBatchCount uint64
@@ -90,10 +96,16 @@ func NewInboxReader(tracker *InboxTracker, client arbutil.L1Interface, l1Reader
 }
 
 func (r *InboxReader) Start(ctxIn context.Context) error {
+	err := r.checkHistory(ctxIn)
+	if err != nil {
+		return err
+	}
 	r.StopWaiter.Start(ctxIn)
 	r.CallIteratively(func(ctx context.Context) time.Duration {
 		err := r.run(ctx)
-		if err != nil && !errors.Is(err, context.Canceled) && !strings.Contains(err.Error(), "header not found") {
+		if errors.Is(err, errPrunedHistory) {
+			log.Error("error reading inbox", "err", err)
+		} else if err != nil && !errors.Is(err, context.Canceled) && !strings.Contains(err.Error(), "header not found") {
 			log.Warn("error reading inbox", "err", err)
 		}
 		return time.Second
@@ -221,6 +233,7 @@ func (ir *InboxReader) run(ctx context.Context) error {
 					return err
 				}
 				dbDelayedAcc, err := ir.tracker.GetDelayedAcc(checkingDelayedSeqNum)
+				delayedAccumulatorChecks.record(err, dbDelayedAcc == l1DelayedAcc)
 				if err != nil {
 					return err
 				}
@@ -257,6 +270,7 @@ func (ir *InboxReader) run(ctx context.Context) error {
 					return err
 				}
 				dbBatchAcc, err := ir.tracker.GetBatchAcc(checkingBatchSeqNum)
+				sequencerAccumulatorChecks.record(err, dbBatchAcc == l1BatchAcc)
 				if err != nil {
 					return err
 				}
@@ -302,11 +316,11 @@ func (ir *InboxReader) run(ctx context.Context) error {
 				to = currentHeight
 			}
 			var delayedMessages []*DelayedInboxMessage
-			delayedMessages, err := ir.delayedBridge.LookupMessagesInRange(ctx, from, to)
+			delayedMessages, err := ir.lookupMessagesInRange(ctx, from, to)
 			if err != nil {
 				return err
 			}
-			sequencerBatches, err := ir.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+			sequencerBatches, batchClient, err := ir.lookupBatchesInRange(ctx, from, to)
 			if err != nil {
 				return err
 			}
@@ -321,6 +335,7 @@ func (ir *InboxReader) run(ctx context.Context) error {
 				firstBatch := sequencerBatches[0]
 				if firstBatch.SequenceNumber > 0 {
 					haveAcc, err := ir.tracker.GetBatchAcc(firstBatch.SequenceNumber - 1)
+					sequencerAccumulatorChecks.record(err, haveAcc == firstBatch.BeforeInboxAcc)
 					if errors.Is(err, accumulatorNotFound) {
 						reorgingSequencer = true
 					} else if err != nil {
@@ -367,6 +382,7 @@ func (ir *InboxReader) run(ctx context.Context) error {
 				}
 				if beforeCount > 0 {
 					haveAcc, err := ir.tracker.GetDelayedAcc(beforeCount - 1)
+					delayedAccumulatorChecks.record(err, haveAcc == beforeAcc)
 					if errors.Is(err, accumulatorNotFound) {
 						reorgingDelayed = true
 					} else if err != nil {
@@ -383,7 +399,16 @@ func (ir *InboxReader) run(ctx context.Context) error {
 
 			log.Trace("looking up messages", "from", from.String(), "to", to.String(), "reorgingDelayed", reorgingDelayed, "reorgingSequencer", reorgingSequencer)
 			if !reorgingDelayed && !reorgingSequencer && (len(delayedMessages) != 0 || len(sequencerBatches) != 0) {
-				delayedMismatch, err := ir.addMessages(ctx, sequencerBatches, delayedMessages)
+				delayedMismatch, err := ir.addMessages(ctx, batchClient, sequencerBatches, delayedMessages)
+				if errors.Is(err, errBatchAwaitingExecution) {
+					// Any batches before it were added, so once they're executed read the range again to add the rest
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(100 * time.Millisecond):
+					}
+					continue
+				}
 				if err != nil {
 					return err
 				}
@@ -419,12 +444,12 @@ func (ir *InboxReader) run(ctx context.Context) error {
 	}
 }
 
-func (r *InboxReader) addMessages(ctx context.Context, sequencerBatches []*SequencerInboxBatch, delayedMessages []*DelayedInboxMessage) (bool, error) {
+func (r *InboxReader) addMessages(ctx context.Context, client arbutil.L1Interface, sequencerBatches []*SequencerInboxBatch, delayedMessages []*DelayedInboxMessage) (bool, error) {
 	err := r.tracker.AddDelayedMessages(delayedMessages)
 	if err != nil {
 		return false, err
 	}
-	err = r.tracker.AddSequencerBatches(ctx, r.client, sequencerBatches)
+	err = r.tracker.AddSequencerBatches(ctx, client, sequencerBatches)
 	if errors.Is(err, delayedMessagesMismatch) {
 		return true, nil
 	} else if err != nil {
@@ -469,13 +494,13 @@ func (r *InboxReader) GetSequencerMessageBytes(ctx context.Context, seqNum uint6
 		return nil, err
 	}
 	blockNum := big.NewInt(0).SetUint64(metadata.L1Block)
-	seqBatches, err := r.sequencerInbox.LookupBatchesInRange(ctx, blockNum, blockNum)
+	seqBatches, client, err := r.lookupBatchesInRange(ctx, blockNum, blockNum)
 	if err != nil {
 		return nil, err
 	}
 	for _, batch := range seqBatches {
 		if batch.SequenceNumber == seqNum {
-			return batch.Serialize(ctx, r.client)
+			return batch.Serialize(ctx, client)
 		}
 	}
 	return nil, errors.New("sequencer batch not found")
@@ -0,0 +1,214 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// Fragments of the errors L1 clients return when asked for history they've pruned or never synced
+var prunedHistoryErrors = []string{
+	"missing trie node",
+	"header not found",
+	"unknown block",
+	"pruned",
+	"history has been pruned",
+	"not available",
+	"block not found",
+	"exceeds the history",
+}
+
+var errPrunedHistory = errors.New("L1 history unavailable")
+
+// Errors reading blocks closer than this to the L1 head are assumed to be races with the head, not pruning
+const prunedHistoryMinDepth = 128
+
+func isPrunedHistoryError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range prunedHistoryErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// inboxArchive reads block ranges the primary L1 endpoint has pruned from a secondary archive endpoint.
+type inboxArchive struct {
+	client         arbutil.L1Interface
+	delayedBridge  *DelayedBridge
+	sequencerInbox *SequencerInbox
+}
+
+// SetArchive configures an L1 endpoint to read old block ranges from, when the primary one can't serve them.
+func (r *InboxReader) SetArchive(client arbutil.L1Interface) error {
+	delayedBridge, err := NewDelayedBridge(client, r.delayedBridge.address, r.delayedBridge.fromBlock)
+	if err != nil {
+		return err
+	}
+	sequencerInbox, err := NewSequencerInbox(client, r.sequencerInbox.address, r.sequencerInbox.fromBlock)
+	if err != nil {
+		return err
+	}
+	r.archive = &inboxArchive{
+		client:         client,
+		delayedBridge:  delayedBridge,
+		sequencerInbox: sequencerInbox,
+	}
+	return nil
+}
+
+// hasHistory checks whether the client can serve the header and logs of a block.
+func (r *InboxReader) hasHistory(ctx context.Context, client arbutil.L1Interface, block uint64) (bool, error) {
+	number := new(big.Int).SetUint64(block)
+	header, err := client.HeaderByNumber(ctx, number)
+	if isPrunedHistoryError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if header == nil {
+		return false, nil
+	}
+	_, err = client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: number,
+		ToBlock:   number,
+		Addresses: []common.Address{r.delayedBridge.address, r.sequencerInbox.address},
+	})
+	if isPrunedHistoryError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// oldestAvailableBlock binary searches (from, head] for the oldest block hasHistory accepts,
+// assuming that blocks before it are all unavailable and blocks after it are all available.
+func oldestAvailableBlock(from uint64, head uint64, hasHistory func(uint64) (bool, error)) (uint64, error) {
+	low, high := from, head
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		available, err := hasHistory(mid)
+		if err != nil {
+			return 0, err
+		}
+		if available {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return high, nil
+}
+
+// checkHistory makes sure the primary L1 endpoint can serve the blocks the inbox reader is about to read.
+// If it can't, the read is routed to the archive endpoint for the missing range, or without one, startup
+// fails with how much history the endpoint would need to keep.
+func (r *InboxReader) checkHistory(ctx context.Context) error {
+	from, err := r.getNextBlockToRead()
+	if err != nil {
+		return err
+	}
+	available, err := r.hasHistory(ctx, r.client, from.Uint64())
+	if err != nil || available {
+		return err
+	}
+	head, err := r.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	oldest, err := oldestAvailableBlock(from.Uint64(), head, func(block uint64) (bool, error) {
+		return r.hasHistory(ctx, r.client, block)
+	})
+	if err != nil {
+		return err
+	}
+	if r.archive == nil {
+		return fmt.Errorf(
+			"the L1 endpoint has pruned blocks before %v but the inbox reader needs to read from block %v: use an L1 node keeping at least %v blocks of history (more to allow for reorgs and catching up), or set --node.inbox-reader.archive-url",
+			oldest, from, head-from.Uint64()+1,
+		)
+	}
+	available, err = r.hasHistory(ctx, r.archive.client, from.Uint64())
+	if err != nil {
+		return err
+	}
+	if !available {
+		return fmt.Errorf("neither the L1 endpoint nor the archive endpoint can serve block %v needed by the inbox reader", from)
+	}
+	log.Info("L1 endpoint has pruned history, reading older blocks from the archive endpoint", "from", from, "oldestAvailable", oldest)
+	r.oldestPrimaryBlock = new(big.Int).SetUint64(oldest)
+	return nil
+}
+
+// useArchive returns whether a range starting at from must be read from the archive endpoint.
+func (r *InboxReader) useArchive(from *big.Int) bool {
+	return r.archive != nil && r.oldestPrimaryBlock != nil && arbmath.BigLessThan(from, r.oldestPrimaryBlock)
+}
+
+// historyPruned returns whether err came from reading a range the primary L1 endpoint has pruned.
+func (r *InboxReader) historyPruned(ctx context.Context, err error, from *big.Int) bool {
+	if !isPrunedHistoryError(err) {
+		return false
+	}
+	header, headerErr := r.l1Reader.LastHeader(ctx)
+	if headerErr != nil {
+		return false
+	}
+	return arbmath.BigLessThan(arbmath.BigAddByUint(from, prunedHistoryMinDepth), header.Number)
+}
+
+// prunedHistoryError explains an error reading a range the primary L1 endpoint has pruned.
+func (r *InboxReader) prunedHistoryError(err error, from *big.Int) error {
+	return fmt.Errorf("%w: L1 endpoint can't serve block %v, it may have pruned its history; use an L1 node with more history or set --node.inbox-reader.archive-url: %v", errPrunedHistory, from, err)
+}
+
+func (r *InboxReader) lookupMessagesInRange(ctx context.Context, from, to *big.Int) ([]*DelayedInboxMessage, error) {
+	if r.useArchive(from) {
+		return r.archive.delayedBridge.LookupMessagesInRange(ctx, from, to)
+	}
+	messages, err := r.delayedBridge.LookupMessagesInRange(ctx, from, to)
+	if r.historyPruned(ctx, err, from) {
+		if r.archive == nil {
+			return nil, r.prunedHistoryError(err, from)
+		}
+		log.Warn("L1 endpoint failed reading delayed messages, trying the archive endpoint", "from", from, "to", to, "err", err)
+		return r.archive.delayedBridge.LookupMessagesInRange(ctx, from, to)
+	}
+	return messages, err
+}
+
+// lookupBatchesInRange also returns the client the batches' data should be fetched with.
+func (r *InboxReader) lookupBatchesInRange(ctx context.Context, from, to *big.Int) ([]*SequencerInboxBatch, arbutil.L1Interface, error) {
+	if r.useArchive(from) {
+		batches, err := r.archive.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+		return batches, r.archive.client, err
+	}
+	batches, err := r.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+	if r.historyPruned(ctx, err, from) {
+		if r.archive == nil {
+			return nil, nil, r.prunedHistoryError(err, from)
+		}
+		log.Warn("L1 endpoint failed reading sequencer batches, trying the archive endpoint", "from", from, "to", to, "err", err)
+		batches, err = r.archive.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+		return batches, r.archive.client, err
+	}
+	return batches, r.client, err
+}
@@ -0,0 +1,62 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+func TestOldestAvailableBlock(t *testing.T) {
+	for _, oldest := range []uint64{11, 12, 500, 999, 1000} {
+		probes := 0
+		found, err := oldestAvailableBlock(10, 1000, func(block uint64) (bool, error) {
+			probes++
+			return block >= oldest, nil
+		})
+		Require(t, err)
+		if found != oldest {
+			Fail(t, "expected oldest available block", oldest, "but found", found)
+		}
+		if probes > 10 {
+			Fail(t, "took", probes, "probes to find block", oldest)
+		}
+	}
+
+	probeErr := errors.New("connection refused")
+	_, err := oldestAvailableBlock(10, 1000, func(block uint64) (bool, error) {
+		return false, probeErr
+	})
+	if !errors.Is(err, probeErr) {
+		Fail(t, "expected probe error but got", err)
+	}
+}
+
+func TestIsPrunedHistoryError(t *testing.T) {
+	pruned := []error{
+		ethereum.NotFound,
+		fmt.Errorf("lookup failed: %w", ethereum.NotFound),
+		errors.New("missing trie node 1234 (path )"),
+		errors.New("Header not found"),
+		errors.New("historical state is not available"),
+	}
+	for _, err := range pruned {
+		if !isPrunedHistoryError(err) {
+			Fail(t, "expected pruned history error:", err)
+		}
+	}
+	notPruned := []error{
+		nil,
+		errors.New("connection refused"),
+		errors.New("execution reverted"),
+	}
+	for _, err := range notPruned {
+		if isPrunedHistoryError(err) {
+			Fail(t, "unexpected pruned history error:", err)
+		}
+	}
+}
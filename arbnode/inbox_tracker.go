@@ -12,6 +12,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbstate"
@@ -83,6 +84,41 @@ func (t *InboxTracker) Initialize() error {
 
 var accumulatorNotFound error = errors.New("accumulator not found")
 
+// accumulatorChecks counts the outcomes of comparing our stored accumulators against those seen on L1.
+// A rising mismatch rate often means a misbehaving L1 provider, before it turns into a reorg loop.
+type accumulatorChecks struct {
+	match    metrics.Counter
+	mismatch metrics.Counter
+	notFound metrics.Counter
+}
+
+func newAccumulatorChecks(source string) *accumulatorChecks {
+	return &accumulatorChecks{
+		match:    metrics.NewRegisteredCounter("arb/inbox/accumulator/"+source+"/match", nil),
+		mismatch: metrics.NewRegisteredCounter("arb/inbox/accumulator/"+source+"/mismatch", nil),
+		notFound: metrics.NewRegisteredCounter("arb/inbox/accumulator/"+source+"/notfound", nil),
+	}
+}
+
+var (
+	delayedAccumulatorChecks   = newAccumulatorChecks("delayed")
+	sequencerAccumulatorChecks = newAccumulatorChecks("sequencer")
+)
+
+// record counts a check given the error from looking up our accumulator and whether it matched.
+// Errors other than accumulatorNotFound aren't a verification outcome, so they're not counted.
+func (c *accumulatorChecks) record(err error, matched bool) {
+	if errors.Is(err, accumulatorNotFound) {
+		c.notFound.Inc(1)
+	} else if err != nil {
+		return
+	} else if matched {
+		c.match.Inc(1)
+	} else {
+		c.mismatch.Inc(1)
+	}
+}
+
 func (t *InboxTracker) GetDelayedAcc(seqNum uint64) (common.Hash, error) {
 	key := dbKey(delayedMessagePrefix, seqNum)
 	hasKey, err := t.db.Has(key)
@@ -153,6 +189,12 @@ func (t *InboxTracker) GetBatchAcc(seqNum uint64) (common.Hash, error) {
 	return metadata.Accumulator, err
 }
 
+// Convenience function wrapping GetBatchMetadata
+func (t *InboxTracker) GetBatchDelayedCount(seqNum uint64) (uint64, error) {
+	metadata, err := t.GetBatchMetadata(seqNum)
+	return metadata.DelayedMessageCount, err
+}
+
 func (t *InboxTracker) GetBatchCount() (uint64, error) {
 	data, err := t.db.Get(sequencerBatchCountKey)
 	if err != nil {
@@ -214,6 +256,7 @@ func (t *InboxTracker) AddDelayedMessages(messages []*DelayedInboxMessage) error
 	if pos > 0 {
 		var err error
 		nextAcc, err = t.GetDelayedAcc(pos - 1)
+		delayedAccumulatorChecks.record(err, nextAcc == messages[0].BeforeInboxAcc)
 		if err != nil {
 			if errors.Is(err, accumulatorNotFound) {
 				return errors.New("missing previous delayed message")
@@ -255,13 +298,14 @@ func (t *InboxTracker) AddDelayedMessages(messages []*DelayedInboxMessage) error
 		pos++
 	}
 
-	return t.setDelayedCountReorgAndWriteBatch(batch, pos)
+	return t.setDelayedCountReorgAndWriteBatch(batch, pos, false)
 }
 
 // All-in-one delayed message count adjuster. Can go forwards or backwards.
 // Requires the mutex is held. Sets the delayed count and performs any sequencer batch reorg necessary.
 // Also deletes any future delayed messages.
-func (t *InboxTracker) setDelayedCountReorgAndWriteBatch(batch ethdb.Batch, newDelayedCount uint64) error {
+// Unless hardReorg is set, the streamer may keep the messages of reorged batches until they're reread.
+func (t *InboxTracker) setDelayedCountReorgAndWriteBatch(batch ethdb.Batch, newDelayedCount uint64, hardReorg bool) error {
 	err := deleteStartingAt(t.db, batch, delayedMessagePrefix, uint64ToKey(newDelayedCount))
 	if err != nil {
 		return err
@@ -318,6 +362,11 @@ func (t *InboxTracker) setDelayedCountReorgAndWriteBatch(batch ethdb.Batch, newD
 			return err
 		}
 		log.Info("InboxTracker", "sequencerBatchCount", count)
+		// The block index reads the metadata of the batches it removes, so it must be deleted first
+		err = t.deleteBlockIndexFrom(batch, count)
+		if err != nil {
+			return err
+		}
 		err = deleteStartingAt(t.db, batch, sequencerBatchMetaPrefix, uint64ToKey(count))
 		if err != nil {
 			return err
@@ -330,6 +379,9 @@ func (t *InboxTracker) setDelayedCountReorgAndWriteBatch(batch ethdb.Batch, newD
 			}
 		}
 		// Writes batch
+		if !hardReorg {
+			return t.txStreamer.UncoverMessagesAndEndBatch(batch, prevMesssageCount)
+		}
 		return t.txStreamer.ReorgToAndEndBatch(batch, prevMesssageCount)
 	} else {
 		return batch.Write()
@@ -381,6 +433,7 @@ func (b *multiplexerBackend) ReadDelayedInbox(seqNum uint64) ([]byte, error) {
 }
 
 var delayedMessagesMismatch = errors.New("sequencer batch delayed messages missing or different")
+var errBatchAwaitingExecution = errors.New("sequencer batch can't be read until the messages before it are executed")
 
 func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L1Interface, batches []*SequencerInboxBatch) error {
 	if len(batches) == 0 {
@@ -397,6 +450,7 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 		var err error
 		prevbatchmeta, err = t.GetBatchMetadata(pos - 1)
 		nextAcc = prevbatchmeta.Accumulator
+		sequencerAccumulatorChecks.record(err, nextAcc == batches[0].BeforeInboxAcc)
 		if errors.Is(err, accumulatorNotFound) {
 			return errors.New("missing previous sequencer batch")
 		} else if err != nil {
@@ -409,6 +463,10 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 	if err != nil {
 		return err
 	}
+	err = t.deleteBlockIndexFrom(dbBatch, startPos)
+	if err != nil {
+		return err
+	}
 
 	for _, batch := range batches {
 		if batch.SequenceNumber != pos {
@@ -420,6 +478,7 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 
 		if batch.AfterDelayedCount > 0 {
 			haveDelayedAcc, err := t.GetDelayedAcc(batch.AfterDelayedCount - 1)
+			delayedAccumulatorChecks.record(err, haveDelayedAcc == batch.AfterDelayedAcc)
 			if errors.Is(err, accumulatorNotFound) {
 				// We somehow missed a referenced delayed message; go back and look for it
 				return delayedMessagesMismatch
@@ -444,15 +503,39 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 		ctx:    ctx,
 		client: client,
 	}
-	multiplexer := arbstate.NewInboxMultiplexer(backend, prevbatchmeta.DelayedMessageCount, t.das)
+	// How some batches decode depends on the ArbOS version before them, which is only known once the messages
+	// before them are executed. Rather than waiting for that while holding the mutex, a batch whose preceding
+	// messages aren't executed yet is left for the reader to retry, along with those after it.
+	arbosVersion := func() (uint64, error) {
+		if backend.batches[0].SequenceNumber != startPos {
+			return 0, errBatchAwaitingExecution
+		}
+		version, err := t.txStreamer.arbOSVersionAfter(prevbatchmeta.MessageCount)
+		if errors.Is(err, errMessagesNotExecuted) {
+			return 0, errBatchAwaitingExecution
+		}
+		return version, err
+	}
+	multiplexer := arbstate.NewInboxMultiplexer(backend, prevbatchmeta.DelayedMessageCount, t.das, arbosVersion)
 	batchMessageCounts := make(map[uint64]arbutil.MessageIndex)
 	currentpos := prevbatchmeta.MessageCount + 1
+	awaitingExecution := false
 	for {
 		if len(backend.batches) == 0 {
 			break
 		}
 		batchSeqNum := backend.batches[0].SequenceNumber
 		msg, err := multiplexer.Pop(ctx)
+		if errors.Is(err, errBatchAwaitingExecution) {
+			if batchSeqNum == startPos {
+				// Nothing can be added yet
+				return err
+			}
+			batches = batches[:batchSeqNum-startPos]
+			pos = batchSeqNum
+			awaitingExecution = true
+			break
+		}
 		if err != nil {
 			return err
 		}
@@ -477,6 +560,10 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 		if err != nil {
 			return err
 		}
+		err = writeBlockIndex(dbBatch, batch.SequenceNumber, meta, lastBatchMeta.MessageCount)
+		if err != nil {
+			return err
+		}
 
 		seqNumData, err := rlp.EncodeToBytes(batch.SequenceNumber)
 		if err != nil {
@@ -550,6 +637,9 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 		t.txStreamer.broadcastServer.Confirm(prevbatchmeta.MessageCount - 1)
 	}
 
+	if awaitingExecution {
+		return errBatchAwaitingExecution
+	}
 	return nil
 }
 
@@ -567,7 +657,7 @@ func (t *InboxTracker) ReorgDelayedTo(count uint64) error {
 		return errors.New("attempted to reorg to future delayed count")
 	}
 
-	return t.setDelayedCountReorgAndWriteBatch(t.db.NewBatch(), count)
+	return t.setDelayedCountReorgAndWriteBatch(t.db.NewBatch(), count, true)
 }
 
 func (t *InboxTracker) ReorgBatchesTo(count uint64) error {
@@ -595,6 +685,10 @@ func (t *InboxTracker) ReorgBatchesTo(count uint64) error {
 	if err != nil {
 		return err
 	}
+	err = t.deleteBlockIndexFrom(dbBatch, count)
+	if err != nil {
+		return err
+	}
 	err = deleteStartingAt(t.db, dbBatch, sequencerBatchMetaPrefix, uint64ToKey(count))
 	if err != nil {
 		return err
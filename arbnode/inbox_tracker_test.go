@@ -0,0 +1,36 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func TestAccumulatorChecks(t *testing.T) {
+	// counters are only counted with metrics enabled
+	metrics.Enabled = true
+	checks := newAccumulatorChecks("test")
+
+	checks.record(nil, true)
+	checks.record(nil, true)
+	checks.record(nil, false)
+	checks.record(fmt.Errorf("looking up delayed message: %w", accumulatorNotFound), false)
+	// a failed lookup isn't a verification outcome
+	checks.record(errors.New("database closed"), false)
+	checks.record(errors.New("database closed"), true)
+
+	if matches := checks.match.Count(); matches != 2 {
+		Fail(t, "counted", matches, "matches, expected 2")
+	}
+	if mismatches := checks.mismatch.Count(); mismatches != 1 {
+		Fail(t, "counted", mismatches, "mismatches, expected 1")
+	}
+	if notFound := checks.notFound.Count(); notFound != 1 {
+		Fail(t, "counted", notFound, "missing accumulators, expected 1")
+	}
+}
@@ -15,9 +15,11 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util"
 	"github.com/offchainlabs/nitro/util/headerreader"
 	"golang.org/x/term"
 
@@ -32,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
@@ -46,12 +49,16 @@ import (
 	"github.com/offchainlabs/nitro/broadcaster"
 	"github.com/offchainlabs/nitro/das"
 	"github.com/offchainlabs/nitro/das/dasrpc"
+	"github.com/offchainlabs/nitro/grpcfeed"
 	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
 	"github.com/offchainlabs/nitro/solgen/go/challengegen"
 	"github.com/offchainlabs/nitro/solgen/go/ospgen"
 	"github.com/offchainlabs/nitro/solgen/go/rollupgen"
 	"github.com/offchainlabs/nitro/statetransfer"
+	"github.com/offchainlabs/nitro/util/featureflags"
+	"github.com/offchainlabs/nitro/util/statehealer"
 	"github.com/offchainlabs/nitro/validator"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
 )
 
 type RollupAddresses struct {
@@ -391,23 +398,39 @@ func DeployOnL1(ctx context.Context, l1client arbutil.L1Interface, deployAuth *b
 }
 
 type Config struct {
-	RPC                  arbitrum.Config                `koanf:"rpc"`
-	Sequencer            SequencerConfig                `koanf:"sequencer"`
-	L1Reader             headerreader.Config            `koanf:"l1-reader"`
-	InboxReader          InboxReaderConfig              `koanf:"inbox-reader"`
-	DelayedSequencer     DelayedSequencerConfig         `koanf:"delayed-sequencer"`
-	BatchPoster          BatchPosterConfig              `koanf:"batch-poster"`
-	ForwardingTargetImpl string                         `koanf:"forwarding-target"`
-	PreCheckTxs          bool                           `koanf:"pre-check-txs"`
-	BlockValidator       validator.BlockValidatorConfig `koanf:"block-validator"`
-	Feed                 broadcastclient.FeedConfig     `koanf:"feed"`
-	Validator            validator.L1ValidatorConfig    `koanf:"validator"`
-	SeqCoordinator       SeqCoordinatorConfig           `koanf:"seq-coordinator"`
-	DataAvailability     das.DataAvailabilityConfig     `koanf:"data-availability"`
-	Wasm                 WasmConfig                     `koanf:"wasm"`
-	Dangerous            DangerousConfig                `koanf:"dangerous"`
-	Archive              bool                           `koanf:"archive"`
-	TxLookupLimit        uint64                         `koanf:"tx-lookup-limit"`
+	RPC                  arbitrum.Config                      `koanf:"rpc"`
+	Sequencer            SequencerConfig                      `koanf:"sequencer"`
+	L1Reader             headerreader.Config                  `koanf:"l1-reader"`
+	InboxReader          InboxReaderConfig                    `koanf:"inbox-reader"`
+	TransactionStreamer  TransactionStreamerConfig            `koanf:"transaction-streamer"`
+	DelayedSequencer     DelayedSequencerConfig               `koanf:"delayed-sequencer"`
+	BatchPoster          BatchPosterConfig                    `koanf:"batch-poster"`
+	ForwardingTargetImpl string                               `koanf:"forwarding-target"`
+	Forwarder            ForwarderConfig                      `koanf:"forwarder"`
+	PreCheckTxs          bool                                 `koanf:"pre-check-txs"`
+	BlockValidator       validator.BlockValidatorConfig       `koanf:"block-validator"`
+	Feed                 broadcastclient.FeedConfig           `koanf:"feed"`
+	Validator            validator.L1ValidatorConfig          `koanf:"validator"`
+	SeqCoordinator       SeqCoordinatorConfig                 `koanf:"seq-coordinator"`
+	ValidatorCoordinator validator.ValidatorCoordinatorConfig `koanf:"validator-coordinator"`
+	LightVerifier        validator.LightVerifierConfig        `koanf:"light-verifier"`
+	Execution            ExecutionConfig                      `koanf:"execution"`
+	StatePruner          StatePrunerConfig                    `koanf:"state-pruner"`
+	InboxMirror          InboxMirrorConfig                    `koanf:"inbox-mirror"`
+	SnapshotPublisher    SnapshotPublisherConfig              `koanf:"snapshot-publisher"`
+	Attestation          AttestationConfig                    `koanf:"attestation"`
+	DebugLimits          DebugLimitsConfig                    `koanf:"debug-limits"`
+	FeeHistory           FeeHistoryConfig                     `koanf:"fee-history"`
+	BlockReceipts        BlockReceiptsConfig                  `koanf:"block-receipts"`
+	StateHealer          statehealer.Config                   `koanf:"state-healer"`
+	FeeTokenOracle       FeeTokenOracleConfig                 `koanf:"fee-token-oracle"`
+	FeatureFlags         featureflags.Config                  `koanf:"feature-flags"`
+	DataAvailability     das.DataAvailabilityConfig           `koanf:"data-availability"`
+	Wasm                 WasmConfig                           `koanf:"wasm"`
+	Migrations           MigrationsConfig                     `koanf:"migrations"`
+	Dangerous            DangerousConfig                      `koanf:"dangerous"`
+	Archive              bool                                 `koanf:"archive"`
+	TxLookupLimit        uint64                               `koanf:"tx-lookup-limit"`
 }
 
 func (c *Config) ForwardingTarget() string {
@@ -423,16 +446,32 @@ func ConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool, feed
 	SequencerConfigAddOptions(prefix+".sequencer", f)
 	headerreader.AddOptions(prefix+".l1-reader", f)
 	InboxReaderConfigAddOptions(prefix+".inbox-reader", f)
+	TransactionStreamerConfigAddOptions(prefix+".transaction-streamer", f)
 	DelayedSequencerConfigAddOptions(prefix+".delayed-sequencer", f)
 	BatchPosterConfigAddOptions(prefix+".batch-poster", f)
 	f.String(prefix+".forwarding-target", ConfigDefault.ForwardingTargetImpl, "transaction forwarding target URL, or \"null\" to disable forwarding (iff not sequencer)")
+	ForwarderConfigAddOptions(prefix+".forwarder", f)
 	f.Bool(prefix+".pre-check-txs", ConfigDefault.PreCheckTxs, "if true, verify basic state transition requirements of incoming RPC transactions before processing them")
 	validator.BlockValidatorConfigAddOptions(prefix+".block-validator", f)
 	broadcastclient.FeedConfigAddOptions(prefix+".feed", f, feedInputEnable, feedOutputEnable)
 	validator.L1ValidatorConfigAddOptions(prefix+".validator", f)
 	SeqCoordinatorConfigAddOptions(prefix+".seq-coordinator", f)
+	validator.ValidatorCoordinatorConfigAddOptions(prefix+".validator-coordinator", f)
+	validator.LightVerifierConfigAddOptions(prefix+".light-verifier", f)
+	ExecutionConfigAddOptions(prefix+".execution", f)
+	StatePrunerConfigAddOptions(prefix+".state-pruner", f)
+	InboxMirrorConfigAddOptions(prefix+".inbox-mirror", f)
+	SnapshotPublisherConfigAddOptions(prefix+".snapshot-publisher", f)
+	AttestationConfigAddOptions(prefix+".attestation", f)
+	DebugLimitsConfigAddOptions(prefix+".debug-limits", f)
+	FeeHistoryConfigAddOptions(prefix+".fee-history", f)
+	BlockReceiptsConfigAddOptions(prefix+".block-receipts", f)
+	statehealer.ConfigAddOptions(prefix+".state-healer", f)
+	FeeTokenOracleConfigAddOptions(prefix+".fee-token-oracle", f)
+	featureflags.ConfigAddOptions(prefix+".feature-flags", f)
 	das.DataAvailabilityConfigAddOptions(prefix+".data-availability", f)
 	WasmConfigAddOptions(prefix+".wasm", f)
+	MigrationsConfigAddOptions(prefix+".migrations", f)
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 	f.Bool(prefix+".archive", ConfigDefault.Archive, "retain past block state")
 	f.Uint64(prefix+".tx-lookup-limit", ConfigDefault.TxLookupLimit, "retain the ability to lookup transactions by hash for the past N blocks (0 = all blocks)")
@@ -443,16 +482,32 @@ var ConfigDefault = Config{
 	Sequencer:            DefaultSequencerConfig,
 	L1Reader:             headerreader.DefaultConfig,
 	InboxReader:          DefaultInboxReaderConfig,
+	TransactionStreamer:  DefaultTransactionStreamerConfig,
 	DelayedSequencer:     DefaultDelayedSequencerConfig,
 	BatchPoster:          DefaultBatchPosterConfig,
 	ForwardingTargetImpl: "",
+	Forwarder:            DefaultForwarderConfig,
 	PreCheckTxs:          false,
 	BlockValidator:       validator.DefaultBlockValidatorConfig,
 	Feed:                 broadcastclient.FeedConfigDefault,
 	Validator:            validator.DefaultL1ValidatorConfig,
 	SeqCoordinator:       DefaultSeqCoordinatorConfig,
+	ValidatorCoordinator: validator.DefaultValidatorCoordinatorConfig,
+	LightVerifier:        validator.DefaultLightVerifierConfig,
+	Execution:            DefaultExecutionConfig,
+	StatePruner:          DefaultStatePrunerConfig,
+	InboxMirror:          DefaultInboxMirrorConfig,
+	SnapshotPublisher:    DefaultSnapshotPublisherConfig,
+	Attestation:          DefaultAttestationConfig,
+	DebugLimits:          DefaultDebugLimitsConfig,
+	FeeHistory:           DefaultFeeHistoryConfig,
+	BlockReceipts:        DefaultBlockReceiptsConfig,
+	StateHealer:          statehealer.DefaultConfig,
+	FeeTokenOracle:       DefaultFeeTokenOracleConfig,
+	FeatureFlags:         featureflags.DefaultConfig,
 	DataAvailability:     das.DefaultDataAvailabilityConfig,
 	Wasm:                 DefaultWasmConfig,
+	Migrations:           DefaultMigrationsConfig,
 	Dangerous:            DefaultDangerousConfig,
 	Archive:              false,
 	TxLookupLimit:        40_000_000,
@@ -528,6 +583,18 @@ type SequencerConfig struct {
 	MaxRevertGasReject          uint64                   `koanf:"max-revert-gas-reject"`
 	MaxAcceptableTimestampDelta time.Duration            `koanf:"max-acceptable-timestamp-delta"`
 	SenderWhitelist             string                   `koanf:"sender-whitelist"`
+	OrderingHook                OrderingHookConfig       `koanf:"ordering-hook"`
+	TimeBoost                   TimeBoostConfig          `koanf:"time-boost"`
+	SpamProtection              SpamProtectionConfig     `koanf:"spam-protection"`
+	NonceQueue                  NonceQueueConfig         `koanf:"nonce-queue"`
+	SoftConfirmation            SoftConfirmationConfig   `koanf:"soft-confirmation"`
+	Advisories                  AdvisoriesConfig         `koanf:"advisories"`
+	QueuePersistence            QueuePersistenceConfig   `koanf:"queue-persistence"`
+	RevertGuard                 RevertGuardConfig        `koanf:"revert-guard"`
+	BlockSpeedTuning            BlockSpeedTuningConfig   `koanf:"block-speed-tuning"`
+	Policy                      SequencingPolicyConfig   `koanf:"policy"`
+	PendingTx                   PendingTxConfig          `koanf:"pending-tx"`
+	Backpressure                BackpressureConfig       `koanf:"backpressure"`
 	Dangerous                   DangerousSequencerConfig `koanf:"dangerous"`
 }
 
@@ -536,6 +603,18 @@ var DefaultSequencerConfig = SequencerConfig{
 	MaxBlockSpeed:               time.Millisecond * 100,
 	MaxRevertGasReject:          params.TxGas + 10000,
 	MaxAcceptableTimestampDelta: time.Hour,
+	OrderingHook:                DefaultOrderingHookConfig,
+	TimeBoost:                   DefaultTimeBoostConfig,
+	SpamProtection:              DefaultSpamProtectionConfig,
+	NonceQueue:                  DefaultNonceQueueConfig,
+	SoftConfirmation:            DefaultSoftConfirmationConfig,
+	Advisories:                  DefaultAdvisoriesConfig,
+	QueuePersistence:            DefaultQueuePersistenceConfig,
+	RevertGuard:                 DefaultRevertGuardConfig,
+	BlockSpeedTuning:            DefaultBlockSpeedTuningConfig,
+	Policy:                      DefaultSequencingPolicyConfig,
+	PendingTx:                   DefaultPendingTxConfig,
+	Backpressure:                DefaultBackpressureConfig,
 	Dangerous:                   DefaultDangerousSequencerConfig,
 }
 
@@ -545,6 +624,18 @@ var TestSequencerConfig = SequencerConfig{
 	MaxRevertGasReject:          params.TxGas + 10000,
 	MaxAcceptableTimestampDelta: time.Hour,
 	SenderWhitelist:             "",
+	OrderingHook:                DefaultOrderingHookConfig,
+	TimeBoost:                   DefaultTimeBoostConfig,
+	SpamProtection:              DefaultSpamProtectionConfig,
+	NonceQueue:                  DefaultNonceQueueConfig,
+	SoftConfirmation:            DefaultSoftConfirmationConfig,
+	Advisories:                  DefaultAdvisoriesConfig,
+	QueuePersistence:            DefaultQueuePersistenceConfig,
+	RevertGuard:                 DefaultRevertGuardConfig,
+	BlockSpeedTuning:            DefaultBlockSpeedTuningConfig,
+	Policy:                      DefaultSequencingPolicyConfig,
+	PendingTx:                   DefaultPendingTxConfig,
+	Backpressure:                DefaultBackpressureConfig,
 	Dangerous:                   TestDangerousSequencerConfig,
 }
 
@@ -554,6 +645,18 @@ func SequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Uint64(prefix+".max-revert-gas-reject", DefaultSequencerConfig.MaxRevertGasReject, "maximum gas executed in a revert for the sequencer to reject the transaction instead of posting it (anti-DOS)")
 	f.Duration(prefix+".max-acceptable-timestamp-delta", DefaultSequencerConfig.MaxAcceptableTimestampDelta, "maximum acceptable time difference between the local time and the latest L1 block's timestamp")
 	f.String(prefix+".sender-whitelist", DefaultSequencerConfig.SenderWhitelist, "comma separated whitelist of authorized senders (if empty, everyone is allowed)")
+	OrderingHookConfigAddOptions(prefix+".ordering-hook", f)
+	TimeBoostConfigAddOptions(prefix+".time-boost", f)
+	SpamProtectionConfigAddOptions(prefix+".spam-protection", f)
+	NonceQueueConfigAddOptions(prefix+".nonce-queue", f)
+	SoftConfirmationConfigAddOptions(prefix+".soft-confirmation", f)
+	AdvisoriesConfigAddOptions(prefix+".advisories", f)
+	QueuePersistenceConfigAddOptions(prefix+".queue-persistence", f)
+	RevertGuardConfigAddOptions(prefix+".revert-guard", f)
+	BlockSpeedTuningConfigAddOptions(prefix+".block-speed-tuning", f)
+	SequencingPolicyConfigAddOptions(prefix+".policy", f)
+	PendingTxConfigAddOptions(prefix+".pending-tx", f)
+	BackpressureConfigAddOptions(prefix+".backpressure", f)
 	DangerousSequencerConfigAddOptions(prefix+".dangerous", f)
 }
 
@@ -569,12 +672,29 @@ var DefaultWasmConfig = WasmConfig{
 	RootPath: "",
 }
 
+func (c *WasmConfig) NitroMachineConfig() validator.NitroMachineConfig {
+	nitroMachineConfig := validator.DefaultNitroMachineConfig
+	if c.RootPath != "" {
+		nitroMachineConfig.RootPath = c.RootPath
+	} else {
+		execfile, err := os.Executable()
+		if err != nil {
+			panic(err)
+		}
+		targetDir := filepath.Dir(filepath.Dir(execfile))
+		nitroMachineConfig.RootPath = filepath.Join(targetDir, "machines")
+	}
+	return nitroMachineConfig
+}
+
 type Node struct {
+	// nil when executing remotely or in light mode, as the local blockchain isn't kept up to date
 	Backend                *arbitrum.Backend
 	ArbInterface           *ArbInterface
 	L1Reader               *headerreader.HeaderReader
 	TxStreamer             *TransactionStreamer
 	TxPublisher            TransactionPublisher
+	Sequencer              *Sequencer
 	DeployInfo             *RollupAddresses
 	InboxReader            *InboxReader
 	InboxTracker           *InboxTracker
@@ -587,6 +707,17 @@ type Node struct {
 	SeqCoordinator         *SeqCoordinator
 	DASLifecycleManager    *das.LifecycleManager
 	ClassicOutboxRetriever *ClassicOutboxRetriever
+	InboxMirror            *InboxMirror
+	SnapshotPublisher      *SnapshotPublisher
+	FeeTokenPrice          *FeeTokenPriceFeed
+	FeatureFlags           *featureflags.Flags
+	Advisories             *FeedAdvisories
+	GRPCFeed               *grpcfeed.Server
+	FeedReplayer           *broadcastclient.FeedReplayer
+	ValidatorCoordinator   *validator.ValidatorCoordinator
+	LightVerifier          *validator.LightVerifier
+	StatePruner            *StatePruner
+	StateHealer            *statehealer.StateHealer
 }
 
 func createNodeImpl(
@@ -630,6 +761,18 @@ func createNodeImpl(
 	var broadcastServer *broadcaster.Broadcaster
 	if config.Feed.Output.Enable {
 		broadcastServer = broadcaster.NewBroadcaster(config.Feed.Output)
+		if config.Sequencer.Enable && daSigner != nil {
+			// signed with the L1 wallet, so clients can check the signer is in the on-chain sequencer set
+			broadcastServer.SetSigner(l2BlockChain.Config().ChainID.Uint64(), daSigner)
+		}
+	}
+	var grpcFeed *grpcfeed.Server
+	if config.Feed.GRPC.Enable {
+		if broadcastServer == nil {
+			return nil, errors.New("gRPC feed enabled without the feed output it serves")
+		}
+		grpcFeed = grpcfeed.NewServer(&config.Feed.GRPC)
+		broadcastServer.AddListener(grpcFeed)
 	}
 
 	var l1Reader *headerreader.HeaderReader
@@ -637,10 +780,63 @@ func createNodeImpl(
 		l1Reader = headerreader.New(l1client, config.L1Reader)
 	}
 
-	txStreamer, err := NewTransactionStreamer(arbDb, l2BlockChain, broadcastServer)
+	featureFlags, err := featureflags.New(&config.FeatureFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	var feeTokenPrice *FeeTokenPriceFeed
+	if config.FeeTokenOracle.Source != "" {
+		var parentClient ethereum.ContractCaller
+		if l1Reader != nil {
+			parentClient = l1client
+		}
+		feeTokenPrice, err = NewFeeTokenPriceFeed(&config.FeeTokenOracle, parentClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	txStreamer, err := NewTransactionStreamer(arbDb, l2BlockChain, broadcastServer, &config.TransactionStreamer)
 	if err != nil {
 		return nil, err
 	}
+	if config.Execution.Serve && (config.L1Reader.Enable || config.Sequencer.Enable || config.Feed.Input.Enable() || config.Execution.URL != "") {
+		return nil, errors.New("an execution node only executes the messages its consensus node sends, so can't read L1 or the feed, sequence, or execute remotely itself")
+	}
+	if config.LightVerifier.Enable {
+		if config.Sequencer.Enable || config.BlockValidator.Enable || config.Validator.Enable || config.StatePruner.Enable || config.SnapshotPublisher.Enable {
+			return nil, errors.New("the light verifier doesn't execute blocks, so can't be used with the sequencer, block validator, staker, state pruner or snapshot publisher, which need them")
+		}
+		if config.Execution.Serve || config.Execution.URL != "" {
+			return nil, errors.New("the light verifier doesn't execute blocks, so can't be used with a separate execution node")
+		}
+		txStreamer.DisableExecution()
+	}
+	var remoteExecution *RemoteExecutionClient
+	if config.Execution.URL != "" {
+		if config.Sequencer.Enable || config.BlockValidator.Enable {
+			return nil, errors.New("the sequencer and block validator read the blocks they need from the local blockchain, so require local execution")
+		}
+		remoteExecution, err = NewRemoteExecutionClient(ctx, &config.Execution)
+		if err != nil {
+			return nil, err
+		}
+		txStreamer.SetRemoteExecution(remoteExecution)
+	}
+	var statePruner *StatePruner
+	if config.StatePruner.Enable {
+		if !config.Archive {
+			return nil, errors.New("the state pruner removes the past state archive nodes keep, so requires archive")
+		}
+		if config.Execution.Serve || config.Execution.URL != "" {
+			return nil, errors.New("the state pruner pauses local block creation while it deletes state, so can't be used with a separate execution node")
+		}
+		statePruner, err = NewStatePruner(&config.StatePruner, chainDb, l2BlockChain, txStreamer, stack.ResolvePath("state-pruner"))
+		if err != nil {
+			return nil, err
+		}
+	}
 	var txPublisher TransactionPublisher
 	var coordinator *SeqCoordinator
 	var sequencer *Sequencer
@@ -669,6 +865,9 @@ func createNodeImpl(
 		}
 		if config.ForwardingTarget() == "" {
 			txPublisher = NewTxDropper()
+		} else if len(config.Forwarder.SecondaryTargets) > 0 {
+			targets := append([]string{config.ForwardingTarget()}, config.Forwarder.SecondaryTargets...)
+			txPublisher = NewRoutedForwarder(targets, &config.Forwarder)
 		} else {
 			txPublisher = NewForwarder(config.ForwardingTarget())
 		}
@@ -679,6 +878,9 @@ func createNodeImpl(
 			return nil, err
 		}
 	}
+	if sequencer != nil {
+		sequencer.setBackpressureSources(nil, coordinator)
+	}
 	if config.PreCheckTxs {
 		txPublisher = NewTxPreChecker(txPublisher, l2BlockChain)
 	}
@@ -686,19 +888,85 @@ func createNodeImpl(
 	if err != nil {
 		return nil, err
 	}
-	backend, err := arbitrum.NewBackend(stack, &config.RPC, chainDb, arbInterface, txStreamer)
-	if err != nil {
-		return nil, err
+	// The local blockchain isn't kept up to date when executing remotely or in light mode, so the eth API isn't served
+	var backend *arbitrum.Backend
+	if remoteExecution != nil {
+		log.Info("not serving the eth API, which the execution node serves", "url", config.Execution.URL)
+	} else if config.LightVerifier.Enable {
+		log.Info("not serving the eth API, as blocks aren't executed in light mode")
+	} else {
+		backend, err = arbitrum.NewBackend(stack, &config.RPC, chainDb, arbInterface, txStreamer)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var broadcastClients []*broadcastclient.BroadcastClient
 	if config.Feed.Input.Enable() {
-		for _, address := range config.Feed.Input.URLs {
-			broadcastClients = append(broadcastClients, broadcastclient.NewBroadcastClient(address, nil, config.Feed.Input.Timeout, txStreamer))
+		compression, err := wsbroadcastserver.ParseCompression(config.Feed.Input.Compression)
+		if err != nil {
+			return nil, err
+		}
+		var verifier *broadcastclient.SignatureVerifier
+		if config.Feed.Input.Verify.Enable {
+			var sequencers broadcastclient.SequencerChecker
+			if config.L1Reader.Enable && deployInfo != nil {
+				seqInboxCaller, err := bridgegen.NewSequencerInboxCaller(deployInfo.SequencerInbox, l1client)
+				if err != nil {
+					return nil, err
+				}
+				sequencers = das.NewBatchPosterVerifier(seqInboxCaller)
+			}
+			verifier, err = broadcastclient.NewSignatureVerifier(&config.Feed.Input.Verify, l2BlockChain.Config().ChainID.Uint64(), sequencers)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var deduplicator *broadcastclient.Deduplicator
+		if len(config.Feed.Input.URLs) > 1 {
+			// connected to every feed at once, following whichever is fastest
+			deduplicator = broadcastclient.NewDeduplicator(txStreamer)
 		}
+		for i, address := range config.Feed.Input.URLs {
+			var clientStreamer broadcastclient.TransactionStreamerInterface = txStreamer
+			if deduplicator != nil {
+				clientStreamer = deduplicator.Source()
+			}
+			client := broadcastclient.NewBroadcastClient(address, nil, config.Feed.Input.Timeout, clientStreamer)
+			client.SetCompression(compression)
+			client.SetToken(config.Feed.Input.Token)
+			client.SetSignatureVerifier(verifier)
+			if config.Feed.Input.RecordPath != "" {
+				path := config.Feed.Input.RecordPath
+				if len(config.Feed.Input.URLs) > 1 {
+					path = fmt.Sprintf("%v.%v", path, i)
+				}
+				recorder, err := broadcastclient.CreateFeedRecorder(path)
+				if err != nil {
+					return nil, err
+				}
+				client.SetRecorder(recorder)
+			}
+			broadcastClients = append(broadcastClients, client)
+		}
+	}
+	var feedReplayer *broadcastclient.FeedReplayer
+	if config.Feed.Input.ReplayPath != "" {
+		feedReplayer = broadcastclient.NewFeedReplayer(config.Feed.Input.ReplayPath, config.Feed.Input.ReplaySpeed, txStreamer)
+	}
+	advisories, err := NewFeedAdvisories(&config.Sequencer.Advisories, broadcastServer, broadcastClients)
+	if err != nil {
+		return nil, err
 	}
 	if !config.L1Reader.Enable {
-		return &Node{backend, arbInterface, nil, txStreamer, txPublisher, nil, nil, nil, nil, nil, nil, nil, broadcastServer, broadcastClients, coordinator, nil, classicOutbox}, nil
+		if !config.Sequencer.Enable {
+			if len(broadcastClients) == 0 && feedReplayer == nil {
+				log.Warn("no L1 reader and no feed input configured; node will not receive any messages")
+			} else {
+				log.Warn("running in feed-only mode without L1: all data is unfinalized and trusted from the sequencer feed", "feeds", config.Feed.Input.URLs)
+			}
+		}
+		return &Node{backend, arbInterface, nil, txStreamer, txPublisher, sequencer, nil, nil, nil, nil, nil, nil, nil, broadcastServer, broadcastClients, coordinator, nil, classicOutbox, nil, nil, feeTokenPrice, featureFlags, advisories, grpcFeed, feedReplayer, nil, nil, statePruner}, nil
 	}
 
 	if deployInfo == nil {
@@ -718,6 +986,12 @@ func createNodeImpl(
 	}
 	if dataAvailabilityService != nil {
 		if config.BatchPoster.Enable {
+			if config.BatchPoster.DryRun {
+				dataAvailabilityService, err = das.NewDryRunDAS(dataAvailabilityService)
+				if err != nil {
+					return nil, err
+				}
+			}
 			if daSigner != nil {
 				dataAvailabilityService, err = das.NewStoreSigningDAS(dataAvailabilityService, daSigner)
 				if err != nil {
@@ -746,20 +1020,36 @@ func createNodeImpl(
 	if err != nil {
 		return nil, err
 	}
+	if config.InboxReader.ArchiveURL != "" {
+		archiveClient, err := ethclient.DialContext(ctx, config.InboxReader.ArchiveURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to L1 archive node %v: %w", config.InboxReader.ArchiveURL, err)
+		}
+		err = inboxReader.SetArchive(archiveClient)
+		if err != nil {
+			return nil, err
+		}
+	}
 	txStreamer.SetInboxReader(inboxReader)
 
-	nitroMachineConfig := validator.DefaultNitroMachineConfig
-	if config.Wasm.RootPath != "" {
-		nitroMachineConfig.RootPath = config.Wasm.RootPath
-	} else {
-		execfile, err := os.Executable()
+	nitroMachineLoader := validator.NewNitroMachineLoader(config.Wasm.NitroMachineConfig())
+
+	var machineSnapshots *validator.MachineSnapshotStore
+	if config.BlockValidator.MachineSnapshots.Enable {
+		snapshotConfig := &config.BlockValidator.MachineSnapshots
+		machineSnapshots, err = validator.NewMachineSnapshotStore(snapshotConfig, stack.ResolvePath(snapshotConfig.Path))
 		if err != nil {
-			panic(err)
+			return nil, err
+		}
+	}
+
+	var validatorCoordinator *validator.ValidatorCoordinator
+	if config.ValidatorCoordinator.Enable {
+		validatorCoordinator, err = validator.NewValidatorCoordinator(&config.ValidatorCoordinator)
+		if err != nil {
+			return nil, err
 		}
-		targetDir := filepath.Dir(filepath.Dir(execfile))
-		nitroMachineConfig.RootPath = filepath.Join(targetDir, "machines")
 	}
-	nitroMachineLoader := validator.NewNitroMachineLoader(nitroMachineConfig)
 
 	var blockValidator *validator.BlockValidator
 	if config.BlockValidator.Enable {
@@ -767,6 +1057,16 @@ func createNodeImpl(
 		if err != nil {
 			return nil, err
 		}
+		if machineSnapshots != nil {
+			blockValidator.SetMachineSnapshots(machineSnapshots)
+		}
+		if validatorCoordinator != nil {
+			blockValidator.SetCoordinator(validatorCoordinator)
+		}
+		if statePruner != nil {
+			// Keep the state of the blocks yet to be validated, which validation re-executes from
+			statePruner.SetMinBlock(blockValidator.LastBlockValidated)
+		}
 	}
 
 	var staker *validator.Staker
@@ -780,6 +1080,55 @@ func createNodeImpl(
 		if err != nil {
 			return nil, err
 		}
+		if remoteExecution != nil {
+			err = staker.SetRemoteBlocks(newExecutionBlockSource(remoteExecution, l2BlockChain.Config().ArbitrumChainParams.GenesisBlockNum))
+			if err != nil {
+				return nil, err
+			}
+		}
+		staker.SetIntentLog(validator.NewStakerIntentLog(rawdb.NewTable(arbDb, stakerIntentPrefix)))
+		staker.SetChallengeProgressStore(validator.NewChallengeProgressStore(rawdb.NewTable(arbDb, challengeProgressPrefix)))
+		staker.SetMachineSnapshots(machineSnapshots)
+		if validatorCoordinator != nil {
+			staker.SetCoordinator(validatorCoordinator)
+		}
+		challengeOpts, confirmationOpts, err := validator.StakerRoleTransactOpts(ctx, l1client, &config.Validator.Wallets)
+		if err != nil {
+			return nil, err
+		}
+		if err := staker.SetRoleWallets(challengeOpts, confirmationOpts); err != nil {
+			return nil, err
+		}
+	}
+
+	var lightVerifier *validator.LightVerifier
+	if config.LightVerifier.Enable {
+		lightVerifier, err = validator.NewLightVerifier(ctx, &config.LightVerifier, l1Reader, deployInfo.Rollup, sequencerInbox, deployInfo.SequencerInbox, inboxTracker, inboxReader, txStreamer, validator.NewWatchtowerAlerter(&config.Validator.Alerts))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if sequencer != nil {
+		sequencer.setBackpressureSources(inboxTracker, coordinator)
+	}
+
+	var inboxMirror *InboxMirror
+	if config.InboxMirror.Enable {
+		inboxMirror, err = NewInboxMirror(arbDb, inboxReader, nil, &config.InboxMirror)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var snapshotPublisher *SnapshotPublisher
+	if config.SnapshotPublisher.Enable {
+		ancientDir := filepath.Join(stack.ResolvePath("l2chaindata"), "ancient")
+		workDir := stack.ResolvePath("snapshot-publisher")
+		snapshotPublisher, err = NewSnapshotPublisher(&config.SnapshotPublisher, chainDb, arbDb, ancientDir, workDir, l2BlockChain, txStreamer, inboxTracker, nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var batchPoster *BatchPoster
@@ -788,7 +1137,11 @@ func createNodeImpl(
 		if txOpts == nil {
 			return nil, errors.New("batchposter, but no TxOpts")
 		}
-		batchPoster, err = NewBatchPoster(l1Reader, inboxTracker, txStreamer, &config.BatchPoster, deployInfo.SequencerInbox, txOpts, dataAvailabilityService)
+		fallbackOpts, err := FallbackBatchPosterOpts(ctx, l1client, &config.BatchPoster.FallbackWallet, config.BatchPoster.FallbackAccounts)
+		if err != nil {
+			return nil, err
+		}
+		batchPoster, err = NewBatchPoster(l1Reader, inboxTracker, txStreamer, &config.BatchPoster, deployInfo.SequencerInbox, deployInfo.Inbox, txOpts, fallbackOpts, dataAvailabilityService, feeTokenPrice, featureFlags)
 		if err != nil {
 			return nil, err
 		}
@@ -801,8 +1154,11 @@ func createNodeImpl(
 	} else if config.Sequencer.Enable {
 		return nil, errors.New("sequencer and l1 reader, without delayed sequencer")
 	}
+	if config.Sequencer.Enable {
+		advisories.computeFrom(inboxReader, txStreamer)
+	}
 
-	return &Node{backend, arbInterface, l1Reader, txStreamer, txPublisher, deployInfo, inboxReader, inboxTracker, delayedSequencer, batchPoster, blockValidator, staker, broadcastServer, broadcastClients, coordinator, dasLifecycleManager, classicOutbox}, nil
+	return &Node{backend, arbInterface, l1Reader, txStreamer, txPublisher, sequencer, deployInfo, inboxReader, inboxTracker, delayedSequencer, batchPoster, blockValidator, staker, broadcastServer, broadcastClients, coordinator, dasLifecycleManager, classicOutbox, inboxMirror, snapshotPublisher, feeTokenPrice, featureFlags, advisories, grpcFeed, feedReplayer, validatorCoordinator, lightVerifier, statePruner}, nil
 }
 
 type L1ReaderCloser struct {
@@ -889,16 +1245,22 @@ func SetUpDataAvailability(
 
 	// This function builds up the DataAvailabilityService with the following topology, starting from the leaves.
 	/*
-			      ChainFetchDAS → Bigcache → Redis →
+			      ChainFetchDAS | ResolutionChainDAS (if specified, trying each source in its order:
+			                      local → REST endpoints → committee members → chain) →
+			      Bigcache → Redis →
 				       RPC Aggregator
 				       | SignAfterStoreDAS →
 				              FallbackDAS (if the REST client aggregator was specified)
 				              (primary) → RedundantStorage (if multiple persistent backing stores were specified)
 				                            → S3
+				                            → IPFS
 				                            → DiskStorage
 				                            → Database
 				         (fallback only)→ RESTful client aggregator
 
+			      Mirror (if specified) → RESTful client aggregator of the mirror's sources,
+			                              storing into the primary
+
 		          → : X--delegates to-->Y
 		          | : Exclusive OR
 
@@ -909,6 +1271,36 @@ func SetUpDataAvailability(
 	}
 	hasPersistentStorage := topLevelStorageService != nil
 
+	// Mirror other DASes into the persistent storage, before it's wrapped so the mirror doesn't read through to the
+	// REST aggregator
+	if config.MirrorConfig.Enable {
+		if !hasPersistentStorage {
+			return nil, nil, errors.New("data-availability.mirror requires a -storage mode to mirror into")
+		}
+		if l1Reader == nil || seqInboxAddress == nil {
+			return nil, nil, errors.New("l1-node-url and sequencer-inbox-address must be specified along with mirror.enable")
+		}
+		mirror, err := das.NewMirror(ctx, &config.MirrorConfig, topLevelStorageService, l1Reader, *seqInboxAddress)
+		if err != nil {
+			return nil, nil, err
+		}
+		mirror.Start(ctx)
+		dasLifecycleManager.Register(mirror)
+	}
+
+	// Sample committee members for the data of recent certificates they signed
+	if config.SamplerConfig.Enable {
+		if l1Reader == nil || seqInboxAddress == nil {
+			return nil, nil, errors.New("l1-node-url and sequencer-inbox-address must be specified along with sampler.enable")
+		}
+		sampler, err := das.NewSampler(&config.SamplerConfig, l1Reader, *seqInboxAddress)
+		if err != nil {
+			return nil, nil, err
+		}
+		sampler.Start(ctx)
+		dasLifecycleManager.Register(sampler)
+	}
+
 	// Create the REST aggregator if one was requested. If other storage types were enabled above, then
 	// the REST aggregator is used as the fallback to them.
 	if config.RestfulClientAggregatorConfig.Enable {
@@ -972,7 +1364,7 @@ func SetUpDataAvailability(
 		}
 
 		// TODO rename StorageServiceDASAdapter
-		topLevelDas, err = das.NewSignAfterStoreDASWithSeqInboxCaller(
+		signAfterStoreDAS, err := das.NewSignAfterStoreDASWithSeqInboxCaller(
 			ctx,
 			config.KeyConfig,
 			_seqInboxCaller,
@@ -982,6 +1374,16 @@ func SetUpDataAvailability(
 		if err != nil {
 			return nil, nil, err
 		}
+		if l1Reader != nil {
+			signAfterStoreDAS.SetL1BlockNumberSource(func(ctx context.Context) (uint64, error) {
+				header, err := l1Reader.LastHeader(ctx)
+				if err != nil {
+					return 0, err
+				}
+				return header.Number.Uint64(), nil
+			})
+		}
+		topLevelDas = signAfterStoreDAS
 	} else {
 		topLevelDas = das.NewReadLimitedDataAvailabilityService(topLevelStorageService)
 	}
@@ -1004,7 +1406,47 @@ func SetUpDataAvailability(
 		topLevelDas = das.NewCacheStorageToDASAdapter(topLevelDas, cache)
 	}
 
-	if topLevelDas != nil && seqInbox != nil {
+	if topLevelDas != nil && config.ResolutionChainConfig.Enable {
+		// The chain resolves from the sources in the configured order, the above being the local source
+		resolutionConfig := &config.ResolutionChainConfig
+		readers := map[string]arbstate.DataAvailabilityReader{
+			das.ResolutionSourceLocal: topLevelDas,
+		}
+		if len(resolutionConfig.RestUrls) > 0 {
+			restConfig := das.DefaultRestfulClientAggregatorConfig
+			restConfig.Enable = true
+			restConfig.Urls = resolutionConfig.RestUrls
+			restAgg, err := das.NewRestfulClientAggregator(ctx, &restConfig)
+			if err != nil {
+				return nil, nil, err
+			}
+			restAgg.Start(ctx)
+			dasLifecycleManager.Register(restAgg)
+			readers[das.ResolutionSourceRest] = restAgg
+		}
+		committeeBackends := resolutionConfig.CommitteeBackends
+		if committeeBackends == "" {
+			committeeBackends = config.AggregatorConfig.Backends
+		}
+		if committeeBackends != "" {
+			committee, err := dasrpc.NewCommitteeReader(committeeBackends)
+			if err != nil {
+				return nil, nil, err
+			}
+			readers[das.ResolutionSourceCommittee] = committee
+		}
+		if seqInbox != nil {
+			chainReader, err := das.NewChainFetchReaderWithSeqInbox(das.NewEmptyStorageService(), seqInbox)
+			if err != nil {
+				return nil, nil, err
+			}
+			readers[das.ResolutionSourceChain] = chainReader
+		}
+		topLevelDas, err = das.NewResolutionChainDAS(topLevelDas, resolutionConfig, readers)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if topLevelDas != nil && seqInbox != nil {
 		topLevelDas, err = das.NewChainFetchDASWithSeqInbox(topLevelDas, seqInbox)
 		if err != nil {
 			return nil, nil, err
@@ -1047,6 +1489,18 @@ func CreateNode(
 	if err != nil {
 		return nil, err
 	}
+	// The chain database heals missing state on reads if it was opened with a healer
+	stateHealer := statehealer.FromDatabase(chainDb)
+	if stateHealer == nil {
+		stateHealer, err = statehealer.Open(ctx, &config.StateHealer, chainDb)
+		if err != nil {
+			return nil, err
+		}
+	}
+	currentNode.StateHealer = stateHealer
+	if stateHealer != nil && currentNode.BlockValidator != nil {
+		currentNode.BlockValidator.SetStateHealer(stateHealer)
+	}
 	var apis []rpc.API
 	if currentNode.BlockValidator != nil {
 		apis = append(apis, rpc.API{
@@ -1057,6 +1511,204 @@ func CreateNode(
 		})
 	}
 
+	if currentNode.SnapshotPublisher != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &SnapshotAPI{publisher: currentNode.SnapshotPublisher},
+			Public:    false,
+		})
+	}
+
+	if currentNode.StatePruner != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &StatePrunerAPI{pruner: currentNode.StatePruner},
+			Public:    false,
+		})
+	}
+
+	if config.Execution.Serve {
+		apis = append(apis, rpc.API{
+			Namespace: "execution",
+			Version:   "1.0",
+			Service:   NewExecutionServerAPI(NewBlockchainExecution(l2BlockChain)),
+			Public:    false,
+		})
+	}
+
+	if currentNode.Staker != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &StakerAPI{staker: currentNode.Staker},
+			Public:    false,
+		})
+	}
+
+	var feedURLs []string
+	if config.Feed.Input.Enable() {
+		feedURLs = config.Feed.Input.URLs
+	}
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service: &ArbSyncAPI{
+			txStreamer: currentNode.TxStreamer,
+			feedURLs:   feedURLs,
+		},
+		Public: false,
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service: &ArbFeeAPI{
+			feeTokenPrice: currentNode.FeeTokenPrice,
+		},
+		Public: true,
+	})
+	// registered after geth's eth APIs, so this eth_feeHistory takes precedence
+	apis = append(apis, rpc.API{
+		Namespace: "eth",
+		Version:   "1.0",
+		Service: &FeeHistoryAPI{
+			blockchain: l2BlockChain,
+			config:     &config.FeeHistory,
+		},
+		Public: true,
+	})
+
+	if currentNode.InboxReader != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &DelayedInboxAPI{inboxReader: currentNode.InboxReader},
+			Public:    true,
+		})
+	}
+
+	if currentNode.InboxTracker != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &L1BlockIndexAPI{inboxTracker: currentNode.InboxTracker},
+			Public:    true,
+		})
+	}
+
+	var inboxAddress *common.Address
+	if deployInfo != nil {
+		inboxAddress = &deployInfo.Inbox
+	}
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service: &ArbRetryableAPI{
+			blockchain: l2BlockChain,
+			chainDb:    chainDb,
+			inbox:      inboxAddress,
+		},
+		Public: true,
+	})
+
+	buildAPI, err := NewArbBuildAPI(l2BlockChain, config.Wasm.NitroMachineConfig(), &config.Attestation, daSigner)
+	if err != nil {
+		return nil, err
+	}
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service:   buildAPI,
+		Public:    false,
+	})
+
+	if currentNode.BatchPoster != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service: &BatchPosterAPI{
+				batchPoster: currentNode.BatchPoster,
+			},
+			Public: false,
+		})
+	}
+
+	if currentNode.Backend != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   &ArbTransactionAPI{publisher: currentNode.TxPublisher},
+			Public:    true,
+		})
+
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service: &ArbReceiptsAPI{
+				blockchain: l2BlockChain,
+				config:     &config.BlockReceipts,
+			},
+			Public: true,
+		})
+	}
+
+	if config.Sequencer.Enable && config.Sequencer.SoftConfirmation.Enable {
+		softConfirmationAPI, err := NewArbSoftConfirmationAPI(currentNode.TxPublisher, l2BlockChain, chainDb, &config.Sequencer.SoftConfirmation, daSigner)
+		if err != nil {
+			return nil, err
+		}
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   softConfirmationAPI,
+			Public:    true,
+		})
+	}
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service:   &ArbSoftConfirmationVerifierAPI{blockchain: l2BlockChain},
+		Public:    true,
+	})
+	if currentNode.Sequencer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &ArbPendingTxAPI{sequencer: currentNode.Sequencer},
+			Public:    true,
+		}, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &ArbBackpressureAPI{sequencer: currentNode.Sequencer},
+			Public:    true,
+		})
+	}
+
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service:   &ArbAdvisoryAPI{advisories: currentNode.Advisories},
+		Public:    true,
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "admin",
+		Version:   "1.0",
+		Service:   &ArbAdminAPI{broadcastServer: currentNode.BroadcastServer},
+		Public:    false,
+	})
+
+	if currentNode.SeqCoordinator != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   &ArbSeqCoordinatorAPI{coordinator: currentNode.SeqCoordinator},
+			Public:    false,
+		})
+	}
+
 	apis = append(apis, rpc.API{
 		Namespace: "arbdebug",
 		Version:   "1.0",
@@ -1064,9 +1716,19 @@ func CreateNode(
 			blockchain:        l2BlockChain,
 			blockRangeBound:   config.RPC.ArbDebug.BlockRangeBound,
 			timeoutQueueBound: config.RPC.ArbDebug.TimeoutQueueBound,
+			limits:            &config.DebugLimits,
+			stateHealer:       stateHealer,
 		},
 		Public: false,
 	})
+	if currentNode.Backend != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewDebugTraceAPI(currentNode.Backend.APIBackend(), &config.DebugLimits),
+			Public:    false,
+		})
+	}
 	stack.RegisterAPIs(apis)
 
 	stack.RegisterLifecycle(arbNodeLifecycle{currentNode})
@@ -1075,11 +1737,12 @@ func CreateNode(
 
 func (n *Node) Start(ctx context.Context) error {
 	n.ArbInterface.Initialize(n)
-	err := n.Backend.Start()
-	if err != nil {
-		return err
+	if n.Backend != nil {
+		if err := n.Backend.Start(); err != nil {
+			return err
+		}
 	}
-	err = n.TxPublisher.Initialize(ctx)
+	err := n.TxPublisher.Initialize(ctx)
 	if err != nil {
 		return err
 	}
@@ -1093,6 +1756,10 @@ func (n *Node) Start(ctx context.Context) error {
 			return err
 		}
 	}
+	n.FeatureFlags.Start(ctx)
+	if n.FeeTokenPrice != nil {
+		n.FeeTokenPrice.Start(ctx)
+	}
 	n.TxStreamer.Start(ctx)
 	if n.InboxReader != nil {
 		err = n.InboxReader.Start(ctx)
@@ -1113,6 +1780,21 @@ func (n *Node) Start(ctx context.Context) error {
 	if n.BatchPoster != nil {
 		n.BatchPoster.Start(ctx)
 	}
+	if n.InboxMirror != nil {
+		n.InboxMirror.Start(ctx)
+	}
+	if n.SnapshotPublisher != nil {
+		n.SnapshotPublisher.Start(ctx)
+	}
+	if n.ValidatorCoordinator != nil {
+		n.ValidatorCoordinator.Start(ctx)
+	}
+	if n.LightVerifier != nil {
+		n.LightVerifier.Start(ctx)
+	}
+	if n.StatePruner != nil {
+		n.StatePruner.Start(ctx)
+	}
 	if n.Staker != nil {
 		err = n.Staker.Initialize(ctx)
 		if err != nil {
@@ -1141,16 +1823,36 @@ func (n *Node) Start(ctx context.Context) error {
 			return err
 		}
 	}
+	if n.GRPCFeed != nil {
+		err = n.GRPCFeed.Start(ctx)
+		if err != nil {
+			return err
+		}
+	}
 	for _, client := range n.BroadcastClients {
 		client.Start(ctx)
 	}
+	if n.FeedReplayer != nil {
+		err = n.FeedReplayer.Start(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	n.Advisories.Start(ctx)
 	return nil
 }
 
 func (n *Node) StopAndWait() {
+	n.Advisories.StopAndWait()
+	if n.FeedReplayer != nil {
+		n.FeedReplayer.StopAndWait()
+	}
 	for _, client := range n.BroadcastClients {
 		client.StopAndWait()
 	}
+	if n.GRPCFeed != nil {
+		n.GRPCFeed.StopAndWait()
+	}
 	if n.BroadcastServer != nil {
 		n.BroadcastServer.StopAndWait()
 	}
@@ -1160,6 +1862,21 @@ func (n *Node) StopAndWait() {
 	if n.BlockValidator != nil {
 		n.BlockValidator.StopAndWait()
 	}
+	if n.ValidatorCoordinator != nil {
+		n.ValidatorCoordinator.StopAndWait()
+	}
+	if n.LightVerifier != nil {
+		n.LightVerifier.StopAndWait()
+	}
+	if n.InboxMirror != nil {
+		n.InboxMirror.StopAndWait()
+	}
+	if n.SnapshotPublisher != nil {
+		n.SnapshotPublisher.StopAndWait()
+	}
+	if n.StatePruner != nil {
+		n.StatePruner.StopAndWait()
+	}
 	if n.BatchPoster != nil {
 		n.BatchPoster.StopAndWait()
 	}
@@ -1174,13 +1891,24 @@ func (n *Node) StopAndWait() {
 		n.SeqCoordinator.StopAndWait()
 	}
 	n.TxStreamer.StopAndWait()
+	if n.FeeTokenPrice != nil {
+		n.FeeTokenPrice.StopAndWait()
+	}
+	n.FeatureFlags.StopAndWait()
 	n.ArbInterface.BlockChain().Stop()
-	if err := n.Backend.Stop(); err != nil {
-		log.Error("backend stop", "err", err)
+	if n.Backend != nil {
+		if err := n.Backend.Stop(); err != nil {
+			log.Error("backend stop", "err", err)
+		}
 	}
 	if n.DASLifecycleManager != nil {
 		n.DASLifecycleManager.StopAndWaitUntil(2 * time.Second)
 	}
+	if n.StateHealer != nil {
+		if err := n.StateHealer.Close(); err != nil {
+			log.Error("state healer close", "err", err)
+		}
+	}
 }
 
 func CreateDefaultStackForTest(dataDir string) (*node.Node, error) {
@@ -1325,11 +2053,26 @@ func shouldPreserveFalse(header *types.Header) bool {
 	return false
 }
 
+var ErrExternalSignerCantSignHashes = errors.New("an external signer can't sign the hashes DAS stores and build attestations are signed with")
+
 func GetSignerFromWallet(
 	walletConfig *genericconf.WalletConfig,
 ) (func([]byte) ([]byte, error), error) {
 	var signer func(data []byte) ([]byte, error)
 
+	if walletConfig.ExternalSigner.Enabled() {
+		// The external signer protocol has no way to sign raw hashes, only whole transactions
+		return nil, ErrExternalSignerCantSignHashes
+	}
+	if walletConfig.KMS.Enabled() {
+		kms, err := util.DialKMS(context.Background(), &walletConfig.KMS)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) {
+			return kms.SignHash(context.Background(), data)
+		}, nil
+	}
 	if len(walletConfig.PrivateKey) != 0 {
 		privateKey, err := crypto.HexToECDSA(walletConfig.PrivateKey)
 		if err != nil {
@@ -0,0 +1,227 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/retryables"
+	"github.com/offchainlabs/nitro/arbos/util"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+	"github.com/offchainlabs/nitro/solgen/go/precompilesgen"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+const (
+	RetryableStatusRedeemable = "redeemable"
+	RetryableStatusRedeemed   = "redeemed"
+	RetryableStatusExpired    = "expired"
+	RetryableStatusUnknown    = "unknown"
+)
+
+// RetryableCall is a transaction to send, less its gas and fees, which the sender should estimate.
+type RetryableCall struct {
+	To    common.Address `json:"to"`
+	Data  hexutil.Bytes  `json:"data"`
+	Value *hexutil.Big   `json:"value,omitempty"`
+}
+
+// RetryableRedeemPlan says how to recover a retryable: redeem it on L2 if it's still alive, or
+// submit it again through the L1 inbox if it has expired.
+type RetryableRedeemPlan struct {
+	TicketId common.Hash     `json:"ticketId"`
+	Status   string          `json:"status"`
+	Reason   string          `json:"reason,omitempty"`
+	Timeout  *hexutil.Uint64 `json:"timeout,omitempty"`
+	NumTries *hexutil.Uint64 `json:"numTries,omitempty"`
+	// An L2 transaction to ArbRetryableTx; its gas must cover the retryable's own gas limit
+	Redeem *RetryableCall `json:"redeem,omitempty"`
+	// An L1 transaction to the delayed inbox recreating the retryable, with its fees at current prices
+	Recreate *RetryableCall `json:"recreate,omitempty"`
+}
+
+type ArbRetryableAPI struct {
+	blockchain *core.BlockChain
+	chainDb    ethdb.Database
+	inbox      *common.Address // nil if the node doesn't know the rollup's contracts
+}
+
+var (
+	inboxABI     abi.ABI
+	inboxABIErr  error
+	inboxABIOnce sync.Once
+)
+
+func loadInboxABI() (abi.ABI, error) {
+	inboxABIOnce.Do(func() {
+		inboxABI, inboxABIErr = abi.JSON(strings.NewReader(bridgegen.InboxABI))
+	})
+	return inboxABI, inboxABIErr
+}
+
+// RetryableRedeemPlan constructs the transactions needed to redeem or recreate a retryable, or reports
+// why that isn't possible, based on the latest block.
+func (api *ArbRetryableAPI) RetryableRedeemPlan(ctx context.Context, ticketId common.Hash) (*RetryableRedeemPlan, error) {
+	plan := &RetryableRedeemPlan{TicketId: ticketId}
+
+	tx, _, submissionBlock, _ := rawdb.ReadTransaction(api.chainDb, ticketId)
+	if tx == nil {
+		plan.Status = RetryableStatusUnknown
+		plan.Reason = "no retryable was submitted with this ticket id"
+		return plan, nil
+	}
+	submission, ok := tx.GetInner().(*types.ArbitrumSubmitRetryableTx)
+	if !ok {
+		plan.Status = RetryableStatusUnknown
+		plan.Reason = "transaction isn't a retryable submission"
+		return plan, nil
+	}
+
+	header := api.blockchain.CurrentBlock().Header()
+	state, _, err := stateAndHeader(api.blockchain, header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	retryable, err := state.RetryableState().OpenRetryable(ticketId, header.Time)
+	if err != nil {
+		return nil, err
+	}
+	if retryable != nil {
+		timeout, err := retryable.CalculateTimeout()
+		if err != nil {
+			return nil, err
+		}
+		numTries, err := retryable.NumTries()
+		if err != nil {
+			return nil, err
+		}
+		data, err := util.PackArbRetryableTxRedeem(ticketId)
+		if err != nil {
+			return nil, err
+		}
+		plan.Status = RetryableStatusRedeemable
+		plan.Timeout = (*hexutil.Uint64)(&timeout)
+		plan.NumTries = (*hexutil.Uint64)(&numTries)
+		plan.Redeem = &RetryableCall{
+			To:   types.ArbRetryableTxAddress,
+			Data: data,
+		}
+		return plan, nil
+	}
+
+	// The retryable is gone, either because one of its redeems succeeded or because it expired.
+	// Recreating a retryable that was redeemed would execute it twice, so check every redeem.
+	redeemed, err := findSuccessfulRedeem(ctx, api.blockchain, ticketId, submissionBlock, header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	if redeemed {
+		plan.Status = RetryableStatusRedeemed
+		plan.Reason = "a redeem of the retryable succeeded"
+		return plan, nil
+	}
+	plan.Status = RetryableStatusExpired
+	plan.Reason = "the retryable expired without being redeemed"
+	if api.inbox == nil {
+		plan.Reason += ", and this node doesn't know the inbox to recreate it through"
+		return plan, nil
+	}
+	if submission.RetryTo == nil {
+		plan.Reason += ", and contract creations can't be recreated as retryables"
+		return plan, nil
+	}
+
+	l1BaseFee, err := state.L1PricingState().PricePerUnit()
+	if err != nil {
+		return nil, err
+	}
+	l2BaseFee, err := state.L2PricingState().BaseFeeWei()
+	if err != nil {
+		return nil, err
+	}
+	submissionFee := arbmath.BigMax(retryables.RetryableSubmissionFee(len(submission.RetryData), l1BaseFee), submission.MaxSubmissionFee)
+	maxFeePerGas := arbmath.BigMax(arbmath.BigMulByUint(l2BaseFee, 2), submission.GasFeeCap)
+	inbox, err := loadInboxABI()
+	if err != nil {
+		return nil, err
+	}
+	data, err := inbox.Pack(
+		"createRetryableTicket",
+		*submission.RetryTo,
+		submission.RetryValue,
+		submissionFee,
+		submission.FeeRefundAddr,
+		submission.Beneficiary,
+		arbmath.UintToBig(submission.Gas),
+		maxFeePerGas,
+		submission.RetryData,
+	)
+	if err != nil {
+		return nil, err
+	}
+	value := arbmath.BigAdd(submission.RetryValue, submissionFee)
+	value.Add(value, arbmath.BigMulByUint(maxFeePerGas, submission.Gas))
+	plan.Recreate = &RetryableCall{
+		To:    *api.inbox,
+		Data:  data,
+		Value: (*hexutil.Big)(value),
+	}
+	return plan, nil
+}
+
+// retryableHistory is the part of the blockchain needed to find a retryable's redeems.
+type retryableHistory interface {
+	GetHeaderByNumber(number uint64) *types.Header
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+}
+
+// findSuccessfulRedeem checks whether any redeem of a retryable, automatic or manual, succeeded between
+// its submission and the given block. ArbOS executes a redeem's retry in the block that scheduled it.
+func findSuccessfulRedeem(ctx context.Context, history retryableHistory, ticketId common.Hash, from uint64, to uint64) (bool, error) {
+	for number := from; number <= to; number++ {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		header := history.GetHeaderByNumber(number)
+		if header == nil {
+			return false, fmt.Errorf("missing header of block %v", number)
+		}
+		if !types.BloomLookup(header.Bloom, types.ArbRetryableTxAddress) || !types.BloomLookup(header.Bloom, ticketId) {
+			continue
+		}
+		receipts := history.GetReceiptsByHash(header.Hash())
+		if receipts == nil {
+			return false, fmt.Errorf("missing receipts of block %v", number)
+		}
+		retries := make(map[common.Hash]struct{})
+		for _, receipt := range receipts {
+			if _, ok := retries[receipt.TxHash]; ok && receipt.Status == types.ReceiptStatusSuccessful {
+				return true, nil
+			}
+			for _, log := range receipt.Logs {
+				if log.Address != types.ArbRetryableTxAddress || len(log.Topics) < 2 || log.Topics[0] != arbos.RedeemScheduledEventID || log.Topics[1] != ticketId {
+					continue
+				}
+				event := &precompilesgen.ArbRetryableTxRedeemScheduled{}
+				if err := util.ParseRedeemScheduledLog(event, log); err != nil {
+					return false, err
+				}
+				retries[event.RetryTxHash] = struct{}{}
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/solgen/go/precompilesgen"
+)
+
+type testRetryableHistory struct {
+	headers  []*types.Header
+	receipts map[common.Hash]types.Receipts
+}
+
+func (h *testRetryableHistory) addBlock(receipts ...*types.Receipt) {
+	header := &types.Header{
+		Number: big.NewInt(int64(len(h.headers))),
+		Bloom:  types.CreateBloom(receipts),
+	}
+	h.headers = append(h.headers, header)
+	h.receipts[header.Hash()] = receipts
+}
+
+func (h *testRetryableHistory) GetHeaderByNumber(number uint64) *types.Header {
+	if number >= uint64(len(h.headers)) {
+		return nil
+	}
+	return h.headers[number]
+}
+
+func (h *testRetryableHistory) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	return h.receipts[hash]
+}
+
+func redeemScheduledReceipt(t *testing.T, ticketId common.Hash, retryTxHash common.Hash) *types.Receipt {
+	retryableABI, err := abi.JSON(strings.NewReader(precompilesgen.ArbRetryableTxABI))
+	Require(t, err)
+	data, err := retryableABI.Events["RedeemScheduled"].Inputs.NonIndexed().Pack(uint64(0), common.Address{}, common.Big0, common.Big0)
+	Require(t, err)
+	return &types.Receipt{
+		Status: types.ReceiptStatusSuccessful,
+		TxHash: crypto.Keccak256Hash(ticketId.Bytes(), retryTxHash.Bytes()),
+		Logs: []*types.Log{{
+			Address: types.ArbRetryableTxAddress,
+			Topics:  []common.Hash{arbos.RedeemScheduledEventID, ticketId, retryTxHash, {}},
+			Data:    data,
+		}},
+	}
+}
+
+func retryReceipt(retryTxHash common.Hash, status uint64) *types.Receipt {
+	return &types.Receipt{Status: status, TxHash: retryTxHash}
+}
+
+func TestFindSuccessfulRedeem(t *testing.T) {
+	ctx := context.Background()
+	ticket := common.HexToHash("0x7ec")
+	other := common.HexToHash("0x07e")
+	history := &testRetryableHistory{receipts: make(map[common.Hash]types.Receipts)}
+
+	// block 0: the submission's automatic redeem fails
+	history.addBlock(redeemScheduledReceipt(t, ticket, common.HexToHash("0x1")), retryReceipt(common.HexToHash("0x1"), types.ReceiptStatusFailed))
+	// block 1: another retryable is redeemed
+	history.addBlock(redeemScheduledReceipt(t, other, common.HexToHash("0x2")), retryReceipt(common.HexToHash("0x2"), types.ReceiptStatusSuccessful))
+	// block 2: a manual redeem succeeds
+	history.addBlock(redeemScheduledReceipt(t, ticket, common.HexToHash("0x3")), retryReceipt(common.HexToHash("0x3"), types.ReceiptStatusSuccessful))
+
+	redeemed, err := findSuccessfulRedeem(ctx, history, ticket, 0, 1)
+	Require(t, err)
+	if redeemed {
+		Fail(t, "retryable redeemed by a failed redeem or another retryable's redeem")
+	}
+	redeemed, err = findSuccessfulRedeem(ctx, history, ticket, 0, 2)
+	Require(t, err)
+	if !redeemed {
+		Fail(t, "manual redeem not found")
+	}
+
+	// A redeem whose block or receipts are missing can't be ruled out
+	if _, err := findSuccessfulRedeem(ctx, history, other, 2, 3); err == nil {
+		Fail(t, "missing header ignored")
+	}
+	delete(history.receipts, history.headers[2].Hash())
+	if _, err := findSuccessfulRedeem(ctx, history, ticket, 0, 2); err == nil {
+		Fail(t, "missing receipts ignored")
+	}
+}
@@ -5,12 +5,23 @@ package arbnode
 
 var (
 	blockValidatorPrefix     string = "v"         // the prefix for all block validator keys
+	stakerIntentPrefix       string = "i"         // the prefix for all staker intent log keys
+	challengeProgressPrefix  string = "g"         // the prefix for all challenge progress keys
 	messagePrefix            []byte = []byte("m") // maps a message sequence number to a message
 	delayedMessagePrefix     []byte = []byte("d") // maps a delayed sequence number to an accumulator and a message
 	sequencerBatchMetaPrefix []byte = []byte("s") // maps a batch sequence number to BatchMetadata
 	delayedSequencedPrefix   []byte = []byte("a") // maps a delayed message count to the first sequencer batch sequence number with this delayed count
+	batchLedgerPrefix        []byte = []byte("l") // maps a batch sequence number to the batch's posting cost and collected L1 fees
+	l1BlockBatchPrefix       []byte = []byte("b") // maps an L1 block number and batch sequence number to the batch's message count
+	batchMessageCountPrefix  []byte = []byte("c") // maps a batch's message count to its sequence number, for batches which added messages
+	mirroredDelayedPrefix    []byte = []byte("r") // maps a delayed sequence number already mirrored to the accumulator it was mirrored with
+	mirroredBatchPrefix      []byte = []byte("t") // maps a batch sequence number already mirrored to the accumulator it was mirrored with
 
-	messageCountKey        []byte = []byte("_messageCount")        // contains the current message count
-	delayedMessageCountKey []byte = []byte("_delayedMessageCount") // contains the current delayed message count
-	sequencerBatchCountKey []byte = []byte("_sequencerBatchCount") // contains the current sequencer message count
+	messageCountKey         []byte = []byte("_messageCount")         // contains the current message count
+	delayedMessageCountKey  []byte = []byte("_delayedMessageCount")  // contains the current delayed message count
+	sequencerBatchCountKey  []byte = []byte("_sequencerBatchCount")  // contains the current sequencer message count
+	inboxMirrorCursorKey    []byte = []byte("_inboxMirrorCursor")    // contains the delayed and batch counts already mirrored
+	blockIndexBatchCountKey []byte = []byte("_blockIndexBatchCount") // contains the number of batches in the L1 block index
+	sequencerQueueKey       []byte = []byte("_sequencerQueue")       // contains the transactions queued by the sequencer when it last stopped
+	feedOnlyMessageCountKey []byte = []byte("_feedOnlyMessageCount") // contains the message count written without L1, until L1 batches cover it
 )
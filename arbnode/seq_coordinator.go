@@ -34,6 +34,8 @@ const MSG_COUNT_KEY string = "coordinator.msgCount"            // Only written b
 const PRIORITIES_KEY string = "coordinator.priorities"         // Read only
 const LIVELINESS_KEY_PREFIX string = "coordinator.liveliness." // Per server. Only written by self
 const MESSAGE_KEY_PREFIX string = "coordinator.msg."           // Per Message. Only written by sequencer holding CHOSEN
+const HANDOFF_KEY string = "coordinator.handoff"               // Only written by sequencer holding CHOSEN. Expires
+const HANDOFF_ACK_KEY string = "coordinator.handoffAck"        // Only written by the handoff successor
 const LIVELINESS_VAL string = "OK"
 const INVALID_VAL string = "INVALID"
 const INVALID_URL string = "<?INVALID-URL?>"
@@ -51,10 +53,16 @@ type SeqCoordinator struct {
 	prevChosenSequencer string
 	reportedAlive       bool
 
-	lockoutUntil int64 // atomic
+	lockoutUntil int64  // atomic
+	syncLag      uint64 // atomic, messages in redis not yet read as of the last update
 
 	chosenUpdateMutex sync.Mutex // mannages access to chosenOneUpdate
 	redisErrors       int        // error counter, from wrokthread
+
+	handoffMutex  sync.Mutex // held for the duration of a handoff
+	handoffState  int32      // atomic
+	statusMutex   sync.Mutex
+	handoffStatus SeqCoordinatorHandoffStatus
 }
 
 type SeqCoordinatorConfig struct {
@@ -71,6 +79,7 @@ type SeqCoordinatorConfig struct {
 	MyUrl                   string                        `koanf:"my-url"`
 	SigningKey              string                        `koanf:"signing-key"`
 	FallbackVerificationKey string                        `koanf:"fallback-verification-key"`
+	Handoff                 SeqCoordinatorHandoffConfig   `koanf:"handoff"`
 	Dangerous               SeqCoordinatorDangerousConfig `koanf:"dangerous"`
 }
 
@@ -90,6 +99,7 @@ func SeqCoordinatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Uint16(prefix+".msg-per-poll", uint16(DefaultSeqCoordinatorConfig.MaxMsgPerPoll), "will only be marked live if not too far behind")
 	f.String(prefix+".my-url", DefaultSeqCoordinatorConfig.MyUrl, "a 32-byte (64-character) hex string used to sign messages, or a path to a file containing it")
 	f.String(prefix+".signing-key", DefaultSeqCoordinatorConfig.SigningKey, "")
+	SeqCoordinatorHandoffConfigAddOptions(prefix+".handoff", f)
 	SeqCoordinatorDangerousConfigAddOptions(prefix+".dangerous", f)
 }
 
@@ -110,6 +120,7 @@ var DefaultSeqCoordinatorConfig = SeqCoordinatorConfig{
 	MaxMsgPerPoll:         2000,
 	MyUrl:                 INVALID_URL,
 	SigningKey:            "",
+	Handoff:               DefaultSeqCoordinatorHandoffConfig,
 	Dangerous:             DefaultSeqCoordinatorDangerousConfig,
 }
 
@@ -129,6 +140,7 @@ var TestSeqCoordinatorConfig = SeqCoordinatorConfig{
 	MaxMsgPerPoll:   20,
 	MyUrl:           INVALID_URL,
 	SigningKey:      "b561f5d5d98debc783aa8a1472d67ec3bcd532a1c8d95e5cb23caa70c649f7c9",
+	Handoff:         TestSeqCoordinatorHandoffConfig,
 	Dangerous: SeqCoordinatorDangerousConfig{
 		DisableSignatureVerification: false,
 	},
@@ -213,7 +225,17 @@ func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, redisUrl st
 	return nil
 }
 
-func (c *SeqCoordinator) recommendLiveSequencer(ctx context.Context) (string, error) {
+func (c *SeqCoordinator) recommendLiveSequencer(ctx context.Context, handoffTarget string) (string, error) {
+	if handoffTarget != "" {
+		// a sequencer being handed off to takes precedence over the priorities while it's live
+		err := c.client.Get(ctx, livelinessKeyFor(handoffTarget)).Err()
+		if err == nil {
+			return handoffTarget, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return "", err
+		}
+	}
 	prioritiesString, err := c.client.Get(ctx, PRIORITIES_KEY).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -322,6 +344,9 @@ func (c *SeqCoordinator) chosenOneUpdate(ctx context.Context, msgCountExpected,
 	}
 	c.chosenUpdateMutex.Lock()
 	defer c.chosenUpdateMutex.Unlock()
+	if lastmsg != nil && atomic.LoadInt32(&c.handoffState) != handoffNone {
+		return fmt.Errorf("%w: handing off to another sequencer", ErrRetrySequencer)
+	}
 	lockoutUntil := time.Now().Add(c.config.LockoutDuration)
 	err := c.client.Watch(ctx, func(tx *redis.Tx) error {
 		current, err := tx.Get(ctx, CHOSENSEQ_KEY).Result()
@@ -523,12 +548,21 @@ func (c *SeqCoordinator) updatePrevKnownChosen(ctx context.Context, nextChosen s
 }
 
 func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
-	chosenSeq, err := c.recommendLiveSequencer(ctx)
+	handoffTarget, err := c.getHandoffTarget(ctx)
+	if err != nil {
+		log.Warn("coordinator failed reading handoff target", "err", err)
+		return c.retryAfterRedisError()
+	}
+	chosenSeq, err := c.recommendLiveSequencer(ctx, handoffTarget)
 	if err != nil {
 		log.Warn("coordinator failed finding live sequencer", "err", err)
 		return c.retryAfterRedisError()
 	}
 	if c.prevChosenSequencer == c.config.MyUrl {
+		if atomic.LoadInt32(&c.handoffState) == handoffPending {
+			// hold on to the lockout until the successor acknowledges it's caught up
+			chosenSeq = c.config.MyUrl
+		}
 		return c.updatePrevKnownChosen(ctx, chosenSeq)
 	}
 	if chosenSeq != c.config.MyUrl && chosenSeq != c.prevChosenSequencer {
@@ -557,6 +591,11 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 		log.Warn("cannot get remote message count", "err", err)
 		return c.retryAfterRedisError()
 	}
+	if remoteMsgCount > localMsgCount {
+		atomic.StoreUint64(&c.syncLag, uint64(remoteMsgCount-localMsgCount))
+	} else {
+		atomic.StoreUint64(&c.syncLag, 0)
+	}
 	readUntil := remoteMsgCount
 	if readUntil > localMsgCount+c.config.MaxMsgPerPoll {
 		readUntil = localMsgCount + c.config.MaxMsgPerPoll
@@ -617,6 +656,13 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 		return c.noRedisError()
 	}
 
+	handingOffToMe := handoffTarget == c.config.MyUrl && chosenSeq == c.config.MyUrl
+	if handingOffToMe && localMsgCount >= remoteMsgCount {
+		if err := c.handoffAck(ctx, localMsgCount); err != nil {
+			log.Warn("coordinator failed to acknowledge handoff", "err", err)
+		}
+	}
+
 	// can take over as main sequencer?
 	if localMsgCount >= remoteMsgCount && chosenSeq == c.config.MyUrl {
 		if c.sequencer == nil {
@@ -631,6 +677,10 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 			if err := c.livelinessUpdate(ctx); err != nil {
 				log.Warn("failed to update liveliness", "err", err)
 			}
+			if handingOffToMe {
+				// the previous sequencer releases its lockout as soon as it sees the acknowledgement
+				return c.config.RetryInterval
+			}
 			return c.retryAfterRedisError()
 		}
 		log.Info("caught chosen-coordinator lock")
@@ -661,6 +711,10 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	if (livelinessErr != nil) || (msgReadErr != nil) {
 		return c.retryAfterRedisError()
 	}
+	if handingOffToMe {
+		c.redisErrors = 0
+		return c.config.RetryInterval
+	}
 	return c.noRedisError()
 }
 
@@ -724,6 +778,11 @@ func (c *SeqCoordinator) StopAndWait() {
 	c.client.Close()
 }
 
+// SyncLag returns how many messages behind redis this node was as of the coordinator's last update.
+func (c *SeqCoordinator) SyncLag() uint64 {
+	return atomic.LoadUint64(&c.syncLag)
+}
+
 func (c *SeqCoordinator) CurrentlyChosen() bool {
 	return time.Now().Before(atomicTimeRead(&c.lockoutUntil))
 }
@@ -732,6 +791,9 @@ func (c *SeqCoordinator) SequencingMessage(pos arbutil.MessageIndex, msg *arbsta
 	if !c.CurrentlyChosen() {
 		return fmt.Errorf("%w: not main sequencer", ErrRetrySequencer)
 	}
+	if atomic.LoadInt32(&c.handoffState) != handoffNone {
+		return fmt.Errorf("%w: handing off to another sequencer", ErrRetrySequencer)
+	}
 	if err := c.chosenOneUpdate(c.GetContext(), pos, pos+1, msg); err != nil {
 		return err
 	}
@@ -0,0 +1,325 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+type SeqCoordinatorHandoffConfig struct {
+	Timeout time.Duration `koanf:"timeout"`
+	Hold    time.Duration `koanf:"hold"`
+}
+
+func SeqCoordinatorHandoffConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".timeout", DefaultSeqCoordinatorHandoffConfig.Timeout, "bound on a whole handoff, after which it's aborted and this sequencer resumes if it hasn't released its lockout yet")
+	f.Duration(prefix+".hold", DefaultSeqCoordinatorHandoffConfig.Hold, "how long the successor of a handoff is preferred over the sequencer priorities, which should be updated within this time")
+}
+
+var DefaultSeqCoordinatorHandoffConfig = SeqCoordinatorHandoffConfig{
+	Timeout: 30 * time.Second,
+	Hold:    time.Hour,
+}
+
+var TestSeqCoordinatorHandoffConfig = SeqCoordinatorHandoffConfig{
+	Timeout: 5 * time.Second,
+	Hold:    time.Minute,
+}
+
+const (
+	handoffNone      int32 = iota
+	handoffPending         // no new messages are sequenced while the successor catches up
+	handoffReleasing       // the successor acknowledged, so the lockout is released to it
+)
+
+// Handoff states, as reported in SeqCoordinatorHandoffStatus
+const (
+	HandoffIdle              = "idle"
+	HandoffFlushing          = "flushing"
+	HandoffAwaitingSuccessor = "awaiting-successor"
+	HandoffReleasing         = "releasing"
+	HandoffComplete          = "complete"
+	HandoffFailed            = "failed"
+)
+
+type SeqCoordinatorHandoffStatus struct {
+	Successor         string `json:"successor,omitempty"`
+	State             string `json:"state"`
+	MsgCount          uint64 `json:"msgCount"`           // messages sequenced before handing off
+	SuccessorMsgCount uint64 `json:"successorMsgCount"`  // messages the successor acknowledged
+	Started           uint64 `json:"started,omitempty"`  // unix timestamp
+	Finished          uint64 `json:"finished,omitempty"` // unix timestamp
+	Error             string `json:"error,omitempty"`
+}
+
+func (c *SeqCoordinator) setHandoffStatus(update func(status *SeqCoordinatorHandoffStatus)) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	update(&c.handoffStatus)
+}
+
+// HandoffStatus returns the progress of the ongoing or last handoff.
+func (c *SeqCoordinator) HandoffStatus() SeqCoordinatorHandoffStatus {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	status := c.handoffStatus
+	if status.State == "" {
+		status.State = HandoffIdle
+	}
+	return status
+}
+
+func (c *SeqCoordinator) getHandoffTarget(ctx context.Context) (string, error) {
+	target, err := c.client.Get(ctx, HANDOFF_KEY).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return target, err
+}
+
+// handoffAck tells the sequencer handing off to this one how many messages this one has.
+func (c *SeqCoordinator) handoffAck(ctx context.Context, msgCount arbutil.MessageIndex) error {
+	var msgCountBytes [8]byte
+	binary.BigEndian.PutUint64(msgCountBytes[:], uint64(msgCount))
+	return c.client.Set(ctx, HANDOFF_ACK_KEY, c.signMessage([]byte(c.config.MyUrl), msgCountBytes[:]), c.config.Handoff.Timeout).Err()
+}
+
+func (c *SeqCoordinator) parseHandoffAck(successor string, data []byte) (arbutil.MessageIndex, error) {
+	msgCountBytes, err := c.verifyMessageSignature([]byte(successor), data)
+	if err != nil {
+		return 0, err
+	}
+	if len(msgCountBytes) != 8 {
+		return 0, fmt.Errorf("unexpected handoff acknowledgement length %v", len(msgCountBytes))
+	}
+	return arbutil.MessageIndex(binary.BigEndian.Uint64(msgCountBytes)), nil
+}
+
+func (c *SeqCoordinator) getHandoffAck(ctx context.Context, successor string) (arbutil.MessageIndex, bool, error) {
+	data, err := c.client.Get(ctx, HANDOFF_ACK_KEY).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	msgCount, err := c.parseHandoffAck(successor, []byte(data))
+	if err != nil {
+		// possibly left over from a handoff to another sequencer
+		log.Debug("ignoring handoff acknowledgement", "successor", successor, "err", err)
+		return 0, false, nil
+	}
+	return msgCount, true, nil
+}
+
+// startHandoff names the successor in redis, as long as this sequencer still holds the lockout.
+func (c *SeqCoordinator) startHandoff(ctx context.Context, successor string) error {
+	return c.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, CHOSENSEQ_KEY).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if current != c.config.MyUrl {
+			return fmt.Errorf("lost the lockout before handing off, redis shows chosen: %s", current)
+		}
+		pipe := tx.TxPipeline()
+		pipe.Del(ctx, HANDOFF_ACK_KEY)
+		pipe.Set(ctx, HANDOFF_KEY, successor, c.config.Handoff.Hold)
+		return execTestPipe(pipe, ctx)
+	}, CHOSENSEQ_KEY)
+}
+
+// abortHandoff retracts the handoff, if it still names the successor.
+func (c *SeqCoordinator) abortHandoff(ctx context.Context, successor string) error {
+	return c.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, HANDOFF_KEY).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if current != successor {
+			return nil
+		}
+		pipe := tx.TxPipeline()
+		pipe.Del(ctx, HANDOFF_KEY)
+		return execTestPipe(pipe, ctx)
+	}, HANDOFF_KEY)
+}
+
+// waitFor polls the condition every retry interval until it holds or the context is done.
+func (c *SeqCoordinator) waitFor(ctx context.Context, condition func() (bool, error)) error {
+	for {
+		done, err := condition()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.config.RetryInterval):
+		}
+	}
+}
+
+// Handoff hands sequencing over to the successor without losing messages: this sequencer stops sequencing
+// new messages, waits until all it sequenced are in redis and the successor acknowledges having them, then
+// releases its lockout to the successor and forwards transactions to it. Transactions received meanwhile
+// are held in the queue and forwarded once the lockout is released. If the successor doesn't catch up
+// within the handoff timeout, the handoff is aborted and this sequencer resumes.
+func (c *SeqCoordinator) Handoff(successor string) (SeqCoordinatorHandoffStatus, error) {
+	if successor == "" || successor == INVALID_URL || successor == c.config.MyUrl {
+		return c.HandoffStatus(), fmt.Errorf("invalid handoff successor \"%v\"", successor)
+	}
+	if c.sequencer == nil {
+		return c.HandoffStatus(), errors.New("not a sequencer")
+	}
+	if !c.handoffMutex.TryLock() {
+		return c.HandoffStatus(), errors.New("a handoff is already in progress")
+	}
+	defer c.handoffMutex.Unlock()
+	if !c.CurrentlyChosen() {
+		return c.HandoffStatus(), errors.New("not the chosen sequencer")
+	}
+
+	ctx, cancel := context.WithTimeout(c.GetContext(), c.config.Handoff.Timeout)
+	defer cancel()
+	c.setHandoffStatus(func(status *SeqCoordinatorHandoffStatus) {
+		*status = SeqCoordinatorHandoffStatus{
+			Successor: successor,
+			State:     HandoffFlushing,
+			Started:   uint64(time.Now().Unix()),
+		}
+	})
+	log.Info("handing off sequencer", "successor", successor)
+	err := c.handoff(ctx, successor)
+	if err != nil {
+		if atomic.CompareAndSwapInt32(&c.handoffState, handoffPending, handoffNone) {
+			abortCtx, abortCancel := context.WithTimeout(c.GetContext(), c.config.Handoff.Timeout)
+			if abortErr := c.abortHandoff(abortCtx, successor); abortErr != nil {
+				log.Warn("failed to retract aborted handoff", "successor", successor, "err", abortErr)
+			}
+			abortCancel()
+		} else {
+			// the lockout is being released to the successor already
+			atomic.StoreInt32(&c.handoffState, handoffNone)
+		}
+		log.Warn("sequencer handoff failed", "successor", successor, "err", err)
+		c.setHandoffStatus(func(status *SeqCoordinatorHandoffStatus) {
+			status.State = HandoffFailed
+			status.Error = err.Error()
+			status.Finished = uint64(time.Now().Unix())
+		})
+		return c.HandoffStatus(), err
+	}
+	atomic.StoreInt32(&c.handoffState, handoffNone)
+	log.Info("sequencer handoff complete", "successor", successor)
+	c.setHandoffStatus(func(status *SeqCoordinatorHandoffStatus) {
+		status.State = HandoffComplete
+		status.Finished = uint64(time.Now().Unix())
+	})
+	return c.HandoffStatus(), nil
+}
+
+func (c *SeqCoordinator) handoff(ctx context.Context, successor string) error {
+	// under the mutex, so no message is still being written to redis once it's set
+	c.chosenUpdateMutex.Lock()
+	atomic.StoreInt32(&c.handoffState, handoffPending)
+	c.chosenUpdateMutex.Unlock()
+
+	var msgCount arbutil.MessageIndex
+	err := c.waitFor(ctx, func() (bool, error) {
+		localMsgCount, err := c.streamer.GetMessageCount()
+		if err != nil {
+			return false, err
+		}
+		remoteMsgCount, err := c.GetRemoteMsgCount(ctx)
+		if err != nil {
+			return false, err
+		}
+		if localMsgCount > remoteMsgCount {
+			return false, fmt.Errorf("%v messages sequenced but only %v in redis", localMsgCount, remoteMsgCount)
+		}
+		// a message may be in redis but not yet stored locally
+		msgCount = localMsgCount
+		return localMsgCount == remoteMsgCount, nil
+	})
+	if err != nil {
+		return fmt.Errorf("flushing messages: %w", err)
+	}
+	if !c.CurrentlyChosen() {
+		return errors.New("lost the lockout while flushing messages")
+	}
+	if err := c.startHandoff(ctx, successor); err != nil {
+		return err
+	}
+	c.setHandoffStatus(func(status *SeqCoordinatorHandoffStatus) {
+		status.State = HandoffAwaitingSuccessor
+		status.MsgCount = uint64(msgCount)
+	})
+
+	err = c.waitFor(ctx, func() (bool, error) {
+		ackCount, acked, err := c.getHandoffAck(ctx, successor)
+		if err != nil || !acked {
+			return false, err
+		}
+		c.setHandoffStatus(func(status *SeqCoordinatorHandoffStatus) {
+			status.SuccessorMsgCount = uint64(ackCount)
+		})
+		return ackCount >= msgCount, nil
+	})
+	if err != nil {
+		return fmt.Errorf("awaiting successor: %w", err)
+	}
+
+	// the coordinator's update releases the lockout and forwards to the successor
+	c.setHandoffStatus(func(status *SeqCoordinatorHandoffStatus) {
+		status.State = HandoffReleasing
+	})
+	atomic.StoreInt32(&c.handoffState, handoffReleasing)
+	err = c.waitFor(ctx, func() (bool, error) {
+		if c.CurrentlyChosen() {
+			return false, nil
+		}
+		current, err := c.client.Get(ctx, CHOSENSEQ_KEY).Result()
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return current == successor, nil
+	})
+	if err != nil {
+		return fmt.Errorf("releasing lockout: %w", err)
+	}
+	return nil
+}
+
+// ArbSeqCoordinatorAPI hands sequencing over between coordinated sequencers.
+type ArbSeqCoordinatorAPI struct {
+	coordinator *SeqCoordinator
+}
+
+// SequencerHandoff hands sequencing over to the successor, returning once it's the chosen sequencer
+// or the handoff failed.
+func (a *ArbSeqCoordinatorAPI) SequencerHandoff(ctx context.Context, successor string) (SeqCoordinatorHandoffStatus, error) {
+	return a.coordinator.Handoff(successor)
+}
+
+func (a *ArbSeqCoordinatorAPI) SequencerHandoffStatus(ctx context.Context) (SeqCoordinatorHandoffStatus, error) {
+	return a.coordinator.HandoffStatus(), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+)
+
+func TestHandoffAck(t *testing.T) {
+	signingKey, err := loadSigningKey(TestSeqCoordinatorConfig.SigningKey)
+	Require(t, err)
+	successor := &SeqCoordinator{
+		config:     TestSeqCoordinatorConfig,
+		signingKey: signingKey,
+	}
+	successor.config.MyUrl = "http://successor:8547"
+	predecessor := &SeqCoordinator{
+		config:     TestSeqCoordinatorConfig,
+		signingKey: signingKey,
+	}
+	predecessor.config.MyUrl = "http://predecessor:8547"
+
+	var msgCountBytes [8]byte
+	msgCountBytes[7] = 42
+	ack := successor.signMessage([]byte(successor.config.MyUrl), msgCountBytes[:])
+	msgCount, err := predecessor.parseHandoffAck(successor.config.MyUrl, ack)
+	Require(t, err)
+	if msgCount != 42 {
+		Fail(t, "unexpected acknowledged message count", msgCount)
+	}
+	if _, err := predecessor.parseHandoffAck("http://other:8547", ack); err == nil {
+		Fail(t, "acknowledgement accepted for another successor")
+	}
+
+	if _, err := predecessor.Handoff(predecessor.config.MyUrl); err == nil {
+		Fail(t, "handed off to self")
+	}
+	if status := predecessor.HandoffStatus(); status.State != HandoffIdle {
+		Fail(t, "unexpected status before any handoff", status)
+	}
+}
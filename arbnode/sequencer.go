@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"math/big"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/log"
@@ -33,6 +35,9 @@ type txQueueItem struct {
 	tx         *types.Transaction
 	resultChan chan<- error
 	ctx        context.Context
+	queuedAt   time.Time
+	options    *ConditionalOptions
+	pending    *pendingTx // nil unless replacement is allowed
 }
 
 func (i *txQueueItem) returnResult(err error) {
@@ -48,6 +53,21 @@ type Sequencer struct {
 	l1Reader        *headerreader.HeaderReader
 	config          SequencerConfig
 	senderWhitelist map[common.Address]struct{}
+	orderingPolicy  OrderingPolicy // nil if transactions are sequenced in arrival order
+	spam            *spamFilter
+	nonceQueue      *nonceQueue
+	policy          *sequencingPolicy
+	pending         *pendingTxs
+	blockSpeed      *blockSpeedController // nil if blocks are produced at max-block-speed
+
+	lastBlockExecution time.Duration
+
+	backpressureTracker     *InboxTracker
+	backpressureCoordinator *SeqCoordinator
+	backpressure            atomic.Value // contains a *BackpressureStatus
+	lastQueueWait           int64        // atomic, how long the last transaction dequeued had waited
+
+	revertGuardExempt map[common.Address]struct{}
 
 	L1BlockAndTimeMutex sync.Mutex
 	l1BlockNumber       uint64
@@ -69,37 +89,113 @@ func NewSequencer(txStreamer *TransactionStreamer, l1Reader *headerreader.Header
 		}
 		senderWhitelist[common.HexToAddress(address)] = struct{}{}
 	}
+	var orderingPolicy OrderingPolicy
+	if config.TimeBoost.Enable {
+		if config.OrderingHook.URL != "" {
+			return nil, errors.New("sequencer time boost can't be used with an ordering hook")
+		}
+		orderingPolicy = NewTimeBoostOrderingPolicy(&config.TimeBoost)
+	} else if config.OrderingHook.URL != "" {
+		var err error
+		orderingPolicy, err = NewRemoteOrderingPolicy(context.Background(), config.OrderingHook.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ordering policy service: %w", err)
+		}
+	}
+	spam, err := newSpamFilter(&config.SpamProtection)
+	if err != nil {
+		return nil, err
+	}
+	revertGuardExempt, err := parseRevertGuardExemptions(config.RevertGuard.ExemptSenders)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := newSequencingPolicy(&config.Policy)
+	if err != nil {
+		return nil, err
+	}
+	var blockSpeed *blockSpeedController
+	if config.BlockSpeedTuning.Enable {
+		blockSpeed, err = newBlockSpeedController(&config.BlockSpeedTuning, config.MaxBlockSpeed)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &Sequencer{
-		txStreamer:      txStreamer,
-		txQueue:         make(chan txQueueItem, 128),
-		l1Reader:        l1Reader,
-		config:          config,
-		senderWhitelist: senderWhitelist,
-		l1BlockNumber:   0,
-		l1Timestamp:     0,
+		txStreamer:        txStreamer,
+		txQueue:           make(chan txQueueItem, 128),
+		l1Reader:          l1Reader,
+		config:            config,
+		senderWhitelist:   senderWhitelist,
+		orderingPolicy:    orderingPolicy,
+		spam:              spam,
+		nonceQueue:        newNonceQueue(&config.NonceQueue),
+		policy:            policy,
+		pending:           newPendingTxs(&config.PendingTx),
+		blockSpeed:        blockSpeed,
+		revertGuardExempt: revertGuardExempt,
+		l1BlockNumber:     0,
+		l1Timestamp:       0,
 	}, nil
 }
 
 var ErrRetrySequencer = errors.New("please retry transaction")
 
-func (s *Sequencer) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (s *Sequencer) PublishTransaction(ctx context.Context, tx *types.Transaction, options *ConditionalOptions) error {
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	sender, err := types.Sender(signer, tx)
+	if err != nil {
+		return err
+	}
 	if len(s.senderWhitelist) > 0 {
-		signer := types.LatestSigner(s.txStreamer.bc.Config())
-		sender, err := types.Sender(signer, tx)
-		if err != nil {
-			return err
-		}
 		_, authorized := s.senderWhitelist[sender]
 		if !authorized {
 			return errors.New("transaction sender is not on the whitelist")
 		}
 	}
+	err = s.policy.check(ctx, tx, sender)
+	if err != nil {
+		return err
+	}
+	err = s.checkBackpressure()
+	if err != nil {
+		return err
+	}
+	err = s.spam.admit(ctx, sender, tx.Nonce(), func() (uint64, error) {
+		statedb, err := s.txStreamer.bc.State()
+		if err != nil {
+			return 0, err
+		}
+		return statedb.GetNonce(sender), nil
+	})
+	if err != nil {
+		return err
+	}
+	defer s.spam.release(sender)
+	if options != nil {
+		// reject transactions whose conditions already don't hold, rather than queueing them
+		err = s.precheckConditions(options)
+		if err != nil {
+			return err
+		}
+	}
+	var pending *pendingTx
+	if s.config.PendingTx.AllowReplacement {
+		pending, err = s.pending.add(sender, tx)
+		if err != nil {
+			return err
+		}
+		defer s.pending.remove(sender, tx.Nonce(), pending)
+	}
 
 	resultChan := make(chan error, 1)
 	queueItem := txQueueItem{
 		tx,
 		resultChan,
 		ctx,
+		time.Now(),
+		options,
+		pending,
 	}
 	select {
 	case s.txQueue <- queueItem:
@@ -114,17 +210,48 @@ func (s *Sequencer) PublishTransaction(ctx context.Context, tx *types.Transactio
 	}
 }
 
-func (s *Sequencer) preTxFilter(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
-	return nil
+func (s *Sequencer) precheckConditions(options *ConditionalOptions) error {
+	head := s.txStreamer.bc.CurrentBlock().Header()
+	statedb, err := s.txStreamer.bc.StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	next := &types.Header{
+		Number: new(big.Int).Add(head.Number, common.Big1),
+		Time:   uint64(time.Now().Unix()),
+	}
+	if next.Time < head.Time {
+		next.Time = head.Time
+	}
+	return options.check(next, statedb)
+}
+
+func (s *Sequencer) preTxFilter(header *types.Header, statedb *state.StateDB, arbState *arbosState.ArbosState, tx *types.Transaction, sender common.Address, options *ConditionalOptions) error {
+	return options.check(header, statedb)
 }
 
 func (s *Sequencer) postTxFilter(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, receipt *types.Receipt) error {
 	if receipt.Status == types.ReceiptStatusFailed && receipt.GasUsed > dataGas && receipt.GasUsed-dataGas <= s.config.MaxRevertGasReject {
 		return vm.ErrExecutionReverted
 	}
+	if s.revertGuardRejects(tx, sender, receipt) {
+		return ErrRevertGuardRejected
+	}
 	return nil
 }
 
+// AddSequencingPredicate adds a predicate consulted for every transaction the sequencing policy's lists allow.
+// It must be called before the sequencer is started.
+func (s *Sequencer) AddSequencingPredicate(predicate SequencingPredicate) {
+	s.policy.predicates = append(s.policy.predicates, predicate)
+}
+
+// SetOrderingPolicy replaces the policy consulted before sequencing transactions, or removes it if nil.
+// It must be called before the sequencer is started.
+func (s *Sequencer) SetOrderingPolicy(policy OrderingPolicy) {
+	s.orderingPolicy = policy
+}
+
 func (s *Sequencer) ForwardTarget() string {
 	s.forwarderMutex.Lock()
 	defer s.forwarderMutex.Unlock()
@@ -159,7 +286,7 @@ func (s *Sequencer) forwardIfSet(queueItems []txQueueItem) bool {
 		return false
 	}
 	for _, item := range queueItems {
-		item.resultChan <- s.forwarder.PublishTransaction(item.ctx, item.tx)
+		item.resultChan <- s.forwarder.PublishTransaction(item.ctx, item.tx, item.options)
 	}
 	return true
 }
@@ -168,14 +295,34 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 	var txes types.Transactions
 	var queueItems []txQueueItem
 	var totalBatchSize int
+	released := s.releaseHeldTransactions()
 	for {
 		var queueItem txQueueItem
-		if len(txes) == 0 {
+		if len(released) > 0 {
+			queueItem = released[0]
+			released = released[1:]
+		} else if len(txes) == 0 {
+			var expiry *time.Timer
+			var expiryChan <-chan time.Time
+			if expiresAt, ok := s.nonceQueue.nextExpiry(); ok {
+				expiry = time.NewTimer(time.Until(expiresAt))
+				expiryChan = expiry.C
+			}
+			expired := false
 			select {
 			case queueItem = <-s.txQueue:
+			case <-expiryChan:
+				expired = true
 			case <-ctx.Done():
 				return
 			}
+			if expiry != nil {
+				expiry.Stop()
+			}
+			if expired {
+				// the next round rejects the expired held transactions
+				return
+			}
 		} else {
 			done := false
 			select {
@@ -192,6 +339,12 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 			queueItem.returnResult(err)
 			continue
 		}
+		err = s.checkPending(queueItem)
+		if err != nil {
+			queueItem.returnResult(err)
+			continue
+		}
+		atomic.StoreInt64(&s.lastQueueWait, int64(time.Since(queueItem.queuedAt)))
 		txBytes, err := queueItem.tx.MarshalBinary()
 		if err != nil {
 			queueItem.returnResult(err)
@@ -199,7 +352,7 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 		}
 		if len(txBytes) > int(maxTxDataSize) {
 			// This tx is too large
-			queueItem.returnResult(core.ErrOversizedData)
+			queueItem.returnResult(oversizedDataRejection(core.ErrOversizedData, uint64(len(txBytes)), maxTxDataSize))
 			continue
 		}
 		if totalBatchSize+len(txBytes) > int(maxTxDataSize) {
@@ -217,11 +370,26 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 		txes = append(txes, queueItem.tx)
 		queueItems = append(queueItems, queueItem)
 	}
+	for _, item := range released {
+		// the batch filled up before all the released transactions fit
+		select {
+		case s.txQueue <- item:
+		default:
+			item.returnResult(errors.New("queue full"))
+		}
+	}
 
 	if s.forwardIfSet(queueItems) {
 		return
 	}
 
+	if s.orderingPolicy != nil {
+		queueItems = s.applyOrderingPolicy(ctx, queueItems)
+		for i, item := range queueItems {
+			txes[i] = item.tx
+		}
+	}
+
 	timestamp := time.Now().Unix()
 	s.L1BlockAndTimeMutex.Lock()
 	l1Block := s.l1BlockNumber
@@ -247,13 +415,23 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 		L1BaseFee:   nil,
 	}
 
+	conditions := make(map[common.Hash]*ConditionalOptions)
+	for _, item := range queueItems {
+		if item.options != nil {
+			conditions[item.tx.Hash()] = item.options
+		}
+	}
 	hooks := &arbos.SequencingHooks{
-		PreTxFilter:            s.preTxFilter,
+		PreTxFilter: func(header *types.Header, statedb *state.StateDB, arbState *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+			return s.preTxFilter(header, statedb, arbState, tx, sender, conditions[tx.Hash()])
+		},
 		PostTxFilter:           s.postTxFilter,
 		DiscardInvalidTxsEarly: true,
 		TxErrors:               []error{},
 	}
+	executionStart := time.Now()
 	err := s.txStreamer.SequenceTransactions(header, txes, hooks)
+	s.lastBlockExecution = time.Since(executionStart)
 	if err == nil && len(hooks.TxErrors) != len(txes) {
 		err = fmt.Errorf("unexpected number of error results: %v vs number of txes %v", len(hooks.TxErrors), len(txes))
 	}
@@ -281,6 +459,8 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 		return
 	}
 
+	// Sequenced transactions are only reported as such once their block's message has been written
+	var sequenced []txQueueItem
 	for i, err := range hooks.TxErrors {
 		queueItem := queueItems[i]
 		if errors.Is(err, core.ErrGasLimit) {
@@ -293,10 +473,40 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 			default:
 			}
 		}
-		queueItem.returnResult(err)
+		if errors.Is(err, core.ErrNonceTooHigh) && s.holdFutureNonce(queueItem) {
+			continue
+		}
+		if err != nil {
+			queueItem.returnResult(s.txRejection(queueItem.tx, err))
+			continue
+		}
+		sequenced = append(sequenced, queueItem)
+	}
+	if len(sequenced) > 0 {
+		s.txStreamer.AfterSequencedWrite(func(err error) {
+			for _, queueItem := range sequenced {
+				queueItem.returnResult(err)
+			}
+		})
 	}
 }
 
+// txRejection adds the data a wallet needs to correct the transaction to its rejection error, where possible.
+func (s *Sequencer) txRejection(tx *types.Transaction, err error) error {
+	if !errors.Is(err, core.ErrNonceTooLow) && !errors.Is(err, core.ErrNonceTooHigh) {
+		return err
+	}
+	sender, senderErr := types.Sender(types.LatestSigner(s.txStreamer.bc.Config()), tx)
+	if senderErr != nil {
+		return err
+	}
+	statedb, stateErr := s.txStreamer.bc.State()
+	if stateErr != nil {
+		return err
+	}
+	return rejectionFromState(err, statedb, sender)
+}
+
 func (s *Sequencer) updateLatestL1Block(header *types.Header) {
 	s.L1BlockAndTimeMutex.Lock()
 	defer s.L1BlockAndTimeMutex.Unlock()
@@ -347,11 +557,56 @@ func (s *Sequencer) Start(ctxIn context.Context) error {
 	}
 
 	s.CallIteratively(func(ctx context.Context) time.Duration {
-		nextBlock := time.Now().Add(s.config.MaxBlockSpeed)
+		s.spam.prune()
+		return time.Minute
+	})
+
+	s.CallIteratively(s.updateBackpressure)
+
+	s.CallIteratively(func(ctx context.Context) time.Duration {
+		blockSpeed := s.config.MaxBlockSpeed
+		if s.blockSpeed != nil {
+			blockSpeed = s.blockSpeed.blockSpeed()
+		}
+		nextBlock := time.Now().Add(blockSpeed)
+		s.lastBlockExecution = 0
 		s.sequenceTransactions(ctx)
+		if s.blockSpeed != nil && s.lastBlockExecution > 0 {
+			s.blockSpeed.update(s.blockSpeedSample())
+		}
 		// Note: this may return a negative duration, but timers are fine with that (they treat negative durations as 0).
 		return time.Until(nextBlock)
 	})
 
+	if s.config.QueuePersistence.Enable {
+		s.LaunchThread(s.restoreQueue)
+	}
+
 	return nil
 }
+
+func (s *Sequencer) blockSpeedSample() *blockSpeedSample {
+	dirtyState, _ := s.txStreamer.bc.StateCache().TrieDB().Size()
+	var feedBacklog int
+	if s.txStreamer.broadcastServer != nil {
+		feedBacklog = s.txStreamer.broadcastServer.GetCachedMessageCount()
+	}
+	return &blockSpeedSample{
+		execution:   s.lastBlockExecution,
+		dirtyState:  uint64(dirtyState),
+		feedBacklog: feedBacklog,
+		at:          time.Now(),
+	}
+}
+
+func (s *Sequencer) StopAndWait() {
+	s.StopWaiter.StopAndWait()
+	if s.config.QueuePersistence.Enable {
+		if err := s.persistQueue(); err != nil {
+			log.Error("failed to save queued transactions", "err", err)
+		}
+	}
+	if err := s.policy.close(); err != nil {
+		log.Error("failed to close sequencing policy audit log", "err", err)
+	}
+}
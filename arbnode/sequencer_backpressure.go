@@ -0,0 +1,229 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	backpressureGauge           = metrics.NewRegisteredGaugeFloat64("arb/sequencer/backpressure/pressure", nil)
+	backpressureRejectedCounter = metrics.NewRegisteredCounter("arb/sequencer/backpressure/rejected", nil)
+)
+
+// BackpressureConfig sets the limits of each dimension of the sequencer's load. Once any is exceeded,
+// the sequencer is overloaded, and if rejection is enabled, new transactions are turned away with
+// an error telling the client when to retry, rather than being queued behind an ever growing backlog.
+type BackpressureConfig struct {
+	RejectWhenOverloaded bool          `koanf:"reject-when-overloaded"`
+	UpdateInterval       time.Duration `koanf:"update-interval"`
+	MaxQueueWait         time.Duration `koanf:"max-queue-wait"`
+	MaxFeedBacklog       int           `koanf:"max-feed-backlog"`
+	MaxBatchBacklog      uint64        `koanf:"max-batch-backlog"`
+	MaxCoordinatorLag    uint64        `koanf:"max-coordinator-lag"`
+	MinRetryAfter        time.Duration `koanf:"min-retry-after"`
+	MaxRetryAfter        time.Duration `koanf:"max-retry-after"`
+}
+
+func BackpressureConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".reject-when-overloaded", DefaultBackpressureConfig.RejectWhenOverloaded, "reject transactions with a retry-after error while the sequencer is overloaded instead of queueing them")
+	f.Duration(prefix+".update-interval", DefaultBackpressureConfig.UpdateInterval, "how often to measure the sequencer's load")
+	f.Duration(prefix+".max-queue-wait", DefaultBackpressureConfig.MaxQueueWait, "longest transactions may wait in the queue before the sequencer is overloaded (0 to ignore)")
+	f.Int(prefix+".max-feed-backlog", DefaultBackpressureConfig.MaxFeedBacklog, "most messages the feed may hold awaiting confirmation before the sequencer is overloaded (0 to ignore)")
+	f.Uint64(prefix+".max-batch-backlog", DefaultBackpressureConfig.MaxBatchBacklog, "most messages which may await posting to L1 before the sequencer is overloaded (0 to ignore)")
+	f.Uint64(prefix+".max-coordinator-lag", DefaultBackpressureConfig.MaxCoordinatorLag, "most messages the sequencer may lag behind the coordinator before it's overloaded (0 to ignore)")
+	f.Duration(prefix+".min-retry-after", DefaultBackpressureConfig.MinRetryAfter, "retry delay suggested to clients when the sequencer is just overloaded, growing with the load")
+	f.Duration(prefix+".max-retry-after", DefaultBackpressureConfig.MaxRetryAfter, "longest retry delay suggested to clients")
+}
+
+var DefaultBackpressureConfig = BackpressureConfig{
+	RejectWhenOverloaded: false,
+	UpdateInterval:       250 * time.Millisecond,
+	MaxQueueWait:         5 * time.Second,
+	MaxFeedBacklog:       100_000,
+	MaxBatchBacklog:      50_000,
+	MaxCoordinatorLag:    100,
+	MinRetryAfter:        time.Second,
+	MaxRetryAfter:        30 * time.Second,
+}
+
+const (
+	BackpressureQueueWait      = "queue-wait"
+	BackpressureFeedBacklog    = "feed-backlog"
+	BackpressureBatchBacklog   = "batch-backlog"
+	BackpressureCoordinatorLag = "coordinator-lag"
+)
+
+// BackpressureDimension is one kind of load on the sequencer. Durations are in seconds.
+type BackpressureDimension struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Limit float64 `json:"limit"`
+}
+
+func (d *BackpressureDimension) pressure() float64 {
+	return d.Value / d.Limit
+}
+
+// BackpressureStatus is the sequencer's load as of its last measurement.
+type BackpressureStatus struct {
+	Overloaded bool                    `json:"overloaded"`
+	Pressure   float64                 `json:"pressure"` // of the heaviest dimension relative to its limit
+	RetryAfter float64                 `json:"retryAfter,omitempty"`
+	Dimensions []BackpressureDimension `json:"dimensions"`
+	MeasuredAt time.Time               `json:"measuredAt"`
+}
+
+// SequencerOverloadedError is returned for transactions turned away while the sequencer is overloaded.
+// It implements geth's rpc.Error and rpc.DataError, and must be returned unwrapped for the RPC server to find them.
+type SequencerOverloadedError struct {
+	status *BackpressureStatus
+}
+
+func (e *SequencerOverloadedError) Error() string {
+	return fmt.Sprintf("sequencer overloaded, retry after %.0fs", math.Ceil(e.status.RetryAfter))
+}
+
+func (e *SequencerOverloadedError) ErrorCode() int {
+	// "limit exceeded", from EIP-1474
+	return -32005
+}
+
+func (e *SequencerOverloadedError) ErrorData() interface{} {
+	return e.status
+}
+
+// RetryAfter returns how long the client should wait before retrying.
+func (e *SequencerOverloadedError) RetryAfter() time.Duration {
+	return time.Duration(e.status.RetryAfter * float64(time.Second))
+}
+
+func (c *BackpressureConfig) retryAfter(pressure float64) time.Duration {
+	retryAfter := time.Duration(float64(c.MinRetryAfter) * pressure)
+	if retryAfter > c.MaxRetryAfter {
+		retryAfter = c.MaxRetryAfter
+	}
+	return retryAfter
+}
+
+func (c *BackpressureConfig) status(dimensions []BackpressureDimension, now time.Time) *BackpressureStatus {
+	status := &BackpressureStatus{
+		Dimensions: dimensions,
+		MeasuredAt: now,
+	}
+	for i := range dimensions {
+		if pressure := dimensions[i].pressure(); pressure > status.Pressure {
+			status.Pressure = pressure
+		}
+	}
+	if status.Pressure >= 1 {
+		status.Overloaded = true
+		status.RetryAfter = c.retryAfter(status.Pressure).Seconds()
+	}
+	return status
+}
+
+// setBackpressureSources sets where the sequencer measures its batch posting and coordinator backlogs.
+// Either may be nil. It must be called before the sequencer is started.
+func (s *Sequencer) setBackpressureSources(tracker *InboxTracker, coordinator *SeqCoordinator) {
+	s.backpressureTracker = tracker
+	s.backpressureCoordinator = coordinator
+}
+
+func (s *Sequencer) measureBackpressure() (*BackpressureStatus, error) {
+	config := &s.config.Backpressure
+	var dimensions []BackpressureDimension
+	if config.MaxQueueWait > 0 {
+		var wait time.Duration
+		if len(s.txQueue) > 0 {
+			wait = time.Duration(atomic.LoadInt64(&s.lastQueueWait))
+		}
+		dimensions = append(dimensions, BackpressureDimension{BackpressureQueueWait, wait.Seconds(), config.MaxQueueWait.Seconds()})
+	}
+	if config.MaxFeedBacklog > 0 && s.txStreamer.broadcastServer != nil {
+		backlog := s.txStreamer.broadcastServer.GetCachedMessageCount()
+		dimensions = append(dimensions, BackpressureDimension{BackpressureFeedBacklog, float64(backlog), float64(config.MaxFeedBacklog)})
+	}
+	if config.MaxBatchBacklog > 0 && s.backpressureTracker != nil {
+		messageCount, err := s.txStreamer.GetMessageCount()
+		if err != nil {
+			return nil, err
+		}
+		var posted uint64
+		batchCount, err := s.backpressureTracker.GetBatchCount()
+		if err != nil {
+			return nil, err
+		}
+		if batchCount > 0 {
+			meta, err := s.backpressureTracker.GetBatchMetadata(batchCount - 1)
+			if err != nil {
+				return nil, err
+			}
+			posted = uint64(meta.MessageCount)
+		}
+		var backlog uint64
+		if uint64(messageCount) > posted {
+			backlog = uint64(messageCount) - posted
+		}
+		dimensions = append(dimensions, BackpressureDimension{BackpressureBatchBacklog, float64(backlog), float64(config.MaxBatchBacklog)})
+	}
+	if config.MaxCoordinatorLag > 0 && s.backpressureCoordinator != nil {
+		lag := s.backpressureCoordinator.SyncLag()
+		dimensions = append(dimensions, BackpressureDimension{BackpressureCoordinatorLag, float64(lag), float64(config.MaxCoordinatorLag)})
+	}
+	return config.status(dimensions, time.Now()), nil
+}
+
+func (s *Sequencer) updateBackpressure(ctx context.Context) time.Duration {
+	status, err := s.measureBackpressure()
+	if err != nil {
+		log.Warn("failed to measure sequencer backpressure", "err", err)
+		return s.config.Backpressure.UpdateInterval
+	}
+	previous := s.Backpressure()
+	if status.Overloaded && (previous == nil || !previous.Overloaded) {
+		log.Warn("sequencer overloaded", "pressure", status.Pressure, "dimensions", status.Dimensions)
+	} else if !status.Overloaded && previous != nil && previous.Overloaded {
+		log.Info("sequencer no longer overloaded", "pressure", status.Pressure)
+	}
+	s.backpressure.Store(status)
+	backpressureGauge.Update(status.Pressure)
+	return s.config.Backpressure.UpdateInterval
+}
+
+// Backpressure returns the sequencer's load as of its last measurement, or nil if it hasn't been measured yet.
+func (s *Sequencer) Backpressure() *BackpressureStatus {
+	status, _ := s.backpressure.Load().(*BackpressureStatus)
+	return status
+}
+
+// checkBackpressure returns a SequencerOverloadedError if transactions should be turned away.
+func (s *Sequencer) checkBackpressure() error {
+	if !s.config.Backpressure.RejectWhenOverloaded {
+		return nil
+	}
+	status := s.Backpressure()
+	if status == nil || !status.Overloaded {
+		return nil
+	}
+	backpressureRejectedCounter.Inc(1)
+	return &SequencerOverloadedError{status}
+}
+
+type ArbBackpressureAPI struct {
+	sequencer *Sequencer
+}
+
+// SequencerBackpressure returns the sequencer's load, as arb_sequencerBackpressure.
+func (api *ArbBackpressureAPI) SequencerBackpressure() *BackpressureStatus {
+	return api.sequencer.Backpressure()
+}
@@ -0,0 +1,49 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestBackpressureStatus(t *testing.T) {
+	config := DefaultBackpressureConfig
+	config.MinRetryAfter = time.Second
+	config.MaxRetryAfter = 10 * time.Second
+	now := time.Now()
+
+	status := config.status([]BackpressureDimension{
+		{BackpressureQueueWait, 1, 5},
+		{BackpressureFeedBacklog, 50, 100},
+	}, now)
+	if status.Overloaded || status.Pressure != 0.5 || status.RetryAfter != 0 {
+		Fail(t, "unexpected status under the limits", status)
+	}
+
+	status = config.status([]BackpressureDimension{
+		{BackpressureQueueWait, 1, 5},
+		{BackpressureBatchBacklog, 300, 100},
+	}, now)
+	if !status.Overloaded || status.Pressure != 3 || status.RetryAfter != 3 {
+		Fail(t, "unexpected status over a limit", status)
+	}
+	status = config.status([]BackpressureDimension{{BackpressureCoordinatorLag, 1000, 10}}, now)
+	if status.RetryAfter != 10 {
+		Fail(t, "retry after not capped", status.RetryAfter)
+	}
+
+	var err error = &SequencerOverloadedError{status}
+	var rpcErr rpc.Error
+	var dataErr rpc.DataError
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != -32005 || !errors.As(err, &dataErr) || dataErr.ErrorData() != status {
+		Fail(t, "overloaded error doesn't carry its code and data", err)
+	}
+	if err.Error() != "sequencer overloaded, retry after 10s" {
+		Fail(t, "unexpected error message", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+)
+
+var blockSpeedGauge = metrics.NewRegisteredGauge("arb/sequencer/blockspeed/ms", nil)
+
+// BlockSpeedTuningConfig has the sequencer slow down block production while it's falling behind,
+// batching more transactions into each block, instead of building up an unbounded queue. The block
+// speed never goes faster than max-block-speed, and speeds back up as the load subsides.
+type BlockSpeedTuningConfig struct {
+	Enable                 bool          `koanf:"enable"`
+	SlowestBlockSpeed      time.Duration `koanf:"slowest-block-speed"`
+	TargetExecutionRatio   float64       `koanf:"target-execution-ratio"`
+	TargetStateGrowthBytes uint64        `koanf:"target-state-growth-bytes"`
+	TargetFeedBacklog      int           `koanf:"target-feed-backlog"`
+	MaxAdjustment          float64       `koanf:"max-adjustment"`
+}
+
+func BlockSpeedTuningConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBlockSpeedTuningConfig.Enable, "adjust the delay between blocks to the sequencer's load instead of always using max-block-speed")
+	f.Duration(prefix+".slowest-block-speed", DefaultBlockSpeedTuningConfig.SlowestBlockSpeed, "longest delay between blocks the sequencer slows down to")
+	f.Float64(prefix+".target-execution-ratio", DefaultBlockSpeedTuningConfig.TargetExecutionRatio, "fraction of the delay between blocks spent executing them, above which blocks are slowed down")
+	f.Uint64(prefix+".target-state-growth-bytes", DefaultBlockSpeedTuningConfig.TargetStateGrowthBytes, "bytes per second of uncommitted state growth above which blocks are slowed down (0 to ignore)")
+	f.Int(prefix+".target-feed-backlog", DefaultBlockSpeedTuningConfig.TargetFeedBacklog, "number of messages awaiting confirmation in the feed above which blocks are slowed down (0 to ignore)")
+	f.Float64(prefix+".max-adjustment", DefaultBlockSpeedTuningConfig.MaxAdjustment, "largest fraction the delay between blocks changes by after each block")
+}
+
+var DefaultBlockSpeedTuningConfig = BlockSpeedTuningConfig{
+	Enable:                 false,
+	SlowestBlockSpeed:      time.Second,
+	TargetExecutionRatio:   0.5,
+	TargetStateGrowthBytes: 64 * 1024 * 1024,
+	TargetFeedBacklog:      50000,
+	MaxAdjustment:          0.2,
+}
+
+// blockSpeedSample is what the sequencer observed while producing a block.
+type blockSpeedSample struct {
+	execution   time.Duration // time spent executing the block
+	dirtyState  uint64        // size of the state not yet committed to disk
+	feedBacklog int           // messages broadcast but not yet confirmed on L1
+	at          time.Time
+}
+
+// blockSpeedController is a feedback controller for the delay between blocks. Each block, it
+// measures the load relative to the configured targets, and scales the delay by up to
+// max-adjustment in proportion to how far the heaviest load is from its target.
+type blockSpeedController struct {
+	config  *BlockSpeedTuningConfig
+	fastest time.Duration
+	current time.Duration
+	last    *blockSpeedSample
+}
+
+func newBlockSpeedController(config *BlockSpeedTuningConfig, fastest time.Duration) (*blockSpeedController, error) {
+	if config.SlowestBlockSpeed < fastest {
+		return nil, errors.New("sequencer slowest-block-speed must not be faster than max-block-speed")
+	}
+	if config.TargetExecutionRatio <= 0 {
+		return nil, errors.New("sequencer block speed target-execution-ratio must be positive")
+	}
+	if config.MaxAdjustment <= 0 || config.MaxAdjustment >= 1 {
+		return nil, errors.New("sequencer block speed max-adjustment must be between 0 and 1")
+	}
+	blockSpeedGauge.Update(fastest.Milliseconds())
+	return &blockSpeedController{
+		config:  config,
+		fastest: fastest,
+		current: fastest,
+	}, nil
+}
+
+func (c *blockSpeedController) blockSpeed() time.Duration {
+	return c.current
+}
+
+// pressure returns the load relative to its target, 1 being on target, for the heaviest kind of load.
+func (c *blockSpeedController) pressure(sample *blockSpeedSample) float64 {
+	pressure := float64(sample.execution) / float64(c.current) / c.config.TargetExecutionRatio
+	if c.config.TargetStateGrowthBytes > 0 && c.last != nil && sample.dirtyState > c.last.dirtyState {
+		elapsed := sample.at.Sub(c.last.at).Seconds()
+		if elapsed > 0 {
+			growth := float64(sample.dirtyState-c.last.dirtyState) / elapsed
+			if p := growth / float64(c.config.TargetStateGrowthBytes); p > pressure {
+				pressure = p
+			}
+		}
+	}
+	if c.config.TargetFeedBacklog > 0 {
+		if p := float64(sample.feedBacklog) / float64(c.config.TargetFeedBacklog); p > pressure {
+			pressure = p
+		}
+	}
+	return pressure
+}
+
+// update adjusts the delay between blocks after a block was produced, and returns the new delay.
+func (c *blockSpeedController) update(sample *blockSpeedSample) time.Duration {
+	adjustment := c.pressure(sample) - 1
+	if adjustment > 1 {
+		adjustment = 1
+	} else if adjustment < -1 {
+		adjustment = -1
+	}
+	c.last = sample
+	next := time.Duration(float64(c.current) * (1 + adjustment*c.config.MaxAdjustment))
+	if next < c.fastest {
+		next = c.fastest
+	} else if next > c.config.SlowestBlockSpeed {
+		next = c.config.SlowestBlockSpeed
+	}
+	c.current = next
+	blockSpeedGauge.Update(next.Milliseconds())
+	return next
+}
@@ -0,0 +1,54 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockSpeedController(t *testing.T) {
+	config := DefaultBlockSpeedTuningConfig
+	config.TargetFeedBacklog = 1000
+	controller, err := newBlockSpeedController(&config, 100*time.Millisecond)
+	Require(t, err)
+	now := time.Now()
+
+	// a lightly loaded sequencer stays at the fastest speed
+	speed := controller.update(&blockSpeedSample{execution: 10 * time.Millisecond, at: now})
+	if speed != 100*time.Millisecond {
+		Fail(t, "slowed down without load", speed)
+	}
+
+	// spending all the time executing slows blocks down, but by no more than the maximum adjustment
+	speed = controller.update(&blockSpeedSample{execution: 100 * time.Millisecond, at: now.Add(time.Second)})
+	if speed != 120*time.Millisecond {
+		Fail(t, "unexpected block speed under execution load", speed)
+	}
+	for i := 0; i < 100; i++ {
+		speed = controller.update(&blockSpeedSample{execution: 10 * time.Second, at: now.Add(time.Second)})
+	}
+	if speed != config.SlowestBlockSpeed {
+		Fail(t, "block speed not capped at the slowest", speed)
+	}
+
+	// a feed backlog keeps blocks slow, even when they execute quickly
+	speed = controller.update(&blockSpeedSample{execution: time.Millisecond, feedBacklog: 2000, at: now.Add(2 * time.Second)})
+	if speed != config.SlowestBlockSpeed {
+		Fail(t, "sped up despite the feed backlog", speed)
+	}
+
+	// and blocks speed back up once the load subsides
+	for i := 0; i < 100; i++ {
+		speed = controller.update(&blockSpeedSample{execution: time.Millisecond, at: now.Add(3 * time.Second)})
+	}
+	if speed != 100*time.Millisecond {
+		Fail(t, "didn't speed back up", speed)
+	}
+
+	config.SlowestBlockSpeed = 10 * time.Millisecond
+	if _, err := newBlockSpeedController(&config, 100*time.Millisecond); err == nil {
+		Fail(t, "allowed a slowest block speed faster than the fastest")
+	}
+}
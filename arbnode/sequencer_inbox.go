@@ -8,11 +8,13 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/pkg/errors"
 
@@ -158,6 +160,16 @@ func (m *SequencerInboxBatch) Serialize(ctx context.Context, client arbutil.L1In
 		return m.serialized, nil
 	}
 
+	data, err := m.GetData(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	m.setData(data)
+	return m.serialized, nil
+}
+
+// setData caches the batch's serialization with the given batch data.
+func (m *SequencerInboxBatch) setData(data []byte) {
 	var fullData []byte
 
 	// Serialize the header
@@ -175,14 +187,9 @@ func (m *SequencerInboxBatch) Serialize(ctx context.Context, client arbutil.L1In
 	}
 
 	// Append the batch data
-	data, err := m.GetData(ctx, client)
-	if err != nil {
-		return nil, err
-	}
 	fullData = append(fullData, data...)
 
 	m.serialized = fullData
-	return fullData, nil
 }
 
 func (i *SequencerInbox) LookupBatchesInRange(ctx context.Context, from, to *big.Int) ([]*SequencerInboxBatch, error) {
@@ -196,6 +203,7 @@ func (i *SequencerInbox) LookupBatchesInRange(ctx context.Context, from, to *big
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	logs = dedupeLogs(logs)
 	messages := make([]*SequencerInboxBatch, 0, len(logs))
 	for _, log := range logs {
 		if log.Topics[0] != batchDeliveredID {
@@ -227,5 +235,29 @@ func (i *SequencerInbox) LookupBatchesInRange(ctx context.Context, from, to *big
 		}
 		messages = append(messages, batch)
 	}
+	// L1 clients don't all return logs in order, and the tracker requires consecutive batches
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].SequenceNumber < messages[j].SequenceNumber
+	})
 	return messages, nil
 }
+
+// dedupeLogs drops repeats of a log, identified by its transaction and index in the block, which some L1
+// clients return when a range is served from several backends.
+func dedupeLogs(logs []types.Log) []types.Log {
+	type logId struct {
+		txHash common.Hash
+		index  uint
+	}
+	seen := make(map[logId]struct{}, len(logs))
+	deduped := logs[:0]
+	for _, log := range logs {
+		id := logId{log.TxHash, log.Index}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		deduped = append(deduped, log)
+	}
+	return deduped
+}
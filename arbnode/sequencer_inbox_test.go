@@ -0,0 +1,51 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDedupeLogs(t *testing.T) {
+	var logs []types.Log
+	for i := 0; i < 10; i++ {
+		// two logs per transaction, told apart by their index in the block
+		logs = append(logs, types.Log{TxHash: common.BigToHash(common.Big1), Index: uint(i)})
+		logs = append(logs, types.Log{TxHash: common.Hash{byte(i)}, Index: uint(i)})
+	}
+	want := len(logs)
+	served := append([]types.Log{}, logs...)
+	served = append(served, logs...)
+	rand.New(rand.NewSource(1)).Shuffle(len(served), func(i, j int) {
+		served[i], served[j] = served[j], served[i]
+	})
+
+	deduped := dedupeLogs(served)
+	if len(deduped) != want {
+		Fail(t, "deduped to", len(deduped), "logs, expected", want)
+	}
+	seen := make(map[common.Hash]map[uint]bool)
+	for _, log := range deduped {
+		if seen[log.TxHash] == nil {
+			seen[log.TxHash] = make(map[uint]bool)
+		}
+		if seen[log.TxHash][log.Index] {
+			Fail(t, "log", log.Index, "of tx", log.TxHash, "kept twice")
+		}
+		seen[log.TxHash][log.Index] = true
+	}
+	for _, log := range logs {
+		if !seen[log.TxHash][log.Index] {
+			Fail(t, "log", log.Index, "of tx", log.TxHash, "dropped")
+		}
+	}
+
+	if len(dedupeLogs(nil)) != 0 {
+		Fail(t, "deduped nil logs to a non-empty list")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	nonceQueueHeldGauge       = metrics.NewRegisteredGauge("arb/sequencer/noncequeue/held", nil)
+	nonceQueueReleasedCounter = metrics.NewRegisteredCounter("arb/sequencer/noncequeue/released", nil)
+	nonceQueueExpiredCounter  = metrics.NewRegisteredCounter("arb/sequencer/noncequeue/expired", nil)
+	nonceQueueFullCounter     = metrics.NewRegisteredCounter("arb/sequencer/noncequeue/full", nil)
+)
+
+// NonceQueueConfig has the sequencer hold transactions with nonces too high to sequence, rather than rejecting
+// them, until the transactions filling the gap are sequenced or the TTL passes. Their senders wait meanwhile.
+type NonceQueueConfig struct {
+	Enable        bool          `koanf:"enable"`
+	TTL           time.Duration `koanf:"ttl"`
+	MaxPerAccount int           `koanf:"max-per-account"`
+	MaxSize       int           `koanf:"max-size"`
+}
+
+func NonceQueueConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultNonceQueueConfig.Enable, "hold transactions with future nonces until the nonce gap is filled instead of rejecting them")
+	f.Duration(prefix+".ttl", DefaultNonceQueueConfig.TTL, "how long to hold a transaction with a future nonce before rejecting it")
+	f.Int(prefix+".max-per-account", DefaultNonceQueueConfig.MaxPerAccount, "maximum transactions with future nonces held for one account")
+	f.Int(prefix+".max-size", DefaultNonceQueueConfig.MaxSize, "maximum transactions with future nonces held in total")
+}
+
+var DefaultNonceQueueConfig = NonceQueueConfig{
+	Enable:        false,
+	TTL:           time.Second,
+	MaxPerAccount: 16,
+	MaxSize:       1024,
+}
+
+type heldTx struct {
+	item   txQueueItem
+	expiry time.Time
+}
+
+// nonceQueue holds transactions with future nonces by sender, in nonce order.
+// It's only used by the sequencing thread, so it needs no locking.
+type nonceQueue struct {
+	config *NonceQueueConfig
+	held   map[common.Address][]*heldTx
+	size   int
+}
+
+func newNonceQueue(config *NonceQueueConfig) *nonceQueue {
+	return &nonceQueue{
+		config: config,
+		held:   make(map[common.Address][]*heldTx),
+	}
+}
+
+// hold keeps a transaction whose nonce is too high, returning false if it should be rejected instead.
+func (q *nonceQueue) hold(item txQueueItem, sender common.Address, now time.Time) bool {
+	if !q.config.Enable || item.ctx.Err() != nil {
+		return false
+	}
+	held := q.held[sender]
+	nonce := item.tx.Nonce()
+	index := sort.Search(len(held), func(i int) bool {
+		return held[i].item.tx.Nonce() >= nonce
+	})
+	if index < len(held) && held[index].item.tx.Nonce() == nonce {
+		if !held[index].item.pending.isReplaced() {
+			// another transaction with this nonce is already held
+			return false
+		}
+		held[index].item.returnResult(ErrTxReplaced)
+		held[index] = &heldTx{item, now.Add(q.config.TTL)}
+		return true
+	}
+	if q.size >= q.config.MaxSize || len(held) >= q.config.MaxPerAccount {
+		nonceQueueFullCounter.Inc(1)
+		return false
+	}
+	held = append(held, nil)
+	copy(held[index+1:], held[index:])
+	held[index] = &heldTx{item, now.Add(q.config.TTL)}
+	q.held[sender] = held
+	q.size++
+	nonceQueueHeldGauge.Update(int64(q.size))
+	return true
+}
+
+// release returns the held transactions which can now be sequenced, as the transactions before them have been,
+// in nonce order, and those which expired or whose senders stopped waiting.
+func (q *nonceQueue) release(now time.Time, currentNonce func(common.Address) uint64) ([]txQueueItem, []txQueueItem) {
+	var ready, expired []txQueueItem
+	for sender, held := range q.held {
+		next := currentNonce(sender)
+		var kept []*heldTx
+		for _, h := range held {
+			nonce := h.item.tx.Nonce()
+			if nonce <= next {
+				ready = append(ready, h.item)
+				if nonce == next {
+					next++
+				}
+			} else if now.After(h.expiry) || h.item.ctx.Err() != nil {
+				expired = append(expired, h.item)
+			} else {
+				kept = append(kept, h)
+			}
+		}
+		if len(kept) == 0 {
+			delete(q.held, sender)
+		} else {
+			q.held[sender] = kept
+		}
+	}
+	q.size -= len(ready) + len(expired)
+	nonceQueueHeldGauge.Update(int64(q.size))
+	nonceQueueReleasedCounter.Inc(int64(len(ready)))
+	nonceQueueExpiredCounter.Inc(int64(len(expired)))
+	return ready, expired
+}
+
+// nextExpiry returns when the first held transaction expires, if any are held.
+func (q *nonceQueue) nextExpiry() (time.Time, bool) {
+	var earliest time.Time
+	for _, held := range q.held {
+		for _, h := range held {
+			if earliest.IsZero() || h.expiry.Before(earliest) {
+				earliest = h.expiry
+			}
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// holdFutureNonce holds a transaction whose nonce was too high in the nonce queue, if it's enabled and has room.
+func (s *Sequencer) holdFutureNonce(item txQueueItem) bool {
+	if !s.config.NonceQueue.Enable {
+		return false
+	}
+	sender, err := types.Sender(types.LatestSigner(s.txStreamer.bc.Config()), item.tx)
+	if err != nil {
+		return false
+	}
+	return s.nonceQueue.hold(item, sender, time.Now())
+}
+
+// releaseHeldTransactions returns the held transactions ready to be sequenced, and rejects those which expired.
+func (s *Sequencer) releaseHeldTransactions() []txQueueItem {
+	if s.nonceQueue.size == 0 {
+		return nil
+	}
+	statedb, err := s.txStreamer.bc.State()
+	if err != nil {
+		log.Warn("failed to get state to release held transactions", "err", err)
+		return nil
+	}
+	ready, expired := s.nonceQueue.release(time.Now(), statedb.GetNonce)
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	for _, item := range expired {
+		if item.ctx.Err() != nil {
+			item.returnResult(item.ctx.Err())
+			continue
+		}
+		err := s.txRejection(item.tx, core.ErrNonceTooHigh)
+		if sender, senderErr := types.Sender(signer, item.tx); senderErr == nil {
+			s.pending.dropped(item.tx, sender, err)
+		}
+		item.returnResult(err)
+	}
+	return ready
+}
@@ -0,0 +1,63 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestNonceQueue(t *testing.T) {
+	config := NonceQueueConfig{Enable: true, TTL: time.Second, MaxPerAccount: 2, MaxSize: 3}
+	queue := newNonceQueue(&config)
+	alice := common.HexToAddress("0x1111")
+	bob := common.HexToAddress("0x2222")
+	item := func(nonce uint64) txQueueItem {
+		tx := types.NewTx(&types.LegacyTx{Nonce: nonce})
+		return txQueueItem{tx, make(chan error, 1), context.Background(), time.Now(), nil, nil}
+	}
+	now := time.Now()
+
+	if !queue.hold(item(7), alice, now) || !queue.hold(item(6), alice, now) {
+		Fail(t, "failed to hold transactions")
+	}
+	if queue.hold(item(8), alice, now) {
+		Fail(t, "held more than the per account limit")
+	}
+	if !queue.hold(item(3), bob, now.Add(time.Second)) {
+		Fail(t, "failed to hold transaction")
+	}
+	if queue.hold(item(4), bob, now) {
+		Fail(t, "held more than the total limit")
+	}
+
+	nonces := map[common.Address]uint64{alice: 5, bob: 2}
+	ready, expired := queue.release(now.Add(time.Millisecond), func(sender common.Address) uint64 { return nonces[sender] })
+	if len(ready) != 0 || len(expired) != 0 {
+		Fail(t, "released transactions before the gap filled", len(ready), len(expired))
+	}
+
+	// Once alice's gap fills, both her transactions are released in nonce order
+	nonces[alice] = 6
+	ready, expired = queue.release(now.Add(time.Millisecond), func(sender common.Address) uint64 { return nonces[sender] })
+	if len(ready) != 2 || ready[0].tx.Nonce() != 6 || ready[1].tx.Nonce() != 7 || len(expired) != 0 {
+		Fail(t, "expected alice's transactions to be released", len(ready), len(expired))
+	}
+	if queue.size != 1 {
+		Fail(t, "expected one held transaction but got", queue.size)
+	}
+	expiry, ok := queue.nextExpiry()
+	if !ok || !expiry.Equal(now.Add(2*time.Second)) {
+		Fail(t, "unexpected next expiry", expiry, ok)
+	}
+
+	ready, expired = queue.release(now.Add(3*time.Second), func(sender common.Address) uint64 { return nonces[sender] })
+	if len(ready) != 0 || len(expired) != 1 || queue.size != 0 {
+		Fail(t, "expected bob's transaction to expire", len(ready), len(expired), queue.size)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos"
+)
+
+var (
+	orderingAppliedCounter   = metrics.NewRegisteredCounter("arb/sequencer/ordering/applied", nil)
+	orderingUnchangedCounter = metrics.NewRegisteredCounter("arb/sequencer/ordering/unchanged", nil)
+	orderingRejectedCounter  = metrics.NewRegisteredCounter("arb/sequencer/ordering/rejected", nil)
+	orderingErrorCounter     = metrics.NewRegisteredCounter("arb/sequencer/ordering/errors", nil)
+)
+
+// OrderingHookConfig configures an external ordering policy service which may reorder each set of
+// transactions the sequencer is about to sequence, within the constraints set here.
+type OrderingHookConfig struct {
+	URL             string        `koanf:"url"`
+	Timeout         time.Duration `koanf:"timeout"`
+	MaxDisplacement int           `koanf:"max-displacement"`
+	MaxHoldTime     time.Duration `koanf:"max-hold-time"`
+}
+
+func OrderingHookConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".url", DefaultOrderingHookConfig.URL, "JSON-RPC URL of an ordering policy service to consult before sequencing transactions (empty to disable)")
+	f.Duration(prefix+".timeout", DefaultOrderingHookConfig.Timeout, "how long to wait for the ordering policy before sequencing in arrival order")
+	f.Int(prefix+".max-displacement", DefaultOrderingHookConfig.MaxDisplacement, "maximum number of positions the ordering policy may move a transaction later than its arrival order")
+	f.Duration(prefix+".max-hold-time", DefaultOrderingHookConfig.MaxHoldTime, "transactions queued for longer than this can't be moved later by the ordering policy")
+}
+
+var DefaultOrderingHookConfig = OrderingHookConfig{
+	URL:             "",
+	Timeout:         20 * time.Millisecond,
+	MaxDisplacement: 8,
+	MaxHoldTime:     time.Second,
+}
+
+// OrderingCandidate is a transaction the sequencer is about to sequence, as shown to an ordering policy.
+type OrderingCandidate struct {
+	Hash     common.Hash    `json:"hash"`
+	Sender   common.Address `json:"sender"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	Size     hexutil.Uint64 `json:"size"`
+	QueuedAt time.Time      `json:"queuedAt"`
+	Tx       hexutil.Bytes  `json:"tx"`
+
+	PriorityFee *hexutil.Big `json:"priorityFee"` // the tip paid per gas above the current base fee, 0 while ArbOS doesn't charge it
+}
+
+// OrderingPolicy decides the order the candidates are sequenced in, in arrival order, returning a
+// permutation of their indices. It may observe the candidates without changing anything by
+// returning them in order.
+type OrderingPolicy interface {
+	Order(ctx context.Context, candidates []*OrderingCandidate) ([]int, error)
+}
+
+// RemoteOrderingPolicy asks a JSON-RPC service for the order with ordering_order(candidates).
+type RemoteOrderingPolicy struct {
+	client *rpc.Client
+}
+
+func NewRemoteOrderingPolicy(ctx context.Context, url string) (*RemoteOrderingPolicy, error) {
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteOrderingPolicy{client}, nil
+}
+
+func (p *RemoteOrderingPolicy) Order(ctx context.Context, candidates []*OrderingCandidate) ([]int, error) {
+	var order []int
+	err := p.client.CallContext(ctx, &order, "ordering_order", candidates)
+	return order, err
+}
+
+// checkOrdering verifies that order is a permutation of the candidates respecting the hook's constraints:
+// no sender's transactions are reordered relative to each other, no transaction moves more than
+// MaxDisplacement positions later, and transactions held longer than MaxHoldTime aren't moved later at all.
+func checkOrdering(config *OrderingHookConfig, candidates []*OrderingCandidate, order []int, now time.Time) error {
+	if len(order) != len(candidates) {
+		return fmt.Errorf("ordering has %v entries for %v candidates", len(order), len(candidates))
+	}
+	seen := make([]bool, len(candidates))
+	lastFromSender := make(map[common.Address]int)
+	for position, index := range order {
+		if index < 0 || index >= len(candidates) || seen[index] {
+			return fmt.Errorf("ordering isn't a permutation of the candidates (entry %v is %v)", position, index)
+		}
+		seen[index] = true
+		candidate := candidates[index]
+		if previous, ok := lastFromSender[candidate.Sender]; ok && previous > index {
+			return fmt.Errorf("ordering reorders transactions from sender %v", candidate.Sender)
+		}
+		lastFromSender[candidate.Sender] = index
+		if position > index {
+			if position-index > config.MaxDisplacement {
+				return fmt.Errorf("ordering moves transaction %v %v positions later, more than the maximum of %v", candidate.Hash, position-index, config.MaxDisplacement)
+			}
+			if now.Sub(candidate.QueuedAt) > config.MaxHoldTime {
+				return fmt.Errorf("ordering moves transaction %v later after it's been queued for %v", candidate.Hash, now.Sub(candidate.QueuedAt))
+			}
+		}
+	}
+	return nil
+}
+
+// applyOrderingPolicy consults the ordering policy, if any, returning the queue items in the order to sequence them.
+// If the policy fails or breaks the constraints, the arrival order is kept. Every decision is logged for auditing.
+func (s *Sequencer) applyOrderingPolicy(ctx context.Context, queueItems []txQueueItem) []txQueueItem {
+	if s.orderingPolicy == nil || len(queueItems) < 2 {
+		return queueItems
+	}
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	head := s.txStreamer.bc.CurrentBlock()
+	baseFee := head.BaseFee()
+	// before ArbOS charges priority fees, they're dropped, so nothing is paid for a place in the order
+	feesCharged := false
+	if extra, err := types.DeserializeHeaderExtraInformation(head.Header()); err == nil {
+		feesCharged = extra.ArbOSFormatVersion >= arbos.PriorityFeeArbosVersion
+	}
+	candidates := make([]*OrderingCandidate, len(queueItems))
+	for i, item := range queueItems {
+		txBytes, err := item.tx.MarshalBinary()
+		if err != nil {
+			return queueItems
+		}
+		sender, err := types.Sender(signer, item.tx)
+		if err != nil {
+			return queueItems
+		}
+		priorityFee, err := item.tx.EffectiveGasTip(baseFee)
+		if err != nil || !feesCharged {
+			// the fee cap is below the base fee, so the transaction will fail anyway, or the fee isn't charged
+			priorityFee = common.Big0
+		}
+		candidates[i] = &OrderingCandidate{
+			Hash:     item.tx.Hash(),
+			Sender:   sender,
+			Nonce:    hexutil.Uint64(item.tx.Nonce()),
+			Size:     hexutil.Uint64(len(txBytes)),
+			QueuedAt: item.queuedAt,
+			Tx:       txBytes,
+
+			PriorityFee: (*hexutil.Big)(priorityFee),
+		}
+	}
+
+	hookCtx, cancel := context.WithTimeout(ctx, s.config.OrderingHook.Timeout)
+	defer cancel()
+	order, err := s.orderingPolicy.Order(hookCtx, candidates)
+	if err != nil {
+		orderingErrorCounter.Inc(1)
+		log.Warn("ordering policy failed, sequencing in arrival order", "candidates", len(candidates), "err", err)
+		return queueItems
+	}
+	constraints := s.config.OrderingHook
+	if s.config.TimeBoost.Enable {
+		// the time boost bounds how far transactions move by the max boost instead
+		constraints.MaxDisplacement = len(candidates)
+		constraints.MaxHoldTime = time.Duration(math.MaxInt64)
+	}
+	err = checkOrdering(&constraints, candidates, order, time.Now())
+	if err != nil {
+		orderingRejectedCounter.Inc(1)
+		log.Warn("rejected ordering policy decision, sequencing in arrival order", "candidates", len(candidates), "order", order, "err", err)
+		return queueItems
+	}
+	changed := false
+	ordered := make([]txQueueItem, len(queueItems))
+	hashes := make([]common.Hash, len(queueItems))
+	for position, index := range order {
+		ordered[position] = queueItems[index]
+		hashes[position] = candidates[index].Hash
+		changed = changed || position != index
+	}
+	if !changed {
+		orderingUnchangedCounter.Inc(1)
+		log.Debug("ordering policy kept arrival order", "candidates", len(candidates))
+		return queueItems
+	}
+	orderingAppliedCounter.Inc(1)
+	log.Info("ordering policy reordered transactions", "candidates", len(candidates), "order", order, "txes", hashes)
+	return ordered
+}
@@ -0,0 +1,98 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCheckOrdering(t *testing.T) {
+	now := time.Now()
+	config := OrderingHookConfig{MaxDisplacement: 2, MaxHoldTime: time.Second}
+	alice := common.HexToAddress("0x1111")
+	bob := common.HexToAddress("0x2222")
+	candidates := []*OrderingCandidate{
+		{Hash: common.HexToHash("0x01"), Sender: alice, QueuedAt: now},
+		{Hash: common.HexToHash("0x02"), Sender: bob, QueuedAt: now},
+		{Hash: common.HexToHash("0x03"), Sender: alice, QueuedAt: now},
+		{Hash: common.HexToHash("0x04"), Sender: bob, QueuedAt: now},
+	}
+
+	valid := [][]int{
+		{0, 1, 2, 3},
+		{1, 0, 3, 2},
+		{1, 3, 0, 2},
+	}
+	for _, order := range valid {
+		Require(t, checkOrdering(&config, candidates, order, now), "order", order)
+	}
+
+	invalid := [][]int{
+		{0, 1, 2},       // missing a candidate
+		{0, 1, 2, 2},    // duplicate
+		{0, 1, 2, 4},    // out of range
+		{2, 1, 0, 3},    // reorders alice's transactions
+		{1, 2, 3, 0},    // moves the first transaction 3 positions
+		{0, 1, 2, 3, 0}, // too many entries
+	}
+	for _, order := range invalid {
+		if checkOrdering(&config, candidates, order, now) == nil {
+			Fail(t, "accepted invalid order", order)
+		}
+	}
+
+	// Transactions held too long can't be moved later
+	candidates[0].QueuedAt = now.Add(-2 * time.Second)
+	if checkOrdering(&config, candidates, []int{1, 0, 3, 2}, now) == nil {
+		Fail(t, "accepted delaying a transaction held too long")
+	}
+	Require(t, checkOrdering(&config, candidates, []int{0, 1, 3, 2}, now))
+}
+
+func TestTimeBoostOrdering(t *testing.T) {
+	now := time.Now()
+	config := TimeBoostConfig{Enable: true, MaxBoost: 200 * time.Millisecond, BoostHalfTip: 1, MaxBoostedPerBlock: 1}
+	policy := NewTimeBoostOrderingPolicy(&config)
+	gwei := func(amount int64) *hexutil.Big {
+		return (*hexutil.Big)(new(big.Int).Mul(big.NewInt(amount), big.NewInt(params.GWei)))
+	}
+	alice := common.HexToAddress("0x1111")
+	bob := common.HexToAddress("0x2222")
+	carol := common.HexToAddress("0x3333")
+	candidates := []*OrderingCandidate{
+		{Hash: common.HexToHash("0x01"), Sender: alice, QueuedAt: now},
+		{Hash: common.HexToHash("0x02"), Sender: bob, QueuedAt: now.Add(50 * time.Millisecond)},
+		{Hash: common.HexToHash("0x03"), Sender: alice, QueuedAt: now.Add(60 * time.Millisecond), PriorityFee: gwei(1)},
+		{Hash: common.HexToHash("0x04"), Sender: carol, QueuedAt: now.Add(150 * time.Millisecond), PriorityFee: gwei(3)},
+	}
+
+	// Carol's tip buys a 150ms boost, but alice's only boosted transaction can't overtake her first one
+	order, err := policy.Order(context.Background(), candidates)
+	Require(t, err)
+	expected := []int{0, 3, 1, 2}
+	for i := range expected {
+		if order[i] != expected[i] {
+			Fail(t, "expected order", expected, "got", order)
+		}
+	}
+
+	// Without the per-block cap, alice's 100ms boost moves her second transaction up to right after her first
+	config.MaxBoostedPerBlock = 0
+	order, err = policy.Order(context.Background(), candidates)
+	Require(t, err)
+	expected = []int{0, 2, 3, 1}
+	for i := range expected {
+		if order[i] != expected[i] {
+			Fail(t, "expected order", expected, "got", order)
+		}
+	}
+	Require(t, checkOrdering(&OrderingHookConfig{MaxDisplacement: len(candidates), MaxHoldTime: time.Hour}, candidates, order, now))
+}
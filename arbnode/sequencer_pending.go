@@ -0,0 +1,222 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	pendingTxExpiredCounter  = metrics.NewRegisteredCounter("arb/sequencer/pending/expired", nil)
+	pendingTxReplacedCounter = metrics.NewRegisteredCounter("arb/sequencer/pending/replaced", nil)
+)
+
+var (
+	ErrTxExpired              = errors.New("transaction expired before it was sequenced")
+	ErrTxReplaced             = errors.New("transaction replaced by another with the same nonce")
+	ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+)
+
+// PendingTxConfig sets rules for transactions queued but not yet sequenced: they can be dropped if they
+// wait too long, and replaced by a transaction from the same sender with the same nonce paying enough
+// more, like in a mempool. Wallets can subscribe to the resulting events with arb_subscribe("pendingTxEvents").
+type PendingTxConfig struct {
+	Expiry           time.Duration `koanf:"expiry"`
+	AllowReplacement bool          `koanf:"allow-replacement"`
+	PriceBump        uint64        `koanf:"price-bump"`
+}
+
+func PendingTxConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".expiry", DefaultPendingTxConfig.Expiry, "drop queued transactions not sequenced within this long (0 to disable)")
+	f.Bool(prefix+".allow-replacement", DefaultPendingTxConfig.AllowReplacement, "allow queued transactions to be replaced by ones with the same sender and nonce paying more")
+	f.Uint64(prefix+".price-bump", DefaultPendingTxConfig.PriceBump, "minimum percentage both the fee cap and tip cap of a replacement must exceed the replaced transaction's by")
+}
+
+var DefaultPendingTxConfig = PendingTxConfig{
+	Expiry:           0,
+	AllowReplacement: false,
+	PriceBump:        10,
+}
+
+const (
+	PendingTxEventDropped  = "dropped"
+	PendingTxEventReplaced = "replaced"
+)
+
+// PendingTxEvent reports a queued transaction which won't be sequenced.
+type PendingTxEvent struct {
+	Kind        string         `json:"kind"`
+	Hash        common.Hash    `json:"hash"`
+	Sender      common.Address `json:"sender"`
+	Nonce       hexutil.Uint64 `json:"nonce"`
+	Replacement *common.Hash   `json:"replacement,omitempty"`
+	Reason      string         `json:"reason,omitempty"`
+}
+
+type pendingTxKey struct {
+	sender common.Address
+	nonce  uint64
+}
+
+type pendingTx struct {
+	hash      common.Hash
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+	replaced  int32 // atomic, set once another transaction replaces this one
+}
+
+func (p *pendingTx) isReplaced() bool {
+	return p != nil && atomic.LoadInt32(&p.replaced) != 0
+}
+
+// pendingTxs tracks the queued transactions by sender and nonce, to find those which are replaced.
+type pendingTxs struct {
+	config *PendingTxConfig
+	mutex  sync.Mutex
+	txs    map[pendingTxKey]*pendingTx
+	feed   event.Feed
+}
+
+func newPendingTxs(config *PendingTxConfig) *pendingTxs {
+	return &pendingTxs{
+		config: config,
+		txs:    make(map[pendingTxKey]*pendingTx),
+	}
+}
+
+// bumped returns whether price exceeds old by at least the configured percentage.
+func (p *pendingTxs) bumped(price, old *big.Int) bool {
+	threshold := new(big.Int).Mul(old, big.NewInt(int64(100+p.config.PriceBump)))
+	return new(big.Int).Mul(price, big.NewInt(100)).Cmp(threshold) >= 0
+}
+
+// add tracks a transaction about to be queued, replacing any queued with the same sender and nonce,
+// or returns ErrReplacementUnderpriced if it doesn't pay enough more to replace it.
+func (p *pendingTxs) add(sender common.Address, tx *types.Transaction) (*pendingTx, error) {
+	key := pendingTxKey{sender, tx.Nonce()}
+	entry := &pendingTx{
+		hash:      tx.Hash(),
+		gasFeeCap: tx.GasFeeCap(),
+		gasTipCap: tx.GasTipCap(),
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if old, ok := p.txs[key]; ok {
+		if old.hash == entry.hash {
+			return nil, errors.New("transaction already queued")
+		}
+		if !p.bumped(entry.gasFeeCap, old.gasFeeCap) || !p.bumped(entry.gasTipCap, old.gasTipCap) {
+			return nil, ErrReplacementUnderpriced
+		}
+		atomic.StoreInt32(&old.replaced, 1)
+		pendingTxReplacedCounter.Inc(1)
+		p.feed.Send(PendingTxEvent{
+			Kind:        PendingTxEventReplaced,
+			Hash:        old.hash,
+			Sender:      sender,
+			Nonce:       hexutil.Uint64(key.nonce),
+			Replacement: &entry.hash,
+		})
+	}
+	p.txs[key] = entry
+	return entry, nil
+}
+
+// remove stops tracking a transaction once it's been dealt with, unless it's been replaced meanwhile.
+func (p *pendingTxs) remove(sender common.Address, nonce uint64, entry *pendingTx) {
+	key := pendingTxKey{sender, nonce}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.txs[key] == entry {
+		delete(p.txs, key)
+	}
+}
+
+func (p *pendingTxs) dropped(tx *types.Transaction, sender common.Address, reason error) {
+	p.feed.Send(PendingTxEvent{
+		Kind:   PendingTxEventDropped,
+		Hash:   tx.Hash(),
+		Sender: sender,
+		Nonce:  hexutil.Uint64(tx.Nonce()),
+		Reason: reason.Error(),
+	})
+}
+
+// SubscribePendingTxEvents subscribes to the events of queued transactions being dropped or replaced.
+func (s *Sequencer) SubscribePendingTxEvents(ch chan<- PendingTxEvent) event.Subscription {
+	return s.pending.feed.Subscribe(ch)
+}
+
+// checkPending returns why a dequeued transaction shouldn't be sequenced, if it's been replaced or has expired.
+func (s *Sequencer) checkPending(item txQueueItem) error {
+	if item.pending.isReplaced() {
+		return ErrTxReplaced
+	}
+	expiry := s.config.PendingTx.Expiry
+	if expiry <= 0 || time.Since(item.queuedAt) <= expiry {
+		return nil
+	}
+	pendingTxExpiredCounter.Inc(1)
+	if sender, err := types.Sender(types.LatestSigner(s.txStreamer.bc.Config()), item.tx); err == nil {
+		s.pending.dropped(item.tx, sender, ErrTxExpired)
+	}
+	return ErrTxExpired
+}
+
+type ArbPendingTxAPI struct {
+	sequencer *Sequencer
+}
+
+// PendingTxEvents streams the events of queued transactions being dropped or replaced, as
+// arb_subscribe("pendingTxEvents", senders), optionally only those of the given senders.
+func (api *ArbPendingTxAPI) PendingTxEvents(ctx context.Context, senders *[]common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	var filter map[common.Address]struct{}
+	if senders != nil {
+		filter = make(map[common.Address]struct{})
+		for _, sender := range *senders {
+			filter[sender] = struct{}{}
+		}
+	}
+	subscription := notifier.CreateSubscription()
+	events := make(chan PendingTxEvent, 128)
+	eventsSub := api.sequencer.SubscribePendingTxEvents(events)
+	go func() {
+		defer eventsSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				if filter != nil {
+					if _, ok := filter[ev.Sender]; !ok {
+						continue
+					}
+				}
+				if err := notifier.Notify(subscription.ID, ev); err != nil {
+					return
+				}
+			case <-subscription.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestPendingTxReplacement(t *testing.T) {
+	config := DefaultPendingTxConfig
+	config.AllowReplacement = true
+	pending := newPendingTxs(&config)
+	events := make(chan PendingTxEvent, 8)
+	sub := pending.feed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	sender := common.HexToAddress("0x1111")
+	tx := func(nonce uint64, feeCap, tipCap int64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{Nonce: nonce, GasFeeCap: big.NewInt(feeCap), GasTipCap: big.NewInt(tipCap)})
+	}
+
+	original := tx(0, 100, 10)
+	first, err := pending.add(sender, original)
+	Require(t, err)
+	if _, err := pending.add(sender, tx(0, 109, 20)); !errors.Is(err, ErrReplacementUnderpriced) {
+		Fail(t, "accepted a replacement without enough of a fee cap bump", err)
+	}
+	if _, err := pending.add(sender, tx(0, 200, 10)); !errors.Is(err, ErrReplacementUnderpriced) {
+		Fail(t, "accepted a replacement without a tip bump", err)
+	}
+	if first.isReplaced() {
+		Fail(t, "underpriced replacement replaced the transaction")
+	}
+	other, err := pending.add(sender, tx(1, 100, 10))
+	Require(t, err)
+
+	replacement := tx(0, 110, 11)
+	second, err := pending.add(sender, replacement)
+	Require(t, err)
+	if !first.isReplaced() || second.isReplaced() || other.isReplaced() {
+		Fail(t, "unexpected replacement state", first.isReplaced(), second.isReplaced(), other.isReplaced())
+	}
+	select {
+	case ev := <-events:
+		if ev.Kind != PendingTxEventReplaced || ev.Hash != original.Hash() || *ev.Replacement != replacement.Hash() {
+			Fail(t, "unexpected event", ev)
+		}
+	default:
+		Fail(t, "no replacement event")
+	}
+
+	// removing the replaced transaction keeps tracking its replacement
+	pending.remove(sender, 0, first)
+	if _, err := pending.add(sender, tx(0, 111, 12)); !errors.Is(err, ErrReplacementUnderpriced) {
+		Fail(t, "replacement no longer tracked", err)
+	}
+	pending.remove(sender, 0, second)
+	if _, err := pending.add(sender, tx(0, 1, 1)); err != nil {
+		Fail(t, "transaction still tracked after removal", err)
+	}
+}
+
+func TestNonceQueueHoldsReplacement(t *testing.T) {
+	config := NonceQueueConfig{Enable: true, TTL: time.Second, MaxPerAccount: 1, MaxSize: 1}
+	queue := newNonceQueue(&config)
+	sender := common.HexToAddress("0x1111")
+	now := time.Now()
+	held := &pendingTx{}
+	heldResult := make(chan error, 1)
+	item := txQueueItem{types.NewTx(&types.LegacyTx{Nonce: 3}), heldResult, context.Background(), now, nil, held}
+	if !queue.hold(item, sender, now) {
+		Fail(t, "failed to hold transaction")
+	}
+	replacement := txQueueItem{types.NewTx(&types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(1)}), make(chan error, 1), context.Background(), now, nil, nil}
+	if queue.hold(replacement, sender, now) {
+		Fail(t, "held two transactions with the same nonce")
+	}
+	held.replaced = 1
+	if !queue.hold(replacement, sender, now) {
+		Fail(t, "didn't hold the replacement in place of the replaced transaction")
+	}
+	if err := <-heldResult; !errors.Is(err, ErrTxReplaced) {
+		Fail(t, "unexpected result for the replaced transaction", err)
+	}
+	if queue.size != 1 || queue.held[sender][0].item.tx != replacement.tx {
+		Fail(t, "replacement not held", queue.size)
+	}
+}
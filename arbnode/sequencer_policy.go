@@ -0,0 +1,335 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"plugin"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/offchainlabs/nitro/grpcpolicy"
+)
+
+var (
+	policyRejectedCounter = metrics.NewRegisteredCounter("arb/sequencer/policy/rejected", nil)
+	policyErrorCounter    = metrics.NewRegisteredCounter("arb/sequencer/policy/errors", nil)
+)
+
+var ErrPolicyRejected = errors.New("transaction rejected by sequencing policy")
+
+// SequencingPolicyConfig restricts which senders may have transactions sequenced, and which contracts
+// they may call, for chains with compliance requirements. Deny lists take precedence over allow lists,
+// and an empty allow list allows everyone. Predicates loaded from a Go plugin or asked of a gRPC policy
+// server are consulted for transactions the lists allow. Transactions are checked as they're submitted,
+// before they're queued. Every rejection is logged for auditing.
+type SequencingPolicyConfig struct {
+	AllowedSenders   string        `koanf:"allowed-senders"`
+	DeniedSenders    string        `koanf:"denied-senders"`
+	AllowedContracts string        `koanf:"allowed-contracts"`
+	DeniedContracts  string        `koanf:"denied-contracts"`
+	PluginPath       string        `koanf:"plugin-path"`
+	ServerAddr       string        `koanf:"server-addr"`
+	ServerTimeout    time.Duration `koanf:"server-timeout"`
+	FailOpen         bool          `koanf:"fail-open"`
+	AuditLog         string        `koanf:"audit-log"`
+}
+
+func SequencingPolicyConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".allowed-senders", DefaultSequencingPolicyConfig.AllowedSenders, "comma separated senders allowed to have transactions sequenced (if empty, everyone not denied is allowed)")
+	f.String(prefix+".denied-senders", DefaultSequencingPolicyConfig.DeniedSenders, "comma separated senders whose transactions are never sequenced")
+	f.String(prefix+".allowed-contracts", DefaultSequencingPolicyConfig.AllowedContracts, "comma separated addresses transactions may be sent to (if empty, any address not denied is allowed)")
+	f.String(prefix+".denied-contracts", DefaultSequencingPolicyConfig.DeniedContracts, "comma separated addresses transactions to which are never sequenced")
+	f.String(prefix+".plugin-path", DefaultSequencingPolicyConfig.PluginPath, "path to a Go plugin exporting a SequencingPredicate function consulted for each transaction")
+	f.String(prefix+".server-addr", DefaultSequencingPolicyConfig.ServerAddr, "address of a local gRPC policy server consulted for each transaction (empty to disable)")
+	f.Duration(prefix+".server-timeout", DefaultSequencingPolicyConfig.ServerTimeout, "how long to wait for a plugin or the policy server to decide on a transaction")
+	f.Bool(prefix+".fail-open", DefaultSequencingPolicyConfig.FailOpen, "sequence transactions when a predicate fails to reach a decision, instead of rejecting them")
+	f.String(prefix+".audit-log", DefaultSequencingPolicyConfig.AuditLog, "file to append a JSON record of every rejection to, in addition to the node's log")
+}
+
+var DefaultSequencingPolicyConfig = SequencingPolicyConfig{
+	ServerTimeout: 100 * time.Millisecond,
+	FailOpen:      false,
+}
+
+// PolicyCandidate is a transaction about to be sequenced, as shown to a sequencing predicate.
+type PolicyCandidate struct {
+	Hash   common.Hash     `json:"hash"`
+	Sender common.Address  `json:"sender"`
+	To     *common.Address `json:"to"`
+	Nonce  hexutil.Uint64  `json:"nonce"`
+	Value  *hexutil.Big    `json:"value"`
+	Tx     hexutil.Bytes   `json:"tx"`
+}
+
+// SequencingPredicate decides whether a transaction may be sequenced. It returns a non-empty
+// reason to reject it, or an error if it couldn't decide.
+type SequencingPredicate interface {
+	Check(ctx context.Context, candidate *PolicyCandidate) (string, error)
+}
+
+// PluginPredicateSymbol is the function a policy plugin exports, with the signature of PluginPredicateFunc.
+const PluginPredicateSymbol = "SequencingPredicate"
+
+type PluginPredicateFunc = func(ctx context.Context, sender common.Address, tx *types.Transaction) (string, error)
+
+type pluginPredicate struct {
+	check PluginPredicateFunc
+}
+
+func LoadPluginPredicate(path string) (SequencingPredicate, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := p.Lookup(PluginPredicateSymbol)
+	if err != nil {
+		return nil, err
+	}
+	check, ok := symbol.(PluginPredicateFunc)
+	if !ok {
+		if checkPtr, ok := symbol.(*PluginPredicateFunc); ok {
+			check = *checkPtr
+		} else {
+			return nil, fmt.Errorf("sequencing policy plugin %v exports %v with unexpected type %T", path, PluginPredicateSymbol, symbol)
+		}
+	}
+	return &pluginPredicate{check}, nil
+}
+
+func (p *pluginPredicate) Check(ctx context.Context, candidate *PolicyCandidate) (string, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(candidate.Tx); err != nil {
+		return "", err
+	}
+	return p.check(ctx, candidate.Sender, tx)
+}
+
+// RemotePredicate asks a local gRPC policy server, which implements grpcpolicy.SequencingPolicy.
+type RemotePredicate struct {
+	conn   *grpc.ClientConn
+	client grpcpolicy.SequencingPolicyClient
+}
+
+// NewRemotePredicate connects to the policy server in the background, so it may be started after the sequencer.
+func NewRemotePredicate(addr string) (*RemotePredicate, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &RemotePredicate{conn, grpcpolicy.NewSequencingPolicyClient(conn)}, nil
+}
+
+func (p *RemotePredicate) Check(ctx context.Context, candidate *PolicyCandidate) (string, error) {
+	request := &grpcpolicy.Candidate{
+		Hash:   candidate.Hash.Bytes(),
+		Sender: candidate.Sender.Bytes(),
+		Nonce:  uint64(candidate.Nonce),
+		Value:  candidate.Value.ToInt().Bytes(),
+		Tx:     candidate.Tx,
+	}
+	if candidate.To != nil {
+		request.To = candidate.To.Bytes()
+	}
+	decision, err := p.client.Check(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	if decision.Allowed {
+		return "", nil
+	}
+	if decision.Reason == "" {
+		return "rejected by policy server", nil
+	}
+	return decision.Reason, nil
+}
+
+func (p *RemotePredicate) Close() error {
+	return p.conn.Close()
+}
+
+type policyAuditRecord struct {
+	Time   time.Time       `json:"time"`
+	Hash   common.Hash     `json:"hash"`
+	Sender common.Address  `json:"sender"`
+	To     *common.Address `json:"to"`
+	Reason string          `json:"reason"`
+}
+
+type sequencingPolicy struct {
+	config           *SequencingPolicyConfig
+	allowedSenders   map[common.Address]struct{}
+	deniedSenders    map[common.Address]struct{}
+	allowedContracts map[common.Address]struct{}
+	deniedContracts  map[common.Address]struct{}
+	predicates       []SequencingPredicate
+	server           *RemotePredicate // nil if no policy server is consulted
+
+	auditMutex sync.Mutex
+	audit      *os.File
+}
+
+func parsePolicyAddresses(name string, list string) (map[common.Address]struct{}, error) {
+	addresses := make(map[common.Address]struct{})
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("sequencing policy %v entry \"%v\" is not a valid address", name, entry)
+		}
+		addresses[common.HexToAddress(entry)] = struct{}{}
+	}
+	return addresses, nil
+}
+
+func newSequencingPolicy(config *SequencingPolicyConfig) (*sequencingPolicy, error) {
+	p := &sequencingPolicy{config: config}
+	var err error
+	if p.allowedSenders, err = parsePolicyAddresses("allowed sender", config.AllowedSenders); err != nil {
+		return nil, err
+	}
+	if p.deniedSenders, err = parsePolicyAddresses("denied sender", config.DeniedSenders); err != nil {
+		return nil, err
+	}
+	if p.allowedContracts, err = parsePolicyAddresses("allowed contract", config.AllowedContracts); err != nil {
+		return nil, err
+	}
+	if p.deniedContracts, err = parsePolicyAddresses("denied contract", config.DeniedContracts); err != nil {
+		return nil, err
+	}
+	if config.PluginPath != "" {
+		predicate, err := LoadPluginPredicate(config.PluginPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sequencing policy plugin: %w", err)
+		}
+		p.predicates = append(p.predicates, predicate)
+	}
+	if config.ServerAddr != "" {
+		predicate, err := NewRemotePredicate(config.ServerAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to sequencing policy server: %w", err)
+		}
+		p.server = predicate
+		p.predicates = append(p.predicates, predicate)
+	}
+	if config.AuditLog != "" {
+		p.audit, err = os.OpenFile(config.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// reason returns why the transaction may not be sequenced, or an empty string if it may be.
+func (p *sequencingPolicy) reason(ctx context.Context, tx *types.Transaction, sender common.Address) string {
+	if _, denied := p.deniedSenders[sender]; denied {
+		return "sender denied"
+	}
+	if len(p.allowedSenders) > 0 {
+		if _, allowed := p.allowedSenders[sender]; !allowed {
+			return "sender not allowed"
+		}
+	}
+	if to := tx.To(); to != nil {
+		if _, denied := p.deniedContracts[*to]; denied {
+			return "destination denied"
+		}
+		if len(p.allowedContracts) > 0 {
+			if _, allowed := p.allowedContracts[*to]; !allowed {
+				return "destination not allowed"
+			}
+		}
+	} else if len(p.allowedContracts) > 0 {
+		return "contract creation not allowed"
+	}
+	if len(p.predicates) == 0 {
+		return ""
+	}
+	txBytes, err := tx.MarshalBinary()
+	if err != nil {
+		return err.Error()
+	}
+	candidate := &PolicyCandidate{
+		Hash:   tx.Hash(),
+		Sender: sender,
+		To:     tx.To(),
+		Nonce:  hexutil.Uint64(tx.Nonce()),
+		Value:  (*hexutil.Big)(tx.Value()),
+		Tx:     txBytes,
+	}
+	for _, predicate := range p.predicates {
+		checkCtx, cancel := context.WithTimeout(ctx, p.config.ServerTimeout)
+		reason, err := predicate.Check(checkCtx, candidate)
+		cancel()
+		if err != nil {
+			policyErrorCounter.Inc(1)
+			log.Warn("sequencing policy predicate failed", "tx", candidate.Hash, "failOpen", p.config.FailOpen, "err", err)
+			if p.config.FailOpen {
+				continue
+			}
+			return "policy predicate failed"
+		}
+		if reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// check returns an error wrapping ErrPolicyRejected if the transaction may not be sequenced, recording the rejection.
+func (p *sequencingPolicy) check(ctx context.Context, tx *types.Transaction, sender common.Address) error {
+	reason := p.reason(ctx, tx, sender)
+	if reason == "" {
+		return nil
+	}
+	policyRejectedCounter.Inc(1)
+	log.Info("sequencing policy rejected transaction", "tx", tx.Hash(), "sender", sender, "to", tx.To(), "reason", reason)
+	if p.audit != nil {
+		record, err := json.Marshal(&policyAuditRecord{
+			Time:   time.Now().UTC(),
+			Hash:   tx.Hash(),
+			Sender: sender,
+			To:     tx.To(),
+			Reason: reason,
+		})
+		if err == nil {
+			p.auditMutex.Lock()
+			_, err = p.audit.Write(append(record, '\n'))
+			p.auditMutex.Unlock()
+		}
+		if err != nil {
+			log.Error("failed to write sequencing policy audit record", "tx", tx.Hash(), "err", err)
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrPolicyRejected, reason)
+}
+
+func (p *sequencingPolicy) close() error {
+	if p.server != nil {
+		if err := p.server.Close(); err != nil {
+			return err
+		}
+	}
+	if p.audit == nil {
+		return nil
+	}
+	return p.audit.Close()
+}
@@ -0,0 +1,136 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/grpc"
+
+	"github.com/offchainlabs/nitro/grpcpolicy"
+)
+
+type denyValuePredicate struct {
+	max *big.Int
+}
+
+func (p *denyValuePredicate) Check(ctx context.Context, candidate *PolicyCandidate) (string, error) {
+	if candidate.Value.ToInt().Cmp(p.max) > 0 {
+		return "value too large", nil
+	}
+	return "", nil
+}
+
+type testPolicyServer struct {
+	grpcpolicy.UnimplementedSequencingPolicyServer
+	denied common.Address
+}
+
+func (s *testPolicyServer) Check(ctx context.Context, candidate *grpcpolicy.Candidate) (*grpcpolicy.Decision, error) {
+	if common.BytesToAddress(candidate.To) == s.denied {
+		return &grpcpolicy.Decision{Allowed: false, Reason: "sanctioned"}, nil
+	}
+	return &grpcpolicy.Decision{Allowed: true}, nil
+}
+
+type failingPredicate struct{}
+
+func (p *failingPredicate) Check(ctx context.Context, candidate *PolicyCandidate) (string, error) {
+	return "", errors.New("unreachable")
+}
+
+func TestSequencingPolicy(t *testing.T) {
+	ctx := context.Background()
+	allowed := common.Address{1}
+	denied := common.Address{2}
+	other := common.Address{3}
+	contract := common.Address{4}
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	config := DefaultSequencingPolicyConfig
+	config.DeniedSenders = denied.Hex()
+	config.AllowedContracts = contract.Hex() + ", " + allowed.Hex()
+	config.AuditLog = auditPath
+	policy, err := newSequencingPolicy(&config)
+	Require(t, err)
+	policy.predicates = append(policy.predicates, &denyValuePredicate{big.NewInt(100)})
+
+	tx := func(to *common.Address, value int64) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{To: to, Value: big.NewInt(value), GasPrice: big.NewInt(1), Gas: 21000})
+	}
+	Require(t, policy.check(ctx, tx(&contract, 1), allowed))
+	Require(t, policy.check(ctx, tx(&contract, 1), other))
+	rejections := []struct {
+		tx     *types.Transaction
+		sender common.Address
+	}{
+		{tx(&contract, 1), denied},
+		{tx(&other, 1), allowed},
+		{tx(nil, 1), allowed},
+		{tx(&contract, 101), allowed},
+	}
+	for i, rejection := range rejections {
+		if err := policy.check(ctx, rejection.tx, rejection.sender); !errors.Is(err, ErrPolicyRejected) {
+			Fail(t, "transaction", i, "not rejected by policy", err)
+		}
+	}
+	Require(t, policy.close())
+	audit, err := os.ReadFile(auditPath)
+	Require(t, err)
+	lines := strings.Split(strings.TrimSpace(string(audit)), "\n")
+	if len(lines) != len(rejections) || !strings.Contains(lines[3], "value too large") {
+		Fail(t, "unexpected audit log", string(audit))
+	}
+
+	config = DefaultSequencingPolicyConfig
+	policy, err = newSequencingPolicy(&config)
+	Require(t, err)
+	policy.predicates = append(policy.predicates, &failingPredicate{})
+	if err := policy.check(ctx, tx(&contract, 1), allowed); !errors.Is(err, ErrPolicyRejected) {
+		Fail(t, "transaction sequenced despite the predicate failing", err)
+	}
+	config.FailOpen = true
+	Require(t, policy.check(ctx, tx(&contract, 1), allowed))
+}
+
+func TestSequencingPolicyServer(t *testing.T) {
+	ctx := context.Background()
+	sanctioned := common.Address{5}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Require(t, err)
+	server := grpc.NewServer()
+	grpcpolicy.RegisterSequencingPolicyServer(server, &testPolicyServer{denied: sanctioned})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	config := DefaultSequencingPolicyConfig
+	config.ServerAddr = listener.Addr().String()
+	config.ServerTimeout = 5 * time.Second
+	policy, err := newSequencingPolicy(&config)
+	Require(t, err)
+	defer func() {
+		Require(t, policy.close())
+	}()
+
+	tx := func(to common.Address) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{To: &to, Value: big.NewInt(1), GasPrice: big.NewInt(1), Gas: 21000})
+	}
+	Require(t, policy.check(ctx, tx(common.Address{6}), common.Address{1}))
+	err = policy.check(ctx, tx(sanctioned), common.Address{1})
+	if !errors.Is(err, ErrPolicyRejected) || !strings.Contains(err.Error(), "sanctioned") {
+		Fail(t, "transaction to a sanctioned address not rejected by the policy server", err)
+	}
+}
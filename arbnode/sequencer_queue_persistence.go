@@ -0,0 +1,239 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	flag "github.com/spf13/pflag"
+)
+
+// QueuePersistenceConfig has the sequencer save the transactions it has queued, including those held for a
+// nonce gap, when it stops, and requeue them when it starts again, so a restart doesn't drop them.
+type QueuePersistenceConfig struct {
+	Enable bool          `koanf:"enable"`
+	MaxAge time.Duration `koanf:"max-age"`
+}
+
+func QueuePersistenceConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultQueuePersistenceConfig.Enable, "save queued transactions on shutdown and requeue them on startup")
+	f.Duration(prefix+".max-age", DefaultQueuePersistenceConfig.MaxAge, "discard saved transactions if the sequencer was stopped for longer than this")
+}
+
+var DefaultQueuePersistenceConfig = QueuePersistenceConfig{
+	Enable: false,
+	MaxAge: 5 * time.Minute,
+}
+
+type persistedTx struct {
+	Tx       []byte
+	QueuedAt uint64 // unix milliseconds
+	Options  []byte // JSON encoded conditional options, empty if unconditional
+}
+
+type persistedQueue struct {
+	SavedAt uint64 // unix milliseconds
+	Txs     []persistedTx
+}
+
+// writePersistedQueue saves the queued transactions, replacing any saved before.
+func writePersistedQueue(db ethdb.KeyValueWriter, items []txQueueItem, now time.Time) error {
+	if len(items) == 0 {
+		return db.Delete(sequencerQueueKey)
+	}
+	queue := persistedQueue{SavedAt: uint64(now.UnixMilli())}
+	for _, item := range items {
+		txBytes, err := item.tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		var options []byte
+		if item.options != nil {
+			options, err = json.Marshal(item.options)
+			if err != nil {
+				return err
+			}
+		}
+		queue.Txs = append(queue.Txs, persistedTx{
+			Tx:       txBytes,
+			QueuedAt: uint64(item.queuedAt.UnixMilli()),
+			Options:  options,
+		})
+	}
+	data, err := rlp.EncodeToBytes(queue)
+	if err != nil {
+		return err
+	}
+	return db.Put(sequencerQueueKey, data)
+}
+
+// readPersistedQueue loads and deletes the saved transactions, discarding them if they were saved too long ago.
+// The returned items have no context or result channel yet.
+func readPersistedQueue(db ethdb.KeyValueStore, maxAge time.Duration, now time.Time) ([]txQueueItem, error) {
+	has, err := db.Has(sequencerQueueKey)
+	if err != nil || !has {
+		return nil, err
+	}
+	data, err := db.Get(sequencerQueueKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Delete(sequencerQueueKey); err != nil {
+		return nil, err
+	}
+	var queue persistedQueue
+	if err := rlp.DecodeBytes(data, &queue); err != nil {
+		return nil, err
+	}
+	savedAt := time.UnixMilli(int64(queue.SavedAt))
+	if now.Sub(savedAt) > maxAge {
+		log.Warn("discarding queued transactions saved too long ago", "count", len(queue.Txs), "savedAt", savedAt)
+		return nil, nil
+	}
+	items := make([]txQueueItem, 0, len(queue.Txs))
+	for _, persisted := range queue.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(persisted.Tx); err != nil {
+			log.Warn("discarding undecodable saved transaction", "err", err)
+			continue
+		}
+		var options *ConditionalOptions
+		if len(persisted.Options) > 0 {
+			options = new(ConditionalOptions)
+			if err := json.Unmarshal(persisted.Options, options); err != nil {
+				log.Warn("discarding saved transaction with undecodable conditions", "tx", tx.Hash(), "err", err)
+				continue
+			}
+		}
+		items = append(items, txQueueItem{
+			tx:       tx,
+			queuedAt: time.UnixMilli(int64(persisted.QueuedAt)),
+			options:  options,
+		})
+	}
+	return items, nil
+}
+
+// drain removes all held transactions, returning them by sender in nonce order.
+func (q *nonceQueue) drain() []txQueueItem {
+	senders := make([]common.Address, 0, len(q.held))
+	for sender := range q.held {
+		senders = append(senders, sender)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i][:], senders[j][:]) < 0
+	})
+	var items []txQueueItem
+	for _, sender := range senders {
+		for _, h := range q.held[sender] {
+			items = append(items, h.item)
+		}
+	}
+	q.held = make(map[common.Address][]*heldTx)
+	q.size = 0
+	nonceQueueHeldGauge.Update(0)
+	return items
+}
+
+// errSequencerStoppedSaved is returned to the senders of the transactions saved when the sequencer stops.
+// They aren't told they were accepted, as they may still be rejected when they're resubmitted.
+var errSequencerStoppedSaved = errors.New("sequencer stopped before sequencing the transaction, it was saved to be resubmitted when the sequencer restarts")
+
+// persistQueue saves the queued and held transactions. It must only be called once sequencing has stopped.
+func (s *Sequencer) persistQueue() error {
+	var items []txQueueItem
+	for done := false; !done; {
+		select {
+		case item := <-s.txQueue:
+			items = append(items, item)
+		default:
+			done = true
+		}
+	}
+	items = append(items, s.nonceQueue.drain()...)
+	err := writePersistedQueue(s.txStreamer.db, items, time.Now())
+	for _, item := range items {
+		if err != nil {
+			item.returnResult(errors.New("sequencer stopped"))
+		} else {
+			item.returnResult(errSequencerStoppedSaved)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		log.Info("saved queued transactions", "count", len(items))
+	}
+	return nil
+}
+
+// restoreQueue resubmits the transactions saved when the sequencer last stopped, skipping those which have
+// been sequenced since, as another sequencer may have taken over meanwhile. They go through the same checks
+// as new transactions, each sender's in nonce order.
+func (s *Sequencer) restoreQueue(ctx context.Context) {
+	items, err := readPersistedQueue(s.txStreamer.db, s.config.QueuePersistence.MaxAge, time.Now())
+	if err != nil {
+		log.Error("failed to load saved queued transactions", "err", err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	statedb, err := s.txStreamer.bc.State()
+	if err != nil {
+		log.Error("failed to get state to validate saved queued transactions", "err", err)
+		return
+	}
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	var senders []common.Address
+	bySender := make(map[common.Address][]txQueueItem)
+	for _, item := range items {
+		sender, err := types.Sender(signer, item.tx)
+		if err != nil {
+			log.Warn("discarding saved transaction with invalid signature", "tx", item.tx.Hash(), "err", err)
+			continue
+		}
+		if item.tx.Nonce() < statedb.GetNonce(sender) {
+			continue
+		}
+		if _, ok := bySender[sender]; !ok {
+			senders = append(senders, sender)
+		}
+		bySender[sender] = append(bySender[sender], item)
+	}
+	var restored int64
+	var wg sync.WaitGroup
+	for _, sender := range senders {
+		senderItems := bySender[sender]
+		sort.SliceStable(senderItems, func(i, j int) bool {
+			return senderItems[i].tx.Nonce() < senderItems[j].tx.Nonce()
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, item := range senderItems {
+				if err := s.PublishTransaction(ctx, item.tx, item.options); err != nil {
+					log.Info("saved transaction rejected on resubmission", "tx", item.tx.Hash(), "err", err)
+					continue
+				}
+				atomic.AddInt64(&restored, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	log.Info("resubmitted saved transactions", "sequenced", restored, "saved", len(items))
+}
@@ -0,0 +1,62 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestPersistedQueueRoundtrip(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	now := time.Now()
+	blockNumber := hexutil.Uint64(12)
+	var items []txQueueItem
+	for i := uint64(0); i < 3; i++ {
+		tx := types.NewTransaction(i, common.Address{1}, big.NewInt(int64(i)), 21000, big.NewInt(1), nil)
+		item := txQueueItem{tx: tx, queuedAt: now.Add(-time.Duration(i) * time.Second)}
+		if i == 1 {
+			item.options = &ConditionalOptions{BlockNumberMax: &blockNumber}
+		}
+		items = append(items, item)
+	}
+	Require(t, writePersistedQueue(db, items, now))
+
+	restored, err := readPersistedQueue(db, time.Minute, now.Add(time.Second))
+	Require(t, err)
+	if len(restored) != len(items) {
+		Fail(t, "restored", len(restored), "transactions but saved", len(items))
+	}
+	for i, item := range restored {
+		if item.tx.Hash() != items[i].tx.Hash() || item.queuedAt.UnixMilli() != items[i].queuedAt.UnixMilli() {
+			Fail(t, "restored transaction", i, "differs from the one saved")
+		}
+		if (item.options == nil) != (i != 1) {
+			Fail(t, "restored transaction", i, "has unexpected conditions", item.options)
+		}
+	}
+	if *restored[1].options.BlockNumberMax != blockNumber {
+		Fail(t, "unexpected restored conditions", restored[1].options)
+	}
+
+	// the saved queue is only restored once
+	restored, err = readPersistedQueue(db, time.Minute, now.Add(time.Second))
+	Require(t, err)
+	if len(restored) != 0 {
+		Fail(t, "restored the saved queue twice")
+	}
+
+	Require(t, writePersistedQueue(db, items, now))
+	restored, err = readPersistedQueue(db, time.Minute, now.Add(2*time.Minute))
+	Require(t, err)
+	if len(restored) != 0 {
+		Fail(t, "restored a queue saved too long ago")
+	}
+}
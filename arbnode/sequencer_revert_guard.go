@@ -0,0 +1,75 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+)
+
+var revertGuardRejectedCounter = metrics.NewRegisteredCounter("arb/sequencer/revertguard/rejected", nil)
+
+var ErrRevertGuardRejected = errors.New("transaction reverted, so the sequencer rejected it")
+
+// RevertGuardConfig has the sequencer reject transactions which revert when it executes them in the block it's
+// building, so failed arbitrage attempts don't take up block space. It's checked as blocks are built, rather than
+// by simulating transactions as they're submitted, so it costs no extra execution and RPC requests aren't held up.
+// Unlike max-revert-gas-reject, which only drops cheap reverts, this rejects them whatever their gas use, so it
+// should only be enabled on chains where reverts are mostly spam.
+type RevertGuardConfig struct {
+	Enable        bool   `koanf:"enable"`
+	MaxGas        uint64 `koanf:"max-gas"`
+	ExemptSenders string `koanf:"exempt-senders"`
+}
+
+func RevertGuardConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultRevertGuardConfig.Enable, "reject transactions which revert in the block being built, instead of including them")
+	f.Uint64(prefix+".max-gas", DefaultRevertGuardConfig.MaxGas, "only reject reverting transactions with a gas limit up to this, including larger ones (0 = unlimited)")
+	f.String(prefix+".exempt-senders", DefaultRevertGuardConfig.ExemptSenders, "comma separated senders whose reverting transactions are always included")
+}
+
+var DefaultRevertGuardConfig = RevertGuardConfig{
+	Enable:        false,
+	MaxGas:        10_000_000,
+	ExemptSenders: "",
+}
+
+func parseRevertGuardExemptions(list string) (map[common.Address]struct{}, error) {
+	exempt := make(map[common.Address]struct{})
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("revert guard exempt sender \"%v\" is not a valid address", entry)
+		}
+		exempt[common.HexToAddress(entry)] = struct{}{}
+	}
+	return exempt, nil
+}
+
+// revertGuardRejects returns whether the guard rejects a transaction which executed with the receipt in the block
+// being built, rather than including it. Rejection rolls the transaction back, so the block isn't charged for it,
+// and it's the execution the transaction would have had in the block, so no separate simulation is needed.
+func (s *Sequencer) revertGuardRejects(tx *types.Transaction, sender common.Address, receipt *types.Receipt) bool {
+	config := &s.config.RevertGuard
+	if !config.Enable || receipt.Status != types.ReceiptStatusFailed {
+		return false
+	}
+	if _, exempt := s.revertGuardExempt[sender]; exempt {
+		return false
+	}
+	if config.MaxGas > 0 && tx.Gas() > config.MaxGas {
+		return false
+	}
+	revertGuardRejectedCounter.Inc(1)
+	return true
+}
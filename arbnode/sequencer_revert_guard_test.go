@@ -0,0 +1,99 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestParseRevertGuardExemptions(t *testing.T) {
+	exempt, err := parseRevertGuardExemptions("")
+	Require(t, err)
+	if len(exempt) != 0 {
+		Fail(t, "empty list should exempt no one", exempt)
+	}
+
+	first := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	second := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	exempt, err = parseRevertGuardExemptions(" " + first.Hex() + ",, " + second.Hex() + " ")
+	Require(t, err)
+	if len(exempt) != 2 {
+		Fail(t, "expected two exempt senders", exempt)
+	}
+	for _, sender := range []common.Address{first, second} {
+		if _, ok := exempt[sender]; !ok {
+			Fail(t, "sender missing from exemptions", sender)
+		}
+	}
+
+	if _, err := parseRevertGuardExemptions("not-an-address"); err == nil {
+		Fail(t, "invalid address should be rejected")
+	}
+}
+
+func TestRevertGuardRejects(t *testing.T) {
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	exemptSender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	config := TestSequencerConfig
+	config.RevertGuard = RevertGuardConfig{
+		Enable: true,
+		MaxGas: 1_000_000,
+	}
+	seq := &Sequencer{
+		config:            config,
+		revertGuardExempt: map[common.Address]struct{}{exemptSender: {}},
+	}
+	txWithGas := func(gas uint64) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{Gas: gas, GasPrice: big.NewInt(1)})
+	}
+	failed := &types.Receipt{Status: types.ReceiptStatusFailed}
+	succeeded := &types.Receipt{Status: types.ReceiptStatusSuccessful}
+
+	if !seq.revertGuardRejects(txWithGas(100_000), sender, failed) {
+		Fail(t, "reverting transaction should be rejected")
+	}
+	if seq.revertGuardRejects(txWithGas(100_000), sender, succeeded) {
+		Fail(t, "successful transaction should be included")
+	}
+	if seq.revertGuardRejects(txWithGas(100_000), exemptSender, failed) {
+		Fail(t, "exempt sender's reverting transaction should be included")
+	}
+	if !seq.revertGuardRejects(txWithGas(config.RevertGuard.MaxGas), sender, failed) {
+		Fail(t, "reverting transaction at the gas cap should be rejected")
+	}
+	if seq.revertGuardRejects(txWithGas(config.RevertGuard.MaxGas+1), sender, failed) {
+		Fail(t, "reverting transaction over the gas cap should be included")
+	}
+
+	seq.config.RevertGuard.MaxGas = 0
+	if !seq.revertGuardRejects(txWithGas(100_000_000), sender, failed) {
+		Fail(t, "no gas cap should reject reverting transactions of any size")
+	}
+
+	seq.config.RevertGuard.Enable = false
+	if seq.revertGuardRejects(txWithGas(100_000), sender, failed) {
+		Fail(t, "disabled guard should reject nothing")
+	}
+}
+
+func TestPostTxFilterRevertGuard(t *testing.T) {
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	config := TestSequencerConfig
+	config.MaxRevertGasReject = 0
+	config.RevertGuard = RevertGuardConfig{Enable: true}
+	seq := &Sequencer{config: config}
+	tx := types.NewTx(&types.LegacyTx{Gas: 100_000, GasPrice: big.NewInt(1)})
+
+	err := seq.postTxFilter(nil, tx, sender, 0, &types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: 50_000})
+	if !errors.Is(err, ErrRevertGuardRejected) {
+		Fail(t, "expected the revert guard to reject the transaction", err)
+	}
+	err = seq.postTxFilter(nil, tx, sender, 0, &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 50_000})
+	Require(t, err)
+}
@@ -0,0 +1,233 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/rpcfilter"
+)
+
+var (
+	spamBannedCounter      = metrics.NewRegisteredCounter("arb/sequencer/spam/banned", nil)
+	spamRateLimitedCounter = metrics.NewRegisteredCounter("arb/sequencer/spam/ratelimited", nil)
+	spamNonceGapCounter    = metrics.NewRegisteredCounter("arb/sequencer/spam/noncegap", nil)
+	spamQueueFullCounter   = metrics.NewRegisteredCounter("arb/sequencer/spam/queuefull", nil)
+)
+
+// SpamProtectionConfig limits how much of the sequencer's capacity a single sender or origin IP can take.
+// Senders and IPs on the allowlist are exempt from the limits, while those on the banlist are always rejected.
+// A node forwarding transactions to the sequencer is the origin of all of them, so it should be allowlisted.
+type SpamProtectionConfig struct {
+	SenderRate         float64 `koanf:"sender-rate"`
+	SenderBurst        int     `koanf:"sender-burst"`
+	OriginRate         float64 `koanf:"origin-rate"`
+	OriginBurst        int     `koanf:"origin-burst"`
+	MaxNonceGap        uint64  `koanf:"max-nonce-gap"`
+	MaxQueuedPerSender int     `koanf:"max-queued-per-sender"`
+	Banlist            string  `koanf:"banlist"`
+	Allowlist          string  `koanf:"allowlist"`
+}
+
+func SpamProtectionConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Float64(prefix+".sender-rate", DefaultSpamProtectionConfig.SenderRate, "transactions per second each sender may submit on average (0 = unlimited)")
+	f.Int(prefix+".sender-burst", DefaultSpamProtectionConfig.SenderBurst, "transactions each sender may submit at once above its rate")
+	f.Float64(prefix+".origin-rate", DefaultSpamProtectionConfig.OriginRate, "transactions per second each origin IP may submit on average (0 = unlimited)")
+	f.Int(prefix+".origin-burst", DefaultSpamProtectionConfig.OriginBurst, "transactions each origin IP may submit at once above its rate")
+	f.Uint64(prefix+".max-nonce-gap", DefaultSpamProtectionConfig.MaxNonceGap, "reject transactions whose nonce is more than this far ahead of the sender's current nonce (0 = unlimited)")
+	f.Int(prefix+".max-queued-per-sender", DefaultSpamProtectionConfig.MaxQueuedPerSender, "maximum transactions from one sender waiting to be sequenced at once (0 = unlimited)")
+	f.String(prefix+".banlist", DefaultSpamProtectionConfig.Banlist, "comma separated sender addresses and origin IPs whose transactions are rejected")
+	f.String(prefix+".allowlist", DefaultSpamProtectionConfig.Allowlist, "comma separated sender addresses and origin IPs exempt from rate and queue limits")
+}
+
+var DefaultSpamProtectionConfig = SpamProtectionConfig{
+	SenderRate:         0,
+	SenderBurst:        10,
+	OriginRate:         0,
+	OriginBurst:        100,
+	MaxNonceGap:        0,
+	MaxQueuedPerSender: 0,
+	Banlist:            "",
+	Allowlist:          "",
+}
+
+// parseSpamList parses a list of addresses and IPs into the keys the spam filter looks them up by.
+func parseSpamList(list string) (map[string]struct{}, error) {
+	entries := make(map[string]struct{})
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if common.IsHexAddress(entry) {
+			entries[common.HexToAddress(entry).Hex()] = struct{}{}
+		} else if ip := net.ParseIP(entry); ip != nil {
+			entries[ip.String()] = struct{}{}
+		} else {
+			return nil, fmt.Errorf("spam protection list entry \"%v\" is neither an address nor an IP", entry)
+		}
+	}
+	return entries, nil
+}
+
+// originFromContext returns the IP a transaction was submitted from over RPC, or an empty string if unknown.
+// Transactions relayed by a method policy filter are from the filter's client, not the filter.
+func originFromContext(ctx context.Context) string {
+	remote, ok := ctx.Value("remote").(string)
+	if !ok || remote == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(rpcfilter.ClientOrigin(remote))
+	if err != nil {
+		host = remote
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time, rate float64, burst int) {
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
+
+type spamFilter struct {
+	config *SpamProtectionConfig
+
+	banned  map[string]struct{}
+	allowed map[string]struct{}
+
+	mutex         sync.Mutex
+	senderBuckets map[string]*tokenBucket
+	originBuckets map[string]*tokenBucket
+	queued        map[common.Address]int
+}
+
+func newSpamFilter(config *SpamProtectionConfig) (*spamFilter, error) {
+	banned, err := parseSpamList(config.Banlist)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := parseSpamList(config.Allowlist)
+	if err != nil {
+		return nil, err
+	}
+	return &spamFilter{
+		config:        config,
+		banned:        banned,
+		allowed:       allowed,
+		senderBuckets: make(map[string]*tokenBucket),
+		originBuckets: make(map[string]*tokenBucket),
+		queued:        make(map[common.Address]int),
+	}, nil
+}
+
+// take spends a token from the key's bucket, creating a full one if needed, returning false if it's empty.
+// Only call this with the mutex held.
+func (f *spamFilter) take(buckets map[string]*tokenBucket, key string, rate float64, burst int, now time.Time) bool {
+	bucket, ok := buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		buckets[key] = bucket
+	}
+	bucket.refill(now, rate, burst)
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// admit decides whether to queue a transaction from sender with the given nonce, where senderNonce returns the
+// sender's current nonce. Once admitted, release must be called when the transaction leaves the queue.
+func (f *spamFilter) admit(ctx context.Context, sender common.Address, nonce uint64, senderNonce func() (uint64, error)) error {
+	origin := originFromContext(ctx)
+	senderKey := sender.Hex()
+	if _, ok := f.banned[senderKey]; ok {
+		spamBannedCounter.Inc(1)
+		return fmt.Errorf("transaction sender %v is banned", sender)
+	}
+	if _, ok := f.banned[origin]; ok && origin != "" {
+		spamBannedCounter.Inc(1)
+		return fmt.Errorf("transaction origin %v is banned", origin)
+	}
+	_, senderAllowed := f.allowed[senderKey]
+	_, originAllowed := f.allowed[origin]
+	exempt := senderAllowed || (originAllowed && origin != "")
+
+	if !exempt && f.config.MaxNonceGap > 0 {
+		current, err := senderNonce()
+		if err != nil {
+			return err
+		}
+		if nonce > current+f.config.MaxNonceGap {
+			spamNonceGapCounter.Inc(1)
+			return fmt.Errorf("nonce %v is more than %v ahead of the sender's nonce %v", nonce, f.config.MaxNonceGap, current)
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !exempt {
+		if f.config.MaxQueuedPerSender > 0 && f.queued[sender] >= f.config.MaxQueuedPerSender {
+			spamQueueFullCounter.Inc(1)
+			return fmt.Errorf("sender %v already has %v transactions waiting to be sequenced", sender, f.queued[sender])
+		}
+		now := time.Now()
+		if f.config.SenderRate > 0 && !f.take(f.senderBuckets, senderKey, f.config.SenderRate, f.config.SenderBurst, now) {
+			spamRateLimitedCounter.Inc(1)
+			return fmt.Errorf("sender %v is submitting transactions too quickly", sender)
+		}
+		if f.config.OriginRate > 0 && origin != "" && !f.take(f.originBuckets, origin, f.config.OriginRate, f.config.OriginBurst, now) {
+			spamRateLimitedCounter.Inc(1)
+			return fmt.Errorf("origin %v is submitting transactions too quickly", origin)
+		}
+	}
+	f.queued[sender]++
+	return nil
+}
+
+func (f *spamFilter) release(sender common.Address) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.queued[sender]--
+	if f.queued[sender] <= 0 {
+		delete(f.queued, sender)
+	}
+}
+
+// prune forgets the buckets which have refilled, as they'd be recreated full anyway.
+func (f *spamFilter) prune() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := time.Now()
+	for key, bucket := range f.senderBuckets {
+		bucket.refill(now, f.config.SenderRate, f.config.SenderBurst)
+		if bucket.tokens >= float64(f.config.SenderBurst) {
+			delete(f.senderBuckets, key)
+		}
+	}
+	for key, bucket := range f.originBuckets {
+		bucket.refill(now, f.config.OriginRate, f.config.OriginBurst)
+		if bucket.tokens >= float64(f.config.OriginBurst) {
+			delete(f.originBuckets, key)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSpamFilter(t *testing.T) {
+	config := SpamProtectionConfig{
+		SenderRate:         0.001,
+		SenderBurst:        2,
+		OriginRate:         0.001,
+		OriginBurst:        3,
+		MaxNonceGap:        4,
+		MaxQueuedPerSender: 1,
+		Banlist:            "0x0000000000000000000000000000000000001111,10.0.0.66",
+		Allowlist:          "10.0.0.1",
+	}
+	filter, err := newSpamFilter(&config)
+	Require(t, err)
+	nonce := func() (uint64, error) { return 10, nil }
+	alice := common.HexToAddress("0x2222")
+	bob := common.HexToAddress("0x3333")
+	carol := common.HexToAddress("0x4444")
+	fromIP := func(ip string) context.Context {
+		// geth keys the remote address of RPC requests by this string
+		// nolint:staticcheck
+		return context.WithValue(context.Background(), "remote", ip+":12345")
+	}
+
+	if filter.admit(context.Background(), common.HexToAddress("0x1111"), 10, nonce) == nil {
+		Fail(t, "admitted banned sender")
+	}
+	if filter.admit(fromIP("10.0.0.66"), alice, 10, nonce) == nil {
+		Fail(t, "admitted banned origin")
+	}
+	if filter.admit(context.Background(), alice, 15, nonce) == nil {
+		Fail(t, "admitted transaction beyond the nonce gap")
+	}
+
+	Require(t, filter.admit(fromIP("10.0.0.2"), alice, 14, nonce))
+	if filter.admit(fromIP("10.0.0.2"), alice, 11, nonce) == nil {
+		Fail(t, "admitted more than the max queued per sender")
+	}
+	filter.release(alice)
+	Require(t, filter.admit(fromIP("10.0.0.2"), alice, 11, nonce))
+	filter.release(alice)
+	if filter.admit(fromIP("10.0.0.2"), alice, 12, nonce) == nil {
+		Fail(t, "admitted sender beyond its burst")
+	}
+
+	// the origin's bucket has one token left after alice's two transactions
+	Require(t, filter.admit(fromIP("10.0.0.2"), bob, 10, nonce))
+	filter.release(bob)
+	if filter.admit(fromIP("10.0.0.2"), carol, 10, nonce) == nil {
+		Fail(t, "admitted origin beyond its burst")
+	}
+
+	// allowlisted origins are exempt from the limits
+	for i := 0; i < 5; i++ {
+		Require(t, filter.admit(fromIP("10.0.0.1"), alice, 20, nonce))
+		filter.release(alice)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	flag "github.com/spf13/pflag"
+)
+
+var timeBoostedCounter = metrics.NewRegisteredCounter("arb/sequencer/timeboost/boosted", nil)
+
+// TimeBoostConfig replaces first come, first served sequencing with a time boost: a transaction paying a
+// priority fee is sequenced as if it arrived up to max-boost earlier than the others queued for the same block.
+// The boost grows with the fee, reaching half of max-boost at a fee of boost-half-tip. Priority fees are only
+// charged from ArbOS version arbos.PriorityFeeArbosVersion, so before then no transaction is boosted.
+type TimeBoostConfig struct {
+	Enable             bool          `koanf:"enable"`
+	MaxBoost           time.Duration `koanf:"max-boost"`
+	BoostHalfTip       float64       `koanf:"boost-half-tip"`
+	MaxBoostedPerBlock int           `koanf:"max-boosted-per-block"`
+}
+
+func TimeBoostConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultTimeBoostConfig.Enable, "order transactions by arrival time minus a boost for their priority fee instead of first come, first served, once ArbOS charges priority fees (can't be used with an ordering hook)")
+	f.Duration(prefix+".max-boost", DefaultTimeBoostConfig.MaxBoost, "the largest head start a priority fee can buy over transactions queued for the same block")
+	f.Float64(prefix+".boost-half-tip", DefaultTimeBoostConfig.BoostHalfTip, "the priority fee in gwei which buys half of the max boost")
+	f.Int(prefix+".max-boosted-per-block", DefaultTimeBoostConfig.MaxBoostedPerBlock, "the most transactions boosted per block, keeping those paying the highest priority fees (0 = unlimited)")
+}
+
+var DefaultTimeBoostConfig = TimeBoostConfig{
+	Enable:             false,
+	MaxBoost:           200 * time.Millisecond,
+	BoostHalfTip:       1,
+	MaxBoostedPerBlock: 32,
+}
+
+// TimeBoostOrderingPolicy orders candidates by their boosted arrival time, keeping each sender's transactions in order.
+type TimeBoostOrderingPolicy struct {
+	config *TimeBoostConfig
+}
+
+func NewTimeBoostOrderingPolicy(config *TimeBoostConfig) *TimeBoostOrderingPolicy {
+	return &TimeBoostOrderingPolicy{config}
+}
+
+// boost returns the head start bought by a priority fee, which approaches the max boost as the fee grows.
+func (p *TimeBoostOrderingPolicy) boost(tip *big.Int) time.Duration {
+	if tip == nil || tip.Sign() <= 0 {
+		return 0
+	}
+	tipFloat, _ := new(big.Float).SetInt(tip).Float64()
+	halfTip := p.config.BoostHalfTip * params.GWei
+	return time.Duration(float64(p.config.MaxBoost) * tipFloat / (tipFloat + halfTip))
+}
+
+func (p *TimeBoostOrderingPolicy) Order(ctx context.Context, candidates []*OrderingCandidate) ([]int, error) {
+	tip := func(i int) *big.Int {
+		if candidates[i].PriorityFee == nil {
+			return common.Big0
+		}
+		return candidates[i].PriorityFee.ToInt()
+	}
+
+	// only the transactions paying the highest priority fees are boosted
+	var boosted []int
+	for i := range candidates {
+		if tip(i).Sign() > 0 {
+			boosted = append(boosted, i)
+		}
+	}
+	sort.SliceStable(boosted, func(a, b int) bool {
+		return tip(boosted[a]).Cmp(tip(boosted[b])) > 0
+	})
+	if p.config.MaxBoostedPerBlock > 0 && len(boosted) > p.config.MaxBoostedPerBlock {
+		boosted = boosted[:p.config.MaxBoostedPerBlock]
+	}
+	timeBoostedCounter.Inc(int64(len(boosted)))
+
+	effective := make([]time.Time, len(candidates))
+	for i, candidate := range candidates {
+		effective[i] = candidate.QueuedAt
+	}
+	for _, i := range boosted {
+		effective[i] = effective[i].Add(-p.boost(tip(i)))
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return effective[order[a]].Before(effective[order[b]])
+	})
+
+	// a boost mustn't reorder a sender's transactions, so each sender's positions are refilled in arrival order
+	bySender := make(map[common.Address][]int)
+	for i, candidate := range candidates {
+		bySender[candidate.Sender] = append(bySender[candidate.Sender], i)
+	}
+	for position, index := range order {
+		sender := candidates[index].Sender
+		order[position] = bySender[sender][0]
+		bySender[sender] = bySender[sender][1:]
+	}
+	return order, nil
+}
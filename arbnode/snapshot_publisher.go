@@ -0,0 +1,758 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+var (
+	snapshotPublishedCounter = metrics.NewRegisteredCounter("arb/snapshot/published", nil)
+	snapshotFailedCounter    = metrics.NewRegisteredCounter("arb/snapshot/failed", nil)
+	snapshotBytesCounter     = metrics.NewRegisteredCounter("arb/snapshot/bytes", nil)
+)
+
+const (
+	SnapshotKindPruned  = "pruned"
+	SnapshotKindArchive = "archive"
+)
+
+type SnapshotPublisherConfig struct {
+	Enable    bool                 `koanf:"enable"`
+	Interval  time.Duration        `koanf:"interval"`
+	Pruned    bool                 `koanf:"pruned"`
+	Archive   bool                 `koanf:"archive"`
+	WorkDir   string               `koanf:"work-dir"`
+	Retention int                  `koanf:"retention"`
+	Directory string               `koanf:"directory"`
+	S3        SnapshotS3Config     `koanf:"s3"`
+	Serve     SnapshotServerConfig `koanf:"serve"`
+}
+
+func SnapshotPublisherConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultSnapshotPublisherConfig.Enable, "periodically publish database snapshots for others to sync from")
+	f.Duration(prefix+".interval", DefaultSnapshotPublisherConfig.Interval, "how often to publish snapshots")
+	f.Bool(prefix+".pruned", DefaultSnapshotPublisherConfig.Pruned, "publish pruned snapshots, holding only the state of the checkpoint block")
+	f.Bool(prefix+".archive", DefaultSnapshotPublisherConfig.Archive, "publish archive snapshots, holding all the state in the database")
+	f.String(prefix+".work-dir", DefaultSnapshotPublisherConfig.WorkDir, "directory to export snapshots to before uploading them (defaults to a directory in the data directory)")
+	f.Int(prefix+".retention", DefaultSnapshotPublisherConfig.Retention, "number of snapshots of each kind to keep published")
+	f.String(prefix+".directory", DefaultSnapshotPublisherConfig.Directory, "local directory to publish snapshots to, instead of S3")
+	SnapshotS3ConfigAddOptions(prefix+".s3", f)
+	SnapshotServerConfigAddOptions(prefix+".serve", f)
+}
+
+var DefaultSnapshotPublisherConfig = SnapshotPublisherConfig{
+	Enable:    false,
+	Interval:  24 * time.Hour,
+	Pruned:    true,
+	Archive:   false,
+	WorkDir:   "",
+	Retention: 7,
+	Directory: "",
+	S3:        DefaultSnapshotS3Config,
+	Serve:     DefaultSnapshotServerConfig,
+}
+
+type SnapshotFile struct {
+	Name   string      `json:"name"`
+	Size   uint64      `json:"size"`
+	SHA256 common.Hash `json:"sha256"`
+}
+
+type SnapshotBlock struct {
+	Number    uint64      `json:"number"`
+	Hash      common.Hash `json:"hash"`
+	StateRoot common.Hash `json:"stateRoot"`
+}
+
+// SnapshotL1Checkpoint is the last batch posted to L1 which the snapshot includes. A node synced
+// from the snapshot doesn't need to read the L1 inbox before it.
+type SnapshotL1Checkpoint struct {
+	BatchCount          uint64      `json:"batchCount"`
+	L1Block             uint64      `json:"l1Block"`
+	MessageCount        uint64      `json:"messageCount"`
+	DelayedMessageCount uint64      `json:"delayedMessageCount"`
+	Accumulator         common.Hash `json:"accumulator"`
+}
+
+// SnapshotManifest describes a published snapshot. It's uploaded after the files it lists,
+// so a manifest which can be read always refers to complete files.
+type SnapshotManifest struct {
+	Kind         string               `json:"kind"`
+	CreatedAt    time.Time            `json:"createdAt"`
+	ChainID      *hexutil.Big         `json:"chainId"`
+	Block        SnapshotBlock        `json:"block"`
+	L1Checkpoint SnapshotL1Checkpoint `json:"l1Checkpoint"`
+	Files        []SnapshotFile       `json:"files"`
+}
+
+// SnapshotJobStatus is the state of the latest snapshot job, whether started on the interval or over RPC.
+type SnapshotJobStatus struct {
+	Running    bool               `json:"running"`
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Published  []SnapshotManifest `json:"published"`
+	Error      string             `json:"error,omitempty"`
+}
+
+type snapshotCheckpoint struct {
+	block *types.Block
+	l1    SnapshotL1Checkpoint
+}
+
+// SnapshotPublisher exports the chain and arbitrum databases as of the latest batch posted to L1,
+// and publishes them along with a manifest, deleting the oldest snapshots beyond the retention.
+type SnapshotPublisher struct {
+	stopwaiter.StopWaiter
+	config     *SnapshotPublisherConfig
+	chainDb    ethdb.Database
+	arbDb      ethdb.Database
+	ancientDir string
+	workDir    string
+	bc         *core.BlockChain
+	txStreamer *TransactionStreamer
+	tracker    *InboxTracker
+	store      SnapshotStore
+	server     *SnapshotServer
+
+	publishMutex  sync.Mutex // held while publishing, and guards lastPublished
+	lastPublished map[string]uint64
+
+	jobMutex sync.Mutex
+	job      SnapshotJobStatus
+}
+
+func NewSnapshotPublisher(
+	config *SnapshotPublisherConfig,
+	chainDb ethdb.Database,
+	arbDb ethdb.Database,
+	ancientDir string,
+	workDir string,
+	bc *core.BlockChain,
+	txStreamer *TransactionStreamer,
+	tracker *InboxTracker,
+	store SnapshotStore,
+) (*SnapshotPublisher, error) {
+	if !config.Pruned && !config.Archive {
+		return nil, errors.New("snapshot publisher enabled but neither pruned nor archive snapshots are")
+	}
+	if config.Retention <= 0 {
+		return nil, errors.New("snapshot publisher retention must be positive")
+	}
+	if store == nil {
+		if config.Directory != "" {
+			store = &directorySnapshotStore{dir: config.Directory}
+		} else if config.S3.Bucket != "" {
+			store = newS3SnapshotStore(&config.S3)
+		} else {
+			return nil, errors.New("snapshot publisher enabled but no directory or S3 bucket configured")
+		}
+	}
+	if config.WorkDir != "" {
+		workDir = config.WorkDir
+	}
+	var server *SnapshotServer
+	if config.Serve.Enable {
+		if config.Directory == "" {
+			return nil, errors.New("serving snapshots requires publishing them to a directory")
+		}
+		server = NewSnapshotServer(config.Serve.Addr, config.Directory)
+	}
+	return &SnapshotPublisher{
+		config:        config,
+		chainDb:       chainDb,
+		arbDb:         arbDb,
+		ancientDir:    ancientDir,
+		workDir:       workDir,
+		bc:            bc,
+		txStreamer:    txStreamer,
+		tracker:       tracker,
+		store:         store,
+		server:        server,
+		lastPublished: make(map[string]uint64),
+	}, nil
+}
+
+func (p *SnapshotPublisher) kinds() []string {
+	var kinds []string
+	if p.config.Pruned {
+		kinds = append(kinds, SnapshotKindPruned)
+	}
+	if p.config.Archive {
+		kinds = append(kinds, SnapshotKindArchive)
+	}
+	return kinds
+}
+
+// checkpoint picks the block produced by the last batch posted to L1.
+func (p *SnapshotPublisher) checkpoint() (*snapshotCheckpoint, error) {
+	batchCount, err := p.tracker.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	if batchCount == 0 {
+		return nil, errors.New("no batches posted yet")
+	}
+	meta, err := p.tracker.GetBatchMetadata(batchCount - 1)
+	if err != nil {
+		return nil, err
+	}
+	blockNum, err := p.txStreamer.MessageCountToBlockNumber(meta.MessageCount)
+	if err != nil {
+		return nil, err
+	}
+	if blockNum < 0 {
+		return nil, errors.New("checkpoint is before the nitro genesis block")
+	}
+	block := p.bc.GetBlockByNumber(uint64(blockNum))
+	if block == nil || uint64(blockNum) > p.bc.CurrentBlock().NumberU64() {
+		return nil, fmt.Errorf("checkpoint block %v not yet executed", blockNum)
+	}
+	return &snapshotCheckpoint{
+		block: block,
+		l1: SnapshotL1Checkpoint{
+			BatchCount:          batchCount,
+			L1Block:             meta.L1Block,
+			MessageCount:        uint64(meta.MessageCount),
+			DelayedMessageCount: meta.DelayedMessageCount,
+			Accumulator:         meta.Accumulator,
+		},
+	}, nil
+}
+
+// copyDatabase copies every entry of the database for which keep returns true. The source
+// iterator reads from a consistent snapshot of the database, so it can be written to meanwhile.
+func copyDatabase(ctx context.Context, from ethdb.Iteratee, to ethdb.Batcher, keep func(key []byte) bool) error {
+	it := from.NewIterator(nil, nil)
+	defer it.Release()
+	batch := to.NewBatch()
+	for it.Next() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if keep != nil && !keep(it.Key()) {
+			continue
+		}
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// copyState copies the trie nodes of the state at root, and its storage tries. The state of recent blocks
+// may only be held in memory by the trie database, so it's committed to disk first.
+func copyState(ctx context.Context, stateDb state.Database, to ethdb.Batcher, root common.Hash) error {
+	if err := stateDb.TrieDB().Commit(root, false, nil); err != nil {
+		return err
+	}
+	from := stateDb.TrieDB().DiskDB()
+	batch := to.NewBatch()
+	copyTrie := func(tr state.Trie, onLeaf func(key []byte, leaf []byte) error) error {
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if hash := it.Hash(); hash != (common.Hash{}) {
+				// Nodes embedded in their parents have no hash, and are copied along with them
+				node, err := from.Get(hash[:])
+				if err != nil {
+					return err
+				}
+				if err := batch.Put(hash[:], node); err != nil {
+					return err
+				}
+			}
+			if it.Leaf() && onLeaf != nil {
+				if err := onLeaf(it.LeafKey(), it.LeafBlob()); err != nil {
+					return err
+				}
+			}
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		return it.Error()
+	}
+	accountTrie, err := stateDb.OpenTrie(root)
+	if err != nil {
+		return fmt.Errorf("state %v no longer available: %w", root, err)
+	}
+	err = copyTrie(accountTrie, func(key []byte, leaf []byte) error {
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(leaf, &account); err != nil {
+			return err
+		}
+		if account.Root == types.EmptyRootHash {
+			return nil
+		}
+		storageTrie, err := stateDb.OpenStorageTrie(common.BytesToHash(key), account.Root)
+		if err != nil {
+			return err
+		}
+		return copyTrie(storageTrie, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// exportChainDb writes the chain database to dir, with the checkpoint block as its head, so a node started
+// from it executes from there. Pruned exports only keep the state of the checkpoint block.
+func (p *SnapshotPublisher) exportChainDb(ctx context.Context, kind string, checkpoint *snapshotCheckpoint, dir string) error {
+	db, err := rawdb.NewLevelDBDatabase(dir, 16, 16, "", false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	root := checkpoint.block.Root()
+	if kind == SnapshotKindArchive {
+		// The checkpoint's state may only be held in memory, so flush it for the copy to include it
+		if err := p.bc.StateCache().TrieDB().Commit(root, false, nil); err != nil {
+			return err
+		}
+		if err := copyDatabase(ctx, p.chainDb, db, nil); err != nil {
+			return err
+		}
+	} else {
+		// Trie nodes are keyed by their bare hash, so skip those and copy just the ones reachable from the checkpoint
+		err := copyDatabase(ctx, p.chainDb, db, func(key []byte) bool {
+			return len(key) != common.HashLength
+		})
+		if err != nil {
+			return err
+		}
+		if err := copyState(ctx, p.bc.StateCache(), db, root); err != nil {
+			return err
+		}
+	}
+	hash := checkpoint.block.Hash()
+	rawdb.WriteHeadBlockHash(db, hash)
+	rawdb.WriteHeadHeaderHash(db, hash)
+	rawdb.WriteHeadFastBlockHash(db, hash)
+	if p.ancientDir == "" {
+		return nil
+	}
+	if _, err := os.Stat(p.ancientDir); os.IsNotExist(err) {
+		return nil
+	}
+	// The freezer is only ever appended to, and truncates itself to its shortest table when opened,
+	// so a copy taken while it's written to is still usable.
+	return copyDir(p.ancientDir, filepath.Join(dir, "ancient"))
+}
+
+// truncateArbDb deletes the messages, delayed messages and batches of an exported arbitrum database beyond
+// the checkpoint, the way an L1 reorg to it would.
+func truncateArbDb(db ethdb.Database, checkpoint SnapshotL1Checkpoint) error {
+	tracker := &InboxTracker{db: db}
+	batch := db.NewBatch()
+	err := deleteStartingAt(db, batch, delayedSequencedPrefix, uint64ToKey(checkpoint.DelayedMessageCount+1))
+	if err != nil {
+		return err
+	}
+	// The block index reads the metadata of the batches it removes, so it must be deleted first
+	if err := tracker.deleteBlockIndexFrom(batch, checkpoint.BatchCount); err != nil {
+		return err
+	}
+	for _, prefix := range [][]byte{sequencerBatchMetaPrefix, batchLedgerPrefix} {
+		if err := deleteStartingAt(db, batch, prefix, uint64ToKey(checkpoint.BatchCount)); err != nil {
+			return err
+		}
+	}
+	if err := deleteStartingAt(db, batch, delayedMessagePrefix, uint64ToKey(checkpoint.DelayedMessageCount)); err != nil {
+		return err
+	}
+	if err := deleteStartingAt(db, batch, messagePrefix, uint64ToKey(checkpoint.MessageCount)); err != nil {
+		return err
+	}
+	counts := []struct {
+		key   []byte
+		count uint64
+	}{
+		{sequencerBatchCountKey, checkpoint.BatchCount},
+		{delayedMessageCountKey, checkpoint.DelayedMessageCount},
+		{messageCountKey, checkpoint.MessageCount},
+	}
+	for _, c := range counts {
+		data, err := rlp.EncodeToBytes(c.count)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(c.key, data); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// packageDir writes dir as a gzipped tarball, returning its size and hash.
+func packageDir(dir string, name string, out string) (SnapshotFile, error) {
+	file, err := os.Create(out)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	counter := &countingWriter{}
+	gz := gzip.NewWriter(io.MultiWriter(file, hasher, counter))
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == "LOCK" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	if err := tw.Close(); err != nil {
+		return SnapshotFile{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return SnapshotFile{}, err
+	}
+	return SnapshotFile{
+		Name:   filepath.Base(out),
+		Size:   counter.count,
+		SHA256: common.BytesToHash(hasher.Sum(nil)),
+	}, file.Close()
+}
+
+type countingWriter struct {
+	count uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.count += uint64(len(p))
+	return len(p), nil
+}
+
+func snapshotID(checkpoint *snapshotCheckpoint) string {
+	return snapshotIDForBlock(checkpoint.block.NumberU64())
+}
+
+func snapshotIDForBlock(number uint64) string {
+	// zero padded so snapshots sort by block
+	return fmt.Sprintf("%020d", number)
+}
+
+func (p *SnapshotPublisher) publish(ctx context.Context, kind string, checkpoint *snapshotCheckpoint) (*SnapshotManifest, error) {
+	id := snapshotID(checkpoint)
+	workDir := filepath.Join(p.workDir, kind+"-"+id)
+	if err := os.RemoveAll(workDir); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	chainDir := filepath.Join(workDir, "l2chaindata")
+	if err := p.exportChainDb(ctx, kind, checkpoint, chainDir); err != nil {
+		return nil, fmt.Errorf("exporting chain database: %w", err)
+	}
+	// The arbitrum database is copied after the chain database, so its inbox is never behind the blocks,
+	// and then truncated to the checkpoint
+	arbDir := filepath.Join(workDir, "arbitrumdata")
+	arbDb, err := rawdb.NewLevelDBDatabase(arbDir, 16, 16, "", false)
+	if err != nil {
+		return nil, err
+	}
+	err = copyDatabase(ctx, p.arbDb, arbDb, func(key []byte) bool {
+		// The sequencer's queue and the mirror's progress are this node's own, not the chain's
+		return !bytes.Equal(key, sequencerQueueKey) && !bytes.Equal(key, inboxMirrorCursorKey)
+	})
+	if err == nil {
+		err = truncateArbDb(arbDb, checkpoint.l1)
+	}
+	arbDb.Close()
+	if err != nil {
+		return nil, fmt.Errorf("exporting arbitrum database: %w", err)
+	}
+
+	manifest := SnapshotManifest{
+		Kind:         kind,
+		CreatedAt:    time.Now().UTC(),
+		ChainID:      (*hexutil.Big)(new(big.Int).Set(p.bc.Config().ChainID)),
+		L1Checkpoint: checkpoint.l1,
+		Block: SnapshotBlock{
+			Number:    checkpoint.block.NumberU64(),
+			Hash:      checkpoint.block.Hash(),
+			StateRoot: checkpoint.block.Root(),
+		},
+	}
+	for _, dir := range []string{"l2chaindata", "arbitrumdata"} {
+		out := filepath.Join(workDir, dir+".tar.gz")
+		file, err := packageDir(filepath.Join(workDir, dir), dir, out)
+		if err != nil {
+			return nil, fmt.Errorf("packaging %v: %w", dir, err)
+		}
+		if err := p.upload(ctx, path.Join(kind, id, file.Name), out); err != nil {
+			return nil, fmt.Errorf("uploading %v: %w", file.Name, err)
+		}
+		snapshotBytesCounter.Inc(int64(file.Size))
+		manifest.Files = append(manifest.Files, file)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.store.Upload(ctx, path.Join(kind, id, "manifest.json"), bytes.NewReader(manifestData)); err != nil {
+		return nil, err
+	}
+	if err := p.store.Upload(ctx, path.Join(kind, "latest.json"), bytes.NewReader(manifestData)); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func (p *SnapshotPublisher) upload(ctx context.Context, name string, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.store.Upload(ctx, name, f)
+}
+
+// expiredSnapshots returns the objects of the snapshots of a kind beyond the newest retention.
+func expiredSnapshots(names []string, kind string, retention int) []string {
+	byID := make(map[string][]string)
+	for _, name := range names {
+		parts := strings.SplitN(strings.TrimPrefix(name, kind+"/"), "/", 2)
+		if len(parts) != 2 {
+			// not part of a snapshot, such as latest.json
+			continue
+		}
+		byID[parts[0]] = append(byID[parts[0]], name)
+	}
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var expired []string
+	for i := 0; i < len(ids)-retention; i++ {
+		expired = append(expired, byID[ids[i]]...)
+	}
+	return expired
+}
+
+func (p *SnapshotPublisher) prune(ctx context.Context, kind string) error {
+	names, err := p.store.List(ctx, kind+"/")
+	if err != nil {
+		return err
+	}
+	expired := expiredSnapshots(names, kind, p.config.Retention)
+	// Delete manifests first, so nothing refers to a partially deleted snapshot
+	sort.SliceStable(expired, func(i, j int) bool {
+		return strings.HasSuffix(expired[i], "/manifest.json") && !strings.HasSuffix(expired[j], "/manifest.json")
+	})
+	for _, name := range expired {
+		if err := p.store.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishNow publishes a snapshot of each configured kind, unless the last one is still current, returning the
+// manifests of those published.
+func (p *SnapshotPublisher) PublishNow(ctx context.Context) ([]SnapshotManifest, error) {
+	p.publishMutex.Lock()
+	defer p.publishMutex.Unlock()
+	checkpoint, err := p.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	var published []SnapshotManifest
+	for _, kind := range p.kinds() {
+		if last, ok := p.lastPublished[kind]; ok && last == checkpoint.block.NumberU64() {
+			log.Info("snapshot already published for checkpoint", "kind", kind, "block", last)
+			continue
+		}
+		start := time.Now()
+		manifest, err := p.publish(ctx, kind, checkpoint)
+		if err != nil {
+			snapshotFailedCounter.Inc(1)
+			return published, fmt.Errorf("publishing %v snapshot: %w", kind, err)
+		}
+		published = append(published, *manifest)
+		p.lastPublished[kind] = checkpoint.block.NumberU64()
+		snapshotPublishedCounter.Inc(1)
+		log.Info("published snapshot", "kind", kind, "block", checkpoint.block.NumberU64(), "batchCount", checkpoint.l1.BatchCount, "elapsed", time.Since(start))
+		if err := p.prune(ctx, kind); err != nil {
+			log.Warn("failed to delete old snapshots", "kind", kind, "err", err)
+		}
+	}
+	return published, nil
+}
+
+// beginJob marks a job as running, unless one already is.
+func (p *SnapshotPublisher) beginJob() bool {
+	p.jobMutex.Lock()
+	defer p.jobMutex.Unlock()
+	if p.job.Running {
+		return false
+	}
+	p.job = SnapshotJobStatus{Running: true, StartedAt: time.Now().UTC()}
+	return true
+}
+
+func (p *SnapshotPublisher) runJob(ctx context.Context) {
+	published, err := p.PublishNow(ctx)
+	if err != nil && ctx.Err() == nil {
+		log.Error("failed to publish snapshot", "err", err)
+	}
+	p.jobMutex.Lock()
+	defer p.jobMutex.Unlock()
+	finished := time.Now().UTC()
+	p.job.Running = false
+	p.job.FinishedAt = &finished
+	p.job.Published = published
+	if err != nil {
+		p.job.Error = err.Error()
+	}
+}
+
+// StartJob publishes snapshots in the background, without waiting for the interval.
+func (p *SnapshotPublisher) StartJob() (SnapshotJobStatus, error) {
+	if !p.beginJob() {
+		return p.JobStatus(), errors.New("a snapshot job is already running")
+	}
+	p.LaunchThread(p.runJob)
+	return p.JobStatus(), nil
+}
+
+func (p *SnapshotPublisher) JobStatus() SnapshotJobStatus {
+	p.jobMutex.Lock()
+	defer p.jobMutex.Unlock()
+	return p.job
+}
+
+func (p *SnapshotPublisher) Start(ctxIn context.Context) {
+	p.StopWaiter.Start(ctxIn)
+	if p.server != nil {
+		addr, err := p.server.Start()
+		if err != nil {
+			log.Error("failed to serve snapshots", "addr", p.config.Serve.Addr, "err", err)
+		} else {
+			log.Info("serving snapshots", "addr", addr, "dir", p.config.Directory)
+			p.LaunchThread(func(ctx context.Context) {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := p.server.Stop(shutdownCtx); err != nil {
+					log.Warn("error stopping snapshot server", "err", err)
+				}
+			})
+		}
+	}
+	p.LaunchThread(func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.config.Interval):
+			}
+			if !p.beginJob() {
+				log.Info("snapshot job still running, skipping the interval's")
+				continue
+			}
+			p.runJob(ctx)
+		}
+	})
+}
+
+// ExportSnapshot exports a snapshot of the kind from the databases of a node that isn't running to the
+// directory, laid out as it'd be published.
+func ExportSnapshot(ctx context.Context, kind string, chainDb ethdb.Database, arbDb ethdb.Database, ancientDir string, workDir string, bc *core.BlockChain, outDir string) (*SnapshotManifest, error) {
+	if kind != SnapshotKindPruned && kind != SnapshotKindArchive {
+		return nil, fmt.Errorf("unknown snapshot kind %v", kind)
+	}
+	txStreamer, err := NewTransactionStreamer(arbDb, bc, nil, &DefaultTransactionStreamerConfig)
+	if err != nil {
+		return nil, err
+	}
+	// Only batch metadata is read, so no data availability reader is needed
+	tracker := &InboxTracker{db: arbDb, txStreamer: txStreamer}
+	config := DefaultSnapshotPublisherConfig
+	config.Pruned = kind == SnapshotKindPruned
+	config.Archive = kind == SnapshotKindArchive
+	config.Directory = outDir
+	publisher, err := NewSnapshotPublisher(&config, chainDb, arbDb, ancientDir, workDir, bc, txStreamer, tracker, nil)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err := publisher.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	return publisher.publish(ctx, kind, checkpoint)
+}
@@ -0,0 +1,157 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+	names := []string{
+		"pruned/latest.json",
+		"pruned/00000000000000000300/manifest.json",
+		"pruned/00000000000000000300/l2chaindata.tar.gz",
+		"pruned/00000000000000000100/manifest.json",
+		"pruned/00000000000000000100/l2chaindata.tar.gz",
+		"pruned/00000000000000000200/manifest.json",
+	}
+	expired := expiredSnapshots(names, SnapshotKindPruned, 2)
+	sort.Strings(expired)
+	if len(expired) != 2 || expired[0] != "pruned/00000000000000000100/l2chaindata.tar.gz" || expired[1] != "pruned/00000000000000000100/manifest.json" {
+		Fail(t, "unexpected expired snapshots", expired)
+	}
+	if expired := expiredSnapshots(names, SnapshotKindPruned, 3); len(expired) != 0 {
+		Fail(t, "expired snapshots within retention", expired)
+	}
+}
+
+func TestPackageSnapshotDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "db")
+	Require(t, os.MkdirAll(filepath.Join(src, "ancient"), 0755))
+	Require(t, os.WriteFile(filepath.Join(src, "000001.ldb"), []byte("data"), 0644))
+	Require(t, os.WriteFile(filepath.Join(src, "ancient", "headers.0000.cdat"), []byte("headers"), 0644))
+	Require(t, os.WriteFile(filepath.Join(src, "LOCK"), nil, 0644))
+
+	out := filepath.Join(dir, "db.tar.gz")
+	file, err := packageDir(src, "db", out)
+	Require(t, err)
+	data, err := os.ReadFile(out)
+	Require(t, err)
+	if file.Name != "db.tar.gz" || file.Size != uint64(len(data)) || file.SHA256 != common.Hash(sha256.Sum256(data)) {
+		Fail(t, "unexpected packaged file", file)
+	}
+
+	store := &directorySnapshotStore{dir: filepath.Join(dir, "published")}
+	ctx := context.Background()
+	Require(t, store.Upload(ctx, "pruned/1/db.tar.gz", bytes.NewReader(data)))
+	Require(t, store.Upload(ctx, "archive/1/db.tar.gz", bytes.NewReader(data)))
+	names, err := store.List(ctx, "pruned/")
+	Require(t, err)
+	if len(names) != 1 || names[0] != "pruned/1/db.tar.gz" {
+		Fail(t, "unexpected published snapshots", names)
+	}
+	Require(t, store.Delete(ctx, "pruned/1/db.tar.gz"))
+	names, err = store.List(ctx, "pruned/")
+	Require(t, err)
+	if len(names) != 0 {
+		Fail(t, "snapshot not deleted", names)
+	}
+}
+
+func TestCopyStateInMemory(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	stateCache := state.NewDatabase(db)
+	stateDb, err := state.New(common.Hash{}, stateCache, nil)
+	Require(t, err)
+	account := common.HexToAddress("0xacc")
+	stateDb.SetBalance(account, big.NewInt(1000))
+	stateDb.SetState(account, common.HexToHash("0x1"), common.HexToHash("0x2"))
+	// Only committed to the trie database, as the blockchain does with recent blocks
+	root, err := stateDb.Commit(true)
+	Require(t, err)
+
+	exported := rawdb.NewMemoryDatabase()
+	Require(t, copyState(context.Background(), stateCache, exported, root))
+	copied, err := state.New(root, state.NewDatabase(exported), nil)
+	Require(t, err)
+	if balance := copied.GetBalance(account); balance.Cmp(big.NewInt(1000)) != 0 {
+		Fail(t, "unexpected copied balance", balance)
+	}
+	if value := copied.GetState(account, common.HexToHash("0x1")); value != common.HexToHash("0x2") {
+		Fail(t, "unexpected copied storage", value)
+	}
+
+	if err := copyState(context.Background(), state.NewDatabase(rawdb.NewMemoryDatabase()), exported, root); err == nil {
+		Fail(t, "copied a state that doesn't exist")
+	}
+}
+
+func TestTruncateArbDb(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	tracker := &InboxTracker{db: db}
+	metas := []BatchMetadata{
+		{MessageCount: 2, DelayedMessageCount: 1, L1Block: 10},
+		{MessageCount: 5, DelayedMessageCount: 2, L1Block: 12},
+		{MessageCount: 8, DelayedMessageCount: 3, L1Block: 15},
+	}
+	dbBatch := db.NewBatch()
+	var prevMessageCount arbutil.MessageIndex
+	for seqNum, meta := range metas {
+		metaBytes, err := rlp.EncodeToBytes(meta)
+		Require(t, err)
+		Require(t, dbBatch.Put(dbKey(sequencerBatchMetaPrefix, uint64(seqNum)), metaBytes))
+		Require(t, dbBatch.Put(dbKey(batchLedgerPrefix, uint64(seqNum)), []byte{1}))
+		Require(t, writeBlockIndex(dbBatch, uint64(seqNum), meta, prevMessageCount))
+		prevMessageCount = meta.MessageCount
+	}
+	for i := uint64(0); i < 8; i++ {
+		Require(t, dbBatch.Put(dbKey(messagePrefix, i), []byte{1}))
+	}
+	for i := uint64(0); i < 3; i++ {
+		Require(t, dbBatch.Put(dbKey(delayedMessagePrefix, i), []byte{1}))
+	}
+	Require(t, dbBatch.Write())
+
+	checkpoint := SnapshotL1Checkpoint{BatchCount: 2, MessageCount: 5, DelayedMessageCount: 2}
+	Require(t, truncateArbDb(db, checkpoint))
+
+	batchCount, err := tracker.GetBatchCount()
+	Require(t, err)
+	delayedCount, err := tracker.GetDelayedCount()
+	Require(t, err)
+	if batchCount != 2 || delayedCount != 2 {
+		Fail(t, "unexpected counts", batchCount, delayedCount)
+	}
+	if _, err := tracker.GetBatchMetadata(2); err == nil {
+		Fail(t, "batch beyond the checkpoint kept")
+	}
+	if _, found, err := tracker.FindBatchContainingMessage(6); err != nil || found {
+		Fail(t, "block index of batch beyond the checkpoint kept", err)
+	}
+	for _, key := range [][]byte{dbKey(messagePrefix, 5), dbKey(delayedMessagePrefix, 2), dbKey(batchLedgerPrefix, 2)} {
+		if has, err := db.Has(key); err != nil || has {
+			Fail(t, "entry beyond the checkpoint kept", key, err)
+		}
+	}
+	for _, key := range [][]byte{dbKey(messagePrefix, 4), dbKey(delayedMessagePrefix, 1), dbKey(batchLedgerPrefix, 1)} {
+		if has, err := db.Has(key); err != nil || !has {
+			Fail(t, "entry within the checkpoint deleted", key, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+)
+
+type SnapshotServerConfig struct {
+	Enable bool   `koanf:"enable"`
+	Addr   string `koanf:"addr"`
+}
+
+func SnapshotServerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultSnapshotServerConfig.Enable, "serve the snapshots published to the directory over HTTP, so other nodes can init from this one")
+	f.String(prefix+".addr", DefaultSnapshotServerConfig.Addr, "address to serve snapshots on")
+}
+
+var DefaultSnapshotServerConfig = SnapshotServerConfig{
+	Enable: false,
+	Addr:   ":8549",
+}
+
+// SnapshotServer serves a directory of published snapshots over HTTP, laid out as they're published: a
+// latest.json manifest for each kind, and the manifest and files of each snapshot under kind/id/.
+type SnapshotServer struct {
+	server *http.Server
+}
+
+func NewSnapshotServer(addr string, dir string) *SnapshotServer {
+	return &SnapshotServer{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           snapshotHandler{dir: dir},
+			ReadHeaderTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// Start listens on the server's address and serves in the background.
+func (s *SnapshotServer) Start() (net.Addr, error) {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warn("error serving snapshots", "err", err)
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+func (s *SnapshotServer) Stop(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+type snapshotHandler struct {
+	dir string
+}
+
+func (h snapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !h.published(name) {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := os.Open(filepath.Join(h.dir, filepath.FromSlash(name)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	// ServeContent handles range requests, so interrupted downloads can resume
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// published returns whether the named file is part of a published snapshot: a kind's latest.json, a snapshot's
+// manifest, or a file its manifest lists. Anything else in the directory, such as databases still being
+// exported or files still being written, isn't served.
+func (h snapshotHandler) published(name string) bool {
+	parts := strings.Split(name, "/")
+	switch len(parts) {
+	case 2:
+		return parts[1] == "latest.json"
+	case 3:
+		if parts[2] == "manifest.json" {
+			return true
+		}
+		manifestData, err := os.ReadFile(filepath.Join(h.dir, parts[0], parts[1], "manifest.json"))
+		if err != nil {
+			return false
+		}
+		var manifest SnapshotManifest
+		if err := json.Unmarshal(manifestData, &manifest); err != nil {
+			return false
+		}
+		for _, file := range manifest.Files {
+			if file.Name == parts[2] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fetchSnapshotObject(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %v: %v", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// DownloadSnapshot downloads the latest snapshot of the kind from a snapshot server to dir, checking each
+// file against the manifest. Unless expectedBlockHash is zero, the snapshot must be of the block with that
+// hash, so a peer can't serve one of its own choosing. It returns the manifest and the paths of the
+// downloaded files.
+func DownloadSnapshot(ctx context.Context, baseURL string, kind string, expectedBlockHash common.Hash, dir string) (*SnapshotManifest, []string, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	resp, err := fetchSnapshotObject(ctx, baseURL+"/"+kind+"/latest.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest SnapshotManifest
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding snapshot manifest: %w", err)
+	}
+	if manifest.Kind != kind {
+		return nil, nil, fmt.Errorf("asked for a %v snapshot but the manifest is of a %v one", kind, manifest.Kind)
+	}
+	if expectedBlockHash != (common.Hash{}) && manifest.Block.Hash != expectedBlockHash {
+		return nil, nil, fmt.Errorf("snapshot is of block %v with hash %v, not the expected %v", manifest.Block.Number, manifest.Block.Hash, expectedBlockHash)
+	}
+	id := snapshotIDForBlock(manifest.Block.Number)
+	var paths []string
+	for _, file := range manifest.Files {
+		if file.Name != filepath.Base(file.Name) {
+			return nil, nil, fmt.Errorf("snapshot file has invalid name %v", file.Name)
+		}
+		log.Info("downloading snapshot file", "name", file.Name, "size", file.Size, "block", manifest.Block.Number)
+		out := filepath.Join(dir, file.Name)
+		if err := downloadSnapshotFile(ctx, baseURL+"/"+path.Join(kind, id, file.Name), out, file); err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, out)
+	}
+	return &manifest, paths, nil
+}
+
+func downloadSnapshotFile(ctx context.Context, url string, out string, expected SnapshotFile) error {
+	resp, err := fetchSnapshotObject(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hasher), resp.Body)
+	if err != nil {
+		return fmt.Errorf("downloading %v: %w", expected.Name, err)
+	}
+	if uint64(size) != expected.Size {
+		return fmt.Errorf("snapshot file %v is %v bytes but the manifest says %v", expected.Name, size, expected.Size)
+	}
+	if hash := common.BytesToHash(hasher.Sum(nil)); hash != expected.SHA256 {
+		return fmt.Errorf("snapshot file %v has checksum %v but the manifest says %v", expected.Name, hash, expected.SHA256)
+	}
+	return file.Close()
+}
@@ -0,0 +1,99 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSnapshotServer(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := &directorySnapshotStore{dir: dir}
+	data := []byte("chain data")
+	manifest := SnapshotManifest{
+		Kind:  SnapshotKindPruned,
+		Block: SnapshotBlock{Number: 100, Hash: common.HexToHash("0x64")},
+		Files: []SnapshotFile{{Name: "l2chaindata.tar.gz", Size: uint64(len(data)), SHA256: common.Hash(sha256.Sum256(data))}},
+	}
+	manifestData, err := json.Marshal(manifest)
+	Require(t, err)
+	id := snapshotIDForBlock(100)
+	Require(t, store.Upload(ctx, "pruned/"+id+"/l2chaindata.tar.gz", bytes.NewReader(data)))
+	Require(t, store.Upload(ctx, "pruned/latest.json", bytes.NewReader(manifestData)))
+	Require(t, store.Upload(ctx, "pruned/"+id+"/manifest.json", bytes.NewReader(manifestData)))
+	Require(t, os.WriteFile(dir+"/pruned/"+id+"/partial.tar.gz.tmp", data, 0644))
+	Require(t, store.Upload(ctx, "pruned/"+id+"/unlisted.tar.gz", bytes.NewReader(data)))
+	Require(t, store.Upload(ctx, "work/l2chaindata/CURRENT", bytes.NewReader(data)))
+
+	server := NewSnapshotServer("127.0.0.1:0", dir)
+	addr, err := server.Start()
+	Require(t, err)
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		Require(t, server.Stop(stopCtx))
+	}()
+	url := "http://" + addr.String()
+
+	downloaded, paths, err := DownloadSnapshot(ctx, url+"/", SnapshotKindPruned, common.Hash{}, t.TempDir())
+	Require(t, err)
+	if downloaded.Block.Number != 100 || len(paths) != 1 {
+		Fail(t, "unexpected downloaded snapshot", downloaded, paths)
+	}
+	got, err := os.ReadFile(paths[0])
+	Require(t, err)
+	if !bytes.Equal(got, data) {
+		Fail(t, "downloaded file differs", string(got))
+	}
+
+	for _, name := range []string{"/pruned/" + id + "/manifest.json", "/pruned/" + id + "/l2chaindata.tar.gz"} {
+		resp, err := http.Get(url + name)
+		Require(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			Fail(t, "didn't serve", name, resp.Status)
+		}
+	}
+	unpublished := []string{
+		"/pruned/" + id + "/partial.tar.gz.tmp",
+		"/pruned/" + id + "/unlisted.tar.gz",
+		"/work/l2chaindata/CURRENT",
+		"/pruned/",
+		"/../" + dir,
+	}
+	for _, name := range unpublished {
+		resp, err := http.Get(url + name)
+		Require(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			Fail(t, "served", name, resp.Status)
+		}
+	}
+
+	// A snapshot of a block other than the pinned one is refused
+	if _, _, err := DownloadSnapshot(ctx, url, SnapshotKindPruned, common.HexToHash("0x01"), t.TempDir()); err == nil {
+		Fail(t, "downloaded a snapshot of a block other than the pinned one")
+	}
+	_, _, err = DownloadSnapshot(ctx, url, SnapshotKindPruned, manifest.Block.Hash, t.TempDir())
+	Require(t, err)
+
+	// A corrupted file fails its checksum
+	Require(t, store.Upload(ctx, "pruned/"+id+"/l2chaindata.tar.gz", bytes.NewReader([]byte("chain dat4"))))
+	if _, _, err := DownloadSnapshot(ctx, url, SnapshotKindPruned, common.Hash{}, t.TempDir()); err == nil {
+		Fail(t, "corrupted snapshot downloaded")
+	}
+	if _, _, err := DownloadSnapshot(ctx, url, SnapshotKindArchive, common.Hash{}, t.TempDir()); err == nil {
+		Fail(t, "downloaded a snapshot that doesn't exist")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	flag "github.com/spf13/pflag"
+)
+
+// SnapshotStore is where published snapshots are uploaded to. Names are slash separated paths.
+type SnapshotStore interface {
+	Upload(ctx context.Context, name string, data io.Reader) error
+	// List returns the names of every object under the prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+type SnapshotS3Config struct {
+	Bucket       string `koanf:"bucket"`
+	ObjectPrefix string `koanf:"object-prefix"`
+	Region       string `koanf:"region"`
+	AccessKey    string `koanf:"access-key"`
+	SecretKey    string `koanf:"secret-key"`
+}
+
+func SnapshotS3ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".bucket", DefaultSnapshotS3Config.Bucket, "S3 bucket to publish snapshots to")
+	f.String(prefix+".object-prefix", DefaultSnapshotS3Config.ObjectPrefix, "prefix to add to snapshot objects")
+	f.String(prefix+".region", DefaultSnapshotS3Config.Region, "S3 region")
+	f.String(prefix+".access-key", DefaultSnapshotS3Config.AccessKey, "S3 access key")
+	f.String(prefix+".secret-key", DefaultSnapshotS3Config.SecretKey, "S3 secret key")
+}
+
+var DefaultSnapshotS3Config = SnapshotS3Config{}
+
+type s3SnapshotStore struct {
+	client       *s3.Client
+	uploader     *manager.Uploader
+	bucket       string
+	objectPrefix string
+}
+
+func newS3SnapshotStore(config *SnapshotS3Config) *s3SnapshotStore {
+	client := s3.New(s3.Options{
+		Region: config.Region,
+		Credentials: aws.NewCredentialsCache(
+			credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, ""),
+		),
+	})
+	return &s3SnapshotStore{
+		client:       client,
+		uploader:     manager.NewUploader(client),
+		bucket:       config.Bucket,
+		objectPrefix: config.ObjectPrefix,
+	}
+}
+
+func (s *s3SnapshotStore) Upload(ctx context.Context, name string, data io.Reader) error {
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectPrefix + name),
+		Body:   data,
+	})
+	return err
+}
+
+func (s *s3SnapshotStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.objectPrefix + prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.ToString(object.Key), s.objectPrefix))
+		}
+	}
+	return names, nil
+}
+
+func (s *s3SnapshotStore) Delete(ctx context.Context, name string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectPrefix + name),
+	})
+	return err
+}
+
+// directorySnapshotStore publishes snapshots to a local directory, such as one served over HTTP.
+type directorySnapshotStore struct {
+	dir string
+}
+
+func (s *directorySnapshotStore) Upload(ctx context.Context, name string, data io.Reader) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so a partial upload is never published under the final name
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *directorySnapshotStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return names, err
+}
+
+func (s *directorySnapshotStore) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,225 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	flag "github.com/spf13/pflag"
+)
+
+// SoftConfirmationConfig has the sequencer sign, for transactions submitted through
+// arb_sendRawTransactionSoftConfirmed, where it sequenced them.
+type SoftConfirmationConfig struct {
+	Enable     bool   `koanf:"enable"`
+	SigningKey string `koanf:"signing-key"`
+}
+
+func SoftConfirmationConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultSoftConfirmationConfig.Enable, "serve signed soft confirmations of sequenced transactions")
+	f.String(prefix+".signing-key", DefaultSoftConfirmationConfig.SigningKey, "hex private key used to sign soft confirmations (defaults to the key of the batch poster's or validator's L1 wallet, unless it's held by an external signer)")
+}
+
+var DefaultSoftConfirmationConfig = SoftConfirmationConfig{
+	Enable:     false,
+	SigningKey: "",
+}
+
+// ErrSoftConfirmationBroken is the error of a soft confirmation the canonical chain contradicts.
+var ErrSoftConfirmationBroken = errors.New("soft confirmation broken")
+
+// Prefixes the signed data so a soft confirmation signature can't be passed off as any other
+var softConfirmationDomain = crypto.Keccak256([]byte("Nitro soft confirmation"))
+
+// SoftConfirmation is the sequencer's promise of where it sequenced a transaction.
+type SoftConfirmation struct {
+	ChainId     *hexutil.Big   `json:"chainId"`
+	TxHash      common.Hash    `json:"txHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Position    hexutil.Uint64 `json:"position"`
+	Timestamp   hexutil.Uint64 `json:"timestamp"`
+}
+
+// Hash is the keccak256 of the domain followed by the fields in order, with the chain ID as 32 bytes and the
+// block number, position, and timestamp as 8, all big endian, so contracts can verify signatures cheaply.
+func (c *SoftConfirmation) Hash() common.Hash {
+	var chainId common.Hash
+	if c.ChainId != nil {
+		chainId = common.BigToHash(c.ChainId.ToInt())
+	}
+	var numbers [24]byte
+	binary.BigEndian.PutUint64(numbers[0:8], uint64(c.BlockNumber))
+	binary.BigEndian.PutUint64(numbers[8:16], uint64(c.Position))
+	binary.BigEndian.PutUint64(numbers[16:24], uint64(c.Timestamp))
+	return crypto.Keccak256Hash(
+		softConfirmationDomain,
+		chainId[:],
+		c.TxHash[:],
+		numbers[0:8],
+		c.BlockHash[:],
+		numbers[8:16],
+		numbers[16:24],
+	)
+}
+
+// CheckBlock verifies the canonical block at the confirmation's block number against it.
+func (c *SoftConfirmation) CheckBlock(block *types.Block) error {
+	if block.NumberU64() != uint64(c.BlockNumber) {
+		return fmt.Errorf("checking soft confirmation for block %v against block %v", c.BlockNumber, block.NumberU64())
+	}
+	if block.Hash() != c.BlockHash {
+		return fmt.Errorf("%w: block %v has hash %v, not %v", ErrSoftConfirmationBroken, c.BlockNumber, block.Hash(), c.BlockHash)
+	}
+	if block.Time() != uint64(c.Timestamp) {
+		return fmt.Errorf("%w: block %v has timestamp %v, not %v", ErrSoftConfirmationBroken, c.BlockNumber, block.Time(), c.Timestamp)
+	}
+	txs := block.Transactions()
+	if uint64(c.Position) >= uint64(len(txs)) {
+		return fmt.Errorf("%w: block %v has %v transactions, so none at position %v", ErrSoftConfirmationBroken, c.BlockNumber, len(txs), c.Position)
+	}
+	if actual := txs[c.Position].Hash(); actual != c.TxHash {
+		return fmt.Errorf("%w: block %v has transaction %v at position %v, not %v", ErrSoftConfirmationBroken, c.BlockNumber, actual, c.Position, c.TxHash)
+	}
+	return nil
+}
+
+type SignedSoftConfirmation struct {
+	Confirmation SoftConfirmation `json:"confirmation"`
+	Signer       common.Address   `json:"signer"`
+	Signature    hexutil.Bytes    `json:"signature"`
+}
+
+// Verify checks the confirmation was signed by its signer, returning the signer.
+func (s *SignedSoftConfirmation) Verify() (common.Address, error) {
+	if len(s.Signature) == 0 {
+		return common.Address{}, errors.New("soft confirmation is unsigned")
+	}
+	pubkey, err := crypto.SigToPub(s.Confirmation.Hash().Bytes(), s.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := crypto.PubkeyToAddress(*pubkey)
+	if signer != s.Signer {
+		return common.Address{}, errors.New("soft confirmation signed by unexpected address")
+	}
+	return signer, nil
+}
+
+// ArbSoftConfirmationAPI publishes transactions through the sequencer, returning where they were sequenced.
+type ArbSoftConfirmationAPI struct {
+	publisher     TransactionPublisher
+	blockchain    *core.BlockChain
+	chainDb       ethdb.Database
+	signer        func([]byte) ([]byte, error)
+	signerAddress common.Address
+}
+
+func NewArbSoftConfirmationAPI(publisher TransactionPublisher, blockchain *core.BlockChain, chainDb ethdb.Database, config *SoftConfirmationConfig, fallbackSigner func([]byte) ([]byte, error)) (*ArbSoftConfirmationAPI, error) {
+	signer, signerAddress, err := loadHashSigner(config.SigningKey, fallbackSigner)
+	if err != nil {
+		return nil, err
+	}
+	if signer == nil {
+		return nil, errors.New("soft confirmations enabled without a signing key or L1 wallet to sign them")
+	}
+	return &ArbSoftConfirmationAPI{
+		publisher:     publisher,
+		blockchain:    blockchain,
+		chainDb:       chainDb,
+		signer:        signer,
+		signerAddress: signerAddress,
+	}, nil
+}
+
+// SendRawTransactionSoftConfirmed sequences the transaction, returning the sequencer's signed confirmation
+// of the block and position it was sequenced at.
+func (a *ArbSoftConfirmationAPI) SendRawTransactionSoftConfirmed(ctx context.Context, input hexutil.Bytes) (*SignedSoftConfirmation, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return nil, err
+	}
+	if err := a.publisher.PublishTransaction(ctx, tx, nil); err != nil {
+		return nil, err
+	}
+	_, blockHash, blockNumber, position := rawdb.ReadTransaction(a.chainDb, tx.Hash())
+	if blockHash == (common.Hash{}) {
+		return nil, fmt.Errorf("sequenced transaction %v not found", tx.Hash())
+	}
+	header := a.blockchain.GetHeader(blockHash, blockNumber)
+	if header == nil {
+		return nil, fmt.Errorf("block %v of sequenced transaction %v not found", blockHash, tx.Hash())
+	}
+	signed := &SignedSoftConfirmation{
+		Confirmation: SoftConfirmation{
+			ChainId:     (*hexutil.Big)(a.blockchain.Config().ChainID),
+			TxHash:      tx.Hash(),
+			BlockNumber: hexutil.Uint64(blockNumber),
+			BlockHash:   blockHash,
+			Position:    hexutil.Uint64(position),
+			Timestamp:   hexutil.Uint64(header.Time),
+		},
+		Signer: a.signerAddress,
+	}
+	sig, err := a.signer(signed.Confirmation.Hash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	signed.Signature = sig
+	return signed, nil
+}
+
+// SoftConfirmationSigner returns the address soft confirmations are signed by.
+func (a *ArbSoftConfirmationAPI) SoftConfirmationSigner() common.Address {
+	return a.signerAddress
+}
+
+type SoftConfirmationVerification struct {
+	Signer common.Address `json:"signer"`
+	Status string         `json:"status"` // "pending" until the block is known, then "honored" or "broken"
+	Reason string         `json:"reason,omitempty"`
+}
+
+// ArbSoftConfirmationVerifierAPI checks soft confirmations against this node's view of the canonical chain.
+type ArbSoftConfirmationVerifierAPI struct {
+	blockchain *core.BlockChain
+}
+
+func (a *ArbSoftConfirmationVerifierAPI) VerifySoftConfirmation(ctx context.Context, signed SignedSoftConfirmation) (*SoftConfirmationVerification, error) {
+	signer, err := signed.Verify()
+	if err != nil {
+		return nil, err
+	}
+	chainId := a.blockchain.Config().ChainID
+	if signed.Confirmation.ChainId == nil || signed.Confirmation.ChainId.ToInt().Cmp(chainId) != 0 {
+		return nil, fmt.Errorf("soft confirmation is for chain %v, not %v", signed.Confirmation.ChainId, chainId)
+	}
+	verification := &SoftConfirmationVerification{Signer: signer}
+	block := a.blockchain.GetBlockByNumber(uint64(signed.Confirmation.BlockNumber))
+	if block == nil {
+		verification.Status = "pending"
+		return verification, nil
+	}
+	err = signed.Confirmation.CheckBlock(block)
+	if errors.Is(err, ErrSoftConfirmationBroken) {
+		verification.Status = "broken"
+		verification.Reason = err.Error()
+		return verification, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	verification.Status = "honored"
+	return verification, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestSoftConfirmation(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signer, signerAddress, err := loadHashSigner(common.Bytes2Hex(crypto.FromECDSA(privateKey)), nil)
+	Require(t, err)
+	if signerAddress != crypto.PubkeyToAddress(privateKey.PublicKey) {
+		Fail(t, "unexpected signer address", signerAddress)
+	}
+
+	txs := []*types.Transaction{
+		types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil),
+		types.NewTransaction(1, common.Address{2}, big.NewInt(1), 21000, big.NewInt(1), nil),
+	}
+	header := &types.Header{Number: big.NewInt(100), Time: 1234, Difficulty: big.NewInt(1)}
+	block := types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+
+	signed := SignedSoftConfirmation{
+		Confirmation: SoftConfirmation{
+			ChainId:     (*hexutil.Big)(big.NewInt(42161)),
+			TxHash:      txs[1].Hash(),
+			BlockNumber: 100,
+			BlockHash:   block.Hash(),
+			Position:    1,
+			Timestamp:   1234,
+		},
+		Signer: signerAddress,
+	}
+	signed.Signature, err = signer(signed.Confirmation.Hash().Bytes())
+	Require(t, err)
+	recovered, err := signed.Verify()
+	Require(t, err)
+	if recovered != signerAddress {
+		Fail(t, "recovered unexpected signer", recovered)
+	}
+	Require(t, signed.Confirmation.CheckBlock(block))
+
+	tampered := signed
+	tampered.Confirmation.Position = 0
+	if _, err := tampered.Verify(); err == nil {
+		Fail(t, "tampered soft confirmation verified")
+	}
+	if err := tampered.Confirmation.CheckBlock(block); !errors.Is(err, ErrSoftConfirmationBroken) {
+		Fail(t, "soft confirmation of the wrong position not broken", err)
+	}
+
+	reordered := types.NewBlock(header, []*types.Transaction{txs[1], txs[0]}, nil, nil, trie.NewStackTrie(nil))
+	if err := signed.Confirmation.CheckBlock(reordered); !errors.Is(err, ErrSoftConfirmationBroken) {
+		Fail(t, "soft confirmation of a reordered block not broken", err)
+	}
+}
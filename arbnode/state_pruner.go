@@ -0,0 +1,537 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+var (
+	statePrunerMarkedCounter  = metrics.NewRegisteredCounter("arb/statepruner/marked", nil)
+	statePrunerDeletedCounter = metrics.NewRegisteredCounter("arb/statepruner/deleted", nil)
+	statePrunerBytesCounter   = metrics.NewRegisteredCounter("arb/statepruner/bytes", nil)
+)
+
+type StatePrunerConfig struct {
+	Enable             bool          `koanf:"enable"`
+	RetainBlocks       uint64        `koanf:"retain-blocks"`
+	RetainAge          time.Duration `koanf:"retain-age"`
+	CheckpointInterval uint64        `koanf:"checkpoint-interval"`
+	Interval           time.Duration `koanf:"interval"`
+	MaxNodesPerSecond  uint64        `koanf:"max-nodes-per-second"`
+	SweepBatch         int           `koanf:"sweep-batch"`
+}
+
+var DefaultStatePrunerConfig = StatePrunerConfig{
+	Enable:             false,
+	RetainBlocks:       1_000_000,
+	RetainAge:          0,
+	CheckpointInterval: 1_000_000,
+	Interval:           24 * time.Hour,
+	MaxNodesPerSecond:  100_000,
+	SweepBatch:         10_000,
+}
+
+func StatePrunerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultStatePrunerConfig.Enable, "periodically delete the state of archive blocks beyond the retention, while the node runs")
+	f.Uint64(prefix+".retain-blocks", DefaultStatePrunerConfig.RetainBlocks, "number of the latest blocks to keep the state of")
+	f.Duration(prefix+".retain-age", DefaultStatePrunerConfig.RetainAge, "also delete the state of blocks older than this, even within retain-blocks (0 to only retain by count)")
+	f.Uint64(prefix+".checkpoint-interval", DefaultStatePrunerConfig.CheckpointInterval, "keep the state of every block whose number is a multiple of this forever (0 for no checkpoints)")
+	f.Duration(prefix+".interval", DefaultStatePrunerConfig.Interval, "how long to wait after a pruning run before starting the next")
+	f.Uint64(prefix+".max-nodes-per-second", DefaultStatePrunerConfig.MaxNodesPerSecond, "limit on the trie nodes marked and database entries scanned per second, so pruning doesn't starve live traffic (0 for no limit)")
+	f.Int(prefix+".sweep-batch", DefaultStatePrunerConfig.SweepBatch, "number of database entries to scan between pauses of block creation, during which the new blocks' state is kept and the batch deleted")
+}
+
+const (
+	StatePruningIdle     = "idle"
+	StatePruningMarking  = "marking"
+	StatePruningSweeping = "sweeping"
+)
+
+// StatePruningProgress reports what the state pruner is doing, and when it estimates it'll be done.
+type StatePruningProgress struct {
+	Phase        string  `json:"phase"`
+	Runs         uint64  `json:"runs"` // started since the node started
+	Head         uint64  `json:"head"`
+	RetainedFrom uint64  `json:"retainedFrom"`
+	Checkpoints  int     `json:"checkpoints"`
+	NodesMarked  uint64  `json:"nodesMarked"`
+	KeysScanned  uint64  `json:"keysScanned"`
+	NodesDeleted uint64  `json:"nodesDeleted"`
+	BytesDeleted uint64  `json:"bytesDeleted"`
+	Progress     float64 `json:"progress"`             // of the current phase, from 0 to 1
+	EtaSeconds   *uint64 `json:"etaSeconds,omitempty"` // until the current phase finishes, if it can be estimated
+	LastFinished uint64  `json:"lastFinished"`         // unix time the last run finished, or 0
+	LastError    string  `json:"lastError,omitempty"`  // of the last run, if it failed
+}
+
+// BlockCreationPauser stops blocks being created, and so state being written, while the pruner deletes a batch.
+type BlockCreationPauser interface {
+	PauseBlockCreation()
+	ResumeBlockCreation()
+}
+
+// StatePruner deletes the state tries of archive blocks outside the retention while the node runs. Each run marks
+// the trie nodes reachable from the state of the retained blocks and checkpoints in a temporary database, then
+// scans the chain database deleting the trie nodes that aren't marked. Trie nodes are shared between blocks and
+// keyed by their hash, so a node written by a block created since marking started may already be in the
+// database; before deleting each batch, block creation is paused and the state of the new blocks is marked too.
+type StatePruner struct {
+	stopwaiter.StopWaiter
+
+	config   *StatePrunerConfig
+	chainDb  ethdb.Database
+	bc       *core.BlockChain
+	pauser   BlockCreationPauser
+	marksDir string
+	minBlock func() uint64 // the lowest block whose state something else still needs, or nil
+
+	progressMutex sync.Mutex
+	progress      StatePruningProgress
+	lastMarked    uint64 // nodes marked by the last run, to estimate the next's progress
+
+	// Only accessed by the pruning thread
+	marks        ethdb.Database
+	markedBlocks map[common.Hash]bool
+	throttle     *pruningThrottle
+}
+
+func NewStatePruner(config *StatePrunerConfig, chainDb ethdb.Database, bc *core.BlockChain, pauser BlockCreationPauser, marksDir string) (*StatePruner, error) {
+	if config.RetainBlocks == 0 {
+		return nil, errors.New("state pruner must retain at least one block")
+	}
+	if config.SweepBatch <= 0 {
+		return nil, errors.New("state pruner sweep batch must be positive")
+	}
+	return &StatePruner{
+		config:   config,
+		chainDb:  chainDb,
+		bc:       bc,
+		pauser:   pauser,
+		marksDir: marksDir,
+		progress: StatePruningProgress{Phase: StatePruningIdle},
+	}, nil
+}
+
+// SetMinBlock keeps the state of blocks from the one returned on, e.g. those the block validator has yet to
+// validate. Must be called before Start.
+func (p *StatePruner) SetMinBlock(minBlock func() uint64) {
+	p.minBlock = minBlock
+}
+
+func (p *StatePruner) Progress() StatePruningProgress {
+	p.progressMutex.Lock()
+	defer p.progressMutex.Unlock()
+	return p.progress
+}
+
+func (p *StatePruner) updateProgress(update func(progress *StatePruningProgress)) {
+	p.progressMutex.Lock()
+	defer p.progressMutex.Unlock()
+	update(&p.progress)
+}
+
+// retainedBlocks returns the lowest block whose state is retained, and the checkpoints below it.
+func (p *StatePruner) retainedBlocks(head *types.Block) (uint64, []uint64) {
+	genesis := p.bc.Config().ArbitrumChainParams.GenesisBlockNum
+	lowest := genesis
+	if head.NumberU64()+1 > genesis+p.config.RetainBlocks {
+		lowest = head.NumberU64() + 1 - p.config.RetainBlocks
+	}
+	if p.config.RetainAge > 0 {
+		cutoff := uint64(time.Now().Add(-p.config.RetainAge).Unix())
+		for lowest < head.NumberU64() {
+			header := p.bc.GetHeaderByNumber(lowest)
+			if header == nil || header.Time >= cutoff {
+				break
+			}
+			lowest++
+		}
+	}
+	if p.minBlock != nil {
+		if min := p.minBlock(); min < lowest && min >= genesis {
+			lowest = min
+		}
+	}
+	var checkpoints []uint64
+	if interval := p.config.CheckpointInterval; interval > 0 {
+		for checkpoint := (genesis + interval - 1) / interval * interval; checkpoint < lowest; checkpoint += interval {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+	}
+	return lowest, checkpoints
+}
+
+// markTrie marks the nodes of the trie not already marked, along with their storage tries and code if it's the
+// account trie. A marked node's subtree is always fully marked, so it isn't descended into again.
+func (p *StatePruner) markTrie(ctx context.Context, tr state.Trie, stateDb state.Database, accounts bool) error {
+	batch := p.marks.NewBatch()
+	it := tr.NodeIterator(nil)
+	descend := true
+	for it.Next(descend) {
+		descend = true
+		if err := p.throttle.wait(ctx); err != nil {
+			return err
+		}
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			marked, err := p.marks.Has(hash[:])
+			if err != nil {
+				return err
+			}
+			if marked {
+				descend = false
+				continue
+			}
+			if err := batch.Put(hash[:], nil); err != nil {
+				return err
+			}
+			p.updateProgress(func(progress *StatePruningProgress) { progress.NodesMarked++ })
+			statePrunerMarkedCounter.Inc(1)
+		}
+		if accounts && it.Leaf() {
+			var account types.StateAccount
+			if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+				return err
+			}
+			// Code is keyed by a prefixed hash, but databases from before that have it by its bare hash
+			if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCodeHash {
+				if err := batch.Put(codeHash[:], nil); err != nil {
+					return err
+				}
+			}
+			if account.Root != types.EmptyRootHash {
+				storageTrie, err := stateDb.OpenStorageTrie(common.BytesToHash(it.LeafKey()), account.Root)
+				if err != nil {
+					return err
+				}
+				// Storage marks must be visible before the account trie continues, so write them out first
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+				if err := p.markTrie(ctx, storageTrie, stateDb, false); err != nil {
+					return err
+				}
+			}
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// markBlock marks the state of the block, if it's still in the database.
+func (p *StatePruner) markBlock(ctx context.Context, header *types.Header) error {
+	if p.markedBlocks[header.Hash()] {
+		return nil
+	}
+	stateDb := p.bc.StateCache()
+	tr, err := stateDb.OpenTrie(header.Root)
+	if err != nil {
+		// Blocks from before the node kept archive state may not have any
+		log.Debug("state pruner skipping block without state", "block", header.Number, "err", err)
+		p.markedBlocks[header.Hash()] = true
+		return nil
+	}
+	if err := p.markTrie(ctx, tr, stateDb, true); err != nil {
+		return err
+	}
+	p.markedBlocks[header.Hash()] = true
+	return nil
+}
+
+// markNewBlocks marks the state of the blocks created since marking started, walking back from the head until
+// a marked block, so blocks reorged in are covered too. Block creation must be paused.
+func (p *StatePruner) markNewBlocks(ctx context.Context, lowest uint64) error {
+	for header := p.bc.CurrentHeader(); header != nil && header.Number.Uint64() >= lowest; header = p.bc.GetHeader(header.ParentHash, header.Number.Uint64()-1) {
+		if p.markedBlocks[header.Hash()] {
+			return nil
+		}
+		if err := p.markBlock(ctx, header); err != nil {
+			return err
+		}
+		if header.Number.Uint64() == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// sweepPosition estimates how far through the database the sweep is from the key it's at, trie nodes being
+// keyed by uniformly distributed hashes.
+func sweepPosition(key []byte) float64 {
+	var prefix [8]byte
+	copy(prefix[:], key)
+	return float64(binary.BigEndian.Uint64(prefix[:])) / math.MaxUint64
+}
+
+// deleteUnmarked pauses block creation, marks the state of any new blocks, and deletes the candidate trie nodes
+// that still aren't marked.
+func (p *StatePruner) deleteUnmarked(ctx context.Context, lowest uint64, candidates [][]byte) error {
+	p.pauser.PauseBlockCreation()
+	defer p.pauser.ResumeBlockCreation()
+	// Don't hold up block creation any longer than needed to keep up with it
+	throttle := p.throttle
+	p.throttle = nil
+	defer func() { p.throttle = throttle }()
+	if err := p.markNewBlocks(ctx, lowest); err != nil {
+		return err
+	}
+	batch := p.chainDb.NewBatch()
+	var deleted, bytes uint64
+	for _, key := range candidates {
+		marked, err := p.marks.Has(key)
+		if err != nil {
+			return err
+		}
+		if marked {
+			continue
+		}
+		value, err := p.chainDb.Get(key)
+		if err != nil {
+			continue
+		}
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		deleted++
+		bytes += uint64(len(key) + len(value))
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	statePrunerDeletedCounter.Inc(int64(deleted))
+	statePrunerBytesCounter.Inc(int64(bytes))
+	p.updateProgress(func(progress *StatePruningProgress) {
+		progress.NodesDeleted += deleted
+		progress.BytesDeleted += bytes
+	})
+	return nil
+}
+
+func (p *StatePruner) sweep(ctx context.Context, lowest uint64) error {
+	it := p.chainDb.NewIterator(nil, nil)
+	defer it.Release()
+	started := time.Now()
+	var candidates [][]byte
+	scanned := 0
+	for it.Next() {
+		if err := p.throttle.wait(ctx); err != nil {
+			return err
+		}
+		key := it.Key()
+		scanned++
+		if len(key) == common.HashLength {
+			marked, err := p.marks.Has(key)
+			if err != nil {
+				return err
+			}
+			if !marked {
+				candidates = append(candidates, common.CopyBytes(key))
+			}
+		}
+		if scanned < p.config.SweepBatch {
+			continue
+		}
+		if err := p.deleteUnmarked(ctx, lowest, candidates); err != nil {
+			return err
+		}
+		position := sweepPosition(key)
+		p.updateProgress(func(progress *StatePruningProgress) {
+			progress.KeysScanned += uint64(scanned)
+			progress.Progress = position
+			if position > 0 {
+				eta := uint64(time.Since(started).Seconds() / position * (1 - position))
+				progress.EtaSeconds = &eta
+			}
+		})
+		candidates = candidates[:0]
+		scanned = 0
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := p.deleteUnmarked(ctx, lowest, candidates); err != nil {
+		return err
+	}
+	p.updateProgress(func(progress *StatePruningProgress) { progress.KeysScanned += uint64(scanned) })
+	return nil
+}
+
+func (p *StatePruner) prune(ctx context.Context) error {
+	if err := os.RemoveAll(p.marksDir); err != nil {
+		return err
+	}
+	marks, err := rawdb.NewLevelDBDatabase(p.marksDir, 16, 16, "", false)
+	if err != nil {
+		return err
+	}
+	p.marks = marks
+	p.markedBlocks = make(map[common.Hash]bool)
+	p.throttle = newPruningThrottle(p.config.MaxNodesPerSecond)
+	defer func() {
+		p.marks.Close()
+		p.marks = nil
+		p.markedBlocks = nil
+		if err := os.RemoveAll(p.marksDir); err != nil {
+			log.Warn("failed to remove state pruner marks", "dir", p.marksDir, "err", err)
+		}
+	}()
+
+	head := p.bc.CurrentBlock()
+	lowest, checkpoints := p.retainedBlocks(head)
+	lastMarked := p.lastMarked
+	p.updateProgress(func(progress *StatePruningProgress) {
+		*progress = StatePruningProgress{
+			Phase:        StatePruningMarking,
+			Runs:         progress.Runs + 1,
+			Head:         head.NumberU64(),
+			RetainedFrom: lowest,
+			Checkpoints:  len(checkpoints),
+			LastFinished: progress.LastFinished,
+		}
+	})
+	log.Info("state pruner marking retained state", "head", head.NumberU64(), "retainedFrom", lowest, "checkpoints", len(checkpoints))
+	started := time.Now()
+	reportMarking := func() {
+		p.updateProgress(func(progress *StatePruningProgress) {
+			if lastMarked == 0 {
+				return
+			}
+			// The last run marked about as many nodes as this one will
+			progress.Progress = math.Min(float64(progress.NodesMarked)/float64(lastMarked), 1)
+			if progress.Progress > 0 {
+				eta := uint64(time.Since(started).Seconds() / progress.Progress * (1 - progress.Progress))
+				progress.EtaSeconds = &eta
+			}
+		})
+	}
+	for _, checkpoint := range checkpoints {
+		if header := p.bc.GetHeaderByNumber(checkpoint); header != nil {
+			if err := p.markBlock(ctx, header); err != nil {
+				return err
+			}
+		}
+		reportMarking()
+	}
+	for number := head.NumberU64(); number >= lowest; number-- {
+		header := p.bc.GetHeaderByNumber(number)
+		if header == nil {
+			return fmt.Errorf("missing retained block %v", number)
+		}
+		if err := p.markBlock(ctx, header); err != nil {
+			return err
+		}
+		reportMarking()
+		if number == 0 {
+			break
+		}
+	}
+
+	marked := p.Progress().NodesMarked
+	p.updateProgress(func(progress *StatePruningProgress) {
+		progress.Phase = StatePruningSweeping
+		progress.Progress = 0
+		progress.EtaSeconds = nil
+	})
+	log.Info("state pruner deleting unretained state", "marked", marked)
+	if err := p.sweep(ctx, lowest); err != nil {
+		return err
+	}
+	p.lastMarked = p.Progress().NodesMarked
+	return nil
+}
+
+func (p *StatePruner) run(ctx context.Context) time.Duration {
+	err := p.prune(ctx)
+	if ctx.Err() != nil {
+		return 0
+	}
+	p.updateProgress(func(progress *StatePruningProgress) {
+		progress.Phase = StatePruningIdle
+		progress.EtaSeconds = nil
+		progress.LastFinished = uint64(time.Now().Unix())
+		progress.LastError = ""
+		if err != nil {
+			progress.LastError = err.Error()
+		}
+	})
+	progress := p.Progress()
+	if err != nil {
+		log.Error("state pruning failed", "err", err)
+	} else {
+		log.Info("state pruning finished", "deleted", progress.NodesDeleted, "bytes", progress.BytesDeleted)
+	}
+	return p.config.Interval
+}
+
+func (p *StatePruner) Start(ctxIn context.Context) {
+	p.StopWaiter.Start(ctxIn)
+	p.CallIteratively(p.run)
+}
+
+// pruningThrottle limits the rate of pruning work, sleeping every so often to keep to it.
+type pruningThrottle struct {
+	rate    uint64
+	started time.Time
+	count   uint64
+}
+
+func newPruningThrottle(rate uint64) *pruningThrottle {
+	return &pruningThrottle{rate: rate, started: time.Now()}
+}
+
+// wait returns once the work so far is within the rate. A nil throttle doesn't limit the rate.
+func (t *pruningThrottle) wait(ctx context.Context) error {
+	if t == nil {
+		return ctx.Err()
+	}
+	t.count++
+	if t.rate == 0 || t.count%1024 != 0 {
+		return ctx.Err()
+	}
+	due := t.started.Add(time.Duration(float64(t.count) / float64(t.rate) * float64(time.Second)))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
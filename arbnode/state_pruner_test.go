@@ -0,0 +1,102 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func commitTestState(t *testing.T, stateDb *state.StateDB) common.Hash {
+	root, err := stateDb.Commit(true)
+	Require(t, err)
+	Require(t, stateDb.Database().TrieDB().Commit(root, false, nil))
+	return root
+}
+
+func markTestState(t *testing.T, db ethdb.Database, root common.Hash) *StatePruner {
+	pruner := &StatePruner{marks: rawdb.NewMemoryDatabase()}
+	stateDb := state.NewDatabase(db)
+	tr, err := stateDb.OpenTrie(root)
+	Require(t, err)
+	Require(t, pruner.markTrie(context.Background(), tr, stateDb, true))
+	return pruner
+}
+
+func TestStatePrunerMarking(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	stateDb, err := state.New(common.Hash{}, state.NewDatabase(db), nil)
+	Require(t, err)
+	contract := common.HexToAddress("0xc0de")
+	stateDb.SetCode(contract, []byte{0x60, 0x00, 0x60, 0x00, 0xf3})
+	for i := int64(1); i <= 20; i++ {
+		stateDb.SetBalance(common.BigToAddress(big.NewInt(i)), big.NewInt(i))
+		stateDb.SetState(contract, common.BigToHash(big.NewInt(i)), common.BigToHash(big.NewInt(i)))
+	}
+	oldRoot := commitTestState(t, stateDb)
+	stateDb.SetBalance(common.BigToAddress(big.NewInt(1)), big.NewInt(100))
+	stateDb.SetState(contract, common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(100)))
+	newRoot := commitTestState(t, stateDb)
+
+	pruner := markTestState(t, db, newRoot)
+	it := db.NewIterator(nil, nil)
+	var unmarked [][]byte
+	for it.Next() {
+		if len(it.Key()) != common.HashLength {
+			continue
+		}
+		marked, err := pruner.marks.Has(it.Key())
+		Require(t, err)
+		if !marked {
+			unmarked = append(unmarked, common.CopyBytes(it.Key()))
+		}
+	}
+	it.Release()
+	if len(unmarked) == 0 {
+		Fail(t, "no nodes only the old state has")
+	}
+	for _, key := range unmarked {
+		Require(t, db.Delete(key))
+	}
+
+	// Marking again fails if any of the new state's nodes were deleted
+	markTestState(t, db, newRoot)
+	if _, err := state.NewDatabase(db).OpenTrie(oldRoot); err == nil {
+		Fail(t, "old state still present")
+	}
+}
+
+func TestStatePrunerThrottle(t *testing.T) {
+	throttle := newPruningThrottle(1024 * 20)
+	start := time.Now()
+	for i := 0; i < 2048; i++ {
+		Require(t, throttle.wait(context.Background()))
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		Fail(t, "throttle didn't limit the rate", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var unlimited *pruningThrottle
+	if unlimited.wait(ctx) == nil {
+		Fail(t, "throttle ignored a cancelled context")
+	}
+}
+
+func TestStatePrunerSweepPosition(t *testing.T) {
+	if position := sweepPosition(common.HexToHash("0x80").Bytes()); position != 0 {
+		Fail(t, "unexpected position of low key", position)
+	}
+	if position := sweepPosition(common.HexToHash("0x8000000000000000000000000000000000000000000000000000000000000000").Bytes()); position != 0.5 {
+		Fail(t, "unexpected position of middle key", position)
+	}
+}
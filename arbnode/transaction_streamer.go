@@ -20,13 +20,16 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/broadcaster"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 	"github.com/offchainlabs/nitro/validator"
+	flag "github.com/spf13/pflag"
 )
 
 // Produces blocks from a node's L1 messages, storing the results in the blockchain and recording their positions
@@ -34,8 +37,9 @@ import (
 type TransactionStreamer struct {
 	stopwaiter.StopWaiter
 
-	db ethdb.Database
-	bc *core.BlockChain
+	db     ethdb.Database
+	bc     *core.BlockChain
+	config *TransactionStreamerConfig
 
 	insertionMutex     sync.Mutex // cannot be acquired while reorgMutex or createBlocksMutex is held
 	createBlocksMutex  sync.Mutex // cannot be acquired while reorgMutex is held
@@ -43,8 +47,20 @@ type TransactionStreamer struct {
 	reorgPending       uint32 // atomic, indicates whether the reorgMutex is attempting to be acquired
 	newMessageNotifier chan struct{}
 
+	// Messages from this position on lost the L1 batches which included them, and are kept until they're reread,
+	// or 0 if none did. Protected by the insertion mutex.
+	uncoveredMessagesPos arbutil.MessageIndex
+
+	// Sequenced messages not yet written to the database, see WriteBatchingConfig
+	pendingWritesMutex sync.RWMutex
+	pendingWritesPos   arbutil.MessageIndex
+	pendingWrites      []pendingWrite
+	pendingWritesSize  int
+	pendingWritesAcks  []func(error)
+
 	broadcasterQueuedMessages    []arbstate.MessageWithMetadata
 	broadcasterQueuedMessagesPos uint64
+	broadcastMessagesReceived    uint32 // atomic, set once the feed has delivered messages since startup
 
 	latestBlockAndMessageMutex sync.Mutex
 	latestBlock                *types.Block
@@ -55,12 +71,16 @@ type TransactionStreamer struct {
 	broadcastServer *broadcaster.Broadcaster
 	validator       *validator.BlockValidator
 	inboxReader     *InboxReader
+	exec            ExecutionClient // nil when messages are executed on the local blockchain
+	// set when messages are only stored, as in light mode
+	executionDisabled bool
 }
 
-func NewTransactionStreamer(db ethdb.Database, bc *core.BlockChain, broadcastServer *broadcaster.Broadcaster) (*TransactionStreamer, error) {
+func NewTransactionStreamer(db ethdb.Database, bc *core.BlockChain, broadcastServer *broadcaster.Broadcaster, config *TransactionStreamerConfig) (*TransactionStreamer, error) {
 	inbox := &TransactionStreamer{
 		db:                 db,
 		bc:                 bc,
+		config:             config,
 		newMessageNotifier: make(chan struct{}, 1),
 		newBlockNotifier:   make(chan struct{}, 1),
 		broadcastServer:    broadcastServer,
@@ -107,6 +127,39 @@ func (s *TransactionStreamer) SetInboxReader(inboxReader *InboxReader) {
 	s.inboxReader = inboxReader
 }
 
+// SetRemoteExecution executes messages on a separate execution node rather than the local blockchain.
+func (s *TransactionStreamer) SetRemoteExecution(exec ExecutionClient) {
+	if s.Started() {
+		panic("trying to set remote execution after start")
+	}
+	if s.exec != nil {
+		panic("trying to set remote execution when already set")
+	}
+	s.exec = exec
+}
+
+// DisableExecution stores messages without executing them, for nodes which only check the chain against L1 and
+// trusted nodes.
+func (s *TransactionStreamer) DisableExecution() {
+	if s.Started() {
+		panic("trying to disable execution after start")
+	}
+	if s.exec != nil {
+		panic("trying to disable execution with remote execution set")
+	}
+	s.executionDisabled = true
+}
+
+// PauseBlockCreation waits for any block being created to be written, and stops more being created until
+// ResumeBlockCreation is called.
+func (s *TransactionStreamer) PauseBlockCreation() {
+	s.createBlocksMutex.Lock()
+}
+
+func (s *TransactionStreamer) ResumeBlockCreation() {
+	s.createBlocksMutex.Unlock()
+}
+
 func (s *TransactionStreamer) cleanupInconsistentState() error {
 	// If it doesn't exist yet, set the message count to 0
 	hasMessageCount, err := s.db.Has(messageCountKey)
@@ -124,7 +177,7 @@ func (s *TransactionStreamer) cleanupInconsistentState() error {
 		}
 	}
 	// TODO remove trailing messageCountToMessage and messageCountToBlockPrefix entries
-	return nil
+	return s.rewindUnwrittenBlocks()
 }
 
 func (s *TransactionStreamer) ReorgTo(count arbutil.MessageIndex) error {
@@ -141,6 +194,51 @@ func (s *TransactionStreamer) ReorgToAndEndBatch(batch ethdb.Batch, count arbuti
 	return batch.Write()
 }
 
+var reorgAvoidedReexecutionCounter = metrics.NewRegisteredCounter("arb/streamer/reorg/reexecution_avoided", nil)
+
+type TransactionStreamerConfig struct {
+	// Only covers L1 reorgs which remove sequencer batches. Reorgs of the delayed inbox, and those the sequencer
+	// or feed make, always reorg the L2 chain. While batches are waiting to be reread, their blocks stay in the
+	// chain and are served over RPC, although no batch on L1 includes them any more, and they're reorged out if
+	// the batches come back different.
+	ReexecutionFreeReorgs bool                `koanf:"reexecution-free-reorgs"`
+	SyncedMessageGap      uint64              `koanf:"synced-message-gap"`
+	WriteBatching         WriteBatchingConfig `koanf:"write-batching"`
+}
+
+func TransactionStreamerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".reexecution-free-reorgs", DefaultTransactionStreamerConfig.ReexecutionFreeReorgs, "on an L1 reorg of sequencer batches, keep their messages and blocks, which RPC keeps serving, and only reorg the L2 chain if they're reread with different contents")
+	f.Uint64(prefix+".synced-message-gap", DefaultTransactionStreamerConfig.SyncedMessageGap, "how many messages may be waiting for blocks while the node still reports itself synced")
+	WriteBatchingConfigAddOptions(prefix+".write-batching", f)
+}
+
+var DefaultTransactionStreamerConfig = TransactionStreamerConfig{
+	ReexecutionFreeReorgs: false,
+	SyncedMessageGap:      20,
+	WriteBatching:         DefaultWriteBatchingConfig,
+}
+
+// UncoverMessagesAndEndBatch is called when an L1 reorg removes the batches which included the messages from
+// count on. If enabled, it keeps the messages and their blocks instead of reorging them out, as L1 reorgs usually
+// reinclude the same batches in different L1 blocks. Once the batches are reread, messages with identical contents
+// are kept as they are, and the chain is only reorged from the first message that changed.
+func (s *TransactionStreamer) UncoverMessagesAndEndBatch(batch ethdb.Batch, count arbutil.MessageIndex) error {
+	if !s.config.ReexecutionFreeReorgs {
+		return s.ReorgToAndEndBatch(batch, count)
+	}
+	s.insertionMutex.Lock()
+	defer s.insertionMutex.Unlock()
+	currentCount, err := s.GetMessageCount()
+	if err != nil {
+		return err
+	}
+	if count < currentCount && (s.uncoveredMessagesPos == 0 || count < s.uncoveredMessagesPos) {
+		log.Info("L1 reorg uncovered messages, keeping them until their batches are reread", "from", count, "messageCount", currentCount)
+		s.uncoveredMessagesPos = count
+	}
+	return batch.Write()
+}
+
 func deleteStartingAt(db ethdb.Database, batch ethdb.Batch, prefix []byte, minKey []byte) error {
 	iter := db.NewIterator(prefix, minKey)
 	defer iter.Release()
@@ -157,17 +255,27 @@ func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.M
 	if count == 0 {
 		return errors.New("cannot reorg out init message")
 	}
+	// Pending messages must be written before they can be reorged out
+	if err := s.flushPendingWrites(); err != nil {
+		return err
+	}
 	atomic.AddUint32(&s.reorgPending, 1)
 	s.reorgMutex.Lock()
 	defer s.reorgMutex.Unlock()
 	atomic.AddUint32(&s.reorgPending, ^uint32(0)) // decrement
+	s.uncoveredMessagesPos = 0
 	blockNum, err := s.MessageCountToBlockNumber(count)
 	if err != nil {
 		return err
 	}
 	// We can safely cast blockNum to a uint64 as we checked count == 0 above
 	targetBlock := s.bc.GetBlockByNumber(uint64(blockNum))
-	if targetBlock != nil {
+	if s.exec != nil {
+		err = s.exec.Reorg(context.Background(), count)
+		if err != nil {
+			return err
+		}
+	} else if targetBlock != nil {
 		if s.validator != nil {
 			err = s.validator.ReorgToBlock(targetBlock.NumberU64(), targetBlock.Hash())
 			if err != nil {
@@ -179,7 +287,7 @@ func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.M
 		if err != nil {
 			return err
 		}
-	} else {
+	} else if !s.executionDisabled {
 		log.Warn("reorg target block not found", "block", blockNum)
 	}
 
@@ -208,6 +316,9 @@ func dbKey(prefix []byte, pos uint64) []byte {
 
 // Note: if changed to acquire the mutex, some internal users may need to be updated to a non-locking version.
 func (s *TransactionStreamer) GetMessage(seqNum arbutil.MessageIndex) (arbstate.MessageWithMetadata, error) {
+	if message, ok := s.pendingMessage(seqNum); ok {
+		return message, nil
+	}
 	key := dbKey(messagePrefix, uint64(seqNum))
 	data, err := s.db.Get(key)
 	if err != nil {
@@ -220,6 +331,9 @@ func (s *TransactionStreamer) GetMessage(seqNum arbutil.MessageIndex) (arbstate.
 
 // Note: if changed to acquire the mutex, some internal users may need to be updated to a non-locking version.
 func (s *TransactionStreamer) GetMessageCount() (arbutil.MessageIndex, error) {
+	if count, ok := s.pendingMessageCount(); ok {
+		return count, nil
+	}
 	posBytes, err := s.db.Get(messageCountKey)
 	if err != nil {
 		return 0, err
@@ -237,6 +351,9 @@ func (s *TransactionStreamer) AddMessages(pos arbutil.MessageIndex, force bool,
 }
 
 func (s *TransactionStreamer) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
+	if len(messages) > 0 {
+		atomic.StoreUint32(&s.broadcastMessagesReceived, 1)
+	}
 	for i, message := range messages {
 		msgPos := pos + arbutil.MessageIndex(i)
 		if message.Message == nil || message.Message.Header == nil {
@@ -351,6 +468,9 @@ func (s *TransactionStreamer) addMessagesAndEndBatchImpl(pos arbutil.MessageInde
 		}
 		if bytes.Equal(haveMessage, wantMessage) {
 			// This message is a duplicate, skip it
+			if s.uncoveredMessagesPos != 0 && pos >= s.uncoveredMessagesPos {
+				reorgAvoidedReexecutionCounter.Inc(1)
+			}
 			prevDelayedRead = nextMessage.DelayedMessagesRead
 			messages = messages[1:]
 			pos++
@@ -389,6 +509,13 @@ func (s *TransactionStreamer) addMessagesAndEndBatchImpl(pos arbutil.MessageInde
 		}
 	}
 
+	if s.uncoveredMessagesPos != 0 && !reorg {
+		if currentCount, err := s.GetMessageCount(); err == nil && pos >= currentCount {
+			log.Info("messages uncovered by an L1 reorg were reread unchanged", "from", s.uncoveredMessagesPos, "messageCount", currentCount)
+			s.uncoveredMessagesPos = 0
+		}
+	}
+
 	if reorg {
 		if force {
 			batch := s.db.NewBatch()
@@ -404,6 +531,12 @@ func (s *TransactionStreamer) addMessagesAndEndBatchImpl(pos arbutil.MessageInde
 			return errors.New("reorg required but not allowed")
 		}
 	}
+	if batch != nil {
+		// Only the inbox tracker passes a batch, so these messages were read from L1
+		if err := s.coverFeedOnlyMessages(batch, afterCount); err != nil {
+			return err
+		}
+	}
 	if len(messages) == 0 {
 		if batch == nil {
 			return nil
@@ -414,6 +547,34 @@ func (s *TransactionStreamer) addMessagesAndEndBatchImpl(pos arbutil.MessageInde
 	return s.writeMessages(pos, messages, batch)
 }
 
+// FeedOnlyMessageCount returns the message count written while no inbox reader checked messages against L1,
+// or 0 if L1 batches have since covered all of them. Messages below it may be unfinalized.
+func (s *TransactionStreamer) FeedOnlyMessageCount() (arbutil.MessageIndex, error) {
+	hasCount, err := s.db.Has(feedOnlyMessageCountKey)
+	if err != nil || !hasCount {
+		return 0, err
+	}
+	countBytes, err := s.db.Get(feedOnlyMessageCountKey)
+	if err != nil {
+		return 0, err
+	}
+	var count uint64
+	if err := rlp.DecodeBytes(countBytes, &count); err != nil {
+		return 0, err
+	}
+	return arbutil.MessageIndex(count), nil
+}
+
+// coverFeedOnlyMessages clears the feed-only marker once L1 batches include every message written without L1.
+func (s *TransactionStreamer) coverFeedOnlyMessages(batch ethdb.Batch, l1Count arbutil.MessageIndex) error {
+	feedOnlyCount, err := s.FeedOnlyMessageCount()
+	if err != nil || feedOnlyCount == 0 || feedOnlyCount > l1Count {
+		return err
+	}
+	log.Info("L1 batches now cover the messages written from the feed alone", "messageCount", feedOnlyCount)
+	return batch.Delete(feedOnlyMessageCountKey)
+}
+
 func messageFromTxes(header *arbos.L1IncomingMessageHeader, txes types.Transactions, txErrors []error) (*arbos.L1IncomingMessage, error) {
 	if len(txErrors) != len(txes) {
 		return nil, fmt.Errorf("unexpected number of error results: %v vs number of txes %v", len(txErrors), len(txes))
@@ -529,16 +690,13 @@ func (s *TransactionStreamer) SequenceTransactions(header *arbos.L1IncomingMessa
 		}
 	}
 
-	if err := s.writeMessages(pos, []arbstate.MessageWithMetadata{msgWithMeta}, nil); err != nil {
+	if err := s.writeSequencedMessage(pos, msgWithMeta); err != nil {
 		return err
 	}
 
-	if s.broadcastServer != nil {
-		s.broadcastServer.BroadcastSingle(msgWithMeta, pos)
-	}
-
 	// Only write the block after we've written the messages, so if the node dies in the middle of this,
 	// it will naturally recover on startup by regenerating the missing block.
+	// If the message's write is still pending, the block is instead rewound on startup.
 	var logs []*types.Log
 	for _, receipt := range receipts {
 		logs = append(logs, receipt.Logs...)
@@ -642,6 +800,34 @@ func (s *TransactionStreamer) MessageCountToBlockNumber(messageNum arbutil.Messa
 	return arbutil.MessageCountToBlockNumber(messageNum, genesis), nil
 }
 
+var errMessagesNotExecuted = errors.New("messages not yet executed")
+var errArbOSVersionUnknown = errors.New("ArbOS version is unknown without local execution, so batches depending on it can't be read")
+
+// arbOSVersionAfter returns the ArbOS version of the chain once count messages have been executed,
+// or errMessagesNotExecuted if they haven't been yet. Without local execution, the version is never known.
+func (s *TransactionStreamer) arbOSVersionAfter(count arbutil.MessageIndex) (uint64, error) {
+	if count == 0 {
+		// The chain isn't initialized before the first message
+		return 0, nil
+	}
+	if s.exec != nil || s.executionDisabled {
+		return 0, errArbOSVersionUnknown
+	}
+	blockNum, err := s.MessageCountToBlockNumber(count)
+	if err != nil {
+		return 0, err
+	}
+	header := s.bc.GetHeaderByNumber(uint64(blockNum))
+	if header == nil {
+		return 0, errMessagesNotExecuted
+	}
+	statedb, err := s.bc.StateAt(header.Root)
+	if err != nil {
+		return 0, err
+	}
+	return arbosState.ArbOSVersion(statedb), nil
+}
+
 // Pauses reorgs until a matching call to ResumeReorgs (may be called concurrently)
 func (s *TransactionStreamer) PauseReorgs() {
 	s.reorgMutex.RLock()
@@ -654,6 +840,10 @@ func (s *TransactionStreamer) ResumeReorgs() {
 // The mutex must be held, and pos must be the latest message count.
 // `batch` may be nil, which initializes a new batch. The batch is closed out in this function.
 func (s *TransactionStreamer) writeMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata, batch ethdb.Batch) error {
+	// Sequenced messages pending a batched write come first
+	if err := s.flushPendingWrites(); err != nil {
+		return err
+	}
 	if batch == nil {
 		batch = s.db.NewBatch()
 	}
@@ -676,6 +866,13 @@ func (s *TransactionStreamer) writeMessages(pos arbutil.MessageIndex, messages [
 	if err != nil {
 		return err
 	}
+	if s.inboxReader == nil {
+		// Nothing on L1 backs these messages, so mark them unfinalized
+		err = batch.Put(feedOnlyMessageCountKey, newCount)
+		if err != nil {
+			return err
+		}
+	}
 	err = batch.Write()
 	if err != nil {
 		return err
@@ -691,6 +888,9 @@ func (s *TransactionStreamer) writeMessages(pos arbutil.MessageIndex, messages [
 
 // Produce and record blocks for all available messages
 func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
+	if s.executionDisabled {
+		return nil
+	}
 	s.createBlocksMutex.Lock()
 	defer s.createBlocksMutex.Unlock()
 	s.reorgMutex.RLock()
@@ -700,6 +900,9 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if s.exec != nil {
+		return s.createBlocksRemotely(ctx, msgCount)
+	}
 	lastBlockHeader := s.bc.CurrentBlock().Header()
 	if lastBlockHeader == nil {
 		return errors.New("current block header not found")
@@ -719,6 +922,9 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 	}()
 
 	batchFetcher := func(batchNum uint64) ([]byte, error) {
+		if s.inboxReader == nil {
+			return nil, fmt.Errorf("cannot fetch batch %v without an L1 connection (feed-only mode)", batchNum)
+		}
 		return s.inboxReader.GetSequencerMessageBytes(ctx, batchNum)
 	}
 
@@ -793,6 +999,77 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 	return nil
 }
 
+// createBlocksRemotely has the execution node execute the messages it hasn't yet, first reorging out any it has
+// that this node no longer does. The caller must hold the createBlocksMutex and reorgMutex.
+func (s *TransactionStreamer) createBlocksRemotely(ctx context.Context, msgCount arbutil.MessageIndex) error {
+	pos, err := s.exec.HeadMessageCount(ctx)
+	if err != nil {
+		return err
+	}
+	if pos > msgCount {
+		log.Warn("execution node is ahead of the messages, reorging it", "executed", pos, "messages", msgCount)
+		if err := s.exec.Reorg(ctx, msgCount); err != nil {
+			return err
+		}
+		pos = msgCount
+	}
+	batchFetcher := func(batchNum uint64) ([]byte, error) {
+		if s.inboxReader == nil {
+			return nil, fmt.Errorf("cannot fetch batch %v without an L1 connection (feed-only mode)", batchNum)
+		}
+		return s.inboxReader.GetSequencerMessageBytes(ctx, batchNum)
+	}
+	for ; pos < msgCount; pos++ {
+		if atomic.LoadUint32(&s.reorgPending) > 0 {
+			// stop block creation as we need to reorg
+			break
+		}
+		if ctx.Err() != nil {
+			// the context is done, shut down
+			// nolint:nilerr
+			return nil
+		}
+		msg, err := s.GetMessage(pos)
+		if err != nil {
+			return err
+		}
+		batches, err := referencedBatches(s.bc.Config().ChainID, &msg, batchFetcher)
+		if err != nil {
+			return err
+		}
+		result, err := s.exec.DigestMessage(ctx, pos, &msg, batches)
+		if err != nil {
+			return err
+		}
+		log.Debug("execution node executed message", "pos", pos, "blockHash", result.BlockHash)
+	}
+	return nil
+}
+
+// builtMessageCount returns the last block built and the number of messages executed to build it.
+func (s *TransactionStreamer) builtMessageCount() (uint64, arbutil.MessageIndex, error) {
+	if s.exec != nil {
+		count, err := s.exec.HeadMessageCount(context.Background())
+		if err != nil {
+			return 0, 0, err
+		}
+		blockNum, err := s.MessageCountToBlockNumber(count)
+		return uint64(blockNum), count, err
+	}
+	if s.executionDisabled {
+		// Nothing is executed, so the messages stored are all there is to build
+		count, err := s.GetMessageCount()
+		if err != nil {
+			return 0, 0, err
+		}
+		blockNum, err := s.MessageCountToBlockNumber(count)
+		return uint64(blockNum), count, err
+	}
+	blockNum := s.bc.CurrentHeader().Number.Uint64()
+	count, err := s.BlockNumberToMessageCount(blockNum)
+	return blockNum, count, err
+}
+
 func (s *TransactionStreamer) SyncProgressMap() map[string]interface{} {
 	res := make(map[string]interface{})
 
@@ -802,12 +1079,15 @@ func (s *TransactionStreamer) SyncProgressMap() map[string]interface{} {
 		return res
 	}
 
+	if s.inboxReader == nil {
+		return s.feedOnlySyncProgressMap(res, msgCount)
+	}
+
 	batchSeen := s.inboxReader.GetLastSeenBatchCount()
 	_, batchProcessed := s.inboxReader.GetLastReadBlockAndBatchCount()
 	broadcasterQueuedMessagesPos := atomic.LoadUint64(&s.broadcasterQueuedMessagesPos)
 
-	lastBlockNum := s.bc.CurrentHeader().Number.Uint64()
-	lastBuiltMessage, err := s.BlockNumberToMessageCount(lastBlockNum)
+	lastBlockNum, lastBuiltMessage, err := s.builtMessageCount()
 	if err != nil {
 		res["blockMessageToMessageCountError"] = err.Error()
 		return res
@@ -824,7 +1104,7 @@ func (s *TransactionStreamer) SyncProgressMap() map[string]interface{} {
 		(batchProcessed >= batchSeen) && // up to date in inbox messages
 		(broadcasterQueuedMessagesPos == 0) && // no unprocessed feed
 		(processedMetadata.MessageCount <= lastBuiltMessage) && // built blocks for entire inbox
-		(msgCount <= lastBuiltMessage+20) { // TODO: configurable gap
+		(msgCount <= lastBuiltMessage+arbutil.MessageIndex(s.config.SyncedMessageGap)) {
 		return res
 	}
 
@@ -838,12 +1118,43 @@ func (s *TransactionStreamer) SyncProgressMap() map[string]interface{} {
 	return res
 }
 
+// Without an inbox reader the feed is the only source of messages, so being synced means the feed has
+// delivered messages since startup, and blocks are built for everything it delivered.
+func (s *TransactionStreamer) feedOnlySyncProgressMap(res map[string]interface{}, msgCount arbutil.MessageIndex) map[string]interface{} {
+	broadcasterQueuedMessagesPos := atomic.LoadUint64(&s.broadcasterQueuedMessagesPos)
+	feedMessagesReceived := atomic.LoadUint32(&s.broadcastMessagesReceived) != 0
+	lastBlockNum, lastBuiltMessage, err := s.builtMessageCount()
+	if err != nil {
+		res["blockMessageToMessageCountError"] = err.Error()
+		return res
+	}
+	if feedMessagesReceived &&
+		broadcasterQueuedMessagesPos == 0 &&
+		msgCount <= lastBuiltMessage+arbutil.MessageIndex(s.config.SyncedMessageGap) {
+		return res
+	}
+	res["feedOnly"] = true
+	res["feedMessagesReceived"] = feedMessagesReceived
+	res["msgCount"] = msgCount
+	res["broadcasterQueuedMessagesPos"] = broadcasterQueuedMessagesPos
+	res["blockNum"] = lastBlockNum
+	res["messageOfLastBlock"] = lastBuiltMessage
+	return res
+}
+
+func (s *TransactionStreamer) HasInboxReader() bool {
+	return s.inboxReader != nil
+}
+
 func (s *TransactionStreamer) Initialize() error {
 	return s.cleanupInconsistentState()
 }
 
 func (s *TransactionStreamer) Start(ctxIn context.Context) {
 	s.StopWaiter.Start(ctxIn)
+	if s.config.WriteBatching.Enable {
+		s.CallIteratively(s.flushPendingWritesIteratively)
+	}
 	s.LaunchThread(func(ctx context.Context) {
 		for {
 			err := s.createBlocks(ctx)
@@ -892,3 +1203,19 @@ func (s *TransactionStreamer) Start(ctxIn context.Context) {
 		}
 	})
 }
+
+func (s *TransactionStreamer) StopAndWait() {
+	s.StopWaiter.StopAndWait()
+	s.insertionMutex.Lock()
+	defer s.insertionMutex.Unlock()
+	if err := s.flushPendingWrites(); err != nil {
+		log.Error("failed to write pending sequenced messages on shutdown", "err", err)
+		s.pendingWritesMutex.Lock()
+		acks := s.pendingWritesAcks
+		s.pendingWritesAcks = nil
+		s.pendingWritesMutex.Unlock()
+		for _, ack := range acks {
+			ack(err)
+		}
+	}
+}
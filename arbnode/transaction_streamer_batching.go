@@ -0,0 +1,212 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+var (
+	writeBatchFlushCounter    = metrics.NewRegisteredCounter("arb/streamer/writebatch/flushes", nil)
+	writeBatchMessagesCounter = metrics.NewRegisteredCounter("arb/streamer/writebatch/messages", nil)
+	writeBatchPendingGauge    = metrics.NewRegisteredGauge("arb/streamer/writebatch/pending", nil)
+)
+
+// WriteBatchingConfig has the streamer group the messages it sequences into fewer database writes.
+// Sequenced messages are only broadcast once written, and if the node stops before writing them,
+// the blocks produced from them are rewound on startup, so what's been broadcast always survives a crash.
+type WriteBatchingConfig struct {
+	Enable        bool          `koanf:"enable"`
+	FlushInterval time.Duration `koanf:"flush-interval"`
+	MaxMessages   int           `koanf:"max-messages"`
+	MaxSize       int           `koanf:"max-size"`
+}
+
+func WriteBatchingConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultWriteBatchingConfig.Enable, "group sequenced messages into fewer database writes")
+	f.Duration(prefix+".flush-interval", DefaultWriteBatchingConfig.FlushInterval, "maximum time a sequenced message waits to be written and broadcast")
+	f.Int(prefix+".max-messages", DefaultWriteBatchingConfig.MaxMessages, "number of pending sequenced messages which triggers a write")
+	f.Int(prefix+".max-size", DefaultWriteBatchingConfig.MaxSize, "size in bytes of pending sequenced messages which triggers a write")
+}
+
+var DefaultWriteBatchingConfig = WriteBatchingConfig{
+	Enable:        false,
+	FlushInterval: 50 * time.Millisecond,
+	MaxMessages:   64,
+	MaxSize:       4 * 1024 * 1024,
+}
+
+type pendingWrite struct {
+	message arbstate.MessageWithMetadata
+	encoded []byte
+}
+
+func (s *TransactionStreamer) pendingMessage(seqNum arbutil.MessageIndex) (arbstate.MessageWithMetadata, bool) {
+	s.pendingWritesMutex.RLock()
+	defer s.pendingWritesMutex.RUnlock()
+	if seqNum < s.pendingWritesPos || seqNum >= s.pendingWritesPos+arbutil.MessageIndex(len(s.pendingWrites)) {
+		return arbstate.MessageWithMetadata{}, false
+	}
+	return s.pendingWrites[seqNum-s.pendingWritesPos].message, true
+}
+
+func (s *TransactionStreamer) pendingMessageCount() (arbutil.MessageIndex, bool) {
+	s.pendingWritesMutex.RLock()
+	defer s.pendingWritesMutex.RUnlock()
+	if len(s.pendingWrites) == 0 {
+		return 0, false
+	}
+	return s.pendingWritesPos + arbutil.MessageIndex(len(s.pendingWrites)), true
+}
+
+// writeSequencedMessage writes and broadcasts a message the sequencer produced, or if write batching is enabled,
+// queues it to be written and broadcast with the messages sequenced after it.
+// The insertion mutex must be held, and pos must be the latest message count.
+func (s *TransactionStreamer) writeSequencedMessage(pos arbutil.MessageIndex, msg arbstate.MessageWithMetadata) error {
+	config := &s.config.WriteBatching
+	if !config.Enable {
+		if err := s.writeMessages(pos, []arbstate.MessageWithMetadata{msg}, nil); err != nil {
+			return err
+		}
+		if s.broadcastServer != nil {
+			s.broadcastServer.BroadcastSingle(msg, pos)
+		}
+		return nil
+	}
+	encoded, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return err
+	}
+	s.pendingWritesMutex.Lock()
+	if len(s.pendingWrites) == 0 {
+		s.pendingWritesPos = pos
+	}
+	s.pendingWrites = append(s.pendingWrites, pendingWrite{msg, encoded})
+	s.pendingWritesSize += len(encoded)
+	pending, size := len(s.pendingWrites), s.pendingWritesSize
+	s.pendingWritesMutex.Unlock()
+	writeBatchPendingGauge.Update(int64(pending))
+
+	if pending >= config.MaxMessages || size >= config.MaxSize {
+		return s.flushPendingWrites()
+	}
+	return nil
+}
+
+// AfterSequencedWrite calls ack once the messages sequenced so far have been written, or right away if they
+// already have been. It's called with an error if the node stops without writing them.
+func (s *TransactionStreamer) AfterSequencedWrite(ack func(error)) {
+	s.pendingWritesMutex.Lock()
+	if len(s.pendingWrites) > 0 {
+		s.pendingWritesAcks = append(s.pendingWritesAcks, ack)
+		s.pendingWritesMutex.Unlock()
+		return
+	}
+	s.pendingWritesMutex.Unlock()
+	ack(nil)
+}
+
+// flushPendingWrites writes and broadcasts the sequenced messages pending a batched write.
+// The insertion mutex must be held.
+func (s *TransactionStreamer) flushPendingWrites() error {
+	s.pendingWritesMutex.RLock()
+	pos := s.pendingWritesPos
+	pending := s.pendingWrites
+	s.pendingWritesMutex.RUnlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	batch := s.db.NewBatch()
+	for i, write := range pending {
+		if err := batch.Put(dbKey(messagePrefix, uint64(pos)+uint64(i)), write.encoded); err != nil {
+			return err
+		}
+	}
+	newCount, err := rlp.EncodeToBytes(uint64(pos) + uint64(len(pending)))
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(messageCountKey, newCount); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	// Only drop the pending messages once they can be read from the database
+	s.pendingWritesMutex.Lock()
+	acks := s.pendingWritesAcks
+	s.pendingWrites = nil
+	s.pendingWritesSize = 0
+	s.pendingWritesAcks = nil
+	s.pendingWritesMutex.Unlock()
+	writeBatchPendingGauge.Update(0)
+	writeBatchFlushCounter.Inc(1)
+	writeBatchMessagesCounter.Inc(int64(len(pending)))
+
+	if s.broadcastServer != nil {
+		for i, write := range pending {
+			s.broadcastServer.BroadcastSingle(write.message, pos+arbutil.MessageIndex(i))
+		}
+	}
+	for _, ack := range acks {
+		ack(nil)
+	}
+	select {
+	case s.newMessageNotifier <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *TransactionStreamer) flushPendingWritesIteratively(ctx context.Context) time.Duration {
+	s.insertionMutex.Lock()
+	err := s.flushPendingWrites()
+	s.insertionMutex.Unlock()
+	if err != nil {
+		log.Error("failed to write pending sequenced messages", "err", err)
+	}
+	return s.config.WriteBatching.FlushInterval
+}
+
+// rewindUnwrittenBlocks rewinds the chain to the last message written, as the node may have stopped after
+// producing blocks from sequenced messages pending a batched write, but before writing them.
+// Those messages were never broadcast, nor their transactions reported as sequenced to their senders, so
+// nothing else has seen the blocks produced from them.
+func (s *TransactionStreamer) rewindUnwrittenBlocks() error {
+	count, err := s.GetMessageCount()
+	if err != nil || count == 0 {
+		return err
+	}
+	lastBlockNum, err := s.MessageCountToBlockNumber(count)
+	if err != nil {
+		return err
+	}
+	head := s.bc.CurrentBlock()
+	if head == nil || head.NumberU64() <= uint64(lastBlockNum) {
+		return nil
+	}
+	target := s.bc.GetBlockByNumber(uint64(lastBlockNum))
+	if target == nil {
+		log.Warn("block of the last written message not found", "block", lastBlockNum)
+		return nil
+	}
+	log.Warn("rewinding blocks produced from messages which were never written", "head", head.NumberU64(), "target", lastBlockNum)
+	if s.validator != nil {
+		if err := s.validator.ReorgToBlock(target.NumberU64(), target.Hash()); err != nil {
+			return err
+		}
+	}
+	return s.bc.ReorgToOldBlock(target)
+}
@@ -0,0 +1,139 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestTransactionStreamerWriteBatching(t *testing.T) {
+	streamer, arbDb, _ := NewTransactionStreamerForTest(t, common.Address{})
+	config := DefaultTransactionStreamerConfig
+	config.WriteBatching.Enable = true
+	config.WriteBatching.MaxMessages = 3
+	streamer.config = &config
+
+	writtenCount := func() arbutil.MessageIndex {
+		data, err := arbDb.Get(messageCountKey)
+		Require(t, err)
+		var count uint64
+		Require(t, rlp.DecodeBytes(data, &count))
+		return arbutil.MessageIndex(count)
+	}
+
+	streamer.insertionMutex.Lock()
+	defer streamer.insertionMutex.Unlock()
+	for i := 0; i < 5; i++ {
+		pos, err := streamer.GetMessageCount()
+		Require(t, err)
+		msg := arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{Kind: arbos.L1MessageType_L2Message, BlockNumber: uint64(i)},
+				L2msg:  []byte{byte(i)},
+			},
+			DelayedMessagesRead: 1,
+		}
+		Require(t, streamer.writeSequencedMessage(pos, msg))
+
+		read, err := streamer.GetMessage(pos)
+		Require(t, err)
+		if read.Message.Header.BlockNumber != uint64(i) {
+			Fail(t, "read the wrong message at", pos, read.Message.Header)
+		}
+	}
+
+	count, err := streamer.GetMessageCount()
+	Require(t, err)
+	if count != 6 {
+		Fail(t, "unexpected message count", count)
+	}
+	// The first three sequenced messages were written together, the last two are pending
+	if written := writtenCount(); written != 4 {
+		Fail(t, "unexpected written message count", written)
+	}
+	acked := false
+	streamer.AfterSequencedWrite(func(err error) {
+		Require(t, err)
+		acked = true
+	})
+	if acked {
+		Fail(t, "acked sequenced messages before they were written")
+	}
+
+	Require(t, streamer.flushPendingWrites())
+	if written := writtenCount(); written != 6 {
+		Fail(t, "unexpected written message count after flush", written)
+	}
+	if !acked {
+		Fail(t, "sequenced messages not acked once written")
+	}
+	acked = false
+	streamer.AfterSequencedWrite(func(err error) {
+		Require(t, err)
+		acked = true
+	})
+	if !acked {
+		Fail(t, "written messages not acked right away")
+	}
+	read, err := streamer.GetMessage(5)
+	Require(t, err)
+	if read.Message.Header.BlockNumber != 4 {
+		Fail(t, "read the wrong message after flush", read.Message.Header)
+	}
+}
+
+func TestTransactionStreamerUncoveredMessages(t *testing.T) {
+	streamer, _, _ := NewTransactionStreamerForTest(t, common.Address{})
+	config := DefaultTransactionStreamerConfig
+	config.ReexecutionFreeReorgs = true
+	streamer.config = &config
+	makeMessage := func(i int) arbstate.MessageWithMetadata {
+		return arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{Kind: arbos.L1MessageType_L2Message, BlockNumber: uint64(i)},
+				L2msg:  []byte{byte(i)},
+			},
+			DelayedMessagesRead: 1,
+		}
+	}
+	var messages []arbstate.MessageWithMetadata
+	for i := 0; i < 4; i++ {
+		messages = append(messages, makeMessage(i))
+	}
+	Require(t, streamer.AddMessages(1, false, messages))
+
+	// An L1 reorg uncovers the last three messages, which are reread unchanged
+	Require(t, streamer.UncoverMessagesAndEndBatch(streamer.db.NewBatch(), 2))
+	count, err := streamer.GetMessageCount()
+	Require(t, err)
+	if count != 5 || streamer.uncoveredMessagesPos != 2 {
+		Fail(t, "unexpected state after uncovering messages", count, streamer.uncoveredMessagesPos)
+	}
+	Require(t, streamer.AddMessages(2, true, messages[1:]))
+	count, err = streamer.GetMessageCount()
+	Require(t, err)
+	if count != 5 || streamer.uncoveredMessagesPos != 0 {
+		Fail(t, "unexpected state after rereading uncovered messages", count, streamer.uncoveredMessagesPos)
+	}
+
+	// This time they're reread with the second one changed
+	Require(t, streamer.UncoverMessagesAndEndBatch(streamer.db.NewBatch(), 2))
+	Require(t, streamer.AddMessages(2, true, []arbstate.MessageWithMetadata{messages[1], makeMessage(10)}))
+	count, err = streamer.GetMessageCount()
+	Require(t, err)
+	if count != 4 {
+		Fail(t, "unexpected message count after rereading changed messages", count)
+	}
+	msg, err := streamer.GetMessage(3)
+	Require(t, err)
+	if msg.Message.Header.BlockNumber != 10 {
+		Fail(t, "changed message not reorged in", msg.Message.Header)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+const (
+	TxRejectionNonceTooLow        = "nonce-too-low"
+	TxRejectionNonceTooHigh       = "nonce-too-high"
+	TxRejectionInsufficientFunds  = "insufficient-funds"
+	TxRejectionIntrinsicGasTooLow = "intrinsic-gas-too-low"
+	TxRejectionOversizedData      = "oversized-data"
+)
+
+// TxRejectionData is returned in the data field of the JSON-RPC error when a transaction is rejected,
+// so that wallets can correct the transaction without parsing the error message.
+type TxRejectionData struct {
+	Reason        string          `json:"reason"`
+	Sender        *common.Address `json:"sender,omitempty"`
+	ExpectedNonce *hexutil.Uint64 `json:"expectedNonce,omitempty"`
+	Balance       *hexutil.Big    `json:"balance,omitempty"`
+	Cost          *hexutil.Big    `json:"cost,omitempty"`
+	Shortfall     *hexutil.Big    `json:"shortfall,omitempty"`
+	RequiredGas   *hexutil.Uint64 `json:"requiredGas,omitempty"`
+	DataSize      *hexutil.Uint64 `json:"dataSize,omitempty"`
+	MaxDataSize   *hexutil.Uint64 `json:"maxDataSize,omitempty"`
+}
+
+// TxRejectionError keeps the message of the underlying error, and implements geth's rpc.DataError.
+// It must be returned unwrapped for the RPC server to find the data.
+type TxRejectionError struct {
+	err  error
+	data TxRejectionData
+}
+
+func (e *TxRejectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *TxRejectionError) Unwrap() error {
+	return e.err
+}
+
+func (e *TxRejectionError) ErrorData() interface{} {
+	return e.data
+}
+
+func uint64Ptr(value uint64) *hexutil.Uint64 {
+	hexValue := hexutil.Uint64(value)
+	return &hexValue
+}
+
+func nonceRejection(err error, sender common.Address, expectedNonce uint64) *TxRejectionError {
+	reason := TxRejectionNonceTooLow
+	if errors.Is(err, core.ErrNonceTooHigh) {
+		reason = TxRejectionNonceTooHigh
+	}
+	return &TxRejectionError{err, TxRejectionData{
+		Reason:        reason,
+		Sender:        &sender,
+		ExpectedNonce: uint64Ptr(expectedNonce),
+	}}
+}
+
+func insufficientFundsRejection(err error, sender common.Address, balance *big.Int, cost *big.Int) *TxRejectionError {
+	return &TxRejectionError{err, TxRejectionData{
+		Reason:    TxRejectionInsufficientFunds,
+		Sender:    &sender,
+		Balance:   (*hexutil.Big)(balance),
+		Cost:      (*hexutil.Big)(cost),
+		Shortfall: (*hexutil.Big)(arbmath.BigSub(cost, balance)),
+	}}
+}
+
+func intrinsicGasRejection(err error, requiredGas uint64) *TxRejectionError {
+	return &TxRejectionError{err, TxRejectionData{
+		Reason:      TxRejectionIntrinsicGasTooLow,
+		RequiredGas: uint64Ptr(requiredGas),
+	}}
+}
+
+func oversizedDataRejection(err error, dataSize uint64, maxDataSize uint64) *TxRejectionError {
+	return &TxRejectionError{err, TxRejectionData{
+		Reason:      TxRejectionOversizedData,
+		DataSize:    uint64Ptr(dataSize),
+		MaxDataSize: uint64Ptr(maxDataSize),
+	}}
+}
+
+// rejectionFromState adds structured data to a nonce error, looking up the sender's current nonce.
+// Other errors are returned as is.
+func rejectionFromState(err error, statedb *state.StateDB, sender common.Address) error {
+	if errors.Is(err, core.ErrNonceTooLow) || errors.Is(err, core.ErrNonceTooHigh) {
+		return nonceRejection(err, sender, statedb.GetNonce(sender))
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestTxRejectionError(t *testing.T) {
+	sender := common.HexToAddress("0x1234")
+
+	var err error = nonceRejection(fmt.Errorf("%w: address %v", core.ErrNonceTooLow, sender), sender, 7)
+	if !errors.Is(err, core.ErrNonceTooLow) {
+		Fail(t, "rejection doesn't wrap nonce error")
+	}
+	dataErr, ok := err.(rpc.DataError)
+	if !ok {
+		Fail(t, "rejection isn't an rpc.DataError")
+	}
+	data := dataErr.ErrorData().(TxRejectionData)
+	if data.Reason != TxRejectionNonceTooLow || uint64(*data.ExpectedNonce) != 7 || *data.Sender != sender {
+		Fail(t, "unexpected nonce rejection data", data)
+	}
+
+	err = insufficientFundsRejection(core.ErrInsufficientFunds, sender, big.NewInt(30), big.NewInt(100))
+	if err.Error() != core.ErrInsufficientFunds.Error() {
+		Fail(t, "rejection changed the error message", err)
+	}
+	data = err.(*TxRejectionError).data
+	if data.Reason != TxRejectionInsufficientFunds || data.Shortfall.ToInt().Int64() != 70 {
+		Fail(t, "unexpected insufficient funds rejection data", data)
+	}
+}
@@ -115,9 +115,9 @@ func (c *TxPreChecker) StopAndWait() {
 	c.publisher.StopAndWait()
 }
 
-func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transaction, options *ConditionalOptions) error {
 	if tx.Gas() < params.TxGas {
-		return core.ErrIntrinsicGas
+		return intrinsicGasRejection(core.ErrIntrinsicGas, params.TxGas)
 	}
 	state := c.getLatestState()
 	sender, err := types.Sender(types.LatestSigner(c.bc.Config()), tx)
@@ -127,10 +127,11 @@ func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transac
 	balance := state.stateDb.GetBalance(sender)
 	cost := tx.Cost()
 	if arbmath.BigLessThan(balance, cost) {
-		return fmt.Errorf("%w: address %v have %v want %v", core.ErrInsufficientFunds, sender, balance, cost)
+		err := fmt.Errorf("%w: address %v have %v want %v", core.ErrInsufficientFunds, sender, balance, cost)
+		return insufficientFundsRejection(err, sender, balance, cost)
 	}
 	if tx.Nonce() < state.stateDb.GetNonce(sender) {
-		return core.ErrNonceTooLow
+		return nonceRejection(core.ErrNonceTooLow, sender, state.stateDb.GetNonce(sender))
 	}
 	intrinsic, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, c.bc.Config().IsHomestead(state.blockNum), true)
 	if err != nil {
@@ -139,7 +140,7 @@ func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transac
 	// We can't cache here because the state the tx is executed in might not the our latestState
 	_, dataGas := state.l1PricingState.GetPosterInfoWithoutCache(tx, l1pricing.BatchPosterAddress)
 	if tx.Gas() < intrinsic+dataGas {
-		return core.ErrIntrinsicGas
+		return intrinsicGasRejection(core.ErrIntrinsicGas, intrinsic+dataGas)
 	}
-	return c.publisher.PublishTransaction(ctx, tx)
+	return c.publisher.PublishTransaction(ctx, tx, options)
 }
@@ -4,10 +4,12 @@
 package arbosState
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common/math"
 
@@ -51,6 +53,7 @@ type ArbosState struct {
 	chainId           storage.StorageBackedBigInt
 	genesisBlockNum   storage.StorageBackedUint64
 	backingStorage    *storage.Storage
+	stateDB           vm.StateDB
 	Burner            burn.Burner
 }
 
@@ -81,6 +84,7 @@ func OpenArbosState(stateDB vm.StateDB, burner burn.Burner) (*ArbosState, error)
 		backingStorage.OpenStorageBackedBigInt(uint64(chainIdOffset)),
 		backingStorage.OpenStorageBackedUint64(uint64(genesisBlockNumOffset)),
 		backingStorage,
+		stateDB,
 		burner,
 	}, nil
 }
@@ -170,6 +174,36 @@ func getArbitrumOnlyPrecompiles(chainConfig *params.ChainConfig) []common.Addres
 	return arbOnlyPrecompiles
 }
 
+// CustomPrecompileVersions holds the custom precompiles registered on each chain, by chain ID, with the
+// ArbOS version activating each. The precompiles package fills it in from its registrations.
+var CustomPrecompileVersions = map[uint64]map[common.Address]uint64{}
+
+func isCustomPrecompile(address common.Address) bool {
+	for _, precompiles := range CustomPrecompileVersions {
+		if _, ok := precompiles[address]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the custom precompiles of a chain activated at an ArbOS version
+func customPrecompilesActivatedAt(chainId *big.Int, arbosVersion uint64) []common.Address {
+	if !chainId.IsUint64() {
+		return nil
+	}
+	var activated []common.Address
+	for address, version := range CustomPrecompileVersions[chainId.Uint64()] {
+		if version == arbosVersion {
+			activated = append(activated, address)
+		}
+	}
+	sort.Slice(activated, func(i, j int) bool {
+		return bytes.Compare(activated[i][:], activated[j][:]) < 0
+	})
+	return activated
+}
+
 // During early development we sometimes change the storage format of version 1, for convenience. But as soon as we
 // start running long-lived chains, every change to the storage format will require defining a new version and
 // providing upgrade code.
@@ -191,7 +225,13 @@ func InitializeArbosState(stateDB vm.StateDB, burner burn.Burner, chainConfig *p
 
 	// Solidity requires call targets have code, but precompiles don't.
 	// To work around this, we give precompiles fake code.
+	// Custom precompiles are only given fake code on the chains registering them, once they're activated.
 	for _, precompile := range getArbitrumOnlyPrecompiles(chainConfig) {
+		if !isCustomPrecompile(precompile) {
+			stateDB.SetCode(precompile, []byte{byte(vm.INVALID)})
+		}
+	}
+	for _, precompile := range customPrecompilesActivatedAt(chainConfig.ChainID, 1) {
 		stateDB.SetCode(precompile, []byte{byte(vm.INVALID)})
 	}
 
@@ -263,10 +303,20 @@ func (state *ArbosState) UpgradeArbosVersion(upgradeTo uint64) {
 			ensure(state.l1PricingState.SetAmortizedCostCapBips(math.MaxUint64))
 		case 3:
 			// no state changes needed
+		case 4:
+			// no state changes needed, zstd-compressed batches are decoded from version 5
+		case 5:
+			// no state changes needed, priority fees are charged from version 6
 		default:
 			panic("Unable to perform requested ArbOS upgrade")
 		}
+		chainId, err := state.chainId.Get()
+		ensure(err)
 		state.arbosVersion++
+
+		for _, precompile := range customPrecompilesActivatedAt(chainId, state.arbosVersion) {
+			state.stateDB.SetCode(precompile, []byte{byte(vm.INVALID)})
+		}
 	}
 	state.Restrict(state.backingStorage.SetUint64ByUint64(uint64(versionOffset), state.arbosVersion))
 }
@@ -80,7 +80,7 @@ func createNewHeader(prevHeader *types.Header, l1info *L1Info, state *arbosState
 type SequencingHooks struct {
 	TxErrors               []error
 	DiscardInvalidTxsEarly bool
-	PreTxFilter            func(*arbosState.ArbosState, *types.Transaction, common.Address) error
+	PreTxFilter            func(*types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, common.Address) error
 	PostTxFilter           func(*arbosState.ArbosState, *types.Transaction, common.Address, uint64, *types.Receipt) error
 }
 
@@ -88,7 +88,7 @@ func noopSequencingHooks() *SequencingHooks {
 	return &SequencingHooks{
 		[]error{},
 		false,
-		func(*arbosState.ArbosState, *types.Transaction, common.Address) error {
+		func(*types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, common.Address) error {
 			return nil
 		},
 		func(*arbosState.ArbosState, *types.Transaction, common.Address, uint64, *types.Receipt) error {
@@ -225,7 +225,7 @@ func ProduceBlockAdvanced(
 				return nil, nil, err
 			}
 
-			if err := hooks.PreTxFilter(state, tx, sender); err != nil {
+			if err := hooks.PreTxFilter(header, statedb, state, tx, sender); err != nil {
 				return nil, nil, err
 			}
 
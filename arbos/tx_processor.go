@@ -33,6 +33,10 @@ var arbosAddress = types.ArbosAddress
 
 const GasEstimationL1PricePadding arbmath.Bips = 11000 // pad estimates by 10%
 
+// PriorityFeeArbosVersion is the ArbOS version from which a transaction's priority fee is charged along with the
+// base fee, going to the network fee account, rather than being dropped.
+const PriorityFeeArbosVersion uint64 = 6
+
 // A TxProcessor is created and freed for every L2 transaction.
 // It tracks state for ArbOS, allowing it infuence in Geth's tx processing.
 // Public fields are accessible in precompiles.
@@ -41,7 +45,8 @@ type TxProcessor struct {
 	state            *arbosState.ArbosState
 	PosterFee        *big.Int // set once in GasChargingHook to track L1 calldata costs
 	posterGas        uint64
-	computeHoldGas   uint64 // amount of gas temporarily held to prevent compute from exceeding the gas limit
+	computeHoldGas   uint64   // amount of gas temporarily held to prevent compute from exceeding the gas limit
+	priorityFee      *big.Int // the priority fee per gas charged in GasChargingHook, or nil if none is
 	Callers          []common.Address
 	TopTxType        *byte // set once in StartTxHook
 	evm              *vm.EVM
@@ -413,6 +418,23 @@ func (p *TxProcessor) GasChargingHook(gasRemaining *uint64) error {
 			*gasRemaining = gasAvailable
 		}
 	}
+
+	if p.state.FormatVersion() >= PriorityFeeArbosVersion && p.msg.RunMode() == types.MessageCommitMode {
+		// Charge the priority fee for all the gas up front, like the base fee, refunding what's left after the tx.
+		// Geth has checked the sender can afford the fee cap, which bounds the base fee plus the priority fee.
+		underlyingTx := p.msg.UnderlyingTransaction()
+		if underlyingTx != nil && underlyingTx.Type() <= types.DynamicFeeTxType {
+			priorityFee := arbmath.BigSub(arbmath.BigMin(p.msg.GasFeeCap(), arbmath.BigAdd(gasPrice, p.msg.GasTipCap())), gasPrice)
+			if priorityFee.Sign() > 0 {
+				from := p.msg.From()
+				err := util.BurnBalance(&from, arbmath.BigMulByUint(priorityFee, p.msg.Gas()), p.evm, util.TracingBeforeEVM, "priorityFee")
+				if err != nil {
+					return err
+				}
+				p.priorityFee = priorityFee
+			}
+		}
+	}
 	return nil
 }
 
@@ -515,6 +537,11 @@ func (p *TxProcessor) EndTxHook(gasLeft uint64, success bool) {
 
 	purpose := "feeCollection"
 	util.MintBalance(&networkFeeAccount, computeCost, p.evm, scenario, purpose)
+	if p.priorityFee != nil {
+		from := p.msg.From()
+		util.MintBalance(&from, arbmath.BigMulByUint(p.priorityFee, gasLeft), p.evm, scenario, "priorityFeeRefund")
+		util.MintBalance(&networkFeeAccount, arbmath.BigMulByUint(p.priorityFee, gasUsed), p.evm, scenario, purpose)
+	}
 	posterFeeDestination := p.evm.Context.Coinbase
 	if p.state.FormatVersion() >= 2 {
 		posterFeeDestination = l1pricing.L1PricerFundsPoolAddress
@@ -616,6 +643,9 @@ func (p *TxProcessor) GetPaidGasPrice() *big.Int {
 	if p.msg.RunMode() != types.MessageCommitMode && p.msg.GasFeeCap().Sign() == 0 {
 		gasPrice.SetInt64(0) // gasprice zero behavior
 	}
+	if p.priorityFee != nil {
+		return arbmath.BigAdd(gasPrice, p.priorityFee)
+	}
 	return gasPrice
 }
 
@@ -44,6 +44,12 @@ const ZeroheavyMessageHeaderFlag byte = 0x20
 // Indicates that the message is brotli-compressed.
 const BrotliMessageHeaderByte byte = 0
 
+// Indicates that the message is zstd-compressed.
+const ZstdMessageHeaderByte byte = 1
+
+// Zstd-compressed messages are only decoded from this ArbOS version on, before which they're of unknown format.
+const ZstdArbosVersion uint64 = 5
+
 func IsDASMessageHeaderByte(header byte) bool {
 	return (DASMessageHeaderFlag & header) > 0
 }
@@ -60,6 +66,10 @@ func IsBrotliMessageHeaderByte(b uint8) bool {
 	return b == BrotliMessageHeaderByte
 }
 
+func IsZstdMessageHeaderByte(b uint8) bool {
+	return b == ZstdMessageHeaderByte
+}
+
 type DataAvailabilityCertificate struct {
 	KeysetHash  [32]byte
 	DataHash    [32]byte
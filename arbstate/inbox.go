@@ -18,6 +18,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/klauspost/compress/zstd"
 
 	"github.com/offchainlabs/nitro/arbcompress"
 	"github.com/offchainlabs/nitro/arbos"
@@ -36,6 +37,10 @@ type InboxBackend interface {
 	ReadDelayedInbox(seqNum uint64) ([]byte, error)
 }
 
+// ArbOSVersionReader returns the ArbOS version of the chain before the sequencer message being read. It's only
+// called for messages whose decoding depends on the version.
+type ArbOSVersionReader func() (uint64, error)
+
 type MessageWithMetadata struct {
 	Message             *arbos.L1IncomingMessage `json:"message"`
 	DelayedMessagesRead uint64                   `json:"delayedMessagesRead"`
@@ -60,7 +65,7 @@ const maxZeroheavyDecompressedLen = 101*maxDecompressedLen/100 + 64
 const MaxSegmentsPerSequencerMessage = 100 * 1024
 const MinLifetimeSecondsForDataAvailabilityCert = 7 * 24 * 60 * 60 // one week
 
-func parseSequencerMessage(ctx context.Context, data []byte, dasReader DataAvailabilityReader) (*sequencerMessage, error) {
+func parseSequencerMessage(ctx context.Context, data []byte, dasReader DataAvailabilityReader, arbosVersion ArbOSVersionReader) (*sequencerMessage, error) {
 	if len(data) < 40 {
 		return nil, errors.New("sequencer message missing L1 header")
 	}
@@ -98,8 +103,25 @@ func parseSequencerMessage(ctx context.Context, data []byte, dasReader DataAvail
 		payload = pl
 	}
 
-	if len(payload) > 0 && IsBrotliMessageHeaderByte(payload[0]) {
-		decompressed, err := arbcompress.Decompress(payload[1:], maxDecompressedLen)
+	if len(payload) > 0 && IsZstdMessageHeaderByte(payload[0]) {
+		version, err := arbosVersion()
+		if err != nil {
+			return nil, err
+		}
+		if version < ZstdArbosVersion {
+			log.Warn("zstd compressed sequencer message before ArbOS supports it", "arbosVersion", version)
+			return parsedMsg, nil
+		}
+	}
+
+	if len(payload) > 0 && (IsBrotliMessageHeaderByte(payload[0]) || IsZstdMessageHeaderByte(payload[0])) {
+		var decompressed []byte
+		var err error
+		if IsZstdMessageHeaderByte(payload[0]) {
+			decompressed, err = decompressZstd(payload[1:], maxDecompressedLen)
+		} else {
+			decompressed, err = arbcompress.Decompress(payload[1:], maxDecompressedLen)
+		}
 		if err == nil {
 			reader := bytes.NewReader(decompressed)
 			stream := rlp.NewStream(reader, uint64(maxDecompressedLen))
@@ -128,6 +150,22 @@ func parseSequencerMessage(ctx context.Context, data []byte, dasReader DataAvail
 	return parsedMsg, nil
 }
 
+func decompressZstd(input []byte, maxSize int) ([]byte, error) {
+	decoder, err := zstd.NewReader(bytes.NewReader(input), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(decoder, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > maxSize {
+		return nil, errors.New("zstd decompressed data exceeds maximum size")
+	}
+	return decompressed, nil
+}
+
 func RecoverPayloadFromDasBatch(
 	ctx context.Context,
 	sequencerMsg []byte,
@@ -228,6 +266,7 @@ type inboxMultiplexer struct {
 	backend                   InboxBackend
 	delayedMessagesRead       uint64
 	dasReader                 DataAvailabilityReader
+	arbosVersion              ArbOSVersionReader
 	cachedSequencerMessage    *sequencerMessage
 	cachedSequencerMessageNum uint64
 	cachedSegmentNum          uint64
@@ -236,11 +275,12 @@ type inboxMultiplexer struct {
 	cachedSubMessageNumber    uint64
 }
 
-func NewInboxMultiplexer(backend InboxBackend, delayedMessagesRead uint64, dasReader DataAvailabilityReader) InboxMultiplexer {
+func NewInboxMultiplexer(backend InboxBackend, delayedMessagesRead uint64, dasReader DataAvailabilityReader, arbosVersion ArbOSVersionReader) InboxMultiplexer {
 	return &inboxMultiplexer{
 		backend:             backend,
 		delayedMessagesRead: delayedMessagesRead,
 		dasReader:           dasReader,
+		arbosVersion:        arbosVersion,
 	}
 }
 
@@ -266,7 +306,7 @@ func (r *inboxMultiplexer) Pop(ctx context.Context) (*MessageWithMetadata, error
 		}
 		r.cachedSequencerMessageNum = r.backend.GetSequencerInboxPosition()
 		var err error
-		r.cachedSequencerMessage, err = parseSequencerMessage(ctx, bytes, r.dasReader)
+		r.cachedSequencerMessage, err = parseSequencerMessage(ctx, bytes, r.dasReader, r.arbosVersion)
 		if err != nil {
 			return nil, err
 		}
@@ -46,6 +46,12 @@ func (b *multiplexerBackend) ReadDelayedInbox(seqNum uint64) ([]byte, error) {
 	return b.delayedMessage, nil
 }
 
+func testArbOSVersion(version uint64) ArbOSVersionReader {
+	return func() (uint64, error) {
+		return version, nil
+	}
+}
+
 func FuzzInboxMultiplexer(f *testing.F) {
 	f.Fuzz(func(t *testing.T, seqMsg []byte, delayedMsg []byte) {
 		if len(seqMsg) < 40 {
@@ -57,7 +63,7 @@ func FuzzInboxMultiplexer(f *testing.F) {
 			delayedMessage:        delayedMsg,
 			positionWithinMessage: 0,
 		}
-		multiplexer := NewInboxMultiplexer(backend, 0, nil)
+		multiplexer := NewInboxMultiplexer(backend, 0, nil, testArbOSVersion(ZstdArbosVersion))
 		_, err := multiplexer.Pop(context.TODO())
 		if err != nil {
 			panic(err)
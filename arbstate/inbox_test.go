@@ -0,0 +1,56 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/offchainlabs/nitro/arbos"
+)
+
+func zstdSequencerMessage(t *testing.T, l2Msg []byte) []byte {
+	segment, err := rlp.EncodeToBytes(append([]byte{BatchSegmentKindL2Message}, l2Msg...))
+	Require(t, err)
+	encoder, err := zstd.NewWriter(nil)
+	Require(t, err)
+	defer encoder.Close()
+	header := make([]byte, 40)
+	binary.BigEndian.PutUint64(header[8:16], ^uint64(0))
+	binary.BigEndian.PutUint64(header[24:32], ^uint64(0))
+	return append(append(header, ZstdMessageHeaderByte), encoder.EncodeAll(segment, nil)...)
+}
+
+// Replaying a zstd batch must yield what older nodes did before the ArbOS version supporting it
+func TestZstdBatchArbOSVersion(t *testing.T) {
+	l2Msg := []byte("l2 message")
+	batch := zstdSequencerMessage(t, l2Msg)
+	pop := func(arbosVersion ArbOSVersionReader) (*MessageWithMetadata, error) {
+		backend := &multiplexerBackend{batch: batch}
+		return NewInboxMultiplexer(backend, 0, nil, arbosVersion).Pop(context.Background())
+	}
+
+	msg, err := pop(testArbOSVersion(ZstdArbosVersion - 1))
+	Require(t, err)
+	if msg.Message.Header.Kind != arbos.L1MessageType_Invalid {
+		Fail(t, "zstd batch decoded before ArbOS supports it")
+	}
+
+	msg, err = pop(testArbOSVersion(ZstdArbosVersion))
+	Require(t, err)
+	if msg.Message.Header.Kind != arbos.L1MessageType_L2Message || !bytes.Equal(msg.Message.L2msg, l2Msg) {
+		Fail(t, "zstd batch not decoded", msg.Message.Header.Kind, string(msg.Message.L2msg))
+	}
+
+	versionErr := errors.New("version unknown")
+	if _, err := pop(func() (uint64, error) { return 0, versionErr }); !errors.Is(err, versionErr) {
+		Fail(t, "error reading the ArbOS version not returned", err)
+	}
+}
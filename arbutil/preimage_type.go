@@ -0,0 +1,29 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbutil
+
+import "fmt"
+
+// PreimageType identifies the hash function, or commitment scheme, a preimage is looked up by.
+type PreimageType uint8
+
+const (
+	Keccak256PreimageType PreimageType = iota
+	Sha2_256PreimageType
+	// EthVersionedHashPreimageType looks up an EIP-4844 blob by the versioned hash of its KZG commitment.
+	EthVersionedHashPreimageType
+)
+
+func (t PreimageType) String() string {
+	switch t {
+	case Keccak256PreimageType:
+		return "keccak256"
+	case Sha2_256PreimageType:
+		return "sha2-256"
+	case EthVersionedHashPreimageType:
+		return "eth-versioned-hash"
+	default:
+		return fmt.Sprintf("preimage-type-%d", uint8(t))
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pkg/errors"
+
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+// OpenFeedArchive opens the feed archive at path for recording to, creating it if it doesn't exist. An archive is
+// a feed recording which is only ever appended to: each session recorded continues from the offset of the last
+// event, so the archive replays as one session with the time between recordings left out.
+func OpenFeedArchive(path string) (*FeedRecorder, error) {
+	lastOffset, err := repairFeedArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open feed archive")
+	}
+	recorder := NewFeedRecorder(file)
+	recorder.start = recorder.start.Add(-lastOffset)
+	return recorder, nil
+}
+
+// repairFeedArchive returns the offset of the archive's last event, first truncating a partial event left by a
+// crash so the next one appended can be read.
+func repairFeedArchive(path string) (time.Duration, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	var lastOffset time.Duration
+	var end int64
+	for {
+		var event FeedEvent
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			return lastOffset, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Warn("truncating partial last event of feed archive", "path", path, "offset", end)
+			return lastOffset, os.Truncate(path, end)
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "unable to read feed archive")
+		}
+		lastOffset = event.Offset
+		end = decoder.InputOffset()
+	}
+}
+
+// FeedReplayer replays a feed archive into a node's transaction streamer in place of a live feed, to reproduce
+// the traffic it was recorded from. Speed scales how fast the messages are sent compared to when they were
+// recorded, with 0 sending them as fast as they're consumed.
+type FeedReplayer struct {
+	stopwaiter.StopWaiter
+	path       string
+	speed      float64
+	txStreamer TransactionStreamerInterface
+}
+
+func NewFeedReplayer(path string, speed float64, txStreamer TransactionStreamerInterface) *FeedReplayer {
+	return &FeedReplayer{
+		path:       path,
+		speed:      speed,
+		txStreamer: txStreamer,
+	}
+}
+
+func (r *FeedReplayer) Start(ctxIn context.Context) error {
+	r.StopWaiter.Start(ctxIn)
+	file, err := os.Open(r.path)
+	if err != nil {
+		return errors.Wrap(err, "unable to open feed archive")
+	}
+	log.Info("replaying feed archive", "path", r.path, "speed", r.speed)
+	// The archive is read as it's replayed, as it's only ever appended to and may not fit in memory
+	r.LaunchThread(func(ctx context.Context) {
+		defer file.Close()
+		start := time.Now()
+		err := ReplayFeedRecording(ctx, NewFeedRecordingReader(file), r.txStreamer, nil, r.speed)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("failed to replay feed archive", "path", r.path, "err", err)
+			}
+			return
+		}
+		log.Info("finished replaying feed archive", "path", r.path, "elapsed", time.Since(start))
+	})
+	return nil
+}
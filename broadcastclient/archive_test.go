@@ -0,0 +1,133 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+func TestFeedArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.archive")
+
+	first, err := OpenFeedArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.recordEvent(feedEventConnect)
+	first.recordMessage([]byte(`{"version":1}`))
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while recording leaves a partial event, which reopening the archive drops
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"offset":`); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	second, err := OpenFeedArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.recordMessage([]byte(`{"version":1}`))
+	second.recordEvent(feedEventDisconnect)
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, err := ReadFeedRecording(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []string{feedEventConnect, feedEventMessage, feedEventMessage, feedEventDisconnect}
+	if len(events) != len(kinds) {
+		t.Fatal("archive has", len(events), "events, expected", len(kinds))
+	}
+	for i, event := range events {
+		if event.Kind != kinds[i] {
+			t.Fatal("event", i, "is a", event.Kind, "expected", kinds[i])
+		}
+		if i > 0 && event.Offset < events[i-1].Offset {
+			t.Fatal("event", i, "offset", event.Offset, "before the previous event's", events[i-1].Offset)
+		}
+	}
+	// the time between the recordings is left out
+	if gap := events[2].Offset - events[1].Offset; gap >= 10*time.Millisecond {
+		t.Fatal("archive includes the", gap, "between recordings")
+	}
+}
+
+func TestFeedReplayer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "feed.archive")
+
+	archive, err := OpenFeedArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.recordEvent(feedEventConnect)
+	const messages = 100
+	for i := 0; i < messages; i++ {
+		message, err := json.Marshal(broadcaster.BroadcastMessage{
+			Version: 1,
+			Messages: []*broadcaster.BroadcastFeedMessage{{
+				SequenceNumber: arbutil.MessageIndex(i),
+				Message:        arbstate.MessageWithMetadata{DelayedMessagesRead: uint64(i)},
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive.recordMessage(message)
+	}
+	archive.recordEvent(feedEventDisconnect)
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	streamer := &collectingTransactionStreamer{}
+	replayer := NewFeedReplayer(path, 0, streamer)
+	if err := replayer.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.StopAndWait()
+	for start := time.Now(); streamer.count() < messages; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("replayed", streamer.count(), "of", messages, "messages")
+		}
+	}
+	streamer.mutex.Lock()
+	defer streamer.mutex.Unlock()
+	for i, message := range streamer.messages {
+		if message.SequenceNumber != arbutil.MessageIndex(i) || message.Message.DelayedMessagesRead != uint64(i) {
+			t.Fatal("replayed message", i, "differs:", message)
+		}
+	}
+
+	if err := NewFeedReplayer(filepath.Join(t.TempDir(), "missing"), 0, streamer).Start(ctx); err == nil {
+		t.Fatal("replaying a missing archive succeeded")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"io"
 	"math/big"
 	"net"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -23,6 +25,7 @@ import (
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/grpcfeed"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 	"github.com/offchainlabs/nitro/wsbroadcastserver"
 )
@@ -30,6 +33,7 @@ import (
 type FeedConfig struct {
 	Output wsbroadcastserver.BroadcasterConfig `koanf:"output"`
 	Input  BroadcastClientConfig               `koanf:"input"`
+	GRPC   grpcfeed.Config                     `koanf:"grpc"`
 }
 
 func FeedConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool, feedOutputEnable bool) {
@@ -38,17 +42,25 @@ func FeedConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool,
 	}
 	if feedOutputEnable {
 		wsbroadcastserver.BroadcasterConfigAddOptions(prefix+".output", f)
+		grpcfeed.ConfigAddOptions(prefix+".grpc", f)
 	}
 }
 
 var FeedConfigDefault = FeedConfig{
 	Output: wsbroadcastserver.DefaultBroadcasterConfig,
 	Input:  DefaultBroadcastClientConfig,
+	GRPC:   grpcfeed.DefaultConfig,
 }
 
 type BroadcastClientConfig struct {
-	Timeout time.Duration `koanf:"timeout"`
-	URLs    []string      `koanf:"url"`
+	Timeout     time.Duration           `koanf:"timeout"`
+	URLs        []string                `koanf:"url"`
+	RecordPath  string                  `koanf:"record-path"`
+	ReplayPath  string                  `koanf:"replay-path"`
+	ReplaySpeed float64                 `koanf:"replay-speed"`
+	Compression string                  `koanf:"compression"`
+	Token       string                  `koanf:"token"`
+	Verify      SignatureVerifierConfig `koanf:"verify"`
 }
 
 func (c *BroadcastClientConfig) Enable() bool {
@@ -56,19 +68,37 @@ func (c *BroadcastClientConfig) Enable() bool {
 }
 
 func BroadcastClientConfigAddOptions(prefix string, f *flag.FlagSet) {
-	f.StringSlice(prefix+".url", DefaultBroadcastClientConfig.URLs, "URL of sequencer feed source")
+	f.StringSlice(prefix+".url", DefaultBroadcastClientConfig.URLs, "URL of sequencer feed source (several are connected to at once, following whichever is fastest)")
 	f.Duration(prefix+".timeout", DefaultBroadcastClientConfig.Timeout, "duration to wait before timing out connection to sequencer feed")
+	f.String(prefix+".record-path", DefaultBroadcastClientConfig.RecordPath, "file to record the feed session to, for replaying in tests (suffixed with the URL's index if there are several)")
+	f.String(prefix+".replay-path", DefaultBroadcastClientConfig.ReplayPath, "feed archive to replay into the node in place of a live feed, as recorded by nitro feed record")
+	f.Float64(prefix+".replay-speed", DefaultBroadcastClientConfig.ReplaySpeed, "how fast to replay the feed archive compared to when it was recorded (0 for as fast as possible)")
+	f.String(prefix+".compression", DefaultBroadcastClientConfig.Compression, "compression to request of the feed (\"none\", \"deflate\" or \"snappy\"), used if the server supports it")
+	f.String(prefix+".token", DefaultBroadcastClientConfig.Token, "token to present to feeds which require authentication")
+	SignatureVerifierConfigAddOptions(prefix+".verify", f)
 }
 
 var DefaultBroadcastClientConfig = BroadcastClientConfig{
-	URLs:    []string{""},
-	Timeout: 20 * time.Second,
+	URLs:        []string{""},
+	Timeout:     20 * time.Second,
+	RecordPath:  "",
+	ReplayPath:  "",
+	ReplaySpeed: 1,
+	Compression: "deflate",
+	Token:       "",
+	Verify:      DefaultSignatureVerifierConfig,
 }
 
 type TransactionStreamerInterface interface {
 	AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error
 }
 
+// FeedMessageReceiver is implemented by transaction streamers which want the feed messages as sent,
+// signatures included, such as the relay's, to pass them on.
+type FeedMessageReceiver interface {
+	AddBroadcastFeedMessages(messages []*broadcaster.BroadcastFeedMessage) error
+}
+
 type BroadcastClient struct {
 	stopwaiter.StopWaiter
 
@@ -84,8 +114,16 @@ type BroadcastClient struct {
 	retrying                        bool
 	shuttingDown                    bool
 	ConfirmedSequenceNumberListener chan arbutil.MessageIndex
+	AdvisoryListener                chan *broadcaster.AdvisoryMessage
 	idleTimeout                     time.Duration
 	txStreamer                      TransactionStreamerInterface
+	recorder                        *FeedRecorder                 // nil unless recording
+	compression                     wsbroadcastserver.Compression // requested
+	negotiatedCompression           wsbroadcastserver.Compression // accepted by the server on the current connection
+	token                           string
+	verifier                        *SignatureVerifier   // nil unless messages' signatures are checked
+	nextSeqNum                      arbutil.MessageIndex // after the last message received, or 0 if none has been
+	metrics                         *clientMetrics       // nil for replayed feeds
 }
 
 func NewBroadcastClient(websocketUrl string, lastInboxSeqNum *big.Int, idleTimeout time.Duration, txStreamer TransactionStreamerInterface) *BroadcastClient {
@@ -96,12 +134,34 @@ func NewBroadcastClient(websocketUrl string, lastInboxSeqNum *big.Int, idleTimeo
 		seqNum = lastInboxSeqNum
 	}
 
-	return &BroadcastClient{
+	bc := &BroadcastClient{
 		websocketUrl:    websocketUrl,
 		lastInboxSeqNum: seqNum,
 		idleTimeout:     idleTimeout,
 		txStreamer:      txStreamer,
+		metrics:         newClientMetrics(websocketUrl),
+	}
+	if lastInboxSeqNum != nil {
+		// resuming, so ask for the messages after it as the client connects
+		bc.nextSeqNum = arbutil.MessageIndex(lastInboxSeqNum.Uint64() + 1)
 	}
+	return bc
+}
+
+// SetCompression sets the compression to request of the feed. It must be called before the client is started.
+func (bc *BroadcastClient) SetCompression(compression wsbroadcastserver.Compression) {
+	bc.compression = compression
+}
+
+// SetToken sets the token presented to a feed requiring authentication. It must be called before the client is started.
+func (bc *BroadcastClient) SetToken(token string) {
+	bc.token = token
+}
+
+// SetSignatureVerifier has the client reject messages not signed by the sequencer. It must be called before the
+// client is started.
+func (bc *BroadcastClient) SetSignatureVerifier(verifier *SignatureVerifier) {
+	bc.verifier = verifier
 }
 
 func (bc *BroadcastClient) Start(ctxIn context.Context) {
@@ -137,13 +197,25 @@ func (bc *BroadcastClient) connect(ctx context.Context) (earlyFrameData io.Reade
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		},
+		Extensions: bc.compression.Extensions(),
+	}
+	header := http.Header{}
+	if bc.token != "" {
+		header.Set("Authorization", "Bearer "+bc.token)
+	}
+	if bc.nextSeqNum > 0 {
+		// resuming, so ask for the messages after those already received
+		header.Set(wsbroadcastserver.RequestedSeqNumHeader, strconv.FormatUint(uint64(bc.nextSeqNum), 10))
+	}
+	if len(header) > 0 {
+		timeoutDialer.Header = ws.HandshakeHeaderHTTP(header)
 	}
 
 	if bc.isShuttingDown() {
 		return
 	}
 
-	conn, br, _, err := timeoutDialer.Dial(ctx, bc.websocketUrl)
+	conn, br, hs, err := timeoutDialer.Dial(ctx, bc.websocketUrl)
 	if err != nil {
 		return nil, errors.Wrap(err, "broadcast client unable to connect")
 	}
@@ -161,9 +233,11 @@ func (bc *BroadcastClient) connect(ctx context.Context) (earlyFrameData io.Reade
 
 	bc.connMutex.Lock()
 	bc.conn = conn
+	bc.negotiatedCompression = wsbroadcastserver.NegotiatedCompression(hs.Extensions)
 	bc.connMutex.Unlock()
+	bc.recorder.recordEvent(feedEventConnect)
 
-	log.Info("Connected")
+	log.Info("Connected", "compression", bc.negotiatedCompression)
 
 	return
 }
@@ -177,7 +251,7 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 			default:
 			}
 
-			msg, op, err := wsbroadcastserver.ReadData(ctx, bc.conn, earlyFrameData, bc.idleTimeout, ws.StateClientSide)
+			msg, op, err := wsbroadcastserver.ReadData(ctx, bc.conn, earlyFrameData, bc.idleTimeout, ws.StateClientSide, bc.negotiatedCompression)
 			if err != nil {
 				if bc.isShuttingDown() {
 					return
@@ -190,47 +264,101 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 					log.Error("error calling readData", "url", bc.websocketUrl, "opcode", int(op), "err", err)
 				}
 				_ = bc.conn.Close()
+				bc.recorder.recordEvent(feedEventDisconnect)
 				earlyFrameData = bc.retryConnect(ctx)
 				continue
 			}
 
 			if msg != nil {
-				res := broadcaster.BroadcastMessage{}
-				err = json.Unmarshal(msg, &res)
-				if err != nil {
-					log.Error("error unmarshalling message", "msg", msg, "err", err)
-					continue
-				}
+				bc.recorder.recordMessage(msg)
+				bc.handleMessage(ctx, msg)
+			}
+		}
+	})
+}
 
-				if len(res.Messages) > 0 {
-					log.Debug("received batch item", "count", len(res.Messages), "first seq", res.Messages[0].SequenceNumber)
-				} else if res.ConfirmedSequenceNumberMessage != nil {
-					log.Debug("confirmed sequence number", "seq", res.ConfirmedSequenceNumberMessage.SequenceNumber)
-				} else {
-					log.Debug("received broadcast with no messages populated", "length", len(msg))
-				}
+// handleMessage passes the messages in a feed message to the transaction streamer,
+// and its confirmed sequence number to the listener.
+func (bc *BroadcastClient) handleMessage(ctx context.Context, msg []byte) {
+	res := broadcaster.BroadcastMessage{}
+	err := json.Unmarshal(msg, &res)
+	if err != nil {
+		log.Error("error unmarshalling message", "msg", msg, "err", err)
+		return
+	}
 
-				if res.Version == 1 {
-					if len(res.Messages) > 0 {
-						messages := []arbstate.MessageWithMetadata{}
-						for _, message := range res.Messages {
-							if message == nil {
-								log.Warn("ignoring nil feed message")
-								continue
-							}
-							messages = append(messages, message.Message)
-						}
-						if err := bc.txStreamer.AddBroadcastMessages(res.Messages[0].SequenceNumber, messages); err != nil {
-							log.Error("Error adding message from Sequencer Feed", "err", err)
-						}
-					}
-					if res.ConfirmedSequenceNumberMessage != nil && bc.ConfirmedSequenceNumberListener != nil {
-						bc.ConfirmedSequenceNumberListener <- res.ConfirmedSequenceNumberMessage.SequenceNumber
+	if len(res.Messages) > 0 {
+		log.Debug("received batch item", "count", len(res.Messages), "first seq", res.Messages[0].SequenceNumber)
+	} else if res.ConfirmedSequenceNumberMessage != nil {
+		log.Debug("confirmed sequence number", "seq", res.ConfirmedSequenceNumberMessage.SequenceNumber)
+	} else if res.AdvisoryMessage != nil {
+		log.Debug("received advisories", "count", len(res.AdvisoryMessage.Advisories))
+	} else if res.BackfillMessage != nil {
+		log.Debug("received backfill", "first seq", res.BackfillMessage.FirstSequenceNumber)
+	} else {
+		log.Debug("received broadcast with no messages populated", "length", len(msg))
+	}
+
+	if res.Version == 1 {
+		now := time.Now()
+		if len(res.Messages) > 0 {
+			feedMessages := []*broadcaster.BroadcastFeedMessage{}
+			messages := []arbstate.MessageWithMetadata{}
+			for _, message := range res.Messages {
+				if message == nil {
+					log.Warn("ignoring nil feed message")
+					continue
+				}
+				if bc.verifier != nil {
+					if err := bc.verifier.Verify(ctx, message); err != nil {
+						// the messages after it wouldn't follow on from those before, so drop them too
+						invalidSignatureCounter.Inc(1)
+						log.Error("rejecting feed message with a bad signature", "url", bc.websocketUrl, "seqNum", message.SequenceNumber, "err", err)
+						break
 					}
 				}
+				feedMessages = append(feedMessages, message)
+				messages = append(messages, message.Message)
+				bc.metrics.received(message, now)
+				if bc.nextSeqNum > 0 && message.SequenceNumber > bc.nextSeqNum {
+					bc.metrics.gap(bc.nextSeqNum, message.SequenceNumber)
+				}
+				if message.SequenceNumber+1 > bc.nextSeqNum {
+					bc.nextSeqNum = message.SequenceNumber + 1
+				}
+			}
+			var err error
+			if receiver, ok := bc.txStreamer.(FeedMessageReceiver); ok {
+				err = receiver.AddBroadcastFeedMessages(feedMessages)
+			} else if len(messages) > 0 {
+				err = bc.txStreamer.AddBroadcastMessages(res.Messages[0].SequenceNumber, messages)
+			}
+			if err != nil {
+				log.Error("Error adding message from Sequencer Feed", "err", err)
 			}
 		}
-	})
+		if res.BackfillMessage != nil {
+			bc.metrics.fellBackToL1()
+			log.Warn(
+				"feed no longer has all the messages missed while disconnected; the rest must come from its archive or L1",
+				"url", bc.websocketUrl,
+				"requested", res.BackfillMessage.RequestedSequenceNumber,
+				"first", res.BackfillMessage.FirstSequenceNumber,
+				"archive", res.BackfillMessage.ArchiveURL,
+			)
+		}
+		if res.ConfirmedSequenceNumberMessage != nil && bc.ConfirmedSequenceNumberListener != nil {
+			bc.ConfirmedSequenceNumberListener <- res.ConfirmedSequenceNumberMessage.SequenceNumber
+		}
+		if res.AdvisoryMessage != nil && bc.AdvisoryListener != nil {
+			// advisories are superseded by the next, so don't hold up the feed for a slow listener
+			select {
+			case bc.AdvisoryListener <- res.AdvisoryMessage:
+			default:
+				log.Warn("dropping feed advisories as the listener is behind")
+			}
+		}
+	}
 }
 
 func (bc *BroadcastClient) GetRetryCount() int64 {
@@ -261,6 +389,7 @@ func (bc *BroadcastClient) retryConnect(ctx context.Context) io.Reader {
 		earlyFrameData, err := bc.connect(ctx)
 		if err == nil {
 			bc.retrying = false
+			bc.metrics.reconnected()
 			return earlyFrameData
 		}
 
@@ -281,4 +410,7 @@ func (bc *BroadcastClient) StopAndWait() {
 	if bc.conn != nil {
 		_ = bc.conn.Close()
 	}
+	if err := bc.recorder.Close(); err != nil {
+		log.Warn("failed to close feed recording", "err", err)
+	}
 }
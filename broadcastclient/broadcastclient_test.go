@@ -4,6 +4,7 @@
 package broadcastclient
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -37,7 +38,7 @@ func TestReceiveMessages(t *testing.T) {
 
 	var wg sync.WaitGroup
 	for i := 0; i < clientCount; i++ {
-		startMakeBroadcastClient(ctx, t, b.ListenerAddr(), i, messageCount, &wg)
+		startMakeBroadcastClient(ctx, t, b.ListenerAddr(), i, messageCount, wsbroadcastserver.CompressionNone, &wg)
 	}
 
 	go func() {
@@ -50,6 +51,41 @@ func TestReceiveMessages(t *testing.T) {
 
 }
 
+func TestReceiveCompressedMessages(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	settings := wsbroadcastserver.DefaultTestBroadcasterConfig
+	settings.EnableCompression = true
+	settings.CompressionMinSize = 0
+
+	messageCount := 100
+
+	b := broadcaster.NewBroadcaster(settings)
+
+	err := b.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.StopAndWait()
+
+	// clients with each compression are sent the same messages, each compressed their own way
+	var wg sync.WaitGroup
+	compressions := []wsbroadcastserver.Compression{wsbroadcastserver.CompressionNone, wsbroadcastserver.CompressionDeflate, wsbroadcastserver.CompressionSnappy}
+	for i, compression := range compressions {
+		startMakeBroadcastClient(ctx, t, b.ListenerAddr(), i, messageCount, compression, &wg)
+	}
+
+	go func() {
+		for i := 0; i < messageCount; i++ {
+			b.BroadcastSingle(arbstate.MessageWithMetadata{}, arbutil.MessageIndex(i))
+		}
+	}()
+
+	wg.Wait()
+}
+
 type dummyTransactionStreamer struct {
 	messageReceiver chan broadcaster.BroadcastFeedMessage
 }
@@ -75,9 +111,10 @@ func newTestBroadcastClient(listenerAddress net.Addr, idleTimeout time.Duration,
 	return NewBroadcastClient(fmt.Sprintf("ws://127.0.0.1:%d/", port), nil, idleTimeout, txStreamer)
 }
 
-func startMakeBroadcastClient(ctx context.Context, t *testing.T, addr net.Addr, index int, expectedCount int, wg *sync.WaitGroup) {
+func startMakeBroadcastClient(ctx context.Context, t *testing.T, addr net.Addr, index int, expectedCount int, compression wsbroadcastserver.Compression, wg *sync.WaitGroup) {
 	ts := NewDummyTransactionStreamer()
 	broadcastClient := newTestBroadcastClient(addr, 20*time.Second, ts)
+	broadcastClient.SetCompression(compression)
 	broadcastClient.Start(ctx)
 	messageCount := 0
 
@@ -259,6 +296,63 @@ func TestBroadcasterSendsCachedMessagesOnClientConnect(t *testing.T) {
 	}
 }
 
+func TestBroadcasterBackfillsReconnectingClients(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	settings := wsbroadcastserver.DefaultTestBroadcasterConfig
+	settings.Backfill = 2
+
+	b := broadcaster.NewBroadcaster(settings)
+
+	err := b.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.StopAndWait()
+
+	for i := 1; i <= 6; i++ {
+		b.BroadcastSingle(arbstate.MessageWithMetadata{}, arbutil.MessageIndex(i))
+	}
+	b.Confirm(4)
+
+	updateTimer := time.NewTimer(2 * time.Second)
+	defer updateTimer.Stop()
+	for b.GetCachedMessageCount() != 2 {
+		select {
+		case <-updateTimer.C:
+			t.Fatal("confirmation not processed")
+		default:
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// confirmed messages 3 and 4 are kept for backfill, and earlier ones evicted
+	for nextSeqNum, expectedFirst := range map[arbutil.MessageIndex]arbutil.MessageIndex{
+		0: 5, // a new client is only sent unconfirmed messages
+		4: 4,
+		1: 3,
+	} {
+		ts := NewDummyTransactionStreamer()
+		broadcastClient := newTestBroadcastClient(b.ListenerAddr(), 20*time.Second, ts)
+		broadcastClient.nextSeqNum = nextSeqNum
+		broadcastClient.Start(ctx)
+		for expected := expectedFirst; expected <= 6; expected++ {
+			timer := time.NewTimer(5 * time.Second)
+			select {
+			case received := <-ts.messageReceiver:
+				if received.SequenceNumber != expected {
+					t.Fatalf("client reconnecting at %d received message %d, expected %d", nextSeqNum, received.SequenceNumber, expected)
+				}
+			case <-timer.C:
+				t.Fatalf("client reconnecting at %d did not receive message %d", nextSeqNum, expected)
+			}
+			timer.Stop()
+		}
+		broadcastClient.StopAndWait()
+	}
+}
+
 func connectAndGetCachedMessages(ctx context.Context, addr net.Addr, t *testing.T, clientIndex int, wg *sync.WaitGroup) {
 	ts := NewDummyTransactionStreamer()
 	broadcastClient := newTestBroadcastClient(addr, 60*time.Second, ts)
@@ -302,3 +396,92 @@ func connectAndGetCachedMessages(ctx context.Context, addr net.Addr, t *testing.
 
 	}()
 }
+
+type collectingTransactionStreamer struct {
+	mutex    sync.Mutex
+	messages []broadcaster.BroadcastFeedMessage
+}
+
+func (ts *collectingTransactionStreamer) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	for i, message := range messages {
+		ts.messages = append(ts.messages, broadcaster.BroadcastFeedMessage{
+			SequenceNumber: pos + arbutil.MessageIndex(i),
+			Message:        message,
+		})
+	}
+	return nil
+}
+
+func (ts *collectingTransactionStreamer) count() int {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return len(ts.messages)
+}
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestRecordAndReplayFeed(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := broadcaster.NewBroadcaster(wsbroadcastserver.DefaultTestBroadcasterConfig)
+	err := b.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.StopAndWait()
+
+	live := &collectingTransactionStreamer{}
+	broadcastClient := newTestBroadcastClient(b.ListenerAddr(), 20*time.Second, live)
+	recording := nopCloser{new(bytes.Buffer)}
+	broadcastClient.SetRecorder(NewFeedRecorder(recording))
+	broadcastClient.Start(ctx)
+
+	// Wait for the connection, so the messages are sent live rather than from the catchup buffer
+	for start := time.Now(); b.ClientCount() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("broadcast client didn't connect")
+		}
+	}
+	// Include a duplicate and a gap, as the replay must reproduce them
+	sequenceNumbers := []arbutil.MessageIndex{0, 1, 1, 2, 5, 6}
+	for _, seqNum := range sequenceNumbers {
+		b.BroadcastSingle(arbstate.MessageWithMetadata{DelayedMessagesRead: uint64(seqNum)}, seqNum)
+	}
+	for start := time.Now(); live.count() < len(sequenceNumbers); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("received", live.count(), "of", len(sequenceNumbers), "messages")
+		}
+	}
+	broadcastClient.StopAndWait()
+
+	events, err := ReadFeedRecording(recording.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[0].Kind != feedEventConnect {
+		t.Fatal("recording doesn't start with a connection, but", events[0].Kind)
+	}
+	replayed := &collectingTransactionStreamer{}
+	err = ReplayFeed(ctx, events, replayed, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed.messages) != len(live.messages) {
+		t.Fatal("replayed", len(replayed.messages), "messages, but received", len(live.messages))
+	}
+	for i, message := range replayed.messages {
+		if message.SequenceNumber != live.messages[i].SequenceNumber || message.Message.DelayedMessagesRead != live.messages[i].Message.DelayedMessagesRead {
+			t.Fatal("replayed message", i, "differs:", message, "received", live.messages[i])
+		}
+	}
+}
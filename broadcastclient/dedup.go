@@ -0,0 +1,164 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+var (
+	dedupFirstCounter     = metrics.NewRegisteredCounter("arb/feed/client/dedup/first", nil)
+	dedupDuplicateCounter = metrics.NewRegisteredCounter("arb/feed/client/dedup/duplicate", nil)
+)
+
+// How many sequence numbers back duplicates are recognized, which should cover a reconnecting feed's catchup
+const dedupWindow = 10_000
+
+// dedupKey identifies a message by its contents as well as its position, so feeds disagreeing on a position
+// each have their message passed on, for the streamer to resolve.
+type dedupKey struct {
+	seqNum arbutil.MessageIndex
+	hash   common.Hash
+}
+
+// Deduplicator sits between the clients of several feeds, connected at once, and the transaction streamer. It
+// passes each message on from whichever feed delivers it first, so the lowest latency feed is the one followed,
+// and if it fails the others carry on without a gap.
+type Deduplicator struct {
+	txStreamer TransactionStreamerInterface
+	// held while a source's messages are filtered and passed on, so a message is only seen once accepted
+	deliveryMutex sync.Mutex
+	accepted      map[dedupKey]struct{}
+	highest       arbutil.MessageIndex
+}
+
+func NewDeduplicator(txStreamer TransactionStreamerInterface) *Deduplicator {
+	return &Deduplicator{
+		txStreamer: txStreamer,
+		accepted:   make(map[dedupKey]struct{}),
+	}
+}
+
+// dedupSource is the transaction streamer a feed's client is given, passing its messages through the deduplicator.
+type dedupSource struct {
+	deduplicator *Deduplicator
+}
+
+// Source returns the transaction streamer to give the client of a feed.
+func (d *Deduplicator) Source() TransactionStreamerInterface {
+	return &dedupSource{deduplicator: d}
+}
+
+func (s *dedupSource) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
+	feedMessages := make([]*broadcaster.BroadcastFeedMessage, 0, len(messages))
+	for i, message := range messages {
+		feedMessages = append(feedMessages, &broadcaster.BroadcastFeedMessage{
+			SequenceNumber: pos + arbutil.MessageIndex(i),
+			Message:        message,
+		})
+	}
+	return s.AddBroadcastFeedMessages(feedMessages)
+}
+
+func (s *dedupSource) AddBroadcastFeedMessages(messages []*broadcaster.BroadcastFeedMessage) error {
+	d := s.deduplicator
+	d.deliveryMutex.Lock()
+	defer d.deliveryMutex.Unlock()
+	fresh, keys := d.filter(messages)
+	if len(fresh) == 0 {
+		return nil
+	}
+	if receiver, ok := d.txStreamer.(FeedMessageReceiver); ok {
+		if err := receiver.AddBroadcastFeedMessages(fresh); err != nil {
+			return err
+		}
+		d.accept(fresh, keys)
+		return nil
+	}
+	// pass the messages on in contiguous runs, as the streamer expects
+	start := 0
+	for i := 1; i <= len(fresh); i++ {
+		if i < len(fresh) && fresh[i].SequenceNumber == fresh[i-1].SequenceNumber+1 {
+			continue
+		}
+		run := make([]arbstate.MessageWithMetadata, 0, i-start)
+		for _, message := range fresh[start:i] {
+			run = append(run, message.Message)
+		}
+		if err := d.txStreamer.AddBroadcastMessages(fresh[start].SequenceNumber, run); err != nil {
+			// another feed's copy of the rejected messages may yet be accepted
+			return err
+		}
+		d.accept(fresh[start:i], keys[start:i])
+		start = i
+	}
+	return nil
+}
+
+func messageKey(message *broadcaster.BroadcastFeedMessage) (dedupKey, bool) {
+	data, err := rlp.EncodeToBytes(message.Message)
+	if err != nil {
+		return dedupKey{}, false
+	}
+	return dedupKey{seqNum: message.SequenceNumber, hash: crypto.Keccak256Hash(data)}, true
+}
+
+// filter returns the messages which haven't been accepted before, along with their keys.
+func (d *Deduplicator) filter(messages []*broadcaster.BroadcastFeedMessage) ([]*broadcaster.BroadcastFeedMessage, []*dedupKey) {
+	var fresh []*broadcaster.BroadcastFeedMessage
+	var keys []*dedupKey
+	for _, message := range messages {
+		seqNum := message.SequenceNumber
+		key, ok := messageKey(message)
+		if !ok || (d.highest >= dedupWindow && seqNum <= d.highest-dedupWindow) {
+			// can't tell whether it's a duplicate, so let the streamer sort it out
+			fresh = append(fresh, message)
+			keys = append(keys, nil)
+			continue
+		}
+		if _, seen := d.accepted[key]; seen {
+			dedupDuplicateCounter.Inc(1)
+			continue
+		}
+		fresh = append(fresh, message)
+		keys = append(keys, &key)
+	}
+	return fresh, keys
+}
+
+// accept records messages the streamer accepted, so other feeds' copies of them are dropped.
+func (d *Deduplicator) accept(messages []*broadcaster.BroadcastFeedMessage, keys []*dedupKey) {
+	for i, message := range messages {
+		if keys[i] == nil {
+			continue
+		}
+		dedupFirstCounter.Inc(1)
+		d.accepted[*keys[i]] = struct{}{}
+		if message.SequenceNumber > d.highest {
+			d.highest = message.SequenceNumber
+		}
+	}
+	d.prune()
+}
+
+// prune forgets the messages outside the window, once there are twice as many as the window holds.
+func (d *Deduplicator) prune() {
+	if len(d.accepted) < 2*dedupWindow || d.highest < dedupWindow {
+		return
+	}
+	for key := range d.accepted {
+		if key.seqNum <= d.highest-dedupWindow {
+			delete(d.accepted, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+type recordingStreamer struct {
+	positions []arbutil.MessageIndex
+	counts    []int
+	reject    bool
+}
+
+func (s *recordingStreamer) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
+	if s.reject {
+		return errors.New("rejected")
+	}
+	s.positions = append(s.positions, pos)
+	s.counts = append(s.counts, len(messages))
+	return nil
+}
+
+func feedMessages(seqNums ...arbutil.MessageIndex) []*broadcaster.BroadcastFeedMessage {
+	var messages []*broadcaster.BroadcastFeedMessage
+	for _, seqNum := range seqNums {
+		messages = append(messages, &broadcaster.BroadcastFeedMessage{SequenceNumber: seqNum})
+	}
+	return messages
+}
+
+func TestDeduplicateFeeds(t *testing.T) {
+	streamer := &recordingStreamer{}
+	d := NewDeduplicator(streamer)
+	fast := d.Source().(*dedupSource)
+	slow := d.Source().(*dedupSource)
+
+	for i := arbutil.MessageIndex(1); i <= 20; i++ {
+		if err := fast.AddBroadcastFeedMessages(feedMessages(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := slow.AddBroadcastFeedMessages(feedMessages(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(streamer.positions) != 20 {
+		t.Fatal("duplicates passed on", len(streamer.positions))
+	}
+
+	// the fast feed fails, and the slow one carries on
+	for i := arbutil.MessageIndex(21); i <= 40; i++ {
+		if err := slow.AddBroadcastFeedMessages(feedMessages(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(streamer.positions) != 40 || streamer.positions[39] != 40 {
+		t.Fatal("messages lost failing over", len(streamer.positions))
+	}
+}
+
+func TestDeduplicatorKeysOnContents(t *testing.T) {
+	streamer := &recordingStreamer{}
+	d := NewDeduplicator(streamer)
+	first := d.Source().(*dedupSource)
+	second := d.Source().(*dedupSource)
+
+	if err := first.AddBroadcastFeedMessages(feedMessages(1)); err != nil {
+		t.Fatal(err)
+	}
+	differing := feedMessages(1)
+	differing[0].Message.DelayedMessagesRead = 1
+	if err := second.AddBroadcastFeedMessages(differing); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamer.positions) != 2 {
+		t.Fatal("a differing message at a seen position was dropped", streamer.positions)
+	}
+}
+
+func TestDeduplicatorOnlyDropsAccepted(t *testing.T) {
+	streamer := &recordingStreamer{reject: true}
+	d := NewDeduplicator(streamer)
+	first := d.Source().(*dedupSource)
+	second := d.Source().(*dedupSource)
+
+	if err := first.AddBroadcastFeedMessages(feedMessages(1, 2)); err == nil {
+		t.Fatal("streamer's rejection not returned")
+	}
+	streamer.reject = false
+	if err := second.AddBroadcastFeedMessages(feedMessages(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamer.positions) != 1 || streamer.positions[0] != 1 || streamer.counts[0] != 2 {
+		t.Fatal("rejected messages treated as delivered", streamer.positions, streamer.counts)
+	}
+	if err := first.AddBroadcastFeedMessages(feedMessages(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamer.positions) != 1 {
+		t.Fatal("accepted messages passed on again", streamer.positions)
+	}
+}
+
+func TestDeduplicatorPassesContiguousRuns(t *testing.T) {
+	streamer := &recordingStreamer{}
+	d := NewDeduplicator(streamer)
+	first := d.Source().(*dedupSource)
+	second := d.Source().(*dedupSource)
+
+	if err := first.AddBroadcastMessages(3, make([]arbstate.MessageWithMetadata, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.AddBroadcastFeedMessages(feedMessages(1, 2, 3, 4, 5, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamer.positions) != 3 || streamer.positions[1] != 1 || streamer.counts[1] != 2 || streamer.positions[2] != 5 || streamer.counts[2] != 2 {
+		t.Fatal("unexpected runs passed on", streamer.positions, streamer.counts)
+	}
+}
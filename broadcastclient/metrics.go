@@ -0,0 +1,88 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+var unsafeMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// upstreamMetricName names the feed at the URL in metrics, by its host and port.
+func upstreamMetricName(websocketUrl string) string {
+	host := websocketUrl
+	if parsed, err := url.Parse(websocketUrl); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	name := unsafeMetricNameChars.ReplaceAllString(host, "_")
+	if name == "" || name == "_" {
+		return "unknown"
+	}
+	return name
+}
+
+// clientMetrics measure the quality of one upstream feed, under arb/feed/client/upstream/<host_port>/. Clients
+// of the same feed share them.
+type clientMetrics struct {
+	latency     metrics.Histogram // ms from the sequencer's timestamp of a message to its receipt
+	gaps        metrics.Counter   // times messages were skipped
+	gapMessages metrics.Counter   // messages skipped
+	reconnects  metrics.Counter
+	fallbacks   metrics.Counter // times the feed no longer had missed messages, leaving them to L1
+}
+
+func newClientMetrics(websocketUrl string) *clientMetrics {
+	prefix := "arb/feed/client/upstream/" + upstreamMetricName(websocketUrl) + "/"
+	return &clientMetrics{
+		latency:     metrics.GetOrRegisterHistogram(prefix+"latency", nil, metrics.NewExpDecaySample(1028, 0.015)),
+		gaps:        metrics.GetOrRegisterCounter(prefix+"gaps", nil),
+		gapMessages: metrics.GetOrRegisterCounter(prefix+"gap/messages", nil),
+		reconnects:  metrics.GetOrRegisterCounter(prefix+"reconnects", nil),
+		fallbacks:   metrics.GetOrRegisterCounter(prefix+"fallbacks", nil),
+	}
+}
+
+// The methods do nothing on nil metrics, as for replayed feeds.
+
+func (m *clientMetrics) received(message *broadcaster.BroadcastFeedMessage, now time.Time) {
+	if m == nil || message.Message.Message == nil || message.Message.Message.Header == nil {
+		return
+	}
+	sent := time.Unix(int64(message.Message.Message.Header.Timestamp), 0)
+	latency := now.Sub(sent)
+	if latency < 0 {
+		// the sequencer's timestamps are whole seconds
+		latency = 0
+	}
+	m.latency.Update(latency.Milliseconds())
+}
+
+func (m *clientMetrics) gap(expected arbutil.MessageIndex, received arbutil.MessageIndex) {
+	if m == nil {
+		return
+	}
+	m.gaps.Inc(1)
+	m.gapMessages.Inc(int64(received - expected))
+}
+
+func (m *clientMetrics) reconnected() {
+	if m == nil {
+		return
+	}
+	m.reconnects.Inc(1)
+}
+
+func (m *clientMetrics) fellBackToL1() {
+	if m == nil {
+		return
+	}
+	m.fallbacks.Inc(1)
+}
@@ -0,0 +1,58 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+func TestUpstreamMetricName(t *testing.T) {
+	names := map[string]string{
+		"wss://arb1.arbitrum.io/feed": "arb1_arbitrum_io",
+		"ws://127.0.0.1:9642":         "127_0_0_1_9642",
+		"":                            "unknown",
+	}
+	for feedUrl, expected := range names {
+		if name := upstreamMetricName(feedUrl); name != expected {
+			t.Fatal("feed", feedUrl, "named", name, "expected", expected)
+		}
+	}
+}
+
+func TestFeedGapMetrics(t *testing.T) {
+	// counters are only counted with metrics enabled
+	metrics.Enabled = true
+	client := NewBroadcastClient("ws://gap.test:9642", nil, 0, &collectingTransactionStreamer{})
+	send := func(seqNums ...arbutil.MessageIndex) {
+		msg := broadcaster.BroadcastMessage{Version: 1}
+		for _, seqNum := range seqNums {
+			msg.Messages = append(msg.Messages, &broadcaster.BroadcastFeedMessage{
+				SequenceNumber: seqNum,
+				Message:        arbstate.MessageWithMetadata{},
+			})
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.handleMessage(context.Background(), data)
+	}
+	send(1, 2)
+	send(5)
+	send(6, 9)
+	if gaps := client.metrics.gaps.Count(); gaps != 2 {
+		t.Fatal("counted", gaps, "gaps, expected 2")
+	}
+	if skipped := client.metrics.gapMessages.Count(); skipped != 4 {
+		t.Fatal("counted", skipped, "skipped messages, expected 4")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pkg/errors"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+const (
+	feedEventMessage    = "message"
+	feedEventConnect    = "connect"
+	feedEventDisconnect = "disconnect"
+)
+
+// FeedEvent is an entry of a feed recording: a raw feed message as received, or a connection change.
+type FeedEvent struct {
+	Offset  time.Duration   `json:"offset"` // since the recording started
+	Kind    string          `json:"kind"`
+	Message json.RawMessage `json:"message,omitempty"`
+}
+
+// FeedRecorder captures a live feed session to a file of JSON lines, one FeedEvent each,
+// which ReplayFeed replays into a transaction streamer in tests.
+type FeedRecorder struct {
+	mutex   sync.Mutex
+	start   time.Time
+	out     *bufio.Writer
+	closer  io.Closer
+	encoder *json.Encoder
+	err     error
+}
+
+func NewFeedRecorder(out io.WriteCloser) *FeedRecorder {
+	writer := bufio.NewWriter(out)
+	return &FeedRecorder{
+		start:   time.Now(),
+		out:     writer,
+		closer:  out,
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+// CreateFeedRecorder records to a new file at path, replacing any existing one.
+func CreateFeedRecorder(path string) (*FeedRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create feed recording")
+	}
+	return NewFeedRecorder(file), nil
+}
+
+func (r *FeedRecorder) record(kind string, message []byte) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return
+	}
+	event := FeedEvent{
+		Offset:  time.Since(r.start),
+		Kind:    kind,
+		Message: message,
+	}
+	r.err = r.encoder.Encode(&event)
+	if r.err == nil {
+		// Flush every event, so a recording of a session which ends in a crash is complete
+		r.err = r.out.Flush()
+	}
+	if r.err != nil {
+		log.Error("failed to record feed, stopping recording", "err", r.err)
+	}
+}
+
+func (r *FeedRecorder) recordMessage(message []byte) {
+	if r != nil && !json.Valid(message) {
+		log.Warn("not recording feed message which isn't valid JSON", "length", len(message))
+		return
+	}
+	r.record(feedEventMessage, message)
+}
+
+func (r *FeedRecorder) recordEvent(kind string) {
+	r.record(kind, nil)
+}
+
+func (r *FeedRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err := r.out.Flush()
+	closeErr := r.closer.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// SetRecorder records the feed session the client receives from now on.
+func (bc *BroadcastClient) SetRecorder(recorder *FeedRecorder) {
+	bc.recorder = recorder
+}
+
+// FeedRecordingReader reads the events of a feed recording one at a time, so replaying a long recording doesn't
+// need it all in memory.
+type FeedRecordingReader struct {
+	decoder *json.Decoder
+	read    int
+}
+
+func NewFeedRecordingReader(in io.Reader) *FeedRecordingReader {
+	return &FeedRecordingReader{decoder: json.NewDecoder(in)}
+}
+
+// Next returns the recording's next event, or io.EOF after the last one.
+func (r *FeedRecordingReader) Next() (*FeedEvent, error) {
+	var event FeedEvent
+	err := r.decoder.Decode(&event)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		// A crash while recording may leave a truncated last event
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Warn("feed recording ends with a truncated event", "events", r.read)
+			return nil, io.EOF
+		}
+		return nil, errors.Wrapf(err, "reading feed recording event %v", r.read)
+	}
+	r.read++
+	return &event, nil
+}
+
+// ReadFeedRecording reads the events of a feed recording.
+func ReadFeedRecording(in io.Reader) ([]FeedEvent, error) {
+	reader := NewFeedRecordingReader(in)
+	var events []FeedEvent
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, *event)
+	}
+}
+
+type feedEventSource interface {
+	Next() (*FeedEvent, error)
+}
+
+type feedEventSlice struct {
+	events []FeedEvent
+}
+
+func (s *feedEventSlice) Next() (*FeedEvent, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	event := &s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+// ReplayFeed delivers the messages of a feed recording to the transaction streamer, and the confirmed sequence
+// numbers to the listener if it isn't nil, exactly as the broadcast client delivered them when they were recorded.
+// With a speed of 0, messages are replayed as fast as they're consumed, so replays are deterministic; otherwise
+// they're spaced out as they were received, divided by the speed, for reproducing races or load. Connection
+// changes are logged.
+func ReplayFeed(ctx context.Context, events []FeedEvent, txStreamer TransactionStreamerInterface, confirmedListener chan arbutil.MessageIndex, speed float64) error {
+	return replayFeed(ctx, &feedEventSlice{events}, txStreamer, confirmedListener, speed)
+}
+
+// ReplayFeedRecording is ReplayFeed, reading the events from the recording as they're replayed.
+func ReplayFeedRecording(ctx context.Context, reader *FeedRecordingReader, txStreamer TransactionStreamerInterface, confirmedListener chan arbutil.MessageIndex, speed float64) error {
+	return replayFeed(ctx, reader, txStreamer, confirmedListener, speed)
+}
+
+func replayFeed(ctx context.Context, events feedEventSource, txStreamer TransactionStreamerInterface, confirmedListener chan arbutil.MessageIndex, speed float64) error {
+	client := &BroadcastClient{
+		ConfirmedSequenceNumberListener: confirmedListener,
+		txStreamer:                      txStreamer,
+	}
+	start := time.Now()
+	for i := 0; ; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		event, err := events.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if speed > 0 {
+			if wait := time.Duration(float64(event.Offset)/speed) - time.Since(start); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		switch event.Kind {
+		case feedEventMessage:
+			client.handleMessage(ctx, event.Message)
+		case feedEventConnect, feedEventDisconnect:
+			log.Info("replaying feed connection change", "event", i, "kind", event.Kind, "offset", event.Offset)
+		default:
+			return errors.Errorf("unknown feed recording event kind %v at event %v", event.Kind, i)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+// SignatureVerifierConfig has the client check the sequencer's signature on every feed message, rejecting those
+// not signed by a member of the on-chain sequencer set or one of the allowed addresses.
+type SignatureVerifierConfig struct {
+	Enable           bool     `koanf:"enable"`
+	AllowedAddresses []string `koanf:"allowed-addresses"`
+}
+
+func SignatureVerifierConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultSignatureVerifierConfig.Enable, "reject feed messages not signed by the sequencer (only enable if the sequencer signs its feed)")
+	f.StringSlice(prefix+".allowed-addresses", DefaultSignatureVerifierConfig.AllowedAddresses, "addresses trusted to sign feed messages, besides the on-chain sequencer set")
+}
+
+// Verification is off by default, as only sequencers with a signing key configured sign the feed
+var DefaultSignatureVerifierConfig = SignatureVerifierConfig{
+	Enable:           false,
+	AllowedAddresses: []string{},
+}
+
+var (
+	ErrMissingFeedSignature = errors.New("feed message is unsigned")
+	ErrInvalidFeedSignature = errors.New("feed message signed by an address outside the sequencer set")
+)
+
+var invalidSignatureCounter = metrics.NewRegisteredCounter("arb/feed/client/signature/invalid", nil)
+
+// SequencerChecker looks up whether an address is in the on-chain sequencer set, such as das.BatchPosterVerifier.
+type SequencerChecker interface {
+	IsBatchPoster(ctx context.Context, addr common.Address) (bool, error)
+}
+
+// SignatureVerifier checks feed messages were signed by the sequencer.
+type SignatureVerifier struct {
+	chainId    uint64
+	allowed    map[common.Address]bool
+	sequencers SequencerChecker // nil if only the allowed addresses may sign
+}
+
+func NewSignatureVerifier(config *SignatureVerifierConfig, chainId uint64, sequencers SequencerChecker) (*SignatureVerifier, error) {
+	allowed := make(map[common.Address]bool)
+	for _, address := range config.AllowedAddresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid feed signer address %q", address)
+		}
+		allowed[common.HexToAddress(address)] = true
+	}
+	if sequencers == nil && len(allowed) == 0 {
+		return nil, errors.New("feed signature verification enabled without an L1 reader to look up the sequencer set or any allowed addresses")
+	}
+	return &SignatureVerifier{
+		chainId:    chainId,
+		allowed:    allowed,
+		sequencers: sequencers,
+	}, nil
+}
+
+// Verify returns an error unless the message was signed by an allowed address or a member of the sequencer set.
+func (v *SignatureVerifier) Verify(ctx context.Context, msg *broadcaster.BroadcastFeedMessage) error {
+	if len(msg.Signature) == 0 {
+		return ErrMissingFeedSignature
+	}
+	signer, err := msg.Signer(v.chainId)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFeedSignature, err)
+	}
+	if v.allowed[signer] {
+		return nil
+	}
+	if v.sequencers != nil {
+		isSequencer, err := v.sequencers.IsBatchPoster(ctx, signer)
+		if err != nil {
+			return err
+		}
+		if isSequencer {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidFeedSignature, signer)
+}
@@ -0,0 +1,101 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+type staticSequencers map[common.Address]bool
+
+func (s staticSequencers) IsBatchPoster(ctx context.Context, addr common.Address) (bool, error) {
+	return s[addr], nil
+}
+
+func TestVerifyFeedSignatures(t *testing.T) {
+	ctx := context.Background()
+	chainId := uint64(412346)
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	sequencerKey, allowedKey, otherKey := keys[0], keys[1], keys[2]
+
+	if _, err := NewSignatureVerifier(&DefaultSignatureVerifierConfig, chainId, nil); err == nil {
+		t.Fatal("verifier created without a sequencer set or allowed addresses")
+	}
+	config := DefaultSignatureVerifierConfig
+	config.AllowedAddresses = []string{crypto.PubkeyToAddress(allowedKey.PublicKey).Hex()}
+	sequencers := staticSequencers{crypto.PubkeyToAddress(sequencerKey.PublicKey): true}
+	verifier, err := NewSignatureVerifier(&config, chainId, sequencers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(key *ecdsa.PrivateKey, signingChainId uint64) *broadcaster.BroadcastFeedMessage {
+		msg := &broadcaster.BroadcastFeedMessage{
+			SequenceNumber: 7,
+			Message: arbstate.MessageWithMetadata{
+				Message: &arbos.L1IncomingMessage{
+					Header: &arbos.L1IncomingMessageHeader{L1BaseFee: big.NewInt(0)},
+					L2msg:  []byte{0xde, 0xad, 0xbe, 0xef},
+				},
+				DelayedMessagesRead: 1,
+			},
+		}
+		err := msg.Sign(signingChainId, func(hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if err := verifier.Verify(ctx, sign(sequencerKey, chainId)); err != nil {
+		t.Fatal("rejected a message signed by the sequencer", err)
+	}
+	if err := verifier.Verify(ctx, sign(allowedKey, chainId)); err != nil {
+		t.Fatal("rejected a message signed by an allowed address", err)
+	}
+	if err := verifier.Verify(ctx, sign(otherKey, chainId)); !errors.Is(err, ErrInvalidFeedSignature) {
+		t.Fatal("accepted a message signed by another address", err)
+	}
+	if err := verifier.Verify(ctx, sign(sequencerKey, chainId+1)); !errors.Is(err, ErrInvalidFeedSignature) {
+		t.Fatal("accepted a message signed for another chain", err)
+	}
+
+	tampered := sign(sequencerKey, chainId)
+	tampered.Message.DelayedMessagesRead++
+	if err := verifier.Verify(ctx, tampered); !errors.Is(err, ErrInvalidFeedSignature) {
+		t.Fatal("accepted a message altered after signing", err)
+	}
+	unsigned := sign(sequencerKey, chainId)
+	unsigned.Signature = nil
+	if err := verifier.Verify(ctx, unsigned); !errors.Is(err, ErrMissingFeedSignature) {
+		t.Fatal("accepted an unsigned message", err)
+	}
+}
+
+func TestDefaultConfigAcceptsUnsignedFeed(t *testing.T) {
+	// Sequencers and relays without a signing key broadcast unsigned messages, which stock consumers must accept
+	if DefaultBroadcastClientConfig.Verify.Enable {
+		t.Fatal("feed signature verification enabled by default")
+	}
+}
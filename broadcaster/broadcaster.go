@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/log"
 
 	"github.com/offchainlabs/nitro/arbstate"
@@ -19,6 +20,15 @@ import (
 type Broadcaster struct {
 	server        *wsbroadcastserver.WSBroadcastServer
 	catchupBuffer *SequenceNumberCatchupBuffer
+	chainId       uint64
+	signer        func([]byte) ([]byte, error) // nil unless messages are signed
+	listeners     []BroadcastListener
+}
+
+// BroadcastListener is sent everything the broadcaster broadcasts, such as the server of another feed transport.
+// Broadcast mustn't block.
+type BroadcastListener interface {
+	Broadcast(msg BroadcastMessage)
 }
 
 /*
@@ -42,33 +52,114 @@ type BroadcastMessage struct {
 	// TODO better name than messages since there are different types of messages
 	Messages                       []*BroadcastFeedMessage         `json:"messages,omitempty"`
 	ConfirmedSequenceNumberMessage *ConfirmedSequenceNumberMessage `json:"confirmedSequenceNumberMessage,omitempty"`
+	AdvisoryMessage                *AdvisoryMessage                `json:"advisoryMessage,omitempty"`
+	BackfillMessage                *BackfillMessage                `json:"backfillMessage,omitempty"`
 }
 
 type BroadcastFeedMessage struct {
 	SequenceNumber arbutil.MessageIndex         `json:"sequenceNumber"`
 	Message        arbstate.MessageWithMetadata `json:"message"`
+	Signature      hexutil.Bytes                `json:"signature,omitempty"`
 }
 
 type ConfirmedSequenceNumberMessage struct {
 	SequenceNumber arbutil.MessageIndex `json:"sequenceNumber"`
 }
 
+// Advisory severities, in increasing order
+const (
+	AdvisoryInfo     = "info"
+	AdvisoryWarning  = "warning"
+	AdvisoryCritical = "critical"
+)
+
+// Advisory warns of a limit the sequencer is approaching. Deadline and DeadlineL1Block are when the limit is
+// reached, as a unix timestamp and an L1 block number, and Until is when a maintenance window ends.
+type Advisory struct {
+	Kind            string `json:"kind"`
+	Severity        string `json:"severity"`
+	Message         string `json:"message"`
+	Deadline        uint64 `json:"deadline,omitempty"`
+	DeadlineL1Block uint64 `json:"deadlineL1Block,omitempty"`
+	Until           uint64 `json:"until,omitempty"`
+}
+
+// AdvisoryMessage is the sequencer's complete set of current advisories, replacing any sent before it.
+// An empty set means there's nothing to advise.
+type AdvisoryMessage struct {
+	Timestamp  uint64     `json:"timestamp"`
+	Advisories []Advisory `json:"advisories"`
+}
+
+// BackfillMessage tells a reconnecting client that some of the messages it asked for, from
+// RequestedSequenceNumber, have been evicted from the broadcaster's backlog, so it's only sent those from
+// FirstSequenceNumber on. It must fetch the rest from ArchiveURL, if there is one, or else from L1.
+type BackfillMessage struct {
+	RequestedSequenceNumber arbutil.MessageIndex `json:"requestedSequenceNumber"`
+	FirstSequenceNumber     arbutil.MessageIndex `json:"firstSequenceNumber"`
+	ArchiveURL              string               `json:"archiveUrl,omitempty"`
+}
+
+// SequenceNumberCatchupBuffer holds the unconfirmed messages, sent to clients as they connect, preceded by up to
+// backfill confirmed messages, which are only sent to reconnecting clients which ask for them.
 type SequenceNumberCatchupBuffer struct {
 	messages     []*BroadcastFeedMessage
-	messageCount int32
+	confirmed    int              // the number of messages at the start of the buffer which are confirmed
+	messageCount int32            // the number of unconfirmed messages
+	advisory     *AdvisoryMessage // the latest, sent to clients as they connect
+	backfill     int
+	archiveURL   string
+
+	latestConfirmation *ConfirmedSequenceNumberMessage // sent to clients of the lightweight streams as they connect
+}
+
+func NewSequenceNumberCatchupBuffer(backfill int, archiveURL string) *SequenceNumberCatchupBuffer {
+	return &SequenceNumberCatchupBuffer{
+		backfill:   backfill,
+		archiveURL: archiveURL,
+	}
 }
 
-func NewSequenceNumberCatchupBuffer() *SequenceNumberCatchupBuffer {
-	return &SequenceNumberCatchupBuffer{}
+// catchupMessages returns the messages to send a client as it connects. That's the unconfirmed messages, unless
+// it asked for messages from an earlier sequence number, as it's reconnecting.
+func (b *SequenceNumberCatchupBuffer) catchupMessages(clientConnection *wsbroadcastserver.ClientConnection) ([]*BroadcastFeedMessage, *BackfillMessage) {
+	requested, ok := clientConnection.RequestedSeqNum()
+	if !ok || len(b.messages) == 0 {
+		return b.messages[b.confirmed:], nil
+	}
+	first := b.messages[0].SequenceNumber
+	if requested < first {
+		return b.messages, &BackfillMessage{
+			RequestedSequenceNumber: requested,
+			FirstSequenceNumber:     first,
+			ArchiveURL:              b.archiveURL,
+		}
+	}
+	if index := uint64(requested - first); index < uint64(len(b.messages)) {
+		return b.messages[index:], nil
+	}
+	return nil, nil
 }
 
 func (b *SequenceNumberCatchupBuffer) OnRegisterClient(ctx context.Context, clientConnection *wsbroadcastserver.ClientConnection) error {
 	start := time.Now()
-	if len(b.messages) > 0 {
+	messages, backfill := b.catchupMessages(clientConnection)
+	if backfill != nil {
+		log.Info("client requested messages evicted from the backlog", "client", clientConnection.Name, "requested", backfill.RequestedSequenceNumber, "first", backfill.FirstSequenceNumber)
+		err := clientConnection.Write(BroadcastMessage{
+			Version:         1,
+			BackfillMessage: backfill,
+		})
+		if err != nil {
+			log.Error("error sending client backfill", "err", err, "client", clientConnection.Name)
+			return err
+		}
+	}
+	if len(messages) > 0 {
 		// send the newly connected client all the messages we've got...
 		bm := BroadcastMessage{
 			Version:  1,
-			Messages: b.messages,
+			Messages: messages,
 		}
 
 		err := clientConnection.Write(bm)
@@ -77,6 +168,27 @@ func (b *SequenceNumberCatchupBuffer) OnRegisterClient(ctx context.Context, clie
 			return err
 		}
 	}
+	if b.latestConfirmation != nil && clientConnection.Stream() != wsbroadcastserver.StreamFull {
+		// clients of the lightweight streams may be following just confirmations, so tell them where it's at
+		err := clientConnection.Write(BroadcastMessage{
+			Version:                        1,
+			ConfirmedSequenceNumberMessage: b.latestConfirmation,
+		})
+		if err != nil {
+			log.Error("error sending client confirmed sequence number", "err", err, "client", clientConnection.Name)
+			return err
+		}
+	}
+	if b.advisory != nil {
+		err := clientConnection.Write(BroadcastMessage{
+			Version:         1,
+			AdvisoryMessage: b.advisory,
+		})
+		if err != nil {
+			log.Error("error sending client advisories", "err", err, "client", clientConnection.Name)
+			return err
+		}
+	}
 
 	log.Info("client registered", "client", clientConnection.Name, "elapsed", time.Since(start))
 
@@ -88,9 +200,16 @@ func (b *SequenceNumberCatchupBuffer) OnDoBroadcast(bmi interface{}) error {
 	if !ok {
 		log.Crit("Requested to broadcast messasge of unknown type")
 	}
-	defer func() { atomic.StoreInt32(&b.messageCount, int32(len(b.messages))) }()
+	defer func() { atomic.StoreInt32(&b.messageCount, int32(len(b.messages)-b.confirmed)) }()
+
+	if broadcastMessage.AdvisoryMessage != nil {
+		b.advisory = broadcastMessage.AdvisoryMessage
+	}
 
 	if confirmMsg := broadcastMessage.ConfirmedSequenceNumberMessage; confirmMsg != nil {
+		if b.latestConfirmation == nil || confirmMsg.SequenceNumber > b.latestConfirmation.SequenceNumber {
+			b.latestConfirmation = confirmMsg
+		}
 		if len(b.messages) == 0 {
 			return nil
 		}
@@ -105,6 +224,11 @@ func (b *SequenceNumberCatchupBuffer) OnDoBroadcast(bmi interface{}) error {
 		if uint64(len(b.messages)) <= confirmedIndex {
 			log.Error("ConfirmedSequenceNumber message ", confirmMsg.SequenceNumber, " is past the end of stored messages. Clearing buffer. Final stored sequence number was ", b.messages[len(b.messages)-1])
 			b.messages = nil
+			b.confirmed = 0
+			return nil
+		}
+		if confirmedIndex < uint64(b.confirmed) {
+			// already confirmed, and only kept for backfill
 			return nil
 		}
 
@@ -113,10 +237,16 @@ func (b *SequenceNumberCatchupBuffer) OnDoBroadcast(bmi interface{}) error {
 			// relays to also cause them to be cleared.
 			log.Error("Invariant violation: Non-sequential messages stored in SequenceNumberCatchupBuffer. Found ", b.messages[confirmedIndex].SequenceNumber, " expected ", confirmMsg.SequenceNumber, ". Clearing buffer.")
 			b.messages = nil
+			b.confirmed = 0
 			return nil
 		}
 
-		b.messages = b.messages[confirmedIndex+1:]
+		b.confirmed = int(confirmedIndex + 1)
+		if b.confirmed > b.backfill {
+			evicted := b.confirmed - b.backfill
+			b.messages = b.messages[evicted:]
+			b.confirmed -= evicted
+		}
 		return nil
 	}
 
@@ -132,6 +262,7 @@ func (b *SequenceNumberCatchupBuffer) OnDoBroadcast(bmi interface{}) error {
 				"expectedSeqNum", expectedSequenceNumber,
 			)
 			b.messages = nil
+			b.confirmed = 0
 			b.messages = append(b.messages, newMsg)
 		} else {
 			log.Info("Skipping already seen message", "seqNum", newMsg.SequenceNumber)
@@ -147,17 +278,29 @@ func (b *SequenceNumberCatchupBuffer) GetMessageCount() int {
 }
 
 func NewBroadcaster(settings wsbroadcastserver.BroadcasterConfig) *Broadcaster {
-	catchupBuffer := NewSequenceNumberCatchupBuffer()
+	catchupBuffer := NewSequenceNumberCatchupBuffer(settings.Backfill, settings.ArchiveURL)
 	return &Broadcaster{
 		server:        wsbroadcastserver.NewWSBroadcastServer(settings, catchupBuffer),
 		catchupBuffer: catchupBuffer,
 	}
 }
 
+// SetSigner has the sequencer sign the messages it broadcasts, so clients needn't trust the transport.
+// It must be called before the broadcaster is started.
+func (b *Broadcaster) SetSigner(chainId uint64, signer func([]byte) ([]byte, error)) {
+	b.chainId = chainId
+	b.signer = signer
+}
+
 func (b *Broadcaster) BroadcastSingle(msg arbstate.MessageWithMetadata, seq arbutil.MessageIndex) {
 	var broadcastMessages []*BroadcastFeedMessage
 
 	bfm := BroadcastFeedMessage{SequenceNumber: seq, Message: msg}
+	if b.signer != nil {
+		if err := bfm.Sign(b.chainId, b.signer); err != nil {
+			log.Error("failed to sign feed message, broadcasting it unsigned", "seqNum", seq, "err", err)
+		}
+	}
 	broadcastMessages = append(broadcastMessages, &bfm)
 
 	bm := BroadcastMessage{
@@ -165,23 +308,44 @@ func (b *Broadcaster) BroadcastSingle(msg arbstate.MessageWithMetadata, seq arbu
 		Messages: broadcastMessages,
 	}
 
-	b.server.Broadcast(bm)
+	b.Broadcast(bm)
 }
 
 func (b *Broadcaster) Broadcast(msg BroadcastMessage) {
 	b.server.Broadcast(msg)
+	for _, listener := range b.listeners {
+		listener.Broadcast(msg)
+	}
+}
+
+// AddListener has the broadcaster send the listener everything it broadcasts. It must be called before the
+// broadcaster is started.
+func (b *Broadcaster) AddListener(listener BroadcastListener) {
+	b.listeners = append(b.listeners, listener)
 }
 
 func (b *Broadcaster) Confirm(seq arbutil.MessageIndex) {
-	b.server.Broadcast(BroadcastMessage{
+	b.Broadcast(BroadcastMessage{
 		Version:                        1,
 		ConfirmedSequenceNumberMessage: &ConfirmedSequenceNumberMessage{seq}})
 }
 
+func (b *Broadcaster) Advise(advisory *AdvisoryMessage) {
+	b.Broadcast(BroadcastMessage{
+		Version:         1,
+		AdvisoryMessage: advisory,
+	})
+}
+
 func (b *Broadcaster) ClientCount() int32 {
 	return b.server.ClientCount()
 }
 
+// RevokeToken stops the feed token with the given ID from connecting, and disconnects its clients.
+func (b *Broadcaster) RevokeToken(id string) {
+	b.server.RevokeToken(id)
+}
+
 func (b *Broadcaster) ListenerAddr() net.Addr {
 	return b.server.ListenerAddr()
 }
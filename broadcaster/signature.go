@@ -0,0 +1,63 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcaster
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Prefixes the signed data so a feed message signature can't be passed off as any other
+var feedMessageDomain = crypto.Keccak256([]byte("Nitro feed message"))
+
+// Hash is what the sequencer signs: the keccak256 of the domain, then the chain ID, sequence number and delayed
+// messages read as 8 bytes each big endian, then the serialized L1 incoming message.
+func (m *BroadcastFeedMessage) Hash(chainId uint64) (common.Hash, error) {
+	var serialized []byte
+	if m.Message.Message != nil {
+		var err error
+		serialized, err = m.Message.Message.Serialize()
+		if err != nil {
+			return common.Hash{}, err
+		}
+	}
+	var numbers [24]byte
+	binary.BigEndian.PutUint64(numbers[0:8], chainId)
+	binary.BigEndian.PutUint64(numbers[8:16], uint64(m.SequenceNumber))
+	binary.BigEndian.PutUint64(numbers[16:24], m.Message.DelayedMessagesRead)
+	return crypto.Keccak256Hash(feedMessageDomain, numbers[:], serialized), nil
+}
+
+// Sign sets the message's signature, using a signer of 32 byte hashes.
+func (m *BroadcastFeedMessage) Sign(chainId uint64, signer func([]byte) ([]byte, error)) error {
+	hash, err := m.Hash(chainId)
+	if err != nil {
+		return err
+	}
+	sig, err := signer(hash.Bytes())
+	if err != nil {
+		return err
+	}
+	m.Signature = sig
+	return nil
+}
+
+// Signer recovers the address which signed the message.
+func (m *BroadcastFeedMessage) Signer(chainId uint64) (common.Address, error) {
+	if len(m.Signature) == 0 {
+		return common.Address{}, errors.New("feed message is unsigned")
+	}
+	hash, err := m.Hash(chainId)
+	if err != nil {
+		return common.Address{}, err
+	}
+	pubkey, err := crypto.SigToPub(hash.Bytes(), m.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
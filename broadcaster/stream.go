@@ -0,0 +1,47 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcaster
+
+import (
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
+)
+
+// ForStream returns the message as it's sent on a stream. The headers stream drops the messages' L2 payloads, and
+// the confirmations stream has only confirmations.
+func (m BroadcastMessage) ForStream(stream wsbroadcastserver.Stream) (interface{}, bool) {
+	switch stream {
+	case wsbroadcastserver.StreamHeaders:
+		if len(m.Messages) == 0 {
+			return m, true
+		}
+		headers := m
+		headers.Messages = make([]*BroadcastFeedMessage, 0, len(m.Messages))
+		for _, message := range m.Messages {
+			if message == nil {
+				continue
+			}
+			header := *message
+			if message.Message.Message != nil {
+				header.Message = arbstate.MessageWithMetadata{
+					Message:             &arbos.L1IncomingMessage{Header: message.Message.Message.Header},
+					DelayedMessagesRead: message.Message.DelayedMessagesRead,
+				}
+			}
+			headers.Messages = append(headers.Messages, &header)
+		}
+		return headers, true
+	case wsbroadcastserver.StreamConfirmations:
+		if m.ConfirmedSequenceNumberMessage == nil {
+			return nil, false
+		}
+		return BroadcastMessage{
+			Version:                        m.Version,
+			ConfirmedSequenceNumberMessage: m.ConfirmedSequenceNumberMessage,
+		}, true
+	default:
+		return m, true
+	}
+}
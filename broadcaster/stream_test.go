@@ -0,0 +1,55 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcaster
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
+)
+
+func TestBroadcastMessageStreams(t *testing.T) {
+	message := BroadcastMessage{
+		Version: 1,
+		Messages: []*BroadcastFeedMessage{{
+			SequenceNumber: 5,
+			Message: arbstate.MessageWithMetadata{
+				Message: &arbos.L1IncomingMessage{
+					Header: &arbos.L1IncomingMessageHeader{BlockNumber: 7, L1BaseFee: big.NewInt(0)},
+					L2msg:  []byte{0xde, 0xad, 0xbe, 0xef},
+				},
+				DelayedMessagesRead: 2,
+			},
+		}},
+	}
+
+	full, send := message.ForStream(wsbroadcastserver.StreamFull)
+	if !send || len(full.(BroadcastMessage).Messages[0].Message.Message.L2msg) != 4 {
+		Fail(t, "full stream altered the message")
+	}
+	headers, send := message.ForStream(wsbroadcastserver.StreamHeaders)
+	if !send {
+		Fail(t, "headers stream dropped the message")
+	}
+	header := headers.(BroadcastMessage).Messages[0]
+	if header.SequenceNumber != 5 || header.Message.Message.Header.BlockNumber != 7 || header.Message.DelayedMessagesRead != 2 || len(header.Message.Message.L2msg) != 0 {
+		Fail(t, "unexpected header", header)
+	}
+	if len(message.Messages[0].Message.Message.L2msg) != 4 {
+		Fail(t, "headers stream altered the original message")
+	}
+	if _, send := message.ForStream(wsbroadcastserver.StreamConfirmations); send {
+		Fail(t, "confirmations stream sent a message")
+	}
+
+	confirmation := BroadcastMessage{Version: 1, ConfirmedSequenceNumberMessage: &ConfirmedSequenceNumberMessage{5}}
+	for _, stream := range []wsbroadcastserver.Stream{wsbroadcastserver.StreamFull, wsbroadcastserver.StreamHeaders, wsbroadcastserver.StreamConfirmations} {
+		if sent, send := confirmation.ForStream(stream); !send || sent.(BroadcastMessage).ConfirmedSequenceNumberMessage.SequenceNumber != 5 {
+			Fail(t, "confirmation not sent on the stream", stream)
+		}
+	}
+}
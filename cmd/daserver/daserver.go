@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"os"
@@ -18,13 +19,13 @@ import (
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
-	"github.com/ethereum/go-ethereum/metrics/exp"
 
 	"github.com/offchainlabs/nitro/arbnode"
 	"github.com/offchainlabs/nitro/cmd/genericconf"
 	"github.com/offchainlabs/nitro/cmd/util"
 	"github.com/offchainlabs/nitro/das"
 	"github.com/offchainlabs/nitro/das/dasrpc"
+	"github.com/offchainlabs/nitro/util/openmetrics"
 )
 
 type DAServerConfig struct {
@@ -38,7 +39,8 @@ type DAServerConfig struct {
 	RESTPort           uint64                              `koanf:"rest-port"`
 	RESTServerTimeouts genericconf.HTTPServerTimeoutConfig `koanf:"rest-server-timeouts"`
 
-	DAConf das.DataAvailabilityConfig `koanf:"data-availability"`
+	DAConf  das.DataAvailabilityConfig `koanf:"data-availability"`
+	Tenants []string                   `koanf:"tenants"`
 
 	ConfConfig genericconf.ConfConfig `koanf:"conf"`
 	LogLevel   int                    `koanf:"log-level"`
@@ -92,6 +94,7 @@ func parseDAServer(args []string) (*DAServerConfig, error) {
 
 	f.Int("log-level", int(log.LvlInfo), "log level; 1: ERROR, 2: WARN, 3: INFO, 4: DEBUG, 5: TRACE")
 	das.DataAvailabilityConfigAddOptions("data-availability", f)
+	f.StringSlice("tenants", nil, "further chains to serve from this process, each as <name>=<config file> with the chain's data-availability settings in this config format; their RPC and REST requests are routed by the /<name> path prefix, and each needs its own keys and storage")
 	genericconf.ConfConfigAddOptions("conf", f)
 
 	k, err := util.BeginCommonParse(f, args)
@@ -123,6 +126,71 @@ func parseDAServer(args []string) (*DAServerConfig, error) {
 	return &serverConfig, nil
 }
 
+// parseTenants reads the data-availability settings of each tenant from its config file, checking the tenants
+// don't share any keys or storage.
+func parseTenants(serverConfig *DAServerConfig) (map[string]*das.DataAvailabilityConfig, error) {
+	tenants := make(map[string]*das.DataAvailabilityConfig)
+	namespaces := make(map[string]string)
+	claim := func(owner string, config *das.DataAvailabilityConfig) error {
+		for _, namespace := range tenantNamespaces(config) {
+			if other, ok := namespaces[namespace]; ok {
+				return fmt.Errorf("%v and %v both use %v, but tenants must have isolated keys and storage", other, owner, namespace)
+			}
+			namespaces[namespace] = owner
+		}
+		return nil
+	}
+	if serverConfig.DAConf.Enable {
+		if err := claim("the root chain", &serverConfig.DAConf); err != nil {
+			return nil, err
+		}
+	}
+	for _, tenant := range serverConfig.Tenants {
+		name, file, ok := strings.Cut(tenant, "=")
+		if !ok || name == "" || file == "" {
+			return nil, fmt.Errorf("invalid tenant %v, expected <name>=<config file>", tenant)
+		}
+		if _, exists := tenants[name]; exists {
+			return nil, fmt.Errorf("tenant %v given twice", name)
+		}
+		tenantServerConfig, err := parseDAServer([]string{"--conf.file", file})
+		if err != nil {
+			return nil, fmt.Errorf("reading tenant %v config: %w", name, err)
+		}
+		if len(tenantServerConfig.Tenants) > 0 {
+			return nil, fmt.Errorf("tenant %v config can't have tenants of its own", name)
+		}
+		if err := claim("tenant "+name, &tenantServerConfig.DAConf); err != nil {
+			return nil, err
+		}
+		tenants[name] = &tenantServerConfig.DAConf
+	}
+	return tenants, nil
+}
+
+// tenantNamespaces lists the keys and storage locations of a chain's config, which tenants mustn't share.
+func tenantNamespaces(config *das.DataAvailabilityConfig) []string {
+	var namespaces []string
+	add := func(kind string, enabled bool, location string) {
+		if enabled && location != "" {
+			namespaces = append(namespaces, kind+" "+location)
+		}
+	}
+	add("key directory", true, config.KeyConfig.KeyDir)
+	if config.KeyConfig.PrivKey != "" {
+		// identified by its hash, to keep it out of errors
+		add("private key", true, fmt.Sprintf("with hash %x", sha256.Sum256([]byte(config.KeyConfig.PrivKey))))
+	}
+	add("local database", config.LocalDBStorageConfig.Enable, config.LocalDBStorageConfig.DataDir)
+	add("local file storage", config.LocalFileStorageConfig.Enable, config.LocalFileStorageConfig.DataDir)
+	add("S3 bucket", config.S3StorageServiceConfig.Enable, config.S3StorageServiceConfig.Bucket+"/"+config.S3StorageServiceConfig.ObjectPrefix)
+	add("IPFS directory", config.IPFSStorageConfig.Enable, config.IPFSStorageConfig.APIURL+config.IPFSStorageConfig.Directory)
+	add("stored index", config.StoredIndexConfig.Enable, config.StoredIndexConfig.Dir)
+	add("retention index", config.RetentionConfig.Enable, config.RetentionConfig.IndexDir)
+	add("mirror state file", config.MirrorConfig.Enable, config.MirrorConfig.StateFile)
+	return namespaces
+}
+
 func startup() error {
 	// Some different defaults to DAS config in a node.
 	das.DefaultDataAvailabilityConfig.Enable = true
@@ -137,9 +205,9 @@ func startup() error {
 		}
 		return nil
 	}
-	if !(serverConfig.EnableRPC || serverConfig.EnableREST) {
+	if !(serverConfig.EnableRPC || serverConfig.EnableREST || serverConfig.DAConf.MirrorConfig.Enable || serverConfig.DAConf.SamplerConfig.Enable || len(serverConfig.Tenants) > 0) {
 		fmt.Printf("\nrevision: %v, vcs.time: %v\n", vcsRevision, vcsTime)
-		fmt.Printf("Please specify at least one of --enable-rest or --enable-rpc\n")
+		fmt.Printf("Please specify at least one of --enable-rest, --enable-rpc, --data-availability.mirror.enable, --data-availability.sampler.enable or --tenants\n")
 		printSampleUsage()
 		return nil
 	}
@@ -153,7 +221,7 @@ func startup() error {
 
 		if serverConfig.MetricsServer.Addr != "" {
 			address := fmt.Sprintf("%v:%v", serverConfig.MetricsServer.Addr, serverConfig.MetricsServer.Port)
-			exp.Setup(address)
+			openmetrics.Setup(address)
 		}
 	}
 
@@ -163,16 +231,41 @@ func startup() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	tenantConfigs, err := parseTenants(serverConfig)
+	if err != nil {
+		return err
+	}
+
+	// With --data-availability.enable=false only the tenants are served
 	dasImpl, dasLifecycleManager, err := arbnode.SetUpDataAvailabilityWithoutNode(ctx, &serverConfig.DAConf)
 	if err != nil {
 		return err
 	}
+	tenants := make(map[string]das.DataAvailabilityService)
+	tenantLifecycleManagers := make(map[string]*das.LifecycleManager)
+	defer func() {
+		for _, lifecycleManager := range tenantLifecycleManagers {
+			lifecycleManager.StopAndWaitUntil(2 * time.Second)
+		}
+	}()
+	for name, tenantConfig := range tenantConfigs {
+		log.Info("Setting up tenant", "name", name)
+		tenantDAS, lifecycleManager, err := arbnode.SetUpDataAvailabilityWithoutNode(ctx, tenantConfig)
+		if err != nil {
+			return fmt.Errorf("setting up tenant %v: %w", name, err)
+		}
+		if tenantDAS == nil {
+			return fmt.Errorf("tenant %v has data-availability disabled", name)
+		}
+		tenants[name] = tenantDAS
+		tenantLifecycleManagers[name] = lifecycleManager
+	}
 
 	var rpcServer *http.Server
 	if serverConfig.EnableRPC {
 		log.Info("Starting HTTP-RPC server", "addr", serverConfig.RPCAddr, "port", serverConfig.RPCPort)
 
-		rpcServer, err = dasrpc.StartDASRPCServer(ctx, serverConfig.RPCAddr, serverConfig.RPCPort, serverConfig.RPCServerTimeouts, dasImpl)
+		rpcServer, err = dasrpc.StartDASRPCServerWithTenants(ctx, serverConfig.RPCAddr, serverConfig.RPCPort, serverConfig.RPCServerTimeouts, dasImpl, tenants)
 		if err != nil {
 			return err
 		}
@@ -186,6 +279,24 @@ func startup() error {
 		if err != nil {
 			return err
 		}
+		if auditor := dasLifecycleManager.RetentionAuditor(); auditor != nil {
+			restServer.SetRetentionAuditor(auditor)
+		}
+		if lister := dasLifecycleManager.HashLister(); lister != nil {
+			restServer.SetHashLister(lister)
+		}
+		for name, tenantDAS := range tenants {
+			tenant := das.NewRestfulDasTenant(tenantDAS)
+			if auditor := tenantLifecycleManagers[name].RetentionAuditor(); auditor != nil {
+				tenant.SetRetentionAuditor(auditor)
+			}
+			if lister := tenantLifecycleManagers[name].HashLister(); lister != nil {
+				tenant.SetHashLister(lister)
+			}
+			if err := restServer.AddTenant(name, tenant); err != nil {
+				return err
+			}
+		}
 	}
 
 	<-sigint
@@ -4,11 +4,16 @@
 package genericconf
 
 import (
+	"fmt"
+	"net"
+	"strconv"
 	"time"
 
 	flag "github.com/spf13/pflag"
 
 	"github.com/ethereum/go-ethereum/node"
+
+	"github.com/offchainlabs/nitro/util/rpcfilter"
 )
 
 type HTTPConfig struct {
@@ -19,6 +24,7 @@ type HTTPConfig struct {
 	CORSDomain     []string                `koanf:"corsdomain"`
 	VHosts         []string                `koanf:"vhosts"`
 	ServerTimeouts HTTPServerTimeoutConfig `koanf:"server-timeouts"`
+	Methods        MethodPolicyConfig      `koanf:"methods"`
 }
 
 var HTTPConfigDefault = HTTPConfig{
@@ -29,6 +35,7 @@ var HTTPConfigDefault = HTTPConfig{
 	CORSDomain:     node.DefaultConfig.HTTPCors,
 	VHosts:         node.DefaultConfig.HTTPVirtualHosts,
 	ServerTimeouts: HTTPServerTimeoutConfigDefault,
+	Methods:        MethodPolicyConfigDefault,
 }
 
 type HTTPServerTimeoutConfig struct {
@@ -58,6 +65,20 @@ func (c HTTPConfig) Apply(stackConf *node.Config) {
 	// stackConf.HTTPTimeouts.ReadHeaderTimeout = c.ServerTimeouts.ReadHeaderTimeout
 	stackConf.HTTPTimeouts.WriteTimeout = c.ServerTimeouts.WriteTimeout
 	stackConf.HTTPTimeouts.IdleTimeout = c.ServerTimeouts.IdleTimeout
+	if c.Filtered() {
+		// The method filter serves the configured address, in front of the server on loopback
+		stackConf.HTTPHost = filteredUpstreamHost
+		stackConf.HTTPPort = 0
+	}
+}
+
+// Filtered returns whether the HTTP server has a method policy and so is served by the method filter.
+func (c HTTPConfig) Filtered() bool {
+	return c.Addr != "" && c.Methods.Enabled()
+}
+
+func (c HTTPConfig) ListenAddr() string {
+	return net.JoinHostPort(c.Addr, strconv.Itoa(c.Port))
 }
 
 func HTTPConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -68,6 +89,7 @@ func HTTPConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.StringSlice(prefix+".corsdomain", HTTPConfigDefault.CORSDomain, "Comma separated list of domains from which to accept cross origin requests (browser enforced)")
 	f.StringSlice(prefix+".vhosts", HTTPConfigDefault.VHosts, "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard")
 	HTTPServerTimeoutConfigAddOptions(prefix+".server-timeouts", f)
+	MethodPolicyConfigAddOptions(prefix+".methods", f)
 }
 
 func HTTPServerTimeoutConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -78,12 +100,13 @@ func HTTPServerTimeoutConfigAddOptions(prefix string, f *flag.FlagSet) {
 }
 
 type WSConfig struct {
-	Addr      string   `koanf:"addr"`
-	Port      int      `koanf:"port"`
-	API       []string `koanf:"api"`
-	RPCPrefix string   `koanf:"rpcprefix"`
-	Origins   []string `koanf:"origins"`
-	ExposeAll bool     `koanf:"expose-all"`
+	Addr      string             `koanf:"addr"`
+	Port      int                `koanf:"port"`
+	API       []string           `koanf:"api"`
+	RPCPrefix string             `koanf:"rpcprefix"`
+	Origins   []string           `koanf:"origins"`
+	ExposeAll bool               `koanf:"expose-all"`
+	Methods   MethodPolicyConfig `koanf:"methods"`
 }
 
 var WSConfigDefault = WSConfig{
@@ -93,6 +116,7 @@ var WSConfigDefault = WSConfig{
 	RPCPrefix: node.DefaultConfig.WSPathPrefix,
 	Origins:   node.DefaultConfig.WSOrigins,
 	ExposeAll: node.DefaultConfig.WSExposeAll,
+	Methods:   MethodPolicyConfigDefault,
 }
 
 func (c WSConfig) Apply(stackConf *node.Config) {
@@ -102,6 +126,19 @@ func (c WSConfig) Apply(stackConf *node.Config) {
 	stackConf.WSPathPrefix = c.RPCPrefix
 	stackConf.WSOrigins = c.Origins
 	stackConf.WSExposeAll = c.ExposeAll
+	if c.Filtered() {
+		stackConf.WSHost = filteredUpstreamHost
+		stackConf.WSPort = 0
+	}
+}
+
+// Filtered returns whether the WS server has a method policy and so is served by the method filter.
+func (c WSConfig) Filtered() bool {
+	return c.Addr != "" && c.Methods.Enabled()
+}
+
+func (c WSConfig) ListenAddr() string {
+	return net.JoinHostPort(c.Addr, strconv.Itoa(c.Port))
 }
 
 func WSConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -111,6 +148,70 @@ func WSConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.String(prefix+".rpcprefix", WSConfigDefault.RPCPrefix, "WS path path prefix on which JSON-RPC is served. Use '/' to serve on all paths")
 	f.StringSlice(prefix+".origins", WSConfigDefault.Origins, "Origins from which to accept websockets requests")
 	f.Bool(prefix+".expose-all", WSConfigDefault.ExposeAll, "expose private api via websocket")
+	MethodPolicyConfigAddOptions(prefix+".methods", f)
+}
+
+// ValidateRPCTransports checks that HTTP and WS served on the same address are either both filtered or neither,
+// as the method filter and the node's own server can't share a port.
+func ValidateRPCTransports(httpConf HTTPConfig, wsConf WSConfig) error {
+	if err := httpConf.Methods.Validate(); err != nil {
+		return fmt.Errorf("http.methods: %w", err)
+	}
+	if err := wsConf.Methods.Validate(); err != nil {
+		return fmt.Errorf("ws.methods: %w", err)
+	}
+	if httpConf.Addr != "" && wsConf.Addr != "" && httpConf.Port == wsConf.Port && httpConf.Filtered() != wsConf.Filtered() {
+		return fmt.Errorf("http and ws share port %v, so must both or neither have method policies", httpConf.Port)
+	}
+	return nil
+}
+
+// The node's servers listen here, on an ephemeral port, when the method filter serves their configured addresses
+const filteredUpstreamHost = "127.0.0.1"
+
+// MethodPolicyConfig restricts the methods an RPC transport serves, beyond the namespaces in its api list.
+// Entries are full method names like eth_sendRawTransaction, or whole namespaces like eth_*. GraphQL can't be
+// filtered by method, so it isn't served over an HTTP transport with a method policy.
+type MethodPolicyConfig struct {
+	Allow []string `koanf:"allow"`
+	Deny  []string `koanf:"deny"`
+}
+
+var MethodPolicyConfigDefault = MethodPolicyConfig{
+	Allow: []string{},
+	Deny:  []string{},
+}
+
+func MethodPolicyConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".allow", MethodPolicyConfigDefault.Allow, "methods or namespace_* wildcards served over this transport, if set, while all others are rejected")
+	f.StringSlice(prefix+".deny", MethodPolicyConfigDefault.Deny, "methods or namespace_* wildcards rejected over this transport, even if allowed")
+}
+
+func (c MethodPolicyConfig) Enabled() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0
+}
+
+func (c MethodPolicyConfig) Validate() error {
+	_, err := rpcfilter.NewPolicy(c.Allow, c.Deny)
+	return err
+}
+
+// IPCConfig serves every API, including the private namespaces, over a local socket. The socket is only
+// accessible to the node's user, so it's the place for administrative namespaces kept off the public endpoints.
+type IPCConfig struct {
+	Path string `koanf:"path"`
+}
+
+var IPCConfigDefault = IPCConfig{
+	Path: "",
+}
+
+func (c IPCConfig) Apply(stackConf *node.Config) {
+	stackConf.IPCPath = c.Path
+}
+
+func IPCConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".path", IPCConfigDefault.Path, "Requested location to place the IPC endpoint. An empty path disables IPC.")
 }
 
 type GraphQLConfig struct {
@@ -13,11 +13,58 @@ import (
 const PASSWORD_NOT_SET = "PASSWORD_NOT_SET"
 
 type WalletConfig struct {
-	Pathname      string `koanf:"pathname"`
-	PasswordImpl  string `koanf:"password"`
-	PrivateKey    string `koanf:"private-key"`
-	Account       string `koanf:"account"`
-	OnlyCreateKey bool   `koanf:"only-create-key"`
+	Pathname       string               `koanf:"pathname"`
+	PasswordImpl   string               `koanf:"password"`
+	PrivateKey     string               `koanf:"private-key"`
+	Account        string               `koanf:"account"`
+	OnlyCreateKey  bool                 `koanf:"only-create-key"`
+	ExternalSigner ExternalSignerConfig `koanf:"external-signer"`
+	KMS            KMSConfig            `koanf:"kms"`
+}
+
+// ExternalSignerConfig delegates signing to a remote signer, such as clef or an HSM/KMS gateway,
+// speaking the web3 external signer JSON-RPC protocol, so the key never lives on the node machine.
+type ExternalSignerConfig struct {
+	URL     string `koanf:"url"`
+	Address string `koanf:"address"`
+}
+
+func (c *ExternalSignerConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+var ExternalSignerConfigDefault = ExternalSignerConfig{
+	URL:     "",
+	Address: "",
+}
+
+func ExternalSignerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".url", ExternalSignerConfigDefault.URL, "external signer endpoint (http(s), ws(s) or IPC path); if set, the wallet's keys are not used")
+	f.String(prefix+".address", ExternalSignerConfigDefault.Address, "account of the external signer to use (default is its first account)")
+}
+
+// KMSConfig delegates signing to a KMS or HSM serving the KeyManagement gRPC service, which signs digests with
+// a key it holds, so the key never lives on the node machine.
+type KMSConfig struct {
+	URL       string `koanf:"url"`
+	KeyID     string `koanf:"key-id"`
+	Plaintext bool   `koanf:"plaintext"`
+}
+
+func (c *KMSConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+var KMSConfigDefault = KMSConfig{
+	URL:       "",
+	KeyID:     "",
+	Plaintext: false,
+}
+
+func KMSConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".url", KMSConfigDefault.URL, "address of the KMS gRPC service; if set, the wallet's keys are not used")
+	f.String(prefix+".key-id", KMSConfigDefault.KeyID, "ID of the KMS key to sign with")
+	f.Bool(prefix+".plaintext", KMSConfigDefault.Plaintext, "connect to the KMS without TLS, for a KMS proxy on the local machine")
 }
 
 func (w *WalletConfig) Password() *string {
@@ -28,11 +75,13 @@ func (w *WalletConfig) Password() *string {
 }
 
 var WalletConfigDefault = WalletConfig{
-	Pathname:      "",
-	PasswordImpl:  "",
-	PrivateKey:    "",
-	Account:       "",
-	OnlyCreateKey: false,
+	Pathname:       "",
+	PasswordImpl:   "",
+	PrivateKey:     "",
+	Account:        "",
+	OnlyCreateKey:  false,
+	ExternalSigner: ExternalSignerConfigDefault,
+	KMS:            KMSConfigDefault,
 }
 
 func WalletConfigAddOptions(prefix string, f *flag.FlagSet, defaultPathname string) {
@@ -41,6 +90,8 @@ func WalletConfigAddOptions(prefix string, f *flag.FlagSet, defaultPathname stri
 	f.String(prefix+".private-key", WalletConfigDefault.PasswordImpl, "private key for wallet")
 	f.String(prefix+".account", WalletConfigDefault.Account, "account to use (default is first account in keystore)")
 	f.Bool(prefix+".only-create-key", WalletConfigDefault.OnlyCreateKey, "if true, creates new key then exits")
+	ExternalSignerConfigAddOptions(prefix+".external-signer", f)
+	KMSConfigAddOptions(prefix+".kms", f)
 }
 
 func (w *WalletConfig) ResolveDirectoryNames(chain string) {
@@ -0,0 +1,73 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util"
+)
+
+// nitro bench ...
+
+func startBench(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nitro bench inbox [options]")
+	}
+	switch strings.ToLower(args[0]) {
+	case "inbox":
+		return startInboxBench(ctx, args[1:])
+	default:
+		return fmt.Errorf("nitro bench '%s' not supported, valid argument is 'inbox'", args[0])
+	}
+}
+
+// nitro bench inbox
+
+type InboxBenchCmdConfig struct {
+	Inbox      arbnode.InboxBenchConfig `koanf:"inbox"`
+	ConfConfig genericconf.ConfConfig   `koanf:"conf"`
+}
+
+func parseInboxBenchConfig(args []string) (*InboxBenchCmdConfig, error) {
+	f := flag.NewFlagSet("nitro bench inbox", flag.ContinueOnError)
+	arbnode.InboxBenchConfigAddOptions("inbox", f)
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config InboxBenchCmdConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func startInboxBench(ctx context.Context, args []string) error {
+	config, err := parseInboxBenchConfig(args)
+	if err != nil {
+		return err
+	}
+	result, err := arbnode.BenchInbox(ctx, &config.Inbox)
+	if err != nil {
+		return err
+	}
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(output))
+	return err
+}
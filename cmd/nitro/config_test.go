@@ -29,8 +29,36 @@ func TestValidatorConfig(t *testing.T) {
 	testhelpers.RequireImpl(t, err)
 }
 
+func TestExternalSignerConfig(t *testing.T) {
+	args := strings.Split("--persistent.chain /tmp/data --init.dev-init --node.l1-reader.enable=false --l1.chain-id 5 --l2.chain-id 421613 --l1.wallet.external-signer.url http://localhost:8550 --l1.wallet.external-signer.address 0x0000000000000000000000000000000000000001 --http.addr 0.0.0.0 --ws.addr 0.0.0.0 --node.sequencer.enable --node.feed.output.enable --node.feed.output.port 9642", " ")
+	_, l1Wallet, _, _, _, err := ParseNode(context.Background(), args)
+	testhelpers.RequireImpl(t, err)
+	if l1Wallet.ExternalSigner.URL != "http://localhost:8550" {
+		testhelpers.FailImpl(t, "external signer url not parsed", l1Wallet.ExternalSigner.URL)
+	}
+}
+
 func TestAggregatorConfig(t *testing.T) {
 	args := strings.Split("--persistent.chain /tmp/data --init.dev-init --node.l1-reader.enable=false --l1.chain-id 5 --l2.chain-id 421613 --l1.wallet.pathname /l1keystore --l1.wallet.password passphrase --http.addr 0.0.0.0 --ws.addr 0.0.0.0 --node.sequencer.enable --node.feed.output.enable --node.feed.output.port 9642 --node.data-availability.enable --node.data-availability.rpc-aggregator.backends {[\"url\":\"http://localhost:8547\",\"pubkey\":\"abc==\",\"signerMask\":0x1]}", " ")
 	_, _, _, _, _, err := ParseNode(context.Background(), args)
 	testhelpers.RequireImpl(t, err)
 }
+
+func TestRPCMethodPolicyConfig(t *testing.T) {
+	base := "--persistent.chain /tmp/data --init.dev-init --node.l1-reader.enable=false --l1.chain-id 5 --l2.chain-id 421613 --l1.wallet.pathname /l1keystore --l1.wallet.password passphrase --http.addr 0.0.0.0 --ws.addr 0.0.0.0 --ipc.path /tmp/data/nitro.ipc "
+	args := strings.Split(base+"--http.methods.allow eth_*,net_version --http.methods.deny eth_sign --ws.methods.deny debug_*", " ")
+	_, _, _, _, _, err := ParseNode(context.Background(), args)
+	testhelpers.RequireImpl(t, err)
+
+	args = strings.Split(base+"--http.methods.allow eth_* --ws.port 8547", " ")
+	_, _, _, _, _, err = ParseNode(context.Background(), args)
+	if err == nil {
+		testhelpers.FailImpl(t, "http and ws sharing a port with only one filtered should be rejected")
+	}
+
+	args = strings.Split(base+"--http.methods.allow eth", " ")
+	_, _, _, _, _, err = ParseNode(context.Background(), args)
+	if err == nil {
+		testhelpers.FailImpl(t, "invalid method policy entry should be rejected")
+	}
+}
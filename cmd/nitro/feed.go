@@ -0,0 +1,124 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcastclient"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
+)
+
+// nitro feed ...
+//
+// nitro feed replay isn't handled here: it runs the node itself, fed from an archive by --node.feed.input.replay-path.
+
+func startFeed(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nitro feed [record|replay] [options]")
+	}
+	switch strings.ToLower(args[0]) {
+	case "record":
+		return startFeedRecord(ctx, args[1:])
+	default:
+		return fmt.Errorf("nitro feed '%s' not supported, valid arguments are 'record' and 'replay'", args[0])
+	}
+}
+
+// nitro feed record
+
+type FeedRecordCmdConfig struct {
+	Feed       broadcastclient.BroadcastClientConfig `koanf:"feed"`
+	Archive    string                                `koanf:"archive"`
+	LogLevel   int                                   `koanf:"log-level"`
+	ConfConfig genericconf.ConfConfig                `koanf:"conf"`
+}
+
+func parseFeedRecordConfig(args []string) (*FeedRecordCmdConfig, error) {
+	f := flag.NewFlagSet("nitro feed record", flag.ContinueOnError)
+	broadcastclient.BroadcastClientConfigAddOptions("feed", f)
+	f.String("archive", "", "feed archive to append the feed to, created if it doesn't exist (suffixed with the URL's index if there are several)")
+	f.Int("log-level", int(log.LvlInfo), "log level")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config FeedRecordCmdConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if !config.Feed.Enable() {
+		return nil, fmt.Errorf("nitro feed record requires --feed.url")
+	}
+	if config.Archive == "" {
+		return nil, fmt.Errorf("nitro feed record requires --archive")
+	}
+	return &config, nil
+}
+
+// discardingStreamer drops the messages it's given, as recording needs only the feed's client.
+type discardingStreamer struct{}
+
+func (discardingStreamer) AddBroadcastMessages(arbutil.MessageIndex, []arbstate.MessageWithMetadata) error {
+	return nil
+}
+
+func startFeedRecord(ctx context.Context, args []string) error {
+	config, err := parseFeedRecordConfig(args)
+	if err != nil {
+		return err
+	}
+	if err := initLog("plaintext", log.Lvl(config.LogLevel)); err != nil {
+		return err
+	}
+	compression, err := wsbroadcastserver.ParseCompression(config.Feed.Compression)
+	if err != nil {
+		return err
+	}
+
+	var clients []*broadcastclient.BroadcastClient
+	for i, address := range config.Feed.URLs {
+		path := config.Archive
+		if len(config.Feed.URLs) > 1 {
+			path = fmt.Sprintf("%v.%v", path, i)
+		}
+		archive, err := broadcastclient.OpenFeedArchive(path)
+		if err != nil {
+			return err
+		}
+		client := broadcastclient.NewBroadcastClient(address, nil, config.Feed.Timeout, discardingStreamer{})
+		client.SetCompression(compression)
+		client.SetToken(config.Feed.Token)
+		client.SetRecorder(archive)
+		clients = append(clients, client)
+		log.Info("recording feed", "url", address, "archive", path)
+	}
+	for _, client := range clients {
+		client.Start(ctx)
+	}
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+	for _, client := range clients {
+		// closes the client's archive
+		client.StopAndWait()
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -32,7 +33,6 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
-	"github.com/ethereum/go-ethereum/metrics/exp"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/knadh/koanf"
@@ -47,6 +47,9 @@ import (
 	"github.com/offchainlabs/nitro/cmd/genericconf"
 	"github.com/offchainlabs/nitro/cmd/util"
 	"github.com/offchainlabs/nitro/statetransfer"
+	"github.com/offchainlabs/nitro/util/openmetrics"
+	"github.com/offchainlabs/nitro/util/rpcfilter"
+	"github.com/offchainlabs/nitro/util/statehealer"
 
 	_ "github.com/ethereum/go-ethereum/eth/tracers/js"
 	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
@@ -182,6 +185,24 @@ func downloadInit(ctx context.Context, initConfig *InitConfig) (string, error) {
 	}
 }
 
+func extractInitArchive(initFile string, dir string) error {
+	reader, err := os.Open(initFile)
+	if err != nil {
+		return fmt.Errorf("couln't open init '%v' archive: %w", initFile, err)
+	}
+	defer reader.Close()
+	stat, err := reader.Stat()
+	if err != nil {
+		return err
+	}
+	log.Info("extracting downloaded init archive", "size", fmt.Sprintf("%dMB", stat.Size()/1024/1024))
+	err = extract.Archive(context.Background(), reader, dir, nil)
+	if err != nil {
+		return fmt.Errorf("couln't extract init archive '%v' err:%w", initFile, err)
+	}
+	return nil
+}
+
 func validateBlockChain(blockChain *core.BlockChain, expectedChainId *big.Int) error {
 	statedb, err := blockChain.State()
 	if err != nil {
@@ -201,6 +222,16 @@ func validateBlockChain(blockChain *core.BlockChain, expectedChainId *big.Int) e
 	return nil
 }
 
+// healingChainDb wraps an existing chain's database to heal state missing from it as it's read, if state
+// healing sources are configured. The node closes the healer when it stops.
+func healingChainDb(ctx context.Context, config *NodeConfig, chainDb ethdb.Database) (ethdb.Database, error) {
+	healer, err := statehealer.Open(ctx, &config.Node.StateHealer, chainDb)
+	if err != nil || healer == nil {
+		return chainDb, err
+	}
+	return healer.Database(), nil
+}
+
 func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeConfig, chainId *big.Int, cacheConfig *core.CacheConfig) (ethdb.Database, *core.BlockChain, error) {
 	if !config.Init.Force {
 		if readOnlyDb, err := stack.OpenDatabaseWithFreezer("l2chaindata", 0, 0, "", "", true); err == nil {
@@ -210,6 +241,10 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 				if err != nil {
 					return nil, nil, err
 				}
+				chainDb, err = healingChainDb(ctx, config, chainDb)
+				if err != nil {
+					return nil, nil, err
+				}
 				l2BlockChain, err := arbnode.GetBlockChain(chainDb, cacheConfig, chainConfig, &config.Node)
 				if err != nil {
 					return nil, nil, err
@@ -224,24 +259,24 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 		}
 	}
 
+	if config.Init.Url != "" && config.Init.SnapshotUrl != "" {
+		return nil, nil, fmt.Errorf("init from either a url or a snapshot server, not both")
+	}
 	initFile, err := downloadInit(ctx, &config.Init)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	if initFile != "" {
-		reader, err := os.Open(initFile)
-		if err != nil {
-			return nil, nil, fmt.Errorf("couln't open init '%v' archive: %w", initFile, err)
-		}
-		stat, err := reader.Stat()
-		if err != nil {
+		if err := extractInitArchive(initFile, stack.InstanceDir()); err != nil {
 			return nil, nil, err
 		}
-		log.Info("extracting downloaded init archive", "size", fmt.Sprintf("%dMB", stat.Size()/1024/1024))
-		err = extract.Archive(context.Background(), reader, stack.InstanceDir(), nil)
+	}
+	var snapshot *arbnode.SnapshotManifest
+	if config.Init.SnapshotUrl != "" {
+		snapshot, err = downloadSnapshot(ctx, &config.Init, chainId, stack.InstanceDir())
 		if err != nil {
-			return nil, nil, fmt.Errorf("couln't extract init archive '%v' err:%w", initFile, err)
+			return nil, nil, err
 		}
 	}
 
@@ -251,6 +286,12 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 	if err != nil {
 		return nil, nil, err
 	}
+	if snapshot != nil {
+		// The manifest's checksums only show the files are the ones the peer meant to serve
+		if hash := rawdb.ReadCanonicalHash(chainDb, snapshot.Block.Number); hash != snapshot.Block.Hash {
+			return nil, nil, fmt.Errorf("snapshot database has block %v with hash %v, but its manifest says %v", snapshot.Block.Number, hash, snapshot.Block.Hash)
+		}
+	}
 
 	if config.Init.ImportFile != "" {
 		initDataReader, err = statetransfer.NewJsonInitDataReader(config.Init.ImportFile)
@@ -292,6 +333,10 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 		if chainConfig == nil {
 			panic("No initialization mode supplied, chain data not in Db")
 		}
+		chainDb, err = healingChainDb(ctx, config, chainDb)
+		if err != nil {
+			return nil, nil, err
+		}
 		l2BlockChain, err = arbnode.GetBlockChain(chainDb, cacheConfig, chainConfig, &config.Node)
 		if err != nil {
 			panic(err)
@@ -345,11 +390,89 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 	return chainDb, l2BlockChain, nil
 }
 
+// startRPCFilters serves the HTTP and WS transports with method policies on their configured addresses,
+// passing the permitted calls to the stack's servers listening on loopback.
+func startRPCFilters(stack *node.Node, config *NodeConfig) ([]*rpcfilter.Server, error) {
+	var httpHandler, wsHandler http.Handler
+	if config.HTTP.Filtered() {
+		policy, err := rpcfilter.NewPolicy(config.HTTP.Methods.Allow, config.HTTP.Methods.Deny)
+		if err != nil {
+			return nil, err
+		}
+		httpHandler, err = rpcfilter.NewHTTPHandler(policy, stack.HTTPEndpoint())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.WS.Filtered() {
+		policy, err := rpcfilter.NewPolicy(config.WS.Methods.Allow, config.WS.Methods.Deny)
+		if err != nil {
+			return nil, err
+		}
+		wsHandler, err = rpcfilter.NewWSHandler(policy, stack.WSEndpoint())
+		if err != nil {
+			return nil, err
+		}
+	}
+	var servers []*rpcfilter.Server
+	if httpHandler != nil && wsHandler != nil && config.HTTP.ListenAddr() == config.WS.ListenAddr() {
+		servers = append(servers, rpcfilter.NewServer(config.HTTP.ListenAddr(), httpHandler, wsHandler))
+	} else {
+		if httpHandler != nil {
+			servers = append(servers, rpcfilter.NewServer(config.HTTP.ListenAddr(), httpHandler, nil))
+		}
+		if wsHandler != nil {
+			servers = append(servers, rpcfilter.NewServer(config.WS.ListenAddr(), nil, wsHandler))
+		}
+	}
+	for i, server := range servers {
+		addr, err := server.Start()
+		if err != nil {
+			for _, started := range servers[:i] {
+				_ = started.Stop(context.Background())
+			}
+			return nil, err
+		}
+		log.Info("rpc method filter started", "addr", addr)
+	}
+	return servers, nil
+}
+
 func main() {
 	ctx := context.Background()
 
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := startBench(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		if err := startSnapshot(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	args := os.Args[1:]
+	replayingFeed := false
+	if len(args) > 1 && args[0] == "feed" && args[1] == "replay" {
+		// nitro feed replay runs the node, fed from a feed archive rather than a live feed
+		args = args[2:]
+		replayingFeed = true
+	} else if len(args) > 0 && args[0] == "feed" {
+		if err := startFeed(ctx, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	vcsRevision, vcsTime := genericconf.GetVersion()
-	nodeConfig, l1Wallet, l2DevWallet, l1Client, l1ChainId, err := ParseNode(ctx, os.Args[1:])
+	nodeConfig, l1Wallet, l2DevWallet, l1Client, l1ChainId, err := ParseNode(ctx, args)
 	if err != nil {
 		fmt.Printf("\nrevision: %v, vcs.time: %v\n", vcsRevision, vcsTime)
 		printSampleUsage(os.Args[0])
@@ -366,6 +489,17 @@ func main() {
 
 	log.Info("Running Arbitrum nitro node", "revision", vcsRevision, "vcs.time", vcsTime)
 
+	if replayingFeed {
+		if nodeConfig.Node.Feed.Input.ReplayPath == "" {
+			flag.Usage()
+			panic("nitro feed replay requires --node.feed.input.replay-path")
+		}
+		if nodeConfig.Node.Feed.Input.Enable() {
+			log.Warn("not connecting to the live feed while replaying a feed archive", "urls", nodeConfig.Node.Feed.Input.URLs)
+			nodeConfig.Node.Feed.Input.URLs = []string{}
+		}
+	}
+
 	if nodeConfig.Node.Dangerous.NoL1Listener {
 		nodeConfig.Node.L1Reader.Enable = false
 		nodeConfig.Node.BatchPoster.Enable = false
@@ -414,9 +548,13 @@ func main() {
 				panic(err)
 			}
 
-			daSigner, err = arbnode.GetSignerFromWallet(l1Wallet)
-			if err != nil {
-				panic(err)
+			if l1Wallet.ExternalSigner.Enabled() {
+				log.Warn("L1 wallet uses an external signer, so DAS stores and build attestations won't be signed with it")
+			} else {
+				daSigner, err = arbnode.GetSignerFromWallet(l1Wallet)
+				if err != nil {
+					panic(err)
+				}
 			}
 		}
 	} else if l1Client != nil {
@@ -445,6 +583,7 @@ func main() {
 	nodeConfig.HTTP.Apply(&stackConf)
 	nodeConfig.WS.Apply(&stackConf)
 	nodeConfig.GraphQL.Apply(&stackConf)
+	nodeConfig.IPC.Apply(&stackConf)
 	if nodeConfig.WS.ExposeAll {
 		stackConf.WSModules = append(stackConf.WSModules, "personal")
 	}
@@ -472,6 +611,10 @@ func main() {
 		panic(err)
 	}
 
+	if err := arbnode.MigrateDataDir(ctx, stack, &nodeConfig.Node.Migrations); err != nil {
+		panic(fmt.Sprintf("Failed to migrate data directory: %v", err))
+	}
+
 	arbDb, err := stack.OpenDatabase("arbitrumdata", 0, 0, "", false)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to open database: %v", err))
@@ -491,7 +634,7 @@ func main() {
 
 		if nodeConfig.MetricsServer.Addr != "" {
 			address := fmt.Sprintf("%v:%v", nodeConfig.MetricsServer.Addr, nodeConfig.MetricsServer.Port)
-			exp.Setup(address)
+			openmetrics.Setup(address)
 		}
 	}
 
@@ -516,6 +659,9 @@ func main() {
 	}
 	gqlConf := nodeConfig.GraphQL
 	if gqlConf.Enable {
+		if currentNode.Backend == nil {
+			panic("GraphQL serves the local blockchain, so can't be enabled when executing remotely or in light mode")
+		}
 		if err := graphql.New(stack, currentNode.Backend.APIBackend(), gqlConf.CORSDomain, gqlConf.VHosts); err != nil {
 			panic(fmt.Sprintf("Failed to register the GraphQL service: %v", err))
 		}
@@ -523,6 +669,10 @@ func main() {
 	if err := stack.Start(); err != nil {
 		panic(fmt.Sprintf("Error starting protocol stack: %v\n", err))
 	}
+	rpcFilterServers, err := startRPCFilters(stack, nodeConfig)
+	if err != nil {
+		panic(fmt.Sprintf("Error starting rpc method filters: %v\n", err))
+	}
 
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
@@ -531,6 +681,11 @@ func main() {
 	// cause future ctrl+c's to panic
 	close(sigint)
 
+	for _, server := range rpcFilterServers {
+		if err := server.Stop(context.Background()); err != nil {
+			log.Warn("error stopping rpc method filter", "err", err)
+		}
+	}
 	if err := stack.Close(); err != nil {
 		panic(fmt.Sprintf("Error closing stack: %v\n", err))
 	}
@@ -539,6 +694,9 @@ func main() {
 type InitConfig struct {
 	Force           bool          `koanf:"force"`
 	Url             string        `koanf:"url"`
+	SnapshotUrl     string        `koanf:"snapshot-url"`
+	SnapshotKind    string        `koanf:"snapshot-kind"`
+	SnapshotHash    string        `koanf:"snapshot-block-hash"`
 	DownloadPath    string        `koanf:"download-path"`
 	DownloadPoll    time.Duration `koanf:"download-poll"`
 	DevInit         bool          `koanf:"dev-init"`
@@ -553,6 +711,9 @@ type InitConfig struct {
 var InitConfigDefault = InitConfig{
 	Force:           false,
 	Url:             "",
+	SnapshotUrl:     "",
+	SnapshotKind:    arbnode.SnapshotKindPruned,
+	SnapshotHash:    "",
 	DownloadPath:    "/tmp/",
 	DownloadPoll:    time.Minute,
 	DevInit:         false,
@@ -566,6 +727,9 @@ var InitConfigDefault = InitConfig{
 func InitConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".force", InitConfigDefault.Force, "if true: in case database exists init code will be reexecuted and genesis block compared to database")
 	f.String(prefix+".url", InitConfigDefault.Url, "url to download initializtion data - will poll if download fails")
+	f.String(prefix+".snapshot-url", InitConfigDefault.SnapshotUrl, "url of a peer's snapshot server to download the latest snapshot from and init with")
+	f.String(prefix+".snapshot-kind", InitConfigDefault.SnapshotKind, "kind of snapshot to init with from the snapshot server, pruned or archive")
+	f.String(prefix+".snapshot-block-hash", InitConfigDefault.SnapshotHash, "hash of the block the snapshot from the snapshot server must be of (default accepts any block)")
 	f.String(prefix+".download-path", InitConfigDefault.DownloadPath, "path to save temp downloaded file")
 	f.Duration(prefix+".download-poll", InitConfigDefault.DownloadPoll, "how long to wait between polling attempts")
 	f.Bool(prefix+".dev-init", InitConfigDefault.DevInit, "init with dev data (1 account with balance) instead of file import")
@@ -587,6 +751,7 @@ type NodeConfig struct {
 	Persistent    conf.PersistentConfig           `koanf:"persistent"`
 	HTTP          genericconf.HTTPConfig          `koanf:"http"`
 	WS            genericconf.WSConfig            `koanf:"ws"`
+	IPC           genericconf.IPCConfig           `koanf:"ipc"`
 	GraphQL       genericconf.GraphQLConfig       `koanf:"graphql"`
 	Metrics       bool                            `koanf:"metrics"`
 	MetricsServer genericconf.MetricsServerConfig `koanf:"metrics-server"`
@@ -603,6 +768,7 @@ var NodeConfigDefault = NodeConfig{
 	Persistent:    conf.PersistentConfigDefault,
 	HTTP:          genericconf.HTTPConfigDefault,
 	WS:            genericconf.WSConfigDefault,
+	IPC:           genericconf.IPCConfigDefault,
 	Metrics:       false,
 	MetricsServer: genericconf.MetricsServerConfigDefault,
 }
@@ -617,6 +783,7 @@ func NodeConfigAddOptions(f *flag.FlagSet) {
 	conf.PersistentConfigAddOptions("persistent", f)
 	genericconf.HTTPConfigAddOptions("http", f)
 	genericconf.WSConfigAddOptions("ws", f)
+	genericconf.IPCConfigAddOptions("ipc", f)
 	genericconf.GraphQLConfigAddOptions("graphql", f)
 	f.Bool("metrics", NodeConfigDefault.Metrics, "enable metrics")
 	genericconf.MetricsServerAddOptions("metrics-server", f)
@@ -630,6 +797,7 @@ func (c *NodeConfig) ResolveDirectoryNames() error {
 	}
 	c.L1.ResolveDirectoryNames(c.Persistent.Chain)
 	c.L2.ResolveDirectoryNames(c.Persistent.Chain)
+	c.Node.BatchPoster.FallbackWallet.ResolveDirectoryNames(c.Persistent.Chain)
 
 	return nil
 }
@@ -745,13 +913,21 @@ func ParseNode(ctx context.Context, args []string) (*NodeConfig, *genericconf.Wa
 		return nil, nil, nil, nil, nil, err
 	}
 
-	// Don't print wallet passwords
+	// Don't print wallet passwords or alerting secrets
 	if nodeConfig.Conf.Dump {
 		err = util.DumpConfig(k, map[string]interface{}{
-			"l1.wallet.password":    "",
-			"l1.wallet.private-key": "",
-			"l2.wallet.password":    "",
-			"l2.wallet.private-key": "",
+			"l1.wallet.password":                              "",
+			"l1.wallet.private-key":                           "",
+			"l2.wallet.password":                              "",
+			"l2.wallet.private-key":                           "",
+			"node.batch-poster.fallback-wallet.password":      "",
+			"node.batch-poster.fallback-wallet.private-key":   "",
+			"node.validator-coordinator.signing-key":          "",
+			"node.validator.alerts.pagerduty.routing-key":     "",
+			"node.validator.alerts.smtp.password":             "",
+			"node.validator.alerts.webhook.hmac-secret":       "",
+			"node.validator.wallets.challenge.private-key":    "",
+			"node.validator.wallets.confirmation.private-key": "",
 		})
 		if err != nil {
 			return nil, nil, nil, nil, nil, err
@@ -762,6 +938,11 @@ func ParseNode(ctx context.Context, args []string) (*NodeConfig, *genericconf.Wa
 		return nil, nil, nil, nil, nil, errors.New("--persistent.chain not specified")
 	}
 
+	err = genericconf.ValidateRPCTransports(nodeConfig.HTTP, nodeConfig.WS)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
 	err = nodeConfig.ResolveDirectoryNames()
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
@@ -0,0 +1,220 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util"
+)
+
+// nitro snapshot ...
+
+func startSnapshot(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nitro snapshot [create|serve] [options]")
+	}
+	switch strings.ToLower(args[0]) {
+	case "create":
+		return startSnapshotCreate(ctx, args[1:])
+	case "serve":
+		return startSnapshotServe(args[1:])
+	default:
+		return fmt.Errorf("nitro snapshot '%s' not supported, valid arguments are 'create' and 'serve'", args[0])
+	}
+}
+
+// nitro snapshot create
+
+type SnapshotCreateCmdConfig struct {
+	Chain      string                 `koanf:"chain"`
+	Kind       string                 `koanf:"kind"`
+	Output     string                 `koanf:"output"`
+	WorkDir    string                 `koanf:"work-dir"`
+	LogLevel   int                    `koanf:"log-level"`
+	ConfConfig genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseSnapshotCreateConfig(args []string) (*SnapshotCreateCmdConfig, error) {
+	f := flag.NewFlagSet("nitro snapshot create", flag.ContinueOnError)
+	f.String("chain", "", "data directory of the node to snapshot, as given to it by --persistent.chain (the node must be stopped)")
+	f.String("kind", arbnode.SnapshotKindPruned, "kind of snapshot to create, pruned or archive")
+	f.String("output", "", "directory to write the snapshot to, laid out as the snapshot publisher publishes them")
+	f.String("work-dir", "", "directory to export the databases to before packaging them (defaults to a directory in the output directory)")
+	f.Int("log-level", int(log.LvlInfo), "log level")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config SnapshotCreateCmdConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if config.Chain == "" {
+		return nil, fmt.Errorf("nitro snapshot create requires --chain")
+	}
+	if config.Output == "" {
+		return nil, fmt.Errorf("nitro snapshot create requires --output")
+	}
+	if config.WorkDir == "" {
+		config.WorkDir = filepath.Join(config.Output, "work")
+	}
+	return &config, nil
+}
+
+func startSnapshotCreate(ctx context.Context, args []string) error {
+	config, err := parseSnapshotCreateConfig(args)
+	if err != nil {
+		return err
+	}
+	if err := initLog("plaintext", log.Lvl(config.LogLevel)); err != nil {
+		return err
+	}
+
+	// The stack locks the data directory, so the snapshot can't be taken while the node is running
+	stackConf := node.DefaultConfig
+	stackConf.DataDir = config.Chain
+	stackConf.HTTPHost = ""
+	stackConf.WSHost = ""
+	stackConf.P2P.ListenAddr = ""
+	stackConf.P2P.NoDial = true
+	stackConf.P2P.NoDiscovery = true
+	stack, err := node.New(&stackConf)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+	chainDb, err := stack.OpenDatabaseWithFreezer("l2chaindata", 0, 0, "", "", false)
+	if err != nil {
+		return err
+	}
+	defer chainDb.Close()
+	arbDb, err := stack.OpenDatabase("arbitrumdata", 0, 0, "", false)
+	if err != nil {
+		return err
+	}
+	defer arbDb.Close()
+	chainConfig := arbnode.TryReadStoredChainConfig(chainDb)
+	if chainConfig == nil {
+		return fmt.Errorf("no chain found in %v", stack.InstanceDir())
+	}
+	bc, err := arbnode.GetBlockChain(chainDb, arbnode.DefaultCacheConfigFor(stack, false), chainConfig, &arbnode.ConfigDefault)
+	if err != nil {
+		return err
+	}
+	defer bc.Stop()
+
+	ancientDir := filepath.Join(stack.ResolvePath("l2chaindata"), "ancient")
+	manifest, err := arbnode.ExportSnapshot(ctx, config.Kind, chainDb, arbDb, ancientDir, config.WorkDir, bc, config.Output)
+	if err != nil {
+		return err
+	}
+	output, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(output))
+	return err
+}
+
+// nitro snapshot serve
+
+type SnapshotServeCmdConfig struct {
+	Dir        string                 `koanf:"dir"`
+	Addr       string                 `koanf:"addr"`
+	LogLevel   int                    `koanf:"log-level"`
+	ConfConfig genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseSnapshotServeConfig(args []string) (*SnapshotServeCmdConfig, error) {
+	f := flag.NewFlagSet("nitro snapshot serve", flag.ContinueOnError)
+	f.String("dir", "", "directory of snapshots to serve, as written by nitro snapshot create or the snapshot publisher")
+	f.String("addr", arbnode.DefaultSnapshotServerConfig.Addr, "address to serve snapshots on")
+	f.Int("log-level", int(log.LvlInfo), "log level")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config SnapshotServeCmdConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if config.Dir == "" {
+		return nil, fmt.Errorf("nitro snapshot serve requires --dir")
+	}
+	return &config, nil
+}
+
+func startSnapshotServe(args []string) error {
+	config, err := parseSnapshotServeConfig(args)
+	if err != nil {
+		return err
+	}
+	if err := initLog("plaintext", log.Lvl(config.LogLevel)); err != nil {
+		return err
+	}
+	server := arbnode.NewSnapshotServer(config.Addr, config.Dir)
+	addr, err := server.Start()
+	if err != nil {
+		return err
+	}
+	log.Info("serving snapshots", "addr", addr, "dir", config.Dir)
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+	return server.Stop(context.Background())
+}
+
+// downloadSnapshot downloads the latest snapshot from a peer's snapshot server and extracts it to dir,
+// returning its manifest.
+func downloadSnapshot(ctx context.Context, initConfig *InitConfig, chainId *big.Int, dir string) (*arbnode.SnapshotManifest, error) {
+	var expectedHash common.Hash
+	if initConfig.SnapshotHash != "" {
+		hash, err := hexutil.Decode(initConfig.SnapshotHash)
+		if err != nil || len(hash) != common.HashLength {
+			return nil, fmt.Errorf("invalid snapshot block hash %v", initConfig.SnapshotHash)
+		}
+		expectedHash = common.BytesToHash(hash)
+	}
+	manifest, files, err := arbnode.DownloadSnapshot(ctx, initConfig.SnapshotUrl, initConfig.SnapshotKind, expectedHash, initConfig.DownloadPath)
+	if err != nil {
+		return nil, fmt.Errorf("downloading snapshot from %v: %w", initConfig.SnapshotUrl, err)
+	}
+	if manifest.ChainID == nil || manifest.ChainID.ToInt().Cmp(chainId) != 0 {
+		return nil, fmt.Errorf("snapshot is of chain %v, not %v", manifest.ChainID, chainId)
+	}
+	for _, file := range files {
+		if err := extractInitArchive(file, dir); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(file); err != nil {
+			log.Warn("failed to remove downloaded snapshot file", "file", file, "err", err)
+		}
+	}
+	log.Info("initialized from snapshot", "block", manifest.Block.Number, "hash", manifest.Block.Hash, "batchCount", manifest.L1Checkpoint.BatchCount)
+	return manifest, nil
+}
@@ -73,11 +73,22 @@ func startup() error {
 		Queue:         relayConfig.Node.Feed.Output.Queue,
 		Workers:       relayConfig.Node.Feed.Output.Workers,
 		MaxSendQueue:  relayConfig.Node.Feed.Output.MaxSendQueue,
+
+		EnableCompression:  relayConfig.Node.Feed.Output.EnableCompression,
+		CompressionMinSize: relayConfig.Node.Feed.Output.CompressionMinSize,
+		Backfill:           relayConfig.Node.Feed.Output.Backfill,
+		ArchiveURL:         relayConfig.Node.Feed.Output.ArchiveURL,
+		Auth:               relayConfig.Node.Feed.Output.Auth,
+		OverflowPolicy:     relayConfig.Node.Feed.Output.OverflowPolicy,
 	}
 
+	// Without L1 to look up the sequencer set, the relay passes the sequencer's signatures on for its clients to check
 	clientConf := broadcastclient.BroadcastClientConfig{
 		Timeout: relayConfig.Node.Feed.Input.Timeout,
 		URLs:    relayConfig.Node.Feed.Input.URLs,
+
+		Compression: relayConfig.Node.Feed.Input.Compression,
+		Token:       relayConfig.Node.Feed.Input.Token,
 	}
 
 	defer log.Info("Cleanly shutting down relay")
@@ -86,7 +97,10 @@ func startup() error {
 	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 
 	// Start up an arbitrum sequencer relay
-	newRelay := relay.NewRelay(serverConf, clientConf)
+	newRelay, err := relay.NewRelay(serverConf, clientConf, relayConfig.Backlog, relayConfig.Node.Feed.GRPC, relayConfig.Cluster)
+	if err != nil {
+		return err
+	}
 	err = newRelay.Start(ctx)
 	if err != nil {
 		return err
@@ -101,6 +115,8 @@ type RelayConfig struct {
 	LogLevel int                    `koanf:"log-level"`
 	LogType  string                 `koanf:"log-type"`
 	Node     RelayNodeConfig        `koanf:"node"`
+	Backlog  relay.BacklogConfig    `koanf:"backlog"`
+	Cluster  relay.ClusterConfig    `koanf:"cluster"`
 }
 
 var RelayConfigDefault = RelayConfig{
@@ -108,6 +124,8 @@ var RelayConfigDefault = RelayConfig{
 	LogLevel: int(log.LvlInfo),
 	LogType:  "plaintext",
 	Node:     RelayNodeConfigDefault,
+	Backlog:  relay.DefaultBacklogConfig,
+	Cluster:  relay.DefaultClusterConfig,
 }
 
 func RelayConfigAddOptions(f *flag.FlagSet) {
@@ -115,6 +133,8 @@ func RelayConfigAddOptions(f *flag.FlagSet) {
 	f.Int("log-level", RelayConfigDefault.LogLevel, "log level")
 	f.String("log-type", RelayConfigDefault.LogType, "log type")
 	RelayNodeConfigAddOptions("node", f)
+	relay.BacklogConfigAddOptions("backlog", f)
+	relay.ClusterConfigAddOptions("cluster", f)
 }
 
 type RelayNodeConfig struct {
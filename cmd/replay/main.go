@@ -131,7 +131,10 @@ func main() {
 		if dasEnabled {
 			dasReader = &PreimageDASReader{}
 		}
-		inboxMultiplexer := arbstate.NewInboxMultiplexer(WavmInbox{}, delayedMessagesRead, dasReader)
+		arbosVersion := func() (uint64, error) {
+			return arbosState.ArbOSVersion(statedb), nil
+		}
+		inboxMultiplexer := arbstate.NewInboxMultiplexer(WavmInbox{}, delayedMessagesRead, dasReader, arbosVersion)
 		ctx := context.Background()
 		message, err := inboxMultiplexer.Pop(ctx)
 		if err != nil {
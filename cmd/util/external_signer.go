@@ -0,0 +1,104 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/util/kmssigner"
+)
+
+// ExternalSignerTransactOpts returns transact opts which sign with a remote signer over the web3
+// external signer protocol (account_signTransaction), so the private key never lives on this machine.
+func ExternalSignerTransactOpts(config *genericconf.ExternalSignerConfig, chainId *big.Int) (*bind.TransactOpts, error) {
+	signer, err := external.NewExternalSigner(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	var account accounts.Account
+	if config.Address != "" {
+		if !common.IsHexAddress(config.Address) {
+			return nil, fmt.Errorf("invalid external signer address \"%v\"", config.Address)
+		}
+		account.Address = common.HexToAddress(config.Address)
+		if !signer.Contains(account) {
+			return nil, fmt.Errorf("external signer %v doesn't hold account %v", config.URL, account.Address)
+		}
+	} else {
+		signerAccounts := signer.Accounts()
+		if len(signerAccounts) == 0 {
+			return nil, fmt.Errorf("external signer %v has no accounts", config.URL)
+		}
+		account = signerAccounts[0]
+	}
+	return &bind.TransactOpts{
+		From: account.Address,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != account.Address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(account, tx, chainId)
+		},
+		Context: context.Background(),
+	}, nil
+}
+
+// HashSigner signs 32 byte digests with a key it holds, returning [R || S || V] signatures with V of 0 or 1.
+// It's the extension point for KMS backends, such as the gRPC KMS client, which can't sign whole transactions.
+type HashSigner interface {
+	Address() common.Address
+	SignHash(ctx context.Context, hash []byte) ([]byte, error)
+}
+
+// HashSignerTransactOpts returns transact opts which sign the transaction hash with a HashSigner.
+func HashSignerTransactOpts(signer HashSigner, chainId *big.Int) *bind.TransactOpts {
+	txSigner := types.LatestSignerForChainID(chainId)
+	from := signer.Address()
+	opts := &bind.TransactOpts{
+		From:    from,
+		Context: context.Background(),
+	}
+	opts.Signer = func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if address != from {
+			return nil, bind.ErrNotAuthorized
+		}
+		signature, err := signer.SignHash(opts.Context, txSigner.Hash(tx).Bytes())
+		if err != nil {
+			return nil, err
+		}
+		return tx.WithSignature(txSigner, signature)
+	}
+	return opts
+}
+
+// DialKMS connects to the KMS gRPC service of the config, over TLS unless it's configured as plaintext.
+func DialKMS(ctx context.Context, config *genericconf.KMSConfig) (*kmssigner.Signer, error) {
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if config.Plaintext {
+		creds = insecure.NewCredentials()
+	}
+	return kmssigner.Dial(ctx, config.URL, config.KeyID, grpc.WithTransportCredentials(creds))
+}
+
+// KMSTransactOpts returns transact opts which sign with a key held by a KMS, over the KeyManagement gRPC service.
+func KMSTransactOpts(config *genericconf.KMSConfig, chainId *big.Int) (*bind.TransactOpts, error) {
+	signer, err := DialKMS(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	return HashSignerTransactOpts(signer, chainId), nil
+}
@@ -16,6 +16,12 @@ import (
 )
 
 func GetTransactOptsFromWallet(walletConfig *genericconf.WalletConfig, chainId *big.Int) (*bind.TransactOpts, error) {
+	if walletConfig.ExternalSigner.Enabled() {
+		return ExternalSignerTransactOpts(&walletConfig.ExternalSigner, chainId)
+	}
+	if walletConfig.KMS.Enabled() {
+		return KMSTransactOpts(&walletConfig.KMS, chainId)
+	}
 	if walletConfig.PrivateKey != "" {
 		privateKey, err := crypto.HexToECDSA(walletConfig.PrivateKey)
 		if err != nil {
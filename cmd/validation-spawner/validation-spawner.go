@@ -0,0 +1,198 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	koanfjson "github.com/knadh/koanf/parsers/json"
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util"
+	"github.com/offchainlabs/nitro/util/openmetrics"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+type ValidationSpawnerConfig struct {
+	RPCAddr           string                              `koanf:"rpc-addr"`
+	RPCPort           uint64                              `koanf:"rpc-port"`
+	RPCServerTimeouts genericconf.HTTPServerTimeoutConfig `koanf:"rpc-server-timeouts"`
+
+	Workers        int                `koanf:"workers"`
+	MaxWitnessSize int64              `koanf:"max-witness-size"`
+	Wasm           arbnode.WasmConfig `koanf:"wasm"`
+	ResultCacheDir string             `koanf:"result-cache-dir"`
+
+	ConfConfig genericconf.ConfConfig `koanf:"conf"`
+	LogLevel   int                    `koanf:"log-level"`
+
+	Metrics       bool                            `koanf:"metrics"`
+	MetricsServer genericconf.MetricsServerConfig `koanf:"metrics-server"`
+}
+
+var DefaultValidationSpawnerConfig = ValidationSpawnerConfig{
+	RPCAddr:           "localhost",
+	RPCPort:           8549,
+	RPCServerTimeouts: genericconf.HTTPServerTimeoutConfigDefault,
+	Workers:           0,
+	MaxWitnessSize:    validator.DefaultMaxWitnessSize,
+	Wasm:              arbnode.DefaultWasmConfig,
+	ResultCacheDir:    "",
+	ConfConfig:        genericconf.ConfConfigDefault,
+	Metrics:           false,
+	MetricsServer:     genericconf.MetricsServerConfigDefault,
+	LogLevel:          3,
+}
+
+func main() {
+	if err := startup(); err != nil {
+		log.Error("Error running validation spawner", "err", err)
+	}
+}
+
+func printSampleUsage() {
+	progname := os.Args[0]
+	fmt.Printf("\n")
+	fmt.Printf("Sample usage:                  %s --help \n", progname)
+}
+
+func parseValidationSpawner(args []string) (*ValidationSpawnerConfig, error) {
+	f := flag.NewFlagSet("validation-spawner", flag.ContinueOnError)
+	f.String("rpc-addr", DefaultValidationSpawnerConfig.RPCAddr, "RPC server listening interface, serving both HTTP and websocket")
+	f.Uint64("rpc-port", DefaultValidationSpawnerConfig.RPCPort, "RPC server listening port")
+	genericconf.HTTPServerTimeoutConfigAddOptions("rpc-server-timeouts", f)
+
+	f.Int("workers", DefaultValidationSpawnerConfig.Workers, "number of blocks to validate at once (0 for the number of CPUs)")
+	f.Int64("max-witness-size", DefaultValidationSpawnerConfig.MaxWitnessSize, "size in bytes of the largest block witness to accept")
+	arbnode.WasmConfigAddOptions("wasm", f)
+	f.String("result-cache-dir", DefaultValidationSpawnerConfig.ResultCacheDir, "directory of a database caching the end state of every block executed, so validators sharing this spawner never have it execute the same block twice (empty to not cache)")
+
+	f.Bool("metrics", DefaultValidationSpawnerConfig.Metrics, "enable metrics")
+	genericconf.MetricsServerAddOptions("metrics-server", f)
+
+	f.Int("log-level", int(log.LvlInfo), "log level; 1: ERROR, 2: WARN, 3: INFO, 4: DEBUG, 5: TRACE")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var spawnerConfig ValidationSpawnerConfig
+	if err := util.EndCommonParse(k, &spawnerConfig); err != nil {
+		return nil, err
+	}
+	if spawnerConfig.ConfConfig.Dump {
+		c, err := k.Marshal(koanfjson.Parser())
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal config file to JSON: %w", err)
+		}
+
+		fmt.Println(string(c))
+		os.Exit(0)
+	}
+
+	return &spawnerConfig, nil
+}
+
+func startup() error {
+	// Validations can take far longer than an ordinary RPC call to respond to.
+	genericconf.HTTPServerTimeoutConfigDefault.WriteTimeout = 0
+
+	vcsRevision, vcsTime := genericconf.GetVersion()
+	spawnerConfig, err := parseValidationSpawner(os.Args[1:])
+	if err != nil {
+		fmt.Printf("\nrevision: %v, vcs.time: %v\n", vcsRevision, vcsTime)
+		printSampleUsage()
+		if !strings.Contains(err.Error(), "help requested") {
+			fmt.Printf("%s\n", err.Error())
+		}
+		return nil
+	}
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(spawnerConfig.LogLevel))
+	log.Root().SetHandler(glogger)
+
+	if spawnerConfig.Metrics {
+		go metrics.CollectProcessMetrics(spawnerConfig.MetricsServer.UpdateInterval)
+
+		if spawnerConfig.MetricsServer.Addr != "" {
+			address := fmt.Sprintf("%v:%v", spawnerConfig.MetricsServer.Addr, spawnerConfig.MetricsServer.Port)
+			openmetrics.Setup(address)
+		}
+	}
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	spawner, err := validator.NewValidationSpawner(validator.NewNitroMachineLoader(spawnerConfig.Wasm.NitroMachineConfig()), spawnerConfig.Workers, spawnerConfig.MaxWitnessSize)
+	if err != nil {
+		return err
+	}
+	if spawnerConfig.ResultCacheDir != "" {
+		cacheDb, err := rawdb.NewLevelDBDatabase(spawnerConfig.ResultCacheDir, 16, 16, "validation-spawner/", false)
+		if err != nil {
+			return err
+		}
+		defer cacheDb.Close()
+		spawner.SetResultCache(validator.NewValidationResultCache(cacheDb))
+	}
+	api := validator.NewValidationSpawnerAPI(spawner)
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("validation", api); err != nil {
+		return err
+	}
+	wsHandler := rpcServer.WebsocketHandler([]string{"*"})
+	witnessHandler := api.WitnessHandler()
+	timeouts := spawnerConfig.RPCServerTimeouts
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == validator.ValidationSpawnerWitnessPath {
+				witnessHandler.ServeHTTP(w, r)
+				return
+			}
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				wsHandler.ServeHTTP(w, r)
+				return
+			}
+			rpcServer.ServeHTTP(w, r)
+		}),
+		ReadTimeout:       timeouts.ReadTimeout,
+		ReadHeaderTimeout: timeouts.ReadHeaderTimeout,
+		WriteTimeout:      timeouts.WriteTimeout,
+		IdleTimeout:       timeouts.IdleTimeout,
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", spawnerConfig.RPCAddr, spawnerConfig.RPCPort))
+	if err != nil {
+		return err
+	}
+	log.Info("Starting validation spawner RPC server", "addr", spawnerConfig.RPCAddr, "port", spawnerConfig.RPCPort)
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error("validation spawner RPC server failed", "err", err)
+		}
+	}()
+
+	<-sigint
+	rpcServer.Stop()
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,20 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+// SPDX-License-Identifier: BUSL-1.1
+
+pragma solidity >=0.4.21 <0.9.0;
+
+/// @title Verification of secp256r1 (P-256) signatures, as produced by passkeys and secure enclaves.
+/// @notice Custom precompile which chains may register at an address of their choosing,
+/// see precompiles/custom_precompiles.json. It doesn't exist on chains which haven't registered it.
+interface ArbP256Verify {
+    /// @notice Checks a P-256 signature (r, s) of a message hash against the public key (x, y)
+    /// @return whether the signature is valid, false for malformed signatures or keys
+    function verify(
+        bytes32 hash,
+        bytes32 r,
+        bytes32 s,
+        bytes32 x,
+        bytes32 y
+    ) external pure returns (bool);
+}
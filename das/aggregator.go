@@ -27,16 +27,20 @@ import (
 )
 
 type AggregatorConfig struct {
-	Enable        bool   `koanf:"enable"`
-	AssumedHonest int    `koanf:"assumed-honest"`
-	Backends      string `koanf:"backends"`
-	DumpKeyset    bool   `koanf:"dump-keyset"`
+	Enable         bool                     `koanf:"enable"`
+	AssumedHonest  int                      `koanf:"assumed-honest"`
+	Backends       string                   `koanf:"backends"`
+	DumpKeyset     bool                     `koanf:"dump-keyset"`
+	KeysetCacheTTL time.Duration            `koanf:"keyset-cache-ttl"`
+	Dispatch       AggregatorDispatchConfig `koanf:"dispatch"`
 }
 
 var DefaultAggregatorConfig = AggregatorConfig{
-	AssumedHonest: 0,
-	Backends:      "",
-	DumpKeyset:    false,
+	AssumedHonest:  0,
+	Backends:       "",
+	DumpKeyset:     false,
+	KeysetCacheTTL: 5 * time.Minute,
+	Dispatch:       DefaultAggregatorDispatchConfig,
 }
 
 func AggregatorConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -44,6 +48,8 @@ func AggregatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Int(prefix+".assumed-honest", DefaultAggregatorConfig.AssumedHonest, "Number of assumed honest backends (H). If there are N backends, K=N+1-H valid responses are required to consider an Store request to be successful.")
 	f.String(prefix+".backends", DefaultAggregatorConfig.Backends, "JSON RPC backend configuration")
 	f.Bool(prefix+".dump-keyset", DefaultAggregatorConfig.DumpKeyset, "Dump the keyset encoded in hexadecimal for the backends string")
+	f.Duration(prefix+".keyset-cache-ttl", DefaultAggregatorConfig.KeysetCacheTTL, "how long to remember which of the keysets of a key rotation the sequencer inbox accepts before checking again (0 to check on every store)")
+	AggregatorDispatchConfigAddOptions(prefix+".dispatch", f)
 }
 
 type Aggregator struct {
@@ -53,15 +59,21 @@ type Aggregator struct {
 	// calculated fields
 	requiredServicesForStore       int
 	maxAllowedServiceStoreFailures int
-	keysetHash                     [32]byte
-	keysetBytes                    []byte
+	keysets                        []aggregatorKeyset // by generation of rotated keys
+	keysetValidator                keysetValidator
+	registeredCache                registeredKeysetsCache
+	seqInboxCaller                 *bridgegen.SequencerInboxCaller
 	bpVerifier                     *BatchPosterVerifier
+	members                        *aggregatorMembers
 }
 
 type ServiceDetails struct {
 	service     DataAvailabilityService
 	pubKey      blsSignatures.PublicKey
 	signersMask uint64
+
+	// Keys the backend rotates to, in order, each in the keysets of the following generations
+	rotationKeys []blsSignatures.PublicKey
 }
 
 func (this *ServiceDetails) String() string {
@@ -116,39 +128,36 @@ func NewAggregatorWithSeqInboxCaller(
 	seqInboxCaller *bridgegen.SequencerInboxCaller,
 ) (*Aggregator, error) {
 	var aggSignersMask uint64
-	pubKeys := []blsSignatures.PublicKey{}
 	for _, d := range services {
 		if bits.OnesCount64(d.signersMask) != 1 {
 			return nil, fmt.Errorf("Tried to configure backend DAS %v with invalid signersMask %X", d.service, d.signersMask)
 		}
 		aggSignersMask |= d.signersMask
-		pubKeys = append(pubKeys, d.pubKey)
 	}
 	if bits.OnesCount64(aggSignersMask) != len(services) {
 		return nil, errors.New("At least two signers share a mask")
 	}
 
-	keyset := &arbstate.DataAvailabilityKeyset{
-		AssumedHonest: uint64(config.AssumedHonest),
-		PubKeys:       pubKeys,
-	}
-	ksBuf := bytes.NewBuffer([]byte{})
-	if err := keyset.Serialize(ksBuf); err != nil {
-		return nil, err
-	}
-	keysetHash, err := keyset.Hash()
+	keysets, err := newAggregatorKeysets(uint64(config.AssumedHonest), services)
 	if err != nil {
 		return nil, err
 	}
 	if config.DumpKeyset {
-		fmt.Printf("Keyset: %s\n", hexutil.Encode(ksBuf.Bytes()))
-		fmt.Printf("KeysetHash: %s\n", hexutil.Encode(keysetHash[:]))
+		for generation, keyset := range keysets {
+			if len(keysets) > 1 {
+				fmt.Printf("Generation %d\n", generation)
+			}
+			fmt.Printf("Keyset: %s\n", hexutil.Encode(keyset.bytes))
+			fmt.Printf("KeysetHash: %s\n", hexutil.Encode(keyset.hash[:]))
+		}
 		os.Exit(0)
 	}
 
 	var bpVerifier *BatchPosterVerifier
+	var ksValidator keysetValidator
 	if seqInboxCaller != nil {
 		bpVerifier = NewBatchPosterVerifier(seqInboxCaller)
+		ksValidator = seqInboxCaller
 	}
 
 	return &Aggregator{
@@ -156,9 +165,11 @@ func NewAggregatorWithSeqInboxCaller(
 		services:                       services,
 		requiredServicesForStore:       len(services) + 1 - config.AssumedHonest,
 		maxAllowedServiceStoreFailures: config.AssumedHonest - 1,
-		keysetHash:                     keysetHash,
-		keysetBytes:                    ksBuf.Bytes(),
+		keysets:                        keysets,
+		keysetValidator:                ksValidator,
+		seqInboxCaller:                 seqInboxCaller,
 		bpVerifier:                     bpVerifier,
+		members:                        newAggregatorMembers(services),
 	}, nil
 }
 
@@ -207,9 +218,13 @@ type storeResponse struct {
 	details ServiceDetails
 	sig     blsSignatures.Signature
 	err     error
+	key     int // which of the backend's keys signed
 }
 
 // Store calls Store on each backend DAS in parallel and collects responses.
+// With latency-aware dispatch, it calls only as many as it needs signatures
+// from at first, ranked by their recent latency and error rate, and calls more
+// as they fail or are slow to respond.
 // If there were at least K responses then it aggregates the signatures and
 // signersMasks from each DAS together into the DataAvailabilityCertificate
 // then Store returns immediately. If there were any backend Store subroutines
@@ -243,65 +258,86 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 		}
 	}
 
+	// Signatures will be tallied per keyset registered on chain which the key each backend signed with is in, so
+	// backends rotating keys are counted under both their old and new keysets.
+	registered, err := a.registeredKeysets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	responses := make(chan storeResponse, len(a.services))
 
 	expectedHash := dastree.Hash(message)
-	for _, d := range a.services {
-		go func(ctx context.Context, d ServiceDetails) {
-			cert, err := d.service.Store(ctx, message, timeout, sig)
-			if err != nil {
-				responses <- storeResponse{d, nil, err}
-				return
-			}
-
-			verified, err := blsSignatures.VerifySignature(
-				cert.Sig, cert.SerializeSignableFields(), d.pubKey,
-			)
-			if err != nil {
-				responses <- storeResponse{d, nil, err}
-				return
-			}
-			if !verified {
-				responses <- storeResponse{d, nil, errors.New("Signature verification failed.")}
-				return
-			}
-
-			// SignersMask from backend DAS is ignored.
-
-			if cert.DataHash != expectedHash {
-				responses <- storeResponse{d, nil, errors.New("Hash verification failed.")}
-				return
-			}
-			if cert.Timeout != timeout {
-				responses <- storeResponse{d, nil, fmt.Errorf("Timeout was %d, expected %d", cert.Timeout, timeout)}
-				return
-			}
+	store := func(ctx context.Context, member int, d ServiceDetails) {
+		start := time.Now()
+		response := a.storeToMember(ctx, d, message, timeout, sig, expectedHash)
+		if ctx.Err() == nil {
+			// stores still running once the context is canceled weren't given the chance to finish
+			a.members.record(member, time.Since(start), response.err)
+		}
+		responses <- response
+	}
 
-			responses <- storeResponse{d, cert.Sig, nil}
-		}(ctx, d)
+	// With latency-aware dispatch the store goes to the best ranked members first, and to the next whenever one
+	// fails or none respond within the hedge delay; otherwise it goes to every member at once.
+	order := a.members.ranked()
+	dispatched := 0
+	dispatch := func() {
+		if dispatched < len(order) {
+			member := order[dispatched]
+			dispatched++
+			go store(ctx, member, a.services[member])
+		}
+	}
+	initial := len(a.services)
+	if a.config.Dispatch.LatencyAware {
+		initial = a.requiredServicesForStore + a.config.Dispatch.ExtraMembers
+	}
+	for i := 0; i < initial; i++ {
+		dispatch()
 	}
 
-	var pubKeys []blsSignatures.PublicKey
-	var sigs []blsSignatures.Signature
+	tallies := make([]keysetSignatures, len(a.keysets))
+	chosen := 0
+
 	var aggCert arbstate.DataAvailabilityCertificate
-	var aggSignersMask uint64
-	var storeFailures, successfullyStoredCount int
+	var storeFailures, successfullyStoredCount, received int
 	var errs []error
-	for i := 0; i < len(a.services) && storeFailures <= a.maxAllowedServiceStoreFailures && successfullyStoredCount < a.requiredServicesForStore; i++ {
+collectResponses:
+	for received < dispatched && storeFailures <= a.maxAllowedServiceStoreFailures && successfullyStoredCount < a.requiredServicesForStore {
+		var hedge <-chan time.Time
+		if dispatched < len(order) && a.config.Dispatch.HedgeDelay > 0 {
+			hedge = time.After(a.config.Dispatch.HedgeDelay)
+		}
 		select {
 		case <-ctx.Done():
-			break
+			break collectResponses
+		case <-hedge:
+			log.Debug("DAS backends slow to respond to store, sending it to another", "dispatched", dispatched)
+			dispatch()
 		case r := <-responses:
+			received++
 			if r.err != nil {
 				storeFailures++
 				errs = append(errs, fmt.Errorf("Error from backend %v, with signer mask %d: %w", r.details.service, r.details.signersMask, r.err))
+				dispatch()
 				continue
 			}
 
-			pubKeys = append(pubKeys, r.details.pubKey)
-			sigs = append(sigs, r.sig)
-			aggSignersMask |= r.details.signersMask
-			successfullyStoredCount++
+			counted := false
+			for generation := range a.keysets {
+				if registered[generation] && r.details.keyIndex(generation) == r.key {
+					tallies[generation].add(r.details.keys()[r.key], r.sig, r.details.signersMask)
+					counted = true
+				}
+			}
+			if !counted {
+				storeFailures++
+				errs = append(errs, fmt.Errorf("Backend %v, with signer mask %d, signed with a key in no registered keyset", r.details.service, r.details.signersMask))
+				dispatch()
+				continue
+			}
+			chosen, successfullyStoredCount = mostSignedKeyset(tallies)
 		}
 	}
 
@@ -309,12 +345,12 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 		return nil, fmt.Errorf("Aggregator failed to store message to at least %d out of %d DASes (assuming %d are honest), errors received %d, %v", a.requiredServicesForStore, len(a.services), a.config.AssumedHonest, storeFailures, errs)
 	}
 
-	aggCert.Sig = blsSignatures.AggregateSignatures(sigs)
-	aggPubKey := blsSignatures.AggregatePublicKeys(pubKeys)
-	aggCert.SignersMask = aggSignersMask
+	aggCert.Sig = blsSignatures.AggregateSignatures(tallies[chosen].sigs)
+	aggPubKey := blsSignatures.AggregatePublicKeys(tallies[chosen].pubKeys)
+	aggCert.SignersMask = tallies[chosen].signersMask
 	aggCert.DataHash = expectedHash
 	aggCert.Timeout = timeout
-	aggCert.KeysetHash = a.keysetHash
+	aggCert.KeysetHash = a.keysets[chosen].hash
 	aggCert.Version = 1
 
 	verified, err := blsSignatures.VerifySignature(aggCert.Sig, aggCert.SerializeSignableFields(), aggPubKey)
@@ -327,6 +363,43 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 	return &aggCert, nil
 }
 
+// storeToMember stores the message to one backend, verifying the certificate it signs.
+func (a *Aggregator) storeToMember(ctx context.Context, d ServiceDetails, message []byte, timeout uint64, sig []byte, expectedHash common.Hash) storeResponse {
+	cert, err := d.service.Store(ctx, message, timeout, sig)
+	if err != nil {
+		return storeResponse{details: d, err: err}
+	}
+
+	// The backend may sign with any of its keys
+	signedBy := -1
+	for i, pubKey := range d.keys() {
+		verified, err := blsSignatures.VerifySignature(
+			cert.Sig, cert.SerializeSignableFields(), pubKey,
+		)
+		if err != nil {
+			return storeResponse{details: d, err: err}
+		}
+		if verified {
+			signedBy = i
+			break
+		}
+	}
+	if signedBy < 0 {
+		return storeResponse{details: d, err: errors.New("Signature verification failed.")}
+	}
+
+	// SignersMask from backend DAS is ignored.
+
+	if cert.DataHash != expectedHash {
+		return storeResponse{details: d, err: errors.New("Hash verification failed.")}
+	}
+	if cert.Timeout != timeout {
+		return storeResponse{details: d, err: fmt.Errorf("Timeout was %d, expected %d", cert.Timeout, timeout)}
+	}
+
+	return storeResponse{details: d, sig: cert.Sig, key: signedBy}
+}
+
 func (a *Aggregator) String() string {
 	var b bytes.Buffer
 	b.WriteString("das.Aggregator{")
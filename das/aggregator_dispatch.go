@@ -0,0 +1,125 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	flag "github.com/spf13/pflag"
+)
+
+// AggregatorDispatchConfig makes the aggregator send each store only to as many committee members as it needs
+// signatures from, fastest first by their recent latency and error rate, then to more as members fail or are slow
+// to respond, rather than to every member at once.
+type AggregatorDispatchConfig struct {
+	LatencyAware bool          `koanf:"latency-aware"`
+	ExtraMembers int           `koanf:"extra-members"`
+	HedgeDelay   time.Duration `koanf:"hedge-delay"`
+}
+
+var DefaultAggregatorDispatchConfig = AggregatorDispatchConfig{
+	LatencyAware: false,
+	ExtraMembers: 0,
+	HedgeDelay:   time.Second,
+}
+
+func AggregatorDispatchConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".latency-aware", DefaultAggregatorDispatchConfig.LatencyAware, "send store requests to only as many backends as signatures are required from, ranked by recent latency and error rate, instead of all of them")
+	f.Int(prefix+".extra-members", DefaultAggregatorDispatchConfig.ExtraMembers, "with latency-aware dispatch, number of backends to send store requests to beyond those required")
+	f.Duration(prefix+".hedge-delay", DefaultAggregatorDispatchConfig.HedgeDelay, "with latency-aware dispatch, time to wait for a response before also sending the store request to the next backend (0 to only do so on errors)")
+}
+
+// Weights of the latest store in the members' moving averages
+const memberStatsWeight = 0.2
+
+// Seconds a member's score is slowed by for failing every store, so one that fails often ranks behind slower
+// reliable ones
+const memberErrorPenalty = 10
+
+// memberStats tracks one committee member's recent store latency and error rate, also reporting them as metrics
+// under arb/das/aggregator/member/<bit of its signers mask>/.
+type memberStats struct {
+	latency   float64 // moving average of successful stores, in seconds
+	errorRate float64 // moving average, from 0 to 1
+	succeeded bool    // whether latency has been measured
+
+	latencyHistogram metrics.Histogram
+	successCounter   metrics.Counter
+	errorCounter     metrics.Counter
+	errorRateGauge   metrics.GaugeFloat64
+}
+
+func newMemberStats(signersMask uint64) *memberStats {
+	prefix := fmt.Sprintf("arb/das/aggregator/member/%d/", bits.TrailingZeros64(signersMask))
+	return &memberStats{
+		latencyHistogram: metrics.GetOrRegisterHistogram(prefix+"store/latency", nil, metrics.NewExpDecaySample(1028, 0.015)),
+		successCounter:   metrics.GetOrRegisterCounter(prefix+"store/success", nil),
+		errorCounter:     metrics.GetOrRegisterCounter(prefix+"store/error", nil),
+		errorRateGauge:   metrics.GetOrRegisterGaugeFloat64(prefix+"store/errorrate", nil),
+	}
+}
+
+// score ranks members for dispatch, lower first. Members never measured rank first, to learn about them.
+func (s *memberStats) score() float64 {
+	return s.latency + memberErrorPenalty*s.errorRate
+}
+
+type aggregatorMembers struct {
+	mutex sync.Mutex
+	stats []*memberStats
+}
+
+func newAggregatorMembers(services []ServiceDetails) *aggregatorMembers {
+	m := &aggregatorMembers{}
+	for _, d := range services {
+		m.stats = append(m.stats, newMemberStats(d.signersMask))
+	}
+	return m
+}
+
+func (m *aggregatorMembers) record(member int, latency time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s := m.stats[member]
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		s.errorCounter.Inc(1)
+	} else {
+		s.successCounter.Inc(1)
+		s.latencyHistogram.Update(latency.Milliseconds())
+	}
+	// a failure's latency says little about how fast the member stores
+	if err == nil {
+		if s.succeeded {
+			s.latency += memberStatsWeight * (latency.Seconds() - s.latency)
+		} else {
+			s.latency = latency.Seconds()
+			s.succeeded = true
+		}
+	}
+	s.errorRate += memberStatsWeight * (failed - s.errorRate)
+	s.errorRateGauge.Update(s.errorRate)
+}
+
+// ranked returns the indexes of the members from best score to worst.
+func (m *aggregatorMembers) ranked() []int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	order := make([]int, len(m.stats))
+	scores := make([]float64, len(m.stats))
+	for i, s := range m.stats {
+		order[i] = i
+		scores[i] = s.score()
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] < scores[order[j]]
+	})
+	return order
+}
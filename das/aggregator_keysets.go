@@ -0,0 +1,143 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/blsSignatures"
+)
+
+// aggregatorKeyset is one generation of the committee's keyset. Generation 0 has every backend's current key, and
+// each later one has the next key of the backends rotating to one, so the aggregator can keep producing
+// certificates while the keysets of a rotation are registered with and removed from the sequencer inbox.
+type aggregatorKeyset struct {
+	hash  [32]byte
+	bytes []byte
+}
+
+func newAggregatorKeysets(assumedHonest uint64, services []ServiceDetails) ([]aggregatorKeyset, error) {
+	generations := 1
+	for _, d := range services {
+		if len(d.keys()) > generations {
+			generations = len(d.keys())
+		}
+	}
+	var keysets []aggregatorKeyset
+	for generation := 0; generation < generations; generation++ {
+		pubKeys := []blsSignatures.PublicKey{}
+		for _, d := range services {
+			pubKeys = append(pubKeys, d.keys()[d.keyIndex(generation)])
+		}
+		keyset := &arbstate.DataAvailabilityKeyset{
+			AssumedHonest: assumedHonest,
+			PubKeys:       pubKeys,
+		}
+		ksBuf := bytes.NewBuffer([]byte{})
+		if err := keyset.Serialize(ksBuf); err != nil {
+			return nil, err
+		}
+		keysetHash, err := keyset.Hash()
+		if err != nil {
+			return nil, err
+		}
+		keysets = append(keysets, aggregatorKeyset{hash: keysetHash, bytes: ksBuf.Bytes()})
+	}
+	return keysets, nil
+}
+
+// keys returns the backend's current key followed by those it rotates to.
+func (d *ServiceDetails) keys() []blsSignatures.PublicKey {
+	return append([]blsSignatures.PublicKey{d.pubKey}, d.rotationKeys...)
+}
+
+// keyIndex returns which of the backend's keys is in the keyset of the generation, backends that have rotated
+// fewer times keeping their last key.
+func (d *ServiceDetails) keyIndex(generation int) int {
+	if generation > len(d.rotationKeys) {
+		return len(d.rotationKeys)
+	}
+	return generation
+}
+
+// SetRotationKeys sets the keys the backend rotates to, in order.
+func (d *ServiceDetails) SetRotationKeys(keys []blsSignatures.PublicKey) {
+	d.rotationKeys = keys
+}
+
+// keysetValidator is the part of the sequencer inbox which says which keysets it accepts.
+type keysetValidator interface {
+	IsValidKeysetHash(opts *bind.CallOpts, ksHash [32]byte) (bool, error)
+}
+
+// registeredKeysetsCache holds which generations' keysets the sequencer inbox accepted when last checked, so each
+// store doesn't check with L1.
+type registeredKeysetsCache struct {
+	mutex      sync.Mutex
+	registered []bool
+	checked    time.Time
+}
+
+// registeredKeysets returns which generations' keysets the sequencer inbox currently accepts, as of at most
+// KeysetCacheTTL ago. Without a rotation, or without the sequencer inbox to check, only the current keyset is used.
+// The result mustn't be modified.
+func (a *Aggregator) registeredKeysets(ctx context.Context) ([]bool, error) {
+	if len(a.keysets) == 1 || a.keysetValidator == nil {
+		registered := make([]bool, len(a.keysets))
+		registered[0] = true
+		return registered, nil
+	}
+	cache := &a.registeredCache
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.registered != nil && time.Since(cache.checked) < a.config.KeysetCacheTTL {
+		return cache.registered, nil
+	}
+	registered := make([]bool, len(a.keysets))
+	anyRegistered := false
+	for generation, keyset := range a.keysets {
+		valid, err := a.keysetValidator.IsValidKeysetHash(&bind.CallOpts{Context: ctx}, keyset.hash)
+		if err != nil {
+			return nil, err
+		}
+		registered[generation] = valid
+		anyRegistered = anyRegistered || valid
+	}
+	if !anyRegistered {
+		return nil, errors.New("none of the aggregator's keysets are registered with the sequencer inbox")
+	}
+	cache.registered = registered
+	cache.checked = time.Now()
+	return registered, nil
+}
+
+// keysetSignatures collects the signatures for one generation's keyset.
+type keysetSignatures struct {
+	pubKeys     []blsSignatures.PublicKey
+	sigs        []blsSignatures.Signature
+	signersMask uint64
+}
+
+func (k *keysetSignatures) add(pubKey blsSignatures.PublicKey, sig blsSignatures.Signature, signersMask uint64) {
+	k.pubKeys = append(k.pubKeys, pubKey)
+	k.sigs = append(k.sigs, sig)
+	k.signersMask |= signersMask
+}
+
+// mostSignedKeyset returns the generation with the most signatures and how many it has, preferring the newest.
+func mostSignedKeyset(tallies []keysetSignatures) (int, int) {
+	chosen := 0
+	for generation := range tallies {
+		if len(tallies[generation].sigs) >= len(tallies[chosen].sigs) {
+			chosen = generation
+		}
+	}
+	return chosen, len(tallies[chosen].sigs)
+}
@@ -12,12 +12,16 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/blsSignatures"
+	"github.com/offchainlabs/nitro/das/dastree"
 )
 
 func TestDAS_BasicAggregationLocal(t *testing.T) {
@@ -351,7 +355,7 @@ func testConfigurableRetrieveFailures(t *testing.T, shouldFail bool) {
 		pubKey, _, err := ReadKeysFromFile(dbPath)
 		Require(t, err)
 		signerMask := uint64(1 << i)
-		details := ServiceDetails{&WrapGetByHash{t, injectedFailures, das}, *pubKey, signerMask}
+		details := ServiceDetails{&WrapGetByHash{t, injectedFailures, das}, *pubKey, signerMask, nil}
 
 		backends = append(backends, details)
 	}
@@ -394,3 +398,174 @@ func TestDAS_RetrieveFailureFromAllDASes(t *testing.T) {
 		testConfigurableRetrieveFailures(t, true)
 	}
 }
+
+type countingStore struct {
+	DataAvailabilityService
+	fail   bool
+	stores int32
+}
+
+func (c *countingStore) Store(ctx context.Context, message []byte, timeout uint64, sig []byte) (*arbstate.DataAvailabilityCertificate, error) {
+	atomic.AddInt32(&c.stores, 1)
+	if c.fail {
+		return nil, errors.New("Expected Store failure")
+	}
+	return c.DataAvailabilityService.Store(ctx, message, timeout, sig)
+}
+
+func TestDAS_LatencyAwareDispatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	numBackendDAS := 4
+	var backends []ServiceDetails
+	var counters []*countingStore
+	for i := 0; i < numBackendDAS; i++ {
+		dbPath := t.TempDir()
+		_, _, err := GenerateAndStoreKeys(dbPath)
+		Require(t, err)
+
+		config := DataAvailabilityConfig{
+			Enable: true,
+			KeyConfig: KeyConfig{
+				KeyDir: dbPath,
+			},
+			LocalFileStorageConfig: LocalFileStorageConfig{
+				Enable:  true,
+				DataDir: dbPath,
+			},
+			L1NodeURL: "none",
+		}
+
+		storageService, lifecycleManager, err := CreatePersistentStorageService(ctx, &config)
+		Require(t, err)
+		defer lifecycleManager.StopAndWaitUntil(time.Second)
+		das, err := NewSignAfterStoreDAS(ctx, config, storageService)
+		Require(t, err)
+		pubKey, _, err := ReadKeysFromFile(dbPath)
+		Require(t, err)
+		// the first backend, dispatched to first before any are ranked, always fails
+		counter := &countingStore{DataAvailabilityService: das, fail: i == 0}
+		counters = append(counters, counter)
+		details, err := NewServiceDetails(counter, *pubKey, uint64(1<<i))
+		Require(t, err)
+		backends = append(backends, *details)
+	}
+
+	aggConfig := AggregatorConfig{AssumedHonest: 2, Dispatch: AggregatorDispatchConfig{LatencyAware: true}}
+	aggregator, err := NewAggregator(ctx, DataAvailabilityConfig{AggregatorConfig: aggConfig, L1NodeURL: "none"}, backends)
+	Require(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := aggregator.Store(ctx, []byte(fmt.Sprintf("message %d", i)), 0, []byte{})
+		Require(t, err, "Error storing message")
+	}
+	// the failing backend is ranked last after its failure, so the second store doesn't need it
+	if stores := atomic.LoadInt32(&counters[0].stores); stores != 1 {
+		Fail(t, "failing backend was sent", stores, "stores, expected 1")
+	}
+	order := aggregator.members.ranked()
+	if order[len(order)-1] != 0 {
+		Fail(t, "failing backend ranked", order)
+	}
+}
+
+func TestDAS_KeyRotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	oldKeyDir, newKeyDir := t.TempDir(), t.TempDir()
+	oldPubKey, _, err := GenerateAndStoreKeys(oldKeyDir)
+	Require(t, err)
+	newPubKey, _, err := GenerateAndStoreKeys(newKeyDir)
+	Require(t, err)
+
+	keyConfig := KeyConfig{
+		KeyDir:   oldKeyDir,
+		Rotation: fmt.Sprintf(`[{"key-dir":%q,"activation-height":100}]`, newKeyDir),
+	}
+	das, err := NewSignAfterStoreDASWithSeqInboxCaller(ctx, keyConfig, nil, NewMemoryBackedStorageService(ctx), "")
+	Require(t, err)
+	var height uint64 = 99
+	das.SetL1BlockNumberSource(func(context.Context) (uint64, error) {
+		return height, nil
+	})
+
+	details, err := NewServiceDetails(das, *oldPubKey, 1)
+	Require(t, err)
+	details.SetRotationKeys([]blsSignatures.PublicKey{*newPubKey})
+	keysets, err := newAggregatorKeysets(1, []ServiceDetails{*details})
+	Require(t, err)
+	if len(keysets) != 2 || keysets[0].hash == keysets[1].hash {
+		Fail(t, "expected a keyset for each key")
+	}
+
+	aggregator := &Aggregator{}
+	message := []byte("signed with the key for the L1 height")
+	for i, h := range []uint64{99, 100} {
+		height = h
+		response := aggregator.storeToMember(ctx, *details, message, 0, nil, dastree.Hash(message))
+		Require(t, response.err)
+		if response.key != i {
+			Fail(t, "at height", h, "signed with key", response.key, "expected", i)
+		}
+	}
+
+	tallies := make([]keysetSignatures, 2)
+	tallies[0].add(*oldPubKey, nil, 1)
+	tallies[1].add(*newPubKey, nil, 1)
+	if chosen, count := mostSignedKeyset(tallies); chosen != 1 || count != 1 {
+		Fail(t, "chose generation", chosen, "with", count, "signatures, expected the newest")
+	}
+}
+
+type countingKeysetValidator struct {
+	valid map[[32]byte]bool
+	calls int
+}
+
+func (v *countingKeysetValidator) IsValidKeysetHash(opts *bind.CallOpts, ksHash [32]byte) (bool, error) {
+	v.calls++
+	return v.valid[ksHash], nil
+}
+
+func TestDAS_RegisteredKeysetsCache(t *testing.T) {
+	ctx := context.Background()
+	keysets := []aggregatorKeyset{{hash: [32]byte{1}}, {hash: [32]byte{2}}}
+	validator := &countingKeysetValidator{valid: map[[32]byte]bool{{1}: true}}
+	aggregator := &Aggregator{
+		config:          AggregatorConfig{KeysetCacheTTL: time.Hour},
+		keysets:         keysets,
+		keysetValidator: validator,
+	}
+
+	for i := 0; i < 3; i++ {
+		registered, err := aggregator.registeredKeysets(ctx)
+		Require(t, err)
+		if !registered[0] || registered[1] {
+			Fail(t, "unexpected registered keysets", registered)
+		}
+	}
+	if validator.calls != len(keysets) {
+		Fail(t, "checked keysets with L1 on every store", validator.calls)
+	}
+
+	// Once the cache expires a newly registered keyset is seen
+	validator.valid[[32]byte{2}] = true
+	aggregator.registeredCache.checked = time.Now().Add(-2 * time.Hour)
+	registered, err := aggregator.registeredKeysets(ctx)
+	Require(t, err)
+	if !registered[1] || validator.calls != 2*len(keysets) {
+		Fail(t, "didn't recheck keysets after the cache expired", registered, validator.calls)
+	}
+
+	// Failed checks aren't cached
+	validator.valid = map[[32]byte]bool{}
+	aggregator.registeredCache.checked = time.Time{}
+	if _, err := aggregator.registeredKeysets(ctx); err == nil {
+		Fail(t, "used keysets none of which are registered")
+	}
+	if _, err := aggregator.registeredKeysets(ctx); err == nil || validator.calls != 4*len(keysets) {
+		Fail(t, "cached a failed check", validator.calls)
+	}
+}
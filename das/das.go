@@ -39,14 +39,20 @@ type DataAvailabilityConfig struct {
 	LocalCacheConfig BigCacheConfig `koanf:"local-cache"`
 	RedisCacheConfig RedisConfig    `koanf:"redis-cache"`
 
-	LocalDBStorageConfig   LocalDBStorageConfig   `koanf:"local-db-storage"`
-	LocalFileStorageConfig LocalFileStorageConfig `koanf:"local-file-storage"`
-	S3StorageServiceConfig S3StorageServiceConfig `koanf:"s3-storage"`
+	LocalDBStorageConfig   LocalDBStorageConfig     `koanf:"local-db-storage"`
+	LocalFileStorageConfig LocalFileStorageConfig   `koanf:"local-file-storage"`
+	S3StorageServiceConfig S3StorageServiceConfig   `koanf:"s3-storage"`
+	IPFSStorageConfig      IPFSStorageServiceConfig `koanf:"ipfs-storage"`
+	StoredIndexConfig      StoredIndexConfig        `koanf:"stored-index"`
+	RetentionConfig        RetentionConfig          `koanf:"retention"`
 
 	KeyConfig KeyConfig `koanf:"key"`
 
 	AggregatorConfig              AggregatorConfig              `koanf:"rpc-aggregator"`
 	RestfulClientAggregatorConfig RestfulClientAggregatorConfig `koanf:"rest-aggregator"`
+	MirrorConfig                  MirrorConfig                  `koanf:"mirror"`
+	SamplerConfig                 SamplerConfig                 `koanf:"sampler"`
+	ResolutionChainConfig         ResolutionChainConfig         `koanf:"resolution"`
 
 	L1NodeURL                       string `koanf:"l1-node-url"`
 	L1ConnectionAttempts            int    `koanf:"l1-connection-attempts"`
@@ -61,6 +67,11 @@ var DefaultDataAvailabilityConfig = DataAvailabilityConfig{
 	RequestTimeout:                5 * time.Second,
 	Enable:                        false,
 	RestfulClientAggregatorConfig: DefaultRestfulClientAggregatorConfig,
+	IPFSStorageConfig:             DefaultIPFSStorageServiceConfig,
+	MirrorConfig:                  DefaultMirrorConfig,
+	SamplerConfig:                 DefaultSamplerConfig,
+	ResolutionChainConfig:         DefaultResolutionChainConfig,
+	RetentionConfig:               DefaultRetentionConfig,
 	L1ConnectionAttempts:          15,
 	PanicOnError:                  false,
 }
@@ -94,6 +105,9 @@ func DataAvailabilityConfigAddOptions(prefix string, f *flag.FlagSet) {
 	LocalDBStorageConfigAddOptions(prefix+".local-db-storage", f)
 	LocalFileStorageConfigAddOptions(prefix+".local-file-storage", f)
 	S3ConfigAddOptions(prefix+".s3-storage", f)
+	IPFSStorageServiceConfigAddOptions(prefix+".ipfs-storage", f)
+	StoredIndexConfigAddOptions(prefix+".stored-index", f)
+	RetentionConfigAddOptions(prefix+".retention", f)
 
 	// Key config for storage
 	KeyConfigAddOptions(prefix+".key", f)
@@ -101,6 +115,9 @@ func DataAvailabilityConfigAddOptions(prefix string, f *flag.FlagSet) {
 	// Aggregator options
 	AggregatorConfigAddOptions(prefix+".rpc-aggregator", f)
 	RestfulClientAggregatorConfigAddOptions(prefix+".rest-aggregator", f)
+	MirrorConfigAddOptions(prefix+".mirror", f)
+	SamplerConfigAddOptions(prefix+".sampler", f)
+	ResolutionChainConfigAddOptions(prefix+".resolution", f)
 
 	f.String(prefix+".l1-node-url", DefaultDataAvailabilityConfig.L1NodeURL, "URL for L1 node, only used in standalone daserver; when running as part of a node that node's L1 configuration is used")
 	f.Int(prefix+".l1-connection-attempts", DefaultDataAvailabilityConfig.L1ConnectionAttempts, "layer 1 RPC connection attempts (spaced out at least 1 second per attempt, 0 to retry infinitely), only used in standalone daserver; when running as part of a node that node's L1 configuration is used")
@@ -57,14 +57,50 @@ func StartDASRPCServer(ctx context.Context, addr string, portNum uint64, rpcServ
 }
 
 func StartDASRPCServerOnListener(ctx context.Context, listener net.Listener, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, localDAS das.DataAvailabilityService) (*http.Server, error) {
-	rpcServer := rpc.NewServer()
-	err := rpcServer.RegisterName("das", &DASRPCServer{localDAS: localDAS})
+	return StartDASRPCServerWithTenantsOnListener(ctx, listener, rpcServerTimeouts, localDAS, nil)
+}
+
+func StartDASRPCServerWithTenants(ctx context.Context, addr string, portNum uint64, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, localDAS das.DataAvailabilityService, tenants map[string]das.DataAvailabilityService) (*http.Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", addr, portNum))
 	if err != nil {
 		return nil, err
 	}
+	return StartDASRPCServerWithTenantsOnListener(ctx, listener, rpcServerTimeouts, localDAS, tenants)
+}
+
+// StartDASRPCServerWithTenantsOnListener serves localDAS at the root path, if it's not nil, and each tenant's DAS,
+// for further chains run in the same process, at /<name>.
+func StartDASRPCServerWithTenantsOnListener(ctx context.Context, listener net.Listener, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, localDAS das.DataAvailabilityService, tenants map[string]das.DataAvailabilityService) (*http.Server, error) {
+	var handler http.Handler
+	if len(tenants) == 0 {
+		rpcServer := rpc.NewServer()
+		err := rpcServer.RegisterName("das", &DASRPCServer{localDAS: localDAS})
+		if err != nil {
+			return nil, err
+		}
+		handler = rpcServer
+	} else {
+		mux := http.NewServeMux()
+		if localDAS != nil {
+			rpcServer := rpc.NewServer()
+			if err := rpcServer.RegisterName("das", &DASRPCServer{localDAS: localDAS}); err != nil {
+				return nil, err
+			}
+			mux.Handle("/", rpcServer)
+		}
+		for name, tenantDAS := range tenants {
+			rpcServer := rpc.NewServer()
+			if err := rpcServer.RegisterName("das", &DASRPCServer{localDAS: tenantDAS}); err != nil {
+				return nil, err
+			}
+			mux.Handle("/"+name, rpcServer)
+			mux.Handle("/"+name+"/", rpcServer)
+		}
+		handler = mux
+	}
 
 	srv := &http.Server{
-		Handler:           rpcServer,
+		Handler:           handler,
 		ReadTimeout:       rpcServerTimeouts.ReadTimeout,
 		ReadHeaderTimeout: rpcServerTimeouts.ReadHeaderTimeout,
 		WriteTimeout:      rpcServerTimeouts.WriteTimeout,
@@ -10,7 +10,9 @@ import (
 	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/blsSignatures"
 
 	"github.com/offchainlabs/nitro/das"
 )
@@ -19,6 +21,9 @@ type BackendConfig struct {
 	URL                 string `json:"url"`
 	PubKeyBase64Encoded string `json:"pubkey"`
 	SignerMask          uint64 `json:"signermask"`
+
+	// Keys the backend is rotating to, in order
+	RotationPubKeysBase64Encoded []string `json:"rotation-pubkeys,omitempty"`
 }
 
 func NewRPCAggregator(ctx context.Context, config das.DataAvailabilityConfig) (*das.Aggregator, error) {
@@ -72,8 +77,36 @@ func setUpServices(config das.AggregatorConfig) ([]das.ServiceDetails, error) {
 			return nil, err
 		}
 
+		var rotationKeys []blsSignatures.PublicKey
+		for _, encoded := range b.RotationPubKeysBase64Encoded {
+			rotationKey, err := das.DecodeBase64BLSPublicKey([]byte(encoded))
+			if err != nil {
+				return nil, err
+			}
+			rotationKeys = append(rotationKeys, *rotationKey)
+		}
+		d.SetRotationKeys(rotationKeys)
+
 		services = append(services, *d)
 	}
 
 	return services, nil
 }
+
+// NewCommitteeReader reads from the committee members in the backend configuration, ignoring their keys, taking
+// the first to respond.
+func NewCommitteeReader(backends string) (arbstate.DataAvailabilityReader, error) {
+	var cs []BackendConfig
+	if err := json.Unmarshal([]byte(backends), &cs); err != nil {
+		return nil, err
+	}
+	var readers []arbstate.DataAvailabilityReader
+	for _, b := range cs {
+		client, err := NewDASRPCClient(b.URL)
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, client)
+	}
+	return das.NewRedundantSimpleDASReader(readers), nil
+}
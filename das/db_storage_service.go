@@ -114,6 +114,12 @@ func (dbs *DBStorageService) Put(ctx context.Context, data []byte, timeout uint6
 	})
 }
 
+func (dbs *DBStorageService) Delete(ctx context.Context, key common.Hash) error {
+	return dbs.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key.Bytes())
+	})
+}
+
 func (dbs *DBStorageService) Sync(ctx context.Context) error {
 	return dbs.db.Sync()
 }
@@ -0,0 +1,87 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/blsSignatures"
+	"github.com/offchainlabs/nitro/util/pretty"
+)
+
+// noopStorageService discards what it is given to store, for dry runs.
+type noopStorageService struct{}
+
+func (noopStorageService) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	return nil, ErrNotFound
+}
+
+func (s noopStorageService) Put(ctx context.Context, data []byte, expirationTime uint64) error {
+	logPut("das.noopStorageService.Store", data, expirationTime, s)
+	return nil
+}
+
+func (noopStorageService) Sync(ctx context.Context) error {
+	return nil
+}
+
+func (noopStorageService) Close(ctx context.Context) error {
+	return nil
+}
+
+func (noopStorageService) String() string {
+	return "noopStorageService"
+}
+
+func (noopStorageService) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func (noopStorageService) ExpirationPolicy(ctx context.Context) (arbstate.ExpirationPolicy, error) {
+	return arbstate.DiscardImmediately, nil
+}
+
+// DryRunDAS stores batches the way a committee member does, checking the batch poster's signature
+// and certifying the batch with a BLS key, but into a storage service which discards them, and
+// under a key of its own which no committee trusts. Reads go to the wrapped service.
+type DryRunDAS struct {
+	DataAvailabilityService
+	writer *SignAfterStoreDAS
+}
+
+func NewDryRunDAS(inner DataAvailabilityService) (*DryRunDAS, error) {
+	_, privKey, err := blsSignatures.GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	key, err := newSigningKey(&privKey, 0)
+	if err != nil {
+		return nil, err
+	}
+	writer := &SignAfterStoreDAS{
+		keys:           []*signingKey{key},
+		storageService: noopStorageService{},
+	}
+	return &DryRunDAS{inner, writer}, nil
+}
+
+func (d *DryRunDAS) Store(ctx context.Context, message []byte, timeout uint64, sig []byte) (*arbstate.DataAvailabilityCertificate, error) {
+	log.Trace("das.DryRunDAS.Store", "message", pretty.FirstFewBytes(message), "timeout", time.Unix(int64(timeout), 0), "sig", pretty.FirstFewBytes(sig))
+	if len(sig) > 0 {
+		if _, err := DasRecoverSigner(message, timeout, sig); err != nil {
+			return nil, fmt.Errorf("dry run store request not properly signed: %w", err)
+		}
+	}
+	return d.writer.Store(ctx, message, timeout, sig)
+}
+
+func (d *DryRunDAS) String() string {
+	return fmt.Sprintf("DryRunDAS(%v)", d.DataAvailabilityService)
+}
@@ -0,0 +1,56 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das/dastree"
+)
+
+func TestDryRunDASStore(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryBackedStorageService(ctx)
+	dryRun, err := NewDryRunDAS(NewReadLimitedDataAvailabilityService(storage))
+	Require(t, err)
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signing, err := NewStoreSigningDAS(dryRun, DasSignerFromPrivateKey(privateKey))
+	Require(t, err)
+
+	message := []byte("a batch posted in dry run mode")
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	cert, err := signing.Store(ctx, message, timeout, []byte{})
+	Require(t, err)
+	if cert.DataHash != dastree.Hash(message) || cert.Timeout != timeout {
+		Fail(t, "certificate doesn't certify the batch", cert)
+	}
+
+	// the certificate is posted in place of the batch, so it must read back as it would from a committee
+	serialized := Serialize(cert)
+	deserialized, err := arbstate.DeserializeDASCertFrom(bytes.NewReader(serialized))
+	Require(t, err)
+	if !bytes.Equal(Serialize(deserialized), serialized) {
+		Fail(t, "certificate didn't round trip")
+	}
+	if cert.KeysetHash != dryRun.writer.keys[0].keysetHash {
+		Fail(t, "certificate isn't under the dry run key")
+	}
+
+	// nothing reaches the backend the node reads from
+	if _, err := storage.GetByHash(ctx, cert.DataHash); !errors.Is(err, ErrNotFound) {
+		Fail(t, "dry run stored the batch", err)
+	}
+
+	if _, err := dryRun.Store(ctx, message, timeout, []byte{1, 2, 3}); err == nil {
+		Fail(t, "dry run accepted a malformed batch poster signature")
+	}
+}
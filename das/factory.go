@@ -5,6 +5,7 @@ package das
 
 import (
 	"context"
+	"fmt"
 )
 
 // Create any storage services that persist to files, database, cloud storage,
@@ -34,7 +35,7 @@ func CreatePersistentStorageService(
 	}
 
 	if config.S3StorageServiceConfig.Enable {
-		s, err := NewS3StorageService(config.S3StorageServiceConfig)
+		s, err := NewS3StorageService(ctx, config.S3StorageServiceConfig)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -42,16 +43,47 @@ func CreatePersistentStorageService(
 		storageServices = append(storageServices, s)
 	}
 
+	if config.IPFSStorageConfig.Enable {
+		s, err := NewIPFSStorageService(config.IPFSStorageConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		lifecycleManager.Register(s)
+		storageServices = append(storageServices, s)
+	}
+
+	var storageService StorageService
 	if len(storageServices) > 1 {
 		s, err := NewRedundantStorageService(ctx, storageServices)
 		if err != nil {
 			return nil, nil, err
 		}
 		lifecycleManager.Register(s)
-		return s, &lifecycleManager, nil
+		storageService = s
+	} else if len(storageServices) == 1 {
+		storageService = storageServices[0]
 	}
-	if len(storageServices) == 1 {
-		return storageServices[0], &lifecycleManager, nil
+
+	if config.StoredIndexConfig.Enable && storageService != nil {
+		if _, ok := storageService.(Deleter); !ok && config.RetentionConfig.Enable {
+			return nil, nil, fmt.Errorf("retention requires a storage which supports deletion, not %v", storageService)
+		}
+		s, err := NewStoredIndexStorageService(storageService, config.StoredIndexConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		lifecycleManager.Register(s)
+		storageService = s
+	}
+
+	if config.RetentionConfig.Enable && storageService != nil {
+		s, err := NewRetentionStorageService(storageService, config.RetentionConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.Start(ctx)
+		lifecycleManager.Register(s)
+		storageService = s
 	}
-	return nil, &lifecycleManager, nil
+	return storageService, &lifecycleManager, nil
 }
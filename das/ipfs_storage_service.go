@@ -0,0 +1,245 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das/dastree"
+	"github.com/offchainlabs/nitro/util/pretty"
+	flag "github.com/spf13/pflag"
+)
+
+type IPFSStorageServiceConfig struct {
+	Enable    bool                  `koanf:"enable"`
+	APIURL    string                `koanf:"api-url"`
+	Directory string                `koanf:"directory"`
+	Timeout   time.Duration         `koanf:"timeout"`
+	Filecoin  FilecoinPinningConfig `koanf:"filecoin"`
+}
+
+// FilecoinPinningConfig asks a remote pinning service, implementing the IPFS Pinning Service API, to pin each batch
+// too. Services backed by Filecoin, such as web3.storage, make storage deals for what they pin.
+type FilecoinPinningConfig struct {
+	Enable            bool   `koanf:"enable"`
+	PinningServiceURL string `koanf:"pinning-service-url"`
+	Token             string `koanf:"token"`
+}
+
+var DefaultIPFSStorageServiceConfig = IPFSStorageServiceConfig{
+	APIURL:    "http://127.0.0.1:5001",
+	Directory: "/nitro-das",
+	Timeout:   time.Minute,
+}
+
+func IPFSStorageServiceConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultIPFSStorageServiceConfig.Enable, "enable storage/retrieval of sequencer batch data from an IPFS node, pinning it by content hash")
+	f.String(prefix+".api-url", DefaultIPFSStorageServiceConfig.APIURL, "URL of the IPFS node's RPC API")
+	f.String(prefix+".directory", DefaultIPFSStorageServiceConfig.Directory, "directory of the IPFS node's mutable file system indexing batch data by its DAS hash")
+	f.Duration(prefix+".timeout", DefaultIPFSStorageServiceConfig.Timeout, "timeout of each request to the IPFS node or pinning service")
+	FilecoinPinningConfigAddOptions(prefix+".filecoin", f)
+}
+
+func FilecoinPinningConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", false, "also pin batch data with a remote pinning service making Filecoin deals for long-term retention")
+	f.String(prefix+".pinning-service-url", "", "URL of the IPFS Pinning Service API endpoint, such as https://api.web3.storage")
+	f.String(prefix+".token", "", "access token of the pinning service")
+}
+
+// IPFSStorageService stores batch data in an IPFS node, pinned by its CID. IPFS addresses content by its sha256
+// rather than its DAS hash, so each batch is also linked into a directory of the node's mutable file system under
+// its DAS hash, through which it's found.
+type IPFSStorageService struct {
+	config     IPFSStorageServiceConfig
+	apiURL     string
+	httpClient *http.Client
+}
+
+func NewIPFSStorageService(config IPFSStorageServiceConfig) (StorageService, error) {
+	if config.APIURL == "" {
+		return nil, errors.New("ipfs-storage.api-url must be specified")
+	}
+	if !strings.HasPrefix(config.Directory, "/") {
+		return nil, fmt.Errorf("ipfs-storage.directory %q must be an absolute path", config.Directory)
+	}
+	if config.Filecoin.Enable && config.Filecoin.PinningServiceURL == "" {
+		return nil, errors.New("ipfs-storage.filecoin.pinning-service-url must be specified with ipfs-storage.filecoin.enable")
+	}
+	return &IPFSStorageService{
+		config:     config,
+		apiURL:     strings.TrimSuffix(config.APIURL, "/") + "/api/v0/",
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+func (s *IPFSStorageService) path(key common.Hash) string {
+	return strings.TrimSuffix(s.config.Directory, "/") + "/" + EncodeStorageServiceKey(key)
+}
+
+// ipfsError is the body of the IPFS RPC API's error responses.
+type ipfsError struct {
+	Message string
+}
+
+// call POSTs to the IPFS RPC API command, as it requires, returning the response body.
+func (s *IPFSStorageService) call(ctx context.Context, command string, args url.Values, body io.Reader, contentType string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+command+"?"+args.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		var ipfsErr ipfsError
+		if json.Unmarshal(data, &ipfsErr) == nil && ipfsErr.Message != "" {
+			if strings.Contains(ipfsErr.Message, "does not exist") {
+				return nil, ErrNotFound
+			}
+			return nil, fmt.Errorf("IPFS %v failed: %v", command, ipfsErr.Message)
+		}
+		return nil, fmt.Errorf("IPFS %v failed with HTTP status %v", command, res.Status)
+	}
+	return data, nil
+}
+
+func (s *IPFSStorageService) GetByHash(ctx context.Context, key common.Hash) ([]byte, error) {
+	log.Trace("das.IPFSStorageService.GetByHash", "key", pretty.PrettyHash(key), "this", s)
+	data, err := s.call(ctx, "files/read", url.Values{"arg": {s.path(key)}}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if !dastree.ValidHash(key, data) {
+		return nil, fmt.Errorf("IPFS returned data not matching hash %v", key)
+	}
+	return data, nil
+}
+
+// add adds the data to the IPFS node, pinning it, and returns its CID.
+func (s *IPFSStorageService) add(ctx context.Context, data []byte, name string) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	args := url.Values{"pin": {"true"}, "cid-version": {"1"}, "quieter": {"true"}}
+	response, err := s.call(ctx, "add", args, body, writer.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+	var added struct {
+		Hash string
+	}
+	if err := json.Unmarshal(response, &added); err != nil {
+		return "", err
+	}
+	if added.Hash == "" {
+		return "", errors.New("IPFS add returned no CID")
+	}
+	return added.Hash, nil
+}
+
+func (s *IPFSStorageService) Put(ctx context.Context, data []byte, timeout uint64) error {
+	logPut("das.IPFSStorageService.Store", data, timeout, s)
+	key := EncodeStorageServiceKey(dastree.Hash(data))
+	cid, err := s.add(ctx, data, key)
+	if err != nil {
+		log.Error("das.IPFSStorageService.Store", "err", err)
+		return err
+	}
+	args := url.Values{"arg": {"/ipfs/" + cid, s.path(dastree.Hash(data))}, "parents": {"true"}}
+	if _, err := s.call(ctx, "files/cp", args, nil, ""); err != nil {
+		// the same content is already linked under its hash
+		if !strings.Contains(err.Error(), "already has entry") {
+			log.Error("das.IPFSStorageService.Store", "err", err)
+			return err
+		}
+	}
+	if s.config.Filecoin.Enable {
+		if err := s.pinRemotely(ctx, cid, key); err != nil {
+			log.Error("das.IPFSStorageService.Store failed to pin with Filecoin pinning service", "cid", cid, "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// pinRemotely requests the pinning service pin the CID, following the IPFS Pinning Service API.
+func (s *IPFSStorageService) pinRemotely(ctx context.Context, cid string, name string) error {
+	body, err := json.Marshal(map[string]string{"cid": cid, "name": name})
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(s.config.Filecoin.PinningServiceURL, "/") + "/pins"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Filecoin.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Filecoin.Token)
+	}
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// the service accepts the pin, then fetches the content from the IPFS network in the background
+	if res.StatusCode != http.StatusAccepted && res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("pinning service returned HTTP status %v: %v", res.Status, string(message))
+	}
+	return nil
+}
+
+func (s *IPFSStorageService) Sync(ctx context.Context) error {
+	return nil
+}
+
+func (s *IPFSStorageService) Close(ctx context.Context) error {
+	s.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (s *IPFSStorageService) ExpirationPolicy(ctx context.Context) (arbstate.ExpirationPolicy, error) {
+	return arbstate.KeepForever, nil
+}
+
+func (s *IPFSStorageService) String() string {
+	return fmt.Sprintf("IPFSStorageService(%v%v)", s.config.APIURL, s.config.Directory)
+}
+
+func (s *IPFSStorageService) HealthCheck(ctx context.Context) error {
+	_, err := s.call(ctx, "id", url.Values{}, nil, "")
+	return err
+}
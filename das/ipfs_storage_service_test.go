@@ -0,0 +1,126 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/offchainlabs/nitro/das/dastree"
+)
+
+// mockIPFSNode implements the parts of the IPFS RPC API and Pinning Service API the storage service uses.
+type mockIPFSNode struct {
+	mutex   sync.Mutex
+	blocks  map[string][]byte
+	files   map[string]string
+	pinned  map[string]bool
+	remotes map[string]bool
+}
+
+func (n *mockIPFSNode) fail(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(map[string]string{"Message": message, "Type": "error"})
+}
+
+func (n *mockIPFSNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	args := r.URL.Query()["arg"]
+	switch r.URL.Path {
+	case "/api/v0/add":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			n.fail(w, err.Error())
+			return
+		}
+		data, _ := io.ReadAll(file)
+		hash := sha256.Sum256(data)
+		cid := "b" + hex.EncodeToString(hash[:])
+		n.blocks[cid] = data
+		n.pinned[cid] = r.URL.Query().Get("pin") == "true"
+		_ = json.NewEncoder(w).Encode(map[string]string{"Hash": cid})
+	case "/api/v0/files/cp":
+		if _, ok := n.files[args[1]]; ok {
+			n.fail(w, "directory already has entry by that name")
+			return
+		}
+		n.files[args[1]] = strings.TrimPrefix(args[0], "/ipfs/")
+	case "/api/v0/files/read":
+		cid, ok := n.files[args[0]]
+		if !ok {
+			n.fail(w, "file does not exist")
+			return
+		}
+		_, _ = w.Write(n.blocks[cid])
+	case "/api/v0/id":
+		_, _ = w.Write([]byte("{}"))
+	case "/pins":
+		var pin map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&pin)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n.remotes[pin["cid"]] = true
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestIPFSStorageService(t *testing.T) {
+	ctx := context.Background()
+	node := &mockIPFSNode{
+		blocks:  make(map[string][]byte),
+		files:   make(map[string]string),
+		pinned:  make(map[string]bool),
+		remotes: make(map[string]bool),
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	config := DefaultIPFSStorageServiceConfig
+	config.APIURL = server.URL
+	config.Filecoin = FilecoinPinningConfig{Enable: true, PinningServiceURL: server.URL, Token: "token"}
+	ipfsService, err := NewIPFSStorageService(config)
+	Require(t, err)
+	Require(t, ipfsService.HealthCheck(ctx))
+
+	value := []byte("The first value")
+	_, err = ipfsService.GetByHash(ctx, dastree.Hash(value))
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	Require(t, ipfsService.Put(ctx, value, timeout))
+	// storing it again is fine
+	Require(t, ipfsService.Put(ctx, value, timeout))
+
+	data, err := ipfsService.GetByHash(ctx, dastree.Hash(value))
+	Require(t, err)
+	if !bytes.Equal(data, value) {
+		t.Fatal(data, value)
+	}
+	if len(node.pinned) != 1 || len(node.remotes) != 1 {
+		t.Fatal("pinned", len(node.pinned), "CIDs locally and", len(node.remotes), "remotely, expected 1")
+	}
+	for cid, pinned := range node.pinned {
+		if !pinned || !node.remotes[cid] {
+			t.Fatal("CID", cid, "not pinned")
+		}
+	}
+}
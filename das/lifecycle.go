@@ -24,6 +24,32 @@ func (m *LifecycleManager) Register(c Closer) {
 	m.toClose = append(m.toClose, c)
 }
 
+// RetentionAuditor returns the retention policy's audit of the components managed, if there is one.
+func (m *LifecycleManager) RetentionAuditor() RetentionAuditor {
+	if m == nil {
+		return nil
+	}
+	for _, c := range m.toClose {
+		if auditor, ok := c.(RetentionAuditor); ok {
+			return auditor
+		}
+	}
+	return nil
+}
+
+// HashLister returns the index of stored data of the components managed, if there is one.
+func (m *LifecycleManager) HashLister() HashLister {
+	if m == nil {
+		return nil
+	}
+	for _, c := range m.toClose {
+		if lister, ok := c.(HashLister); ok {
+			return lister
+		}
+	}
+	return nil
+}
+
 func (m *LifecycleManager) StopAndWaitUntil(t time.Duration) {
 	if m != nil && m.toClose != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), t)
@@ -92,6 +92,14 @@ func (s *LocalFileStorageService) Put(ctx context.Context, data []byte, timeout
 
 }
 
+func (s *LocalFileStorageService) Delete(ctx context.Context, key common.Hash) error {
+	err := os.Remove(s.dataDir + "/" + EncodeStorageServiceKey(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
 func (s *LocalFileStorageService) Sync(ctx context.Context) error {
 	return nil
 }
@@ -53,6 +53,16 @@ func (m *MemoryBackedStorageService) Put(ctx context.Context, data []byte, expir
 	return nil
 }
 
+func (m *MemoryBackedStorageService) Delete(ctx context.Context, key common.Hash) error {
+	m.rwmutex.Lock()
+	defer m.rwmutex.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	delete(m.contents, key)
+	return nil
+}
+
 func (m *MemoryBackedStorageService) Sync(ctx context.Context) error {
 	m.rwmutex.RLock()
 	defer m.rwmutex.RUnlock()
@@ -0,0 +1,165 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/offchainlabs/nitro/util/headerreader"
+	flag "github.com/spf13/pflag"
+)
+
+type MirrorConfig struct {
+	Enable          bool          `koanf:"enable"`
+	Urls            []string      `koanf:"urls"`
+	OnlineUrlList   string        `koanf:"online-url-list"`
+	StartBlock      uint64        `koanf:"start-block"`
+	StateFile       string        `koanf:"state-file"`
+	RetentionPeriod time.Duration `koanf:"retention-period"`
+	L1BlocksPerRead uint64        `koanf:"l1-blocks-per-read"`
+	DelayOnError    time.Duration `koanf:"delay-on-error"`
+}
+
+var DefaultMirrorConfig = MirrorConfig{
+	Urls:            []string{},
+	RetentionPeriod: time.Duration(math.MaxInt64),
+	L1BlocksPerRead: 100,
+	DelayOnError:    time.Second,
+}
+
+func MirrorConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultMirrorConfig.Enable, "continuously replicate batch data from source REST endpoints into this DAS's storage, using L1 as the index of batch data hashes")
+	f.StringSlice(prefix+".urls", DefaultMirrorConfig.Urls, "list of URLs of source REST DAS endpoints to mirror; additive with the online-url-list option")
+	f.String(prefix+".online-url-list", DefaultMirrorConfig.OnlineUrlList, "a URL to a list of URLs of source REST DAS endpoints to mirror; additive with the urls option")
+	f.Uint64(prefix+".start-block", DefaultMirrorConfig.StartBlock, "L1 block to start mirroring from when there's no saved progress")
+	f.String(prefix+".state-file", DefaultMirrorConfig.StateFile, "file saving the L1 block mirroring has reached, to resume from after a restart (empty to always start from start-block)")
+	f.Duration(prefix+".retention-period", DefaultMirrorConfig.RetentionPeriod, "period to retain mirrored data (defaults to forever)")
+	f.Uint64(prefix+".l1-blocks-per-read", DefaultMirrorConfig.L1BlocksPerRead, "max L1 blocks to read per poll")
+	f.Duration(prefix+".delay-on-error", DefaultMirrorConfig.DelayOnError, "time to wait if encountered an error before retrying")
+}
+
+// Mirror replicates the batch data posted to L1 from other DASes into local storage, so a new committee member or
+// public mirror can bootstrap from the existing ones and then stay in sync with them. Data is fetched through a
+// REST aggregator, which only accepts data matching the hash it was requested by.
+type Mirror struct {
+	config      MirrorConfig
+	sources     *SimpleDASReaderAggregator
+	syncService *l1SyncService
+	savedBlock  uint64
+}
+
+func NewMirror(
+	ctx context.Context,
+	config *MirrorConfig,
+	syncTo StorageService,
+	l1Reader *headerreader.HeaderReader,
+	inboxAddr common.Address,
+) (*Mirror, error) {
+	sourcesConfig := DefaultRestfulClientAggregatorConfig
+	sourcesConfig.Enable = true
+	sourcesConfig.Urls = config.Urls
+	sourcesConfig.OnlineUrlList = config.OnlineUrlList
+	sources, err := NewRestfulClientAggregator(ctx, &sourcesConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating mirror sources: %w", err)
+	}
+
+	startBlock := config.StartBlock
+	if config.StateFile != "" {
+		savedBlock, err := readMirrorState(config.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		if savedBlock > startBlock {
+			startBlock = savedBlock
+		}
+	}
+	syncConfig := SyncToStorageConfig{
+		Eager:                true,
+		EagerLowerBoundBlock: startBlock,
+		RetentionPeriod:      config.RetentionPeriod,
+		DelayOnError:         config.DelayOnError,
+		IgnoreWriteErrors:    false,
+		L1BlocksPerRead:      config.L1BlocksPerRead,
+	}
+	syncService, err := newl1SyncService(&syncConfig, syncTo, sources, l1Reader, inboxAddr)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mirror{
+		config:      *config,
+		sources:     sources,
+		syncService: syncService,
+		savedBlock:  startBlock,
+	}
+	syncService.syncedTo = m.syncedTo
+	log.Info("DAS mirror resuming", "block", startBlock, "sources", len(sources.readers))
+	return m, nil
+}
+
+func (m *Mirror) Start(ctx context.Context) {
+	m.sources.Start(ctx)
+	m.syncService.Start(ctx)
+}
+
+func (m *Mirror) syncedTo(block uint64) {
+	if m.config.StateFile == "" || block == m.savedBlock {
+		return
+	}
+	if err := writeMirrorState(m.config.StateFile, block); err != nil {
+		log.Warn("failed to save DAS mirror progress", "file", m.config.StateFile, "err", err)
+		return
+	}
+	m.savedBlock = block
+}
+
+func readMirrorState(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	block, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid DAS mirror state file %v: %w", path, err)
+	}
+	return block, nil
+}
+
+// writeMirrorState replaces the state file through a rename, so a crash can't leave it partially written.
+func writeMirrorState(path string, block uint64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(strconv.FormatUint(block, 10) + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (m *Mirror) Close(ctx context.Context) error {
+	m.syncService.StopAndWait()
+	return m.sources.Close(ctx)
+}
+
+func (m *Mirror) String() string {
+	return fmt.Sprintf("Mirror(%v)", m.sources)
+}
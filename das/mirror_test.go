@@ -0,0 +1,25 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMirrorState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirror.state")
+	block, err := readMirrorState(path)
+	Require(t, err)
+	if block != 0 {
+		t.Fatal("read block", block, "without a state file")
+	}
+	Require(t, writeMirrorState(path, 1234))
+	Require(t, writeMirrorState(path, 5678))
+	block, err = readMirrorState(path)
+	Require(t, err)
+	if block != 5678 {
+		t.Fatal("read block", block, "expected 5678")
+	}
+}
@@ -6,6 +6,7 @@ package das
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -101,6 +102,38 @@ func (r *RedundantStorageService) Sync(ctx context.Context) error {
 	return anyError
 }
 
+// Delete deletes the data from the replicas which support it; others keep it. It tries every replica, and
+// returns an error naming each which failed.
+func (r *RedundantStorageService) Delete(ctx context.Context, key common.Hash) error {
+	var wg sync.WaitGroup
+	var errorMutex sync.Mutex
+	var errs []error
+	for _, serv := range r.innerServices {
+		deleter, ok := serv.(Deleter)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(s StorageService, d Deleter) {
+			err := d.Delete(ctx, key)
+			if err != nil {
+				errorMutex.Lock()
+				errs = append(errs, fmt.Errorf("%v: %w", s, err))
+				errorMutex.Unlock()
+			}
+			wg.Done()
+		}(serv, deleter)
+	}
+	wg.Wait()
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("failed to delete %v from %d replicas: %v", pretty.PrettyHash(key), len(errs), errs)
+}
+
 func (r *RedundantStorageService) Close(ctx context.Context) error {
 	var wg sync.WaitGroup
 	var errorMutex sync.Mutex
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -63,3 +64,26 @@ func TestRedundantStorageService(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRedundantStorageServiceDelete(t *testing.T) {
+	ctx := context.Background()
+	working := NewMemoryBackedStorageService(ctx)
+	services := []StorageService{working}
+	for i := 0; i < 2; i++ {
+		closed := NewMemoryBackedStorageService(ctx)
+		Require(t, closed.Close(ctx))
+		services = append(services, closed)
+	}
+	redundantService, err := NewRedundantStorageService(ctx, services)
+	Require(t, err)
+
+	val := []byte("deleted from the replicas that can")
+	Require(t, working.Put(ctx, val, uint64(time.Now().Add(time.Hour).Unix())))
+	err = redundantService.(Deleter).Delete(ctx, dastree.Hash(val))
+	if err == nil || strings.Count(err.Error(), ErrClosed.Error()) != 2 {
+		Fail(t, "expected both failures to be reported", err)
+	}
+	if _, err := working.GetByHash(ctx, dastree.Hash(val)); !errors.Is(err, ErrNotFound) {
+		Fail(t, "data not deleted from the working replica", err)
+	}
+}
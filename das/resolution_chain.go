@@ -0,0 +1,246 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das/dastree"
+	"github.com/offchainlabs/nitro/util/pretty"
+	flag "github.com/spf13/pflag"
+)
+
+// The kinds of source batch data can be resolved from
+const (
+	ResolutionSourceLocal     = "local"
+	ResolutionSourceRest      = "rest"
+	ResolutionSourceCommittee = "committee"
+	ResolutionSourceChain     = "chain"
+)
+
+type ResolutionSourceConfig struct {
+	Timeout         time.Duration `koanf:"timeout"`
+	BreakerFailures int           `koanf:"breaker-failures"`
+	BreakerCooldown time.Duration `koanf:"breaker-cooldown"`
+}
+
+var DefaultResolutionSourceConfig = ResolutionSourceConfig{
+	Timeout:         5 * time.Second,
+	BreakerFailures: 5,
+	BreakerCooldown: 30 * time.Second,
+}
+
+func ResolutionSourceConfigAddOptions(prefix string, f *flag.FlagSet, defaultConfig ResolutionSourceConfig) {
+	f.Duration(prefix+".timeout", defaultConfig.Timeout, "how long to wait for the source before trying the next")
+	f.Int(prefix+".breaker-failures", defaultConfig.BreakerFailures, "consecutive failures after which the source is skipped until the cooldown has passed (0 to never skip it)")
+	f.Duration(prefix+".breaker-cooldown", defaultConfig.BreakerCooldown, "how long to skip the source for once it has failed too often")
+}
+
+// ResolutionChainConfig orders where batch data is resolved from, each source only tried once the ones before
+// it didn't have the data.
+type ResolutionChainConfig struct {
+	Enable            bool                   `koanf:"enable"`
+	Order             []string               `koanf:"order"`
+	RestUrls          []string               `koanf:"rest-urls"`
+	CommitteeBackends string                 `koanf:"committee-backends"`
+	Local             ResolutionSourceConfig `koanf:"local"`
+	Rest              ResolutionSourceConfig `koanf:"rest"`
+	Committee         ResolutionSourceConfig `koanf:"committee"`
+	Chain             ResolutionSourceConfig `koanf:"chain"`
+}
+
+var DefaultResolutionChainConfig = ResolutionChainConfig{
+	Order:     []string{ResolutionSourceLocal, ResolutionSourceRest, ResolutionSourceCommittee, ResolutionSourceChain},
+	RestUrls:  []string{},
+	Local:     ResolutionSourceConfig{Timeout: time.Second},
+	Rest:      DefaultResolutionSourceConfig,
+	Committee: DefaultResolutionSourceConfig,
+	Chain:     ResolutionSourceConfig{Timeout: 30 * time.Second},
+}
+
+func ResolutionChainConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultResolutionChainConfig.Enable, "resolve batch data from the sources in the configured order, with per-source timeouts and circuit breakers")
+	f.StringSlice(prefix+".order", DefaultResolutionChainConfig.Order, "order to try the sources in, of 'local' (this node's caches and storage), 'rest' (rest-urls), 'committee' (committee-backends) and 'chain' (keysets on L1); sources that aren't configured are skipped")
+	f.StringSlice(prefix+".rest-urls", DefaultResolutionChainConfig.RestUrls, "list of URLs of REST DAS endpoints to resolve from")
+	f.String(prefix+".committee-backends", DefaultResolutionChainConfig.CommitteeBackends, "JSON RPC backend configuration of the committee members to resolve from, in the format of rpc-aggregator.backends (defaults to it)")
+	ResolutionSourceConfigAddOptions(prefix+".local", f, DefaultResolutionChainConfig.Local)
+	ResolutionSourceConfigAddOptions(prefix+".rest", f, DefaultResolutionChainConfig.Rest)
+	ResolutionSourceConfigAddOptions(prefix+".committee", f, DefaultResolutionChainConfig.Committee)
+	ResolutionSourceConfigAddOptions(prefix+".chain", f, DefaultResolutionChainConfig.Chain)
+}
+
+func (c *ResolutionChainConfig) sourceConfig(kind string) (ResolutionSourceConfig, error) {
+	switch kind {
+	case ResolutionSourceLocal:
+		return c.Local, nil
+	case ResolutionSourceRest:
+		return c.Rest, nil
+	case ResolutionSourceCommittee:
+		return c.Committee, nil
+	case ResolutionSourceChain:
+		return c.Chain, nil
+	default:
+		return ResolutionSourceConfig{}, fmt.Errorf("unknown resolution source %v", kind)
+	}
+}
+
+// resolutionSource is a source in the chain, with a circuit breaker skipping it while it keeps failing.
+type resolutionSource struct {
+	kind   string
+	reader arbstate.DataAvailabilityReader
+	config ResolutionSourceConfig
+
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+
+	successCounter metrics.Counter
+	failureCounter metrics.Counter
+	skippedCounter metrics.Counter
+	openGauge      metrics.Gauge
+}
+
+func newResolutionSource(kind string, reader arbstate.DataAvailabilityReader, config ResolutionSourceConfig) *resolutionSource {
+	prefix := "arb/das/resolution/" + kind + "/"
+	return &resolutionSource{
+		kind:           kind,
+		reader:         reader,
+		config:         config,
+		successCounter: metrics.GetOrRegisterCounter(prefix+"success", nil),
+		failureCounter: metrics.GetOrRegisterCounter(prefix+"failure", nil),
+		skippedCounter: metrics.GetOrRegisterCounter(prefix+"skipped", nil),
+		openGauge:      metrics.GetOrRegisterGauge(prefix+"breakeropen", nil),
+	}
+}
+
+// available returns whether the breaker lets a request through. Once the cooldown has passed one is let through
+// to probe the source, which closes the breaker if it succeeds and reopens it if it fails.
+func (s *resolutionSource) available(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Before(s.openUntil) {
+		return false
+	}
+	if s.config.BreakerFailures > 0 && s.failures >= s.config.BreakerFailures {
+		s.openUntil = now.Add(s.config.BreakerCooldown)
+	}
+	return true
+}
+
+func (s *resolutionSource) record(now time.Time, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil || errors.Is(err, ErrNotFound) {
+		// the source answered, even if it didn't have the data
+		if s.failures > 0 && !s.openUntil.IsZero() {
+			log.Info("DAS resolution source recovered", "source", s.kind)
+		}
+		s.failures = 0
+		s.openUntil = time.Time{}
+		s.openGauge.Update(0)
+		return
+	}
+	s.failures++
+	if s.config.BreakerFailures > 0 && s.failures >= s.config.BreakerFailures {
+		if s.openUntil.IsZero() {
+			log.Warn("DAS resolution source failing, skipping it", "source", s.kind, "failures", s.failures, "err", err)
+		}
+		s.openUntil = now.Add(s.config.BreakerCooldown)
+		s.openGauge.Update(1)
+	}
+}
+
+func (s *resolutionSource) getByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+	data, err := s.reader.GetByHash(ctx, hash)
+	if err == nil && !dastree.ValidHash(hash, data) {
+		err = arbstate.ErrHashMismatch
+	}
+	return data, err
+}
+
+// ResolutionChainDAS resolves batch data from an ordered list of sources, passing everything else to the
+// DataAvailabilityService it wraps.
+type ResolutionChainDAS struct {
+	DataAvailabilityService
+	sources []*resolutionSource
+}
+
+// NewResolutionChainDAS orders the readers given, by kind, as configured, skipping kinds without a reader.
+func NewResolutionChainDAS(inner DataAvailabilityService, config *ResolutionChainConfig, readers map[string]arbstate.DataAvailabilityReader) (*ResolutionChainDAS, error) {
+	var sources []*resolutionSource
+	seen := make(map[string]bool)
+	for _, kind := range config.Order {
+		sourceConfig, err := config.sourceConfig(kind)
+		if err != nil {
+			return nil, err
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("resolution source %v is listed twice", kind)
+		}
+		seen[kind] = true
+		reader := readers[kind]
+		if reader == nil {
+			log.Info("DAS resolution source isn't configured, skipping it", "source", kind)
+			continue
+		}
+		sources = append(sources, newResolutionSource(kind, reader, sourceConfig))
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("none of the DAS resolution sources are configured")
+	}
+	return &ResolutionChainDAS{
+		DataAvailabilityService: inner,
+		sources:                 sources,
+	}, nil
+}
+
+func (r *ResolutionChainDAS) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	log.Trace("das.ResolutionChainDAS.GetByHash", "key", pretty.PrettyHash(hash))
+	var errs []error
+	for _, source := range r.sources {
+		if !source.available(time.Now()) {
+			source.skippedCounter.Inc(1)
+			continue
+		}
+		data, err := source.getByHash(ctx, hash)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		source.record(time.Now(), err)
+		if err == nil {
+			source.successCounter.Inc(1)
+			return data, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			source.failureCounter.Inc(1)
+		}
+		errs = append(errs, fmt.Errorf("%v: %w", source.kind, err))
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("all DAS resolution sources are being skipped after failing: %w", ErrNotFound)
+	}
+	return nil, fmt.Errorf("failed to resolve %v from any source: %v", hash, errs)
+}
+
+func (r *ResolutionChainDAS) String() string {
+	var kinds []string
+	for _, source := range r.sources {
+		kinds = append(kinds, source.kind)
+	}
+	return fmt.Sprintf("ResolutionChainDAS(%v, %v)", strings.Join(kinds, ","), r.DataAvailabilityService)
+}
@@ -0,0 +1,86 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das/dastree"
+)
+
+type flakyReader struct {
+	arbstate.DataAvailabilityReader
+	fail  bool
+	calls int
+}
+
+func (r *flakyReader) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	r.calls++
+	if r.fail {
+		return nil, errors.New("unavailable")
+	}
+	return r.DataAvailabilityReader.GetByHash(ctx, hash)
+}
+
+func TestResolutionChain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := []byte("resolved from the committee")
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	local := NewMemoryBackedStorageService(ctx)
+	rest := &flakyReader{DataAvailabilityReader: NewMemoryBackedStorageService(ctx), fail: true}
+	committeeStorage := NewMemoryBackedStorageService(ctx)
+	Require(t, committeeStorage.Put(ctx, data, timeout))
+	committee := &flakyReader{DataAvailabilityReader: committeeStorage}
+
+	config := DefaultResolutionChainConfig
+	config.Rest.BreakerFailures = 2
+	config.Rest.BreakerCooldown = time.Hour
+	chain, err := NewResolutionChainDAS(NewReadLimitedDataAvailabilityService(local), &config, map[string]arbstate.DataAvailabilityReader{
+		ResolutionSourceLocal:     local,
+		ResolutionSourceRest:      rest,
+		ResolutionSourceCommittee: committee,
+	})
+	Require(t, err)
+	if len(chain.sources) != 3 {
+		Fail(t, "expected the unconfigured chain source to be skipped")
+	}
+
+	for i := 0; i < 3; i++ {
+		resolved, err := chain.GetByHash(ctx, dastree.Hash(data))
+		Require(t, err)
+		if !bytes.Equal(resolved, data) {
+			Fail(t, "resolved", resolved, "expected", data)
+		}
+	}
+	if rest.calls != 2 {
+		Fail(t, "failing source was tried", rest.calls, "times, expected its breaker to open after 2")
+	}
+	if committee.calls != 3 {
+		Fail(t, "committee was tried", committee.calls, "times, expected 3")
+	}
+
+	// the local source not having the data doesn't count against it
+	if chain.sources[0].failures != 0 {
+		Fail(t, "local source counted", chain.sources[0].failures, "failures")
+	}
+
+	_, err = chain.GetByHash(ctx, dastree.Hash([]byte("absent")))
+	if err == nil {
+		Fail(t, "resolved absent data")
+	}
+
+	config.Order = []string{ResolutionSourceLocal, "unknown"}
+	_, err = NewResolutionChainDAS(NewReadLimitedDataAvailabilityService(local), &config, nil)
+	if err == nil {
+		Fail(t, "accepted an unknown source")
+	}
+}
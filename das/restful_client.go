@@ -4,14 +4,20 @@
 package das
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/offchainlabs/nitro/arbstate"
@@ -40,10 +46,15 @@ func NewRestfulDasClientFromURL(url string) (*RestfulDasClient, error) {
 }
 
 func (c *RestfulDasClient) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
-	res, err := http.Get(c.url + getByHashRequestPath + EncodeStorageServiceKey(hash))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+getByHashRequestPath+EncodeStorageServiceKey(hash), nil)
 	if err != nil {
 		return nil, err
 	}
+	res, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP error with status %d returned by server: %s", res.StatusCode, http.StatusText(res.StatusCode))
 	}
@@ -103,3 +114,75 @@ func (c *RestfulDasClient) ExpirationPolicy(ctx context.Context) (arbstate.Expir
 
 	return arbstate.StringToExpirationPolicy(response.ExpirationPolicy)
 }
+
+// ListHashes lists the hashes of data the server stored by time range, as HashLister does.
+func (c *RestfulDasClient) ListHashes(ctx context.Context, from, to uint64, after common.Hash, limit int) ([]StoredHashRecord, error) {
+	query := url.Values{}
+	query.Set("from", strconv.FormatUint(from, 10))
+	query.Set("to", strconv.FormatUint(to, 10))
+	if after != (common.Hash{}) {
+		query.Set("after", EncodeStorageServiceKey(after))
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+listHashesRequestPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error with status %d returned by server: %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	var records []StoredHashRecord
+	if err := json.NewDecoder(res.Body).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Export streams the data the server stored from the unix time from up to to (0 for no bound), passing each to
+// the callback once it's checked against its hash.
+func (c *RestfulDasClient) Export(ctx context.Context, from, to uint64, callback func(hash common.Hash, storedAt time.Time, data []byte) error) error {
+	query := url.Values{}
+	query.Set("from", strconv.FormatUint(from, 10))
+	query.Set("to", strconv.FormatUint(to, 10))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+exportRequestPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error with status %d returned by server: %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	archive := tar.NewReader(res.Body)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		hash, err := DecodeStorageServiceKey(header.Name)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return err
+		}
+		if !dastree.ValidHash(hash, data) {
+			return arbstate.ErrHashMismatch
+		}
+		if err := callback(hash, header.ModTime, data); err != nil {
+			return err
+		}
+	}
+}
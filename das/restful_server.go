@@ -4,6 +4,7 @@
 package das
 
 import (
+	"archive/tar"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -11,7 +12,11 @@ import (
 	"net"
 	"net/http"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -40,6 +45,11 @@ type RestfulDasServer struct {
 	storage              arbstate.DataAvailabilityReader
 	httpServerExitedChan chan interface{}
 	httpServerError      error
+	retention            atomic.Value // RetentionAuditor, if the retention audit is served
+	hashLister           atomic.Value // HashLister, if stored data is listed and exported
+
+	tenantsMutex sync.RWMutex
+	tenants      map[string]*RestfulDasServer // further chains served under /<name>/
 }
 
 func NewRestfulDasServer(address string, port uint64, restServerTimeouts genericconf.HTTPServerTimeoutConfig, storageService arbstate.DataAvailabilityReader) (*RestfulDasServer, error) {
@@ -76,6 +86,52 @@ func NewRestfulDasServerOnListener(listener net.Listener, restServerTimeouts gen
 	return ret, nil
 }
 
+// NewRestfulDasTenant creates the handler of a further chain's requests, to add to a server with AddTenant.
+func NewRestfulDasTenant(storageService arbstate.DataAvailabilityReader) *RestfulDasServer {
+	return &RestfulDasServer{storage: storageService}
+}
+
+var tenantNameRegex = regexp.MustCompile("^[a-z0-9][a-z0-9-]*$")
+
+// AddTenant serves a further chain's requests under /<name>/, for running the DAS of several chains in one
+// process with their own keys and storage.
+func (rds *RestfulDasServer) AddTenant(name string, tenant *RestfulDasServer) error {
+	if !tenantNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid tenant name %v, must be lowercase letters, digits and dashes", name)
+	}
+	for _, requestPath := range []string{healthRequestPath, expirationPolicyRequestPath, getByHashRequestPath, retentionExpiredRequestPath, listHashesRequestPath, exportRequestPath} {
+		if strings.Split(requestPath, "/")[1] == name {
+			return fmt.Errorf("tenant name %v conflicts with the request path %v", name, requestPath)
+		}
+	}
+	rds.tenantsMutex.Lock()
+	defer rds.tenantsMutex.Unlock()
+	if rds.tenants == nil {
+		rds.tenants = make(map[string]*RestfulDasServer)
+	}
+	if _, exists := rds.tenants[name]; exists {
+		return fmt.Errorf("tenant %v added twice", name)
+	}
+	rds.tenants[name] = tenant
+	return nil
+}
+
+// tenant returns the tenant the request path is routed to and the path within it, or nil if it isn't routed to
+// one.
+func (rds *RestfulDasServer) tenant(requestPath string) (*RestfulDasServer, string) {
+	rds.tenantsMutex.RLock()
+	defer rds.tenantsMutex.RUnlock()
+	if len(rds.tenants) == 0 {
+		return nil, ""
+	}
+	name, rest, _ := strings.Cut(strings.TrimPrefix(requestPath, "/"), "/")
+	tenant, ok := rds.tenants[name]
+	if !ok {
+		return nil, ""
+	}
+	return tenant, "/" + rest
+}
+
 type RestfulDasServerResponse struct {
 	Data             string `json:"data,omitempty"`
 	ExpirationPolicy string `json:"expirationPolicy,omitempty"`
@@ -87,10 +143,29 @@ const cacheControlValue = "public, max-age=2419200, immutable" // cache for up t
 const healthRequestPath = "/health"
 const expirationPolicyRequestPath = "/expiration-policy/"
 const getByHashRequestPath = "/get-by-hash/"
+const retentionExpiredRequestPath = "/retention/expired"
+const listHashesRequestPath = "/list-hashes"
+const exportRequestPath = "/export"
+
+const maxRetentionExpiredLimit = 10000
+const maxListHashesLimit = 10000
 
 func (rds *RestfulDasServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestPath := path.Clean(r.URL.Path)
 	log.Debug("Got request", "requestPath", requestPath)
+	if tenant, tenantPath := rds.tenant(requestPath); tenant != nil {
+		tenant.serve(w, r, tenantPath)
+		return
+	}
+	if rds.storage == nil {
+		// only tenants are served
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rds.serve(w, r, requestPath)
+}
+
+func (rds *RestfulDasServer) serve(w http.ResponseWriter, r *http.Request, requestPath string) {
 	switch {
 	case strings.HasPrefix(requestPath, healthRequestPath):
 		rds.HealthHandler(w, r, requestPath)
@@ -98,6 +173,12 @@ func (rds *RestfulDasServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		rds.ExpirationPolicyHandler(w, r, requestPath)
 	case strings.HasPrefix(requestPath, getByHashRequestPath):
 		rds.GetByHashHandler(w, r, requestPath)
+	case requestPath == retentionExpiredRequestPath:
+		rds.RetentionExpiredHandler(w, r, requestPath)
+	case requestPath == listHashesRequestPath:
+		rds.ListHashesHandler(w, r, requestPath)
+	case requestPath == exportRequestPath:
+		rds.ExportHandler(w, r, requestPath)
 	default:
 		log.Warn("Unknown requestPath", "requestPath", requestPath)
 		w.WriteHeader(http.StatusBadRequest)
@@ -188,6 +269,176 @@ func (rds *RestfulDasServer) GetByHashHandler(w http.ResponseWriter, r *http.Req
 	success = true
 }
 
+// SetRetentionAuditor serves the audit of data expired by the retention policy.
+func (rds *RestfulDasServer) SetRetentionAuditor(auditor RetentionAuditor) {
+	rds.retention.Store(auditor)
+}
+
+// RetentionExpiredHandler lists the data expired by the retention policy, from the unix time in the since query
+// parameter, up to limit records.
+func (rds *RestfulDasServer) RetentionExpiredHandler(w http.ResponseWriter, r *http.Request, requestPath string) {
+	auditor, ok := rds.retention.Load().(RetentionAuditor)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var since uint64
+	limit := 1000
+	var err error
+	if value := r.URL.Query().Get("since"); value != "" {
+		since, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxRetentionExpiredLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	records, err := auditor.ExpiredSince(since, limit)
+	if err != nil {
+		log.Warn("Error listing expired data", "path", requestPath, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(records)
+	if err != nil {
+		log.Warn("Failed encoding and writing response", "path", requestPath, "err", err)
+	}
+}
+
+// SetHashLister serves listing stored data by time range, and exporting it in bulk.
+func (rds *RestfulDasServer) SetHashLister(lister HashLister) {
+	rds.hashLister.Store(lister)
+}
+
+// parseTimeRange parses the from and to query parameters, as unix times.
+func parseTimeRange(r *http.Request) (uint64, uint64, error) {
+	var from, to uint64
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		from, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		to, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return from, to, nil
+}
+
+// ListHashesHandler lists the hashes of data stored from the unix time in the from query parameter up to the one
+// in to, after the hash in after if given, up to limit records.
+func (rds *RestfulDasServer) ListHashesHandler(w http.ResponseWriter, r *http.Request, requestPath string) {
+	lister, ok := rds.hashLister.Load().(HashLister)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var after common.Hash
+	if value := r.URL.Query().Get("after"); value != "" {
+		after, err = DecodeStorageServiceKey(value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 1000
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxListHashesLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	records, err := lister.ListHashes(from, to, after, limit)
+	if err != nil {
+		log.Warn("Error listing stored data", "path", requestPath, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(records)
+	if err != nil {
+		log.Warn("Failed encoding and writing response", "path", requestPath, "err", err)
+	}
+}
+
+// ExportHandler streams a tar archive of the data stored from the unix time in the from query parameter up to the
+// one in to, each file named by the data's hash. Data no longer stored is left out. Large exports may need a
+// longer write timeout than the server's default.
+func (rds *RestfulDasServer) ExportHandler(w http.ResponseWriter, r *http.Request, requestPath string) {
+	lister, ok := rds.hashLister.Load().(HashLister)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// Once streaming, errors abort the response, so the client sees the archive is incomplete
+	w.Header().Set("Content-Type", "application/x-tar")
+	archive := tar.NewWriter(w)
+	exported := 0
+	var after common.Hash
+	for {
+		records, err := lister.ListHashes(from, to, after, maxListHashesLimit)
+		if err != nil {
+			log.Warn("Error listing stored data to export", "path", requestPath, "err", err)
+			panic(http.ErrAbortHandler)
+		}
+		for _, record := range records {
+			data, err := rds.storage.GetByHash(r.Context(), record.Hash)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				log.Warn("Error reading stored data to export", "path", requestPath, "hash", record.Hash, "err", err)
+				panic(http.ErrAbortHandler)
+			}
+			header := &tar.Header{
+				Name:    EncodeStorageServiceKey(record.Hash),
+				Mode:    0644,
+				Size:    int64(len(data)),
+				ModTime: time.Unix(int64(record.StoredAt), 0),
+			}
+			if err := archive.WriteHeader(header); err != nil {
+				log.Warn("Failed writing export", "path", requestPath, "err", err)
+				return
+			}
+			if _, err := archive.Write(data); err != nil {
+				log.Warn("Failed writing export", "path", requestPath, "err", err)
+				return
+			}
+			exported++
+		}
+		if len(records) < maxListHashesLimit {
+			break
+		}
+		last := records[len(records)-1]
+		from, after = last.StoredAt, last.Hash
+	}
+	if err := archive.Close(); err != nil {
+		log.Warn("Failed writing export", "path", requestPath, "err", err)
+		return
+	}
+	log.Info("Exported stored data", "path", requestPath, "count", exported)
+}
+
 func (rds *RestfulDasServer) GetServerExitedChan() <-chan interface{} { // channel will close when server terminates
 	return rds.httpServerExitedChan
 }
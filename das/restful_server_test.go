@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/cmd/genericconf"
 	"github.com/offchainlabs/nitro/das/dastree"
@@ -69,3 +70,122 @@ func TestRestfulClientServer(t *testing.T) {
 	err = server.Shutdown()
 	Require(t, err)
 }
+
+func TestRestfulServerListAndExport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage, err := NewStoredIndexStorageService(NewMemoryBackedStorageService(ctx), StoredIndexConfig{Enable: true, Dir: t.TempDir()})
+	Require(t, err)
+	defer storage.Close(ctx)
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	stored := map[common.Hash][]byte{}
+	for _, data := range [][]byte{[]byte("first batch"), []byte("second batch"), []byte("third batch")} {
+		Require(t, storage.Put(ctx, data, timeout))
+		stored[dastree.Hash(data)] = data
+	}
+
+	server, port, err := NewRestfulDasServerOnRandomPort(LocalServerAddressForTest, storage)
+	Require(t, err)
+	defer func() {
+		Require(t, server.Shutdown())
+	}()
+	client := NewRestfulDasClient("http", LocalServerAddressForTest, port)
+	_, err = client.ListHashes(ctx, 0, 0, common.Hash{}, 10)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		Fail(t, "expected listing to not be served without a lister", err)
+	}
+	server.SetHashLister(storage)
+
+	// page through the listing
+	var listed []StoredHashRecord
+	var from uint64
+	var after common.Hash
+	for {
+		records, err := client.ListHashes(ctx, from, 0, after, 2)
+		Require(t, err)
+		listed = append(listed, records...)
+		if len(records) < 2 {
+			break
+		}
+		from, after = records[len(records)-1].StoredAt, records[len(records)-1].Hash
+	}
+	if len(listed) != len(stored) {
+		Fail(t, "listed", len(listed), "hashes, expected", len(stored))
+	}
+	for _, record := range listed {
+		if stored[record.Hash] == nil || record.Timeout != timeout {
+			Fail(t, "unexpected record", record)
+		}
+	}
+
+	records, err := client.ListHashes(ctx, 0, listed[0].StoredAt, common.Hash{}, 10)
+	Require(t, err)
+	if len(records) != 0 {
+		Fail(t, "listed", len(records), "hashes stored before the range")
+	}
+
+	exported := map[common.Hash][]byte{}
+	err = client.Export(ctx, 0, 0, func(hash common.Hash, storedAt time.Time, data []byte) error {
+		exported[hash] = data
+		return nil
+	})
+	Require(t, err)
+	if len(exported) != len(stored) {
+		Fail(t, "exported", len(exported), "batches, expected", len(stored))
+	}
+	for hash, data := range stored {
+		if !bytes.Equal(exported[hash], data) {
+			Fail(t, "exported", exported[hash], "expected", data)
+		}
+	}
+}
+
+func TestRestfulServerTenants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rootStorage := NewMemoryBackedStorageService(ctx)
+	tenantStorage := NewMemoryBackedStorageService(ctx)
+	rootData := []byte("the root chain's batch")
+	tenantData := []byte("the tenant chain's batch")
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	Require(t, rootStorage.Put(ctx, rootData, timeout))
+	Require(t, tenantStorage.Put(ctx, tenantData, timeout))
+
+	server, port, err := NewRestfulDasServerOnRandomPort(LocalServerAddressForTest, rootStorage)
+	Require(t, err)
+	defer func() {
+		Require(t, server.Shutdown())
+	}()
+	Require(t, server.AddTenant("chain-b", NewRestfulDasTenant(tenantStorage)))
+	if server.AddTenant("health", NewRestfulDasTenant(tenantStorage)) == nil {
+		Fail(t, "added a tenant conflicting with a request path")
+	}
+	if server.AddTenant("Chain/C", NewRestfulDasTenant(tenantStorage)) == nil {
+		Fail(t, "added a tenant with an invalid name")
+	}
+
+	rootClient := NewRestfulDasClient("http", LocalServerAddressForTest, port)
+	tenantClient, err := NewRestfulDasClientFromURL(fmt.Sprintf("http://%s:%d/chain-b", LocalServerAddressForTest, port))
+	Require(t, err)
+
+	data, err := rootClient.GetByHash(ctx, dastree.Hash(rootData))
+	Require(t, err)
+	if !bytes.Equal(data, rootData) {
+		Fail(t, "root returned", data)
+	}
+	data, err = tenantClient.GetByHash(ctx, dastree.Hash(tenantData))
+	Require(t, err)
+	if !bytes.Equal(data, tenantData) {
+		Fail(t, "tenant returned", data)
+	}
+
+	// storage is isolated between chains
+	if _, err := tenantClient.GetByHash(ctx, dastree.Hash(rootData)); err == nil {
+		Fail(t, "tenant served the root chain's data")
+	}
+	if _, err := rootClient.GetByHash(ctx, dastree.Hash(tenantData)); err == nil {
+		Fail(t, "root served the tenant chain's data")
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das/dastree"
+	"github.com/offchainlabs/nitro/util/arbmath"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	retentionExpiredCounter = metrics.NewRegisteredCounter("arb/das/retention/expired", nil)
+	retentionFailedCounter  = metrics.NewRegisteredCounter("arb/das/retention/failed", nil)
+)
+
+type RetentionConfig struct {
+	Enable       bool          `koanf:"enable"`
+	IndexDir     string        `koanf:"index-dir"`
+	SafetyWindow time.Duration `koanf:"safety-window"`
+	Interval     time.Duration `koanf:"interval"`
+	BatchSize    int           `koanf:"batch-size"`
+	DryRun       bool          `koanf:"dry-run"`
+}
+
+var DefaultRetentionConfig = RetentionConfig{
+	SafetyWindow: 7 * 24 * time.Hour,
+	Interval:     10 * time.Minute,
+	BatchSize:    1000,
+}
+
+func RetentionConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultRetentionConfig.Enable, "garbage collect stored batch data once its timeout, by which its batch is finalized, and the safety window have passed")
+	f.String(prefix+".index-dir", DefaultRetentionConfig.IndexDir, "directory of the database indexing stored data by when it expires, and recording what was expired")
+	f.Duration(prefix+".safety-window", DefaultRetentionConfig.SafetyWindow, "how long to keep data after its timeout")
+	f.Duration(prefix+".interval", DefaultRetentionConfig.Interval, "how often to garbage collect expired data")
+	f.Int(prefix+".batch-size", DefaultRetentionConfig.BatchSize, "most data to expire at once")
+	f.Bool(prefix+".dry-run", DefaultRetentionConfig.DryRun, "only record what would be expired in the audit, without deleting it")
+}
+
+// Deleter is implemented by storage services which data can be deleted from.
+type Deleter interface {
+	Delete(ctx context.Context, key common.Hash) error
+}
+
+// ExpiredRecord is an audit entry for data the retention policy expired, or would have in a dry run.
+type ExpiredRecord struct {
+	Hash      common.Hash `json:"hash"`
+	Expiry    uint64      `json:"expiry"`
+	ExpiredAt uint64      `json:"expiredAt"`
+	DryRun    bool        `json:"dryRun,omitempty"`
+}
+
+// RetentionAuditor lists the data a retention policy expired, from the time given onwards.
+type RetentionAuditor interface {
+	ExpiredSince(since uint64, limit int) ([]ExpiredRecord, error)
+}
+
+// The index keeps, by the data's hash, when it expires; the expiry queue, by expiry then hash; and the audit, by
+// the time data was expired then its hash.
+const (
+	retentionExpiryPrefix = byte('k')
+	retentionQueuePrefix  = byte('q')
+	retentionAuditPrefix  = byte('a')
+)
+
+// Data stored without a timeout is kept forever
+const retentionForever = math.MaxUint64
+
+// The longest data whose deletion keeps failing waits between attempts, which start an interval apart and double
+const retentionMaxBackoff = 24 * time.Hour
+
+func retentionKey(prefix byte, at uint64, hash common.Hash) []byte {
+	key := []byte{prefix}
+	if prefix != retentionExpiryPrefix {
+		key = append(key, arbmath.UintToBytes(at)...)
+	}
+	return append(key, hash.Bytes()...)
+}
+
+// RetentionStorageService garbage collects data from the storage it wraps once the data's timeout and a safety
+// window have passed. As a store's timeout is after its batch has been posted to L1 and finalized, the window is
+// kept for anyone still catching up. Data stored more than once is kept until the latest expiry.
+type RetentionStorageService struct {
+	StorageService
+	stopwaiter.StopWaiter
+	deleter        Deleter
+	config         RetentionConfig
+	index          *badger.DB
+	dryRunReported []byte // queue key up to which a dry run has audited, as it deletes nothing to move past
+}
+
+func NewRetentionStorageService(base StorageService, config RetentionConfig) (*RetentionStorageService, error) {
+	deleter, ok := base.(Deleter)
+	if !ok {
+		return nil, fmt.Errorf("retention requires a storage which supports deletion, not %v", base)
+	}
+	if config.IndexDir == "" {
+		return nil, errors.New("retention.index-dir must be specified")
+	}
+	if config.BatchSize <= 0 {
+		return nil, errors.New("retention.batch-size must be positive")
+	}
+	index, err := badger.Open(badger.DefaultOptions(config.IndexDir))
+	if err != nil {
+		return nil, err
+	}
+	return &RetentionStorageService{
+		StorageService: base,
+		deleter:        deleter,
+		config:         config,
+		index:          index,
+	}, nil
+}
+
+func (r *RetentionStorageService) Start(ctxIn context.Context) {
+	r.StopWaiter.Start(ctxIn)
+	r.CallIteratively(func(ctx context.Context) time.Duration {
+		count, err := r.collect(ctx, uint64(time.Now().Unix()))
+		if err != nil {
+			log.Error("failed to garbage collect expired DAS data", "err", err)
+			return r.config.Interval
+		}
+		if count >= r.config.BatchSize {
+			// there may be more already expired
+			return 0
+		}
+		return r.config.Interval
+	})
+}
+
+func (r *RetentionStorageService) Put(ctx context.Context, data []byte, timeout uint64) error {
+	if err := r.StorageService.Put(ctx, data, timeout); err != nil {
+		return err
+	}
+	expiry := uint64(retentionForever)
+	if timeout != 0 && timeout != math.MaxUint64 {
+		expiry = arbmath.SaturatingUAdd(timeout, uint64(r.config.SafetyWindow.Seconds()))
+	}
+	hash := dastree.Hash(data)
+	return r.index.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(retentionKey(retentionExpiryPrefix, 0, hash))
+		if err == nil {
+			var current uint64
+			err = item.Value(func(val []byte) error {
+				current = binary.BigEndian.Uint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if current >= expiry {
+				return nil
+			}
+			// the superseded queue entry is dropped when it's reached
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		if err := txn.Set(retentionKey(retentionExpiryPrefix, 0, hash), arbmath.UintToBytes(expiry)); err != nil {
+			return err
+		}
+		if expiry == retentionForever {
+			return nil
+		}
+		return txn.Set(retentionKey(retentionQueuePrefix, expiry, hash), nil)
+	})
+}
+
+type retentionQueued struct {
+	key      []byte
+	hash     common.Hash
+	expiry   uint64
+	current  bool   // whether it's still the data's expiry, rather than superseded by a later store
+	attempts uint64 // how many times deleting it has failed
+}
+
+// collect expires up to a batch of the data which expired by now, returning how many queue entries it handled.
+func (r *RetentionStorageService) collect(ctx context.Context, now uint64) (int, error) {
+	var queued []retentionQueued
+	err := r.index.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte{retentionQueuePrefix}
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		start := []byte{retentionQueuePrefix}
+		if r.config.DryRun && r.dryRunReported != nil {
+			start = append(append([]byte{}, r.dryRunReported...), 0)
+		}
+		for it.Seek(start); it.Valid() && len(queued) < r.config.BatchSize; it.Next() {
+			key := it.Item().KeyCopy(nil)
+			expiry := binary.BigEndian.Uint64(key[1:9])
+			if expiry > now {
+				break
+			}
+			entry := retentionQueued{key: key, hash: common.BytesToHash(key[9:]), expiry: expiry}
+			err := it.Item().Value(func(val []byte) error {
+				if len(val) == 8 {
+					entry.attempts = binary.BigEndian.Uint64(val)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			item, err := txn.Get(retentionKey(retentionExpiryPrefix, 0, entry.hash))
+			if err == nil {
+				err = item.Value(func(val []byte) error {
+					entry.current = binary.BigEndian.Uint64(val) == expiry
+					return nil
+				})
+			}
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			queued = append(queued, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range queued {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		record := ExpiredRecord{Hash: entry.hash, Expiry: entry.expiry, ExpiredAt: now, DryRun: r.config.DryRun}
+		if r.config.DryRun {
+			if entry.current {
+				if err := r.audit(record, nil); err != nil {
+					return 0, err
+				}
+			}
+			r.dryRunReported = entry.key
+			continue
+		}
+		if entry.current {
+			if err := r.deleter.Delete(ctx, entry.hash); err != nil {
+				retentionFailedCounter.Inc(1)
+				retryAt, retryErr := r.retryLater(entry, now)
+				if retryErr != nil {
+					return 0, retryErr
+				}
+				log.Warn("failed to delete expired DAS data", "hash", entry.hash, "attempts", entry.attempts+1, "retryAt", retryAt, "err", err)
+				continue
+			}
+			retentionExpiredCounter.Inc(1)
+		}
+		if err := r.audit(record, &entry); err != nil {
+			return 0, err
+		}
+	}
+	if len(queued) > 0 {
+		log.Info("garbage collected expired DAS data", "handled", len(queued), "dryRun", r.config.DryRun)
+	}
+	return len(queued), nil
+}
+
+// retryLater requeues data which failed to be deleted, backing off exponentially so data which can't be deleted
+// doesn't hold up the rest of the queue. It returns when the deletion will next be attempted.
+func (r *RetentionStorageService) retryLater(entry retentionQueued, now uint64) (uint64, error) {
+	attempts := entry.attempts + 1
+	backoff := r.config.Interval
+	for i := uint64(1); i < attempts && backoff < retentionMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > retentionMaxBackoff || backoff <= 0 {
+		backoff = retentionMaxBackoff
+	}
+	retryAt := arbmath.SaturatingUAdd(now, uint64(backoff.Seconds()))
+	return retryAt, r.index.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(entry.key); err != nil {
+			return err
+		}
+		// the retry becomes the data's expiry, so a later store can still supersede it
+		if err := txn.Set(retentionKey(retentionExpiryPrefix, 0, entry.hash), arbmath.UintToBytes(retryAt)); err != nil {
+			return err
+		}
+		return txn.Set(retentionKey(retentionQueuePrefix, retryAt, entry.hash), arbmath.UintToBytes(attempts))
+	})
+}
+
+// audit records the data as expired, if entry is given also removing it from the index. Superseded queue entries
+// are only removed.
+func (r *RetentionStorageService) audit(record ExpiredRecord, entry *retentionQueued) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.index.Update(func(txn *badger.Txn) error {
+		if entry != nil {
+			if err := txn.Delete(entry.key); err != nil {
+				return err
+			}
+			if !entry.current {
+				return nil
+			}
+			if err := txn.Delete(retentionKey(retentionExpiryPrefix, 0, entry.hash)); err != nil {
+				return err
+			}
+		}
+		return txn.Set(retentionKey(retentionAuditPrefix, record.ExpiredAt, record.Hash), value)
+	})
+}
+
+// ExpiredSince lists up to limit records of data expired at or after the unix time since, oldest first.
+func (r *RetentionStorageService) ExpiredSince(since uint64, limit int) ([]ExpiredRecord, error) {
+	records := []ExpiredRecord{}
+	err := r.index.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte{retentionAuditPrefix}
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(retentionKey(retentionAuditPrefix, since, common.Hash{})); it.Valid() && len(records) < limit; it.Next() {
+			var record ExpiredRecord
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &record)
+			})
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (r *RetentionStorageService) ExpirationPolicy(ctx context.Context) (arbstate.ExpirationPolicy, error) {
+	if r.config.DryRun {
+		return r.StorageService.ExpirationPolicy(ctx)
+	}
+	return arbstate.DiscardAfterDataTimeout, nil
+}
+
+// Close leaves the storage it wraps open, as it's closed on its own.
+func (r *RetentionStorageService) Close(ctx context.Context) error {
+	r.StopAndWait()
+	return r.index.Close()
+}
+
+func (r *RetentionStorageService) String() string {
+	return fmt.Sprintf("RetentionStorageService(%v)", r.StorageService)
+}
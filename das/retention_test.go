@@ -0,0 +1,126 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/offchainlabs/nitro/das/dastree"
+)
+
+func TestRetentionStorageService(t *testing.T) {
+	ctx := context.Background()
+	for _, dryRun := range []bool{false, true} {
+		config := DefaultRetentionConfig
+		config.IndexDir = t.TempDir()
+		config.SafetyWindow = time.Hour
+		config.DryRun = dryRun
+		retention, err := NewRetentionStorageService(NewMemoryBackedStorageService(ctx), config)
+		Require(t, err)
+
+		now := uint64(time.Now().Unix())
+		window := uint64(time.Hour.Seconds())
+		expired := []byte("expired")
+		extended := []byte("stored again with a later timeout")
+		forever := []byte("stored without a timeout")
+		Require(t, retention.Put(ctx, expired, now-window-1))
+		Require(t, retention.Put(ctx, extended, now-window-1))
+		Require(t, retention.Put(ctx, extended, now))
+		Require(t, retention.Put(ctx, forever, 0))
+
+		handled, err := retention.collect(ctx, now)
+		Require(t, err)
+		if handled != 2 {
+			Fail(t, "handled", handled, "expired entries, expected 2")
+		}
+		_, err = retention.GetByHash(ctx, dastree.Hash(expired))
+		if dryRun {
+			Require(t, err, "dry run deleted data")
+		} else if !errors.Is(err, ErrNotFound) {
+			Fail(t, "expired data not deleted", err)
+		}
+		for _, kept := range [][]byte{extended, forever} {
+			_, err = retention.GetByHash(ctx, dastree.Hash(kept))
+			Require(t, err)
+		}
+
+		records, err := retention.ExpiredSince(now, 10)
+		Require(t, err)
+		if len(records) != 1 || records[0].Hash != dastree.Hash(expired) || records[0].DryRun != dryRun {
+			Fail(t, "unexpected audit", records)
+		}
+
+		// nothing more has expired
+		handled, err = retention.collect(ctx, now)
+		Require(t, err)
+		if handled != 0 {
+			Fail(t, "handled", handled, "entries again")
+		}
+		records, err = retention.ExpiredSince(0, 10)
+		Require(t, err)
+		if len(records) != 1 {
+			Fail(t, "audited", len(records), "records, expected 1")
+		}
+		Require(t, retention.index.Close())
+	}
+}
+
+type failingDeleter struct {
+	StorageService
+	fail bool
+}
+
+func (d *failingDeleter) Delete(ctx context.Context, key common.Hash) error {
+	if d.fail {
+		return errors.New("deletion failed")
+	}
+	return d.StorageService.(Deleter).Delete(ctx, key)
+}
+
+func TestRetentionBacksOffFailedDeletions(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultRetentionConfig
+	config.IndexDir = t.TempDir()
+	config.SafetyWindow = time.Hour
+	base := &failingDeleter{StorageService: NewMemoryBackedStorageService(ctx), fail: true}
+	retention, err := NewRetentionStorageService(base, config)
+	Require(t, err)
+	defer func() { Require(t, retention.index.Close()) }()
+
+	now := uint64(time.Now().Unix())
+	interval := uint64(config.Interval.Seconds())
+	data := []byte("undeletable for now")
+	Require(t, retention.Put(ctx, data, now-uint64(time.Hour.Seconds())-1))
+
+	expectHandled := func(at uint64, expected int) {
+		t.Helper()
+		handled, err := retention.collect(ctx, at)
+		Require(t, err)
+		if handled != expected {
+			Fail(t, "handled", handled, "entries at", at, "expected", expected)
+		}
+	}
+	expectHandled(now, 1)
+	// retried an interval later, then after twice that
+	expectHandled(now, 0)
+	expectHandled(now+interval, 1)
+	expectHandled(now+2*interval, 0)
+	expectHandled(now+3*interval, 1)
+
+	base.fail = false
+	expectHandled(now+10*interval, 1)
+	if _, err := retention.GetByHash(ctx, dastree.Hash(data)); !errors.Is(err, ErrNotFound) {
+		Fail(t, "data not deleted once deletion succeeded", err)
+	}
+	records, err := retention.ExpiredSince(0, 10)
+	Require(t, err)
+	if len(records) != 1 || records[0].Hash != dastree.Hash(data) {
+		Fail(t, "unexpected audit", records)
+	}
+}
@@ -6,14 +6,21 @@ package das
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/das/dastree"
 	"github.com/offchainlabs/nitro/util/pretty"
@@ -33,52 +40,234 @@ type S3Downloader interface {
 }
 
 type S3StorageServiceConfig struct {
-	Enable              bool   `koanf:"enable"`
-	AccessKey           string `koanf:"access-key"`
-	Bucket              string `koanf:"bucket"`
-	ObjectPrefix        string `koanf:"object-prefix"`
-	Region              string `koanf:"region"`
-	SecretKey           string `koanf:"secret-key"`
-	DiscardAfterTimeout bool   `koanf:"discard-after-timeout"`
+	Enable               bool              `koanf:"enable"`
+	AccessKey            string            `koanf:"access-key"`
+	Bucket               string            `koanf:"bucket"`
+	ObjectPrefix         string            `koanf:"object-prefix"`
+	ShardPrefixLength    int               `koanf:"shard-prefix-length"`
+	Region               string            `koanf:"region"`
+	SecretKey            string            `koanf:"secret-key"`
+	Endpoint             string            `koanf:"endpoint"`
+	UsePathStyle         bool              `koanf:"use-path-style"`
+	ServerSideEncryption string            `koanf:"server-side-encryption"`
+	KMSKeyId             string            `koanf:"kms-key-id"`
+	DiscardAfterTimeout  bool              `koanf:"discard-after-timeout"`
+	Lifecycle            S3LifecycleConfig `koanf:"lifecycle"`
+	Batch                S3BatchConfig     `koanf:"batch"`
 }
 
-var DefaultS3StorageServiceConfig = S3StorageServiceConfig{}
+// S3LifecycleConfig installs rules in the bucket's lifecycle configuration expiring the objects stored with
+// discard-after-timeout, so S3 deletes them rather than the server having to. S3 can only expire objects a
+// number of days after they're stored, so each object is tagged with a bucket of days covering its timeout and
+// the safety window, and there's a rule for each bucket.
+type S3LifecycleConfig struct {
+	Enable     bool `koanf:"enable"`
+	SafetyDays int  `koanf:"safety-days"`
+}
+
+// S3BatchConfig groups stores made at about the same time, uploading each group concurrently once it's full or
+// the delay has passed since its first store.
+type S3BatchConfig struct {
+	MaxSize int           `koanf:"max-size"`
+	Delay   time.Duration `koanf:"delay"`
+}
+
+var DefaultS3StorageServiceConfig = S3StorageServiceConfig{
+	Lifecycle: DefaultS3LifecycleConfig,
+	Batch:     DefaultS3BatchConfig,
+}
+
+var DefaultS3LifecycleConfig = S3LifecycleConfig{
+	Enable:     false,
+	SafetyDays: 7,
+}
+
+var DefaultS3BatchConfig = S3BatchConfig{
+	MaxSize: 16,
+	Delay:   0,
+}
 
 func S3ConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultS3StorageServiceConfig.Enable, "enable storage/retrieval of sequencer batch data from an AWS S3 bucket")
 	f.String(prefix+".access-key", DefaultS3StorageServiceConfig.AccessKey, "S3 access key")
 	f.String(prefix+".bucket", DefaultS3StorageServiceConfig.Bucket, "S3 bucket")
 	f.String(prefix+".object-prefix", DefaultS3StorageServiceConfig.ObjectPrefix, "prefix to add to S3 objects")
+	f.Int(prefix+".shard-prefix-length", DefaultS3StorageServiceConfig.ShardPrefixLength, "number of hex characters of each object's hash to put in a directory before it, spreading objects over prefixes (0 for none)")
 	f.String(prefix+".region", DefaultS3StorageServiceConfig.Region, "S3 region")
 	f.String(prefix+".secret-key", DefaultS3StorageServiceConfig.SecretKey, "S3 secret key")
+	f.String(prefix+".endpoint", DefaultS3StorageServiceConfig.Endpoint, "URL of an S3 compatible object store, such as MinIO, to use instead of AWS")
+	f.Bool(prefix+".use-path-style", DefaultS3StorageServiceConfig.UsePathStyle, "address the bucket in the URL path rather than the host name, as most S3 compatible stores require")
+	f.String(prefix+".server-side-encryption", DefaultS3StorageServiceConfig.ServerSideEncryption, "server-side encryption of stored objects (\"AES256\" or \"aws:kms\", empty for the bucket's default)")
+	f.String(prefix+".kms-key-id", DefaultS3StorageServiceConfig.KMSKeyId, "KMS key to encrypt stored objects with, for aws:kms server-side encryption")
 	f.Bool(prefix+".discard-after-timeout", DefaultS3StorageServiceConfig.DiscardAfterTimeout, "discard data after its expiry timeout")
+	S3LifecycleConfigAddOptions(prefix+".lifecycle", f)
+	S3BatchConfigAddOptions(prefix+".batch", f)
+}
+
+func S3LifecycleConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultS3LifecycleConfig.Enable, "install bucket lifecycle rules expiring data stored with discard-after-timeout once its timeout has passed")
+	f.Int(prefix+".safety-days", DefaultS3LifecycleConfig.SafetyDays, "days to keep data after its timeout before the lifecycle rules expire it")
+}
+
+func S3BatchConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Int(prefix+".max-size", DefaultS3BatchConfig.MaxSize, "most stores uploaded together")
+	f.Duration(prefix+".delay", DefaultS3BatchConfig.Delay, "how long a store waits for others to upload with (0 to upload each as it's stored)")
+}
+
+// Objects stored with discard-after-timeout are tagged with how many days after they're stored they may be
+// expired, rounded up to a power of two so a few rules cover them all. Data which must be kept longer than the
+// last bucket isn't tagged, and is kept.
+const (
+	s3ExpiryDaysTagKey      = "nitro-das-expiry-days"
+	s3LifecycleRuleIdPrefix = "nitro-das-expiry"
+	s3MaxExpiryDays         = 1024
+)
+
+// s3ExpiryDays returns the bucket of days after now by which the data with the timeout and safety window can be
+// expired, or false if it must be kept.
+func s3ExpiryDays(timeout uint64, safetyDays int, now time.Time) (int, bool) {
+	if timeout == 0 || timeout > uint64(math.MaxInt64) {
+		return 0, false
+	}
+	remaining := time.Unix(int64(timeout), 0).Sub(now)
+	needed := safetyDays
+	if remaining > 0 {
+		needed += int((remaining + 24*time.Hour - 1) / (24 * time.Hour))
+	}
+	for days := 1; days <= s3MaxExpiryDays; days *= 2 {
+		if days >= needed {
+			return days, true
+		}
+	}
+	return 0, false
+}
+
+type s3PendingPut struct {
+	input *s3.PutObjectInput
+	done  chan error
 }
 
 type S3StorageService struct {
-	client              *s3.Client
-	bucket              string
-	objectPrefix        string
-	uploader            S3Uploader
-	downloader          S3Downloader
-	discardAfterTimeout bool
+	client               *s3.Client
+	bucket               string
+	objectPrefix         string
+	shardPrefixLength    int
+	uploader             S3Uploader
+	downloader           S3Downloader
+	discardAfterTimeout  bool
+	safetyDays           int
+	serverSideEncryption types.ServerSideEncryption
+	kmsKeyId             string
+
+	batchConfig S3BatchConfig
+	batchMutex  sync.Mutex
+	batch       []*s3PendingPut // waiting to be uploaded
+	batchTimer  *time.Timer     // uploads the batch once the delay has passed, nil if it's empty
 }
 
-func NewS3StorageService(config S3StorageServiceConfig) (StorageService, error) {
+func NewS3StorageService(ctx context.Context, config S3StorageServiceConfig) (StorageService, error) {
+	switch types.ServerSideEncryption(config.ServerSideEncryption) {
+	case "", types.ServerSideEncryptionAes256, types.ServerSideEncryptionAwsKms:
+	default:
+		return nil, fmt.Errorf("unknown S3 server-side encryption %q", config.ServerSideEncryption)
+	}
 	credCache := aws.NewCredentialsCache(
 		credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, ""),
 	)
-	client := s3.New(s3.Options{
-		Region:      config.Region,
-		Credentials: credCache,
+	options := s3.Options{
+		Region:       config.Region,
+		Credentials:  credCache,
+		UsePathStyle: config.UsePathStyle,
+	}
+	if config.Endpoint != "" {
+		options.EndpointResolver = s3.EndpointResolverFromURL(config.Endpoint)
+	}
+	client := s3.New(options)
+	s3s := &S3StorageService{
+		client:               client,
+		bucket:               config.Bucket,
+		objectPrefix:         config.ObjectPrefix,
+		shardPrefixLength:    config.ShardPrefixLength,
+		uploader:             manager.NewUploader(client),
+		downloader:           manager.NewDownloader(client),
+		discardAfterTimeout:  config.DiscardAfterTimeout,
+		safetyDays:           config.Lifecycle.SafetyDays,
+		serverSideEncryption: types.ServerSideEncryption(config.ServerSideEncryption),
+		kmsKeyId:             config.KMSKeyId,
+		batchConfig:          config.Batch,
+	}
+	if config.Lifecycle.Enable {
+		if err := s3s.installLifecycleRules(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s3s, nil
+}
+
+func (s3s *S3StorageService) objectKey(key common.Hash) string {
+	encoded := EncodeStorageServiceKey(key)
+	if s3s.shardPrefixLength <= 0 {
+		return s3s.objectPrefix + encoded
+	}
+	hexKey := strings.TrimPrefix(encoded, "0x")
+	shard := hexKey
+	if s3s.shardPrefixLength < len(hexKey) {
+		shard = hexKey[:s3s.shardPrefixLength]
+	}
+	return s3s.objectPrefix + shard + "/" + encoded
+}
+
+// installLifecycleRules adds the rules expiring the objects tagged with expiry days under the object prefix to the
+// bucket's lifecycle configuration, keeping its other rules.
+func (s3s *S3StorageService) installLifecycleRules(ctx context.Context) error {
+	var rules []types.LifecycleRule
+	existing, err := s3s.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(s3s.bucket)})
+	if err == nil {
+		rules = existing.Rules
+	} else {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchLifecycleConfiguration" {
+			return err
+		}
+	}
+	_, err = s3s.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s3s.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
+			Rules: withDASLifecycleRules(rules, s3s.objectPrefix),
+		},
 	})
-	return &S3StorageService{
-		client:              client,
-		bucket:              config.Bucket,
-		objectPrefix:        config.ObjectPrefix,
-		uploader:            manager.NewUploader(client),
-		downloader:          manager.NewDownloader(client),
-		discardAfterTimeout: config.DiscardAfterTimeout,
-	}, nil
+	if err != nil {
+		return err
+	}
+	log.Info("installed DAS expiry lifecycle rules", "bucket", s3s.bucket, "prefix", s3s.objectPrefix, "safetyDays", s3s.safetyDays)
+	return nil
+}
+
+// withDASLifecycleRules returns the rules with the DAS expiry rules for the prefix added, replacing any present.
+func withDASLifecycleRules(rules []types.LifecycleRule, prefix string) []types.LifecycleRule {
+	id := s3LifecycleRuleIdPrefix
+	if prefix != "" {
+		id += ":" + prefix
+	}
+	updated := make([]types.LifecycleRule, 0, len(rules))
+	for _, existing := range rules {
+		if existing.ID == nil || (*existing.ID != id && !strings.HasPrefix(*existing.ID, id+"/")) {
+			updated = append(updated, existing)
+		}
+	}
+	for days := 1; days <= s3MaxExpiryDays; days *= 2 {
+		value := strconv.Itoa(days)
+		updated = append(updated, types.LifecycleRule{
+			ID:     aws.String(id + "/" + value),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilterMemberAnd{Value: types.LifecycleRuleAndOperator{
+				Prefix: aws.String(prefix),
+				Tags:   []types.Tag{{Key: aws.String(s3ExpiryDaysTagKey), Value: aws.String(value)}},
+			}},
+			Expiration: &types.LifecycleExpiration{Days: int32(days)},
+		})
+	}
+	return updated
 }
 
 func (s3s *S3StorageService) GetByHash(ctx context.Context, key common.Hash) ([]byte, error) {
@@ -87,7 +276,7 @@ func (s3s *S3StorageService) GetByHash(ctx context.Context, key common.Hash) ([]
 	buf := manager.NewWriteAtBuffer([]byte{})
 	_, err := s3s.downloader.Download(ctx, buf, &s3.GetObjectInput{
 		Bucket: aws.String(s3s.bucket),
-		Key:    aws.String(s3s.objectPrefix + EncodeStorageServiceKey(key)),
+		Key:    aws.String(s3s.objectKey(key)),
 	})
 	return buf.Bytes(), err
 }
@@ -95,20 +284,94 @@ func (s3s *S3StorageService) GetByHash(ctx context.Context, key common.Hash) ([]
 func (s3s *S3StorageService) Put(ctx context.Context, value []byte, timeout uint64) error {
 	logPut("das.S3StorageService.Store", value, timeout, s3s)
 	putObjectInput := s3.PutObjectInput{
-		Bucket: aws.String(s3s.bucket),
-		Key:    aws.String(s3s.objectPrefix + EncodeStorageServiceKey(dastree.Hash(value))),
-		Body:   bytes.NewReader(value)}
+		Bucket:               aws.String(s3s.bucket),
+		Key:                  aws.String(s3s.objectKey(dastree.Hash(value))),
+		Body:                 bytes.NewReader(value),
+		ServerSideEncryption: s3s.serverSideEncryption,
+	}
+	if s3s.kmsKeyId != "" {
+		putObjectInput.SSEKMSKeyId = aws.String(s3s.kmsKeyId)
+	}
 	if !s3s.discardAfterTimeout {
 		expires := time.Unix(int64(timeout), 0)
 		putObjectInput.Expires = &expires
+	} else if days, ok := s3ExpiryDays(timeout, s3s.safetyDays, time.Now()); ok {
+		putObjectInput.Tagging = aws.String(s3ExpiryDaysTagKey + "=" + strconv.Itoa(days))
+	}
+	var err error
+	if s3s.batchConfig.Delay > 0 && s3s.batchConfig.MaxSize > 1 {
+		err = s3s.batchedUpload(ctx, &putObjectInput)
+	} else {
+		_, err = s3s.uploader.Upload(ctx, &putObjectInput)
 	}
-	_, err := s3s.uploader.Upload(ctx, &putObjectInput)
 	if err != nil {
 		log.Error("das.S3StorageService.Store", "err", err)
 	}
 	return err
 }
 
+// batchedUpload adds the object to the batch being gathered, and waits for the batch to be uploaded.
+func (s3s *S3StorageService) batchedUpload(ctx context.Context, input *s3.PutObjectInput) error {
+	pending := &s3PendingPut{input: input, done: make(chan error, 1)}
+	s3s.batchMutex.Lock()
+	s3s.batch = append(s3s.batch, pending)
+	if len(s3s.batch) >= s3s.batchConfig.MaxSize {
+		batch := s3s.takeBatch()
+		s3s.batchMutex.Unlock()
+		go s3s.uploadBatch(batch)
+	} else {
+		if s3s.batchTimer == nil {
+			s3s.batchTimer = time.AfterFunc(s3s.batchConfig.Delay, func() {
+				s3s.batchMutex.Lock()
+				batch := s3s.takeBatch()
+				s3s.batchMutex.Unlock()
+				s3s.uploadBatch(batch)
+			})
+		}
+		s3s.batchMutex.Unlock()
+	}
+	select {
+	case err := <-pending.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// takeBatch returns the batch gathered, starting the next. The batch mutex must be held.
+func (s3s *S3StorageService) takeBatch() []*s3PendingPut {
+	batch := s3s.batch
+	s3s.batch = nil
+	if s3s.batchTimer != nil {
+		s3s.batchTimer.Stop()
+		s3s.batchTimer = nil
+	}
+	return batch
+}
+
+// uploadBatch uploads the objects concurrently, reporting each upload's result to its store. The uploads aren't
+// tied to any one store's context, as the others still need them.
+func (s3s *S3StorageService) uploadBatch(batch []*s3PendingPut) {
+	var wg sync.WaitGroup
+	for _, pending := range batch {
+		wg.Add(1)
+		go func(pending *s3PendingPut) {
+			defer wg.Done()
+			_, err := s3s.uploader.Upload(context.Background(), pending.input)
+			pending.done <- err
+		}(pending)
+	}
+	wg.Wait()
+}
+
+func (s3s *S3StorageService) Delete(ctx context.Context, key common.Hash) error {
+	_, err := s3s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s3s.bucket),
+		Key:    aws.String(s3s.objectKey(key)),
+	})
+	return err
+}
+
 func (s3s *S3StorageService) Sync(ctx context.Context) error {
 	return nil
 }
@@ -8,11 +8,16 @@ import (
 	"context"
 	"errors"
 	"io"
+	"math"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"github.com/offchainlabs/nitro/cmd/genericconf"
 	"github.com/offchainlabs/nitro/das/dastree"
@@ -90,3 +95,105 @@ func TestS3StorageService(t *testing.T) {
 		t.Fatal(val, val1)
 	}
 }
+
+type capturingS3Uploader struct {
+	mutex  sync.Mutex
+	inputs []*s3.PutObjectInput
+}
+
+func (u *capturingS3Uploader) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.inputs = append(u.inputs, input)
+	return &manager.UploadOutput{}, nil
+}
+
+func TestS3StorageServiceObjects(t *testing.T) {
+	ctx := context.Background()
+	uploader := &capturingS3Uploader{}
+	s3Service := &S3StorageService{
+		bucket:               "bucket",
+		objectPrefix:         "das/",
+		shardPrefixLength:    2,
+		uploader:             uploader,
+		discardAfterTimeout:  true,
+		safetyDays:           7,
+		serverSideEncryption: types.ServerSideEncryptionAwsKms,
+		kmsKeyId:             "key",
+		batchConfig:          S3BatchConfig{MaxSize: 4, Delay: 10 * time.Millisecond},
+	}
+
+	values := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+	var wg sync.WaitGroup
+	for _, value := range values {
+		wg.Add(1)
+		go func(value []byte) {
+			defer wg.Done()
+			Require(t, s3Service.Put(ctx, value, uint64(time.Now().Add(3*24*time.Hour).Unix())))
+		}(value)
+	}
+	wg.Wait()
+
+	if len(uploader.inputs) != len(values) {
+		t.Fatal("uploaded", len(uploader.inputs), "objects, expected", len(values))
+	}
+	keys := make(map[string]bool)
+	for _, value := range values {
+		encoded := EncodeStorageServiceKey(dastree.Hash(value))
+		keys["das/"+encoded[2:4]+"/"+encoded] = true
+	}
+	for _, input := range uploader.inputs {
+		if !keys[*input.Key] {
+			t.Fatal("unexpected object key", *input.Key)
+		}
+		if input.ServerSideEncryption != types.ServerSideEncryptionAwsKms || input.SSEKMSKeyId == nil || *input.SSEKMSKeyId != "key" {
+			t.Fatal("object", *input.Key, "not encrypted with the KMS key")
+		}
+		// 3 days to its timeout and 7 more to keep it, rounded up
+		if input.Tagging == nil || *input.Tagging != s3ExpiryDaysTagKey+"=16" {
+			t.Fatal("object", *input.Key, "not tagged with its expiry", input.Tagging)
+		}
+	}
+}
+
+func TestS3ExpiryDays(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	for _, test := range []struct {
+		timeout uint64
+		days    int
+		ok      bool
+	}{
+		{0, 0, false},
+		{math.MaxUint64, 0, false},
+		{uint64(now.Add(-day).Unix()), 8, true},
+		{uint64(now.Add(day).Unix()), 8, true},
+		{uint64(now.Add(2 * day).Unix()), 16, true},
+		{uint64(now.Add(2000 * day).Unix()), 0, false},
+	} {
+		days, ok := s3ExpiryDays(test.timeout, 7, now)
+		if days != test.days || ok != test.ok {
+			t.Fatal("timeout", test.timeout, "expires after", days, ok, "expected", test.days, test.ok)
+		}
+	}
+}
+
+func TestS3LifecycleRules(t *testing.T) {
+	other := types.LifecycleRule{ID: aws.String("other"), Status: types.ExpirationStatusEnabled}
+	old := types.LifecycleRule{ID: aws.String(s3LifecycleRuleIdPrefix + ":das/"), Status: types.ExpirationStatusEnabled}
+	rules := withDASLifecycleRules([]types.LifecycleRule{other, old}, "das/")
+	rules = withDASLifecycleRules(rules, "das/")
+	if len(rules) != 12 {
+		t.Fatal("have", len(rules), "lifecycle rules, expected 12")
+	}
+	if *rules[0].ID != "other" {
+		t.Fatal("replaced the bucket's other rule")
+	}
+	for i, rule := range rules[1:] {
+		days := int32(1) << i
+		tags := rule.Filter.(*types.LifecycleRuleFilterMemberAnd).Value.Tags
+		if rule.Expiration.Days != days || len(tags) != 1 || *tags[0].Value != strconv.Itoa(int(days)) {
+			t.Fatal("rule", *rule.ID, "expires after", rule.Expiration.Days, "days, expected", days)
+		}
+	}
+}
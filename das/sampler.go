@@ -0,0 +1,272 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+	"github.com/offchainlabs/nitro/util/headerreader"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+	flag "github.com/spf13/pflag"
+)
+
+type SamplerConfig struct {
+	Enable           bool          `koanf:"enable"`
+	Members          string        `koanf:"members"`
+	Interval         time.Duration `koanf:"interval"`
+	LookbackBlocks   uint64        `koanf:"lookback-blocks"`
+	BatchesPerSample int           `koanf:"batches-per-sample"`
+	RequestTimeout   time.Duration `koanf:"request-timeout"`
+	WebhookURL       string        `koanf:"webhook-url"`
+}
+
+var DefaultSamplerConfig = SamplerConfig{
+	Interval:         time.Minute,
+	LookbackBlocks:   300,
+	BatchesPerSample: 2,
+	RequestTimeout:   10 * time.Second,
+}
+
+func SamplerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultSamplerConfig.Enable, "periodically check that committee members serve the data of randomly sampled recent certificates they signed, alerting when they don't")
+	f.String(prefix+".members", DefaultSamplerConfig.Members, "JSON list of the committee members' REST endpoints, each with the url and the signermask of its key in the keyset")
+	f.Duration(prefix+".interval", DefaultSamplerConfig.Interval, "how often to sample")
+	f.Uint64(prefix+".lookback-blocks", DefaultSamplerConfig.LookbackBlocks, "number of recent L1 blocks to sample certificates from")
+	f.Int(prefix+".batches-per-sample", DefaultSamplerConfig.BatchesPerSample, "number of certificates to check each time")
+	f.Duration(prefix+".request-timeout", DefaultSamplerConfig.RequestTimeout, "timeout of requests for data to members")
+	f.String(prefix+".webhook-url", DefaultSamplerConfig.WebhookURL, "URL to POST a JSON alert to when a member fails to serve data it signed for (optional)")
+}
+
+// SamplerMemberConfig is a committee member in SamplerConfig.Members.
+type SamplerMemberConfig struct {
+	URL        string `json:"url"`
+	SignerMask uint64 `json:"signermask"`
+}
+
+// SamplerAlert is what's sent to the webhook when a member fails to serve data it signed for.
+type SamplerAlert struct {
+	Member     string      `json:"member"`
+	SignerMask uint64      `json:"signerMask"`
+	DataHash   common.Hash `json:"dataHash"`
+	KeysetHash common.Hash `json:"keysetHash"`
+	Timeout    uint64      `json:"timeout"`
+	Error      string      `json:"error"`
+}
+
+type samplerMember struct {
+	url         string
+	signersMask uint64
+	reader      arbstate.DataAvailabilityReader
+
+	successCounter metrics.Counter
+	failureCounter metrics.Counter
+}
+
+// Sampler verifies the committee keeps data available without downloading everything it stores, by randomly
+// picking certificates posted to L1 recently and fetching their data from each member that signed them. The REST
+// API serves whole batches by hash, so each sampled certificate's data is fetched in full and checked against it.
+type Sampler struct {
+	stopwaiter.StopWaiter
+	config        SamplerConfig
+	members       []*samplerMember
+	l1Reader      *headerreader.HeaderReader
+	inboxContract *bridgegen.SequencerInbox
+	inboxAddr     common.Address
+	webhook       *http.Client
+}
+
+func NewSampler(config *SamplerConfig, l1Reader *headerreader.HeaderReader, inboxAddr common.Address) (*Sampler, error) {
+	var memberConfigs []SamplerMemberConfig
+	if err := json.Unmarshal([]byte(config.Members), &memberConfigs); err != nil {
+		return nil, fmt.Errorf("sampler.members was invalid: %w", err)
+	}
+	if len(memberConfigs) == 0 {
+		return nil, errors.New("sampler.members must list at least one member")
+	}
+	if config.BatchesPerSample <= 0 {
+		return nil, errors.New("sampler.batches-per-sample must be positive")
+	}
+	var members []*samplerMember
+	for _, m := range memberConfigs {
+		if bits.OnesCount64(m.SignerMask) != 1 {
+			return nil, fmt.Errorf("sampler member %v has invalid signermask %X", m.URL, m.SignerMask)
+		}
+		reader, err := NewRestfulDasClientFromURL(m.URL)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, newSamplerMember(m, reader))
+	}
+	inboxContract, err := bridgegen.NewSequencerInbox(inboxAddr, l1Reader.Client())
+	if err != nil {
+		return nil, err
+	}
+	return &Sampler{
+		config:        *config,
+		members:       members,
+		l1Reader:      l1Reader,
+		inboxContract: inboxContract,
+		inboxAddr:     inboxAddr,
+		webhook:       &http.Client{Timeout: config.RequestTimeout},
+	}, nil
+}
+
+func newSamplerMember(config SamplerMemberConfig, reader arbstate.DataAvailabilityReader) *samplerMember {
+	prefix := fmt.Sprintf("arb/das/sampler/member/%d/", bits.TrailingZeros64(config.SignerMask))
+	return &samplerMember{
+		url:            config.URL,
+		signersMask:    config.SignerMask,
+		reader:         reader,
+		successCounter: metrics.GetOrRegisterCounter(prefix+"success", nil),
+		failureCounter: metrics.GetOrRegisterCounter(prefix+"failure", nil),
+	}
+}
+
+func (s *Sampler) Start(ctxIn context.Context) {
+	s.StopWaiter.Start(ctxIn)
+	s.CallIteratively(func(ctx context.Context) time.Duration {
+		if err := s.sample(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("failed to sample DAS certificates", "err", err)
+		}
+		return s.config.Interval
+	})
+}
+
+// sample picks certificates from recently delivered batches and checks them.
+func (s *Sampler) sample(ctx context.Context) error {
+	header, err := s.l1Reader.LastHeader(ctx)
+	if err != nil {
+		return err
+	}
+	head := header.Number.Uint64()
+	from := uint64(0)
+	if head > s.config.LookbackBlocks {
+		from = head - s.config.LookbackBlocks
+	}
+	logs, err := s.l1Reader.Client().FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(head),
+		Addresses: []common.Address{s.inboxAddr},
+		Topics:    [][]common.Hash{{batchDeliveredID}},
+	})
+	if err != nil {
+		return err
+	}
+	rand.Shuffle(len(logs), func(i, j int) { logs[i], logs[j] = logs[j], logs[i] })
+
+	sampled := 0
+	for _, deliveredLog := range logs {
+		if sampled >= s.config.BatchesPerSample {
+			break
+		}
+		deliveredEvent, err := s.inboxContract.ParseSequencerBatchDelivered(deliveredLog)
+		if err != nil {
+			return err
+		}
+		data, err := sequencerBatchData(ctx, s.l1Reader.Client(), s.inboxContract, s.inboxAddr, deliveredLog, deliveredEvent)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 || !arbstate.IsDASMessageHeaderByte(data[0]) {
+			continue
+		}
+		cert, err := arbstate.DeserializeDASCertFrom(bytes.NewReader(data))
+		if err != nil {
+			log.Warn("failed to deserialize sampled DAS certificate", "txhash", deliveredLog.TxHash, "err", err)
+			continue
+		}
+		if cert.Timeout < uint64(time.Now().Unix()) {
+			// members may have discarded it
+			continue
+		}
+		s.checkCertificate(ctx, cert)
+		sampled++
+	}
+	return nil
+}
+
+// checkCertificate fetches the certificate's data from each member that signed it, alerting on those that don't
+// serve it. It returns how many failed to.
+func (s *Sampler) checkCertificate(ctx context.Context, cert *arbstate.DataAvailabilityCertificate) int {
+	failures := 0
+	for _, member := range s.members {
+		if cert.SignersMask&member.signersMask == 0 {
+			continue
+		}
+		fetchCtx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+		_, err := member.reader.GetByHash(fetchCtx, cert.DataHash)
+		cancel()
+		if ctx.Err() != nil {
+			return failures
+		}
+		if err == nil {
+			member.successCounter.Inc(1)
+			continue
+		}
+		failures++
+		member.failureCounter.Inc(1)
+		log.Error("DAS committee member failed to serve data it signed for", "member", member.url, "signerMask", member.signersMask, "dataHash", common.Hash(cert.DataHash), "err", err)
+		if s.config.WebhookURL != "" {
+			alert := &SamplerAlert{
+				Member:     member.url,
+				SignerMask: member.signersMask,
+				DataHash:   cert.DataHash,
+				KeysetHash: cert.KeysetHash,
+				Timeout:    cert.Timeout,
+				Error:      err.Error(),
+			}
+			if err := s.sendAlert(ctx, alert); err != nil {
+				log.Warn("failed to send DAS sampler alert", "url", s.config.WebhookURL, "err", err)
+			}
+		}
+	}
+	return failures
+}
+
+func (s *Sampler) sendAlert(ctx context.Context, alert *SamplerAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := s.webhook.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("webhook returned status %v: %v", response.StatusCode, string(responseBody))
+	}
+	return nil
+}
+
+func (s *Sampler) Close(ctx context.Context) error {
+	s.StopAndWait()
+	return nil
+}
+
+func (s *Sampler) String() string {
+	return fmt.Sprintf("Sampler(%d members)", len(s.members))
+}
@@ -0,0 +1,66 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das/dastree"
+)
+
+func TestSamplerCheckCertificate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := []byte("data the committee signed for")
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	honest := NewMemoryBackedStorageService(ctx)
+	Require(t, honest.Put(ctx, data, timeout))
+	withholding := NewMemoryBackedStorageService(ctx)
+
+	var alertsMutex sync.Mutex
+	var alerts []SamplerAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert SamplerAlert
+		Require(t, json.NewDecoder(r.Body).Decode(&alert))
+		alertsMutex.Lock()
+		alerts = append(alerts, alert)
+		alertsMutex.Unlock()
+	}))
+	defer webhook.Close()
+
+	config := DefaultSamplerConfig
+	config.WebhookURL = webhook.URL
+	sampler := &Sampler{
+		config: config,
+		members: []*samplerMember{
+			newSamplerMember(SamplerMemberConfig{URL: "honest", SignerMask: 1}, honest),
+			newSamplerMember(SamplerMemberConfig{URL: "withholding", SignerMask: 2}, withholding),
+			newSamplerMember(SamplerMemberConfig{URL: "not signing", SignerMask: 4}, withholding),
+		},
+		webhook: &http.Client{Timeout: time.Second},
+	}
+
+	cert := &arbstate.DataAvailabilityCertificate{
+		DataHash:    dastree.Hash(data),
+		Timeout:     timeout,
+		SignersMask: 3,
+	}
+	if failures := sampler.checkCertificate(ctx, cert); failures != 1 {
+		Fail(t, "got", failures, "failures, expected 1")
+	}
+	if len(alerts) != 1 || alerts[0].Member != "withholding" || alerts[0].DataHash != cert.DataHash {
+		Fail(t, "unexpected alerts", alerts)
+	}
+	if sampler.members[0].successCounter.Count() != 1 || sampler.members[1].failureCounter.Count() != 1 {
+		Fail(t, "member metrics not updated")
+	}
+}
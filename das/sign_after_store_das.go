@@ -4,13 +4,14 @@
 package das
 
 import (
-	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -29,8 +30,9 @@ import (
 var ErrDasKeysetNotFound = errors.New("no such keyset")
 
 type KeyConfig struct {
-	KeyDir  string `koanf:"key-dir"`
-	PrivKey string `koanf:"priv-key"`
+	KeyDir   string `koanf:"key-dir"`
+	PrivKey  string `koanf:"priv-key"`
+	Rotation string `koanf:"rotation"`
 }
 
 var DefaultKeyConfig = KeyConfig{}
@@ -38,6 +40,60 @@ var DefaultKeyConfig = KeyConfig{}
 func KeyConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.String(prefix+".key-dir", DefaultKeyConfig.KeyDir, fmt.Sprintf("the directory to read the bls keypair ('%s' and '%s') from; if using any of the DAS storage types exactly one of key-dir or priv-key must be specified", DefaultPubKeyFilename, DefaultPrivKeyFilename))
 	f.String(prefix+".priv-key", DefaultKeyConfig.PrivKey, "the base64 BLS private key to use for signing DAS certificates; if using any of the DAS storage types exactly one of key-dir or priv-key must be specified")
+	f.String(prefix+".rotation", DefaultKeyConfig.Rotation, "JSON list of further BLS keys to sign with from L1 block heights, each with either key-dir or priv-key and an activation-height, for rotating keys without downtime")
+}
+
+// RotationKeyConfig is a key in KeyConfig.Rotation, signed with once L1 reaches its activation height.
+type RotationKeyConfig struct {
+	KeyDir           string `json:"key-dir"`
+	PrivKey          string `json:"priv-key"`
+	ActivationHeight uint64 `json:"activation-height"`
+}
+
+// readBLSPrivateKey reads the key given in base64, or else from the key directory.
+func readBLSPrivateKey(privKeyBase64 string, keyDir string) (*blsSignatures.PrivateKey, error) {
+	if len(privKeyBase64) != 0 {
+		privKey, err := DecodeBase64BLSPrivateKey([]byte(privKeyBase64))
+		if err != nil {
+			return nil, fmt.Errorf("'priv-key' was invalid: %w", err)
+		}
+		return privKey, nil
+	}
+	_, privKey, err := ReadKeysFromFile(keyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Required BLS keypair did not exist at %s", keyDir)
+		}
+		return nil, err
+	}
+	return privKey, nil
+}
+
+// signingKey is one of the keys a SignAfterStoreDAS signs with, with the single key keyset it certifies under.
+type signingKey struct {
+	activationHeight uint64
+	privKey          *blsSignatures.PrivateKey
+	keysetHash       [32]byte
+}
+
+func newSigningKey(privKey *blsSignatures.PrivateKey, activationHeight uint64) (*signingKey, error) {
+	publicKey, err := blsSignatures.PublicKeyFromPrivateKey(*privKey)
+	if err != nil {
+		return nil, err
+	}
+	keyset := &arbstate.DataAvailabilityKeyset{
+		AssumedHonest: 1,
+		PubKeys:       []blsSignatures.PublicKey{publicKey},
+	}
+	ksHash, err := keyset.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{
+		activationHeight: activationHeight,
+		privKey:          privKey,
+		keysetHash:       ksHash,
+	}, nil
 }
 
 // Provides DAS signature functionality over a StorageService by adapting
@@ -53,12 +109,13 @@ func KeyConfigAddOptions(prefix string, f *flag.FlagSet) {
 // signature is not checked, which is useful for testing.
 type SignAfterStoreDAS struct {
 	config         KeyConfig
-	privKey        *blsSignatures.PrivateKey
-	keysetHash     [32]byte
-	keysetBytes    []byte
+	keys           []*signingKey // by activation height, the first active from the start
 	storageService StorageService
 	bpVerifier     *BatchPosterVerifier
 
+	// Returns the current L1 block number, to pick the key to sign with when rotating keys.
+	l1BlockNumber func(ctx context.Context) (uint64, error)
+
 	// Extra batch poster verifier, for local installations to have their
 	// own way of testing Stores.
 	extraBpVerifier func(message []byte, timeout uint64, sig []byte) bool
@@ -84,7 +141,12 @@ func NewSignAfterStoreDAS(ctx context.Context, config DataAvailabilityConfig, st
 	if err != nil {
 		return nil, err
 	}
-	return NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, seqInboxCaller, storageService, config.ExtraSignatureCheckingPublicKey)
+	das, err := NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, seqInboxCaller, storageService, config.ExtraSignatureCheckingPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	das.SetL1BlockNumberSource(l1client.BlockNumber)
+	return das, nil
 }
 
 func NewSignAfterStoreDASWithSeqInboxCaller(
@@ -94,41 +156,35 @@ func NewSignAfterStoreDASWithSeqInboxCaller(
 	storageService StorageService,
 	extraSignatureCheckingPublicKey string,
 ) (*SignAfterStoreDAS, error) {
-	var privKey *blsSignatures.PrivateKey
-	var err error
-	if len(config.PrivKey) != 0 {
-		privKey, err = DecodeBase64BLSPrivateKey([]byte(config.PrivKey))
-		if err != nil {
-			return nil, fmt.Errorf("'priv-key' was invalid: %w", err)
-		}
-	} else {
-		_, privKey, err = ReadKeysFromFile(config.KeyDir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, fmt.Errorf("Required BLS keypair did not exist at %s", config.KeyDir)
-			} else {
-				return nil, err
-			}
-		}
-	}
-
-	publicKey, err := blsSignatures.PublicKeyFromPrivateKey(*privKey)
+	privKey, err := readBLSPrivateKey(config.PrivKey, config.KeyDir)
 	if err != nil {
 		return nil, err
 	}
-
-	keyset := &arbstate.DataAvailabilityKeyset{
-		AssumedHonest: 1,
-		PubKeys:       []blsSignatures.PublicKey{publicKey},
-	}
-	ksBuf := bytes.NewBuffer([]byte{})
-	if err := keyset.Serialize(ksBuf); err != nil {
-		return nil, err
-	}
-	ksHash, err := keyset.Hash()
+	key, err := newSigningKey(privKey, 0)
 	if err != nil {
 		return nil, err
 	}
+	keys := []*signingKey{key}
+	if config.Rotation != "" {
+		var rotation []RotationKeyConfig
+		if err := json.Unmarshal([]byte(config.Rotation), &rotation); err != nil {
+			return nil, fmt.Errorf("'rotation' was invalid: %w", err)
+		}
+		for _, rotationKey := range rotation {
+			privKey, err := readBLSPrivateKey(rotationKey.PrivKey, rotationKey.KeyDir)
+			if err != nil {
+				return nil, err
+			}
+			key, err := newSigningKey(privKey, rotationKey.ActivationHeight)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			return keys[i].activationHeight < keys[j].activationHeight
+		})
+	}
 
 	var bpVerifier *BatchPosterVerifier
 	if seqInboxCaller != nil {
@@ -164,9 +220,7 @@ func NewSignAfterStoreDASWithSeqInboxCaller(
 
 	return &SignAfterStoreDAS{
 		config:          config,
-		privKey:         privKey,
-		keysetHash:      ksHash,
-		keysetBytes:     ksBuf.Bytes(),
+		keys:            keys,
 		storageService:  storageService,
 		bpVerifier:      bpVerifier,
 		extraBpVerifier: extraBpVerifier,
@@ -196,6 +250,11 @@ func (d *SignAfterStoreDAS) Store(
 		}
 	}
 
+	key, err := d.signingKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	c = &arbstate.DataAvailabilityCertificate{
 		Timeout:     timeout,
 		DataHash:    dastree.Hash(message),
@@ -204,7 +263,7 @@ func (d *SignAfterStoreDAS) Store(
 	}
 
 	fields := c.SerializeSignableFields()
-	c.Sig, err = blsSignatures.SignMessage(*d.privKey, fields)
+	c.Sig, err = blsSignatures.SignMessage(*key.privKey, fields)
 	if err != nil {
 		return nil, err
 	}
@@ -218,11 +277,38 @@ func (d *SignAfterStoreDAS) Store(
 		return nil, err
 	}
 
-	c.KeysetHash = d.keysetHash
+	c.KeysetHash = key.keysetHash
 
 	return c, nil
 }
 
+// SetL1BlockNumberSource sets how to get the current L1 block number, which picks the key to sign with.
+func (d *SignAfterStoreDAS) SetL1BlockNumberSource(l1BlockNumber func(ctx context.Context) (uint64, error)) {
+	d.l1BlockNumber = l1BlockNumber
+}
+
+// signingKey returns the key with the latest activation height L1 has reached.
+func (d *SignAfterStoreDAS) signingKey(ctx context.Context) (*signingKey, error) {
+	if len(d.keys) == 1 {
+		return d.keys[0], nil
+	}
+	if d.l1BlockNumber == nil {
+		return nil, errors.New("key rotation requires an L1 connection to read the block height from")
+	}
+	height, err := d.l1BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := d.keys[0]
+	for _, next := range d.keys[1:] {
+		if next.activationHeight > height {
+			break
+		}
+		key = next
+	}
+	return key, nil
+}
+
 func (d *SignAfterStoreDAS) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
 	return d.storageService.GetByHash(ctx, hash)
 }
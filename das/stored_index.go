@@ -0,0 +1,183 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/offchainlabs/nitro/das/dastree"
+	"github.com/offchainlabs/nitro/util/arbmath"
+	flag "github.com/spf13/pflag"
+)
+
+type StoredIndexConfig struct {
+	Enable bool   `koanf:"enable"`
+	Dir    string `koanf:"dir"`
+}
+
+var DefaultStoredIndexConfig = StoredIndexConfig{}
+
+func StoredIndexConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultStoredIndexConfig.Enable, "index stored batch data by when it was stored, so the REST server can list it by time range and export it in bulk")
+	f.String(prefix+".dir", DefaultStoredIndexConfig.Dir, "directory of the database indexing stored data by when it was stored")
+}
+
+// StoredHashRecord is stored data's entry in the index.
+type StoredHashRecord struct {
+	Hash     common.Hash `json:"hash"`
+	StoredAt uint64      `json:"storedAt"`
+	Timeout  uint64      `json:"timeout"`
+}
+
+// HashLister lists the hashes of stored data by when it was stored.
+type HashLister interface {
+	// ListHashes lists up to limit records of data stored from the unix time from, up to but excluding to (0 for
+	// no bound), ordered by when stored then by hash. Passing the last record's hash as after, and its storedAt as
+	// from, continues the listing.
+	ListHashes(from, to uint64, after common.Hash, limit int) ([]StoredHashRecord, error)
+}
+
+// The index keeps records by when data was stored then its hash, and when each hash was stored.
+const (
+	storedIndexRecordPrefix = byte('s')
+	storedIndexHashPrefix   = byte('h')
+)
+
+func storedIndexRecordKey(storedAt uint64, hash common.Hash) []byte {
+	return append(append([]byte{storedIndexRecordPrefix}, arbmath.UintToBytes(storedAt)...), hash.Bytes()...)
+}
+
+func storedIndexHashKey(hash common.Hash) []byte {
+	return append([]byte{storedIndexHashPrefix}, hash.Bytes()...)
+}
+
+// StoredIndexStorageService indexes the data stored to the storage it wraps by when it was first stored.
+type StoredIndexStorageService struct {
+	StorageService
+	deleter Deleter // nil if the storage doesn't support deletion
+	index   *badger.DB
+}
+
+func NewStoredIndexStorageService(base StorageService, config StoredIndexConfig) (*StoredIndexStorageService, error) {
+	if config.Dir == "" {
+		return nil, errors.New("stored-index.dir must be specified")
+	}
+	index, err := badger.Open(badger.DefaultOptions(config.Dir))
+	if err != nil {
+		return nil, err
+	}
+	deleter, _ := base.(Deleter)
+	return &StoredIndexStorageService{
+		StorageService: base,
+		deleter:        deleter,
+		index:          index,
+	}, nil
+}
+
+func (s *StoredIndexStorageService) Put(ctx context.Context, data []byte, timeout uint64) error {
+	if err := s.StorageService.Put(ctx, data, timeout); err != nil {
+		return err
+	}
+	hash := dastree.Hash(data)
+	storedAt := uint64(time.Now().Unix())
+	return s.index.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(storedIndexHashKey(hash))
+		if err == nil {
+			// keep when it was first stored, only updating the timeout
+			err = item.Value(func(val []byte) error {
+				storedAt = binary.BigEndian.Uint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		if err := txn.Set(storedIndexHashKey(hash), arbmath.UintToBytes(storedAt)); err != nil {
+			return err
+		}
+		return txn.Set(storedIndexRecordKey(storedAt, hash), arbmath.UintToBytes(timeout))
+	})
+}
+
+func (s *StoredIndexStorageService) Delete(ctx context.Context, hash common.Hash) error {
+	if s.deleter == nil {
+		return fmt.Errorf("%v doesn't support deletion", s.StorageService)
+	}
+	if err := s.deleter.Delete(ctx, hash); err != nil {
+		return err
+	}
+	return s.index.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(storedIndexHashKey(hash))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var storedAt uint64
+		err = item.Value(func(val []byte) error {
+			storedAt = binary.BigEndian.Uint64(val)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(storedIndexRecordKey(storedAt, hash)); err != nil {
+			return err
+		}
+		return txn.Delete(storedIndexHashKey(hash))
+	})
+}
+
+func (s *StoredIndexStorageService) ListHashes(from, to uint64, after common.Hash, limit int) ([]StoredHashRecord, error) {
+	records := []StoredHashRecord{}
+	err := s.index.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte{storedIndexRecordPrefix}
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		start := storedIndexRecordKey(from, after)
+		for it.Seek(start); it.Valid() && len(records) < limit; it.Next() {
+			key := it.Item().Key()
+			if after != (common.Hash{}) && bytes.Equal(key, start) {
+				continue
+			}
+			record := StoredHashRecord{
+				StoredAt: binary.BigEndian.Uint64(key[1:9]),
+				Hash:     common.BytesToHash(key[9:]),
+			}
+			if to != 0 && record.StoredAt >= to {
+				break
+			}
+			err := it.Item().Value(func(val []byte) error {
+				record.Timeout = binary.BigEndian.Uint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Close leaves the storage it wraps open, as it's closed on its own.
+func (s *StoredIndexStorageService) Close(ctx context.Context) error {
+	return s.index.Close()
+}
+
+func (s *StoredIndexStorageService) String() string {
+	return fmt.Sprintf("StoredIndexStorageService(%v)", s.StorageService)
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
 	"github.com/offchainlabs/nitro/util/arbmath"
 	"github.com/offchainlabs/nitro/util/headerreader"
@@ -93,6 +94,9 @@ type l1SyncService struct {
 	lowBlockNr     uint64
 	lastBatchCount *big.Int
 	lastBatchAcc   common.Hash
+
+	// called with the L1 block to resume from after each successful read, if set
+	syncedTo func(block uint64)
 }
 
 func newl1SyncService(config *SyncToStorageConfig, syncTo StorageService, dataSource arbstate.DataAvailabilityReader, l1Reader *headerreader.HeaderReader, inboxAddr common.Address) (*l1SyncService, error) {
@@ -120,7 +124,6 @@ func newl1SyncService(config *SyncToStorageConfig, syncTo StorageService, dataSo
 }
 
 func (s *l1SyncService) processBatchDelivered(ctx context.Context, batchDeliveredLog types.Log) error {
-	data := []byte{}
 	deliveredEvent, err := s.inboxContract.ParseSequencerBatchDelivered(batchDeliveredLog)
 	if err != nil {
 		return err
@@ -131,39 +134,9 @@ func (s *l1SyncService) processBatchDelivered(ctx context.Context, batchDelivere
 		// old batch - no need to store
 		return nil
 	}
-	if deliveredEvent.DataLocation == uint8(batchDataSeparateEvent) {
-		query := ethereum.FilterQuery{
-			BlockHash: &batchDeliveredLog.BlockHash,
-			Addresses: []common.Address{s.inboxAddr},
-			Topics:    [][]common.Hash{{sequencerBatchDataABI.ID}, {common.BigToHash(deliveredEvent.BatchSequenceNumber)}},
-		}
-		logs, err := s.l1Reader.Client().FilterLogs(ctx, query)
-		if err != nil {
-			return err
-		}
-		if len(logs) != 1 {
-			return fmt.Errorf("found %d data logs for sequence 0x%x (expected 1)", len(logs), deliveredEvent.BatchSequenceNumber)
-		}
-		dataEvent, err := s.inboxContract.ParseSequencerBatchData(logs[0])
-		if err != nil {
-			return err
-		}
-		data = dataEvent.Data
-	} else if deliveredEvent.DataLocation == uint8(batchDataTxInput) {
-		tx, err := s.l1Reader.Client().TransactionInBlock(ctx, batchDeliveredLog.BlockHash, batchDeliveredLog.TxIndex)
-		if err != nil {
-			return err
-		}
-		args := make(map[string]interface{})
-		err = addSequencerL2BatchFromOriginCallABI.Inputs.UnpackIntoMap(args, tx.Data()[4:])
-		if err != nil {
-			return err
-		}
-		var ok bool
-		data, ok = args["data"].([]byte)
-		if !ok {
-			return fmt.Errorf("couldn't parse data for sequence 0x%x", deliveredEvent.BatchSequenceNumber)
-		}
+	data, err := sequencerBatchData(ctx, s.l1Reader.Client(), s.inboxContract, s.inboxAddr, batchDeliveredLog, deliveredEvent)
+	if err != nil {
+		return err
 	}
 	if len(data) < 1 {
 		// no data - nothing to do
@@ -210,6 +183,53 @@ func (s *l1SyncService) processBatchDelivered(ctx context.Context, batchDelivere
 	return nil
 }
 
+// sequencerBatchData returns the data of the batch delivered in the log, from wherever the batch poster put it.
+func sequencerBatchData(
+	ctx context.Context,
+	l1Client arbutil.L1Interface,
+	inboxContract *bridgegen.SequencerInbox,
+	inboxAddr common.Address,
+	deliveredLog types.Log,
+	deliveredEvent *bridgegen.SequencerInboxSequencerBatchDelivered,
+) ([]byte, error) {
+	data := []byte{}
+	if deliveredEvent.DataLocation == uint8(batchDataSeparateEvent) {
+		query := ethereum.FilterQuery{
+			BlockHash: &deliveredLog.BlockHash,
+			Addresses: []common.Address{inboxAddr},
+			Topics:    [][]common.Hash{{sequencerBatchDataABI.ID}, {common.BigToHash(deliveredEvent.BatchSequenceNumber)}},
+		}
+		logs, err := l1Client.FilterLogs(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if len(logs) != 1 {
+			return nil, fmt.Errorf("found %d data logs for sequence 0x%x (expected 1)", len(logs), deliveredEvent.BatchSequenceNumber)
+		}
+		dataEvent, err := inboxContract.ParseSequencerBatchData(logs[0])
+		if err != nil {
+			return nil, err
+		}
+		data = dataEvent.Data
+	} else if deliveredEvent.DataLocation == uint8(batchDataTxInput) {
+		tx, err := l1Client.TransactionInBlock(ctx, deliveredLog.BlockHash, deliveredLog.TxIndex)
+		if err != nil {
+			return nil, err
+		}
+		args := make(map[string]interface{})
+		err = addSequencerL2BatchFromOriginCallABI.Inputs.UnpackIntoMap(args, tx.Data()[4:])
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		data, ok = args["data"].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("couldn't parse data for sequence 0x%x", deliveredEvent.BatchSequenceNumber)
+		}
+	}
+	return data, nil
+}
+
 func (s *l1SyncService) processBlockRange(ctx context.Context, lowerBound, higherBound uint64) error {
 	query := ethereum.FilterQuery{
 		FromBlock: new(big.Int).SetUint64(lowerBound),
@@ -294,6 +314,9 @@ func (s *l1SyncService) mainThread(ctx context.Context) {
 			}
 			continue
 		}
+		if s.syncedTo != nil {
+			s.syncedTo(s.lowBlockNr)
+		}
 		if s.catchingUp {
 			// we're behind. Don't wait.
 			continue
@@ -0,0 +1,159 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package grpcfeed
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
+)
+
+func toFeedStream(stream Stream) (wsbroadcastserver.Stream, error) {
+	switch stream {
+	case Stream_STREAM_FULL:
+		return wsbroadcastserver.StreamFull, nil
+	case Stream_STREAM_HEADERS:
+		return wsbroadcastserver.StreamHeaders, nil
+	case Stream_STREAM_CONFIRMATIONS:
+		return wsbroadcastserver.StreamConfirmations, nil
+	}
+	return wsbroadcastserver.StreamFull, fmt.Errorf("unknown feed stream %v", stream)
+}
+
+// toProto converts a broadcast message into the feed messages for each of its parts.
+func toProto(msg broadcaster.BroadcastMessage) []*FeedMessage {
+	var feedMessages []*FeedMessage
+	if msg.BackfillMessage != nil {
+		feedMessages = append(feedMessages, &FeedMessage{Payload: &FeedMessage_Backfill{Backfill: &Backfill{
+			RequestedSequenceNumber: uint64(msg.BackfillMessage.RequestedSequenceNumber),
+			FirstSequenceNumber:     uint64(msg.BackfillMessage.FirstSequenceNumber),
+			ArchiveUrl:              msg.BackfillMessage.ArchiveURL,
+		}}})
+	}
+	if len(msg.Messages) > 0 {
+		messages := &Messages{}
+		for _, message := range msg.Messages {
+			if message != nil {
+				messages.Messages = append(messages.Messages, messageToProto(message))
+			}
+		}
+		feedMessages = append(feedMessages, &FeedMessage{Payload: &FeedMessage_Messages{Messages: messages}})
+	}
+	if msg.ConfirmedSequenceNumberMessage != nil {
+		feedMessages = append(feedMessages, &FeedMessage{Payload: &FeedMessage_Confirmation{Confirmation: &Confirmation{
+			SequenceNumber: uint64(msg.ConfirmedSequenceNumberMessage.SequenceNumber),
+		}}})
+	}
+	if msg.AdvisoryMessage != nil {
+		advisories := &Advisories{Timestamp: msg.AdvisoryMessage.Timestamp}
+		for _, advisory := range msg.AdvisoryMessage.Advisories {
+			advisories.Advisories = append(advisories.Advisories, &Advisory{
+				Kind:            advisory.Kind,
+				Severity:        advisory.Severity,
+				Message:         advisory.Message,
+				Deadline:        advisory.Deadline,
+				DeadlineL1Block: advisory.DeadlineL1Block,
+				Until:           advisory.Until,
+			})
+		}
+		feedMessages = append(feedMessages, &FeedMessage{Payload: &FeedMessage_Advisories{Advisories: advisories}})
+	}
+	return feedMessages
+}
+
+func messageToProto(message *broadcaster.BroadcastFeedMessage) *Message {
+	converted := &Message{
+		SequenceNumber:      uint64(message.SequenceNumber),
+		DelayedMessagesRead: message.Message.DelayedMessagesRead,
+		Signature:           message.Signature,
+	}
+	if l1Message := message.Message.Message; l1Message != nil {
+		converted.L2Msg = l1Message.L2msg
+		if header := l1Message.Header; header != nil {
+			converted.Header = &MessageHeader{
+				Kind:        uint32(header.Kind),
+				Poster:      header.Poster.Bytes(),
+				BlockNumber: header.BlockNumber,
+				Timestamp:   header.Timestamp,
+			}
+			if header.RequestId != nil {
+				converted.Header.RequestId = header.RequestId.Bytes()
+			}
+			if header.L1BaseFee != nil {
+				converted.Header.L1BaseFee = header.L1BaseFee.Bytes()
+			}
+		}
+	}
+	return converted
+}
+
+// FromProto converts a feed message back into a broadcast message, for Go subscribers.
+func FromProto(feedMessage *FeedMessage) broadcaster.BroadcastMessage {
+	msg := broadcaster.BroadcastMessage{Version: 1}
+	switch payload := feedMessage.Payload.(type) {
+	case *FeedMessage_Messages:
+		for _, message := range payload.Messages.Messages {
+			msg.Messages = append(msg.Messages, messageFromProto(message))
+		}
+	case *FeedMessage_Confirmation:
+		msg.ConfirmedSequenceNumberMessage = &broadcaster.ConfirmedSequenceNumberMessage{
+			SequenceNumber: arbutil.MessageIndex(payload.Confirmation.SequenceNumber),
+		}
+	case *FeedMessage_Advisories:
+		advisories := &broadcaster.AdvisoryMessage{
+			Timestamp:  payload.Advisories.Timestamp,
+			Advisories: []broadcaster.Advisory{},
+		}
+		for _, advisory := range payload.Advisories.Advisories {
+			advisories.Advisories = append(advisories.Advisories, broadcaster.Advisory{
+				Kind:            advisory.Kind,
+				Severity:        advisory.Severity,
+				Message:         advisory.Message,
+				Deadline:        advisory.Deadline,
+				DeadlineL1Block: advisory.DeadlineL1Block,
+				Until:           advisory.Until,
+			})
+		}
+		msg.AdvisoryMessage = advisories
+	case *FeedMessage_Backfill:
+		msg.BackfillMessage = &broadcaster.BackfillMessage{
+			RequestedSequenceNumber: arbutil.MessageIndex(payload.Backfill.RequestedSequenceNumber),
+			FirstSequenceNumber:     arbutil.MessageIndex(payload.Backfill.FirstSequenceNumber),
+			ArchiveURL:              payload.Backfill.ArchiveUrl,
+		}
+	}
+	return msg
+}
+
+func messageFromProto(message *Message) *broadcaster.BroadcastFeedMessage {
+	converted := &broadcaster.BroadcastFeedMessage{
+		SequenceNumber: arbutil.MessageIndex(message.SequenceNumber),
+		Message: arbstate.MessageWithMetadata{
+			DelayedMessagesRead: message.DelayedMessagesRead,
+		},
+		Signature: message.Signature,
+	}
+	if header := message.Header; header != nil {
+		l1Header := &arbos.L1IncomingMessageHeader{
+			Kind:        uint8(header.Kind),
+			Poster:      common.BytesToAddress(header.Poster),
+			BlockNumber: header.BlockNumber,
+			Timestamp:   header.Timestamp,
+			L1BaseFee:   new(big.Int).SetBytes(header.L1BaseFee),
+		}
+		if len(header.RequestId) > 0 {
+			requestId := common.BytesToHash(header.RequestId)
+			l1Header.RequestId = &requestId
+		}
+		converted.Message.Message = &arbos.L1IncomingMessage{Header: l1Header, L2msg: message.L2Msg}
+	}
+	return converted
+}
@@ -0,0 +1,73 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package grpcfeed
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+func TestFeedMessageRoundTrip(t *testing.T) {
+	requestId := common.HexToHash("0x1234")
+	message := &broadcaster.BroadcastFeedMessage{
+		SequenceNumber: 42,
+		Message: arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:        arbos.L1MessageType_L2Message,
+					Poster:      common.HexToAddress("0xa4b000000000000000000073657175656e636572"),
+					BlockNumber: 100,
+					Timestamp:   1650000000,
+					RequestId:   &requestId,
+					L1BaseFee:   big.NewInt(1_000_000_000),
+				},
+				L2msg: []byte{0xde, 0xad, 0xbe, 0xef},
+			},
+			DelayedMessagesRead: 3,
+		},
+		Signature: []byte{1, 2, 3},
+	}
+	feedMessages := toProto(broadcaster.BroadcastMessage{
+		Version:                        1,
+		Messages:                       []*broadcaster.BroadcastFeedMessage{message},
+		ConfirmedSequenceNumberMessage: &broadcaster.ConfirmedSequenceNumberMessage{SequenceNumber: 40},
+	})
+	if len(feedMessages) != 2 {
+		Fail(t, "expected a feed message for the messages and one for the confirmation, got", len(feedMessages))
+	}
+
+	converted := FromProto(feedMessages[0])
+	if len(converted.Messages) != 1 {
+		Fail(t, "expected one message, got", len(converted.Messages))
+	}
+	got := converted.Messages[0]
+	if got.SequenceNumber != message.SequenceNumber || got.Message.DelayedMessagesRead != message.Message.DelayedMessagesRead {
+		Fail(t, "unexpected message metadata", got)
+	}
+	header, gotHeader := message.Message.Message.Header, got.Message.Message.Header
+	if gotHeader.Kind != header.Kind || gotHeader.Poster != header.Poster || gotHeader.BlockNumber != header.BlockNumber || gotHeader.Timestamp != header.Timestamp {
+		Fail(t, "header changed by conversion", gotHeader)
+	}
+	if gotHeader.RequestId == nil || *gotHeader.RequestId != requestId || gotHeader.L1BaseFee.Cmp(header.L1BaseFee) != 0 {
+		Fail(t, "header changed by conversion", gotHeader)
+	}
+	if !bytes.Equal(got.Message.Message.L2msg, message.Message.Message.L2msg) {
+		Fail(t, "L2 message changed by conversion", got.Message.Message.L2msg)
+	}
+	if !bytes.Equal(got.Signature, message.Signature) {
+		Fail(t, "signature changed by conversion", got.Signature)
+	}
+
+	confirmation := FromProto(feedMessages[1]).ConfirmedSequenceNumberMessage
+	if confirmation == nil || confirmation.SequenceNumber != 40 {
+		Fail(t, "unexpected confirmation", confirmation)
+	}
+}
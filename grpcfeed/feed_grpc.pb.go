@@ -0,0 +1,132 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: feed.proto
+
+package grpcfeed
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SequencerFeedClient is the client API for SequencerFeed service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SequencerFeedClient interface {
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (SequencerFeed_SubscribeClient, error)
+}
+
+type sequencerFeedClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSequencerFeedClient(cc grpc.ClientConnInterface) SequencerFeedClient {
+	return &sequencerFeedClient{cc}
+}
+
+func (c *sequencerFeedClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (SequencerFeed_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SequencerFeed_ServiceDesc.Streams[0], "/arbitrum.feed.v1.SequencerFeed/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sequencerFeedSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SequencerFeed_SubscribeClient interface {
+	Recv() (*FeedMessage, error)
+	grpc.ClientStream
+}
+
+type sequencerFeedSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *sequencerFeedSubscribeClient) Recv() (*FeedMessage, error) {
+	m := new(FeedMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SequencerFeedServer is the server API for SequencerFeed service.
+// All implementations must embed UnimplementedSequencerFeedServer
+// for forward compatibility
+type SequencerFeedServer interface {
+	Subscribe(*SubscribeRequest, SequencerFeed_SubscribeServer) error
+	mustEmbedUnimplementedSequencerFeedServer()
+}
+
+// UnimplementedSequencerFeedServer must be embedded to have forward compatible implementations.
+type UnimplementedSequencerFeedServer struct {
+}
+
+func (UnimplementedSequencerFeedServer) Subscribe(*SubscribeRequest, SequencerFeed_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedSequencerFeedServer) mustEmbedUnimplementedSequencerFeedServer() {}
+
+// UnsafeSequencerFeedServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SequencerFeedServer will
+// result in compilation errors.
+type UnsafeSequencerFeedServer interface {
+	mustEmbedUnimplementedSequencerFeedServer()
+}
+
+func RegisterSequencerFeedServer(s grpc.ServiceRegistrar, srv SequencerFeedServer) {
+	s.RegisterService(&SequencerFeed_ServiceDesc, srv)
+}
+
+func _SequencerFeed_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SequencerFeedServer).Subscribe(m, &sequencerFeedSubscribeServer{stream})
+}
+
+type SequencerFeed_SubscribeServer interface {
+	Send(*FeedMessage) error
+	grpc.ServerStream
+}
+
+type sequencerFeedSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *sequencerFeedSubscribeServer) Send(m *FeedMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SequencerFeed_ServiceDesc is the grpc.ServiceDesc for SequencerFeed service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SequencerFeed_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "arbitrum.feed.v1.SequencerFeed",
+	HandlerType: (*SequencerFeedServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _SequencerFeed_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "feed.proto",
+}
@@ -0,0 +1,225 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package grpcfeed
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // lets subscribers ask for gzip compressed messages
+	"google.golang.org/grpc/status"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
+)
+
+// Config serves the sequencer feed over gRPC, alongside the websocket feed.
+type Config struct {
+	Enable       bool   `koanf:"enable"`
+	Addr         string `koanf:"addr"`
+	Port         string `koanf:"port"`
+	MaxSendQueue int    `koanf:"max-send-queue"`
+	Backlog      int    `koanf:"backlog"`
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultConfig.Enable, "serve the feed over gRPC as well as websockets")
+	f.String(prefix+".addr", DefaultConfig.Addr, "address to bind the gRPC feed to")
+	f.String(prefix+".port", DefaultConfig.Port, "port to bind the gRPC feed to")
+	f.Int(prefix+".max-send-queue", DefaultConfig.MaxSendQueue, "maximum number of messages allowed to accumulate for a subscriber before it's disconnected")
+	f.Int(prefix+".backlog", DefaultConfig.Backlog, "maximum number of unconfirmed messages to keep for subscribers as they connect, dropping the oldest beyond it")
+}
+
+var DefaultConfig = Config{
+	Enable:       false,
+	Addr:         "",
+	Port:         "9643",
+	MaxSendQueue: 4096,
+	Backlog:      10_000,
+}
+
+var errSubscriberBehind = status.Error(codes.ResourceExhausted, "subscriber fell too far behind the feed")
+
+type subscriber struct {
+	stream wsbroadcastserver.Stream
+	out    chan broadcaster.BroadcastMessage
+	behind chan struct{} // closed if the subscriber's queue overflows
+}
+
+// Server streams everything the broadcaster broadcasts to gRPC subscribers. Like the websocket feed, a new
+// subscriber is first sent the unconfirmed messages, or those from the sequence number it resumes from.
+type Server struct {
+	stopwaiter.StopWaiter
+	UnimplementedSequencerFeedServer
+
+	config     *Config
+	grpcServer *grpc.Server
+	listener   net.Listener
+
+	mutex       sync.Mutex
+	subscribers map[*subscriber]struct{}
+	messages    []*broadcaster.BroadcastFeedMessage // unconfirmed, up to the backlog
+	confirmed   *broadcaster.ConfirmedSequenceNumberMessage
+	advisory    *broadcaster.AdvisoryMessage
+}
+
+func NewServer(config *Config) *Server {
+	s := &Server{
+		config:      config,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+	s.grpcServer = grpc.NewServer()
+	RegisterSequencerFeedServer(s.grpcServer, s)
+	return s
+}
+
+func (s *Server) Start(ctxIn context.Context) error {
+	s.StopWaiter.Start(ctxIn)
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.config.Addr, s.config.Port))
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.LaunchThread(func(ctx context.Context) {
+		if err := s.grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Error("gRPC feed stopped serving", "err", err)
+		}
+	})
+	return nil
+}
+
+func (s *Server) ListenerAddr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Server) StopAndWait() {
+	s.grpcServer.Stop()
+	s.StopWaiter.StopAndWait()
+}
+
+// Broadcast sends the message to the subscribers. It never blocks: subscribers too far behind are disconnected.
+func (s *Server) Broadcast(msg broadcaster.BroadcastMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages = append(s.messages, msg.Messages...)
+	drop := 0
+	if msg.ConfirmedSequenceNumberMessage != nil {
+		s.confirmed = msg.ConfirmedSequenceNumberMessage
+		for drop < len(s.messages) && s.messages[drop].SequenceNumber <= s.confirmed.SequenceNumber {
+			drop++
+		}
+	}
+	// Like the websocket feed's backlog, subscribers resuming from before the oldest kept are told to backfill
+	if overflow := len(s.messages) - drop - s.config.Backlog; s.config.Backlog > 0 && overflow > 0 {
+		drop += overflow
+	}
+	if drop > 0 {
+		// Copied, so the dropped messages aren't kept alive by the slice's backing array
+		s.messages = append([]*broadcaster.BroadcastFeedMessage{}, s.messages[drop:]...)
+	}
+	if msg.AdvisoryMessage != nil {
+		s.advisory = msg.AdvisoryMessage
+	}
+	for sub := range s.subscribers {
+		select {
+		case sub.out <- msg:
+		default:
+			close(sub.behind)
+			delete(s.subscribers, sub)
+		}
+	}
+}
+
+func (s *Server) subscribe(req *SubscribeRequest, stream wsbroadcastserver.Stream) (*subscriber, []broadcaster.BroadcastMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var catchup []broadcaster.BroadcastMessage
+	messages := s.messages
+	if req.Resume && len(messages) > 0 {
+		first := messages[0].SequenceNumber
+		if uint64(first) > req.FromSequenceNumber {
+			catchup = append(catchup, broadcaster.BroadcastMessage{
+				Version: 1,
+				BackfillMessage: &broadcaster.BackfillMessage{
+					RequestedSequenceNumber: arbutil.MessageIndex(req.FromSequenceNumber),
+					FirstSequenceNumber:     first,
+				},
+			})
+		} else if index := req.FromSequenceNumber - uint64(first); index < uint64(len(messages)) {
+			messages = messages[index:]
+		} else {
+			messages = nil
+		}
+	}
+	if len(messages) > 0 {
+		catchup = append(catchup, broadcaster.BroadcastMessage{Version: 1, Messages: messages})
+	}
+	if s.confirmed != nil {
+		catchup = append(catchup, broadcaster.BroadcastMessage{Version: 1, ConfirmedSequenceNumberMessage: s.confirmed})
+	}
+	if s.advisory != nil {
+		catchup = append(catchup, broadcaster.BroadcastMessage{Version: 1, AdvisoryMessage: s.advisory})
+	}
+	sub := &subscriber{
+		stream: stream,
+		out:    make(chan broadcaster.BroadcastMessage, s.config.MaxSendQueue),
+		behind: make(chan struct{}),
+	}
+	s.subscribers[sub] = struct{}{}
+	return sub, catchup
+}
+
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.subscribers, sub)
+}
+
+// Subscribe streams the feed to a subscriber until it disconnects or falls too far behind.
+func (s *Server) Subscribe(req *SubscribeRequest, stream SequencerFeed_SubscribeServer) error {
+	feedStream, err := toFeedStream(req.Stream)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	sub, catchup := s.subscribe(req, feedStream)
+	defer s.unsubscribe(sub)
+	send := func(msg broadcaster.BroadcastMessage) error {
+		filtered, ok := msg.ForStream(sub.stream)
+		if !ok {
+			return nil
+		}
+		for _, feedMessage := range toProto(filtered.(broadcaster.BroadcastMessage)) {
+			if err := stream.Send(feedMessage); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, msg := range catchup {
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.behind:
+			return errSubscriberBehind
+		case msg := <-sub.out:
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
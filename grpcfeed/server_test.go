@@ -0,0 +1,42 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package grpcfeed
+
+import (
+	"testing"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/wsbroadcastserver"
+)
+
+func TestServerBacklog(t *testing.T) {
+	config := DefaultConfig
+	config.Backlog = 3
+	server := NewServer(&config)
+	for i := 0; i < 5; i++ {
+		server.Broadcast(broadcaster.BroadcastMessage{
+			Version:  1,
+			Messages: []*broadcaster.BroadcastFeedMessage{{SequenceNumber: arbutil.MessageIndex(i)}},
+		})
+	}
+	if len(server.messages) != 3 || server.messages[0].SequenceNumber != 2 {
+		Fail(t, "backlog not capped", len(server.messages))
+	}
+
+	// A subscriber resuming from before the backlog is told to backfill the dropped messages
+	sub, catchup := server.subscribe(&SubscribeRequest{Resume: true, FromSequenceNumber: 0}, wsbroadcastserver.StreamFull)
+	server.unsubscribe(sub)
+	if len(catchup) != 2 || catchup[0].BackfillMessage == nil || catchup[0].BackfillMessage.FirstSequenceNumber != 2 || len(catchup[1].Messages) != 3 {
+		Fail(t, "unexpected catchup", catchup)
+	}
+
+	server.Broadcast(broadcaster.BroadcastMessage{
+		Version:                        1,
+		ConfirmedSequenceNumberMessage: &broadcaster.ConfirmedSequenceNumberMessage{SequenceNumber: 3},
+	})
+	if len(server.messages) != 1 || server.messages[0].SequenceNumber != 4 {
+		Fail(t, "confirmed messages not dropped", len(server.messages))
+	}
+}
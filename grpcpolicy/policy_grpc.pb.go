@@ -0,0 +1,105 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: policy.proto
+
+package grpcpolicy
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SequencingPolicyClient is the client API for SequencingPolicy service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SequencingPolicyClient interface {
+	Check(ctx context.Context, in *Candidate, opts ...grpc.CallOption) (*Decision, error)
+}
+
+type sequencingPolicyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSequencingPolicyClient(cc grpc.ClientConnInterface) SequencingPolicyClient {
+	return &sequencingPolicyClient{cc}
+}
+
+func (c *sequencingPolicyClient) Check(ctx context.Context, in *Candidate, opts ...grpc.CallOption) (*Decision, error) {
+	out := new(Decision)
+	err := c.cc.Invoke(ctx, "/arbitrum.policy.v1.SequencingPolicy/Check", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SequencingPolicyServer is the server API for SequencingPolicy service.
+// All implementations must embed UnimplementedSequencingPolicyServer
+// for forward compatibility
+type SequencingPolicyServer interface {
+	Check(context.Context, *Candidate) (*Decision, error)
+	mustEmbedUnimplementedSequencingPolicyServer()
+}
+
+// UnimplementedSequencingPolicyServer must be embedded to have forward compatible implementations.
+type UnimplementedSequencingPolicyServer struct {
+}
+
+func (UnimplementedSequencingPolicyServer) Check(context.Context, *Candidate) (*Decision, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedSequencingPolicyServer) mustEmbedUnimplementedSequencingPolicyServer() {}
+
+// UnsafeSequencingPolicyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SequencingPolicyServer will
+// result in compilation errors.
+type UnsafeSequencingPolicyServer interface {
+	mustEmbedUnimplementedSequencingPolicyServer()
+}
+
+func RegisterSequencingPolicyServer(s grpc.ServiceRegistrar, srv SequencingPolicyServer) {
+	s.RegisterService(&SequencingPolicy_ServiceDesc, srv)
+}
+
+func _SequencingPolicy_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Candidate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequencingPolicyServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/arbitrum.policy.v1.SequencingPolicy/Check",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequencingPolicyServer).Check(ctx, req.(*Candidate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SequencingPolicy_ServiceDesc is the grpc.ServiceDesc for SequencingPolicy service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SequencingPolicy_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "arbitrum.policy.v1.SequencingPolicy",
+	HandlerType: (*SequencingPolicyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _SequencingPolicy_Check_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "policy.proto",
+}
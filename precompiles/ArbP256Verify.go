@@ -0,0 +1,35 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package precompiles
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"math/big"
+)
+
+// Gas cost of verifying a P-256 signature, as for the P256VERIFY precompile of RIP-7212
+const p256VerifyGas = 3450
+
+// Verifies secp256r1 signatures. A custom precompile, which exists only on chains that register it.
+type ArbP256Verify struct {
+	Address addr
+}
+
+// Checks a P-256 signature (r, s) of a message hash against the public key (x, y)
+func (con ArbP256Verify) Verify(c ctx, hash, r, s, x, y bytes32) (bool, error) {
+	if err := c.Burn(p256VerifyGas); err != nil {
+		return false, err
+	}
+	curve := elliptic.P256()
+	key := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x[:]),
+		Y:     new(big.Int).SetBytes(y[:]),
+	}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return false, nil
+	}
+	return ecdsa.Verify(key, hash[:], new(big.Int).SetBytes(r[:]), new(big.Int).SetBytes(s[:])), nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package precompiles
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/offchainlabs/nitro/arbos/arbosState"
+	templates "github.com/offchainlabs/nitro/solgen/go/precompilesgen"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// The custom precompiles of each chain. As they're part of the state transition function,
+// they're compiled into every binary, including the replay binary used to prove blocks.
+//
+//go:embed custom_precompiles.json
+var customPrecompilesJSON []byte
+
+// CustomPrecompileConfig registers a precompile from the sanctioned extension set on a chain,
+// active once the chain reaches the given ArbOS version.
+type CustomPrecompileConfig struct {
+	ChainId      uint64         `json:"chainId"`
+	Address      common.Address `json:"address"`
+	Extension    string         `json:"extension"`
+	ArbosVersion uint64         `json:"arbosVersion"`
+}
+
+// The precompiles chains may register without changing the precompile wiring, by name
+var sanctionedExtensions = map[string]func(address addr) (addr, Precompile){
+	"ArbP256Verify": func(address addr) (addr, Precompile) {
+		return MakePrecompile(templates.ArbP256VerifyMetaData, &ArbP256Verify{Address: address})
+	},
+}
+
+// A precompile wrapper for those only some chains register
+type CustomPrecompile struct {
+	precompile ArbosPrecompile
+	extension  string
+	chains     map[uint64]uint64 // the ArbOS version activating the precompile, by chain ID
+}
+
+func (wrapper *CustomPrecompile) Call(
+	input []byte,
+	precompileAddress common.Address,
+	actingAsAddress common.Address,
+	caller common.Address,
+	value *big.Int,
+	readOnly bool,
+	gasSupplied uint64,
+	evm *vm.EVM,
+) ([]byte, uint64, error) {
+	activation, ok := wrapper.chains[evm.ChainConfig().ChainID.Uint64()]
+	if !ok || arbosState.ArbOSVersion(evm.StateDB) < activation {
+		// the precompile isn't active on this chain, so treat this call as if it were to a contract that doesn't exist
+		return []byte{}, gasSupplied, nil
+	}
+	con := wrapper.precompile
+	return con.Call(input, precompileAddress, actingAsAddress, caller, value, readOnly, gasSupplied, evm)
+}
+
+func (wrapper *CustomPrecompile) Precompile() Precompile {
+	return wrapper.precompile.Precompile()
+}
+
+// parseCustomPrecompiles builds the custom precompiles registered by the configs, which mustn't collide
+// with the built-in precompiles. Chains may register the same extension at the same address.
+func parseCustomPrecompiles(data []byte, builtin map[addr]ArbosPrecompile) (map[addr]*CustomPrecompile, error) {
+	var configs []CustomPrecompileConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parsing custom precompiles: %w", err)
+	}
+	custom := make(map[addr]*CustomPrecompile)
+	for _, config := range configs {
+		if config.ArbosVersion == 0 {
+			return nil, fmt.Errorf("custom precompile %v of chain %v must activate at an ArbOS version", config.Address, config.ChainId)
+		}
+		if _, ok := builtin[config.Address]; ok {
+			return nil, fmt.Errorf("custom precompile %v of chain %v collides with an ArbOS precompile", config.Address, config.ChainId)
+		}
+		if _, ok := vm.PrecompiledContractsBerlin[config.Address]; ok {
+			return nil, fmt.Errorf("custom precompile %v of chain %v collides with an Ethereum precompile", config.Address, config.ChainId)
+		}
+		existing, ok := custom[config.Address]
+		if !ok {
+			makePrecompile, ok := sanctionedExtensions[config.Extension]
+			if !ok {
+				return nil, fmt.Errorf("custom precompile %v of chain %v is the unknown extension %v", config.Address, config.ChainId, config.Extension)
+			}
+			_, precompile := makePrecompile(config.Address)
+			existing = &CustomPrecompile{
+				precompile: precompile,
+				extension:  config.Extension,
+				chains:     make(map[uint64]uint64),
+			}
+			custom[config.Address] = existing
+		} else if existing.extension != config.Extension {
+			return nil, fmt.Errorf("custom precompile %v is registered as both %v and %v", config.Address, existing.extension, config.Extension)
+		}
+		if _, ok := existing.chains[config.ChainId]; ok {
+			return nil, fmt.Errorf("custom precompile %v is registered twice on chain %v", config.Address, config.ChainId)
+		}
+		existing.chains[config.ChainId] = config.ArbosVersion
+	}
+	return custom, nil
+}
+
+// tell ArbOS which custom precompiles to give fake code, as it does for the built-in ones, once they activate
+func registerCustomPrecompileVersions(custom map[addr]*CustomPrecompile) {
+	versions := make(map[uint64]map[common.Address]uint64)
+	for address, precompile := range custom {
+		for chainId, version := range precompile.chains {
+			if versions[chainId] == nil {
+				versions[chainId] = make(map[common.Address]uint64)
+			}
+			versions[chainId][address] = version
+		}
+	}
+	arbosState.CustomPrecompileVersions = versions
+}
@@ -0,0 +1,74 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package precompiles
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestParseCustomPrecompiles(t *testing.T) {
+	builtin := Precompiles()
+	p256 := `{"chainId": 412346, "address": "0x0000000000000000000000000000000000000100", "extension": "ArbP256Verify", "arbosVersion": 4}`
+
+	custom, err := parseCustomPrecompiles([]byte("["+p256+`,
+		{"chainId": 1337, "address": "0x0000000000000000000000000000000000000100", "extension": "ArbP256Verify", "arbosVersion": 1}
+	]`), builtin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precompile := custom[common.HexToAddress("0x100")]
+	if len(custom) != 1 || precompile == nil || precompile.chains[412346] != 4 || precompile.chains[1337] != 1 {
+		t.Fatal("unexpected custom precompiles", custom)
+	}
+
+	invalid := []string{
+		"[" + p256 + "," + p256 + "]",
+		`[{"chainId": 1, "address": "0x0000000000000000000000000000000000000100", "extension": "Unknown", "arbosVersion": 1}]`,
+		`[{"chainId": 1, "address": "0x0000000000000000000000000000000000000100", "extension": "ArbP256Verify", "arbosVersion": 0}]`,
+		`[{"chainId": 1, "address": "0x0000000000000000000000000000000000000064", "extension": "ArbP256Verify", "arbosVersion": 1}]`,
+		`[{"chainId": 1, "address": "0x0000000000000000000000000000000000000001", "extension": "ArbP256Verify", "arbosVersion": 1}]`,
+	}
+	for _, data := range invalid {
+		if _, err := parseCustomPrecompiles([]byte(data), builtin); err == nil {
+			t.Fatal("accepted invalid custom precompiles", data)
+		}
+	}
+}
+
+func TestArbP256Verify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := crypto.Keccak256Hash([]byte("message"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := common.BigToHash(key.X), common.BigToHash(key.Y)
+
+	c := &Context{gasSupplied: p256VerifyGas * 4, gasLeft: p256VerifyGas * 4}
+	con := ArbP256Verify{}
+	valid, err := con.Verify(c, hash, common.BigToHash(r), common.BigToHash(s), x, y)
+	if err != nil || !valid {
+		t.Fatal("valid signature rejected", err)
+	}
+	valid, err = con.Verify(c, crypto.Keccak256Hash([]byte("other")), common.BigToHash(r), common.BigToHash(s), x, y)
+	if err != nil || valid {
+		t.Fatal("signature of another message accepted", err)
+	}
+	valid, err = con.Verify(c, hash, common.BigToHash(r), common.BigToHash(s), x, common.Hash{})
+	if err != nil || valid {
+		t.Fatal("key off the curve accepted", err)
+	}
+	if c.Burned() != p256VerifyGas*3 {
+		t.Fatal("unexpected gas burned", c.Burned())
+	}
+}
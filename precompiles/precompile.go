@@ -569,6 +569,15 @@ func Precompiles() map[addr]ArbosPrecompile {
 	arbos.InternalTxStartBlockMethodID = ArbosActs.GetMethodID("StartBlock")
 	arbos.InternalTxBatchPostingReportMethodID = ArbosActs.GetMethodID("BatchPostingReport")
 
+	custom, err := parseCustomPrecompiles(customPrecompilesJSON, contracts)
+	if err != nil {
+		log.Crit("Invalid custom precompiles", "err", err)
+	}
+	for address, precompile := range custom {
+		insert(address, precompile)
+	}
+	registerCustomPrecompileVersions(custom)
+
 	return contracts
 }
 
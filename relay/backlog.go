@@ -0,0 +1,215 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package relay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+// BacklogConfig keeps the relay's backlog on disk, so it survives restarts. The backlog is a ring of segment
+// files, the oldest of which are deleted once they're older than MaxAge or the backlog is larger than MaxBytes.
+type BacklogConfig struct {
+	Directory   string        `koanf:"directory"`
+	MaxAge      time.Duration `koanf:"max-age"`
+	MaxBytes    int64         `koanf:"max-bytes"`
+	SegmentSize int64         `koanf:"segment-size"`
+}
+
+func BacklogConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".directory", DefaultBacklogConfig.Directory, "directory to keep the backlog in across restarts (empty to keep it in memory only)")
+	f.Duration(prefix+".max-age", DefaultBacklogConfig.MaxAge, "how long to keep backlog messages on disk (0 for no limit)")
+	f.Int64(prefix+".max-bytes", DefaultBacklogConfig.MaxBytes, "most bytes of backlog to keep on disk (0 for no limit)")
+	f.Int64(prefix+".segment-size", DefaultBacklogConfig.SegmentSize, "bytes written to a backlog segment file before starting the next")
+}
+
+var DefaultBacklogConfig = BacklogConfig{
+	Directory:   "",
+	MaxAge:      24 * time.Hour,
+	MaxBytes:    1 << 30,
+	SegmentSize: 64 << 20,
+}
+
+const backlogSegmentSuffix = ".log"
+
+type backlogSegment struct {
+	number   uint64
+	size     int64
+	modified time.Time
+}
+
+// diskBacklog records the messages and confirmations the relay broadcasts, one JSON line each, so they can be
+// broadcast again when it restarts. Segments are numbered in the order they're written.
+type diskBacklog struct {
+	config   *BacklogConfig
+	segments []*backlogSegment // oldest first, the last being written to
+	current  *os.File
+}
+
+func openDiskBacklog(config *BacklogConfig) (*diskBacklog, error) {
+	if err := os.MkdirAll(config.Directory, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(config.Directory)
+	if err != nil {
+		return nil, err
+	}
+	backlog := &diskBacklog{config: config}
+	for _, entry := range entries {
+		var number uint64
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), backlogSegmentSuffix) {
+			continue
+		}
+		if _, err := fmt.Sscanf(entry.Name(), "%d"+backlogSegmentSuffix, &number); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backlog.segments = append(backlog.segments, &backlogSegment{number, info.Size(), info.ModTime()})
+	}
+	sort.Slice(backlog.segments, func(i, j int) bool {
+		return backlog.segments[i].number < backlog.segments[j].number
+	})
+	if err := backlog.prune(time.Now()); err != nil {
+		return nil, err
+	}
+	return backlog, nil
+}
+
+func (b *diskBacklog) path(segment *backlogSegment) string {
+	return filepath.Join(b.config.Directory, fmt.Sprintf("%020d%s", segment.number, backlogSegmentSuffix))
+}
+
+func (b *diskBacklog) size() int64 {
+	var size int64
+	for _, segment := range b.segments {
+		size += segment.size
+	}
+	return size
+}
+
+// replay calls the callback with each message in the backlog, oldest first.
+func (b *diskBacklog) replay(callback func(*broadcaster.BroadcastMessage)) error {
+	for _, segment := range b.segments {
+		file, err := os.Open(b.path(segment))
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 1<<30)
+		for scanner.Scan() {
+			var msg broadcaster.BroadcastMessage
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				// a partial final line, if the relay didn't stop cleanly
+				log.Warn("skipping unreadable relay backlog record", "segment", b.path(segment), "err", err)
+				continue
+			}
+			callback(&msg)
+		}
+		err = scanner.Err()
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastSequenceNumber returns the sequence number of the last message in the backlog, if it has any.
+func (b *diskBacklog) lastSequenceNumber() (arbutil.MessageIndex, bool, error) {
+	var last arbutil.MessageIndex
+	found := false
+	err := b.replay(func(msg *broadcaster.BroadcastMessage) {
+		for _, message := range msg.Messages {
+			if message != nil && (!found || message.SequenceNumber > last) {
+				last = message.SequenceNumber
+				found = true
+			}
+		}
+	})
+	return last, found, err
+}
+
+func (b *diskBacklog) append(msg *broadcaster.BroadcastMessage, now time.Time) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if b.current == nil || b.segments[len(b.segments)-1].size >= b.config.SegmentSize {
+		if err := b.rotate(now); err != nil {
+			return err
+		}
+	}
+	segment := b.segments[len(b.segments)-1]
+	n, err := b.current.Write(data)
+	segment.size += int64(n)
+	segment.modified = now
+	return err
+}
+
+// rotate starts writing to a new segment, and drops the oldest ones past the retention limits.
+func (b *diskBacklog) rotate(now time.Time) error {
+	if err := b.closeCurrent(); err != nil {
+		return err
+	}
+	segment := &backlogSegment{modified: now}
+	if len(b.segments) > 0 {
+		segment.number = b.segments[len(b.segments)-1].number + 1
+	}
+	file, err := os.OpenFile(b.path(segment), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	b.current = file
+	b.segments = append(b.segments, segment)
+	return b.prune(now)
+}
+
+// prune deletes the oldest segments while the backlog is past its retention limits, other than the one being written.
+func (b *diskBacklog) prune(now time.Time) error {
+	for len(b.segments) > 0 {
+		oldest := b.segments[0]
+		if b.current != nil && len(b.segments) == 1 {
+			break
+		}
+		expired := b.config.MaxAge > 0 && now.Sub(oldest.modified) > b.config.MaxAge
+		oversized := b.config.MaxBytes > 0 && b.size() > b.config.MaxBytes
+		if !expired && !oversized {
+			break
+		}
+		if err := os.Remove(b.path(oldest)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		b.segments = b.segments[1:]
+	}
+	return nil
+}
+
+func (b *diskBacklog) closeCurrent() error {
+	if b.current == nil {
+		return nil
+	}
+	err := b.current.Close()
+	b.current = nil
+	return err
+}
+
+func (b *diskBacklog) Close() error {
+	return b.closeCurrent()
+}
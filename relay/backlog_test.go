@@ -0,0 +1,83 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package relay
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+func TestDiskBacklog(t *testing.T) {
+	config := DefaultBacklogConfig
+	config.Directory = t.TempDir()
+	config.SegmentSize = 1
+	config.MaxAge = time.Hour
+	config.MaxBytes = 0
+
+	backlog, err := openDiskBacklog(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 1; i <= 4; i++ {
+		msg := &broadcaster.BroadcastMessage{
+			Version:  1,
+			Messages: []*broadcaster.BroadcastFeedMessage{{SequenceNumber: arbutil.MessageIndex(i)}},
+		}
+		if err := backlog.append(msg, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = backlog.append(&broadcaster.BroadcastMessage{
+		Version:                        1,
+		ConfirmedSequenceNumberMessage: &broadcaster.ConfirmedSequenceNumberMessage{SequenceNumber: 2},
+	}, start.Add(5*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlog.segments) != 5 {
+		t.Fatal("each record should have its own segment", len(backlog.segments))
+	}
+	if err := backlog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the backlog survives reopening, less the segments which have expired since
+	expired := time.Now().Add(-2 * time.Hour)
+	for _, segment := range backlog.segments[:2] {
+		if err := os.Chtimes(backlog.path(segment), expired, expired); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backlog, err = openDiskBacklog(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backlog.Close()
+	var replayed []*broadcaster.BroadcastMessage
+	if err := backlog.replay(func(msg *broadcaster.BroadcastMessage) { replayed = append(replayed, msg) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 3 || replayed[0].Messages[0].SequenceNumber != 3 || replayed[2].ConfirmedSequenceNumberMessage.SequenceNumber != 2 {
+		t.Fatal("unexpected backlog after reopening", len(replayed))
+	}
+	last, found, err := backlog.lastSequenceNumber()
+	if err != nil || !found || last != 4 {
+		t.Fatal("unexpected last sequence number", last, found, err)
+	}
+
+	// a byte limit keeps the newest segments which fit, and the one being written
+	config.MaxAge = 0
+	config.MaxBytes = backlog.segments[len(backlog.segments)-1].size
+	if err := backlog.append(&broadcaster.BroadcastMessage{Version: 1}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(backlog.segments) != 2 || backlog.segments[0].number != 4 || backlog.segments[1].number != 5 {
+		t.Fatal("unexpected segments after pruning by size", len(backlog.segments))
+	}
+}
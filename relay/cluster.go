@@ -0,0 +1,233 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-redis/redis/v8"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+// ClusterConfig lets a fleet of relays behind a load balancer share their messages through Redis. Each message
+// a relay receives is recorded once for the whole cluster and published to the others, so a relay that missed a
+// message from its upstream feed still broadcasts it, and a relay joining the cluster starts with the same backlog.
+type ClusterConfig struct {
+	RedisUrl   string        `koanf:"redis-url"`
+	KeyPrefix  string        `koanf:"key-prefix"`
+	MessageTTL time.Duration `koanf:"message-ttl"`
+	Timeout    time.Duration `koanf:"timeout"`
+}
+
+func ClusterConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".redis-url", DefaultClusterConfig.RedisUrl, "Redis url shared by the relays of a cluster (empty to run alone)")
+	f.String(prefix+".key-prefix", DefaultClusterConfig.KeyPrefix, "prefix of the Redis keys and channel the cluster uses, so several clusters can share a Redis")
+	f.Duration(prefix+".message-ttl", DefaultClusterConfig.MessageTTL, "how long a message is kept in Redis for relays which haven't seen it")
+	f.Duration(prefix+".timeout", DefaultClusterConfig.Timeout, "timeout of each Redis operation")
+}
+
+var DefaultClusterConfig = ClusterConfig{
+	RedisUrl:   "",
+	KeyPrefix:  "relay.cluster",
+	MessageTTL: time.Hour,
+	Timeout:    5 * time.Second,
+}
+
+// Keys under the config's prefix
+const CLUSTER_MESSAGE_KEY_PREFIX string = ".msg." // Per message, written by whichever relay receives it first
+const CLUSTER_INDEX_KEY string = ".index"         // Sorted set of the unconfirmed messages' sequence numbers
+const CLUSTER_CONFIRMED_KEY string = ".confirmed" // Highest confirmed sequence number
+const CLUSTER_CHANNEL string = ".messages"        // Pub/sub channel of the messages
+
+// How many messages and confirmations may wait to be shared with the cluster before more are dropped
+const clusterQueueSize = 1024
+
+// recordScript stores a message unless a relay already has, indexing and publishing it if not. Its keys are the
+// message and the index, and its arguments the message, its sequence number, its TTL in milliseconds and the channel.
+var recordScript = redis.NewScript(`
+local first
+if ARGV[3] == "0" then
+	first = redis.call("SET", KEYS[1], ARGV[1], "NX")
+else
+	first = redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[3])
+end
+if not first then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[2], ARGV[2])
+redis.call("PUBLISH", ARGV[4], ARGV[1])
+return 1
+`)
+
+// confirmScript sets the confirmation unless it already holds a greater one, and forgets the index of the
+// messages it confirms. Its keys are the confirmation and the index.
+var confirmScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]))
+local value = tonumber(ARGV[1])
+if current == nil or value > current then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])
+return 0
+`)
+
+// clusterUpdate is a message or a confirmation waiting to be shared with the cluster.
+type clusterUpdate struct {
+	msg       *broadcaster.BroadcastFeedMessage
+	confirmed arbutil.MessageIndex
+}
+
+type cluster struct {
+	config  *ClusterConfig
+	client  redis.UniversalClient
+	updates chan clusterUpdate
+}
+
+func newCluster(config *ClusterConfig) (*cluster, error) {
+	redisOptions, err := redis.ParseURL(config.RedisUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &cluster{
+		config:  config,
+		client:  redis.NewClient(redisOptions),
+		updates: make(chan clusterUpdate, clusterQueueSize),
+	}, nil
+}
+
+func (c *cluster) messageKey(seqNum arbutil.MessageIndex) string {
+	return c.config.KeyPrefix + CLUSTER_MESSAGE_KEY_PREFIX + strconv.FormatUint(uint64(seqNum), 10)
+}
+
+// record stores the message for the cluster, publishing it to the other relays if none has recorded it yet.
+func (c *cluster) record(ctx context.Context, msg *broadcaster.BroadcastFeedMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	keys := []string{c.messageKey(msg.SequenceNumber), c.config.KeyPrefix + CLUSTER_INDEX_KEY}
+	return recordScript.Run(ctx, c.client, keys, data, uint64(msg.SequenceNumber), c.config.MessageTTL.Milliseconds(), c.config.KeyPrefix+CLUSTER_CHANNEL).Err()
+}
+
+// confirm records the confirmation, forgetting the index of the messages it confirms.
+func (c *cluster) confirm(ctx context.Context, seqNum arbutil.MessageIndex) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	keys := []string{c.config.KeyPrefix + CLUSTER_CONFIRMED_KEY, c.config.KeyPrefix + CLUSTER_INDEX_KEY}
+	return confirmScript.Run(ctx, c.client, keys, uint64(seqNum)).Err()
+}
+
+// share queues the update to be shared with the cluster, so the relay's broadcasting never waits on Redis.
+// If Redis has fallen too far behind the update is dropped, as the other relays still get it from their feeds.
+func (c *cluster) share(update clusterUpdate) {
+	select {
+	case c.updates <- update:
+	default:
+		log.Warn("relay cluster queue full, not sharing update", "message", update.msg != nil)
+	}
+}
+
+// shareUpdates writes the queued updates to Redis, until the context is done.
+func (c *cluster) shareUpdates(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-c.updates:
+			if update.msg != nil {
+				if err := c.record(ctx, update.msg); err != nil {
+					log.Warn("failed to share message with relay cluster", "sequenceNumber", update.msg.SequenceNumber, "err", err)
+				}
+			} else if err := c.confirm(ctx, update.confirmed); err != nil {
+				log.Warn("failed to share confirmation with relay cluster", "sequenceNumber", update.confirmed, "err", err)
+			}
+		}
+	}
+}
+
+// load returns the cluster's unconfirmed messages, oldest first, and its confirmation if it has one.
+func (c *cluster) load(ctx context.Context) ([]*broadcaster.BroadcastFeedMessage, *arbutil.MessageIndex, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	var confirmed *arbutil.MessageIndex
+	confirmedVal, err := c.client.Get(ctx, c.config.KeyPrefix+CLUSTER_CONFIRMED_KEY).Uint64()
+	if err == nil {
+		seqNum := arbutil.MessageIndex(confirmedVal)
+		confirmed = &seqNum
+	} else if !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+	minScore := "-inf"
+	if confirmed != nil {
+		minScore = "(" + strconv.FormatUint(confirmedVal, 10)
+	}
+	members, err := c.client.ZRangeByScore(ctx, c.config.KeyPrefix+CLUSTER_INDEX_KEY, &redis.ZRangeBy{Min: minScore, Max: "+inf"}).Result()
+	if err != nil || len(members) == 0 {
+		return nil, confirmed, err
+	}
+	keys := make([]string, 0, len(members))
+	for _, member := range members {
+		keys = append(keys, c.config.KeyPrefix+CLUSTER_MESSAGE_KEY_PREFIX+member)
+	}
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	messages := make([]*broadcaster.BroadcastFeedMessage, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// expired since it was indexed
+			continue
+		}
+		var msg broadcaster.BroadcastFeedMessage
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			log.Warn("skipping unreadable relay cluster message", "key", keys[i], "err", err)
+			continue
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, confirmed, nil
+}
+
+// subscribe passes the messages the other relays record to the channel, until the context is done.
+func (c *cluster) subscribe(ctx context.Context, messageChan chan<- *broadcaster.BroadcastFeedMessage) {
+	pubsub := c.client.Subscribe(ctx, c.config.KeyPrefix+CLUSTER_CHANNEL)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case published, ok := <-messages:
+			if !ok {
+				return
+			}
+			var msg broadcaster.BroadcastFeedMessage
+			if err := json.Unmarshal([]byte(published.Payload), &msg); err != nil {
+				log.Warn("skipping unreadable relay cluster message", "err", err)
+				continue
+			}
+			select {
+			case messageChan <- &msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (c *cluster) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,106 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package relay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/broadcaster"
+)
+
+func newTestCluster(t *testing.T, server *miniredis.Miniredis) *cluster {
+	config := DefaultClusterConfig
+	config.RedisUrl = "redis://" + server.Addr()
+	c, err := newCluster(&config)
+	Require(t, err)
+	return c
+}
+
+func TestRelayCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, err := miniredis.Run()
+	Require(t, err)
+	defer server.Close()
+
+	first := newTestCluster(t, server)
+	defer first.Close()
+	second := newTestCluster(t, server)
+	defer second.Close()
+
+	published := make(chan *broadcaster.BroadcastFeedMessage, 10)
+	go second.subscribe(ctx, published)
+	// give the subscription time to be registered
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		msg := &broadcaster.BroadcastFeedMessage{SequenceNumber: arbutil.MessageIndex(i)}
+		Require(t, first.record(ctx, msg))
+		// the second relay receiving it too doesn't publish it again
+		Require(t, second.record(ctx, msg))
+	}
+	for i := 1; i <= 3; i++ {
+		select {
+		case msg := <-published:
+			if msg.SequenceNumber != arbutil.MessageIndex(i) {
+				Fail(t, "published message", msg.SequenceNumber, "expected", i)
+			}
+		case <-time.After(5 * time.Second):
+			Fail(t, "timed out waiting for message", i)
+		}
+	}
+	select {
+	case msg := <-published:
+		Fail(t, "message published twice", msg.SequenceNumber)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	Require(t, first.confirm(ctx, 2))
+	// an older confirmation doesn't move it back
+	Require(t, second.confirm(ctx, 1))
+	joining := newTestCluster(t, server)
+	defer joining.Close()
+	messages, confirmed, err := joining.load(ctx)
+	Require(t, err)
+	if confirmed == nil || *confirmed != 2 {
+		Fail(t, "unexpected confirmation", confirmed)
+	}
+	if len(messages) != 1 || messages[0].SequenceNumber != 3 {
+		Fail(t, "unexpected unconfirmed messages", messages)
+	}
+}
+
+func TestRelayClusterSharesInBackground(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, err := miniredis.Run()
+	Require(t, err)
+	defer server.Close()
+
+	c := newTestCluster(t, server)
+	defer c.Close()
+	// queued while nothing writes to Redis, so sharing never blocks
+	for i := 1; i <= 3; i++ {
+		c.share(clusterUpdate{msg: &broadcaster.BroadcastFeedMessage{SequenceNumber: arbutil.MessageIndex(i)}})
+	}
+	c.share(clusterUpdate{confirmed: 1})
+	go c.shareUpdates(ctx)
+
+	for start := time.Now(); ; {
+		messages, confirmed, err := c.load(ctx)
+		Require(t, err)
+		if confirmed != nil && *confirmed == 1 && len(messages) == 2 && messages[0].SequenceNumber == 2 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			Fail(t, "queued updates not shared", messages, confirmed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -6,13 +6,17 @@ package relay
 import (
 	"context"
 	"errors"
+	"math/big"
 	"net"
 	"time"
 
+	"github.com/ethereum/go-ethereum/log"
+
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/broadcastclient"
 	"github.com/offchainlabs/nitro/broadcaster"
+	"github.com/offchainlabs/nitro/grpcfeed"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 	"github.com/offchainlabs/nitro/wsbroadcastserver"
 )
@@ -22,48 +26,99 @@ type Relay struct {
 	broadcastClients            []*broadcastclient.BroadcastClient
 	broadcaster                 *broadcaster.Broadcaster
 	confirmedSequenceNumberChan chan arbutil.MessageIndex
-	messageChan                 chan broadcastFeedMessage
-}
-
-type broadcastFeedMessage struct {
-	message        arbstate.MessageWithMetadata
-	sequenceNumber arbutil.MessageIndex
+	advisoryChan                chan *broadcaster.AdvisoryMessage
+	messageChan                 chan *broadcaster.BroadcastFeedMessage
+	backlog                     *diskBacklog     // nil unless the backlog is kept on disk
+	grpcFeed                    *grpcfeed.Server // nil unless the feed is also served over gRPC
+	cluster                     *cluster         // nil unless the relay shares its messages with a cluster
 }
 
 type RelayMessageQueue struct {
-	queue chan broadcastFeedMessage
+	queue chan *broadcaster.BroadcastFeedMessage
 }
 
 func (q *RelayMessageQueue) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
 	for i, message := range messages {
-		q.queue <- broadcastFeedMessage{
-			sequenceNumber: pos + arbutil.MessageIndex(i),
-			message:        message,
+		q.queue <- &broadcaster.BroadcastFeedMessage{
+			SequenceNumber: pos + arbutil.MessageIndex(i),
+			Message:        message,
 		}
 	}
 
 	return nil
 }
 
-func NewRelay(serverConf wsbroadcastserver.BroadcasterConfig, clientConf broadcastclient.BroadcastClientConfig) *Relay {
+// AddBroadcastFeedMessages queues the messages as they were sent, so the sequencer's signatures are relayed
+// for clients to check.
+func (q *RelayMessageQueue) AddBroadcastFeedMessages(messages []*broadcaster.BroadcastFeedMessage) error {
+	for _, message := range messages {
+		q.queue <- message
+	}
+
+	return nil
+}
+
+func NewRelay(serverConf wsbroadcastserver.BroadcasterConfig, clientConf broadcastclient.BroadcastClientConfig, backlogConf BacklogConfig, grpcConf grpcfeed.Config, clusterConf ClusterConfig) (*Relay, error) {
+	compression, err := wsbroadcastserver.ParseCompression(clientConf.Compression)
+	if err != nil {
+		return nil, err
+	}
+	var backlog *diskBacklog
+	var lastInboxSeqNum *big.Int
+	if backlogConf.Directory != "" {
+		backlog, err = openDiskBacklog(&backlogConf)
+		if err != nil {
+			return nil, err
+		}
+		// resume the feed after the messages the backlog already has
+		last, found, err := backlog.lastSequenceNumber()
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			lastInboxSeqNum = new(big.Int).SetUint64(uint64(last))
+		}
+	}
+	var relayCluster *cluster
+	if clusterConf.RedisUrl != "" {
+		relayCluster, err = newCluster(&clusterConf)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var broadcastClients []*broadcastclient.BroadcastClient
 
-	q := RelayMessageQueue{make(chan broadcastFeedMessage, 100)}
+	q := RelayMessageQueue{make(chan *broadcaster.BroadcastFeedMessage, 100)}
 
 	confirmedSequenceNumberListener := make(chan arbutil.MessageIndex, 10)
+	advisoryListener := make(chan *broadcaster.AdvisoryMessage, 10)
 
 	for _, address := range clientConf.URLs {
-		client := broadcastclient.NewBroadcastClient(address, nil, clientConf.Timeout, &q)
+		client := broadcastclient.NewBroadcastClient(address, lastInboxSeqNum, clientConf.Timeout, &q)
 		client.ConfirmedSequenceNumberListener = confirmedSequenceNumberListener
+		client.AdvisoryListener = advisoryListener
+		client.SetCompression(compression)
+		client.SetToken(clientConf.Token)
 		broadcastClients = append(broadcastClients, client)
 	}
 
+	feedBroadcaster := broadcaster.NewBroadcaster(serverConf)
+	var grpcFeed *grpcfeed.Server
+	if grpcConf.Enable {
+		grpcFeed = grpcfeed.NewServer(&grpcConf)
+		feedBroadcaster.AddListener(grpcFeed)
+	}
+
 	return &Relay{
-		broadcaster:                 broadcaster.NewBroadcaster(serverConf),
+		broadcaster:                 feedBroadcaster,
 		broadcastClients:            broadcastClients,
 		confirmedSequenceNumberChan: confirmedSequenceNumberListener,
+		advisoryChan:                advisoryListener,
 		messageChan:                 q.queue,
-	}
+		backlog:                     backlog,
+		grpcFeed:                    grpcFeed,
+		cluster:                     relayCluster,
+	}, nil
 }
 
 const RECENT_FEED_ITEM_TTL time.Duration = time.Second * 10
@@ -74,12 +129,50 @@ func (r *Relay) Start(ctx context.Context) error {
 	if err != nil {
 		return errors.New("broadcast unable to start")
 	}
+	if r.grpcFeed != nil {
+		if err := r.grpcFeed.Start(ctx); err != nil {
+			return err
+		}
+	}
+
+	if r.backlog != nil {
+		// the broadcaster's catchup buffer rebuilds itself from the messages and confirmations it had
+		replayed := 0
+		err := r.backlog.replay(func(msg *broadcaster.BroadcastMessage) {
+			r.broadcaster.Broadcast(*msg)
+			replayed++
+		})
+		if err != nil {
+			return err
+		}
+		log.Info("replayed relay backlog from disk", "records", replayed, "bytes", r.backlog.size())
+	}
+
+	recentFeedItems := make(map[arbutil.MessageIndex]time.Time)
+	if r.cluster != nil {
+		// start from the cluster's backlog, so every relay in it presents the same messages
+		messages, confirmed, err := r.cluster.load(ctx)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			recentFeedItems[msg.SequenceNumber] = time.Now()
+			r.broadcaster.Broadcast(broadcaster.BroadcastMessage{Version: 1, Messages: []*broadcaster.BroadcastFeedMessage{msg}})
+		}
+		if confirmed != nil {
+			r.broadcaster.Confirm(*confirmed)
+		}
+		log.Info("loaded relay cluster backlog", "messages", len(messages))
+		r.LaunchThread(func(ctx context.Context) {
+			r.cluster.subscribe(ctx, r.messageChan)
+		})
+		r.LaunchThread(r.cluster.shareUpdates)
+	}
 
 	for _, client := range r.broadcastClients {
 		client.Start(ctx)
 	}
 
-	recentFeedItems := make(map[arbutil.MessageIndex]time.Time)
 	r.LaunchThread(func(ctx context.Context) {
 		recentFeedItemsCleanup := time.NewTicker(RECENT_FEED_ITEM_TTL)
 		defer recentFeedItemsCleanup.Stop()
@@ -88,13 +181,30 @@ func (r *Relay) Start(ctx context.Context) error {
 			case <-ctx.Done():
 				return
 			case msg := <-r.messageChan:
-				if recentFeedItems[msg.sequenceNumber] != (time.Time{}) {
+				if recentFeedItems[msg.SequenceNumber] != (time.Time{}) {
 					continue
 				}
-				recentFeedItems[msg.sequenceNumber] = time.Now()
-				r.broadcaster.BroadcastSingle(msg.message, msg.sequenceNumber)
+				recentFeedItems[msg.SequenceNumber] = time.Now()
+				broadcastMessage := &broadcaster.BroadcastMessage{
+					Version:  1,
+					Messages: []*broadcaster.BroadcastFeedMessage{msg},
+				}
+				r.broadcaster.Broadcast(*broadcastMessage)
+				r.recordBacklog(broadcastMessage)
+				if r.cluster != nil {
+					r.cluster.share(clusterUpdate{msg: msg})
+				}
 			case cs := <-r.confirmedSequenceNumberChan:
 				r.broadcaster.Confirm(cs)
+				r.recordBacklog(&broadcaster.BroadcastMessage{
+					Version:                        1,
+					ConfirmedSequenceNumberMessage: &broadcaster.ConfirmedSequenceNumberMessage{SequenceNumber: cs},
+				})
+				if r.cluster != nil {
+					r.cluster.share(clusterUpdate{confirmed: cs})
+				}
+			case advisory := <-r.advisoryChan:
+				r.broadcaster.Advise(advisory)
 			case <-recentFeedItemsCleanup.C:
 				// Clear expired items from recentFeedItems
 				recentFeedItemExpiry := time.Now().Add(-RECENT_FEED_ITEM_TTL)
@@ -103,6 +213,11 @@ func (r *Relay) Start(ctx context.Context) error {
 						delete(recentFeedItems, acc)
 					}
 				}
+				if r.backlog != nil {
+					if err := r.backlog.prune(time.Now()); err != nil {
+						log.Error("failed to prune relay backlog", "err", err)
+					}
+				}
 			}
 		}
 	})
@@ -110,6 +225,15 @@ func (r *Relay) Start(ctx context.Context) error {
 	return nil
 }
 
+func (r *Relay) recordBacklog(msg *broadcaster.BroadcastMessage) {
+	if r.backlog == nil {
+		return
+	}
+	if err := r.backlog.append(msg, time.Now()); err != nil {
+		log.Error("failed to write relay backlog", "err", err)
+	}
+}
+
 func (r *Relay) GetListenerAddr() net.Addr {
 	return r.broadcaster.ListenerAddr()
 }
@@ -119,5 +243,18 @@ func (r *Relay) StopAndWait() {
 	for _, client := range r.broadcastClients {
 		client.StopAndWait()
 	}
+	if r.grpcFeed != nil {
+		r.grpcFeed.StopAndWait()
+	}
 	r.broadcaster.StopAndWait()
+	if r.backlog != nil {
+		if err := r.backlog.Close(); err != nil {
+			log.Warn("failed to close relay backlog", "err", err)
+		}
+	}
+	if r.cluster != nil {
+		if err := r.cluster.Close(); err != nil {
+			log.Warn("failed to close relay cluster connection", "err", err)
+		}
+	}
 }
@@ -0,0 +1,132 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+)
+
+// setBatchPosterAuthorized authorizes the sequencer to post batches, or revokes it so every batch it posts reverts.
+func setBatchPosterAuthorized(t *testing.T, ctx context.Context, l1info info, l1client *ethclient.Client, authorized bool) {
+	t.Helper()
+	seqInbox, err := bridgegen.NewSequencerInbox(l1info.GetAddress("SequencerInbox"), l1client)
+	Require(t, err)
+	ownerOpts := l1info.GetDefaultTransactOpts("RollupOwner", ctx)
+	tx, err := seqInbox.SetIsBatchPoster(&ownerOpts, l1info.GetAddress("Sequencer"), authorized)
+	Require(t, err)
+	_, err = EnsureTxSucceeded(ctx, l1client, tx)
+	Require(t, err)
+}
+
+func TestEstimateBatchPostingCost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l2info, node, l2client, l2stack, l1info, _, l1client, l1stack := CreateTestNodeOnL1(t, ctx, true)
+	defer requireClose(t, l1stack)
+	defer requireClose(t, l2stack)
+
+	seqInboxAddr := l1info.GetAddress("SequencerInbox")
+	seqInbox, err := bridgegen.NewSequencerInbox(seqInboxAddr, l1client)
+	Require(t, err)
+	callOpts := &bind.CallOpts{Context: ctx}
+
+	// Hold back batches while building up a backlog, once the node has read those already posted
+	setBatchPosterAuthorized(t, ctx, l1info, l1client, false)
+	l1BatchCount, err := seqInbox.BatchCount(callOpts)
+	Require(t, err)
+	for i := 0; ; i++ {
+		batchCount, err := node.InboxTracker.GetBatchCount()
+		Require(t, err)
+		if batchCount == l1BatchCount.Uint64() {
+			break
+		}
+		if i >= 100 {
+			Fail(t, "inbox reader didn't catch up with", l1BatchCount, "batches")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	l1Block, err := l1client.BlockNumber(ctx)
+	Require(t, err)
+
+	const txCount = 5
+	l2info.GenerateAccount("User2")
+	for i := 0; i < txCount; i++ {
+		tx := l2info.PrepareTx("Owner", "User2", l2info.TransferGas, big.NewInt(1e12), nil)
+		Require(t, l2client.SendTransaction(ctx, tx))
+		_, err = EnsureTxSucceeded(ctx, l2client, tx)
+		Require(t, err)
+	}
+
+	rpcClient, err := l2stack.Attach()
+	Require(t, err)
+	var estimate arbnode.BatchPostingCostEstimate
+	Require(t, rpcClient.CallContext(ctx, &estimate, "arb_estimateBatchPostingCost"))
+	if estimate.BatchSequenceNumber != l1BatchCount.Uint64() {
+		Fail(t, "estimated batch", estimate.BatchSequenceNumber, "but the next batch is", l1BatchCount)
+	}
+	if estimate.Messages != estimate.BacklogMessages || estimate.Transactions != txCount {
+		Fail(t, "estimate doesn't cover the backlog", estimate.Messages, estimate.BacklogMessages, estimate.Transactions)
+	}
+	if estimate.Cost.ToInt().Cmp(new(big.Int).Mul(estimate.L1BaseFee.ToInt(), new(big.Int).SetUint64(estimate.CalldataGas))) != 0 {
+		Fail(t, "cost isn't the calldata gas at the L1 base fee", estimate.Cost)
+	}
+
+	// The batch posted once the poster is authorized again is the one estimated
+	setBatchPosterAuthorized(t, ctx, l1info, l1client, true)
+	nodeSeqInbox, err := arbnode.NewSequencerInbox(l1client, seqInboxAddr, 0)
+	Require(t, err)
+	var batch *arbnode.SequencerInboxBatch
+	for i := 0; batch == nil; i++ {
+		if i >= 100 {
+			Fail(t, "estimated batch not posted")
+		}
+		time.Sleep(100 * time.Millisecond)
+		latest, err := l1client.BlockNumber(ctx)
+		Require(t, err)
+		batches, err := nodeSeqInbox.LookupBatchesInRange(ctx, new(big.Int).SetUint64(l1Block), new(big.Int).SetUint64(latest))
+		Require(t, err)
+		for _, b := range batches {
+			if b.SequenceNumber == estimate.BatchSequenceNumber {
+				batch = b
+			}
+		}
+	}
+	data, err := batch.GetData(ctx, l1client)
+	Require(t, err)
+	if estimate.CompressedSize != uint64(len(data)) {
+		Fail(t, "estimated a compressed size of", estimate.CompressedSize, "but posted", len(data), "bytes")
+	}
+
+	block, err := l1client.BlockByHash(ctx, batch.BlockHash)
+	Require(t, err)
+	var postTx *types.Transaction
+	for _, tx := range block.Transactions() {
+		if tx.To() != nil && *tx.To() == seqInboxAddr {
+			postTx = tx
+		}
+	}
+	if postTx == nil {
+		Fail(t, "batch posting transaction not found in block", block.Number())
+	}
+	intrinsicGas, err := core.IntrinsicGas(postTx.Data(), nil, false, true, true)
+	Require(t, err)
+	// The estimate prices the call's ABI encoding as if none of it were zero bytes
+	maxOverhead := uint64(4+32*6) * params.TxDataNonZeroGasEIP2028
+	if estimate.CalldataGas < intrinsicGas || estimate.CalldataGas > intrinsicGas+maxOverhead {
+		Fail(t, "estimated", estimate.CalldataGas, "calldata gas, but the batch used", intrinsicGas)
+	}
+}
@@ -260,8 +260,28 @@ func CreateTestNodeOnL1WithConfig(
 ) (
 	l2info info, node *arbnode.Node, l2client *ethclient.Client, l2stack *node.Node, l1info info,
 	l1backend *eth.Ethereum, l1client *ethclient.Client, l1stack *node.Node,
+) {
+	return createTestNodeOnL1WithWrappedL1(t, ctx, isSequencer, nodeConfig, chainConfig, nil)
+}
+
+// createTestNodeOnL1WithWrappedL1 is CreateTestNodeOnL1WithConfig, but the node reads L1 through the client
+// wrapL1 returns, if it's set.
+func createTestNodeOnL1WithWrappedL1(
+	t *testing.T,
+	ctx context.Context,
+	isSequencer bool,
+	nodeConfig *arbnode.Config,
+	chainConfig *params.ChainConfig,
+	wrapL1 func(*ethclient.Client, *eth.Ethereum) arbutil.L1Interface,
+) (
+	l2info info, node *arbnode.Node, l2client *ethclient.Client, l2stack *node.Node, l1info info,
+	l1backend *eth.Ethereum, l1client *ethclient.Client, l1stack *node.Node,
 ) {
 	l1info, l1client, l1backend, l1stack = CreateTestL1BlockChain(t, nil)
+	var nodeL1 arbutil.L1Interface = l1client
+	if wrapL1 != nil {
+		nodeL1 = wrapL1(l1client, l1backend)
+	}
 	var l2chainDb ethdb.Database
 	var l2arbDb ethdb.Database
 	var l2blockchain *core.BlockChain
@@ -278,7 +298,7 @@ func CreateTestNodeOnL1WithConfig(
 		nodeConfig.Sequencer.Enable = false
 		nodeConfig.DelayedSequencer.Enable = false
 	}
-	node, err := arbnode.CreateNode(ctx, l2stack, l2chainDb, l2arbDb, nodeConfig, l2blockchain, l1client, addresses, sequencerTxOptsPtr, nil)
+	node, err := arbnode.CreateNode(ctx, l2stack, l2chainDb, l2arbDb, nodeConfig, l2blockchain, nodeL1, addresses, sequencerTxOptsPtr, nil)
 
 	Require(t, err)
 	Require(t, l2stack.Start())
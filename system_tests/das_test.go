@@ -1,13 +1,16 @@
 package arbtest
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -395,6 +398,7 @@ func TestDASComplexConfigAndRestMirror(t *testing.T) {
 	l2clientC, _, l2stackC := Create2ndNodeWithConfig(t, ctx, nodeA, l1stack, &l2info.ArbInitData, l1NodeConfigC)
 
 	checkBatchPosting(t, ctx, l1client, l2clientA, l1info, l2info, big.NewInt(1e12), l2clientB, l2clientC)
+	checkDASMirror(t, ctx, "http://"+restLis.Addr().String(), fileDataDir, l1Reader, addresses.SequencerInbox)
 
 	requireClose(t, l2stackA)
 	requireClose(t, l2stackB)
@@ -408,6 +412,55 @@ func TestDASComplexConfigAndRestMirror(t *testing.T) {
 
 }
 
+// checkDASMirror mirrors the batch data posted to L1 from the REST server into a new store, as a new committee
+// member bootstrapping from the existing ones would, and checks it ends up with all the source's data.
+func checkDASMirror(t *testing.T, ctx context.Context, sourceUrl string, sourceDir string, l1Reader *headerreader.HeaderReader, seqInboxAddr common.Address) {
+	mirrorDir := t.TempDir()
+	mirrorStorage, err := das.NewLocalFileStorageService(mirrorDir)
+	Require(t, err)
+	mirrorConfig := das.DefaultMirrorConfig
+	mirrorConfig.Enable = true
+	mirrorConfig.Urls = []string{sourceUrl}
+	mirrorConfig.StateFile = filepath.Join(t.TempDir(), "mirror.state")
+	mirror, err := das.NewMirror(ctx, &mirrorConfig, mirrorStorage, l1Reader, seqInboxAddr)
+	Require(t, err)
+	mirror.Start(ctx)
+	defer func() {
+		Require(t, mirror.Close(ctx))
+	}()
+
+	sourceFiles, err := os.ReadDir(sourceDir)
+	Require(t, err)
+	if len(sourceFiles) == 0 {
+		Fail(t, "no batch data to mirror")
+	}
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		missing := 0
+		for _, file := range sourceFiles {
+			mirrored, err := os.ReadFile(filepath.Join(mirrorDir, file.Name()))
+			if errors.Is(err, os.ErrNotExist) {
+				missing++
+				continue
+			}
+			Require(t, err)
+			source, err := os.ReadFile(filepath.Join(sourceDir, file.Name()))
+			Require(t, err)
+			if !bytes.Equal(mirrored, source) {
+				Fail(t, "mirrored data", file.Name(), "differs from the source")
+			}
+		}
+		if missing == 0 {
+			break
+		}
+		if time.Since(start) > 30*time.Second {
+			Fail(t, "mirror is missing", missing, "of", len(sourceFiles), "source entries")
+		}
+	}
+	if _, err := os.Stat(mirrorConfig.StateFile); err != nil {
+		Fail(t, "mirror didn't save its progress", err)
+	}
+}
+
 func enableLogging(logLvl int) {
 	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.Lvl(logLvl))
@@ -0,0 +1,87 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+)
+
+func TestBatchPosterEscapeHatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := arbnode.ConfigDefaultL1Test()
+	conf.BatchPoster.EscapeHatch.Enable = true
+	conf.BatchPoster.EscapeHatch.After = time.Second
+	l2info, _, l2client, l2stack, l1info, _, l1client, l1stack := CreateTestNodeOnL1WithConfig(t, ctx, true, conf, params.ArbitrumDevTestChainConfig())
+	defer requireClose(t, l1stack)
+	defer requireClose(t, l2stack)
+
+	seqInbox, err := bridgegen.NewSequencerInbox(l1info.GetAddress("SequencerInbox"), l1client)
+	Require(t, err)
+	delayedInbox, err := bridgegen.NewInbox(l1info.GetAddress("Inbox"), l1client)
+	Require(t, err)
+	callOpts := &bind.CallOpts{Context: ctx}
+
+	setBatchPosterAuthorized(t, ctx, l1info, l1client, false)
+	batchCount, err := seqInbox.BatchCount(callOpts)
+	Require(t, err)
+
+	l2info.GenerateAccount("User2")
+	tx := l2info.PrepareTx("Owner", "User2", l2info.TransferGas, big.NewInt(1e12), nil)
+	Require(t, l2client.SendTransaction(ctx, tx))
+	_, err = EnsureTxSucceeded(ctx, l2client, tx)
+	Require(t, err)
+	txBytes, err := tx.MarshalBinary()
+	Require(t, err)
+	forwardedMsg := append([]byte{arbos.L2MessageKind_SignedTx}, txBytes...)
+
+	// Once posting has failed for longer than the threshold, the poster sends the unposted message through the delayed inbox
+	forwarded := false
+	for i := 0; i < 100 && !forwarded; i++ {
+		time.Sleep(100 * time.Millisecond)
+		iter, err := delayedInbox.FilterInboxMessageDelivered(&bind.FilterOpts{Context: ctx}, nil)
+		Require(t, err)
+		for iter.Next() {
+			if bytes.Equal(iter.Event.Data, forwardedMsg) {
+				forwarded = true
+			}
+		}
+		Require(t, iter.Error())
+		iter.Close()
+	}
+	if !forwarded {
+		Fail(t, "unposted message not forwarded to the delayed inbox")
+	}
+	newBatchCount, err := seqInbox.BatchCount(callOpts)
+	Require(t, err)
+	if newBatchCount.Cmp(batchCount) != 0 {
+		Fail(t, "batch posted by a revoked poster")
+	}
+
+	// Normal posting resumes once the poster is authorized again
+	setBatchPosterAuthorized(t, ctx, l1info, l1client, true)
+	for i := 0; ; i++ {
+		newBatchCount, err = seqInbox.BatchCount(callOpts)
+		Require(t, err)
+		if newBatchCount.Cmp(batchCount) > 0 {
+			break
+		}
+		if i >= 100 {
+			Fail(t, "batch posting didn't resume")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
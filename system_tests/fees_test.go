@@ -21,6 +21,7 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/offchainlabs/nitro/arbcompress"
 	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbos/l1pricing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -74,6 +75,67 @@ func TestSequencerFeePaid(t *testing.T) {
 	}
 }
 
+func TestPriorityFeeCharged(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l2info, _, l2client, l2stack := CreateTestL2(t, ctx)
+	defer requireClose(t, l2stack)
+
+	ownerAuth := l2info.GetDefaultTransactOpts("Owner", ctx)
+	callOpts := l2info.GetDefaultCallOpts("Owner", ctx)
+	arbOwner, err := precompilesgen.NewArbOwner(common.HexToAddress("0x70"), l2client)
+	Require(t, err)
+	arbOwnerPublic, err := precompilesgen.NewArbOwnerPublic(common.HexToAddress("0x6b"), l2client)
+	Require(t, err)
+	networkFeeAccount, err := arbOwnerPublic.GetNetworkFeeAccount(callOpts)
+	Require(t, err)
+
+	tx, err := arbOwner.ScheduleArbOSUpgrade(&ownerAuth, arbos.PriorityFeeArbosVersion, 0)
+	Require(t, err)
+	_, err = EnsureTxSucceeded(ctx, l2client, tx)
+	Require(t, err)
+	// the upgrade happens at the start of the next block
+	TransferBalance(t, "Owner", "Owner", common.Big0, l2info, l2client, ctx)
+
+	l2info.GenerateAccount("Tipper")
+	l2info.GenerateAccount("Recipient")
+	TransferBalance(t, "Faucet", "Tipper", big.NewInt(1e18), l2info, l2client, ctx)
+	tipperBefore := GetBalance(t, ctx, l2client, l2info.GetAddress("Tipper"))
+	networkBefore := GetBalance(t, ctx, l2client, networkFeeAccount)
+
+	tip := big.NewInt(params.GWei)
+	recipient := l2info.GetAddress("Recipient")
+	tipper := l2info.GetInfoWithPrivKey("Tipper")
+	tx = l2info.SignTxAs("Tipper", &types.DynamicFeeTx{
+		To:        &recipient,
+		Gas:       l2info.TransferGas,
+		GasTipCap: tip,
+		GasFeeCap: arbmath.BigAdd(arbmath.BigMulByUint(GetBaseFee(t, l2client, ctx), 2), tip),
+		Value:     common.Big0,
+		Nonce:     tipper.Nonce,
+	})
+	tipper.Nonce++
+	Require(t, l2client.SendTransaction(ctx, tx))
+	receipt, err := EnsureTxSucceeded(ctx, l2client, tx)
+	Require(t, err)
+	header, err := l2client.HeaderByHash(ctx, receipt.BlockHash)
+	Require(t, err)
+
+	tipperPaid := arbmath.BigSub(tipperBefore, GetBalance(t, ctx, l2client, l2info.GetAddress("Tipper")))
+	if !arbmath.BigEquals(tipperPaid, arbmath.BigMulByUint(arbmath.BigAdd(header.BaseFee, tip), receipt.GasUsed)) {
+		Fail(t, "sender paid", tipperPaid, "rather than the base fee plus the priority fee for", receipt.GasUsed, "gas")
+	}
+	networkRevenue := arbmath.BigSub(GetBalance(t, ctx, l2client, networkFeeAccount), networkBefore)
+	expectedRevenue := arbmath.BigAdd(
+		arbmath.BigMulByUint(header.BaseFee, receipt.GasUsed-receipt.GasUsedForL1),
+		arbmath.BigMulByUint(tip, receipt.GasUsed),
+	)
+	if !arbmath.BigEquals(networkRevenue, expectedRevenue) {
+		Fail(t, "network received", networkRevenue, "but expected", expectedRevenue, "including the priority fee")
+	}
+}
+
 func testSequencerPriceAdjustsFrom(t *testing.T, initialEstimate uint64) {
 	t.Parallel()
 
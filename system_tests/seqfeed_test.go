@@ -13,6 +13,7 @@ import (
 
 	"github.com/offchainlabs/nitro/arbnode"
 	"github.com/offchainlabs/nitro/broadcastclient"
+	"github.com/offchainlabs/nitro/grpcfeed"
 	"github.com/offchainlabs/nitro/relay"
 	"github.com/offchainlabs/nitro/wsbroadcastserver"
 )
@@ -24,11 +25,13 @@ func newBroadcasterConfigTest(port string) *wsbroadcastserver.BroadcasterConfig
 	return &config
 }
 
+// newBroadcastClientConfigTest starts from the default config, so the feed tests cover a stock consumer of an
+// unsigned feed.
 func newBroadcastClientConfigTest(port int) *broadcastclient.BroadcastClientConfig {
-	return &broadcastclient.BroadcastClientConfig{
-		URLs:    []string{fmt.Sprintf("ws://localhost:%d/feed", port)},
-		Timeout: 20 * time.Second,
-	}
+	config := broadcastclient.DefaultBroadcastClientConfig
+	config.URLs = []string{fmt.Sprintf("ws://localhost:%d/feed", port)}
+	config.Timeout = 20 * time.Second
+	return &config
 }
 
 func TestSequencerFeed(t *testing.T) {
@@ -80,8 +83,9 @@ func TestRelayedSequencerFeed(t *testing.T) {
 	port := nodeA.BroadcastServer.ListenerAddr().(*net.TCPAddr).Port
 	relayClientConf := *newBroadcastClientConfigTest(port)
 
-	relay := relay.NewRelay(relayServerConf, relayClientConf)
-	err := relay.Start(ctx)
+	relay, err := relay.NewRelay(relayServerConf, relayClientConf, relay.DefaultBacklogConfig, grpcfeed.DefaultConfig, relay.DefaultClusterConfig)
+	Require(t, err)
+	err = relay.Start(ctx)
 	Require(t, err)
 	defer relay.StopAndWait()
 
@@ -213,3 +217,66 @@ func TestLyingSequencer(t *testing.T) {
 func TestLyingSequencerLocalDAS(t *testing.T) {
 	testLyingSequencer(t, "files")
 }
+
+func TestFeedOnlyFollowerWithoutL1(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seqNodeConfig := arbnode.ConfigDefaultL2Test()
+	seqNodeConfig.Feed.Output = *newBroadcasterConfigTest("0")
+	// without taking ownership, the sequencer broadcasts nothing until the transfer below
+	l2info1, nodeA, client1, l2stackA := CreateTestL2WithConfig(t, ctx, nil, seqNodeConfig, false)
+	defer requireClose(t, l2stackA)
+
+	clientNodeConfig := arbnode.ConfigDefaultL2Test()
+	port := nodeA.BroadcastServer.ListenerAddr().(*net.TCPAddr).Port
+	clientNodeConfig.Feed.Input = *newBroadcastClientConfigTest(port)
+	_, _, client2, l2stackB := CreateTestL2WithConfig(t, ctx, nil, clientNodeConfig, false)
+	defer requireClose(t, l2stackB)
+
+	rpcClient, err := l2stackB.Attach()
+	Require(t, err)
+	syncStatus := func() arbnode.SyncStatus {
+		var status arbnode.SyncStatus
+		Require(t, rpcClient.CallContext(ctx, &status, "arb_syncStatus"))
+		return status
+	}
+
+	status := syncStatus()
+	if status.L1Connected || !status.FeedOnly || status.DataFinality != "unfinalized" {
+		Fail(t, "unexpected feed-only status", status)
+	}
+	if len(status.TrustAssumptions) == 0 {
+		Fail(t, "feed-only status doesn't list its trust assumptions")
+	}
+	if received, ok := status.Progress["feedMessagesReceived"]; !ok || received != false {
+		Fail(t, "reported synced before the feed delivered anything", status.Progress)
+	}
+	if status.UnfinalizedMessageCount != status.MessageCount {
+		Fail(t, "init message not marked unfinalized", status.UnfinalizedMessageCount, "of", status.MessageCount)
+	}
+
+	l2info1.GenerateAccount("User2")
+	tx := l2info1.PrepareTx("Owner", "User2", l2info1.TransferGas, big.NewInt(1e12), nil)
+	err = client1.SendTransaction(ctx, tx)
+	Require(t, err)
+	_, err = EnsureTxSucceeded(ctx, client1, tx)
+	Require(t, err)
+	_, err = WaitForTx(ctx, client2, tx.Hash(), time.Second*5)
+	Require(t, err)
+
+	for i := 0; ; i++ {
+		status = syncStatus()
+		if len(status.Progress) == 0 {
+			break
+		}
+		if i >= 50 {
+			Fail(t, "feed-only node never reported synced", status.Progress)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if status.MessageCount < 2 || status.UnfinalizedMessageCount != status.MessageCount {
+		Fail(t, "feed messages not marked unfinalized", status.UnfinalizedMessageCount, "of", status.MessageCount)
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -24,8 +26,10 @@ import (
 	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbos/l2pricing"
 	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
 	"github.com/offchainlabs/nitro/util"
+	"github.com/offchainlabs/nitro/util/reorgsim"
 )
 
 type blockTestState struct {
@@ -38,8 +42,12 @@ type blockTestState struct {
 
 const seqInboxTestIters = 40
 
-func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
-	t.Parallel()
+// testSequencerInboxReaderImpl posts batches and reorgs L1 under a node, checking it follows. With anomalies, the
+// node also reads L1 through a client which duplicates and shuffles logs and serves stale headers. The seed picks
+// the batches, reorgs and anomalies.
+func testSequencerInboxReaderImpl(t *testing.T, validator bool, anomalies bool, seed int64) {
+	t.Logf("Using seed %v", seed)
+	rng := rand.New(rand.NewSource(seed))
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	conf := arbnode.ConfigDefaultL1Test()
@@ -48,13 +56,20 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 		conf.BlockValidator.Enable = true
 		conf.BlockValidator.ConcurrentRunsLimit = 16
 	}
-	l2Info, arbNode, _, l2stack, l1Info, l1backend, l1Client, l1stack := CreateTestNodeOnL1WithConfig(t, ctx, false, conf, params.ArbitrumDevTestChainConfig())
+	var l1Sim *reorgsim.Backend
+	wrapL1 := func(client *ethclient.Client, backend *eth.Ethereum) arbutil.L1Interface {
+		l1Sim = reorgsim.NewBackend(client, backend.BlockChain())
+		if anomalies {
+			l1Sim.SetDuplicateLogs(true)
+			l1Sim.SetShuffleLogs(rand.New(rand.NewSource(rng.Int63())))
+		}
+		return l1Sim
+	}
+	l2Info, arbNode, _, l2stack, l1Info, _, l1Client, l1stack := createTestNodeOnL1WithWrappedL1(t, ctx, false, conf, params.ArbitrumDevTestChainConfig(), wrapL1)
 	l2Backend := arbNode.Backend
 	defer requireClose(t, l1stack)
 	defer requireClose(t, l2stack)
 
-	l1BlockChain := l1backend.BlockChain()
-
 	seqInbox, err := bridgegen.NewSequencerInbox(l1Info.GetAddress("SequencerInbox"), l1Client)
 	Require(t, err)
 	seqOpts := l1Info.GetDefaultTransactOpts("Sequencer", ctx)
@@ -100,7 +115,7 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 
 	for i := 1; i < seqInboxTestIters; i++ {
 		if i%10 == 0 {
-			reorgTo := rand.Int() % len(blockStates)
+			reorgTo := rng.Int() % len(blockStates)
 			if reorgTo == 0 {
 				reorgTo = 1
 			}
@@ -128,8 +143,7 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 				Fail(t, "Less than 65 blocks of difference between current block", currentHeader.Number, "and target", reorgTargetNumber)
 			}
 			t.Logf("Reorganizing to L1 block %v", reorgTargetNumber)
-			reorgTarget := l1BlockChain.GetBlockByNumber(reorgTargetNumber)
-			err = l1BlockChain.ReorgToOldBlock(reorgTarget)
+			_, err = l1Sim.Reorg(currentHeader.Number.Uint64() - reorgTargetNumber)
 			Require(t, err)
 			blockStates = blockStates[:(reorgTo + 1)]
 
@@ -155,11 +169,11 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 			state.nonces = newNonces
 
 			batchBuffer := bytes.NewBuffer([]byte{})
-			numMessages := 1 + rand.Int()%5
+			numMessages := 1 + rng.Int()%5
 			for j := 0; j < numMessages; j++ {
-				sourceNum := rand.Int() % len(state.accounts)
+				sourceNum := rng.Int() % len(state.accounts)
 				source := state.accounts[sourceNum]
-				amount := new(big.Int).SetUint64(uint64(rand.Int()) % state.balances[source].Uint64())
+				amount := new(big.Int).SetUint64(uint64(rng.Int()) % state.balances[source].Uint64())
 				reserveAmount := new(big.Int).SetUint64(l2pricing.InitialBaseFeeWei * 100000000)
 				if state.balances[source].Cmp(new(big.Int).Add(amount, reserveAmount)) < 0 {
 					// Leave enough funds for gas
@@ -175,7 +189,7 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 					state.accounts = append(state.accounts, dest)
 					state.balances[dest] = big.NewInt(0)
 				} else {
-					dest = state.accounts[rand.Int()%len(state.accounts)]
+					dest = state.accounts[rng.Int()%len(state.accounts)]
 				}
 
 				rawTx := &types.DynamicFeeTx{
@@ -242,6 +256,9 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 		}
 
 		t.Logf("Iteration %v: state %v block %v", i, len(blockStates)-1, blockStates[len(blockStates)-1].l2BlockNumber)
+		if anomalies {
+			l1Sim.InjectStaleHeaders(2)
+		}
 
 		for i := 0; ; i++ {
 			batchCount, err := seqInbox.BatchCount(&bind.CallOpts{})
@@ -298,5 +315,16 @@ func testSequencerInboxReaderImpl(t *testing.T, validator bool) {
 }
 
 func TestSequencerInboxReader(t *testing.T) {
-	testSequencerInboxReaderImpl(t, false)
+	t.Parallel()
+	testSequencerInboxReaderImpl(t, false, false, rand.Int63())
+}
+
+// FuzzSequencerInboxReaderL1Anomalies runs the reorg test against an L1 client duplicating and shuffling logs
+// and serving stale headers. Without -fuzz, only the seeds below are run.
+func FuzzSequencerInboxReaderL1Anomalies(f *testing.F) {
+	f.Add(int64(1))
+	f.Add(int64(2))
+	f.Fuzz(func(t *testing.T, seed int64) {
+		testSequencerInboxReaderImpl(t, false, true, seed)
+	})
 }
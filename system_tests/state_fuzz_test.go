@@ -36,7 +36,10 @@ func BuildBlock(
 	if lastBlockHeader != nil {
 		delayedMessagesRead = lastBlockHeader.Nonce.Uint64()
 	}
-	inboxMultiplexer := arbstate.NewInboxMultiplexer(inbox, delayedMessagesRead, nil)
+	arbosVersion := func() (uint64, error) {
+		return arbosState.ArbOSVersion(statedb), nil
+	}
+	inboxMultiplexer := arbstate.NewInboxMultiplexer(inbox, delayedMessagesRead, nil, arbosVersion)
 
 	ctx := context.Background()
 	message, err := inboxMultiplexer.Pop(ctx)
@@ -6,8 +6,12 @@
 
 package arbtest
 
-import "testing"
+import (
+	"math/rand"
+	"testing"
+)
 
 func TestBlockValidatorReorg(t *testing.T) {
-	testSequencerInboxReaderImpl(t, true)
+	t.Parallel()
+	testSequencerInboxReaderImpl(t, true, false, rand.Int63())
 }
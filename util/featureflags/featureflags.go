@@ -0,0 +1,218 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package featureflags gates risky behaviors behind flags which can be enabled per node,
+// or rolled out to a percentage of a fleet, and changed without a redeploy via a remote source.
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+// Enables the batch poster's dynamic sizing, with its configured parameters.
+const AdaptiveBatching = "adaptive-batching"
+
+type Config struct {
+	Enable         []string      `koanf:"enable"`
+	Disable        []string      `koanf:"disable"`
+	Rollout        []string      `koanf:"rollout"`
+	NodeId         string        `koanf:"node-id"`
+	RemoteURL      string        `koanf:"remote-url"`
+	PollInterval   time.Duration `koanf:"poll-interval"`
+	RequestTimeout time.Duration `koanf:"request-timeout"`
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".enable", DefaultConfig.Enable, "feature flags to enable on this node, overriding any rollout")
+	f.StringSlice(prefix+".disable", DefaultConfig.Disable, "feature flags to disable on this node, overriding any rollout")
+	f.StringSlice(prefix+".rollout", DefaultConfig.Rollout, "feature flags to enable on a percentage of nodes, as name=percent")
+	f.String(prefix+".node-id", DefaultConfig.NodeId, "identity used to place this node in rollouts (default is the hostname)")
+	f.String(prefix+".remote-url", DefaultConfig.RemoteURL, "URL of a JSON object mapping feature flags to rollout percentages, taking precedence over configured rollouts")
+	f.Duration(prefix+".poll-interval", DefaultConfig.PollInterval, "how often to poll the remote flag source")
+	f.Duration(prefix+".request-timeout", DefaultConfig.RequestTimeout, "timeout for a single request to the remote flag source")
+}
+
+var DefaultConfig = Config{
+	Enable:         []string{},
+	Disable:        []string{},
+	Rollout:        []string{},
+	NodeId:         "",
+	RemoteURL:      "",
+	PollInterval:   time.Minute,
+	RequestTimeout: 10 * time.Second,
+}
+
+// Flags answers whether features are enabled on this node. Explicitly enabled or disabled flags
+// always win; otherwise a flag is enabled if this node's bucket falls within its rollout percentage.
+// A node's bucket for a flag is stable, so raising a percentage only ever adds nodes to a rollout.
+type Flags struct {
+	stopwaiter.StopWaiter
+	config   *Config
+	nodeId   string
+	client   *http.Client
+	forced   map[string]bool
+	configed map[string]float64
+
+	mutex  sync.RWMutex
+	remote map[string]float64
+}
+
+func New(config *Config) (*Flags, error) {
+	flags := &Flags{
+		config:   config,
+		nodeId:   config.NodeId,
+		client:   &http.Client{Timeout: config.RequestTimeout},
+		forced:   make(map[string]bool),
+		configed: make(map[string]float64),
+	}
+	if flags.nodeId == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		flags.nodeId = hostname
+	}
+	for _, name := range config.Enable {
+		flags.forced[name] = true
+	}
+	for _, name := range config.Disable {
+		if flags.forced[name] {
+			return nil, fmt.Errorf("feature flag %v both enabled and disabled", name)
+		}
+		flags.forced[name] = false
+	}
+	for _, rollout := range config.Rollout {
+		name, percentString, found := strings.Cut(rollout, "=")
+		if !found {
+			return nil, fmt.Errorf("feature flag rollout \"%v\" isn't of the form name=percent", rollout)
+		}
+		percent, err := strconv.ParseFloat(percentString, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid feature flag rollout percentage \"%v\"", percentString)
+		}
+		flags.configed[name] = percent
+	}
+	return flags, nil
+}
+
+// bucket places the node in [0, 100) for a flag.
+func (f *Flags) bucket(name string) float64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(f.nodeId + "/" + name))
+	return float64(hash.Sum64()%10000) / 100
+}
+
+// Enabled returns whether a feature is enabled. It's safe to call on a nil Flags, which has nothing enabled.
+func (f *Flags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	if forced, ok := f.forced[name]; ok {
+		return forced
+	}
+	f.mutex.RLock()
+	percent, ok := f.remote[name]
+	f.mutex.RUnlock()
+	if !ok {
+		percent, ok = f.configed[name]
+	}
+	return ok && f.bucket(name) < percent
+}
+
+// All returns every flag this node knows of and whether it's enabled.
+func (f *Flags) All() map[string]bool {
+	all := make(map[string]bool)
+	if f == nil {
+		return all
+	}
+	var names []string
+	for name := range f.forced {
+		names = append(names, name)
+	}
+	for name := range f.configed {
+		names = append(names, name)
+	}
+	f.mutex.RLock()
+	for name := range f.remote {
+		names = append(names, name)
+	}
+	f.mutex.RUnlock()
+	for _, name := range names {
+		all[name] = f.Enabled(name)
+	}
+	return all
+}
+
+func (f *Flags) fetchRemote(ctx context.Context) (map[string]float64, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, f.config.RemoteURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feature flag source returned status %v", response.StatusCode)
+	}
+	var remote map[string]float64
+	err = json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&remote)
+	if err != nil {
+		return nil, err
+	}
+	for name, percent := range remote {
+		if percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid rollout percentage %v for feature flag %v", percent, name)
+		}
+	}
+	return remote, nil
+}
+
+func (f *Flags) updateRemote(ctx context.Context) error {
+	remote, err := f.fetchRemote(ctx)
+	if err != nil {
+		return err
+	}
+	before := f.All()
+	f.mutex.Lock()
+	f.remote = remote
+	f.mutex.Unlock()
+	for name, enabled := range f.All() {
+		if before[name] != enabled {
+			log.Info("feature flag changed", "flag", name, "enabled", enabled)
+		}
+	}
+	return nil
+}
+
+// Start polls the remote flag source, if one is configured.
+func (f *Flags) Start(ctxIn context.Context) {
+	f.StopWaiter.Start(ctxIn)
+	if f.config.RemoteURL == "" {
+		return
+	}
+	f.CallIteratively(func(ctx context.Context) time.Duration {
+		err := f.updateRemote(ctx)
+		if err != nil {
+			// keep using the last known flags
+			log.Warn("failed to update feature flags", "err", err)
+		}
+		return f.config.PollInterval
+	})
+}
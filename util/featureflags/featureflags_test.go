@@ -0,0 +1,74 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offchainlabs/nitro/util/testhelpers"
+)
+
+func TestRollout(t *testing.T) {
+	enabledAt := func(percent int) int {
+		enabled := 0
+		for i := 0; i < 1000; i++ {
+			config := DefaultConfig
+			config.NodeId = fmt.Sprintf("node-%v", i)
+			config.Rollout = []string{fmt.Sprintf("feature=%v", percent)}
+			flags, err := New(&config)
+			testhelpers.RequireImpl(t, err)
+			if flags.Enabled("feature") {
+				enabled++
+			}
+		}
+		return enabled
+	}
+	if enabledAt(0) != 0 || enabledAt(100) != 1000 {
+		testhelpers.FailImpl(t, "rollout extremes not respected")
+	}
+	if half := enabledAt(50); half < 400 || half > 600 {
+		testhelpers.FailImpl(t, "50% rollout enabled on", half, "of 1000 nodes")
+	}
+
+	var nilFlags *Flags
+	if nilFlags.Enabled("feature") {
+		testhelpers.FailImpl(t, "nil flags have a feature enabled")
+	}
+}
+
+func TestRemoteOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"remote": 100, "forced-off": 100, "configured": 0}`))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig
+	config.NodeId = "node"
+	config.RemoteURL = server.URL
+	config.Disable = []string{"forced-off"}
+	config.Enable = []string{"forced-on"}
+	config.Rollout = []string{"configured=100"}
+	flags, err := New(&config)
+	testhelpers.RequireImpl(t, err)
+	if !flags.Enabled("configured") || flags.Enabled("remote") {
+		testhelpers.FailImpl(t, "unexpected flags before remote update", flags.All())
+	}
+	testhelpers.RequireImpl(t, flags.updateRemote(context.Background()))
+
+	expected := map[string]bool{"remote": true, "forced-off": false, "forced-on": true, "configured": false}
+	for name, enabled := range expected {
+		if flags.Enabled(name) != enabled {
+			testhelpers.FailImpl(t, "flag", name, "enabled", flags.Enabled(name), "expected", enabled)
+		}
+	}
+
+	config.Enable = []string{"forced-off"}
+	if _, err := New(&config); err == nil {
+		testhelpers.FailImpl(t, "flag both enabled and disabled accepted")
+	}
+}
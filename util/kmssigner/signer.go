@@ -0,0 +1,109 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package kmssigner signs with a secp256k1 key held by a KMS or HSM, reached over the KeyManagement gRPC
+// service, so the private key never lives on the node machine.
+package kmssigner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc"
+)
+
+var secp256k1N = crypto.S256().Params().N
+var secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+
+// Signer signs digests with a key of the KMS, returning [R || S || V] signatures with V of 0 or 1, like crypto.Sign.
+type Signer struct {
+	conn      *grpc.ClientConn // nil if the client wasn't dialed by the signer
+	client    KeyManagementClient
+	keyID     string
+	publicKey []byte
+	address   common.Address
+}
+
+// Dial connects to the KMS at target and loads the public key of the key to sign with.
+func Dial(ctx context.Context, target string, keyID string, opts ...grpc.DialOption) (*Signer, error) {
+	conn, err := grpc.DialContext(ctx, target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to KMS %v: %w", target, err)
+	}
+	signer, err := NewSigner(ctx, NewKeyManagementClient(conn), keyID)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	signer.conn = conn
+	return signer, nil
+}
+
+func NewSigner(ctx context.Context, client KeyManagementClient, keyID string) (*Signer, error) {
+	response, err := client.GetPublicKey(ctx, &GetPublicKeyRequest{KeyId: keyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the public key of KMS key %v: %w", keyID, err)
+	}
+	publicKey, err := crypto.UnmarshalPubkey(response.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("KMS key %v has an invalid public key: %w", keyID, err)
+	}
+	return &Signer{
+		client:    client,
+		keyID:     keyID,
+		publicKey: crypto.FromECDSAPub(publicKey),
+		address:   crypto.PubkeyToAddress(*publicKey),
+	}, nil
+}
+
+func (s *Signer) Address() common.Address {
+	return s.address
+}
+
+// SignHash signs a 32 byte digest with the KMS key.
+func (s *Signer) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	if len(hash) != common.HashLength {
+		return nil, fmt.Errorf("can't sign a %v byte digest", len(hash))
+	}
+	response, err := s.client.Sign(ctx, &SignRequest{KeyId: s.keyID, Digest: hash})
+	if err != nil {
+		return nil, fmt.Errorf("KMS failed to sign with key %v: %w", s.keyID, err)
+	}
+	return s.normalize(hash, response.Signature)
+}
+
+// normalize turns the KMS's signature into one Ethereum accepts, with the lower of the two valid S values
+// and the V recovering the key's public key, which KMSes generally don't return.
+func (s *Signer) normalize(hash []byte, signature []byte) ([]byte, error) {
+	if len(signature) != 64 && len(signature) != 65 {
+		return nil, fmt.Errorf("KMS returned a %v byte signature", len(signature))
+	}
+	sValue := new(big.Int).SetBytes(signature[32:64])
+	if sValue.Cmp(secp256k1HalfN) > 0 {
+		sValue.Sub(secp256k1N, sValue)
+	}
+	normalized := make([]byte, 65)
+	copy(normalized[:32], signature[:32])
+	sValue.FillBytes(normalized[32:64])
+	for v := byte(0); v < 2; v++ {
+		normalized[64] = v
+		recovered, err := crypto.Ecrecover(hash, normalized)
+		if err == nil && bytes.Equal(recovered, s.publicKey) {
+			return normalized, nil
+		}
+	}
+	return nil, errors.New("KMS signature doesn't match the key's public key")
+}
+
+// Close closes the connection to the KMS, if the signer dialed it.
+func (s *Signer) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,94 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package kmssigner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc"
+
+	"github.com/offchainlabs/nitro/util/testhelpers"
+)
+
+// testKMS signs like a KMS does, without V and with whichever S the signature came out with.
+type testKMS struct {
+	keys  map[string]*ecdsa.PrivateKey
+	highS bool
+}
+
+func (k *testKMS) GetPublicKey(ctx context.Context, in *GetPublicKeyRequest, opts ...grpc.CallOption) (*GetPublicKeyResponse, error) {
+	key, ok := k.keys[in.KeyId]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &GetPublicKeyResponse{PublicKey: crypto.FromECDSAPub(&key.PublicKey)}, nil
+}
+
+func (k *testKMS) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	key, ok := k.keys[in.KeyId]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	signature, err := crypto.Sign(in.Digest, key)
+	if err != nil {
+		return nil, err
+	}
+	if k.highS {
+		s := new(big.Int).SetBytes(signature[32:64])
+		new(big.Int).Sub(secp256k1N, s).FillBytes(signature[32:64])
+	}
+	return &SignResponse{Signature: signature[:64]}, nil
+}
+
+func TestSignerNormalizesSignatures(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	testhelpers.RequireImpl(t, err)
+	other, err := crypto.GenerateKey()
+	testhelpers.RequireImpl(t, err)
+	kms := &testKMS{keys: map[string]*ecdsa.PrivateKey{"batch-poster": key, "other": other}}
+
+	signer, err := NewSigner(ctx, kms, "batch-poster")
+	testhelpers.RequireImpl(t, err)
+	if signer.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		testhelpers.FailImpl(t, "wrong address", signer.Address())
+	}
+
+	for _, highS := range []bool{false, true} {
+		kms.highS = highS
+		for i := 0; i < 8; i++ {
+			hash := crypto.Keccak256([]byte{byte(i)})
+			signature, err := signer.SignHash(ctx, hash)
+			testhelpers.RequireImpl(t, err)
+			if len(signature) != 65 || signature[64] > 1 {
+				testhelpers.FailImpl(t, "signature not in [R || S || V] form", signature)
+			}
+			if new(big.Int).SetBytes(signature[32:64]).Cmp(secp256k1HalfN) > 0 {
+				testhelpers.FailImpl(t, "high S not normalized")
+			}
+			recovered, err := crypto.SigToPub(hash, signature)
+			testhelpers.RequireImpl(t, err)
+			if crypto.PubkeyToAddress(*recovered) != signer.Address() {
+				testhelpers.FailImpl(t, "signature recovers another address")
+			}
+		}
+	}
+
+	if _, err := signer.SignHash(ctx, []byte("not a digest")); err == nil {
+		testhelpers.FailImpl(t, "signed something other than a digest")
+	}
+	// A signature by another key is rejected
+	signer.keyID = "other"
+	if _, err := signer.SignHash(ctx, crypto.Keccak256([]byte{1})); err == nil {
+		testhelpers.FailImpl(t, "accepted a signature by another key")
+	}
+	if _, err := NewSigner(ctx, kms, "missing"); err == nil {
+		testhelpers.FailImpl(t, "signer created for a missing key")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package openmetrics exports a metrics registry in the OpenMetrics text format, with exemplars
+// tying the slowest recent samples of a histogram to what was being processed, such as a block or
+// a batch, and to the ID of the trace it was processed in.
+package openmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/exp"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+)
+
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// An exemplar is kept until a larger value is recorded, or for this long
+const exemplarWindow = 5 * time.Minute
+
+// OpenMetrics limits the combined length of an exemplar's label names and values
+const maxExemplarLabelsLength = 128
+
+type Exemplar struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+var (
+	exemplarsMutex sync.Mutex
+	exemplars      = make(map[string]*Exemplar)
+)
+
+// RecordExemplar offers a sample of the named histogram as its exemplar, labelled with what produced it,
+// for example {"trace_id": ...} or {"block": ...}. The largest sample of the last few minutes is kept,
+// so that a latency spike on a dashboard links to the request which caused it.
+func RecordExemplar(name string, value float64, labels map[string]string) {
+	length := 0
+	for key, labelValue := range labels {
+		length += len(key) + len(labelValue)
+	}
+	if length > maxExemplarLabelsLength {
+		return
+	}
+	now := time.Now()
+	exemplarsMutex.Lock()
+	defer exemplarsMutex.Unlock()
+	current := exemplars[name]
+	if current == nil || value >= current.Value || now.Sub(current.Timestamp) > exemplarWindow {
+		exemplars[name] = &Exemplar{labels, value, now}
+	}
+}
+
+func getExemplar(name string) *Exemplar {
+	exemplarsMutex.Lock()
+	defer exemplarsMutex.Unlock()
+	return exemplars[name]
+}
+
+// Handler serves the registry's metrics in the OpenMetrics text format.
+func Handler(registry metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		err := Write(w, registry)
+		if err != nil {
+			log.Warn("failed to write OpenMetrics response", "err", err)
+		}
+	})
+}
+
+// Setup starts a metrics server, serving the OpenMetrics format at /metrics alongside the
+// /debug/metrics and /debug/metrics/prometheus endpoints of go-ethereum's exp.Setup.
+func Setup(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(metrics.DefaultRegistry))
+	mux.Handle("/debug/metrics", exp.ExpHandler(metrics.DefaultRegistry))
+	mux.Handle("/debug/metrics/prometheus", prometheus.Handler(metrics.DefaultRegistry))
+	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/metrics", address))
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Error("Failure in running metrics server", "err", err)
+		}
+	}()
+}
+
+var nameReplacer = strings.NewReplacer("/", "_", ".", "_", "-", "_", " ", "_")
+
+func metricName(name string) string {
+	return nameReplacer.Replace(name)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+func formatExemplar(exemplar *Exemplar) string {
+	keys := make([]string, 0, len(exemplar.Labels))
+	for key := range exemplar.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	labels := make([]string, len(keys))
+	for i, key := range keys {
+		labels[i] = fmt.Sprintf("%s=%q", metricName(key), exemplar.Labels[key])
+	}
+	timestamp := float64(exemplar.Timestamp.UnixNano()) / 1e9
+	return fmt.Sprintf(" # {%s} %s %.3f", strings.Join(labels, ","), formatFloat(exemplar.Value), timestamp)
+}
+
+// histogramBounds returns bucket upper bounds of 1, 2 and 5 times powers of ten spanning the values.
+func histogramBounds(values []int64) []float64 {
+	var max int64
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	var bounds []float64
+	for scale := 1.0; ; scale *= 10 {
+		for _, step := range []float64{1, 2, 5} {
+			bounds = append(bounds, step*scale)
+			if step*scale >= float64(max) {
+				return bounds
+			}
+		}
+	}
+}
+
+func writeHistogram(w io.Writer, name string, histogram metrics.Histogram) {
+	snapshot := histogram.Snapshot()
+	count := snapshot.Count()
+	values := snapshot.Sample().Values()
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	exemplar := getExemplar(name)
+	name = metricName(name)
+
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	exemplarWritten := false
+	if len(values) > 0 {
+		// The sample only holds some of the values, so bucket counts are scaled up from it
+		scale := float64(count) / float64(len(values))
+		inBucket := 0
+		for _, bound := range histogramBounds(values) {
+			for inBucket < len(values) && float64(values[inBucket]) <= bound {
+				inBucket++
+			}
+			line := fmt.Sprintf("%s_bucket{le=\"%s\"} %d", name, formatFloat(bound), int64(math.Round(float64(inBucket)*scale)))
+			if exemplar != nil && !exemplarWritten && exemplar.Value <= bound {
+				line += formatExemplar(exemplar)
+				exemplarWritten = true
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	line := fmt.Sprintf("%s_bucket{le=\"+Inf\"} %d", name, count)
+	if exemplar != nil && !exemplarWritten {
+		line += formatExemplar(exemplar)
+	}
+	fmt.Fprintln(w, line)
+	fmt.Fprintf(w, "%s_count %d\n", name, count)
+	fmt.Fprintf(w, "%s_sum %d\n", name, snapshot.Sum())
+}
+
+func writeTimer(w io.Writer, name string, timer metrics.Timer) {
+	snapshot := timer.Snapshot()
+	name = metricName(name)
+	quantiles := []float64{0.5, 0.75, 0.95, 0.99}
+	values := snapshot.Percentiles(quantiles)
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+	fmt.Fprintf(w, "# UNIT %s seconds\n", name)
+	for i, quantile := range quantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%s\"} %s\n", name, formatFloat(quantile), formatFloat(values[i]/1e9))
+	}
+	fmt.Fprintf(w, "%s_count %d\n", name, snapshot.Count())
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(float64(snapshot.Sum())/1e9))
+}
+
+// Write writes the registry's metrics in the OpenMetrics text format.
+func Write(out io.Writer, registry metrics.Registry) error {
+	names := []string{}
+	all := make(map[string]interface{})
+	registry.Each(func(name string, metric interface{}) {
+		names = append(names, name)
+		all[name] = metric
+	})
+	sort.Strings(names)
+
+	w := bufio.NewWriter(out)
+	for _, name := range names {
+		switch metric := all[name].(type) {
+		case metrics.Counter:
+			fmt.Fprintf(w, "# TYPE %s counter\n%s_total %d\n", metricName(name), metricName(name), metric.Count())
+		case metrics.Gauge:
+			fmt.Fprintf(w, "# TYPE %s gauge\n%s %d\n", metricName(name), metricName(name), metric.Value())
+		case metrics.GaugeFloat64:
+			fmt.Fprintf(w, "# TYPE %s gauge\n%s %s\n", metricName(name), metricName(name), formatFloat(metric.Value()))
+		case metrics.Meter:
+			fmt.Fprintf(w, "# TYPE %s counter\n%s_total %d\n", metricName(name), metricName(name), metric.Count())
+		case metrics.Histogram:
+			writeHistogram(w, name, metric)
+		case metrics.Timer:
+			writeTimer(w, name, metric)
+		}
+	}
+	fmt.Fprintln(w, "# EOF")
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package openmetrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/util/testhelpers"
+)
+
+func TestWrite(t *testing.T) {
+	metrics.Enabled = true
+	registry := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("test/counter", registry).Inc(3)
+	metrics.NewRegisteredGauge("test/gauge", registry).Update(7)
+	histogram := metrics.NewRegisteredHistogram("test/latency", registry, metrics.NewUniformSample(100))
+	for _, value := range []int64{1, 3, 40, 700} {
+		histogram.Update(value)
+	}
+	RecordExemplar("test/latency", 40, map[string]string{"block": "12"})
+	RecordExemplar("test/latency", 3, map[string]string{"block": "13"})
+
+	var out bytes.Buffer
+	testhelpers.RequireImpl(t, Write(&out, registry))
+	text := out.String()
+	expected := []string{
+		"# TYPE test_counter counter\ntest_counter_total 3\n",
+		"# TYPE test_gauge gauge\ntest_gauge 7\n",
+		"# TYPE test_latency histogram\n",
+		"test_latency_bucket{le=\"1\"} 1\n",
+		"test_latency_bucket{le=\"5\"} 2\n",
+		"test_latency_bucket{le=\"50\"} 3 # {block=\"12\"} 40 ",
+		"test_latency_bucket{le=\"1000\"} 4\n",
+		"test_latency_bucket{le=\"+Inf\"} 4\n",
+		"test_latency_count 4\ntest_latency_sum 744\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line) {
+			testhelpers.FailImpl(t, "missing", line, "from", text)
+		}
+	}
+	if !strings.HasSuffix(text, "# EOF\n") {
+		testhelpers.FailImpl(t, "missing EOF marker")
+	}
+	if strings.Count(text, " # {") != 1 {
+		testhelpers.FailImpl(t, "expected exactly one exemplar", text)
+	}
+}
+
+func TestRecordExemplarInTrace(t *testing.T) {
+	ctx, id := StartTrace(context.Background())
+	if id.IsZero() || len(id.String()) != 32 {
+		testhelpers.FailImpl(t, "invalid trace ID", id)
+	}
+	if same, sameID := StartTrace(ctx); same != ctx || sameID != id {
+		testhelpers.FailImpl(t, "started a new trace within a trace")
+	}
+	if _, other := StartTrace(context.Background()); other == id {
+		testhelpers.FailImpl(t, "traces share an ID")
+	}
+
+	labels := map[string]string{"batch": "5"}
+	RecordExemplarInTrace(ctx, "test/traced", 10, labels)
+	exemplar := getExemplar("test/traced")
+	if exemplar == nil || exemplar.Labels["trace_id"] != id.String() || exemplar.Labels["batch"] != "5" {
+		testhelpers.FailImpl(t, "exemplar not labelled with the trace", exemplar)
+	}
+	if _, ok := labels["trace_id"]; ok {
+		testhelpers.FailImpl(t, "caller's labels modified")
+	}
+
+	RecordExemplarInTrace(context.Background(), "test/untraced", 10, labels)
+	if _, ok := getExemplar("test/untraced").Labels["trace_id"]; ok {
+		testhelpers.FailImpl(t, "exemplar outside of a trace labelled with a trace")
+	}
+}
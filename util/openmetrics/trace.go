@@ -0,0 +1,59 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package openmetrics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// TraceID identifies an operation, such as posting a batch or validating a block, across the log lines
+// and exemplars it produces. It has the form of a W3C trace context (and OpenTelemetry) trace ID, so log
+// pipelines and tracing backends can index it as one.
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceID) IsZero() bool {
+	return id == TraceID{}
+}
+
+func NewTraceID() TraceID {
+	var id TraceID
+	// crypto/rand only fails if the system's randomness source does, leaving a zero ID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+type traceIDKey struct{}
+
+// StartTrace returns the ID of the trace the context is part of, starting a new trace if it isn't part of one.
+func StartTrace(ctx context.Context) (context.Context, TraceID) {
+	if id, ok := TraceIDFromContext(ctx); ok {
+		return ctx, id
+	}
+	id := NewTraceID()
+	return context.WithValue(ctx, traceIDKey{}, id), id
+}
+
+func TraceIDFromContext(ctx context.Context) (TraceID, bool) {
+	id, ok := ctx.Value(traceIDKey{}).(TraceID)
+	return id, ok && !id.IsZero()
+}
+
+// RecordExemplarInTrace is RecordExemplar, additionally labelling the sample with the context's trace ID.
+func RecordExemplarInTrace(ctx context.Context, name string, value float64, labels map[string]string) {
+	if id, ok := TraceIDFromContext(ctx); ok {
+		withTrace := make(map[string]string, len(labels)+1)
+		for key, labelValue := range labels {
+			withTrace[key] = labelValue
+		}
+		withTrace["trace_id"] = id.String()
+		labels = withTrace
+	}
+	RecordExemplar(name, value, labels)
+}
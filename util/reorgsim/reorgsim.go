@@ -0,0 +1,159 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package reorgsim wraps an in-process L1 chain and its client so tests can inject
+// reorgs and misbehaving RPC responses into the inbox reading path.
+package reorgsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// Backend is an arbutil.L1Interface that forwards to a real client, except where
+// an anomaly has been injected. Header subscriptions are refused so that readers
+// fall back to polling, where out-of-order headers can be injected.
+type Backend struct {
+	arbutil.L1Interface
+	chain *core.BlockChain
+
+	mutex          sync.Mutex
+	duplicateLogs  bool
+	shuffleLogs    *rand.Rand
+	staleHeaders   int
+	headersHandled []*types.Header
+	reorgs         []ReorgEvent
+}
+
+type ReorgEvent struct {
+	FromNumber uint64
+	ToNumber   uint64
+}
+
+func NewBackend(client arbutil.L1Interface, chain *core.BlockChain) *Backend {
+	return &Backend{
+		L1Interface: client,
+		chain:       chain,
+	}
+}
+
+// Reorg rewinds the chain's head by depth blocks. New blocks mined afterwards form the new fork.
+func (b *Backend) Reorg(depth uint64) (ReorgEvent, error) {
+	current := b.chain.CurrentBlock()
+	currentNumber := current.NumberU64()
+	if depth == 0 || depth > currentNumber {
+		return ReorgEvent{}, fmt.Errorf("cannot reorg %v blocks from block %v", depth, currentNumber)
+	}
+	target := b.chain.GetBlockByNumber(currentNumber - depth)
+	if target == nil {
+		return ReorgEvent{}, fmt.Errorf("reorg target block %v not found", currentNumber-depth)
+	}
+	err := b.chain.ReorgToOldBlock(target)
+	if err != nil {
+		return ReorgEvent{}, err
+	}
+	event := ReorgEvent{
+		FromNumber: currentNumber,
+		ToNumber:   target.NumberU64(),
+	}
+	b.mutex.Lock()
+	b.reorgs = append(b.reorgs, event)
+	b.mutex.Unlock()
+	return event, nil
+}
+
+// RandomReorg reorgs between 1 and maxDepth blocks, bounded by the chain's height.
+func (b *Backend) RandomReorg(rng *rand.Rand, maxDepth uint64) (ReorgEvent, error) {
+	height := b.chain.CurrentBlock().NumberU64()
+	if maxDepth > height {
+		maxDepth = height
+	}
+	if maxDepth == 0 {
+		return ReorgEvent{}, errors.New("chain too short to reorg")
+	}
+	return b.Reorg(uint64(rng.Int63n(int64(maxDepth))) + 1)
+}
+
+// Reorgs returns every reorg injected so far.
+func (b *Backend) Reorgs() []ReorgEvent {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]ReorgEvent{}, b.reorgs...)
+}
+
+// SetDuplicateLogs makes FilterLogs return every log twice.
+func (b *Backend) SetDuplicateLogs(duplicate bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.duplicateLogs = duplicate
+}
+
+// SetShuffleLogs makes FilterLogs return logs in a random order. A nil rng disables shuffling.
+func (b *Backend) SetShuffleLogs(rng *rand.Rand) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.shuffleLogs = rng
+}
+
+// InjectStaleHeaders makes the next count requests for the latest header return
+// a previously served, older header instead.
+func (b *Backend) InjectStaleHeaders(count int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.staleHeaders += count
+}
+
+func (b *Backend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	logs, err := b.L1Interface.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.duplicateLogs {
+		duplicated := make([]types.Log, 0, len(logs)*2)
+		for _, l := range logs {
+			duplicated = append(duplicated, l, l)
+		}
+		logs = duplicated
+	}
+	if b.shuffleLogs != nil {
+		b.shuffleLogs.Shuffle(len(logs), func(i, j int) {
+			logs[i], logs[j] = logs[j], logs[i]
+		})
+	}
+	return logs, nil
+}
+
+func (b *Backend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header, err := b.L1Interface.HeaderByNumber(ctx, number)
+	if err != nil || number != nil {
+		return header, err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.staleHeaders > 0 && len(b.headersHandled) > 1 {
+		b.staleHeaders--
+		return b.headersHandled[len(b.headersHandled)-2], nil
+	}
+	b.headersHandled = append(b.headersHandled, header)
+	if len(b.headersHandled) > 64 {
+		b.headersHandled = b.headersHandled[1:]
+	}
+	return header, nil
+}
+
+func (b *Backend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return nil, rpc.ErrNotificationsUnsupported
+}
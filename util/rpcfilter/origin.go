@@ -0,0 +1,68 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package rpcfilter
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// originRegistry maps the local address of each connection the filter relays a client over to the address of
+// that client. The node's servers see the connection's local address as a request's remote address, so looking
+// it up gives them the client, rather than the filter, as the origin of the request. Geth doesn't pass request
+// headers on to the methods it serves, so the connection is what identifies the client to them.
+type originRegistry struct {
+	mutex   sync.Mutex
+	origins map[string]registeredOrigin
+	nextId  uint64
+}
+
+// registeredOrigin is the client a connection relays for, along with which registration set it, as a pooled
+// connection may be registered for the next request before the previous one has unregistered.
+type registeredOrigin struct {
+	client string
+	id     uint64
+}
+
+var origins = &originRegistry{origins: make(map[string]registeredOrigin)}
+
+func (r *originRegistry) register(conn net.Conn, client string) func() {
+	local := conn.LocalAddr().String()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.nextId++
+	registration := registeredOrigin{client, r.nextId}
+	r.origins[local] = registration
+	return func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if r.origins[local] == registration {
+			delete(r.origins, local)
+		}
+	}
+}
+
+// ClientOrigin returns the address of the client a request received from remote was relayed for by a filter,
+// or remote itself if the request didn't come through a filter.
+func ClientOrigin(remote string) string {
+	origins.mutex.Lock()
+	defer origins.mutex.Unlock()
+	if registration, ok := origins.origins[remote]; ok {
+		return registration.client
+	}
+	return remote
+}
+
+// setForwardedFor records the client in the X-Forwarded-For header of a request relayed for it, as a proxy would.
+func setForwardedFor(header http.Header, client string) {
+	host, _, err := net.SplitHostPort(client)
+	if err != nil {
+		return
+	}
+	if prior := header.Get("X-Forwarded-For"); prior != "" {
+		host = prior + ", " + host
+	}
+	header.Set("X-Forwarded-For", host)
+}
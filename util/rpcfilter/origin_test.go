@@ -0,0 +1,160 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package rpcfilter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+func TestHTTPHandlerForwardsOrigin(t *testing.T) {
+	var remote, origin, forwardedFor string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+		origin = ClientOrigin(r.RemoteAddr)
+		forwardedFor = r.Header.Get("X-Forwarded-For")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer upstream.Close()
+	policy, err := NewPolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewHTTPHandler(policy, upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := httptest.NewServer(handler)
+	defer filtered.Close()
+
+	var client string
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			client = info.Conn.LocalAddr().String()
+		},
+	}
+	request, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodPost, filtered.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if origin != client || remote == client {
+		t.Error("request not from the client", "client", client, "origin", origin, "remote", remote)
+	}
+	if forwardedFor != "127.0.0.1" {
+		t.Error("unexpected X-Forwarded-For", forwardedFor)
+	}
+	// Once the request is done, the connection no longer identifies the client
+	if ClientOrigin(remote) != remote {
+		t.Error("origin of a finished request still registered")
+	}
+}
+
+func TestHTTPHandlerPoolsConnections(t *testing.T) {
+	var remotes, clientOrigins []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remotes = append(remotes, r.RemoteAddr)
+		clientOrigins = append(clientOrigins, ClientOrigin(r.RemoteAddr))
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer upstream.Close()
+	policy, err := NewPolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewHTTPHandler(policy, upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := httptest.NewServer(handler)
+	defer filtered.Close()
+
+	// Each request comes from a different client connection, but is relayed over the same pooled connection
+	var clients []string
+	for i := 0; i < 3; i++ {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				clients = append(clients, info.Conn.LocalAddr().String())
+			},
+		}
+		request, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodPost, filtered.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+	for i := range clients {
+		if remotes[i] != remotes[0] {
+			t.Error("request", i, "relayed over a new connection", remotes[i], "rather than", remotes[0])
+		}
+		if clientOrigins[i] != clients[i] {
+			t.Error("request", i, "from", clients[i], "attributed to", clientOrigins[i])
+		}
+	}
+}
+
+func TestWSHandlerForwardsOrigin(t *testing.T) {
+	origin := make(chan string, 1)
+	var remote string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, op, err := wsutil.ReadClientData(conn)
+			if err != nil {
+				return
+			}
+			origin <- ClientOrigin(r.RemoteAddr)
+			if wsutil.WriteServerMessage(conn, op, message) != nil {
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+	policy, err := NewPolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewWSHandler(policy, "ws"+strings.TrimPrefix(upstream.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := httptest.NewServer(handler)
+	defer filtered.Close()
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(filtered.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := wsutil.WriteClientText(conn, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wsutil.ReadServerText(conn); err != nil {
+		t.Fatal(err)
+	}
+	if client := conn.LocalAddr().String(); <-origin != client || remote == client {
+		t.Error("messages not from the client", client)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package rpcfilter restricts which JSON-RPC methods an RPC transport serves. It fronts the node's own
+// HTTP and WS servers, which listen on loopback, and only passes them the calls its policy permits.
+package rpcfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The JSON-RPC error codes geth uses for unparseable messages and methods it doesn't serve
+const (
+	parseErrorCode     = -32700
+	methodNotFoundCode = -32601
+)
+
+// Policy decides which methods a transport serves. Entries are either a full method name like
+// eth_sendRawTransaction, or a whole namespace like eth_*. A method is permitted if the allowlist is empty
+// or it's on it, and it's not on the denylist.
+type Policy struct {
+	allowMethods    map[string]struct{}
+	allowNamespaces map[string]struct{}
+	denyMethods     map[string]struct{}
+	denyNamespaces  map[string]struct{}
+}
+
+func parseEntries(entries []string) (map[string]struct{}, map[string]struct{}, error) {
+	methods := make(map[string]struct{})
+	namespaces := make(map[string]struct{})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		parts := strings.SplitN(entry, "_", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, nil, fmt.Errorf("invalid method policy entry \"%v\": must be namespace_method or namespace_*", entry)
+		}
+		if parts[1] == "*" {
+			namespaces[parts[0]] = struct{}{}
+		} else {
+			methods[entry] = struct{}{}
+		}
+	}
+	return methods, namespaces, nil
+}
+
+func NewPolicy(allow []string, deny []string) (*Policy, error) {
+	allowMethods, allowNamespaces, err := parseEntries(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyMethods, denyNamespaces, err := parseEntries(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{
+		allowMethods:    allowMethods,
+		allowNamespaces: allowNamespaces,
+		denyMethods:     denyMethods,
+		denyNamespaces:  denyNamespaces,
+	}, nil
+}
+
+func namespaceOf(method string) string {
+	return strings.SplitN(method, "_", 2)[0]
+}
+
+// Restricts returns whether the policy doesn't permit some method.
+func (p *Policy) Restricts() bool {
+	return len(p.allowMethods) > 0 || len(p.allowNamespaces) > 0 || len(p.denyMethods) > 0 || len(p.denyNamespaces) > 0
+}
+
+func (p *Policy) Permits(method string) bool {
+	namespace := namespaceOf(method)
+	if _, ok := p.denyMethods[method]; ok {
+		return false
+	}
+	if _, ok := p.denyNamespaces[namespace]; ok {
+		return false
+	}
+	if len(p.allowMethods) == 0 && len(p.allowNamespaces) == 0 {
+		return true
+	}
+	if _, ok := p.allowMethods[method]; ok {
+		return true
+	}
+	_, ok := p.allowNamespaces[namespace]
+	return ok
+}
+
+type jsonrpcCall struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonrpcErrorResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Error   jsonrpcError    `json:"error"`
+}
+
+func notPermittedResponse(call jsonrpcCall) jsonrpcErrorResponse {
+	id := call.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return jsonrpcErrorResponse{
+		Version: "2.0",
+		ID:      id,
+		Error: jsonrpcError{
+			Code:    methodNotFoundCode,
+			Message: fmt.Sprintf("the method %v is not available over this transport", call.Method),
+		},
+	}
+}
+
+func parseErrorResponse(err error) []byte {
+	return marshalResponse(jsonrpcErrorResponse{
+		Version: "2.0",
+		ID:      json.RawMessage("null"),
+		Error:   jsonrpcError{Code: parseErrorCode, Message: err.Error()},
+	})
+}
+
+// The response to send if a rejection can't be marshalled, which never happens with these types
+var fallbackResponse = []byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32601,"message":"method not available over this transport"}}`)
+
+func marshalResponse(response interface{}) []byte {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fallbackResponse
+	}
+	return data
+}
+
+// check inspects a JSON-RPC message, which may be a batch. If it calls any method the policy doesn't permit,
+// it returns the response rejecting it; a batch is rejected as a whole, with an error for each call.
+// Messages which don't parse are rejected too, so the server never sees a call the filter couldn't read.
+func (p *Policy) check(message []byte) ([]byte, bool) {
+	message = bytes.TrimLeft(message, " \t\r\n")
+	if len(message) > 0 && message[0] == '[' {
+		var calls []jsonrpcCall
+		if err := json.Unmarshal(message, &calls); err != nil {
+			return parseErrorResponse(err), false
+		}
+		permitted := true
+		for _, call := range calls {
+			permitted = permitted && p.Permits(call.Method)
+		}
+		if permitted {
+			return nil, true
+		}
+		responses := make([]jsonrpcErrorResponse, 0, len(calls))
+		for _, call := range calls {
+			response := notPermittedResponse(call)
+			if p.Permits(call.Method) {
+				response.Error.Message = "batch not executed as it calls methods not available over this transport"
+			}
+			responses = append(responses, response)
+		}
+		return marshalResponse(responses), false
+	}
+	var call jsonrpcCall
+	if err := json.Unmarshal(message, &call); err != nil {
+		return parseErrorResponse(err), false
+	}
+	if p.Permits(call.Method) {
+		return nil, true
+	}
+	return marshalResponse(notPermittedResponse(call)), false
+}
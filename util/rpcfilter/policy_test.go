@@ -0,0 +1,222 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package rpcfilter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+func TestPolicyPermits(t *testing.T) {
+	policy, err := NewPolicy([]string{"eth_*", "net_version"}, []string{"eth_sign"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for method, expected := range map[string]bool{
+		"eth_chainId":     true,
+		"net_version":     true,
+		"net_peerCount":   false,
+		"eth_sign":        false,
+		"debug_traceCall": false,
+		"admin_peers":     false,
+	} {
+		if policy.Permits(method) != expected {
+			t.Error("method", method, "permitted", !expected, "expected", expected)
+		}
+	}
+
+	denyOnly, err := NewPolicy(nil, []string{"debug_*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !denyOnly.Permits("eth_call") || denyOnly.Permits("debug_traceTransaction") {
+		t.Error("deny only policy should permit everything but its denylist")
+	}
+
+	for _, entry := range []string{"eth", "_call", "eth_"} {
+		if _, err := NewPolicy([]string{entry}, nil); err == nil {
+			t.Error("invalid entry", entry, "accepted")
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := NewPolicy([]string{"eth_*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, permitted := policy.check([]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`)); !permitted {
+		t.Error("permitted call rejected")
+	}
+	if _, permitted := policy.check([]byte(` [{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber"}]`)); !permitted {
+		t.Error("permitted batch rejected")
+	}
+
+	rejection, permitted := policy.check([]byte(`{"jsonrpc":"2.0","id":7,"method":"admin_peers"}`))
+	if permitted {
+		t.Fatal("call to denied method permitted")
+	}
+	var response jsonrpcErrorResponse
+	if err := json.Unmarshal(rejection, &response); err != nil {
+		t.Fatal(err)
+	}
+	if string(response.ID) != "7" || response.Error.Code != methodNotFoundCode {
+		t.Error("unexpected rejection", string(rejection))
+	}
+
+	rejection, permitted = policy.check([]byte(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"debug_traceCall"}]`))
+	if permitted {
+		t.Fatal("batch with denied method permitted")
+	}
+	var responses []jsonrpcErrorResponse
+	if err := json.Unmarshal(rejection, &responses); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || string(responses[0].ID) != "1" || string(responses[1].ID) != "2" {
+		t.Error("unexpected batch rejection", string(rejection))
+	}
+
+	if _, permitted := policy.check([]byte(`{"method":`)); permitted {
+		t.Error("unparseable message permitted")
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	var served []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		served = append(served, string(body))
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer upstream.Close()
+
+	policy, err := NewPolicy([]string{"eth_chainId"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewHTTPHandler(policy, upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := httptest.NewServer(handler)
+	defer filtered.Close()
+
+	post := func(body string) string {
+		resp, err := http.Post(filtered.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if result := post(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`); !strings.Contains(result, `"result"`) {
+		t.Error("permitted call not proxied:", result)
+	}
+	if result := post(`{"jsonrpc":"2.0","id":2,"method":"personal_unlockAccount"}`); !strings.Contains(result, "not available") {
+		t.Error("denied call not rejected:", result)
+	}
+	if len(served) != 1 {
+		t.Error("upstream served", len(served), "requests, expected 1")
+	}
+
+	// GraphQL can't be filtered by method, so a restricting policy refuses it
+	for _, graphqlPath := range []string{"/graphql", "/graphql/", "/graphql/ui"} {
+		resp, err := http.Post(filtered.URL+graphqlPath, "application/json", strings.NewReader(`{"query":"{block{number}}"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Error("graphql request to", graphqlPath, "not refused, status", resp.StatusCode)
+		}
+	}
+	if len(served) != 1 {
+		t.Error("upstream served", len(served), "requests, expected 1")
+	}
+
+	permissive, err := NewPolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err = NewHTTPHandler(permissive, upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unfiltered := httptest.NewServer(handler)
+	defer unfiltered.Close()
+	resp, err := http.Post(unfiltered.URL+"/graphql", "application/json", strings.NewReader(`{"query":"{block{number}}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(served) != 2 {
+		t.Error("graphql request not proxied without a restricting policy, status", resp.StatusCode)
+	}
+}
+
+func TestWSHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, op, err := wsutil.ReadClientData(conn)
+			if err != nil {
+				return
+			}
+			if wsutil.WriteServerMessage(conn, op, message) != nil {
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	policy, err := NewPolicy(nil, []string{"admin_*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewWSHandler(policy, "ws"+strings.TrimPrefix(upstream.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := httptest.NewServer(handler)
+	defer filtered.Close()
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(filtered.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	call := func(message string) string {
+		if err := wsutil.WriteClientText(conn, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+		response, err := wsutil.ReadServerText(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(response)
+	}
+	permitted := `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`
+	if result := call(permitted); result != permitted {
+		t.Error("permitted call not relayed:", result)
+	}
+	if result := call(`{"jsonrpc":"2.0","id":2,"method":"admin_addPeer"}`); !strings.Contains(result, "not available") {
+		t.Error("denied call not rejected:", result)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package rpcfilter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// The largest HTTP request body the filter reads, matching geth's limit
+const maxRequestContentLength = 1024 * 1024 * 5
+
+// relayWithOrigin proxies a request over a pooled connection, registered as relaying the request's client for as
+// long as the request takes. HTTP/1.1 connections carry one request at a time, so while it's in flight the
+// connection identifies the client. The proxy sets X-Forwarded-For to the client too.
+func relayWithOrigin(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) {
+	var unregister func()
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			unregister = origins.register(info.Conn, r.RemoteAddr)
+		},
+	}
+	proxy.ServeHTTP(w, r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	if unregister != nil {
+		unregister()
+	}
+}
+
+// isGraphQL returns whether a request is for the node's GraphQL endpoint or its UI.
+func isGraphQL(requestPath string) bool {
+	requestPath = path.Clean("/" + requestPath)
+	return strings.HasSuffix(requestPath, "/graphql") || strings.Contains(requestPath, "/graphql/")
+}
+
+// NewHTTPHandler filters JSON-RPC requests over HTTP by the policy, proxying those it permits to the node's HTTP
+// server at target. GraphQL queries can't be filtered by method, and can send transactions, so they're refused
+// unless the policy permits every method. Requests other than POSTs, which the RPC server doesn't execute any
+// method for, pass through unfiltered.
+func NewHTTPHandler(policy *Policy, target string) (http.Handler, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	proxy.Transport = &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGraphQL(r.URL.Path) {
+			if policy.Restricts() {
+				http.Error(w, "graphql is not available over this transport", http.StatusForbidden)
+				return
+			}
+			relayWithOrigin(proxy, w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			relayWithOrigin(proxy, w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestContentLength+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxRequestContentLength {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if rejection, permitted := policy.check(body); !permitted {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(rejection)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		relayWithOrigin(proxy, w, r)
+	}), nil
+}
+
+// lockedWriter serializes writes of whole websocket frames to a connection, as both the relay and the replies
+// to control frames write to it.
+type lockedWriter struct {
+	mutex sync.Mutex
+	conn  net.Conn
+	state ws.State
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.conn.Write(p)
+}
+
+func (w *lockedWriter) writeMessage(op ws.OpCode, message []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return wsutil.WriteMessage(w.conn, w.state, op, message)
+}
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+// NewWSHandler filters JSON-RPC messages over websockets by the policy, relaying those it permits over a
+// connection of its own to the node's websocket server at target, and relaying back everything the server sends.
+// The connection is registered as relaying the client while it's open.
+func NewWSHandler(policy *Policy, target string) (http.Handler, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := http.Header{}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			header.Set("Origin", origin)
+		}
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			header.Set("X-Forwarded-For", forwardedFor)
+		}
+		setForwardedFor(header, r.RemoteAddr)
+		dialer := ws.Dialer{
+			Timeout: 10 * time.Second,
+			Header:  ws.HandshakeHeaderHTTP(header),
+		}
+		upstreamURL := url.URL{Scheme: targetURL.Scheme, Host: targetURL.Host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		upstream, br, _, err := dialer.Dial(r.Context(), upstreamURL.String())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to connect to rpc server: %v", err), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		defer origins.register(upstream, r.RemoteAddr)()
+		var upstreamReader io.Reader = upstream
+		if br != nil {
+			// The dialer may have read frames the server sent right after the handshake
+			upstreamReader = io.MultiReader(io.LimitReader(br, int64(br.Buffered())), upstream)
+		}
+		client, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		defer client.Close()
+		clientWriter := &lockedWriter{conn: client, state: ws.StateServerSide}
+		upstreamWriter := &lockedWriter{conn: upstream, state: ws.StateClientSide}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			defer cancel()
+			for {
+				message, op, err := wsutil.ReadServerData(readWriter{upstreamReader, upstreamWriter})
+				if err != nil || clientWriter.writeMessage(op, message) != nil {
+					return
+				}
+			}
+		}()
+		go func() {
+			defer cancel()
+			for {
+				message, op, err := wsutil.ReadClientData(readWriter{client, clientWriter})
+				if err != nil {
+					return
+				}
+				if rejection, permitted := policy.check(message); !permitted {
+					err = clientWriter.writeMessage(ws.OpText, rejection)
+				} else {
+					err = upstreamWriter.writeMessage(op, message)
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+		<-ctx.Done()
+	}), nil
+}
+
+// Server serves filtered HTTP and websocket RPC on one address, like the node's own servers do when their
+// ports match. Either handler may be nil if that transport isn't served here.
+type Server struct {
+	server *http.Server
+}
+
+func NewServer(addr string, httpHandler http.Handler, wsHandler http.Handler) *Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isUpgrade := strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+		if isUpgrade && wsHandler != nil {
+			wsHandler.ServeHTTP(w, r)
+		} else if !isUpgrade && httpHandler != nil {
+			httpHandler.ServeHTTP(w, r)
+		} else {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+	return &Server{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// Start listens on the server's address and serves in the background.
+func (s *Server) Start() (net.Addr, error) {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+	return listener.Addr(), nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,369 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package statehealer recovers from "missing trie node" errors, left behind by a partial snapshot
+// or disk issues, by fetching just the missing trie nodes and contract code from a peer or a
+// snapshot database instead of requiring a full resync.
+package statehealer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	healedNodesCounter = metrics.NewRegisteredCounter("arb/statehealer/nodes/healed", nil)
+	healedCodeCounter  = metrics.NewRegisteredCounter("arb/statehealer/code/healed", nil)
+	healedBytesCounter = metrics.NewRegisteredCounter("arb/statehealer/bytes", nil)
+	healFailedCounter  = metrics.NewRegisteredCounter("arb/statehealer/failed", nil)
+)
+
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+var ErrNotFound = errors.New("not found in any state healing source")
+
+type Config struct {
+	PeerURL        string        `koanf:"peer-url"`
+	SnapshotDir    string        `koanf:"snapshot-dir"`
+	RequestTimeout time.Duration `koanf:"request-timeout"`
+	MaxNodes       uint64        `koanf:"max-nodes"`
+}
+
+func (c *Config) Enabled() bool {
+	return c.PeerURL != "" || c.SnapshotDir != ""
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".peer-url", DefaultConfig.PeerURL, "URL of a node serving debug_dbGet to fetch missing trie nodes and code from")
+	f.String(prefix+".snapshot-dir", DefaultConfig.SnapshotDir, "chaindata directory of a database snapshot to fetch missing trie nodes and code from")
+	f.Duration(prefix+".request-timeout", DefaultConfig.RequestTimeout, "timeout for fetching a single trie node from the peer")
+	f.Uint64(prefix+".max-nodes", DefaultConfig.MaxNodes, "maximum number of trie nodes healed by a single state healing run (0 = unlimited)")
+}
+
+var DefaultConfig = Config{
+	PeerURL:        "",
+	SnapshotDir:    "",
+	RequestTimeout: 10 * time.Second,
+	MaxNodes:       100_000,
+}
+
+// Source is somewhere missing database entries can be fetched from, keyed as in the chain database.
+type Source interface {
+	Get(ctx context.Context, key []byte) ([]byte, error)
+}
+
+type peerSource struct {
+	client  *rpc.Client
+	timeout time.Duration
+}
+
+func (s *peerSource) Get(ctx context.Context, key []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var value hexutil.Bytes
+	err := s.client.CallContext(ctx, &value, "debug_dbGet", hexutil.Encode(key))
+	return value, err
+}
+
+func (s *peerSource) Close() error {
+	s.client.Close()
+	return nil
+}
+
+type databaseSource struct {
+	db ethdb.KeyValueStore
+}
+
+func (s *databaseSource) Get(ctx context.Context, key []byte) ([]byte, error) {
+	return s.db.Get(key)
+}
+
+func (s *databaseSource) Close() error {
+	return s.db.Close()
+}
+
+// StateHealer writes trie nodes and contract code missing from the chain database, verified
+// against their hashes, after fetching them from its sources in order.
+type StateHealer struct {
+	config  *Config
+	db      ethdb.Database
+	sources []Source
+}
+
+func NewStateHealer(config *Config, db ethdb.Database, sources ...Source) *StateHealer {
+	return &StateHealer{
+		config:  config,
+		db:      db,
+		sources: sources,
+	}
+}
+
+// Open creates a state healer with the sources in the config, or returns nil if none are configured.
+// The healer must be closed to release its sources.
+func Open(ctx context.Context, config *Config, db ethdb.Database) (*StateHealer, error) {
+	var sources []Source
+	if config.PeerURL != "" {
+		client, err := rpc.DialContext(ctx, config.PeerURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to state healing peer %v: %w", config.PeerURL, err)
+		}
+		sources = append(sources, &peerSource{client, config.RequestTimeout})
+	}
+	if config.SnapshotDir != "" {
+		snapshot, err := rawdb.NewLevelDBDatabase(config.SnapshotDir, 16, 16, "arb/statehealer/snapshot/", true)
+		if err != nil {
+			for _, source := range sources {
+				_ = source.(io.Closer).Close()
+			}
+			return nil, fmt.Errorf("failed to open state healing snapshot %v: %w", config.SnapshotDir, err)
+		}
+		sources = append(sources, &databaseSource{snapshot})
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	return NewStateHealer(config, db, sources...), nil
+}
+
+// Close releases the sources which hold resources, such as the snapshot database.
+func (h *StateHealer) Close() error {
+	var firstErr error
+	for _, source := range h.sources {
+		closer, ok := source.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// healingDatabase is the chain database, healing trie nodes and contract code missing from it as they're read.
+// Everything reading state through it, such as RPC calls and block production, then succeeds where it'd
+// otherwise fail with a missing trie node.
+type healingDatabase struct {
+	ethdb.Database
+	healer *StateHealer
+}
+
+// Database returns the healer's database, wrapped to heal trie nodes and contract code missing from it on reads.
+func (h *StateHealer) Database() ethdb.Database {
+	return &healingDatabase{Database: h.db, healer: h}
+}
+
+// FromDatabase returns the healer of a database returned by Database, or nil for any other database.
+func FromDatabase(db ethdb.Database) *StateHealer {
+	if healing, ok := db.(*healingDatabase); ok {
+		return healing.healer
+	}
+	return nil
+}
+
+func (d *healingDatabase) Get(key []byte) ([]byte, error) {
+	value, err := d.Database.Get(key)
+	if err == nil && len(value) > 0 {
+		return value, nil
+	}
+	var healed []byte
+	var healErr error
+	ctx := context.Background()
+	if len(key) == common.HashLength {
+		healed, healErr = d.healer.HealNode(ctx, common.BytesToHash(key))
+	} else if len(key) == len(rawdb.CodePrefix)+common.HashLength && bytes.HasPrefix(key, rawdb.CodePrefix) {
+		healed, healErr = d.healer.HealCode(ctx, common.BytesToHash(key[len(rawdb.CodePrefix):]))
+	} else {
+		return value, err
+	}
+	if healErr != nil {
+		log.Debug("failed to heal database entry on read", "key", hexutil.Encode(key), "err", healErr)
+		return value, err
+	}
+	return healed, nil
+}
+
+func codeKey(hash common.Hash) []byte {
+	return append(append([]byte{}, rawdb.CodePrefix...), hash.Bytes()...)
+}
+
+func (h *StateHealer) fetch(ctx context.Context, hash common.Hash, key []byte) ([]byte, error) {
+	for _, source := range h.sources {
+		value, err := source.Get(ctx, key)
+		if err != nil {
+			log.Debug("state healing source failed", "hash", hash, "err", err)
+			continue
+		}
+		if crypto.Keccak256Hash(value) != hash {
+			log.Warn("state healing source returned data not matching its hash", "hash", hash)
+			continue
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrNotFound, hash)
+}
+
+// HealNode fetches the trie node with the given hash and writes it to the database.
+func (h *StateHealer) HealNode(ctx context.Context, hash common.Hash) ([]byte, error) {
+	node, err := h.fetch(ctx, hash, hash.Bytes())
+	if err != nil {
+		healFailedCounter.Inc(1)
+		return nil, err
+	}
+	err = h.db.Put(hash.Bytes(), node)
+	if err != nil {
+		return nil, err
+	}
+	healedNodesCounter.Inc(1)
+	healedBytesCounter.Inc(int64(len(node)))
+	log.Info("healed missing trie node", "hash", hash, "size", len(node))
+	return node, nil
+}
+
+// HealCode fetches the contract code with the given hash and writes it to the database.
+func (h *StateHealer) HealCode(ctx context.Context, hash common.Hash) ([]byte, error) {
+	code, err := h.fetch(ctx, hash, codeKey(hash))
+	if err != nil {
+		healFailedCounter.Inc(1)
+		return nil, err
+	}
+	rawdb.WriteCode(h.db, hash, code)
+	healedCodeCounter.Inc(1)
+	healedBytesCounter.Inc(int64(len(code)))
+	log.Info("healed missing contract code", "hash", hash, "size", len(code))
+	return code, nil
+}
+
+// HealPreimage heals a hash which is expected to be either a trie node or contract code.
+func (h *StateHealer) HealPreimage(ctx context.Context, hash common.Hash) ([]byte, error) {
+	node, err := h.HealNode(ctx, hash)
+	if err == nil {
+		return node, nil
+	}
+	return h.HealCode(ctx, hash)
+}
+
+type HealResult struct {
+	Root         common.Hash `json:"root"`
+	HealedNodes  uint64      `json:"healedNodes"`
+	HealedCode   uint64      `json:"healedCode"`
+	VisitedNodes uint64      `json:"visitedNodes"`
+	// Set if the run stopped at the node limit before the whole state was checked
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+func (r *HealResult) healed() uint64 {
+	return r.HealedNodes + r.HealedCode
+}
+
+// HealState walks the state trie under root and every storage trie and contract code it references,
+// healing whatever is missing. Nodes already present are only read, so a state with a few missing
+// nodes is healed by fetching just those.
+func (h *StateHealer) HealState(ctx context.Context, root common.Hash) (*HealResult, error) {
+	result := &HealResult{Root: root}
+	stateDb := state.NewDatabase(h.db)
+	err := h.healTrie(ctx, result, func() (state.Trie, error) { return stateDb.OpenTrie(root) }, func(key []byte, leaf []byte) error {
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(leaf, &account); err != nil {
+			return err
+		}
+		if account.Root != types.EmptyRootHash {
+			addrHash := common.BytesToHash(key)
+			err := h.healTrie(ctx, result, func() (state.Trie, error) { return stateDb.OpenStorageTrie(addrHash, account.Root) }, nil)
+			if err != nil {
+				return err
+			}
+		}
+		codeHash := common.BytesToHash(account.CodeHash)
+		if codeHash != emptyCodeHash && len(rawdb.ReadCode(h.db, codeHash)) == 0 {
+			if _, err := h.HealCode(ctx, codeHash); err != nil {
+				return err
+			}
+			result.HealedCode++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	log.Info("state healing complete", "root", root, "healedNodes", result.HealedNodes, "healedCode", result.HealedCode, "incomplete", result.Incomplete)
+	return result, nil
+}
+
+// healTrie iterates a trie, healing each missing node and resuming after the last leaf visited.
+func (h *StateHealer) healTrie(ctx context.Context, result *HealResult, open func() (state.Trie, error), onLeaf func(key []byte, leaf []byte) error) error {
+	var start []byte
+	for {
+		if h.config.MaxNodes != 0 && result.healed() >= h.config.MaxNodes {
+			result.Incomplete = true
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tr, err := open()
+		if err == nil {
+			it := tr.NodeIterator(start)
+			for it.Next(true) {
+				result.VisitedNodes++
+				if !it.Leaf() || onLeaf == nil {
+					continue
+				}
+				key := common.CopyBytes(it.LeafKey())
+				if err := onLeaf(key, it.LeafBlob()); err != nil {
+					return err
+				}
+				if result.Incomplete {
+					return nil
+				}
+				start = key
+			}
+			err = it.Error()
+			if err == nil {
+				return nil
+			}
+		}
+		var missing *trie.MissingNodeError
+		if !errors.As(err, &missing) {
+			return err
+		}
+		if _, err := h.HealNode(ctx, missing.NodeHash); err != nil {
+			return err
+		}
+		result.HealedNodes++
+	}
+}
+
+// Heal retries fn, healing the missing trie node it failed on each time, until it succeeds, fails
+// for another reason, or keeps failing after maxRetries heals. A nil healer just runs fn once.
+func (h *StateHealer) Heal(ctx context.Context, maxRetries int, fn func() error) error {
+	for i := 0; ; i++ {
+		err := fn()
+		var missing *trie.MissingNodeError
+		if h == nil || i >= maxRetries || !errors.As(err, &missing) {
+			return err
+		}
+		if _, healErr := h.HealNode(ctx, missing.NodeHash); healErr != nil {
+			log.Warn("failed to heal missing trie node", "hash", missing.NodeHash, "err", healErr)
+			return err
+		}
+	}
+}
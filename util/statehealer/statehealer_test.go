@@ -0,0 +1,168 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package statehealer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/offchainlabs/nitro/util/testhelpers"
+)
+
+// buildState commits a state with a few accounts, one with code and storage, returning its root.
+func buildState(t *testing.T, db ethdb.Database) common.Hash {
+	stateDb, err := state.New(common.Hash{}, state.NewDatabase(db), nil)
+	testhelpers.RequireImpl(t, err)
+	for i := int64(1); i <= 20; i++ {
+		stateDb.SetBalance(common.BigToAddress(big.NewInt(i)), big.NewInt(i))
+	}
+	contract := common.HexToAddress("0xc0de")
+	stateDb.SetCode(contract, []byte{0x60, 0x00, 0x60, 0x00, 0xf3})
+	for i := int64(1); i <= 20; i++ {
+		stateDb.SetState(contract, common.BigToHash(big.NewInt(i)), common.BigToHash(big.NewInt(i)))
+	}
+	root, err := stateDb.Commit(true)
+	testhelpers.RequireImpl(t, err)
+	testhelpers.RequireImpl(t, stateDb.Database().TrieDB().Commit(root, false, nil))
+	return root
+}
+
+func TestHealState(t *testing.T) {
+	ctx := context.Background()
+	source := rawdb.NewMemoryDatabase()
+	root := buildState(t, source)
+
+	local := rawdb.NewMemoryDatabase()
+	if buildState(t, local) != root {
+		testhelpers.FailImpl(t, "states differ")
+	}
+	// Lose the root node, and so every node under it, as far as the iterator can tell
+	testhelpers.RequireImpl(t, local.Delete(root.Bytes()))
+
+	healer := NewStateHealer(&DefaultConfig, local, &databaseSource{source})
+	result, err := healer.HealState(ctx, root)
+	testhelpers.RequireImpl(t, err)
+	if result.HealedNodes != 1 || result.Incomplete {
+		testhelpers.FailImpl(t, "unexpected result", result)
+	}
+	has, err := local.Has(root.Bytes())
+	testhelpers.RequireImpl(t, err)
+	if !has {
+		testhelpers.FailImpl(t, "root node not healed")
+	}
+
+	// A fully present state needs no healing
+	result, err = healer.HealState(ctx, root)
+	testhelpers.RequireImpl(t, err)
+	if result.HealedNodes != 0 || result.VisitedNodes == 0 {
+		testhelpers.FailImpl(t, "unexpected result", result)
+	}
+}
+
+type corruptSource struct{}
+
+func (s *corruptSource) Get(ctx context.Context, key []byte) ([]byte, error) {
+	return []byte("not the preimage"), nil
+}
+
+func TestHealRejectsMismatchedData(t *testing.T) {
+	ctx := context.Background()
+	healer := NewStateHealer(&DefaultConfig, rawdb.NewMemoryDatabase(), &corruptSource{})
+	_, err := healer.HealPreimage(ctx, common.HexToHash("0x1234"))
+	if !errors.Is(err, ErrNotFound) {
+		testhelpers.FailImpl(t, "expected not found, got", err)
+	}
+}
+
+func TestHealRetries(t *testing.T) {
+	ctx := context.Background()
+	source := rawdb.NewMemoryDatabase()
+	node := []byte("a trie node")
+	hash := common.BytesToHash(crypto.Keccak256(node))
+	testhelpers.RequireImpl(t, source.Put(hash.Bytes(), node))
+	local := rawdb.NewMemoryDatabase()
+	healer := NewStateHealer(&DefaultConfig, local, &databaseSource{source})
+
+	attempts := 0
+	err := healer.Heal(ctx, 3, func() error {
+		attempts++
+		if has, _ := local.Has(hash.Bytes()); !has {
+			return &trie.MissingNodeError{NodeHash: hash}
+		}
+		return nil
+	})
+	testhelpers.RequireImpl(t, err)
+	if attempts != 2 {
+		testhelpers.FailImpl(t, "expected 2 attempts, got", attempts)
+	}
+
+	// A nil healer runs once and passes the error through
+	var nilHealer *StateHealer
+	err = nilHealer.Heal(ctx, 3, func() error { return &trie.MissingNodeError{NodeHash: hash} })
+	var missing *trie.MissingNodeError
+	if !errors.As(err, &missing) {
+		testhelpers.FailImpl(t, "expected missing node error, got", err)
+	}
+}
+
+func TestHealingDatabase(t *testing.T) {
+	source := rawdb.NewMemoryDatabase()
+	root := buildState(t, source)
+	local := rawdb.NewMemoryDatabase()
+	if buildState(t, local) != root {
+		testhelpers.FailImpl(t, "states differ")
+	}
+	testhelpers.RequireImpl(t, local.Delete(root.Bytes()))
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	codeHash := crypto.Keccak256Hash(code)
+	testhelpers.RequireImpl(t, local.Delete(codeKey(codeHash)))
+
+	healer := NewStateHealer(&DefaultConfig, local, &databaseSource{source})
+	db := healer.Database()
+	if FromDatabase(db) != healer || FromDatabase(local) != nil {
+		testhelpers.FailImpl(t, "healer not found from its database")
+	}
+
+	// Reading state through the database heals the missing root and code, as an RPC call would
+	stateDb, err := state.New(root, state.NewDatabase(db), nil)
+	testhelpers.RequireImpl(t, err)
+	if stateDb.GetBalance(common.BigToAddress(big.NewInt(7))).Cmp(big.NewInt(7)) != 0 {
+		testhelpers.FailImpl(t, "wrong balance read")
+	}
+	if string(stateDb.GetCode(common.HexToAddress("0xc0de"))) != string(code) {
+		testhelpers.FailImpl(t, "wrong code read")
+	}
+	for _, key := range [][]byte{root.Bytes(), codeKey(codeHash)} {
+		has, err := local.Has(key)
+		testhelpers.RequireImpl(t, err)
+		if !has {
+			testhelpers.FailImpl(t, "entry not healed", common.Bytes2Hex(key))
+		}
+	}
+
+	// Other entries aren't looked up in the sources
+	testhelpers.RequireImpl(t, source.Put([]byte("LastBlock"), root.Bytes()))
+	if _, err := db.Get([]byte("LastBlock")); err == nil {
+		testhelpers.FailImpl(t, "healed an entry that isn't state")
+	}
+}
+
+func TestCloseReleasesSources(t *testing.T) {
+	source := rawdb.NewMemoryDatabase()
+	testhelpers.RequireImpl(t, source.Put([]byte("key"), []byte("value")))
+	healer := NewStateHealer(&DefaultConfig, rawdb.NewMemoryDatabase(), &databaseSource{source}, &corruptSource{})
+	testhelpers.RequireImpl(t, healer.Close())
+	if _, err := source.Get([]byte("key")); err == nil {
+		testhelpers.FailImpl(t, "source database not closed")
+	}
+}
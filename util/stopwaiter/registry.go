@@ -0,0 +1,118 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package stopwaiter
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A thread still running this long after its stop waiter was stopped isn't respecting cancellation.
+const StuckThreshold = 5 * time.Second
+
+// ThreadInfo describes a running thread launched by a stop waiter.
+type ThreadInfo struct {
+	Name        string     `json:"name"`      // the function the thread runs
+	Component   string     `json:"component"` // the type or package which launched it
+	StartedAt   time.Time  `json:"startedAt"`
+	Iterative   bool       `json:"iterative"` // launched by CallIteratively, which checks for cancellation between iterations
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+	Stuck       bool       `json:"stuck"` // still running StuckThreshold after it was cancelled
+}
+
+type trackedThread struct {
+	name      string
+	component string
+	startedAt time.Time
+	iterative bool
+	waiter    *StopWaiterSafe
+}
+
+var registry = struct {
+	mutex   sync.Mutex
+	nextId  uint64
+	threads map[uint64]*trackedThread
+}{
+	threads: make(map[uint64]*trackedThread),
+}
+
+// threadNames returns the name of the function a thread runs, and the component it belongs to:
+// its receiver type if it's a method or a closure within one, otherwise its package.
+func threadNames(foo interface{}) (string, string) {
+	name := "unknown"
+	if function := runtime.FuncForPC(reflect.ValueOf(foo).Pointer()); function != nil {
+		name = function.Name()
+	}
+	// drop the package path, e.g. github.com/offchainlabs/nitro/arbnode.(*BatchPoster).Start.func1
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+	component := name
+	if dot := strings.Index(name, "."); dot >= 0 {
+		component = name[:dot]
+		receiver := name[dot+1:]
+		if strings.HasPrefix(receiver, "(") {
+			if end := strings.Index(receiver, ")"); end >= 0 {
+				component += "." + strings.TrimPrefix(receiver[1:end], "*")
+			}
+		}
+	}
+	return name, component
+}
+
+func registerThread(waiter *StopWaiterSafe, foo interface{}, iterative bool) uint64 {
+	name, component := threadNames(foo)
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	id := registry.nextId
+	registry.nextId++
+	registry.threads[id] = &trackedThread{
+		name:      name,
+		component: component,
+		startedAt: time.Now(),
+		iterative: iterative,
+		waiter:    waiter,
+	}
+	return id
+}
+
+func unregisterThread(id uint64) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.threads, id)
+}
+
+// Threads lists the threads launched by stop waiters which are still running, oldest first.
+// Threads which are cancelled but keep running are what hold up a shutdown.
+func Threads() []ThreadInfo {
+	registry.mutex.Lock()
+	tracked := make([]*trackedThread, 0, len(registry.threads))
+	for _, thread := range registry.threads {
+		tracked = append(tracked, thread)
+	}
+	registry.mutex.Unlock()
+
+	threads := make([]ThreadInfo, 0, len(tracked))
+	for _, thread := range tracked {
+		info := ThreadInfo{
+			Name:      thread.name,
+			Component: thread.component,
+			StartedAt: thread.startedAt,
+			Iterative: thread.iterative,
+		}
+		if stoppedAt, stopped := thread.waiter.stoppedTime(); stopped {
+			info.CancelledAt = &stoppedAt
+			info.Stuck = time.Since(stoppedAt) >= StuckThreshold
+		}
+		threads = append(threads, info)
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].StartedAt.Before(threads[j].StartedAt)
+	})
+	return threads
+}
@@ -0,0 +1,66 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package stopwaiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testComponent struct {
+	StopWaiter
+	release chan struct{}
+}
+
+func (c *testComponent) ignoreCancellation(ctx context.Context) {
+	<-c.release
+}
+
+func findThread(name string) *ThreadInfo {
+	for _, thread := range Threads() {
+		if thread.Name == name {
+			return &thread
+		}
+	}
+	return nil
+}
+
+func TestThreadRegistry(t *testing.T) {
+	c := &testComponent{release: make(chan struct{})}
+	c.Start(context.Background())
+	c.LaunchThread(c.ignoreCancellation)
+	c.CallIteratively(func(ctx context.Context) time.Duration {
+		return time.Millisecond
+	})
+
+	name := "stopwaiter.(*testComponent).ignoreCancellation-fm"
+	thread := findThread(name)
+	if thread == nil {
+		t.Fatal("thread not registered", Threads())
+	}
+	if thread.Component != "stopwaiter.testComponent" || thread.Iterative || thread.CancelledAt != nil {
+		t.Fatal("unexpected thread info", *thread)
+	}
+	iterative := findThread("stopwaiter.TestThreadRegistry.func1")
+	if iterative == nil || !iterative.Iterative || iterative.Component != "stopwaiter" {
+		t.Fatal("unexpected iterative thread info", iterative)
+	}
+
+	c.StopOnly()
+	time.Sleep(10 * time.Millisecond)
+	if findThread("stopwaiter.TestThreadRegistry.func1") != nil {
+		t.Fatal("iterative thread still registered after stopping")
+	}
+	thread = findThread(name)
+	if thread == nil || thread.CancelledAt == nil || thread.Stuck {
+		t.Fatal("thread ignoring cancellation not reported as cancelled", thread)
+	}
+
+	close(c.release)
+	c.StopAndWait()
+	if findThread(name) != nil {
+		t.Fatal("thread still registered after exiting")
+	}
+}
@@ -11,12 +11,13 @@ import (
 )
 
 type StopWaiterSafe struct {
-	mutex    sync.Mutex // protects started, stopped, ctx, stopFunc
-	started  bool
-	stopped  bool
-	ctx      context.Context
-	stopFunc func()
-	waitChan <-chan interface{}
+	mutex     sync.Mutex // protects started, stopped, stoppedAt, ctx, stopFunc
+	started   bool
+	stopped   bool
+	stoppedAt time.Time
+	ctx       context.Context
+	stopFunc  func()
+	waitChan  <-chan interface{}
 
 	wg sync.WaitGroup
 }
@@ -69,9 +70,19 @@ func (s *StopWaiterSafe) StopOnly() {
 	if s.started && !s.stopped {
 		s.stopFunc()
 	}
+	if !s.stopped {
+		s.stoppedAt = time.Now()
+	}
 	s.stopped = true
 }
 
+// stoppedTime returns when the stop waiter was stopped, cancelling its threads, if it has been.
+func (s *StopWaiterSafe) stoppedTime() (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stoppedAt, s.stopped
+}
+
 // Stopping multiple times, even before start, will work
 func (s *StopWaiterSafe) StopAndWait() {
 	s.StopOnly()
@@ -99,6 +110,11 @@ func (s *StopWaiterSafe) GetWaitChannel() (<-chan interface{}, error) {
 
 // If stop was already called, thread might silently not be launched
 func (s *StopWaiterSafe) LaunchThread(foo func(context.Context)) error {
+	return s.launchThread(foo, foo, false)
+}
+
+// launchThread runs foo in a thread tracked by the registry under the name of the function named.
+func (s *StopWaiterSafe) launchThread(foo func(context.Context), named interface{}, iterative bool) error {
 	ctx, err := s.GetContext()
 	if err != nil {
 		return err
@@ -107,8 +123,10 @@ func (s *StopWaiterSafe) LaunchThread(foo func(context.Context)) error {
 		return nil
 	}
 	s.wg.Add(1)
+	id := registerThread(s, named, iterative)
 	go func() {
 		foo(ctx)
+		unregisterThread(id)
 		s.wg.Done()
 	}()
 	return nil
@@ -122,7 +140,7 @@ func (s *StopWaiterSafe) LaunchUntrackedThread(foo func()) {
 // call function iteratively in a thread.
 // input param return value is how long to wait before next invocation
 func (s *StopWaiterSafe) CallIteratively(foo func(context.Context) time.Duration) error {
-	return s.LaunchThread(func(ctx context.Context) {
+	return s.launchThread(func(ctx context.Context) {
 		for {
 			interval := foo(ctx)
 			if ctx.Err() != nil {
@@ -136,7 +154,7 @@ func (s *StopWaiterSafe) CallIteratively(foo func(context.Context) time.Duration
 			case <-timer.C:
 			}
 		}
-	})
+	}, foo, true)
 }
 
 // May panic on race conditions instead of returning errors
@@ -0,0 +1,186 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type AssertionScheduleConfig struct {
+	Interval         time.Duration `koanf:"interval"`
+	EarlyWindow      time.Duration `koanf:"early-window"`
+	LateWindow       time.Duration `koanf:"late-window"`
+	LowGasPercentile float64       `koanf:"low-gas-percentile"`
+	GasPriceSamples  int           `koanf:"gas-price-samples"`
+}
+
+var DefaultAssertionScheduleConfig = AssertionScheduleConfig{
+	Interval:         0,
+	EarlyWindow:      10 * time.Minute,
+	LateWindow:       30 * time.Minute,
+	LowGasPercentile: 25,
+	GasPriceSamples:  120,
+}
+
+func AssertionScheduleConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".interval", DefaultAssertionScheduleConfig.Interval, "how long after the previous assertion to post a new one (0 to post as soon as there's something to assert)")
+	f.Duration(prefix+".early-window", DefaultAssertionScheduleConfig.EarlyWindow, "how long before the interval is up an assertion may be posted if L1 gas is low")
+	f.Duration(prefix+".late-window", DefaultAssertionScheduleConfig.LateWindow, "how long past the interval an assertion may be held back waiting for low L1 gas, after which it's posted regardless")
+	f.Float64(prefix+".low-gas-percentile", DefaultAssertionScheduleConfig.LowGasPercentile, "percentile of recently sampled L1 gas prices at or below which gas is considered low")
+	f.Int(prefix+".gas-price-samples", DefaultAssertionScheduleConfig.GasPriceSamples, "number of L1 gas prices to keep, sampled each time the staker acts")
+}
+
+// How many staker transactions to remember the cost of for projections
+const stakingCostRecords = 1024
+
+const month = 30 * 24 * time.Hour
+
+type stakingCostRecord struct {
+	time      time.Time
+	gasUsed   uint64
+	assertion bool
+}
+
+// assertionScheduler times assertions to low gas periods within the bounds configured, and tracks what staking costs.
+type assertionScheduler struct {
+	config    AssertionScheduleConfig
+	mutex     sync.Mutex
+	gasPrices []*big.Int // oldest first
+	costs     []stakingCostRecord
+}
+
+func newAssertionScheduler(config *AssertionScheduleConfig) *assertionScheduler {
+	return &assertionScheduler{config: *config}
+}
+
+func (s *assertionScheduler) recordGasPrice(gasPrice *big.Int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.gasPrices = append(s.gasPrices, new(big.Int).Set(gasPrice))
+	if len(s.gasPrices) > s.config.GasPriceSamples {
+		s.gasPrices = s.gasPrices[len(s.gasPrices)-s.config.GasPriceSamples:]
+	}
+}
+
+func (s *assertionScheduler) recordCost(receipt *types.Receipt, assertion bool, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.costs = append(s.costs, stakingCostRecord{now, receipt.GasUsed, assertion})
+	if len(s.costs) > stakingCostRecords {
+		s.costs = s.costs[len(s.costs)-stakingCostRecords:]
+	}
+}
+
+// gasPricePercentile returns the percentile of the sampled gas prices, or nil if there are none.
+// The caller must hold the mutex.
+func (s *assertionScheduler) gasPricePercentile(percentile float64) *big.Int {
+	if len(s.gasPrices) == 0 {
+		return nil
+	}
+	sorted := make([]*big.Int, len(s.gasPrices))
+	copy(sorted, s.gasPrices)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	index := int(percentile / 100 * float64(len(sorted)-1))
+	if index < 0 {
+		index = 0
+	} else if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// shouldPost returns whether to post an assertion sinceLast after the previous one, and why.
+// Within the window around the interval it's posted once gas is low, and at the end of the window regardless.
+func (s *assertionScheduler) shouldPost(sinceLast time.Duration) (bool, string) {
+	if s.config.Interval == 0 {
+		return true, "unscheduled"
+	}
+	if sinceLast >= s.config.Interval+s.config.LateWindow {
+		return true, "deadline"
+	}
+	if sinceLast < s.config.Interval-s.config.EarlyWindow {
+		return false, "too early"
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.gasPrices) == 0 {
+		return true, "no gas prices sampled"
+	}
+	current := s.gasPrices[len(s.gasPrices)-1]
+	if current.Cmp(s.gasPricePercentile(s.config.LowGasPercentile)) <= 0 {
+		return true, "low gas"
+	}
+	return false, "waiting for low gas"
+}
+
+// StakingCostProjection projects what staking costs over a month, from the gas recent staker transactions used.
+type StakingCostProjection struct {
+	AssertionInterval    hexutil.Uint64 `json:"assertionIntervalSeconds"` // configured, or observed if unscheduled
+	AssertionsPerMonth   float64        `json:"assertionsPerMonth"`
+	AssertionGas         hexutil.Uint64 `json:"assertionGas"`         // average of those observed
+	OtherGasPerMonth     hexutil.Uint64 `json:"otherGasPerMonth"`     // confirmations, challenges and stake moves
+	GasPrice             *hexutil.Big   `json:"gasPrice"`             // expected, given the schedule and recent prices
+	ProjectedMonthlyGas  hexutil.Uint64 `json:"projectedMonthlyGas"`  // of all staker transactions
+	ProjectedMonthlyCost *hexutil.Big   `json:"projectedMonthlyCost"` // in wei
+	ObservedTransactions hexutil.Uint64 `json:"observedTransactions"`
+	ObservedSince        hexutil.Uint64 `json:"observedSince"` // unix time of the oldest transaction observed
+}
+
+func (s *assertionScheduler) projectCost(now time.Time) *StakingCostProjection {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	projection := &StakingCostProjection{
+		AssertionInterval:    hexutil.Uint64(s.config.Interval / time.Second),
+		ObservedTransactions: hexutil.Uint64(len(s.costs)),
+	}
+	var assertions, assertionGas, otherGas uint64
+	for _, record := range s.costs {
+		if record.assertion {
+			assertions++
+			assertionGas += record.gasUsed
+		} else {
+			otherGas += record.gasUsed
+		}
+	}
+	if assertions > 0 {
+		projection.AssertionGas = hexutil.Uint64(assertionGas / assertions)
+	}
+	var observed time.Duration
+	if len(s.costs) > 0 {
+		projection.ObservedSince = hexutil.Uint64(s.costs[0].time.Unix())
+		observed = now.Sub(s.costs[0].time)
+	}
+	if observed > 0 {
+		projection.OtherGasPerMonth = hexutil.Uint64(float64(otherGas) * float64(month) / float64(observed))
+	}
+	if s.config.Interval > 0 {
+		projection.AssertionsPerMonth = float64(month) / float64(s.config.Interval)
+	} else if observed > 0 && assertions > 0 {
+		projection.AssertionsPerMonth = float64(assertions) * float64(month) / float64(observed)
+		projection.AssertionInterval = hexutil.Uint64(observed / time.Duration(assertions) / time.Second)
+	}
+	monthlyGas := uint64(projection.AssertionsPerMonth*float64(projection.AssertionGas)) + uint64(projection.OtherGasPerMonth)
+	projection.ProjectedMonthlyGas = hexutil.Uint64(monthlyGas)
+
+	// A schedule aims to post at low gas, otherwise assume the median price
+	percentile := 50.0
+	if s.config.Interval > 0 {
+		percentile = s.config.LowGasPercentile
+	}
+	gasPrice := s.gasPricePercentile(percentile)
+	if gasPrice == nil {
+		gasPrice = new(big.Int)
+	}
+	projection.GasPrice = (*hexutil.Big)(new(big.Int).Set(gasPrice))
+	projection.ProjectedMonthlyCost = (*hexutil.Big)(new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(monthlyGas)))
+	return projection
+}
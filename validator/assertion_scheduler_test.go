@@ -0,0 +1,70 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestAssertionSchedule(t *testing.T) {
+	config := DefaultAssertionScheduleConfig
+	config.Interval = time.Hour
+	scheduler := newAssertionScheduler(&config)
+	for i := int64(1); i <= 10; i++ {
+		scheduler.recordGasPrice(big.NewInt(i * 1e9))
+	}
+
+	if post, _ := scheduler.shouldPost(30 * time.Minute); post {
+		Fail(t, "posted before the window")
+	}
+	if post, _ := scheduler.shouldPost(time.Hour); post {
+		Fail(t, "posted within the window while gas is high")
+	}
+	if post, reason := scheduler.shouldPost(time.Hour + config.LateWindow); !post || reason != "deadline" {
+		Fail(t, "didn't post at the deadline", reason)
+	}
+	scheduler.recordGasPrice(big.NewInt(2e9))
+	if post, reason := scheduler.shouldPost(time.Hour - config.EarlyWindow); !post || reason != "low gas" {
+		Fail(t, "didn't post early while gas is low", reason)
+	}
+
+	unscheduled := newAssertionScheduler(&DefaultAssertionScheduleConfig)
+	if post, _ := unscheduled.shouldPost(0); !post {
+		Fail(t, "unscheduled assertions should post immediately")
+	}
+}
+
+func TestStakingCostProjection(t *testing.T) {
+	config := DefaultAssertionScheduleConfig
+	config.Interval = 6 * time.Hour
+	scheduler := newAssertionScheduler(&config)
+	scheduler.recordGasPrice(big.NewInt(10e9))
+	start := time.Now()
+	scheduler.recordCost(&types.Receipt{GasUsed: 300000}, true, start)
+	scheduler.recordCost(&types.Receipt{GasUsed: 100000}, true, start.Add(time.Hour))
+	scheduler.recordCost(&types.Receipt{GasUsed: 50000}, false, start.Add(2*time.Hour))
+
+	projection := scheduler.projectCost(start.Add(30 * 24 * time.Hour))
+	if projection.AssertionsPerMonth != 120 {
+		Fail(t, "unexpected assertions per month", projection.AssertionsPerMonth)
+	}
+	if projection.AssertionGas != 200000 {
+		Fail(t, "unexpected assertion gas", projection.AssertionGas)
+	}
+	if projection.OtherGasPerMonth != 50000 {
+		Fail(t, "unexpected other gas per month", projection.OtherGasPerMonth)
+	}
+	expectedGas := uint64(120*200000 + 50000)
+	if uint64(projection.ProjectedMonthlyGas) != expectedGas {
+		Fail(t, "unexpected monthly gas", projection.ProjectedMonthlyGas)
+	}
+	expectedCost := new(big.Int).Mul(big.NewInt(10e9), new(big.Int).SetUint64(expectedGas))
+	if projection.ProjectedMonthlyCost.ToInt().Cmp(expectedCost) != 0 {
+		Fail(t, "unexpected monthly cost", projection.ProjectedMonthlyCost)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
-	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -253,33 +252,35 @@ func (b *BlockChallengeBackend) IssueExecChallenge(
 	startSegment int,
 	numsteps uint64,
 ) (*types.Transaction, error) {
-	position := oldState.Segments[startSegment].Position
+	machineStatuses, globalStateHashes, err := b.execChallengeInfo(oldState.Segments[startSegment].Position)
+	if err != nil {
+		return nil, err
+	}
+	return core.sendMove(oldState, &ChallengeMove{
+		Kind:              ChallengeMoveExecution,
+		SegmentIndex:      uint64(startSegment),
+		MachineStatuses:   machineStatuses,
+		GlobalStateHashes: globalStateHashes,
+		Steps:             numsteps,
+	})
+}
+
+// execChallengeInfo returns the machine statuses and global state hashes either side of the position.
+func (b *BlockChallengeBackend) execChallengeInfo(position uint64) ([2]uint8, [2][32]byte, error) {
 	machineStatuses := [2]uint8{}
 	globalStates := [2]GoGlobalState{}
 	var err error
 	globalStates[0], machineStatuses[0], err = b.GetInfoAtStep(position)
 	if err != nil {
-		return nil, err
+		return machineStatuses, [2][32]byte{}, err
 	}
 	globalStates[1], machineStatuses[1], err = b.GetInfoAtStep(position + 1)
 	if err != nil {
-		return nil, err
+		return machineStatuses, [2][32]byte{}, err
 	}
 	globalStateHashes := [2][32]byte{
 		globalStates[0].Hash(),
 		globalStates[1].Hash(),
 	}
-	return core.con.ChallengeExecution(
-		core.auth,
-		core.challengeIndex,
-		challengegen.ChallengeLibSegmentSelection{
-			OldSegmentsStart:  oldState.Start,
-			OldSegmentsLength: new(big.Int).Sub(oldState.End, oldState.Start),
-			OldSegments:       oldState.RawSegments,
-			ChallengePosition: big.NewInt(int64(startSegment)),
-		},
-		machineStatuses,
-		globalStateHashes,
-		big.NewInt(int64(numsteps)),
-	)
+	return machineStatuses, globalStateHashes, nil
 }
@@ -22,9 +22,11 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/openmetrics"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 )
 
@@ -52,8 +54,14 @@ type BlockValidator struct {
 	globalPosNextSend        GlobalStatePosition
 
 	config                   *BlockValidatorConfig
+	witnessArchive           *WitnessArchive
+	remoteValidation         *RemoteValidationPool // nil when validating locally
+	coordinator              *ValidatorCoordinator // nil unless sharing validation with other instances
 	atomicValidationsRunning int32
+	atomicValidationsMemory  int64 // estimated bytes held by running validations
 	concurrentRunsLimit      int32
+	concurrentMemoryLimit    int64 // 0 for no limit
+	validationCosts          validationCostLog
 
 	sendValidationsChan chan struct{}
 	checkProgressChan   chan struct{}
@@ -61,41 +69,72 @@ type BlockValidator struct {
 }
 
 type BlockValidatorConfig struct {
-	Enable                   bool   `koanf:"enable"`
-	OutputPath               string `koanf:"output-path"`
-	ConcurrentRunsLimit      int    `koanf:"concurrent-runs-limit"`
-	CurrentModuleRoot        string `koanf:"current-module-root"`
-	PendingUpgradeModuleRoot string `koanf:"pending-upgrade-module-root"`
-	StorePreimages           bool   `koanf:"store-preimages"`
+	Enable                   bool                   `koanf:"enable"`
+	OutputPath               string                 `koanf:"output-path"`
+	ConcurrentRunsLimit      int                    `koanf:"concurrent-runs-limit"`
+	ConcurrentRunsMemoryMB   uint64                 `koanf:"concurrent-runs-memory-mb"`
+	CurrentModuleRoot        string                 `koanf:"current-module-root"`
+	PendingUpgradeModuleRoot string                 `koanf:"pending-upgrade-module-root"`
+	StorePreimages           bool                   `koanf:"store-preimages"`
+	WitnessArchive           bool                   `koanf:"witness-archive"`
+	WitnessArchiveRetention  uint64                 `koanf:"witness-archive-retention"`
+	ResultCache              bool                   `koanf:"result-cache"`
+	MachineSnapshots         MachineSnapshotConfig  `koanf:"machine-snapshots"`
+	Remote                   RemoteValidationConfig `koanf:"remote"`
 }
 
 func BlockValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultBlockValidatorConfig.Enable, "enable block validator")
 	f.String(prefix+".output-path", DefaultBlockValidatorConfig.OutputPath, "")
-	f.Int(prefix+".concurrent-runs-limit", DefaultBlockValidatorConfig.ConcurrentRunsLimit, "")
+	f.Int(prefix+".concurrent-runs-limit", DefaultBlockValidatorConfig.ConcurrentRunsLimit, "number of blocks to validate concurrently (0 for the number of CPUs)")
+	f.Uint64(prefix+".concurrent-runs-memory-mb", DefaultBlockValidatorConfig.ConcurrentRunsMemoryMB, "limit on the estimated memory, in megabytes, of the preimages and batches held by concurrent validations; one validation always runs regardless (0 for no limit)")
 	f.String(prefix+".current-module-root", DefaultBlockValidatorConfig.CurrentModuleRoot, "current wasm module root ('current' read from chain, 'latest' from machines/latest dir, or provide hash)")
 	f.String(prefix+".pending-upgrade-module-root", DefaultBlockValidatorConfig.PendingUpgradeModuleRoot, "pending upgrade wasm module root to additionally validate (hash, 'latest' or empty)")
 	f.Bool(prefix+".store-preimages", DefaultBlockValidatorConfig.StorePreimages, "store preimages of running machines (higher memory cost, better debugging, potentially better performance)")
+	f.Bool(prefix+".witness-archive", DefaultBlockValidatorConfig.WitnessArchive, "archive the preimages, batches and delayed messages needed to re-validate every validated block, so fraud proofs never depend on external data sources")
+	f.Uint64(prefix+".witness-archive-retention", DefaultBlockValidatorConfig.WitnessArchiveRetention, "how many blocks before the latest confirmed assertion to keep in the witness archive; older witnesses are pruned as assertions are confirmed (0 = keep all)")
+	f.Bool(prefix+".result-cache", DefaultBlockValidatorConfig.ResultCache, "persist the end state of every block executed, keyed by module root, start state and messages read, so it's never executed again, e.g. after a restart")
+	MachineSnapshotConfigAddOptions(prefix+".machine-snapshots", f)
+	RemoteValidationConfigAddOptions(prefix+".remote", f)
 }
 
 var DefaultBlockValidatorConfig = BlockValidatorConfig{
 	Enable:                   false,
 	OutputPath:               "./target/output",
 	ConcurrentRunsLimit:      0,
+	ConcurrentRunsMemoryMB:   0,
 	CurrentModuleRoot:        "current",
 	PendingUpgradeModuleRoot: "latest",
 	StorePreimages:           false,
+	WitnessArchive:           false,
+	WitnessArchiveRetention:  10000,
+	MachineSnapshots:         DefaultMachineSnapshotConfig,
+	Remote:                   DefaultRemoteValidationConfig,
 }
 
 var TestBlockValidatorConfig = BlockValidatorConfig{
 	Enable:                   false,
 	OutputPath:               "./target/output",
 	ConcurrentRunsLimit:      0,
+	ConcurrentRunsMemoryMB:   0,
 	CurrentModuleRoot:        "latest",
 	PendingUpgradeModuleRoot: "latest",
 	StorePreimages:           false,
+	WitnessArchive:           false,
+	WitnessArchiveRetention:  10000,
+	MachineSnapshots:         DefaultMachineSnapshotConfig,
+	Remote:                   DefaultRemoteValidationConfig,
 }
 
+var (
+	validationsRunningGauge    = metrics.NewRegisteredGauge("arb/validator/validations/running", nil)
+	validationsMemoryGauge     = metrics.NewRegisteredGauge("arb/validator/validations/memory", nil)
+	validationsOutOfOrderMeter = metrics.NewRegisteredMeter("arb/validator/validations/outoforder", nil)
+)
+
+// How many missing trie nodes to heal while preparing a block's validation before giving up
+const maxStateHealsPerBlock = 256
+
 const validationStatusUnprepared uint32 = 0 // waiting for validationEntry to be populated
 const validationStatusPrepared uint32 = 1   // ready to undergo validation
 const validationStatusValid uint32 = 2      // validation succeeded
@@ -118,7 +157,18 @@ func NewBlockValidator(
 	das arbstate.DataAvailabilityReader,
 	reorgingToBlock *types.Block,
 ) (*BlockValidator, error) {
+	var remoteValidation *RemoteValidationPool
+	if len(config.Remote.URLs) > 0 {
+		var err error
+		remoteValidation, err = NewRemoteValidationPool(&config.Remote)
+		if err != nil {
+			return nil, err
+		}
+	}
 	concurrent := config.ConcurrentRunsLimit
+	if concurrent == 0 && remoteValidation != nil {
+		concurrent = remoteValidation.Workers()
+	}
 	if concurrent == 0 {
 		concurrent = runtime.NumCPU()
 	}
@@ -140,7 +190,15 @@ func NewBlockValidator(
 		checkProgressChan:       make(chan struct{}, 1),
 		progressChan:            make(chan uint64, 1),
 		concurrentRunsLimit:     int32(concurrent),
+		concurrentMemoryLimit:   int64(config.ConcurrentRunsMemoryMB) * 1024 * 1024,
 		config:                  config,
+		remoteValidation:        remoteValidation,
+	}
+	if config.WitnessArchive {
+		validator.witnessArchive = NewWitnessArchive(db)
+	}
+	if config.ResultCache {
+		validator.SetResultCache(NewValidationResultCache(db))
 	}
 	err = validator.readLastBlockValidatedDbInfo(reorgingToBlock)
 	if err != nil {
@@ -233,7 +291,17 @@ func (v *BlockValidator) readLastBlockValidatedDbInfo(reorgingToBlock *types.Blo
 }
 
 func (v *BlockValidator) prepareBlock(ctx context.Context, header *types.Header, prevHeader *types.Header, msg arbstate.MessageWithMetadata, validationStatus *validationStatus) {
-	preimages, readBatchInfo, hasDelayedMessage, delayedMsgToRead, err := BlockDataForValidation(ctx, v.blockchain, v.inboxReader, header, prevHeader, msg, v.config.StorePreimages)
+	// The witness archive and remote spawners need every preimage the machine reads, so they must be recorded
+	recordPreimages := v.config.StorePreimages || v.witnessArchive != nil || v.remoteValidation != nil
+	var preimages map[common.Hash][]byte
+	var readBatchInfo []BatchInfo
+	var hasDelayedMessage bool
+	var delayedMsgToRead uint64
+	err := v.stateHealer.Heal(ctx, maxStateHealsPerBlock, func() error {
+		var err error
+		preimages, readBatchInfo, hasDelayedMessage, delayedMsgToRead, err = BlockDataForValidation(ctx, v.blockchain, v.inboxReader, header, prevHeader, msg, recordPreimages)
+		return err
+	})
 	if err != nil {
 		log.Error("failed to set up validation", "err", err, "header", header, "prevHeader", prevHeader)
 		return
@@ -409,14 +477,40 @@ func (v *BlockValidator) SetCurrentWasmModuleRoot(hash common.Hash) error {
 	return fmt.Errorf("unexpected wasmModuleRoot! cannot validate! found %v , current %v, pending %v", hash, v.currentWasmModuleRoot, v.pendingWasmModuleRoot)
 }
 
-func (v *BlockValidator) validate(ctx context.Context, validationStatus *validationStatus, seqMsg []byte) {
+// validationMemory estimates the bytes a validation holds onto while it runs.
+func validationMemory(entry *validationEntry, seqMsg []byte) int64 {
+	size := len(seqMsg)
+	for _, preimage := range entry.Preimages {
+		size += common.HashLength + len(preimage)
+	}
+	for _, batch := range entry.BatchInfo {
+		size += len(batch.Data)
+	}
+	return int64(size)
+}
+
+// canLaunchValidation returns whether there's room in the worker pool for another validation holding memory
+// bytes. With nothing running it always returns true, so a validation larger than the limit still runs alone.
+func (v *BlockValidator) canLaunchValidation(memory int64) bool {
+	running := atomic.LoadInt32(&v.atomicValidationsRunning)
+	if running >= v.concurrentRunsLimit {
+		return false
+	}
+	if running == 0 || v.concurrentMemoryLimit == 0 {
+		return true
+	}
+	return atomic.LoadInt64(&v.atomicValidationsMemory)+memory <= v.concurrentMemoryLimit
+}
+
+func (v *BlockValidator) validate(ctx context.Context, validationStatus *validationStatus, seqMsg []byte, memory int64) {
 	if atomic.LoadUint32(&validationStatus.Status) < validationStatusPrepared {
 		log.Error("attempted to validate unprepared validation entry")
 		return
 	}
 	entry := validationStatus.Entry
 	defer func() {
-		atomic.AddInt32(&v.atomicValidationsRunning, -1)
+		validationsMemoryGauge.Update(atomic.AddInt64(&v.atomicValidationsMemory, -memory))
+		validationsRunningGauge.Update(int64(atomic.AddInt32(&v.atomicValidationsRunning, -1)))
 		select {
 		case v.sendValidationsChan <- struct{}{}:
 		default:
@@ -426,16 +520,24 @@ func (v *BlockValidator) validate(ctx context.Context, validationStatus *validat
 		Number: entry.StartPosition.BatchNumber,
 		Data:   seqMsg,
 	})
-	log.Info("starting validation for block", "blockNr", entry.BlockNumber)
+	ctx, traceID := openmetrics.StartTrace(ctx)
+	log.Info("starting validation for block", "traceId", traceID, "blockNr", entry.BlockNumber)
+	var archivedDelayedMsg []byte
 	for _, moduleRoot := range validationStatus.ModuleRoots {
-		before := time.Now()
-		gsEnd, delayedMsg, err := v.executeBlock(ctx, entry, moduleRoot)
-		duration := time.Since(before)
+		var gsEnd GoGlobalState
+		var delayedMsg []byte
+		var cost ValidationCost
+		var err error
+		if v.coordinator != nil {
+			gsEnd, delayedMsg, cost, err = v.executeBlockCoordinated(ctx, entry, moduleRoot)
+		} else {
+			gsEnd, delayedMsg, cost, err = v.runBlock(ctx, entry, moduleRoot)
+		}
 		if err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				log.Info("Validation of block canceled", "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "err", err)
 			} else {
-				log.Error("Validation of block failed", "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "moduleRoot", moduleRoot, "err", err)
+				log.Error("Validation of block failed", "traceId", traceID, "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "moduleRoot", moduleRoot, "err", err)
 			}
 			return
 		}
@@ -459,18 +561,132 @@ func (v *BlockValidator) validate(ctx context.Context, validationStatus *validat
 			return
 		}
 
-		log.Info("validation succeeded", "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "moduleRoot", moduleRoot, "time", duration)
+		if cost.Cached {
+			log.Info("validation succeeded from cached result", "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "moduleRoot", moduleRoot)
+		} else {
+			v.recordValidationCost(ctx, entry.BlockNumber, moduleRoot, cost)
+			log.Info("validation succeeded", "traceId", traceID, "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "moduleRoot", moduleRoot, "time", cost.WallTime, "steps", cost.Steps, "preimageBytes", cost.PreimageBytes)
+		}
+		archivedDelayedMsg = delayedMsg
+	}
+
+	if v.witnessArchive != nil {
+		err := v.witnessArchive.Store(newWitnessArchiveEntry(entry, archivedDelayedMsg))
+		if err != nil {
+			log.Error("failed to archive block witness", "blockNr", entry.BlockNumber, "err", err)
+		}
 	}
 
+	if entry.BlockNumber > atomic.LoadUint64(&v.lastBlockValidated)+1 {
+		// finished before an earlier block; progressValidated only advances once the earlier ones are valid too
+		validationsOutOfOrderMeter.Mark(1)
+	}
 	atomic.StoreUint32(&validationStatus.Status, validationStatusValid) // after that - validation entry could be deleted from map
 	v.checkProgressChan <- struct{}{}
 }
 
+// runBlock executes the block on this instance, or a validation spawner if configured.
+func (v *BlockValidator) runBlock(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, ValidationCost, error) {
+	if v.remoteValidation != nil {
+		return v.executeBlockRemotely(ctx, entry, moduleRoot)
+	}
+	return v.executeBlock(ctx, entry, moduleRoot)
+}
+
+// SetCoordinator shares the validation of blocks with the other instances coordinated.
+// Must be called before Start.
+func (v *BlockValidator) SetCoordinator(coordinator *ValidatorCoordinator) {
+	v.coordinator = coordinator
+}
+
+// executeBlockCoordinated executes the block if this instance claims it, publishing the result for the others.
+// If another instance claimed it, it waits for that instance's result instead, taking over should its claim lapse.
+func (v *BlockValidator) executeBlockCoordinated(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, ValidationCost, error) {
+	start := time.Now()
+	waiting := false
+	defer func() {
+		if waiting {
+			atomic.AddInt32(&v.atomicValidationsRunning, 1)
+		}
+	}()
+	for {
+		gsEnd, found, err := v.coordinator.result(ctx, moduleRoot, entry.BlockNumber, entry.start())
+		if err != nil {
+			log.Warn("failed to read validation result published by another instance", "blockNr", entry.BlockNumber, "err", err)
+		}
+		if found {
+			delayedMsg, err := v.readDelayedMsg(entry)
+			if err != nil {
+				return GoGlobalState{}, nil, ValidationCost{}, err
+			}
+			coordinatorPeerBlocksCounter.Inc(1)
+			return gsEnd, delayedMsg, ValidationCost{WallTime: time.Since(start), Cached: true}, nil
+		}
+		claimed, err := v.coordinator.claim(ctx, entry.BlockNumber)
+		if err != nil {
+			return GoGlobalState{}, nil, ValidationCost{}, err
+		}
+		if claimed {
+			if waiting {
+				log.Info("taking over validation from another instance", "blockNr", entry.BlockNumber)
+				atomic.AddInt32(&v.atomicValidationsRunning, 1)
+				waiting = false
+			}
+			gsEnd, delayedMsg, cost, err := v.runBlock(ctx, entry, moduleRoot)
+			if err != nil {
+				return gsEnd, delayedMsg, cost, err
+			}
+			coordinatorClaimedBlocksCounter.Inc(1)
+			if err := v.coordinator.publishResult(ctx, moduleRoot, entry.BlockNumber, entry.start(), gsEnd); err != nil {
+				log.Warn("failed to publish validation result", "blockNr", entry.BlockNumber, "err", err)
+			}
+			return gsEnd, delayedMsg, cost, nil
+		}
+		if !waiting {
+			// Waiting on another instance doesn't take up a run, so more blocks can be claimed meanwhile
+			waiting = true
+			atomic.AddInt32(&v.atomicValidationsRunning, -1)
+			select {
+			case v.sendValidationsChan <- struct{}{}:
+			default:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return GoGlobalState{}, nil, ValidationCost{}, ctx.Err()
+		case <-time.After(v.coordinator.config.PollInterval):
+		}
+	}
+}
+
+// executeBlockRemotely sends the block's witness to a validation spawner to execute.
+func (v *BlockValidator) executeBlockRemotely(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, ValidationCost, error) {
+	start := time.Now()
+	delayedMsg, err := v.readDelayedMsg(entry)
+	if err != nil {
+		return GoGlobalState{}, nil, ValidationCost{}, err
+	}
+	gsEnd, batchHash, found := v.cachedResult(entry, moduleRoot, delayedMsg)
+	if found {
+		return gsEnd, delayedMsg, ValidationCost{WallTime: time.Since(start), Cached: true}, nil
+	}
+	// spawners can't reach the DAS, so the payloads must travel with the witness
+	if err := recordDasPreimages(ctx, entry.BatchInfo, entry.Preimages, v.blockchain, v.daService); err != nil {
+		return GoGlobalState{}, nil, ValidationCost{}, err
+	}
+	gsEnd, cost, err := v.remoteValidation.Validate(ctx, newWitnessArchiveEntry(entry, delayedMsg), moduleRoot)
+	if err == nil && !cost.Cached {
+		v.cacheResult(entry, moduleRoot, batchHash, gsEnd)
+	}
+	return gsEnd, delayedMsg, cost, err
+}
+
 func (v *BlockValidator) sendValidations(ctx context.Context) {
 	v.reorgMutex.Lock()
 	defer v.reorgMutex.Unlock()
 	var batchCount uint64
 	for atomic.LoadInt32(&v.reorgsPending) == 0 {
+		// cheap check before loading the next entry; canLaunchValidation also accounts for its memory
 		if atomic.LoadInt32(&v.atomicValidationsRunning) >= v.concurrentRunsLimit {
 			return
 		}
@@ -530,6 +746,15 @@ func (v *BlockValidator) sendValidations(ctx context.Context) {
 		if atomic.LoadUint32(&validationStatus.Status) == validationStatusUnprepared {
 			return
 		}
+		seqMsg, ok := seqBatchEntry.([]byte)
+		if !ok {
+			log.Error("sequencer message bad format", "blockNr", v.nextBlockToValidate, "msgNum", v.globalPosNextSend.BatchNumber)
+			return
+		}
+		memory := validationMemory(validationStatus.Entry, seqMsg)
+		if !v.canLaunchValidation(memory) {
+			return
+		}
 		startPos, endPos, err := GlobalStatePositionsFor(v.inboxTracker, nextMsg, v.globalPosNextSend.BatchNumber)
 		if err != nil {
 			log.Error("failed calculating position for validation", "err", err, "msg", nextMsg, "batch", v.globalPosNextSend.BatchNumber)
@@ -539,21 +764,15 @@ func (v *BlockValidator) sendValidations(ctx context.Context) {
 			log.Error("inconsistent pos mapping", "msg", nextMsg, "expected", v.globalPosNextSend, "found", startPos)
 			return
 		}
-		atomic.AddInt32(&v.atomicValidationsRunning, 1)
+		validationsRunningGauge.Update(int64(atomic.AddInt32(&v.atomicValidationsRunning, 1)))
+		validationsMemoryGauge.Update(atomic.AddInt64(&v.atomicValidationsMemory, memory))
 		validationStatus.Entry.StartPosition = startPos
 		validationStatus.Entry.EndPosition = endPos
 
-		batchNum := validationStatus.Entry.StartPosition.BatchNumber
-		seqMsg, ok := seqBatchEntry.([]byte)
-		if !ok {
-			log.Error("sequencer message bad format", "blockNr", v.nextBlockToValidate, "msgNum", batchNum)
-			return
-		}
-
 		v.LaunchThread(func(ctx context.Context) {
 			validationCtx, cancel := context.WithCancel(ctx)
 			validationStatus.Cancel = cancel
-			v.validate(validationCtx, validationStatus, seqMsg)
+			v.validate(validationCtx, validationStatus, seqMsg, memory)
 			cancel()
 		})
 
@@ -614,6 +833,11 @@ func (v *BlockValidator) progressValidated() {
 				v.sequencerBatches.Delete(batch)
 			}
 			atomic.StoreUint64(&v.earliestBatchKept, seqMsgNr)
+			if v.resultCache != nil {
+				if err := v.resultCache.DeleteBeforeBatch(seqMsgNr); err != nil {
+					log.Warn("failed to prune validation result cache", "batch", seqMsgNr, "err", err)
+				}
+			}
 		}
 
 		v.lastBlockValidatedMutex.Lock()
@@ -622,6 +846,7 @@ func (v *BlockValidator) progressValidated() {
 		v.lastBlockValidatedMutex.Unlock()
 
 		v.validationEntries.Delete(checkingBlock)
+		validatedMessagesMeter.Mark(1)
 		select {
 		case v.progressChan <- checkingBlock:
 		default:
@@ -633,6 +858,35 @@ func (v *BlockValidator) progressValidated() {
 	}
 }
 
+// WitnessArchive returns the block witness archive, or nil if it isn't enabled.
+func (v *BlockValidator) WitnessArchive() *WitnessArchive {
+	return v.witnessArchive
+}
+
+// AssertionConfirmed prunes the witness archive up to the given confirmed state, less the configured retention.
+func (v *BlockValidator) AssertionConfirmed(confirmed GoGlobalState) error {
+	if v.witnessArchive == nil || v.config.WitnessArchiveRetention == 0 {
+		return nil
+	}
+	header := v.blockchain.GetHeaderByHash(confirmed.BlockHash)
+	if header == nil {
+		// not built yet, so there's nothing to prune up to it
+		return nil
+	}
+	confirmedBlock := header.Number.Uint64()
+	if confirmedBlock <= v.config.WitnessArchiveRetention {
+		return nil
+	}
+	pruned, err := v.witnessArchive.PruneConfirmed(confirmed.Batch, confirmedBlock-v.config.WitnessArchiveRetention)
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		log.Info("pruned confirmed witnesses from the witness archive", "count", pruned, "confirmedBlock", confirmedBlock, "confirmedBatch", confirmed.Batch)
+	}
+	return nil
+}
+
 func (v *BlockValidator) LastBlockValidated() uint64 {
 	return atomic.LoadUint64(&v.lastBlockValidated)
 }
@@ -667,6 +921,12 @@ func (v *BlockValidator) reorgToBatchCountImpl(count uint64) {
 		v.sequencerBatches.Delete(i)
 	}
 	v.nextBatchKept = count
+	if v.witnessArchive != nil && count < localBatchCount {
+		err := v.witnessArchive.DeleteFromBatch(count)
+		if err != nil {
+			log.Error("failed to remove reorged batches from witness archive", "batch", count, "err", err)
+		}
+	}
 }
 
 func (v *BlockValidator) ProcessBatches(pos uint64, batches [][]byte) {
@@ -820,6 +1080,9 @@ func (v *BlockValidator) Initialize() error {
 
 func (v *BlockValidator) Start(ctxIn context.Context) error {
 	v.StopWaiter.Start(ctxIn)
+	if v.remoteValidation != nil {
+		v.remoteValidation.Start(v.GetContext())
+	}
 	v.LaunchThread(func(ctx context.Context) {
 		// `progressValidated` and `sendValidations` should both only do `concurrentRunsLimit` iterations of work,
 		// so they won't stomp on each other and prevent the other from running.
@@ -844,6 +1107,13 @@ func (v *BlockValidator) Start(ctxIn context.Context) error {
 	return nil
 }
 
+func (v *BlockValidator) StopAndWait() {
+	v.StopWaiter.StopAndWait()
+	if v.remoteValidation != nil {
+		v.remoteValidation.StopAndWait()
+	}
+}
+
 // can only be used from One thread
 func (v *BlockValidator) WaitForBlock(blockNumber uint64, timeout time.Duration) bool {
 	timer := time.NewTimer(timeout)
@@ -13,4 +13,12 @@ type lastBlockValidatedDbInfo struct {
 
 var (
 	lastBlockValidatedInfoKey []byte = []byte("_lastBlockValidatedInfo") // contains a rlp encoded lastBlockValidatedDbInfo
+	stakerPendingIntentKey    []byte = []byte("_stakerPendingIntent")    // contains a rlp encoded StakerIntent
+	activeChallengeIndexKey   []byte = []byte("_activeChallenge")        // contains the index of the challenge last acted on
+)
+
+var (
+	witnessArchivePrefix    []byte = []byte("w") // maps a batch number and block number to a compressed witnessArchiveEntry
+	validationResultPrefix  []byte = []byte("r") // maps a start batch number, module root, start state hash and batch hash to the rlp encoded end state
+	challengeProgressPrefix []byte = []byte("c") // maps a challenge index to its rlp encoded ChallengeProgress
 )
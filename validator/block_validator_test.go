@@ -0,0 +1,92 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCanLaunchValidation(t *testing.T) {
+	v := &BlockValidator{
+		concurrentRunsLimit:   3,
+		concurrentMemoryLimit: 1000,
+	}
+	entry := &validationEntry{
+		Preimages: map[common.Hash][]byte{{1}: make([]byte, 400)},
+		BatchInfo: []BatchInfo{{Number: 1, Data: make([]byte, 100)}},
+	}
+	memory := validationMemory(entry, make([]byte, 68))
+	if memory != 600 {
+		Fail(t, "estimated", memory, "bytes, expected 600")
+	}
+
+	if !v.canLaunchValidation(2 * memory) {
+		Fail(t, "a validation over the memory limit didn't run alone")
+	}
+	v.atomicValidationsRunning = 1
+	v.atomicValidationsMemory = memory
+	if v.canLaunchValidation(memory) {
+		Fail(t, "launched a validation past the memory limit")
+	}
+	if !v.canLaunchValidation(memory / 2) {
+		Fail(t, "didn't launch a validation within the memory limit")
+	}
+	v.atomicValidationsRunning = 3
+	v.atomicValidationsMemory = 0
+	if v.canLaunchValidation(1) {
+		Fail(t, "launched a validation past the concurrent runs limit")
+	}
+	v.concurrentMemoryLimit = 0
+	v.atomicValidationsRunning = 1
+	v.atomicValidationsMemory = memory
+	if !v.canLaunchValidation(memory * 10) {
+		Fail(t, "memory limited validations with no limit configured")
+	}
+}
+
+func TestValidationCosts(t *testing.T) {
+	v := &BlockValidator{}
+	if stats := v.ValidationCosts(); stats.Blocks != 0 || stats.Steps.Max != 0 {
+		Fail(t, "costs reported before any validation", stats)
+	}
+
+	// 100 validations taking 1 to 100 milliseconds, 1000 to 100000 steps and 10 to 1000 preimage bytes
+	for i := uint64(1); i <= 100; i++ {
+		v.recordValidationCost(context.Background(), i, common.Hash{}, ValidationCost{
+			WallTime:      time.Duration(i) * time.Millisecond,
+			Steps:         i * 1000,
+			PreimageBytes: i * 10,
+		})
+	}
+	stats := v.ValidationCosts()
+	if stats.Blocks != 100 {
+		Fail(t, "recorded", stats.Blocks, "validations, expected 100")
+	}
+	expected := ValidationCostPercentiles{Mean: 50500, P50: 50000, P90: 90000, P99: 99000, Max: 100000}
+	if stats.Steps != expected {
+		Fail(t, "steps", stats.Steps, "expected", expected)
+	}
+	if stats.WallTimeMicro.Max != 100000 || stats.WallTimeMicro.P50 != 50000 {
+		Fail(t, "wall time", stats.WallTimeMicro)
+	}
+	if stats.PreimageBytes.Max != 1000 || stats.PreimageBytes.P90 != 900 {
+		Fail(t, "preimage bytes", stats.PreimageBytes)
+	}
+
+	// Only the most recent validations are summarized, but all are counted
+	for i := 0; i < validationCostWindow; i++ {
+		v.recordValidationCost(context.Background(), 0, common.Hash{}, ValidationCost{Steps: 7})
+	}
+	stats = v.ValidationCosts()
+	if stats.Blocks != 100+validationCostWindow {
+		Fail(t, "recorded", stats.Blocks, "validations, expected", 100+validationCostWindow)
+	}
+	if stats.Steps.Max != 7 || stats.Steps.Mean != 7 {
+		Fail(t, "older validations still summarized", stats.Steps)
+	}
+}
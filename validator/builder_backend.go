@@ -30,6 +30,11 @@ type ValidatorTxBuilder struct {
 }
 
 func NewValidatorTxBuilder(wallet *ValidatorWallet) (*ValidatorTxBuilder, error) {
+	return newValidatorTxBuilderFor(wallet, wallet.From())
+}
+
+// newValidatorTxBuilderFor builds transactions the sender, the wallet's owner or one of its executors, will send.
+func newValidatorTxBuilderFor(wallet *ValidatorWallet, sender common.Address) (*ValidatorTxBuilder, error) {
 	randKey, err := crypto.GenerateKey()
 	if err != nil {
 		return nil, err
@@ -40,7 +45,7 @@ func NewValidatorTxBuilder(wallet *ValidatorWallet) (*ValidatorTxBuilder, error)
 	}
 	return &ValidatorTxBuilder{
 		builderAuth: fakeAuth,
-		realSender:  wallet.From(),
+		realSender:  sender,
 		wallet:      wallet,
 		L1Interface: wallet.l1Reader.Client(),
 	}, nil
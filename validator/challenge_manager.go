@@ -7,7 +7,9 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"math/big"
+	"time"
 
 	"github.com/offchainlabs/nitro/arbstate"
 
@@ -74,11 +76,17 @@ type ChallengeManager struct {
 	targetNumMachines int
 	wasmModuleRoot    common.Hash
 
-	initialMachine        *ArbitratorMachine
-	initialMachineBlockNr int64
+	initialMachine          *ArbitratorMachine
+	initialMachineBlockNr   int64
+	initialMachineSnapshots *machineSnapshots // of the initial machine's execution, nil if not snapshotted
+	machineSnapshots        *MachineSnapshotStore
 
 	// nil until working on execution challenge
 	executionChallengeBackend *ExecutionChallengeBackend
+
+	progress      *ChallengeProgress
+	progressStore *ChallengeProgressStore  // nil if progress isn't persisted
+	hashBackend   *cachingChallengeBackend // of the current mode, nil until needed
 }
 
 // latestMachineLoader may be nil if the block validator is disabled
@@ -162,6 +170,10 @@ func NewChallengeManager(
 		machineLoader:         machineLoader,
 		targetNumMachines:     targetNumMachines,
 		wasmModuleRoot:        challengeInfo.WasmModuleRoot,
+		progress: &ChallengeProgress{
+			ChallengeIndex: challengeIndex,
+			WasmModuleRoot: challengeInfo.WasmModuleRoot,
+		},
 	}, nil
 }
 
@@ -196,15 +208,48 @@ func NewExecutionChallengeManager(
 			confirmationBlocks:   confirmationBlocks,
 		},
 		executionChallengeBackend: backend,
+		progress:                  &ChallengeProgress{ChallengeIndex: challengeIndex, ExecutionMode: true},
 	}, nil
 }
 
+// SetProgressStore persists the challenge's progress after each move, resuming from what was persisted already.
+func (m *ChallengeManager) SetProgressStore(store *ChallengeProgressStore) error {
+	progress, err := store.Get(m.challengeIndex)
+	if err != nil {
+		return err
+	}
+	if progress != nil && progress.WasmModuleRoot == m.progress.WasmModuleRoot {
+		log.Info("resuming challenge", "challenge", m.challengeIndex, "executionMode", progress.ExecutionMode, "start", progress.Start, "end", progress.End, "pendingMove", progress.PendingMove.Kind)
+		m.progress = progress
+	}
+	m.progressStore = store
+	return nil
+}
+
+// SetMachineSnapshots snapshots the execution of the challenged block, so it's stepped through only once.
+func (m *ChallengeManager) SetMachineSnapshots(store *MachineSnapshotStore) {
+	m.machineSnapshots = store
+}
+
+// Progress returns what's known of the challenge as of the last move.
+func (m *ChallengeManager) Progress() *ChallengeProgress {
+	return m.progress
+}
+
+func (m *ChallengeManager) saveProgress() error {
+	if m.progressStore == nil {
+		return nil
+	}
+	return m.progressStore.Put(m.progress)
+}
+
 type ChallengeSegment struct {
 	Hash     common.Hash
 	Position uint64
 }
 
 type ChallengeState struct {
+	StateHash   common.Hash
 	Start       *big.Int
 	End         *big.Int
 	Segments    []ChallengeSegment
@@ -287,7 +332,7 @@ func (m *ChallengeManager) resolveStateHash(ctx context.Context, stateHash commo
 	return state, nil
 }
 
-func (m *ChallengeManager) bisect(ctx context.Context, backend ChallengeBackend, oldState *ChallengeState, startSegment int) (*types.Transaction, error) {
+func (m *ChallengeManager) bisectionSegments(ctx context.Context, backend ChallengeBackend, oldState *ChallengeState, startSegment int) ([][32]byte, error) {
 	startSegmentPosition := oldState.Segments[startSegment].Position
 	endSegmentPosition := oldState.Segments[startSegment+1].Position
 	newChallengeLength := endSegmentPosition - startSegmentPosition
@@ -315,17 +360,44 @@ func (m *ChallengeManager) bisect(ctx context.Context, backend ChallengeBackend,
 		}
 		position += normalSegmentLength
 	}
-	return m.con.BisectExecution(
-		m.auth,
-		m.challengeIndex,
-		challengegen.ChallengeLibSegmentSelection{
-			OldSegmentsStart:  oldState.Start,
-			OldSegmentsLength: new(big.Int).Sub(oldState.End, oldState.Start),
-			OldSegments:       oldState.RawSegments,
-			ChallengePosition: big.NewInt(int64(startSegment)),
-		},
-		newSegments,
-	)
+	return newSegments, nil
+}
+
+// sendMove sends a move computed against the challenge state.
+func (c *challengeCore) sendMove(oldState *ChallengeState, move *ChallengeMove) (*types.Transaction, error) {
+	selection := challengegen.ChallengeLibSegmentSelection{
+		OldSegmentsStart:  oldState.Start,
+		OldSegmentsLength: new(big.Int).Sub(oldState.End, oldState.Start),
+		OldSegments:       oldState.RawSegments,
+		ChallengePosition: new(big.Int).SetUint64(move.SegmentIndex),
+	}
+	switch move.Kind {
+	case ChallengeMoveBisect:
+		return c.con.BisectExecution(c.auth, c.challengeIndex, selection, move.NewSegments)
+	case ChallengeMoveExecution:
+		return c.con.ChallengeExecution(c.auth, c.challengeIndex, selection, move.MachineStatuses, move.GlobalStateHashes, new(big.Int).SetUint64(move.Steps))
+	case ChallengeMoveOneStepProof:
+		return c.con.OneStepProveExecution(c.auth, c.challengeIndex, selection, move.Proof)
+	default:
+		return nil, fmt.Errorf("unknown challenge move kind %v", move.Kind)
+	}
+}
+
+// updateTurn records whose turn it is and when they time out.
+func (m *ChallengeManager) updateTurn(ctx context.Context, myTurn bool) error {
+	info, err := m.con.ChallengeInfo(&bind.CallOpts{Context: ctx}, m.challengeIndex)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.progress.MyTurn = myTurn
+	m.progress.Responder = info.Current.Addr
+	m.progress.ResponderDeadline = new(big.Int).Add(info.LastMoveTimestamp, info.Current.TimeLeft).Uint64()
+	if info.Current.Addr == m.actingAs {
+		m.progress.OurTimeLeft = info.Current.TimeLeft.Uint64()
+	} else {
+		m.progress.OurTimeLeft = info.Next.TimeLeft.Uint64()
+	}
+	return nil
 }
 
 func (m *ChallengeManager) IsMyTurn(ctx context.Context) (bool, error) {
@@ -370,6 +442,7 @@ func (m *ChallengeManager) GetChallengeState(ctx context.Context) (*ChallengeSta
 	if err != nil {
 		return nil, err
 	}
+	state.StateHash = challengeState.ChallengeStateHash
 	return &state, nil
 }
 
@@ -418,6 +491,9 @@ func (m *ChallengeManager) createInitialMachine(ctx context.Context, blockNum in
 		return err
 	}
 	var batchInfo []BatchInfo
+	var hasDelayedMsg bool
+	var delayedMsgNr uint64
+	var delayedMsg []byte
 	if tooFar {
 		// Just record the part of block creation before the message is read
 		_, preimages, readBatchInfo, err := RecordBlockCreation(ctx, m.blockchain, m.inboxReader, blockHeader, nil, true)
@@ -443,7 +519,9 @@ func (m *ChallengeManager) createInitialMachine(ctx context.Context, blockNum in
 		if nextHeader == nil {
 			return fmt.Errorf("next block header %v after challenge point unknown", blockNum+1)
 		}
-		preimages, readBatchInfo, hasDelayedMsg, delayedMsgNr, err := BlockDataForValidation(ctx, m.blockchain, m.inboxReader, nextHeader, blockHeader, message, false)
+		var preimages map[common.Hash][]byte
+		var readBatchInfo []BatchInfo
+		preimages, readBatchInfo, hasDelayedMsg, delayedMsgNr, err = BlockDataForValidation(ctx, m.blockchain, m.inboxReader, nextHeader, blockHeader, message, false)
 		if err != nil {
 			return err
 		}
@@ -461,11 +539,11 @@ func (m *ChallengeManager) createInitialMachine(ctx context.Context, blockNum in
 			return err
 		}
 		if hasDelayedMsg {
-			delayedBytes, err := m.inboxTracker.GetDelayedMessageBytes(delayedMsgNr)
+			delayedMsg, err = m.inboxTracker.GetDelayedMessageBytes(delayedMsgNr)
 			if err != nil {
 				return err
 			}
-			err = machine.AddDelayedInboxMessage(delayedMsgNr, delayedBytes)
+			err = machine.AddDelayedInboxMessage(delayedMsgNr, delayedMsg)
 			if err != nil {
 				return err
 			}
@@ -480,6 +558,8 @@ func (m *ChallengeManager) createInitialMachine(ctx context.Context, blockNum in
 	m.initialMachine = machine
 	m.initialMachine.Freeze()
 	m.initialMachineBlockNr = blockNum
+	batchHash := validationBatchHash(batchInfo, hasDelayedMsg, delayedMsgNr, delayedMsg)
+	m.initialMachineSnapshots = m.machineSnapshots.execution(m.wasmModuleRoot, startGlobalState, batchHash, true)
 	return nil
 }
 
@@ -527,6 +607,7 @@ func (m *ChallengeManager) LoadExecChallengeIfExists(ctx context.Context) error
 	if err != nil {
 		return err
 	}
+	execBackend.snapshots = m.initialMachineSnapshots
 	m.executionChallengeBackend = execBackend
 	return nil
 }
@@ -537,22 +618,67 @@ func (m *ChallengeManager) Act(ctx context.Context) (*types.Transaction, error)
 		return nil, err
 	}
 	myTurn, err := m.IsMyTurn(ctx)
-	if !myTurn || (err != nil) {
+	if err != nil {
 		return nil, err
 	}
+	err = m.updateTurn(ctx, myTurn)
+	if err != nil {
+		return nil, err
+	}
+	if !myTurn {
+		return nil, m.saveProgress()
+	}
 	state, err := m.GetChallengeState(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var backend ChallengeBackend
-	if m.executionChallengeBackend != nil {
-		backend = m.executionChallengeBackend
-	} else {
-		backend = m.blockChallengeBackend
+	executionMode := m.executionChallengeBackend != nil
+	if executionMode != m.progress.ExecutionMode {
+		// The hashes of the block challenge don't apply to the execution challenge
+		m.progress.ExecutionMode = executionMode
+		m.progress.Hashes = nil
+		m.hashBackend = nil
+	}
+	m.progress.StateHash = state.StateHash
+	m.progress.Start = state.Start.Uint64()
+	m.progress.End = state.End.Uint64()
+	m.progress.Segments = state.Segments
+
+	if m.progress.PendingMove.Kind != ChallengeMoveNone && m.progress.PendingMove.StateHash == state.StateHash {
+		// The move hasn't been accepted yet, likely as it was lost to a restart, so resend it as computed
+		log.Info("resending challenge move", "challenge", m.challengeIndex, "kind", m.progress.PendingMove.Kind, "segment", m.progress.PendingMove.SegmentIndex)
+		return m.sendMove(state, &m.progress.PendingMove)
+	}
+	move, err := m.computeMove(ctx, state)
+	if err != nil {
+		// Keep the hashes computed so far
+		if saveErr := m.saveProgress(); saveErr != nil {
+			log.Warn("failed to persist challenge progress", "challenge", m.challengeIndex, "err", saveErr)
+		}
+		return nil, err
+	}
+	m.progress.PendingMove = *move
+	err = m.saveProgress()
+	if err != nil {
+		return nil, err
+	}
+	return m.sendMove(state, move)
+}
+
+func (m *ChallengeManager) computeMove(ctx context.Context, state *ChallengeState) (*ChallengeMove, error) {
+	if m.hashBackend == nil {
+		var backend ChallengeBackend
+		if m.executionChallengeBackend != nil {
+			backend = m.executionChallengeBackend
+		} else {
+			backend = m.blockChallengeBackend
+		}
+		m.hashBackend = newCachingChallengeBackend(backend, m.progress)
 	}
+	backend := m.hashBackend
 
-	err = backend.SetRange(ctx, state.Start.Uint64(), state.End.Uint64())
+	err := backend.SetRange(ctx, state.Start.Uint64(), state.End.Uint64())
 	if err != nil {
 		return nil, err
 	}
@@ -561,46 +687,68 @@ func (m *ChallengeManager) Act(ctx context.Context) (*types.Transaction, error)
 	if err != nil {
 		return nil, err
 	}
+	move := &ChallengeMove{
+		StateHash:    state.StateHash,
+		SegmentIndex: uint64(nextMovePos),
+		ComputedAt:   uint64(time.Now().Unix()),
+	}
 	startPosition := state.Segments[nextMovePos].Position
 	endPosition := state.Segments[nextMovePos+1].Position
 	if startPosition+1 != endPosition {
 		log.Info("bisecting execution", "challenge", m.challengeIndex, "startPosition", startPosition, "endPosition", endPosition)
-		return m.bisect(ctx, backend, state, nextMovePos)
+		move.Kind = ChallengeMoveBisect
+		move.NewSegments, err = m.bisectionSegments(ctx, backend, state, nextMovePos)
+		return move, err
 	}
 	if m.executionChallengeBackend != nil {
 		log.Info("sending onestepproof", "challenge", m.challengeIndex, "startPosition", startPosition, "endPosition", endPosition)
-		return m.executionChallengeBackend.IssueOneStepProof(
-			ctx,
-			m.challengeCore,
-			state,
-			nextMovePos,
-		)
+		err = backend.ensureRange(ctx)
+		if err != nil {
+			return nil, err
+		}
+		move.Kind = ChallengeMoveOneStepProof
+		move.Proof, err = m.executionChallengeBackend.oneStepProof(ctx, startPosition)
+		return move, err
 	}
 	blockNum, tooFar := m.blockChallengeBackend.GetBlockNrAtStep(uint64(nextMovePos))
-	err = m.createInitialMachine(ctx, blockNum, tooFar)
+	stepCount, err := m.blockStepCount(ctx, blockNum, tooFar)
 	if err != nil {
 		return nil, err
 	}
+	log.Info("issuing one step proof", "challenge", m.challengeIndex, "stepCount", stepCount, "blockNum", blockNum)
+	move.Kind = ChallengeMoveExecution
+	move.Steps = stepCount
+	move.MachineStatuses, move.GlobalStateHashes, err = m.blockChallengeBackend.execChallengeInfo(startPosition)
+	return move, err
+}
+
+// blockStepCount returns how many steps executing the block takes, unless it was already computed.
+func (m *ChallengeManager) blockStepCount(ctx context.Context, blockNum int64, tooFar bool) (uint64, error) {
+	if m.progress.ExecutionSteps != 0 && m.progress.ExecutionBlock == uint64(blockNum+1) && m.progress.ExecutionTooFar == tooFar {
+		return m.progress.ExecutionSteps, nil
+	}
+	err := m.createInitialMachine(ctx, blockNum, tooFar)
+	if err != nil {
+		return 0, err
+	}
 	// TODO: we might also use HostIoMachineTo Speed things up
 	stepCountMachine := m.initialMachine.Clone()
-	var stepCount uint64
+	snapshots := m.initialMachineSnapshots
+	snapshots.restore(stepCountMachine, math.MaxUint64)
 	for stepCountMachine.IsRunning() {
-		stepsPerLoop := uint64(1_000_000_000)
+		stepCount := stepCountMachine.GetStepCount()
 		if stepCount > 0 {
 			log.Debug("step count machine", "block", blockNum, "steps", stepCount)
 		}
-		err = stepCountMachine.Step(ctx, stepsPerLoop)
+		err = stepCountMachine.Step(ctx, snapshots.stepsBeforeSnapshot(stepCount, 1_000_000_000))
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
-		stepCount += stepsPerLoop
+		snapshots.snapshot(stepCountMachine)
 	}
-	stepCount = stepCountMachine.GetStepCount()
-	log.Info("issuing one step proof", "challenge", m.challengeIndex, "stepCount", stepCount, "blockNum", blockNum)
-	return m.blockChallengeBackend.IssueExecChallenge(
-		m.challengeCore,
-		state,
-		nextMovePos,
-		stepCount,
-	)
+	stepCount := stepCountMachine.GetStepCount()
+	m.progress.ExecutionBlock = uint64(blockNum + 1)
+	m.progress.ExecutionTooFar = tooFar
+	m.progress.ExecutionSteps = stepCount
+	return stepCount, nil
 }
@@ -0,0 +1,194 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+type ChallengeMoveKind uint8
+
+const (
+	ChallengeMoveNone ChallengeMoveKind = iota
+	ChallengeMoveBisect
+	ChallengeMoveExecution // moving a block challenge into an execution challenge
+	ChallengeMoveOneStepProof
+)
+
+// ChallengeMove is a move computed against a challenge state, kept so it can be resent without recomputing it.
+type ChallengeMove struct {
+	Kind              ChallengeMoveKind `json:"kind"`
+	StateHash         common.Hash       `json:"stateHash"` // of the state the move responds to
+	SegmentIndex      uint64            `json:"segmentIndex"`
+	NewSegments       [][32]byte        `json:"newSegments,omitempty"` // bisections only
+	MachineStatuses   [2]uint8          `json:"machineStatuses"`       // execution challenges only
+	GlobalStateHashes [2][32]byte       `json:"globalStateHashes"`     // execution challenges only
+	Steps             uint64            `json:"steps"`                 // execution challenges only
+	Proof             []byte            `json:"proof,omitempty"`       // one step proofs only
+	ComputedAt        uint64            `json:"computedAt"`            // unix time
+}
+
+type ChallengePositionHash struct {
+	Position uint64      `json:"position"`
+	Hash     common.Hash `json:"hash"`
+}
+
+// ChallengeProgress is the state of a challenge the validator is in, persisted after every move so a restart
+// resumes where it left off rather than recomputing the hashes and moves it already had.
+type ChallengeProgress struct {
+	ChallengeIndex    uint64                  `json:"challengeIndex"`
+	WasmModuleRoot    common.Hash             `json:"wasmModuleRoot"`
+	ExecutionMode     bool                    `json:"executionMode"` // whether it's in the execution challenge yet
+	StateHash         common.Hash             `json:"stateHash"`
+	Start             uint64                  `json:"start"` // of the challenged range, in blocks or steps
+	End               uint64                  `json:"end"`
+	Segments          []ChallengeSegment      `json:"segments"`
+	Responder         common.Address          `json:"responder"`
+	MyTurn            bool                    `json:"myTurn"`
+	ResponderDeadline uint64                  `json:"responderDeadline"` // unix time the responder times out
+	OurTimeLeft       uint64                  `json:"ourTimeLeft"`       // seconds, as of the last move
+	ExecutionBlock    uint64                  `json:"executionBlock"`    // one past the block executed, 0 if not known
+	ExecutionTooFar   bool                    `json:"executionTooFar"`
+	ExecutionSteps    uint64                  `json:"executionSteps"` // steps executing the block takes, 0 if not computed
+	Hashes            []ChallengePositionHash `json:"-"`              // computed for the current mode
+	PendingMove       ChallengeMove           `json:"pendingMove"`
+	UpdatedAt         uint64                  `json:"updatedAt"`
+}
+
+// ChallengeProgressStore persists the progress of the challenges the validator is in.
+type ChallengeProgressStore struct {
+	db ethdb.Database
+}
+
+func NewChallengeProgressStore(db ethdb.Database) *ChallengeProgressStore {
+	return &ChallengeProgressStore{db: db}
+}
+
+func challengeProgressKey(challengeIndex uint64) []byte {
+	return append(append([]byte{}, challengeProgressPrefix...), arbmath.UintToBytes(challengeIndex)...)
+}
+
+// Get returns the progress of the challenge, or nil if none was persisted.
+func (s *ChallengeProgressStore) Get(challengeIndex uint64) (*ChallengeProgress, error) {
+	key := challengeProgressKey(challengeIndex)
+	exists, err := s.db.Has(key)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := s.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var progress ChallengeProgress
+	if err := rlp.DecodeBytes(data, &progress); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (s *ChallengeProgressStore) Put(progress *ChallengeProgress) error {
+	progress.UpdatedAt = uint64(time.Now().Unix())
+	data, err := rlp.EncodeToBytes(progress)
+	if err != nil {
+		return err
+	}
+	batch := s.db.NewBatch()
+	if err := batch.Put(challengeProgressKey(progress.ChallengeIndex), data); err != nil {
+		return err
+	}
+	if err := batch.Put(activeChallengeIndexKey, arbmath.UintToBytes(progress.ChallengeIndex)); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// Active returns the progress of the challenge last acted on, or nil if the validator isn't in one.
+func (s *ChallengeProgressStore) Active() (*ChallengeProgress, error) {
+	exists, err := s.db.Has(activeChallengeIndexKey)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := s.db.Get(activeChallengeIndexKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 8 {
+		return nil, fmt.Errorf("invalid active challenge index %v", data)
+	}
+	return s.Get(binary.BigEndian.Uint64(data))
+}
+
+// Finish forgets the challenge, once it's over.
+func (s *ChallengeProgressStore) Finish(challengeIndex uint64) error {
+	batch := s.db.NewBatch()
+	if err := batch.Delete(challengeProgressKey(challengeIndex)); err != nil {
+		return err
+	}
+	if err := batch.Delete(activeChallengeIndexKey); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// cachingChallengeBackend remembers the hashes a backend computed in the challenge's progress, only setting the
+// backend's range once a hash it doesn't have is needed.
+type cachingChallengeBackend struct {
+	backend    ChallengeBackend
+	hashes     map[uint64]common.Hash
+	progress   *ChallengeProgress
+	start, end uint64
+	rangeSet   bool
+}
+
+func newCachingChallengeBackend(backend ChallengeBackend, progress *ChallengeProgress) *cachingChallengeBackend {
+	hashes := make(map[uint64]common.Hash, len(progress.Hashes))
+	for _, positionHash := range progress.Hashes {
+		hashes[positionHash.Position] = positionHash.Hash
+	}
+	return &cachingChallengeBackend{backend: backend, hashes: hashes, progress: progress}
+}
+
+func (b *cachingChallengeBackend) SetRange(ctx context.Context, start uint64, end uint64) error {
+	if b.start != start || b.end != end {
+		b.start, b.end = start, end
+		b.rangeSet = false
+	}
+	return nil
+}
+
+// ensureRange sets the backend's range, for moves needing more than its hashes.
+func (b *cachingChallengeBackend) ensureRange(ctx context.Context) error {
+	if b.rangeSet {
+		return nil
+	}
+	if err := b.backend.SetRange(ctx, b.start, b.end); err != nil {
+		return err
+	}
+	b.rangeSet = true
+	return nil
+}
+
+func (b *cachingChallengeBackend) GetHashAtStep(ctx context.Context, position uint64) (common.Hash, error) {
+	if hash, ok := b.hashes[position]; ok {
+		return hash, nil
+	}
+	if err := b.ensureRange(ctx); err != nil {
+		return common.Hash{}, err
+	}
+	hash, err := b.backend.GetHashAtStep(ctx, position)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	b.hashes[position] = hash
+	b.progress.Hashes = append(b.progress.Hashes, ChallengePositionHash{position, hash})
+	return hash, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+type countingChallengeBackend struct {
+	ranges int
+	hashes int
+}
+
+func (b *countingChallengeBackend) SetRange(ctx context.Context, start uint64, end uint64) error {
+	b.ranges++
+	return nil
+}
+
+func (b *countingChallengeBackend) GetHashAtStep(ctx context.Context, position uint64) (common.Hash, error) {
+	b.hashes++
+	return common.BigToHash(new(big.Int).SetUint64(position)), nil
+}
+
+func TestChallengeProgressStore(t *testing.T) {
+	store := NewChallengeProgressStore(rawdb.NewMemoryDatabase())
+	active, err := store.Active()
+	Require(t, err)
+	if active != nil {
+		Fail(t, "active challenge before any was stored")
+	}
+
+	progress := &ChallengeProgress{
+		ChallengeIndex: 5,
+		ExecutionMode:  true,
+		Start:          100,
+		End:            200,
+		Segments:       []ChallengeSegment{{Hash: common.HexToHash("0x01"), Position: 100}, {Hash: common.HexToHash("0x02"), Position: 200}},
+		ExecutionBlock: 8,
+		ExecutionSteps: 12345,
+		Hashes:         []ChallengePositionHash{{Position: 150, Hash: common.HexToHash("0x03")}},
+		PendingMove: ChallengeMove{
+			Kind:         ChallengeMoveOneStepProof,
+			StateHash:    common.HexToHash("0x04"),
+			SegmentIndex: 1,
+			Proof:        []byte{1, 2, 3},
+		},
+	}
+	Require(t, store.Put(progress))
+	active, err = store.Active()
+	Require(t, err)
+	if active == nil || active.ChallengeIndex != 5 || active.ExecutionSteps != 12345 || len(active.Segments) != 2 || len(active.Hashes) != 1 {
+		Fail(t, "unexpected active challenge", active)
+	}
+	if active.PendingMove.Kind != ChallengeMoveOneStepProof || string(active.PendingMove.Proof) != string([]byte{1, 2, 3}) {
+		Fail(t, "unexpected pending move", active.PendingMove)
+	}
+
+	Require(t, store.Finish(5))
+	active, err = store.Active()
+	Require(t, err)
+	if active != nil {
+		Fail(t, "active challenge after it finished")
+	}
+}
+
+func TestCachingChallengeBackend(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingChallengeBackend{}
+	progress := &ChallengeProgress{Hashes: []ChallengePositionHash{{Position: 10, Hash: common.HexToHash("0x0a")}}}
+	backend := newCachingChallengeBackend(inner, progress)
+
+	Require(t, backend.SetRange(ctx, 0, 100))
+	hash, err := backend.GetHashAtStep(ctx, 10)
+	Require(t, err)
+	if hash != common.HexToHash("0x0a") || inner.ranges != 0 || inner.hashes != 0 {
+		Fail(t, "persisted hash wasn't used", hash, inner.ranges, inner.hashes)
+	}
+	_, err = backend.GetHashAtStep(ctx, 20)
+	Require(t, err)
+	_, err = backend.GetHashAtStep(ctx, 20)
+	Require(t, err)
+	if inner.ranges != 1 || inner.hashes != 1 || len(progress.Hashes) != 2 {
+		Fail(t, "unexpected backend use", inner.ranges, inner.hashes, len(progress.Hashes))
+	}
+}
@@ -5,11 +5,9 @@ package validator
 
 import (
 	"context"
-	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/offchainlabs/nitro/solgen/go/challengegen"
 	"github.com/pkg/errors"
 )
 
@@ -20,6 +18,7 @@ type ExecutionChallengeBackend struct {
 	machineCacheStart uint64
 	machineCacheEnd   uint64
 	targetNumMachines int
+	snapshots         *machineSnapshots // of the initial machine's execution, nil if not snapshotted
 }
 
 // Assert that ExecutionChallengeBackend implements ChallengeBackend
@@ -48,6 +47,10 @@ func (b *ExecutionChallengeBackend) getMachineAt(ctx context.Context, stepCount
 			mach = b.lastMachine
 		}
 		mach = mach.CloneMachineInterface()
+		if arbMach, ok := mach.(*ArbitratorMachine); ok {
+			// Skip as much of the execution as its snapshots allow
+			b.snapshots.restore(arbMach, stepCount)
+		}
 		err := mach.Step(ctx, stepCount-mach.GetStepCount())
 		if err != nil {
 			return nil, err
@@ -91,20 +94,21 @@ func (b *ExecutionChallengeBackend) IssueOneStepProof(
 	oldState *ChallengeState,
 	startSegment int,
 ) (*types.Transaction, error) {
-	mach, err := b.getMachineAt(ctx, oldState.Segments[startSegment].Position)
+	proof, err := b.oneStepProof(ctx, oldState.Segments[startSegment].Position)
+	if err != nil {
+		return nil, err
+	}
+	return core.sendMove(oldState, &ChallengeMove{
+		Kind:         ChallengeMoveOneStepProof,
+		SegmentIndex: uint64(startSegment),
+		Proof:        proof,
+	})
+}
+
+func (b *ExecutionChallengeBackend) oneStepProof(ctx context.Context, position uint64) ([]byte, error) {
+	mach, err := b.getMachineAt(ctx, position)
 	if err != nil {
 		return nil, err
 	}
-	proof := mach.ProveNextStep()
-	return core.con.OneStepProveExecution(
-		core.auth,
-		core.challengeIndex,
-		challengegen.ChallengeLibSegmentSelection{
-			OldSegmentsStart:  oldState.Start,
-			OldSegmentsLength: new(big.Int).Sub(oldState.End, oldState.Start),
-			OldSegments:       oldState.RawSegments,
-			ChallengePosition: big.NewInt(int64(startSegment)),
-		},
-		proof,
-	)
+	return mach.ProveNextStep(), nil
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/solgen/go/challengegen"
 	"github.com/offchainlabs/nitro/solgen/go/rollupgen"
 	"github.com/pkg/errors"
 )
@@ -50,11 +51,15 @@ type L1Validator struct {
 	genesisBlockNumber      uint64
 
 	l2Blockchain       *core.BlockChain
+	l2Blocks           L2BlockSource
 	das                arbstate.DataAvailabilityReader
 	inboxTracker       InboxTrackerInterface
 	txStreamer         TransactionStreamerInterface
 	blockValidator     *BlockValidator
 	lastWasmModuleRoot common.Hash
+
+	// called with each incorrect assertion found, if set
+	invalidAssertionHandler func(*InvalidAssertionAlert)
 }
 
 func NewL1Validator(
@@ -97,6 +102,7 @@ func NewL1Validator(
 		callOpts:           callOpts,
 		genesisBlockNumber: genesisBlockNumber,
 		l2Blockchain:       l2Blockchain,
+		l2Blocks:           NewBlockchainBlockSource(l2Blockchain),
 		das:                das,
 		inboxTracker:       inboxTracker,
 		txStreamer:         txStreamer,
@@ -142,7 +148,9 @@ func (v *L1Validator) updateBlockValidatorModuleRoot(ctx context.Context) error
 	return nil
 }
 
-func (v *L1Validator) resolveTimedOutChallenges(ctx context.Context) (*types.Transaction, error) {
+// resolveTimedOutChallenges times out challenges through the validator wallet, or straight from direct if it's
+// set, as anyone can time out a challenge.
+func (v *L1Validator) resolveTimedOutChallenges(ctx context.Context, direct *bind.TransactOpts) (*types.Transaction, error) {
 	challengesToEliminate, _, err := v.validatorUtils.TimedOutChallenges(v.getCallOpts(ctx), v.rollupAddress, 0, 10)
 	if err != nil {
 		return nil, err
@@ -151,44 +159,76 @@ func (v *L1Validator) resolveTimedOutChallenges(ctx context.Context) (*types.Tra
 		return nil, nil
 	}
 	log.Info("timing out challenges", "count", len(challengesToEliminate))
-	return v.wallet.TimeoutChallenges(ctx, v.challengeManagerAddress, challengesToEliminate)
+	if direct == nil {
+		return v.wallet.TimeoutChallenges(ctx, v.challengeManagerAddress, challengesToEliminate)
+	}
+	manager, err := challengegen.NewChallengeManagerTransactor(v.challengeManagerAddress, v.client)
+	if err != nil {
+		return nil, err
+	}
+	var arbTx *types.Transaction
+	for _, challenge := range challengesToEliminate {
+		arbTx, err = manager.Timeout(direct, challenge)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return arbTx, nil
 }
 
-func (v *L1Validator) resolveNextNode(ctx context.Context, info *StakerInfo, latestConfirmedNode *uint64) (bool, error) {
+// resolveNextNode confirms or rejects the next node if it can be. The transaction is built to be sent through the
+// validator wallet, or is sent straight from direct if it's set, as resolving a node needs no stake. The
+// transaction is only returned in the latter case.
+func (v *L1Validator) resolveNextNode(ctx context.Context, info *StakerInfo, latestConfirmedNode *uint64, direct *bind.TransactOpts) (*types.Transaction, bool, error) {
 	callOpts := v.getCallOpts(ctx)
 	confirmType, err := v.validatorUtils.CheckDecidableNextNode(callOpts, v.rollupAddress)
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
 	unresolvedNodeIndex, err := v.rollup.FirstUnresolvedNode(callOpts)
 	if err != nil {
-		return false, err
+		return nil, false, err
+	}
+	rollup := &v.rollup.RollupUserLogicTransactor
+	auth := v.builder.Auth(ctx)
+	if direct != nil {
+		rollup, err = rollupgen.NewRollupUserLogicTransactor(v.rollupAddress, v.client)
+		if err != nil {
+			return nil, false, err
+		}
+		auth = direct
 	}
+	var arbTx *types.Transaction
 	switch ConfirmType(confirmType) {
 	case CONFIRM_TYPE_INVALID:
 		addr := v.wallet.Address()
 		if info == nil || addr == nil || info.LatestStakedNode <= unresolvedNodeIndex {
 			// We aren't an example of someone staked on a competitor
-			return false, nil
+			return nil, false, nil
 		}
 		log.Info("rejecing node", "node", unresolvedNodeIndex)
-		_, err = v.rollup.RejectNextNode(v.builder.Auth(ctx), *addr)
-		return true, err
+		arbTx, err = rollup.RejectNextNode(auth, *addr)
+		if err != nil {
+			return nil, false, err
+		}
 	case CONFIRM_TYPE_VALID:
 		nodeInfo, err := v.rollup.LookupNode(ctx, unresolvedNodeIndex)
 		if err != nil {
-			return false, err
+			return nil, false, err
 		}
 		afterGs := nodeInfo.AfterState().GlobalState
-		_, err = v.rollup.ConfirmNextNode(v.builder.Auth(ctx), afterGs.BlockHash, afterGs.SendRoot)
+		arbTx, err = rollup.ConfirmNextNode(auth, afterGs.BlockHash, afterGs.SendRoot)
 		if err != nil {
-			return false, err
+			return nil, false, err
 		}
 		*latestConfirmedNode = unresolvedNodeIndex
-		return true, nil
 	default:
-		return false, nil
+		return nil, false, nil
+	}
+	if direct == nil {
+		return nil, true, nil
 	}
+	return arbTx, true, nil
 }
 
 func (v *L1Validator) isRequiredStakeElevated(ctx context.Context) (bool, error) {
@@ -225,13 +265,21 @@ type OurStakerInfo struct {
 	*StakerInfo
 }
 
+type batchMessageCounter interface {
+	GetBatchMessageCount(seqNum uint64) (arbutil.MessageIndex, error)
+}
+
 // Returns (block number, global state inbox position is invalid, error).
 // If global state is invalid, block number is set to the last of the batch.
 func (v *L1Validator) blockNumberFromGlobalState(gs GoGlobalState) (int64, bool, error) {
+	return blockNumberFromGlobalState(v.inboxTracker, v.genesisBlockNumber, gs)
+}
+
+func blockNumberFromGlobalState(inboxTracker batchMessageCounter, genesisBlockNumber uint64, gs GoGlobalState) (int64, bool, error) {
 	var batchHeight arbutil.MessageIndex
 	if gs.Batch > 0 {
 		var err error
-		batchHeight, err = v.inboxTracker.GetBatchMessageCount(gs.Batch - 1)
+		batchHeight, err = inboxTracker.GetBatchMessageCount(gs.Batch - 1)
 		if err != nil {
 			return 0, false, err
 		}
@@ -239,7 +287,7 @@ func (v *L1Validator) blockNumberFromGlobalState(gs GoGlobalState) (int64, bool,
 
 	// Validate the PosInBatch if it's non-zero
 	if gs.PosInBatch > 0 {
-		nextBatchHeight, err := v.inboxTracker.GetBatchMessageCount(gs.Batch)
+		nextBatchHeight, err := inboxTracker.GetBatchMessageCount(gs.Batch)
 		if err != nil {
 			return 0, false, err
 		}
@@ -247,11 +295,11 @@ func (v *L1Validator) blockNumberFromGlobalState(gs GoGlobalState) (int64, bool,
 		if gs.PosInBatch >= uint64(nextBatchHeight-batchHeight) {
 			// This PosInBatch would enter the next batch. Return the last block before the next batch.
 			// We can be sure that MessageCountToBlockNumber will return a non-negative number as nextBatchHeight must be nonzero.
-			return arbutil.MessageCountToBlockNumber(nextBatchHeight, v.genesisBlockNumber), true, nil
+			return arbutil.MessageCountToBlockNumber(nextBatchHeight, genesisBlockNumber), true, nil
 		}
 	}
 
-	return arbutil.MessageCountToBlockNumber(batchHeight+arbutil.MessageIndex(gs.PosInBatch), v.genesisBlockNumber), false, nil
+	return arbutil.MessageCountToBlockNumber(batchHeight+arbutil.MessageIndex(gs.PosInBatch), genesisBlockNumber), false, nil
 }
 
 func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurStakerInfo, strategy StakerStrategy) (nodeAction, bool, error) {
@@ -272,8 +320,12 @@ func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurSta
 		return nil, false, nil
 	}
 
-	startBlock := v.l2Blockchain.GetBlockByHash(startState.GlobalState.BlockHash)
-	if startBlock == nil && (startState.GlobalState != GoGlobalState{}) {
+	headBlock, err := v.l2Blocks.HeadBlockNumber(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var startBlock *L2BlockInfo
+	if (startState.GlobalState != GoGlobalState{}) {
 		expectedBlockHeight, inboxPositionInvalid, err := v.blockNumberFromGlobalState(startState.GlobalState)
 		if err != nil {
 			return nil, false, err
@@ -282,11 +334,17 @@ func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurSta
 			log.Error("invalid start global state inbox position", startState.GlobalState.BlockHash, "batch", startState.GlobalState.Batch, "pos", startState.GlobalState.PosInBatch)
 			return nil, false, errors.New("invalid start global state inbox position")
 		}
-		latestHeader := v.l2Blockchain.CurrentBlock().Header()
-		if latestHeader.Number.Int64() < expectedBlockHeight {
-			log.Info("catching up to chain blocks", "localBlocks", latestHeader.Number, "target", expectedBlockHeight)
+		if expectedBlockHeight >= 0 && headBlock < uint64(expectedBlockHeight) {
+			log.Info("catching up to chain blocks", "localBlocks", headBlock, "target", expectedBlockHeight)
 			return nil, false, nil
-		} else {
+		}
+		if expectedBlockHeight >= 0 {
+			startBlock, err = v.l2Blocks.BlockByNumber(ctx, uint64(expectedBlockHeight))
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		if startBlock == nil || startBlock.Hash != startState.GlobalState.BlockHash {
 			log.Error("unknown start block hash", "hash", startState.GlobalState.BlockHash, "batch", startState.GlobalState.Batch, "pos", startState.GlobalState.PosInBatch)
 			return nil, false, errors.New("unknown start block hash")
 		}
@@ -297,7 +355,14 @@ func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurSta
 		var expectedHash common.Hash
 		var validRoots []common.Hash
 		lastBlockValidated, expectedHash, validRoots = v.blockValidator.LastBlockValidatedAndHash()
-		haveHash := v.l2Blockchain.GetCanonicalHash(lastBlockValidated)
+		var haveHash common.Hash
+		validatedBlock, err := v.l2Blocks.BlockByNumber(ctx, lastBlockValidated)
+		if err != nil {
+			return nil, false, err
+		}
+		if validatedBlock != nil {
+			haveHash = validatedBlock.Hash
+		}
 		if haveHash != expectedHash {
 			return nil, false, fmt.Errorf("block validator validated block %v as hash %v but blockchain has hash %v", lastBlockValidated, expectedHash, haveHash)
 		}
@@ -315,7 +380,7 @@ func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurSta
 			return nil, false, fmt.Errorf("wasmroot doesn't match rollup : %v, valid: %v", v.lastWasmModuleRoot, validRoots)
 		}
 	} else {
-		lastBlockValidated = v.l2Blockchain.CurrentBlock().Header().Number.Uint64()
+		lastBlockValidated = headBlock
 
 		if localBatchCount > 0 {
 			messageCount, err := v.inboxTracker.GetBatchMessageCount(localBatchCount - 1)
@@ -388,23 +453,22 @@ func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurSta
 			var expectedBlockHash common.Hash
 			var expectedSendRoot common.Hash
 			if lastBlockNum >= 0 {
-				lastBlock := v.l2Blockchain.GetBlockByNumber(uint64(lastBlockNum))
-				if lastBlock == nil {
-					return nil, false, fmt.Errorf("block %v not in database despite being validated", lastBlockNum)
-				}
-				lastBlockExtra, err := types.DeserializeHeaderExtraInformation(lastBlock.Header())
+				lastBlock, err := v.l2Blocks.BlockByNumber(ctx, uint64(lastBlockNum))
 				if err != nil {
 					return nil, false, err
 				}
-				expectedBlockHash = lastBlock.Hash()
-				expectedSendRoot = lastBlockExtra.SendRoot
+				if lastBlock == nil {
+					return nil, false, fmt.Errorf("block %v not in database despite being validated", lastBlockNum)
+				}
+				expectedBlockHash = lastBlock.Hash
+				expectedSendRoot = lastBlock.SendRoot
 			}
 
 			var expectedNumBlocks uint64
 			if startBlock == nil {
 				expectedNumBlocks = uint64(lastBlockNum + 1)
 			} else {
-				expectedNumBlocks = uint64(lastBlockNum) - startBlock.NumberU64()
+				expectedNumBlocks = uint64(lastBlockNum) - startBlock.Number
 			}
 			valid := !inboxPositionInvalid &&
 				nd.Assertion.NumBlocks == expectedNumBlocks &&
@@ -435,6 +499,24 @@ func (v *L1Validator) generateNodeAction(ctx context.Context, stakerInfo *OurSta
 					"sendRoot", afterGs.SendRoot,
 					"expectedSendRoot", expectedSendRoot,
 				)
+				if v.invalidAssertionHandler != nil {
+					v.invalidAssertionHandler(&InvalidAssertionAlert{
+						Rollup:               v.rollupAddress,
+						Node:                 nd.NodeNum,
+						NodeHash:             nd.NodeHash,
+						ProposedAtL1Block:    nd.BlockProposed,
+						NumBlocks:            nd.Assertion.NumBlocks,
+						BlockHash:            afterGs.BlockHash,
+						SendRoot:             afterGs.SendRoot,
+						Batch:                afterGs.Batch,
+						PosInBatch:           afterGs.PosInBatch,
+						InboxPositionInvalid: inboxPositionInvalid,
+						ExpectedBlockNumber:  lastBlockNum,
+						ExpectedNumBlocks:    expectedNumBlocks,
+						ExpectedBlockHash:    expectedBlockHash,
+						ExpectedSendRoot:     expectedSendRoot,
+					})
+				}
 			}
 		} else {
 			log.Warn("found younger sibling to correct node", "node", nd.NodeNum)
@@ -462,7 +544,7 @@ func (v *L1Validator) createNewNodeAction(
 	lastBlockValidated uint64,
 	localBatchCount uint64,
 	prevInboxMaxCount *big.Int,
-	startBlock *types.Block,
+	startBlock *L2BlockInfo,
 	startState *ExecutionState,
 	lastNodeHashIfExists *common.Hash,
 ) (nodeAction, error) {
@@ -479,7 +561,7 @@ func (v *L1Validator) createNewNodeAction(
 		// we haven't validated anything
 		return nil, nil
 	}
-	if startBlock != nil && lastBlockValidated <= startBlock.NumberU64() {
+	if startBlock != nil && lastBlockValidated <= startBlock.Number {
 		// we haven't validated any new blocks
 		return nil, nil
 	}
@@ -527,14 +609,13 @@ func (v *L1Validator) createNewNodeAction(
 		return nil, err
 	}
 
-	assertingBlock := v.l2Blockchain.GetBlockByNumber(lastBlockValidated)
-	if assertingBlock == nil {
-		return nil, fmt.Errorf("missing validated block %v", lastBlockValidated)
-	}
-	assertingBlockExtra, err := types.DeserializeHeaderExtraInformation(assertingBlock.Header())
+	assertingBlock, err := v.l2Blocks.BlockByNumber(ctx, lastBlockValidated)
 	if err != nil {
 		return nil, err
 	}
+	if assertingBlock == nil {
+		return nil, fmt.Errorf("missing validated block %v", lastBlockValidated)
+	}
 
 	hasSiblingByte := [1]byte{0}
 	prevNum := stakerInfo.LatestStakedNode
@@ -545,16 +626,16 @@ func (v *L1Validator) createNewNodeAction(
 	}
 	var assertionNumBlocks uint64
 	if startBlock == nil {
-		assertionNumBlocks = assertingBlock.NumberU64() + 1
+		assertionNumBlocks = assertingBlock.Number + 1
 	} else {
-		assertionNumBlocks = assertingBlock.NumberU64() - startBlock.NumberU64()
+		assertionNumBlocks = assertingBlock.Number - startBlock.Number
 	}
 	assertion := &Assertion{
 		BeforeState: startState,
 		AfterState: &ExecutionState{
 			GlobalState: GoGlobalState{
-				BlockHash:  assertingBlock.Hash(),
-				SendRoot:   assertingBlockExtra.SendRoot,
+				BlockHash:  assertingBlock.Hash,
+				SendRoot:   assertingBlock.SendRoot,
 				Batch:      afterGsBatch,
 				PosInBatch: afterGsPosInBatch,
 			},
@@ -0,0 +1,51 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// L2BlockInfo is what an assertion commits to of an L2 block.
+type L2BlockInfo struct {
+	Number   uint64
+	Hash     common.Hash
+	SendRoot common.Hash
+}
+
+// L2BlockSource is what the L1 validator reads of the L2 chain, which is either the local blockchain or, when
+// messages are executed on a separate execution node, that node's results.
+type L2BlockSource interface {
+	HeadBlockNumber(ctx context.Context) (uint64, error)
+	// BlockByNumber returns the canonical block, or nil if it hasn't been created yet.
+	BlockByNumber(ctx context.Context, number uint64) (*L2BlockInfo, error)
+}
+
+type blockchainBlockSource struct {
+	bc *core.BlockChain
+}
+
+func NewBlockchainBlockSource(bc *core.BlockChain) L2BlockSource {
+	return blockchainBlockSource{bc: bc}
+}
+
+func (s blockchainBlockSource) HeadBlockNumber(ctx context.Context) (uint64, error) {
+	return s.bc.CurrentBlock().NumberU64(), nil
+}
+
+func (s blockchainBlockSource) BlockByNumber(ctx context.Context, number uint64) (*L2BlockInfo, error) {
+	header := s.bc.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, nil
+	}
+	extra, err := types.DeserializeHeaderExtraInformation(header)
+	if err != nil {
+		return nil, err
+	}
+	return &L2BlockInfo{Number: number, Hash: header.Hash(), SendRoot: extra.SendRoot}, nil
+}
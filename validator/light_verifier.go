@@ -0,0 +1,438 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/headerreader"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+type LightVerifierConfig struct {
+	Enable             bool          `koanf:"enable"`
+	TrustedNodes       []string      `koanf:"trusted-nodes"`
+	CheckInterval      time.Duration `koanf:"check-interval"`
+	AccumulatorBatches uint64        `koanf:"accumulator-batches"`
+	L1Confirmations    uint64        `koanf:"l1-confirmations"`
+	RequestTimeout     time.Duration `koanf:"request-timeout"`
+}
+
+var DefaultLightVerifierConfig = LightVerifierConfig{
+	Enable:             false,
+	TrustedNodes:       []string{},
+	CheckInterval:      time.Minute,
+	AccumulatorBatches: 16,
+	L1Confirmations:    12,
+	RequestTimeout:     30 * time.Second,
+}
+
+func LightVerifierConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultLightVerifierConfig.Enable, "run in light mode, storing messages without executing them, and check posted assertions against the state reported by trusted nodes and the inbox accumulators against L1, alerting through the sinks of node.validator.alerts on any mismatch")
+	f.StringSlice(prefix+".trusted-nodes", DefaultLightVerifierConfig.TrustedNodes, "RPC URLs of the trusted execution nodes to compare assertions with")
+	f.Duration(prefix+".check-interval", DefaultLightVerifierConfig.CheckInterval, "how often to check for new assertions and batches")
+	f.Uint64(prefix+".accumulator-batches", DefaultLightVerifierConfig.AccumulatorBatches, "maximum number of batch accumulators to compare with L1 each check, the most recent being checked first after falling behind")
+	f.Uint64(prefix+".l1-confirmations", DefaultLightVerifierConfig.L1Confirmations, "number of L1 blocks to wait before comparing batch accumulators, so reorgs aren't reported as mismatches")
+	f.Duration(prefix+".request-timeout", DefaultLightVerifierConfig.RequestTimeout, "timeout of each request to a trusted node")
+}
+
+var (
+	lightVerifierNodeGauge              = metrics.NewRegisteredGauge("arb/validator/light/node", nil)
+	lightVerifierBatchGauge             = metrics.NewRegisteredGauge("arb/validator/light/batch", nil)
+	lightVerifierInvalidNodesCounter    = metrics.NewRegisteredCounter("arb/validator/light/invalid/nodes", nil)
+	lightVerifierAccMismatchesCounter   = metrics.NewRegisteredCounter("arb/validator/light/invalid/accumulators", nil)
+	lightVerifierTrustedConflictCounter = metrics.NewRegisteredCounter("arb/validator/light/trusted/conflicts", nil)
+)
+
+// LightVerifierInbox is the part of the inbox tracker the light verifier reads: the positions of batches, to map
+// global states to blocks, and what the accumulator of each batch is formed from.
+type LightVerifierInbox interface {
+	GetBatchMessageCount(seqNum uint64) (arbutil.MessageIndex, error)
+	GetBatchAcc(seqNum uint64) (common.Hash, error)
+	GetBatchCount() (uint64, error)
+	GetBatchDelayedCount(seqNum uint64) (uint64, error)
+	GetDelayedAcc(seqNum uint64) (common.Hash, error)
+}
+
+// SequencerInboxReader reads the batch accumulators posted to the sequencer inbox on L1.
+type SequencerInboxReader interface {
+	GetBatchCount(ctx context.Context, blockNumber *big.Int) (uint64, error)
+	GetAccumulator(ctx context.Context, sequenceNumber uint64, blockNumber *big.Int) (common.Hash, error)
+}
+
+type trustedHeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+type trustedNode struct {
+	url    string
+	client trustedHeaderReader
+}
+
+// trustedBlock is the state of a block as reported by a trusted node.
+type trustedBlock struct {
+	node      string
+	blockHash common.Hash
+	sendRoot  common.Hash
+}
+
+// LightVerifier is a watchtower for operators who can't run full validation, run by a node which doesn't execute
+// blocks. It compares each assertion posted to the rollup with the state trusted execution nodes report for its
+// last block, and the accumulators formed from the batches of the local inbox with those the sequencer inbox has.
+type LightVerifier struct {
+	stopwaiter.StopWaiter
+
+	config             LightVerifierConfig
+	l1Reader           *headerreader.HeaderReader
+	rollup             *RollupWatcher
+	rollupAddress      common.Address
+	sequencerInbox     SequencerInboxReader
+	sequencerInboxAddr common.Address
+	inbox              LightVerifierInbox
+	batchData          InboxReaderInterface
+	genesisBlockNumber uint64
+	trustedNodes       []trustedNode
+	alerter            *WatchtowerAlerter
+
+	// only accessed from the check thread
+	nextNode       uint64
+	nextBatch      uint64
+	alertedBatches map[uint64]bool
+}
+
+func NewLightVerifier(
+	ctx context.Context,
+	config *LightVerifierConfig,
+	l1Reader *headerreader.HeaderReader,
+	rollupAddress common.Address,
+	sequencerInbox SequencerInboxReader,
+	sequencerInboxAddr common.Address,
+	inbox LightVerifierInbox,
+	batchData InboxReaderInterface,
+	txStreamer TransactionStreamerInterface,
+	alerter *WatchtowerAlerter,
+) (*LightVerifier, error) {
+	if len(config.TrustedNodes) == 0 {
+		return nil, errors.New("light verifier requires at least one trusted node")
+	}
+	rollup, err := NewRollupWatcher(rollupAddress, l1Reader.Client(), bind.CallOpts{})
+	if err != nil {
+		return nil, err
+	}
+	genesisBlockNumber, err := txStreamer.GetGenesisBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	var trustedNodes []trustedNode
+	for _, url := range config.TrustedNodes {
+		client, err := ethclient.DialContext(ctx, url)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to trusted node %v", url)
+		}
+		trustedNodes = append(trustedNodes, trustedNode{url: url, client: client})
+	}
+	return &LightVerifier{
+		config:             *config,
+		l1Reader:           l1Reader,
+		rollup:             rollup,
+		rollupAddress:      rollupAddress,
+		sequencerInbox:     sequencerInbox,
+		sequencerInboxAddr: sequencerInboxAddr,
+		inbox:              inbox,
+		batchData:          batchData,
+		genesisBlockNumber: genesisBlockNumber,
+		trustedNodes:       trustedNodes,
+		alerter:            alerter,
+		alertedBatches:     make(map[uint64]bool),
+	}, nil
+}
+
+// formBatchAccumulator recomputes the accumulator of the batch as the bridge forms it, from the accumulator L1 has
+// for the batch before it, the batch data the node read, and the node's own accumulator of the delayed messages the
+// batch reads up to. It matching L1's accumulator for the batch checks the data the node derives its messages from.
+func (v *LightVerifier) formBatchAccumulator(ctx context.Context, batch uint64, l1BlockNum *big.Int) (common.Hash, error) {
+	var beforeAcc common.Hash
+	if batch > 0 {
+		var err error
+		beforeAcc, err = v.sequencerInbox.GetAccumulator(ctx, batch-1, l1BlockNum)
+		if err != nil {
+			return common.Hash{}, err
+		}
+	}
+	data, err := v.batchData.GetSequencerMessageBytes(ctx, batch)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	delayedCount, err := v.inbox.GetBatchDelayedCount(batch)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var delayedAcc common.Hash
+	if delayedCount > 0 {
+		delayedAcc, err = v.inbox.GetDelayedAcc(delayedCount - 1)
+		if err != nil {
+			return common.Hash{}, err
+		}
+	}
+	return crypto.Keccak256Hash(beforeAcc.Bytes(), crypto.Keccak256(data), delayedAcc.Bytes()), nil
+}
+
+// checkAccumulators compares the accumulators L1 has at l1Block with those formed from the batches the local inbox
+// has, resuming from the last batch checked but checking at most AccumulatorBatches of the most recent.
+func (v *LightVerifier) checkAccumulators(ctx context.Context, l1Block uint64) error {
+	l1BlockNum := new(big.Int).SetUint64(l1Block)
+	l1Count, err := v.sequencerInbox.GetBatchCount(ctx, l1BlockNum)
+	if err != nil {
+		return err
+	}
+	localCount, err := v.inbox.GetBatchCount()
+	if err != nil {
+		return err
+	}
+	count := l1Count
+	if localCount < count {
+		count = localCount
+	}
+	if v.nextBatch > count {
+		// The local inbox was reorged, so check the batches it has again
+		v.nextBatch = count
+	}
+	if v.config.AccumulatorBatches > 0 && count-v.nextBatch > v.config.AccumulatorBatches {
+		v.nextBatch = count - v.config.AccumulatorBatches
+	}
+	for ; v.nextBatch < count; v.nextBatch++ {
+		batch := v.nextBatch
+		localAcc, err := v.formBatchAccumulator(ctx, batch, l1BlockNum)
+		if err != nil {
+			return err
+		}
+		l1Acc, err := v.sequencerInbox.GetAccumulator(ctx, batch, l1BlockNum)
+		if err != nil {
+			return err
+		}
+		if localAcc == l1Acc {
+			delete(v.alertedBatches, batch)
+			continue
+		}
+		log.Error("inbox accumulator differs from L1", "batch", batch, "local", localAcc, "l1", l1Acc, "l1Block", l1Block)
+		if v.alertedBatches[batch] {
+			continue
+		}
+		v.alertedBatches[batch] = true
+		lightVerifierAccMismatchesCounter.Inc(1)
+		v.alert(&InboxAccumulatorAlert{
+			SequencerInbox:   v.sequencerInboxAddr,
+			Batch:            batch,
+			LocalAccumulator: localAcc,
+			L1Accumulator:    l1Acc,
+			L1Block:          l1Block,
+		})
+	}
+	lightVerifierBatchGauge.Update(int64(v.nextBatch))
+	return nil
+}
+
+// trustedBlocks fetches the state of the block from every trusted node, returning false if any doesn't have it yet.
+func (v *LightVerifier) trustedBlocks(ctx context.Context, blockNum uint64) ([]trustedBlock, bool, error) {
+	var blocks []trustedBlock
+	for _, node := range v.trustedNodes {
+		nodeCtx, cancel := context.WithTimeout(ctx, v.config.RequestTimeout)
+		header, err := node.client.HeaderByNumber(nodeCtx, new(big.Int).SetUint64(blockNum))
+		cancel()
+		if errors.Is(err, ethereum.NotFound) || (err == nil && header == nil) {
+			log.Info("trusted node hasn't reached assertion block yet", "node", node.url, "block", blockNum)
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to get block %v from trusted node %v", blockNum, node.url)
+		}
+		extra, err := types.DeserializeHeaderExtraInformation(header)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "invalid block %v from trusted node %v", blockNum, node.url)
+		}
+		blocks = append(blocks, trustedBlock{node: node.url, blockHash: header.Hash(), sendRoot: extra.SendRoot})
+	}
+	return blocks, true, nil
+}
+
+// compareAssertion returns an alert if the assertion's last block doesn't match the state any trusted node
+// reported for it, or nil if it's correct. Trusted nodes disagreeing among themselves are logged, the assertion
+// only being considered invalid if it matches none of them.
+func compareAssertion(rollup common.Address, nd *NodeInfo, lastBlockNum uint64, inboxPositionInvalid bool, expectedNumBlocks uint64, blocks []trustedBlock) *InvalidAssertionAlert {
+	afterGs := nd.AfterState().GlobalState
+	for _, block := range blocks[1:] {
+		if block.blockHash != blocks[0].blockHash || block.sendRoot != blocks[0].sendRoot {
+			lightVerifierTrustedConflictCounter.Inc(1)
+			log.Error("trusted nodes disagree", "block", lastBlockNum, "node", blocks[0].node, "blockHash", blocks[0].blockHash, "otherNode", block.node, "otherBlockHash", block.blockHash)
+		}
+	}
+	positionValid := !inboxPositionInvalid && nd.Assertion.NumBlocks == expectedNumBlocks
+	for _, block := range blocks {
+		if positionValid && afterGs.BlockHash == block.blockHash && afterGs.SendRoot == block.sendRoot {
+			return nil
+		}
+	}
+	return &InvalidAssertionAlert{
+		Rollup:               rollup,
+		Node:                 nd.NodeNum,
+		NodeHash:             nd.NodeHash,
+		ProposedAtL1Block:    nd.BlockProposed,
+		NumBlocks:            nd.Assertion.NumBlocks,
+		BlockHash:            afterGs.BlockHash,
+		SendRoot:             afterGs.SendRoot,
+		Batch:                afterGs.Batch,
+		PosInBatch:           afterGs.PosInBatch,
+		InboxPositionInvalid: inboxPositionInvalid,
+		ExpectedBlockNumber:  lastBlockNum,
+		ExpectedNumBlocks:    expectedNumBlocks,
+		ExpectedBlockHash:    blocks[0].blockHash,
+		ExpectedSendRoot:     blocks[0].sendRoot,
+		TrustedNode:          blocks[0].node,
+	}
+}
+
+// checkNode checks the assertion of the rollup node, returning false if it can't be checked yet because the
+// local inbox or a trusted node hasn't caught up to it.
+func (v *LightVerifier) checkNode(ctx context.Context, nd *NodeInfo) (bool, error) {
+	localBatchCount, err := v.inbox.GetBatchCount()
+	if err != nil {
+		return false, err
+	}
+	afterGs := nd.AfterState().GlobalState
+	if localBatchCount < nd.AfterState().RequiredBatches() || !nd.InboxMaxCount.IsUint64() || localBatchCount < nd.InboxMaxCount.Uint64() {
+		return false, nil
+	}
+	if inboxMaxCount := nd.InboxMaxCount.Uint64(); inboxMaxCount > 0 {
+		localAcc, err := v.inbox.GetBatchAcc(inboxMaxCount - 1)
+		if err != nil {
+			return false, err
+		}
+		if localAcc != nd.AfterInboxBatchAcc {
+			// The node's accumulator was read from L1 when it was created, so it's the local inbox that's wrong
+			log.Error("inbox accumulator differs from the one in rollup node", "node", nd.NodeNum, "batch", inboxMaxCount-1, "local", localAcc, "nodeAcc", nd.AfterInboxBatchAcc)
+			if !v.alertedBatches[inboxMaxCount-1] {
+				v.alertedBatches[inboxMaxCount-1] = true
+				lightVerifierAccMismatchesCounter.Inc(1)
+				v.alert(&InboxAccumulatorAlert{
+					SequencerInbox:   v.sequencerInboxAddr,
+					Batch:            inboxMaxCount - 1,
+					LocalAccumulator: localAcc,
+					L1Accumulator:    nd.AfterInboxBatchAcc,
+					L1Block:          nd.BlockProposed,
+				})
+			}
+		}
+	}
+
+	prevBlockNum, _, err := blockNumberFromGlobalState(v.inbox, v.genesisBlockNumber, nd.Assertion.BeforeState.GlobalState)
+	if err != nil {
+		return false, err
+	}
+	lastBlockNum, inboxPositionInvalid, err := blockNumberFromGlobalState(v.inbox, v.genesisBlockNumber, afterGs)
+	if err != nil {
+		return false, err
+	}
+	if lastBlockNum < 0 || lastBlockNum < prevBlockNum {
+		return false, errors.Errorf("assertion of node %v ends at block %v before it starts at %v", nd.NodeNum, lastBlockNum, prevBlockNum)
+	}
+	blocks, found, err := v.trustedBlocks(ctx, uint64(lastBlockNum))
+	if err != nil || !found {
+		return false, err
+	}
+	alert := compareAssertion(v.rollupAddress, nd, uint64(lastBlockNum), inboxPositionInvalid, uint64(lastBlockNum-prevBlockNum), blocks)
+	if alert == nil {
+		log.Info("light verifier found correct node", "node", nd.NodeNum, "blockNum", lastBlockNum, "blockHash", afterGs.BlockHash)
+		return true, nil
+	}
+	log.Error(
+		"light verifier found node with incorrect assertion",
+		"node", nd.NodeNum,
+		"inboxPositionInvalid", inboxPositionInvalid,
+		"computedBlockNum", lastBlockNum,
+		"numBlocks", nd.Assertion.NumBlocks,
+		"expectedNumBlocks", alert.ExpectedNumBlocks,
+		"blockHash", afterGs.BlockHash,
+		"expectedBlockHash", alert.ExpectedBlockHash,
+		"sendRoot", afterGs.SendRoot,
+		"expectedSendRoot", alert.ExpectedSendRoot,
+		"trustedNode", alert.TrustedNode,
+	)
+	lightVerifierInvalidNodesCounter.Inc(1)
+	v.alert(alert)
+	return true, nil
+}
+
+// checkNodes checks the assertions of the nodes created since the last one checked, starting after the latest
+// confirmed node.
+func (v *LightVerifier) checkNodes(ctx context.Context) error {
+	callOpts := v.rollup.getCallOpts(ctx)
+	if v.nextNode == 0 {
+		latestConfirmed, err := v.rollup.LatestConfirmed(callOpts)
+		if err != nil {
+			return err
+		}
+		v.nextNode = latestConfirmed + 1
+	}
+	latestCreated, err := v.rollup.LatestNodeCreated(callOpts)
+	if err != nil {
+		return err
+	}
+	for ; v.nextNode <= latestCreated; v.nextNode++ {
+		nd, err := v.rollup.LookupNode(ctx, v.nextNode)
+		if err != nil {
+			return err
+		}
+		checked, err := v.checkNode(ctx, nd)
+		if err != nil || !checked {
+			return err
+		}
+		lightVerifierNodeGauge.Update(int64(v.nextNode))
+	}
+	return nil
+}
+
+func (v *LightVerifier) alert(alert WatchtowerAlert) {
+	if !v.alerter.Enabled() {
+		return
+	}
+	v.LaunchThread(func(ctx context.Context) {
+		v.alerter.Alert(ctx, alert)
+	})
+}
+
+func (v *LightVerifier) check(ctx context.Context) time.Duration {
+	header, err := v.l1Reader.LastHeader(ctx)
+	if err != nil {
+		log.Warn("light verifier failed to get L1 header", "err", err)
+		return v.config.CheckInterval
+	}
+	if header.Number.Uint64() > v.config.L1Confirmations {
+		if err := v.checkAccumulators(ctx, header.Number.Uint64()-v.config.L1Confirmations); err != nil {
+			log.Warn("light verifier failed to check batch accumulators", "err", err)
+		}
+	}
+	if err := v.checkNodes(ctx); err != nil {
+		log.Warn("light verifier failed to check assertions", "node", v.nextNode, "err", err)
+	}
+	return v.config.CheckInterval
+}
+
+func (v *LightVerifier) Start(ctxIn context.Context) {
+	v.StopWaiter.Start(ctxIn)
+	v.CallIteratively(v.check)
+}
@@ -0,0 +1,138 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+type testBatchInfo struct {
+	accs        []common.Hash
+	data        [][]byte
+	delayedAccs []common.Hash
+}
+
+func (b *testBatchInfo) GetBatchMessageCount(seqNum uint64) (arbutil.MessageIndex, error) {
+	return arbutil.MessageIndex(seqNum+1) * 10, nil
+}
+
+func (b *testBatchInfo) GetBatchAcc(seqNum uint64) (common.Hash, error) {
+	return b.accs[seqNum], nil
+}
+
+func (b *testBatchInfo) GetBatchCount() (uint64, error) {
+	return uint64(len(b.accs)), nil
+}
+
+// Each batch reads one more delayed message than the one before it
+func (b *testBatchInfo) GetBatchDelayedCount(seqNum uint64) (uint64, error) {
+	return seqNum, nil
+}
+
+func (b *testBatchInfo) GetDelayedAcc(seqNum uint64) (common.Hash, error) {
+	return b.delayedAccs[seqNum], nil
+}
+
+func (b *testBatchInfo) GetSequencerMessageBytes(ctx context.Context, seqNum uint64) ([]byte, error) {
+	return b.data[seqNum], nil
+}
+
+type testSequencerInbox struct {
+	accs []common.Hash
+}
+
+func (i *testSequencerInbox) GetBatchCount(ctx context.Context, blockNumber *big.Int) (uint64, error) {
+	return uint64(len(i.accs)), nil
+}
+
+func (i *testSequencerInbox) GetAccumulator(ctx context.Context, sequenceNumber uint64, blockNumber *big.Int) (common.Hash, error) {
+	return i.accs[sequenceNumber], nil
+}
+
+func TestLightVerifierAccumulators(t *testing.T) {
+	local := &testBatchInfo{}
+	var accs []common.Hash
+	var acc common.Hash
+	for i := 0; i < 10; i++ {
+		data := []byte{byte(i)}
+		var delayedAcc common.Hash
+		if i > 0 {
+			delayedAcc = local.delayedAccs[i-1]
+		}
+		acc = crypto.Keccak256Hash(acc.Bytes(), crypto.Keccak256(data), delayedAcc.Bytes())
+		accs = append(accs, acc)
+		local.data = append(local.data, data)
+		local.delayedAccs = append(local.delayedAccs, common.BigToHash(big.NewInt(int64(i+1))))
+	}
+	local.accs = append([]common.Hash{}, accs...)
+	l1 := &testSequencerInbox{accs: accs[:8]}
+	config := DefaultLightVerifierConfig
+	config.AccumulatorBatches = 4
+	verifier := &LightVerifier{
+		config:         config,
+		sequencerInbox: l1,
+		inbox:          local,
+		batchData:      local,
+		alerter:        NewWatchtowerAlerter(&DefaultWatchtowerAlertsConfig),
+		alertedBatches: make(map[uint64]bool),
+	}
+
+	// The accumulators the local inbox recorded aren't trusted, only what they're formed from
+	local.accs[5] = common.HexToHash("0xbad")
+	local.data[2] = []byte("bad")
+	local.data[6] = []byte("bad")
+	local.delayedAccs[3] = common.HexToHash("0xbad")
+	Require(t, verifier.checkAccumulators(context.Background(), 100))
+	if verifier.nextBatch != 8 {
+		Fail(t, "checked batches L1 doesn't have yet", verifier.nextBatch)
+	}
+	if len(verifier.alertedBatches) != 2 || !verifier.alertedBatches[4] || !verifier.alertedBatches[6] {
+		Fail(t, "unexpected mismatches found", verifier.alertedBatches)
+	}
+
+	// The local inbox reorgs back to the batches on L1
+	local.accs = local.accs[:6]
+	Require(t, verifier.checkAccumulators(context.Background(), 100))
+	if verifier.nextBatch != 6 {
+		Fail(t, "didn't follow local reorg", verifier.nextBatch)
+	}
+}
+
+func TestLightVerifierCompareAssertion(t *testing.T) {
+	rollup := common.HexToAddress("0x01")
+	nd := &NodeInfo{
+		NodeNum: 3,
+		Assertion: &Assertion{
+			BeforeState: &ExecutionState{GlobalState: GoGlobalState{Batch: 1}},
+			AfterState: &ExecutionState{GlobalState: GoGlobalState{
+				Batch:     2,
+				BlockHash: common.HexToHash("0x02"),
+				SendRoot:  common.HexToHash("0x03"),
+			}},
+			NumBlocks: 10,
+		},
+	}
+	correct := trustedBlock{node: "a", blockHash: common.HexToHash("0x02"), sendRoot: common.HexToHash("0x03")}
+	wrong := trustedBlock{node: "b", blockHash: common.HexToHash("0x04"), sendRoot: common.HexToHash("0x03")}
+
+	if alert := compareAssertion(rollup, nd, 20, false, 10, []trustedBlock{correct}); alert != nil {
+		Fail(t, "correct assertion alerted", alert)
+	}
+	if alert := compareAssertion(rollup, nd, 20, false, 10, []trustedBlock{wrong, correct}); alert != nil {
+		Fail(t, "assertion matching a trusted node alerted", alert)
+	}
+	alert := compareAssertion(rollup, nd, 20, false, 10, []trustedBlock{wrong})
+	if alert == nil || alert.ExpectedBlockHash != wrong.blockHash || alert.TrustedNode != "b" || alert.Node != 3 {
+		Fail(t, "unexpected alert for incorrect assertion", alert)
+	}
+	if alert := compareAssertion(rollup, nd, 20, false, 9, []trustedBlock{correct}); alert == nil {
+		Fail(t, "assertion with wrong number of blocks not alerted")
+	}
+}
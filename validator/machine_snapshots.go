@@ -0,0 +1,268 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/pkg/errors"
+)
+
+type MachineSnapshotConfig struct {
+	Enable          bool   `koanf:"enable"`
+	Path            string `koanf:"path"`
+	MessageInterval uint64 `koanf:"message-interval"`
+	StepInterval    uint64 `koanf:"step-interval"`
+	MaxExecutions   int    `koanf:"max-executions"`
+}
+
+var DefaultMachineSnapshotConfig = MachineSnapshotConfig{
+	Enable:          false,
+	Path:            "machine-snapshots",
+	MessageInterval: 16,
+	StepInterval:    200_000_000,
+	MaxExecutions:   32,
+}
+
+func MachineSnapshotConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultMachineSnapshotConfig.Enable, "save snapshots of the arbitrator machine while executing blocks, so challenges and re-validation after a restart resume from the nearest one rather than the start of the block")
+	f.String(prefix+".path", DefaultMachineSnapshotConfig.Path, "directory to save machine snapshots in, relative to the data directory unless absolute")
+	f.Uint64(prefix+".message-interval", DefaultMachineSnapshotConfig.MessageInterval, "snapshot the execution of every nth message validated (executions being challenged are always snapshotted)")
+	f.Uint64(prefix+".step-interval", DefaultMachineSnapshotConfig.StepInterval, "number of machine steps between the snapshots of an execution")
+	f.Int(prefix+".max-executions", DefaultMachineSnapshotConfig.MaxExecutions, "number of executions to keep the snapshots of, the least recently snapshotted being deleted first")
+}
+
+var (
+	machineSnapshotsSavedCounter    = metrics.NewRegisteredCounter("arb/validator/snapshots/saved", nil)
+	machineSnapshotsRestoredCounter = metrics.NewRegisteredCounter("arb/validator/snapshots/restored", nil)
+)
+
+const machineSnapshotSuffix = ".state"
+
+// MachineSnapshotStore keeps snapshots of the machine state partway through executions on disk. An execution is
+// determined by the module root, its start state and the messages it reads, so its snapshots are kept under those,
+// each named by the step it was taken at. Restoring one requires a machine created from the same module root.
+type MachineSnapshotStore struct {
+	config MachineSnapshotConfig
+	dir    string
+	mutex  sync.Mutex // held while saving and pruning
+}
+
+func NewMachineSnapshotStore(config *MachineSnapshotConfig, dir string) (*MachineSnapshotStore, error) {
+	if config.StepInterval == 0 {
+		return nil, errors.New("machine snapshot step interval must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &MachineSnapshotStore{config: *config, dir: dir}, nil
+}
+
+// snapshotMessage returns whether the execution of the message is snapshotted while validating.
+func (s *MachineSnapshotStore) snapshotMessage(msgIndex arbutil.MessageIndex) bool {
+	return s.config.MessageInterval != 0 && uint64(msgIndex)%s.config.MessageInterval == 0
+}
+
+func machineExecutionID(moduleRoot common.Hash, start GoGlobalState, batchHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash(moduleRoot.Bytes(), start.Hash().Bytes(), batchHash.Bytes())
+}
+
+func (s *MachineSnapshotStore) executionDir(id common.Hash) string {
+	return filepath.Join(s.dir, id.Hex())
+}
+
+func (s *MachineSnapshotStore) snapshotPath(id common.Hash, step uint64) string {
+	return filepath.Join(s.executionDir(id), strconv.FormatUint(step, 10)+machineSnapshotSuffix)
+}
+
+// steps returns the steps the execution was snapshotted at, in ascending order.
+func (s *MachineSnapshotStore) steps(id common.Hash) ([]uint64, error) {
+	entries, err := os.ReadDir(s.executionDir(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var steps []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, machineSnapshotSuffix) {
+			continue
+		}
+		step, err := strconv.ParseUint(strings.TrimSuffix(name, machineSnapshotSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i] < steps[j] })
+	return steps, nil
+}
+
+// nearest returns the last step at or before maxStep the execution was snapshotted at, if any.
+func (s *MachineSnapshotStore) nearest(id common.Hash, maxStep uint64) (uint64, bool, error) {
+	steps, err := s.steps(id)
+	if err != nil {
+		return 0, false, err
+	}
+	index := sort.Search(len(steps), func(i int) bool { return steps[i] > maxStep })
+	if index == 0 {
+		return 0, false, nil
+	}
+	return steps[index-1], true, nil
+}
+
+// Save snapshots the machine, which is partway through the execution.
+func (s *MachineSnapshotStore) Save(id common.Hash, mach *ArbitratorMachine) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	dir := s.executionDir(id)
+	_, err := os.Stat(dir)
+	newExecution := os.IsNotExist(err)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := s.snapshotPath(id, mach.GetStepCount())
+	tmpPath := path + ".tmp"
+	if err := mach.SerializeState(tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	machineSnapshotsSavedCounter.Inc(1)
+	if newExecution {
+		return s.prune()
+	}
+	// Mark the execution as recently snapshotted
+	now := time.Now()
+	return os.Chtimes(dir, now, now)
+}
+
+// prune deletes the snapshots of the least recently snapshotted executions beyond those kept.
+// The caller must hold the mutex.
+func (s *MachineSnapshotStore) prune() error {
+	if s.config.MaxExecutions <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	type execution struct {
+		name    string
+		modTime time.Time
+	}
+	var executions []execution
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		executions = append(executions, execution{entry.Name(), info.ModTime()})
+	}
+	if len(executions) <= s.config.MaxExecutions {
+		return nil
+	}
+	sort.Slice(executions, func(i, j int) bool { return executions[i].modTime.Before(executions[j].modTime) })
+	for _, execution := range executions[:len(executions)-s.config.MaxExecutions] {
+		if err := os.RemoveAll(filepath.Join(s.dir, execution.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the machine's state with the last snapshot of the execution at or before maxStep, if it's
+// further along than the machine already is. The machine must be set up for the execution, with its messages added
+// and preimage resolver set, as snapshots include neither. Returns whether a snapshot was restored.
+func (s *MachineSnapshotStore) Restore(id common.Hash, mach *ArbitratorMachine, maxStep uint64) (bool, error) {
+	step, found, err := s.nearest(id, maxStep)
+	if err != nil || !found || step <= mach.GetStepCount() {
+		return false, err
+	}
+	if err := mach.DeserializeAndReplaceState(s.snapshotPath(id, step)); err != nil {
+		return false, fmt.Errorf("failed to restore snapshot at step %v of execution %v: %w", step, id, err)
+	}
+	machineSnapshotsRestoredCounter.Inc(1)
+	return true, nil
+}
+
+// machineSnapshots snapshots a single execution as it's stepped through. A nil *machineSnapshots does nothing,
+// so it may be passed wherever snapshots are disabled.
+type machineSnapshots struct {
+	store *MachineSnapshotStore
+	id    common.Hash
+	save  bool // whether to save new snapshots, or only restore existing ones
+}
+
+func (s *MachineSnapshotStore) execution(moduleRoot common.Hash, start GoGlobalState, batchHash common.Hash, save bool) *machineSnapshots {
+	if s == nil {
+		return nil
+	}
+	return &machineSnapshots{store: s, id: machineExecutionID(moduleRoot, start, batchHash), save: save}
+}
+
+// restore moves the machine up to the nearest snapshot at or before maxStep, logging rather than failing if it
+// can't, as the machine can always be stepped there instead.
+func (m *machineSnapshots) restore(mach *ArbitratorMachine, maxStep uint64) {
+	if m == nil {
+		return
+	}
+	restored, err := m.store.Restore(m.id, mach, maxStep)
+	if err != nil {
+		log.Warn("failed to restore machine snapshot", "execution", m.id, "err", err)
+	} else if restored {
+		log.Info("restored machine snapshot", "execution", m.id, "steps", mach.GetStepCount())
+	}
+}
+
+// stepsBeforeSnapshot limits the steps to take from steps so the machine stops at the next snapshot.
+func (m *machineSnapshots) stepsBeforeSnapshot(steps uint64, count uint64) uint64 {
+	if m == nil || !m.save {
+		return count
+	}
+	interval := m.store.config.StepInterval
+	untilSnapshot := interval - steps%interval
+	if untilSnapshot < count {
+		return untilSnapshot
+	}
+	return count
+}
+
+// snapshot saves the machine's state if it's at a snapshot step and still running.
+func (m *machineSnapshots) snapshot(mach *ArbitratorMachine) {
+	if m == nil || !m.save || !mach.IsRunning() {
+		return
+	}
+	steps := mach.GetStepCount()
+	if steps == 0 || steps%m.store.config.StepInterval != 0 {
+		return
+	}
+	if _, err := os.Stat(m.store.snapshotPath(m.id, steps)); err == nil {
+		return
+	}
+	if err := m.store.Save(m.id, mach); err != nil {
+		log.Warn("failed to save machine snapshot", "execution", m.id, "steps", steps, "err", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMachineSnapshotStore(t *testing.T) {
+	config := DefaultMachineSnapshotConfig
+	config.MessageInterval = 4
+	config.StepInterval = 100
+	config.MaxExecutions = 2
+	store, err := NewMachineSnapshotStore(&config, t.TempDir())
+	Require(t, err)
+
+	if !store.snapshotMessage(8) || store.snapshotMessage(9) {
+		Fail(t, "unexpected messages snapshotted")
+	}
+
+	id := machineExecutionID(common.HexToHash("0x01"), GoGlobalState{Batch: 1}, common.HexToHash("0x02"))
+	if other := machineExecutionID(common.HexToHash("0x01"), GoGlobalState{Batch: 2}, common.HexToHash("0x02")); other == id {
+		Fail(t, "executions from different start states share snapshots")
+	}
+	Require(t, os.MkdirAll(store.executionDir(id), 0755))
+	for _, step := range []uint64{300, 100, 200} {
+		Require(t, os.WriteFile(store.snapshotPath(id, step), nil, 0644))
+	}
+	Require(t, os.WriteFile(store.snapshotPath(id, 400)+".tmp", nil, 0644))
+
+	if _, found, err := store.nearest(id, 99); err != nil || found {
+		Fail(t, "found a snapshot before the first one", err)
+	}
+	if step, found, err := store.nearest(id, 250); err != nil || !found || step != 200 {
+		Fail(t, "unexpected nearest snapshot", step, found, err)
+	}
+	if step, _, _ := store.nearest(id, 1000); step != 300 {
+		Fail(t, "unfinished snapshot used", step)
+	}
+
+	snapshots := store.execution(common.HexToHash("0x01"), GoGlobalState{Batch: 1}, common.HexToHash("0x02"), true)
+	if snapshots.id != id {
+		Fail(t, "unexpected execution id", snapshots.id)
+	}
+	if count := snapshots.stepsBeforeSnapshot(250, 500); count != 50 {
+		Fail(t, "stepped past a snapshot", count)
+	}
+	if count := snapshots.stepsBeforeSnapshot(250, 10); count != 10 {
+		Fail(t, "unexpected steps", count)
+	}
+	var disabled *machineSnapshots
+	if count := disabled.stepsBeforeSnapshot(250, 500); count != 500 {
+		Fail(t, "disabled snapshots limited steps", count)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	Require(t, os.Chtimes(store.executionDir(id), old, old))
+	for _, batch := range []uint64{2, 3} {
+		newer := machineExecutionID(common.HexToHash("0x01"), GoGlobalState{Batch: batch}, common.Hash{})
+		Require(t, os.MkdirAll(store.executionDir(newer), 0755))
+	}
+	Require(t, store.prune())
+	entries, err := os.ReadDir(store.dir)
+	Require(t, err)
+	if len(entries) != 2 {
+		Fail(t, "unexpected executions kept", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(store.dir, id.Hex())); !os.IsNotExist(err) {
+		Fail(t, "least recently snapshotted execution kept", err)
+	}
+}
@@ -68,6 +68,23 @@ func (c NitroMachineConfig) ReadLatestWasmModuleRoot() (common.Hash, error) {
 	return common.HexToHash(s), nil
 }
 
+// AvailableModuleRoots lists the module roots with a machine folder under the root path.
+func (c NitroMachineConfig) AvailableModuleRoots() ([]common.Hash, error) {
+	entries, err := ioutil.ReadDir(c.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	var moduleRoots []common.Hash
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || len(name) != 2+2*common.HashLength || !strings.HasPrefix(name, "0x") {
+			continue
+		}
+		moduleRoots = append(moduleRoots, common.HexToHash(name))
+	}
+	return moduleRoots, nil
+}
+
 type loaderMachineStatus struct {
 	machine    *ArbitratorMachine
 	chanSignal chan struct{}
@@ -0,0 +1,150 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/statehealer"
+	"github.com/pkg/errors"
+)
+
+var ErrPreimageNotFound = errors.New("preimage not found")
+
+// PreimageProvider serves preimages of a single type, such as the blobs a chain posting its batches as
+// EIP-4844 blobs needs, or the payloads of an alternative data availability layer.
+type PreimageProvider interface {
+	PreimageType() arbutil.PreimageType
+	// GetPreimage returns the preimage of the hash, or ErrPreimageNotFound if the provider doesn't have it.
+	GetPreimage(ctx context.Context, hash common.Hash) ([]byte, error)
+}
+
+// PreimageOracle resolves preimages by asking the providers registered for their type, in registration order.
+// Preimages of a type with a known hash function are checked against their hash, so a faulty provider
+// can't have a block validated against the wrong data.
+type PreimageOracle struct {
+	mutex     sync.RWMutex
+	providers map[arbutil.PreimageType][]PreimageProvider
+}
+
+func NewPreimageOracle(providers ...PreimageProvider) *PreimageOracle {
+	oracle := &PreimageOracle{
+		providers: make(map[arbutil.PreimageType][]PreimageProvider),
+	}
+	for _, provider := range providers {
+		oracle.Register(provider)
+	}
+	return oracle
+}
+
+func (o *PreimageOracle) Register(provider PreimageProvider) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	ty := provider.PreimageType()
+	o.providers[ty] = append(o.providers[ty], provider)
+}
+
+func (o *PreimageOracle) Resolve(ctx context.Context, ty arbutil.PreimageType, hash common.Hash) ([]byte, error) {
+	o.mutex.RLock()
+	providers := o.providers[ty]
+	o.mutex.RUnlock()
+	for _, provider := range providers {
+		preimage, err := provider.GetPreimage(ctx, hash)
+		if errors.Is(err, ErrPreimageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !verifyPreimage(ty, hash, preimage) {
+			return nil, fmt.Errorf("%v preimage of %v from %T doesn't match its hash", ty, hash, provider)
+		}
+		return preimage, nil
+	}
+	return nil, fmt.Errorf("%w: %v preimage of %v", ErrPreimageNotFound, ty, hash)
+}
+
+// verifyPreimage checks the preimage against its hash, where that can be done without a proof.
+// Blob preimages are trusted, as their provider is expected to have checked them against their KZG commitment.
+func verifyPreimage(ty arbutil.PreimageType, hash common.Hash, preimage []byte) bool {
+	switch ty {
+	case arbutil.Keccak256PreimageType:
+		return crypto.Keccak256Hash(preimage) == hash
+	case arbutil.Sha2_256PreimageType:
+		return sha256.Sum256(preimage) == hash
+	default:
+		return true
+	}
+}
+
+// MapPreimageProvider serves preimages from a map, such as those recorded while creating a block.
+type MapPreimageProvider struct {
+	Type      arbutil.PreimageType
+	Preimages map[common.Hash][]byte
+}
+
+func (p *MapPreimageProvider) PreimageType() arbutil.PreimageType {
+	return p.Type
+}
+
+func (p *MapPreimageProvider) GetPreimage(ctx context.Context, hash common.Hash) ([]byte, error) {
+	if preimage, ok := p.Preimages[hash]; ok {
+		return preimage, nil
+	}
+	return nil, ErrPreimageNotFound
+}
+
+// blockchainPreimageProvider serves state trie nodes, code and block headers from the local chain.
+type blockchainPreimageProvider struct {
+	bc *core.BlockChain
+}
+
+func (p *blockchainPreimageProvider) PreimageType() arbutil.PreimageType {
+	return arbutil.Keccak256PreimageType
+}
+
+func (p *blockchainPreimageProvider) GetPreimage(ctx context.Context, hash common.Hash) ([]byte, error) {
+	db := p.bc.StateCache().TrieDB()
+	// Check if it's part of the state trie
+	if preimage, err := db.Node(hash); err == nil {
+		return preimage, nil
+	}
+	// Check if it's a code hash
+	codeKey := append([]byte{}, rawdb.CodePrefix...)
+	codeKey = append(codeKey, hash.Bytes()...)
+	if preimage, err := db.DiskDB().Get(codeKey); err == nil {
+		return preimage, nil
+	}
+	// Check if it's a block hash
+	if header := p.bc.GetHeaderByHash(hash); header != nil {
+		return rlp.EncodeToBytes(header)
+	}
+	return nil, ErrPreimageNotFound
+}
+
+// healerPreimageProvider fetches trie nodes and code missing from the database from the healer's peers.
+type healerPreimageProvider struct {
+	healer *statehealer.StateHealer
+}
+
+func (p *healerPreimageProvider) PreimageType() arbutil.PreimageType {
+	return arbutil.Keccak256PreimageType
+}
+
+func (p *healerPreimageProvider) GetPreimage(ctx context.Context, hash common.Hash) ([]byte, error) {
+	preimage, err := p.healer.HealPreimage(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPreimageNotFound, err)
+	}
+	return preimage, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestPreimageOracle(t *testing.T) {
+	ctx := context.Background()
+	preimage := []byte("preimage data")
+	keccakHash := crypto.Keccak256Hash(preimage)
+	sha256Hash := common.Hash(sha256.Sum256(preimage))
+
+	oracle := NewPreimageOracle(
+		&MapPreimageProvider{Type: arbutil.Keccak256PreimageType, Preimages: map[common.Hash][]byte{}},
+		&MapPreimageProvider{Type: arbutil.Keccak256PreimageType, Preimages: map[common.Hash][]byte{keccakHash: preimage}},
+	)
+	resolved, err := oracle.Resolve(ctx, arbutil.Keccak256PreimageType, keccakHash)
+	Require(t, err)
+	if !bytes.Equal(resolved, preimage) {
+		Fail(t, "resolved the wrong keccak preimage")
+	}
+
+	// no provider is registered for sha256 preimages yet
+	if _, err := oracle.Resolve(ctx, arbutil.Sha2_256PreimageType, sha256Hash); !errors.Is(err, ErrPreimageNotFound) {
+		Fail(t, "expected sha256 preimage not to be found, got", err)
+	}
+	oracle.Register(&MapPreimageProvider{Type: arbutil.Sha2_256PreimageType, Preimages: map[common.Hash][]byte{sha256Hash: preimage}})
+	resolved, err = oracle.Resolve(ctx, arbutil.Sha2_256PreimageType, sha256Hash)
+	Require(t, err)
+	if !bytes.Equal(resolved, preimage) {
+		Fail(t, "resolved the wrong sha256 preimage")
+	}
+	// a sha256 preimage isn't served as a keccak one, or the other way around
+	if _, err := oracle.Resolve(ctx, arbutil.Keccak256PreimageType, sha256Hash); !errors.Is(err, ErrPreimageNotFound) {
+		Fail(t, "sha256 preimage resolved as keccak, got", err)
+	}
+
+	// blobs can't be checked against their versioned hash without their KZG proof, so their provider is trusted
+	blob := bytes.Repeat([]byte{7}, 128)
+	versionedHash := common.Hash{0x01, 2, 3}
+	oracle.Register(&MapPreimageProvider{Type: arbutil.EthVersionedHashPreimageType, Preimages: map[common.Hash][]byte{versionedHash: blob}})
+	resolved, err = oracle.Resolve(ctx, arbutil.EthVersionedHashPreimageType, versionedHash)
+	Require(t, err)
+	if !bytes.Equal(resolved, blob) {
+		Fail(t, "resolved the wrong blob")
+	}
+
+	// a provider serving data which doesn't match its hash is rejected
+	wrongHash := common.Hash{1}
+	oracle.Register(&MapPreimageProvider{Type: arbutil.Keccak256PreimageType, Preimages: map[common.Hash][]byte{wrongHash: preimage}})
+	if _, err := oracle.Resolve(ctx, arbutil.Keccak256PreimageType, wrongHash); err == nil || errors.Is(err, ErrPreimageNotFound) {
+		Fail(t, "expected mismatching keccak preimage to be rejected, got", err)
+	}
+	oracle.Register(&MapPreimageProvider{Type: arbutil.Sha2_256PreimageType, Preimages: map[common.Hash][]byte{wrongHash: preimage}})
+	if _, err := oracle.Resolve(ctx, arbutil.Sha2_256PreimageType, wrongHash); err == nil || errors.Is(err, ErrPreimageNotFound) {
+		Fail(t, "expected mismatching sha256 preimage to be rejected, got", err)
+	}
+}
+
+func TestTypedWitnessPreimages(t *testing.T) {
+	preimage := []byte("preimage data")
+	keccakHash := crypto.Keccak256Hash(preimage)
+	sha256Hash := common.Hash(sha256.Sum256(preimage))
+	entry := &WitnessArchiveEntry{
+		BlockNumber: 3,
+		Preimages: []WitnessPreimage{
+			{keccakHash, preimage, arbutil.Keccak256PreimageType},
+			{sha256Hash, preimage, arbutil.Sha2_256PreimageType},
+		},
+	}
+	Require(t, entry.verifyPreimages())
+
+	encoded, err := rlp.EncodeToBytes(entry)
+	Require(t, err)
+	var decoded WitnessArchiveEntry
+	Require(t, rlp.DecodeBytes(encoded, &decoded))
+	if decoded.Preimages[1].Type != arbutil.Sha2_256PreimageType {
+		Fail(t, "preimage type lost in encoding", decoded.Preimages[1].Type)
+	}
+	typed := decoded.TypedPreimageMap()
+	if len(decoded.PreimageMap()) != 1 || !bytes.Equal(typed[arbutil.Sha2_256PreimageType][sha256Hash], preimage) {
+		Fail(t, "preimages not separated by type", typed)
+	}
+	oracle := NewPreimageOracle(decoded.PreimageProviders()...)
+	_, err = oracle.Resolve(context.Background(), arbutil.Sha2_256PreimageType, sha256Hash)
+	Require(t, err)
+
+	// preimages archived before they were typed are keccak preimages
+	type untypedPreimage struct {
+		Hash common.Hash
+		Data []byte
+	}
+	encoded, err = rlp.EncodeToBytes(untypedPreimage{keccakHash, preimage})
+	Require(t, err)
+	var old WitnessPreimage
+	Require(t, rlp.DecodeBytes(encoded, &old))
+	if old.Type != arbutil.Keccak256PreimageType || old.Hash != keccakHash {
+		Fail(t, "untyped preimage not decoded as keccak", old)
+	}
+
+	// a preimage is checked against the hash function of its type
+	entry.Preimages[1].Type = arbutil.Keccak256PreimageType
+	if entry.verifyPreimages() == nil {
+		Fail(t, "sha256 preimage accepted as a keccak preimage")
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+type RemoteValidationConfig struct {
+	URLs                 []string      `koanf:"urls"`
+	JobTimeout           time.Duration `koanf:"job-timeout"`
+	Retries              int           `koanf:"retries"`
+	RetryDelay           time.Duration `koanf:"retry-delay"`
+	CapabilitiesInterval time.Duration `koanf:"capabilities-interval"`
+}
+
+func RemoteValidationConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".urls", DefaultRemoteValidationConfig.URLs, "HTTP or websocket RPC URLs of validation spawners to dispatch validations to instead of executing them locally")
+	f.Duration(prefix+".job-timeout", DefaultRemoteValidationConfig.JobTimeout, "timeout of a single validation on a spawner, including waiting for one of its workers")
+	f.Int(prefix+".retries", DefaultRemoteValidationConfig.Retries, "number of times to retry a failed validation, preferring spawners it hasn't failed on yet")
+	f.Duration(prefix+".retry-delay", DefaultRemoteValidationConfig.RetryDelay, "delay before retrying a failed validation")
+	f.Duration(prefix+".capabilities-interval", DefaultRemoteValidationConfig.CapabilitiesInterval, "how often to renegotiate with spawners, reconnecting to the ones that were down")
+}
+
+var DefaultRemoteValidationConfig = RemoteValidationConfig{
+	URLs:                 []string{},
+	JobTimeout:           10 * time.Minute,
+	Retries:              3,
+	RetryDelay:           time.Second,
+	CapabilitiesInterval: 30 * time.Second,
+}
+
+// How long to wait for spawners when first negotiating with them
+const remoteNegotiationTimeout = 10 * time.Second
+
+type remoteSpawner struct {
+	url        string
+	witnessURL string
+
+	// behind the pool's mutex
+	client       *rpc.Client
+	capabilities *SpawnerCapabilities // nil while the spawner is down
+	inFlight     uint64
+}
+
+func (s *remoteSpawner) supports(moduleRoot common.Hash, witnessSize int) bool {
+	if s.capabilities == nil || uint64(witnessSize) > uint64(s.capabilities.MaxWitnessSize) {
+		return false
+	}
+	for _, supported := range s.capabilities.WasmModuleRoots {
+		if supported == moduleRoot {
+			return true
+		}
+	}
+	return false
+}
+
+// spawnerWitnessURL is where the spawner at the RPC url takes witnesses.
+func spawnerWitnessURL(rpcURL string) (string, error) {
+	parsed, err := url.Parse(rpcURL)
+	if err != nil {
+		return "", err
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+	case "ws":
+		parsed.Scheme = "http"
+	case "wss":
+		parsed.Scheme = "https"
+	default:
+		return "", fmt.Errorf("validation spawner url %v isn't http or websocket", rpcURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/") + ValidationSpawnerWitnessPath
+	return parsed.String(), nil
+}
+
+// spawnerResponseError is an error a spawner responded with, rather than one reaching it.
+type spawnerResponseError struct {
+	status  int
+	message string
+}
+
+func (e *spawnerResponseError) Error() string {
+	return fmt.Sprintf("validation spawner responded %v: %v", e.status, e.message)
+}
+
+func (s *remoteSpawner) validate(ctx context.Context, witness []byte, moduleRoot common.Hash) (*RemoteValidationResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.witnessURL+"?moduleRoot="+moduleRoot.Hex(), bytes.NewReader(witness))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &spawnerResponseError{resp.StatusCode, strings.TrimSpace(string(message))}
+	}
+	var result RemoteValidationResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RemoteValidationPool dispatches validations to validation spawners on other machines, scheduling each on the
+// least loaded spawner that supports its module root.
+type RemoteValidationPool struct {
+	stopwaiter.StopWaiter
+	config   RemoteValidationConfig
+	mutex    sync.Mutex
+	spawners []*remoteSpawner
+}
+
+func NewRemoteValidationPool(config *RemoteValidationConfig) (*RemoteValidationPool, error) {
+	if len(config.URLs) == 0 {
+		return nil, errors.New("no validation spawner urls given")
+	}
+	pool := &RemoteValidationPool{config: *config}
+	for _, rpcURL := range config.URLs {
+		witnessURL, err := spawnerWitnessURL(rpcURL)
+		if err != nil {
+			return nil, err
+		}
+		pool.spawners = append(pool.spawners, &remoteSpawner{url: rpcURL, witnessURL: witnessURL})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteNegotiationTimeout)
+	defer cancel()
+	pool.negotiate(ctx)
+	return pool, nil
+}
+
+func (p *RemoteValidationPool) Start(ctxIn context.Context) {
+	p.StopWaiter.Start(ctxIn)
+	p.CallIteratively(func(ctx context.Context) time.Duration {
+		p.negotiate(ctx)
+		return p.config.CapabilitiesInterval
+	})
+}
+
+// negotiate (re)connects to each spawner and fetches its capabilities, leaving incompatible ones down.
+func (p *RemoteValidationPool) negotiate(ctx context.Context) {
+	for _, spawner := range p.spawners {
+		p.mutex.Lock()
+		client := spawner.client
+		p.mutex.Unlock()
+		var err error
+		if client == nil {
+			client, err = rpc.DialContext(ctx, spawner.url)
+			if err != nil {
+				log.Warn("failed to connect to validation spawner", "url", spawner.url, "err", err)
+				continue
+			}
+		}
+		var capabilities SpawnerCapabilities
+		err = client.CallContext(ctx, &capabilities, "validation_capabilities")
+		if err == nil && capabilities.Version != ValidationSpawnerVersion {
+			err = fmt.Errorf("spawner has version %v, expected %v", capabilities.Version, ValidationSpawnerVersion)
+		}
+		p.mutex.Lock()
+		spawner.client = client
+		if err != nil {
+			if spawner.capabilities != nil {
+				log.Warn("validation spawner down", "url", spawner.url, "err", err)
+			}
+			spawner.capabilities = nil
+		} else {
+			if spawner.capabilities == nil {
+				log.Info("validation spawner up", "url", spawner.url, "moduleRoots", capabilities.WasmModuleRoots, "workers", capabilities.Workers)
+			}
+			spawner.capabilities = &capabilities
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// Workers returns how many validations the spawners that are up can run at once.
+func (p *RemoteValidationPool) Workers() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	workers := 0
+	for _, spawner := range p.spawners {
+		if spawner.capabilities != nil {
+			workers += int(spawner.capabilities.Workers)
+		}
+	}
+	return workers
+}
+
+// pick reserves the least loaded spawner supporting the module root and witness size, preferring ones not yet tried.
+func (p *RemoteValidationPool) pick(moduleRoot common.Hash, witnessSize int, tried map[*remoteSpawner]bool) *remoteSpawner {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var best *remoteSpawner
+	for _, spawner := range p.spawners {
+		if !spawner.supports(moduleRoot, witnessSize) {
+			continue
+		}
+		if best == nil || (tried[best] && !tried[spawner]) {
+			best = spawner
+			continue
+		}
+		if tried[spawner] && !tried[best] {
+			continue
+		}
+		// compare inFlight/workers without dividing
+		if spawner.inFlight*uint64(best.capabilities.Workers) < best.inFlight*uint64(spawner.capabilities.Workers) {
+			best = spawner
+		}
+	}
+	if best != nil {
+		best.inFlight++
+	}
+	return best
+}
+
+func (p *RemoteValidationPool) release(spawner *remoteSpawner, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	spawner.inFlight--
+	if err == nil || spawner.capabilities == nil {
+		return
+	}
+	var responseErr *spawnerResponseError
+	if errors.As(err, &responseErr) && responseErr.status < http.StatusInternalServerError {
+		// it answered, rejecting the witness or failing to validate it
+		return
+	}
+	// it didn't answer, so skip it until it's renegotiated with
+	log.Warn("validation spawner down", "url", spawner.url, "err", err)
+	spawner.capabilities = nil
+}
+
+// Validate executes the block on a spawner, retrying on others if it fails.
+func (p *RemoteValidationPool) Validate(ctx context.Context, entry *WitnessArchiveEntry, moduleRoot common.Hash) (GoGlobalState, ValidationCost, error) {
+	witness, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		return GoGlobalState{}, ValidationCost{}, err
+	}
+	tried := make(map[*remoteSpawner]bool)
+	for attempt := 0; ; attempt++ {
+		var result *RemoteValidationResult
+		spawner := p.pick(moduleRoot, len(witness), tried)
+		if spawner == nil {
+			err = fmt.Errorf("no validation spawner up supporting wasm module root %v and witnesses of %v bytes", moduleRoot, len(witness))
+		} else {
+			tried[spawner] = true
+			jobCtx, cancel := context.WithTimeout(ctx, p.config.JobTimeout)
+			result, err = spawner.validate(jobCtx, witness, moduleRoot)
+			cancel()
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			p.release(spawner, err)
+		}
+		if err == nil {
+			cost := ValidationCost{
+				WallTime:      time.Duration(result.WallTimeMicro) * time.Microsecond,
+				Steps:         uint64(result.Steps),
+				PreimageBytes: uint64(result.PreimageBytes),
+				Cached:        result.Cached,
+			}
+			return result.GlobalState, cost, nil
+		}
+		if ctx.Err() != nil || attempt >= p.config.Retries {
+			return GoGlobalState{}, ValidationCost{}, err
+		}
+		spawnerURL := "none"
+		if spawner != nil {
+			spawnerURL = spawner.url
+		}
+		log.Warn("remote validation failed, retrying", "blockNr", entry.BlockNumber, "spawner", spawnerURL, "attempt", attempt+1, "err", err)
+		select {
+		case <-time.After(p.config.RetryDelay):
+		case <-ctx.Done():
+			return GoGlobalState{}, ValidationCost{}, ctx.Err()
+		}
+	}
+}
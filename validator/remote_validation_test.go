@@ -0,0 +1,143 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type mockSpawnerAPI struct {
+	moduleRoot     common.Hash
+	fail           bool
+	maxWitnessSize int64
+	calls          int32
+}
+
+func (a *mockSpawnerAPI) Capabilities(ctx context.Context) (*SpawnerCapabilities, error) {
+	return &SpawnerCapabilities{
+		Version:         ValidationSpawnerVersion,
+		WasmModuleRoots: []common.Hash{a.moduleRoot},
+		Workers:         2,
+		MaxWitnessSize:  DefaultMaxWitnessSize,
+	}, nil
+}
+
+func (a *mockSpawnerAPI) Validate(ctx context.Context, witness hexutil.Bytes, moduleRoot common.Hash) (*RemoteValidationResult, error) {
+	atomic.AddInt32(&a.calls, 1)
+	if a.fail {
+		return nil, errors.New("machine failed")
+	}
+	var entry WitnessArchiveEntry
+	if err := rlp.DecodeBytes(witness, &entry); err != nil {
+		return nil, err
+	}
+	return &RemoteValidationResult{GlobalState: entry.expectedEnd(), Steps: 100}, nil
+}
+
+func startMockSpawner(t *testing.T, api *mockSpawnerAPI) string {
+	rpcServer := rpc.NewServer()
+	Require(t, rpcServer.RegisterName("validation", api))
+	maxWitnessSize := api.maxWitnessSize
+	if maxWitnessSize == 0 {
+		maxWitnessSize = DefaultMaxWitnessSize
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", rpcServer)
+	mux.Handle(ValidationSpawnerWitnessPath, newWitnessHandler(api.Validate, maxWitnessSize))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRemoteValidationPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	moduleRoot := common.HexToHash("0x01")
+	failing := &mockSpawnerAPI{moduleRoot: moduleRoot, fail: true}
+	working := &mockSpawnerAPI{moduleRoot: moduleRoot}
+	other := &mockSpawnerAPI{moduleRoot: common.HexToHash("0x02")}
+	config := DefaultRemoteValidationConfig
+	config.URLs = []string{startMockSpawner(t, failing), startMockSpawner(t, working), startMockSpawner(t, other)}
+	config.RetryDelay = time.Millisecond
+	pool, err := NewRemoteValidationPool(&config)
+	Require(t, err)
+	if pool.Workers() != 6 {
+		Fail(t, "negotiated", pool.Workers(), "workers, expected 6")
+	}
+
+	entry := &WitnessArchiveEntry{
+		BlockNumber:   5,
+		BlockHash:     common.HexToHash("0xb5"),
+		StartPosition: GlobalStatePosition{BatchNumber: 1},
+		EndPosition:   GlobalStatePosition{BatchNumber: 1, PosInBatch: 1},
+	}
+	for i := 0; i < 2; i++ {
+		gsEnd, cost, err := pool.Validate(ctx, entry, moduleRoot)
+		Require(t, err)
+		if gsEnd != entry.expectedEnd() || cost.Steps != 100 {
+			Fail(t, "unexpected result", gsEnd, cost)
+		}
+	}
+	if atomic.LoadInt32(&working.calls) != 2 || atomic.LoadInt32(&other.calls) != 0 {
+		Fail(t, "jobs went to the wrong spawners")
+	}
+	if atomic.LoadInt32(&failing.calls) == 0 {
+		Fail(t, "the equally loaded failing spawner was never tried")
+	}
+
+	_, _, err = pool.Validate(ctx, entry, common.HexToHash("0x03"))
+	if err == nil {
+		Fail(t, "validated against an unsupported module root")
+	}
+}
+
+func TestRemoteValidationWitnessSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	moduleRoot := common.HexToHash("0x01")
+	// it advertises the default limit, but only reads small witnesses
+	small := &mockSpawnerAPI{moduleRoot: moduleRoot, maxWitnessSize: 16}
+	config := DefaultRemoteValidationConfig
+	config.URLs = []string{startMockSpawner(t, small)}
+	config.Retries = 0
+	pool, err := NewRemoteValidationPool(&config)
+	Require(t, err)
+
+	entry := &WitnessArchiveEntry{
+		BlockNumber: 5,
+		BlockHash:   common.HexToHash("0xb5"),
+		Batches:     []BatchInfo{{Number: 1, Data: make([]byte, 1024)}},
+	}
+	_, _, err = pool.Validate(ctx, entry, moduleRoot)
+	var responseErr *spawnerResponseError
+	if !errors.As(err, &responseErr) || responseErr.status != http.StatusRequestEntityTooLarge {
+		Fail(t, "oversized witness not rejected by the spawner", err)
+	}
+	if atomic.LoadInt32(&small.calls) != 0 {
+		Fail(t, "spawner validated an oversized witness")
+	}
+	if pool.Workers() == 0 {
+		Fail(t, "spawner marked down for rejecting an oversized witness")
+	}
+
+	// A spawner advertising a smaller limit than the witness isn't sent it at all
+	pool.spawners[0].capabilities.MaxWitnessSize = 16
+	_, _, err = pool.Validate(ctx, entry, moduleRoot)
+	if err == nil || errors.As(err, &responseErr) {
+		Fail(t, "oversized witness sent to a spawner advertising a smaller limit", err)
+	}
+}
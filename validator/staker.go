@@ -52,14 +52,17 @@ func L1PostingStrategyAddOptions(prefix string, f *flag.FlagSet) {
 }
 
 type L1ValidatorConfig struct {
-	Enable             bool              `koanf:"enable"`
-	Strategy           string            `koanf:"strategy"`
-	StakerInterval     time.Duration     `koanf:"staker-interval"`
-	L1PostingStrategy  L1PostingStrategy `koanf:"posting-strategy"`
-	DisableChallenge   bool              `koanf:"disable-challenge"`
-	TargetMachineCount int               `koanf:"target-machine-count"`
-	ConfirmationBlocks int64             `koanf:"confirmation-blocks"`
-	Dangerous          DangerousConfig   `koanf:"dangerous"`
+	Enable             bool                    `koanf:"enable"`
+	Strategy           string                  `koanf:"strategy"`
+	StakerInterval     time.Duration           `koanf:"staker-interval"`
+	L1PostingStrategy  L1PostingStrategy       `koanf:"posting-strategy"`
+	DisableChallenge   bool                    `koanf:"disable-challenge"`
+	TargetMachineCount int                     `koanf:"target-machine-count"`
+	ConfirmationBlocks int64                   `koanf:"confirmation-blocks"`
+	Alerts             WatchtowerAlertsConfig  `koanf:"alerts"`
+	Wallets            StakerWalletsConfig     `koanf:"wallets"`
+	AssertionSchedule  AssertionScheduleConfig `koanf:"assertion-schedule"`
+	Dangerous          DangerousConfig         `koanf:"dangerous"`
 }
 
 var DefaultL1ValidatorConfig = L1ValidatorConfig{
@@ -70,6 +73,9 @@ var DefaultL1ValidatorConfig = L1ValidatorConfig{
 	DisableChallenge:   false,
 	TargetMachineCount: 4,
 	ConfirmationBlocks: 12,
+	Alerts:             DefaultWatchtowerAlertsConfig,
+	Wallets:            DefaultStakerWalletsConfig,
+	AssertionSchedule:  DefaultAssertionScheduleConfig,
 	Dangerous:          DangerousConfig{},
 }
 
@@ -81,6 +87,9 @@ func L1ValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".disable-challenge", DefaultL1ValidatorConfig.DisableChallenge, "disable validator challenge")
 	f.Int(prefix+".target-machine-count", DefaultL1ValidatorConfig.TargetMachineCount, "target machine count")
 	f.Int64(prefix+".confirmation-blocks", DefaultL1ValidatorConfig.ConfirmationBlocks, "confirmation blocks")
+	WatchtowerAlertsConfigAddOptions(prefix+".alerts", f)
+	StakerWalletsConfigAddOptions(prefix+".wallets", f)
+	AssertionScheduleConfigAddOptions(prefix+".assertion-schedule", f)
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 }
 
@@ -115,6 +124,36 @@ type Staker struct {
 	bringActiveUntilNode    uint64
 	inboxReader             InboxReaderInterface
 	nitroMachineLoader      *NitroMachineLoader
+	intentLog               *StakerIntentLog
+	challengeProgress       *ChallengeProgressStore
+	machineSnapshots        *MachineSnapshotStore
+	coordinator             *ValidatorCoordinator // nil unless coordinating with other instances
+	alerter                 *WatchtowerAlerter
+	alertedNodes            map[uint64]bool // only accessed from the staker's thread
+	roleWallets             [stakerRoleCount]*stakerRoleWallet
+	challengeBuilder        *ValidatorTxBuilder // nil unless challenge moves have their own wallet
+	executorsAuthorized     bool
+	scheduler               *assertionScheduler
+	postingAssertion        bool   // whether the transaction Act built includes an assertion
+	witnessesPrunedNode     uint64 // the confirmed node the witness archive was last pruned to
+}
+
+// pruneConfirmedWitnesses lets the block validator prune its witness archive once a new node is confirmed,
+// as no fraud proof can need the witnesses it covers. Failures are logged and retried with the next node.
+func (s *Staker) pruneConfirmedWitnesses(ctx context.Context, confirmedNode uint64) {
+	if s.blockValidator == nil || confirmedNode <= s.witnessesPrunedNode {
+		return
+	}
+	nodeInfo, err := s.rollup.LookupNode(ctx, confirmedNode)
+	if err != nil {
+		log.Warn("failed to look up confirmed node to prune witnesses", "node", confirmedNode, "err", err)
+		return
+	}
+	if err := s.blockValidator.AssertionConfirmed(nodeInfo.Assertion.AfterState.GlobalState); err != nil {
+		log.Warn("failed to prune confirmed witnesses", "node", confirmedNode, "err", err)
+		return
+	}
+	s.witnessesPrunedNode = confirmedNode
 }
 
 func stakerStrategyFromString(s string) (StakerStrategy, error) {
@@ -154,7 +193,7 @@ func NewStaker(
 	if err != nil {
 		return nil, err
 	}
-	return &Staker{
+	staker := &Staker{
 		L1Validator:         val,
 		l1Reader:            l1Reader,
 		strategy:            strategy,
@@ -164,7 +203,150 @@ func NewStaker(
 		lastActCalledBlock:  nil,
 		inboxReader:         inboxReader,
 		nitroMachineLoader:  nitroMachineLoader,
-	}, nil
+		alerter:             NewWatchtowerAlerter(&config.Alerts),
+		alertedNodes:        make(map[uint64]bool),
+		scheduler:           newAssertionScheduler(&config.AssertionSchedule),
+	}
+	for role := StakerRole(0); role < stakerRoleCount; role++ {
+		staker.roleWallets[role] = &stakerRoleWallet{
+			role:   role,
+			auth:   wallet.auth,
+			config: *config.Wallets.forRole(role),
+		}
+	}
+	if staker.alerter.Enabled() {
+		val.invalidAssertionHandler = staker.alertInvalidAssertion
+	}
+	return staker, nil
+}
+
+// alertInvalidAssertion pushes an alert for each incorrect assertion once, without holding up the staker.
+func (s *Staker) alertInvalidAssertion(alert *InvalidAssertionAlert) {
+	if s.alertedNodes[alert.Node] {
+		return
+	}
+	s.alertedNodes[alert.Node] = true
+	s.LaunchThread(func(ctx context.Context) {
+		s.alerter.Alert(ctx, alert)
+	})
+}
+
+// SetRoleWallets sends challenge moves and confirmations from their own wallets rather than the L1 wallet, each
+// nil to leave the role to the L1 wallet. The challenge wallet is made an executor of the validator wallet, able
+// to call only the challenge manager through it, so a compromised one can't withdraw the stake. Confirmations
+// need no stake, so the confirmation wallet sends them to the rollup directly and is given no rights over the
+// validator wallet. Must be called before Start.
+func (s *Staker) SetRoleWallets(challenge *bind.TransactOpts, confirmation *bind.TransactOpts) error {
+	if challenge != nil {
+		builder, err := newValidatorTxBuilderFor(s.wallet, challenge.From)
+		if err != nil {
+			return err
+		}
+		s.challengeBuilder = builder
+		s.roleWallets[ChallengeRole].auth = challenge
+		s.roleWallets[ChallengeRole].separate = true
+	}
+	if confirmation != nil {
+		s.roleWallets[ConfirmationRole].auth = confirmation
+		s.roleWallets[ConfirmationRole].separate = true
+	}
+	return nil
+}
+
+// sendingWallet returns the wallet the role's transactions are sent from, the stake wallet if it has none of its own.
+func (s *Staker) sendingWallet(role StakerRole) *stakerRoleWallet {
+	if s.roleWallets[role].separate {
+		return s.roleWallets[role]
+	}
+	return s.roleWallets[StakeRole]
+}
+
+// authorizeExecutors makes the challenge wallet an executor of the validator wallet, returning the transaction
+// doing so if one was needed.
+func (s *Staker) authorizeExecutors(ctx context.Context) (*types.Transaction, error) {
+	executors := executorAddresses(s.roleWallets[:])
+	if s.executorsAuthorized || len(executors) == 0 {
+		return nil, nil
+	}
+	// The rollup must never be allowed, as through it an executor could withdraw the stake
+	dests := []common.Address{s.challengeManagerAddress}
+	arbTx, err := s.wallet.authorizeExecutors(ctx, executors, dests)
+	if err != nil {
+		return nil, err
+	}
+	s.executorsAuthorized = arbTx == nil
+	return arbTx, nil
+}
+
+func (s *Staker) checkWalletBalances(ctx context.Context) {
+	for role, w := range s.roleWallets {
+		if w.auth == nil || (StakerRole(role) != StakeRole && !w.separate) {
+			continue
+		}
+		w.checkBalance(ctx, s.client)
+	}
+}
+
+// SetIntentLog enables persisting L1 actions before they're submitted.
+// Must be called before Initialize.
+func (s *Staker) SetIntentLog(intentLog *StakerIntentLog) {
+	s.intentLog = intentLog
+}
+
+// SetChallengeProgressStore persists the progress of challenges, so they resume where they left off after a restart.
+// Must be called before Start.
+func (s *Staker) SetChallengeProgressStore(store *ChallengeProgressStore) {
+	s.challengeProgress = store
+}
+
+// SetMachineSnapshots snapshots the execution of challenged blocks, and resumes it from snapshots already taken.
+// Must be called before Start.
+func (s *Staker) SetMachineSnapshots(store *MachineSnapshotStore) {
+	s.machineSnapshots = store
+}
+
+// SetCoordinator makes the staker act on L1 only while this instance holds the coordinator's staker lock.
+// Must be called before Start.
+func (s *Staker) SetCoordinator(coordinator *ValidatorCoordinator) {
+	s.coordinator = coordinator
+}
+
+// SetRemoteBlocks has the staker read the blocks it asserts from source rather than the local blockchain, for when
+// messages are executed on a separate execution node. Challenges re-execute blocks, so need the local blockchain, and
+// such a staker can't play them. It would lose any stake it put up to a challenge, so only the watchtower strategy
+// is allowed. Must be called before Start.
+func (s *Staker) SetRemoteBlocks(source L2BlockSource) error {
+	if s.strategy != WatchtowerStrategy {
+		return fmt.Errorf("staker strategy %v can't be used with remote execution, as a staker without local execution can't play challenges, so only Watchtower is allowed", s.config.Strategy)
+	}
+	s.l2Blocks = source
+	s.l2Blockchain = nil
+	return nil
+}
+
+// isStandby returns whether another instance is the one staking.
+func (s *Staker) isStandby() bool {
+	return s.coordinator != nil && !s.coordinator.IsStaker()
+}
+
+// ChallengeProgress returns the progress of the challenge the staker is in, or nil if it isn't in one.
+func (s *Staker) ChallengeProgress() (*ChallengeProgress, error) {
+	if s.challengeProgress == nil {
+		return nil, errors.New("challenge progress isn't persisted")
+	}
+	return s.challengeProgress.Active()
+}
+
+func (s *Staker) Initialize(ctx context.Context) error {
+	err := s.L1Validator.Initialize(ctx)
+	if err != nil {
+		return err
+	}
+	if s.intentLog == nil {
+		return nil
+	}
+	_, err = s.intentLog.Reconcile(ctx, s.l1Reader, s.wallet.From())
+	return err
 }
 
 func (s *Staker) Start(ctxIn context.Context) {
@@ -177,10 +359,12 @@ func (s *Staker) Start(ctxIn context.Context) {
 		}
 		arbTx, err := s.Act(ctx)
 		if err == nil && arbTx != nil {
-			_, err = s.l1Reader.WaitForTxApproval(ctx, arbTx)
+			var receipt *types.Receipt
+			receipt, err = s.l1Reader.WaitForTxApproval(ctx, arbTx)
 			err = errors.Wrap(err, "error waiting for tx receipt")
 			if err == nil {
 				log.Info("successfully executed staker transaction", "hash", arbTx.Hash())
+				s.scheduler.recordCost(receipt, s.postingAssertion, time.Now())
 			}
 		}
 		if err == nil {
@@ -196,6 +380,12 @@ func (s *Staker) Start(ctxIn context.Context) {
 		}
 		return backoff
 	})
+	if s.config.Wallets.BalanceCheckInterval > 0 {
+		s.CallIteratively(func(ctx context.Context) time.Duration {
+			s.checkWalletBalances(ctx)
+			return s.config.Wallets.BalanceCheckInterval
+		})
+	}
 }
 
 func (s *Staker) shouldAct(ctx context.Context) bool {
@@ -205,6 +395,7 @@ func (s *Staker) shouldAct(ctx context.Context) bool {
 	if err != nil {
 		log.Warn("error getting gas price", "err", err)
 	} else {
+		s.scheduler.recordGasPrice(gasPrice)
 		gasPriceFloat = float64(gasPrice.Int64()) / 1e9
 		if gasPriceFloat >= s.config.L1PostingStrategy.HighGasThreshold {
 			gasPriceHigh = true
@@ -248,10 +439,30 @@ func (s *Staker) shouldAct(ctx context.Context) bool {
 }
 
 func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
+	s.postingAssertion = false
 	if !s.shouldAct(ctx) {
 		// The fact that we're delaying acting is alreay logged in `shouldAct`
 		return nil, nil
 	}
+	if s.isStandby() {
+		log.Debug("not acting as staker, as another validator instance holds the staker lock")
+		return nil, nil
+	}
+	if s.intentLog != nil {
+		inFlight, err := s.intentLog.Reconcile(ctx, s.l1Reader, s.wallet.From())
+		if err != nil {
+			return nil, err
+		}
+		if inFlight {
+			return nil, nil
+		}
+	}
+	if s.strategy > WatchtowerStrategy {
+		arbTx, err := s.authorizeExecutors(ctx)
+		if err != nil || arbTx != nil {
+			return arbTx, err
+		}
+	}
 	callOpts := s.getCallOpts(ctx)
 	s.builder.ClearTransactions()
 	var rawInfo *StakerInfo
@@ -318,6 +529,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.pruneConfirmedWitnesses(ctx, latestConfirmedNode)
 
 	requiredStakeElevated, err := s.isRequiredStakeElevated(ctx)
 	if err != nil {
@@ -329,14 +541,21 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 	shouldResolveNodes := effectiveStrategy >= MakeNodesStrategy ||
 		(effectiveStrategy >= StakeLatestStrategy && rawInfo == nil && requiredStakeElevated)
 	resolvingNode := false
-	if shouldResolveNodes {
-		arbTx, err := s.resolveTimedOutChallenges(ctx)
+	confirmer := s.sendingWallet(ConfirmationRole)
+	if shouldResolveNodes && confirmer.canSend(ctx, s.client) {
+		// With its own wallet, the confirmation is sent from it straight to the rollup rather than through the
+		// validator wallet, before touching the stake
+		var direct *bind.TransactOpts
+		if confirmer.separate {
+			direct = confirmer.transactOpts(ctx)
+		}
+		arbTx, err := s.resolveTimedOutChallenges(ctx, direct)
 		if err != nil || arbTx != nil {
 			return arbTx, err
 		}
-		resolvingNode, err = s.resolveNextNode(ctx, rawInfo, &latestConfirmedNode)
-		if err != nil {
-			return nil, err
+		arbTx, resolvingNode, err = s.resolveNextNode(ctx, rawInfo, &latestConfirmedNode, direct)
+		if err != nil || arbTx != nil {
+			return arbTx, err
 		}
 		if resolvingNode && rawInfo == nil && latestConfirmedNode > info.LatestStakedNode {
 			// If we hit this condition, we've resolved what was previously the latest confirmed node,
@@ -368,7 +587,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 				return nil, err
 			}
 			log.Info("removing old stake and withdrawing funds")
-			return s.wallet.ExecuteTransactions(ctx, s.builder)
+			return s.executeTransactions(ctx, rawInfo)
 		}
 	}
 
@@ -415,16 +634,80 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 	if info.StakerInfo == nil && info.StakeExists {
 		log.Info("staking to execute transactions")
 	}
-	return s.wallet.ExecuteTransactions(ctx, s.builder)
+	return s.executeTransactions(ctx, rawInfo)
+}
+
+// executeTransactions sends the built transactions through the wallet,
+// recording the intent first if an intent log is configured.
+func (s *Staker) executeTransactions(ctx context.Context, info *StakerInfo) (*types.Transaction, error) {
+	staker := s.roleWallets[StakeRole]
+	if !staker.canSend(ctx, s.client) {
+		return nil, nil
+	}
+	if s.isStandby() {
+		// The staker lock lapsed while deciding what to do
+		log.Warn("lost the staker lock before sending staker transactions")
+		return nil, nil
+	}
+	if s.intentLog == nil {
+		return s.wallet.executeTransactionsAs(ctx, s.builder, staker.transactOpts(ctx))
+	}
+	nonce, err := s.client.PendingNonceAt(ctx, s.wallet.From())
+	if err != nil {
+		return nil, err
+	}
+	intent := &StakerIntent{
+		Nonce:       nonce,
+		ActionCount: uint64(s.builder.BuildingTransactionCount()),
+		RecordedAt:  uint64(time.Now().Unix()),
+	}
+	if info != nil {
+		intent.LatestStakedNode = info.LatestStakedNode
+		if info.CurrentChallenge != nil {
+			intent.ChallengeIndex = *info.CurrentChallenge
+		}
+	}
+	err = s.intentLog.Record(intent)
+	if err != nil {
+		return nil, err
+	}
+	arbTx, err := s.wallet.executeTransactionsAs(ctx, s.builder, staker.transactOpts(ctx))
+	if err != nil {
+		// The transaction may or may not have reached L1, so leave the intent for reconciliation.
+		return nil, err
+	}
+	if arbTx == nil {
+		return nil, s.intentLog.Clear()
+	}
+	intent.Nonce = arbTx.Nonce()
+	intent.TxHash = arbTx.Hash()
+	err = s.intentLog.Record(intent)
+	if err != nil {
+		return nil, err
+	}
+	return arbTx, nil
 }
 
 func (s *Staker) handleConflict(ctx context.Context, info *StakerInfo) error {
 	if info.CurrentChallenge == nil {
 		s.activeChallenge = nil
+		if s.challengeProgress != nil {
+			finished, err := s.challengeProgress.Active()
+			if err != nil {
+				return err
+			}
+			if finished != nil {
+				log.Info("challenge over", "challenge", finished.ChallengeIndex)
+				return s.challengeProgress.Finish(finished.ChallengeIndex)
+			}
+		}
 		return nil
 	}
 
 	if s.activeChallenge == nil || s.activeChallenge.ChallengeIndex() != *info.CurrentChallenge {
+		if s.l2Blockchain == nil {
+			return fmt.Errorf("in challenge %v, which can't be played as this node executes blocks remotely", *info.CurrentChallenge)
+		}
 		log.Warn("entered challenge", "challenge", info.CurrentChallenge)
 
 		latestConfirmedCreated, err := s.rollup.LatestConfirmedCreationBlock(ctx)
@@ -432,10 +715,14 @@ func (s *Staker) handleConflict(ctx context.Context, info *StakerInfo) error {
 			return err
 		}
 
+		builder := s.builder
+		if s.challengeBuilder != nil {
+			builder = s.challengeBuilder
+		}
 		newChallengeManager, err := NewChallengeManager(
 			ctx,
-			s.builder,
-			s.builder.builderAuth,
+			builder,
+			builder.builderAuth,
 			*s.builder.wallet.Address(),
 			s.challengeManagerAddress,
 			*info.CurrentChallenge,
@@ -452,12 +739,32 @@ func (s *Staker) handleConflict(ctx context.Context, info *StakerInfo) error {
 		if err != nil {
 			return err
 		}
+		if s.challengeProgress != nil {
+			if err := newChallengeManager.SetProgressStore(s.challengeProgress); err != nil {
+				return err
+			}
+		}
+		newChallengeManager.SetMachineSnapshots(s.machineSnapshots)
 
 		s.activeChallenge = newChallengeManager
 	}
 
 	_, err := s.activeChallenge.Act(ctx)
-	return err
+	if err != nil || s.challengeBuilder == nil || s.challengeBuilder.BuildingTransactionCount() == 0 {
+		return err
+	}
+	// Challenge moves have their own wallet, so send them now rather than with the stake's transactions
+	defer s.challengeBuilder.ClearTransactions()
+	challenger := s.roleWallets[ChallengeRole]
+	if !challenger.canSend(ctx, s.client) {
+		return nil
+	}
+	arbTx, err := s.wallet.executeTransactionsAs(ctx, s.challengeBuilder, challenger.transactOpts(ctx))
+	if err != nil {
+		return err
+	}
+	log.Info("sent challenge move from challenge wallet", "hash", arbTx.Hash())
+	return nil
 }
 
 func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiveStrategy StakerStrategy) error {
@@ -490,8 +797,20 @@ func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiv
 			return nil
 		}
 
+		if !wrongNodesExist {
+			post, err := s.assertionDue(ctx, info.LatestStakedNode)
+			if err != nil {
+				return err
+			}
+			if !post {
+				info.CanProgress = false
+				return nil
+			}
+		}
+
 		// Details are already logged with more details in generateNodeAction
 		info.CanProgress = false
+		s.postingAssertion = true
 		info.LatestStakedNode = 0
 		info.LatestStakedNodeHash = action.hash
 
@@ -560,6 +879,35 @@ func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiv
 	}
 }
 
+// assertionDue returns whether the schedule allows posting an assertion following the previous node.
+// Incorrect assertions are challenged regardless of the schedule.
+func (s *Staker) assertionDue(ctx context.Context, prevNode uint64) (bool, error) {
+	if s.config.AssertionSchedule.Interval == 0 {
+		return true, nil
+	}
+	prevInfo, err := s.rollup.LookupNode(ctx, prevNode)
+	if err != nil {
+		return false, err
+	}
+	header, err := s.client.HeaderByNumber(ctx, new(big.Int).SetUint64(prevInfo.BlockProposed))
+	if err != nil {
+		return false, err
+	}
+	sinceLast := time.Since(time.Unix(int64(header.Time), 0))
+	post, reason := s.scheduler.shouldPost(sinceLast)
+	if post {
+		log.Info("posting scheduled assertion", "reason", reason, "sincePrevious", sinceLast)
+	} else {
+		log.Debug("holding back assertion", "reason", reason, "sincePrevious", sinceLast)
+	}
+	return post, nil
+}
+
+// StakingCostProjection projects the monthly cost of the staker's transactions.
+func (s *Staker) StakingCostProjection() *StakingCostProjection {
+	return s.scheduler.projectCost(time.Now())
+}
+
 func (s *Staker) createConflict(ctx context.Context, info *StakerInfo) error {
 	if info.CurrentChallenge != nil {
 		return nil
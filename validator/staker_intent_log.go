@@ -0,0 +1,133 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+)
+
+// StakerIntent describes an L1 transaction the staker is about to send (or has sent)
+// but has not yet seen confirmed. It is written before submission so that, after a crash,
+// the staker can tell whether the action reached L1 before deciding what to do next.
+type StakerIntent struct {
+	Nonce            uint64
+	TxHash           common.Hash // zero until the transaction has been signed and sent
+	ActionCount      uint64
+	LatestStakedNode uint64
+	ChallengeIndex   uint64 // zero if the staker wasn't in a challenge
+	RecordedAt       uint64
+}
+
+type IntentStatus uint8
+
+const (
+	// No intent is recorded, or the recorded one never made it to L1
+	IntentStatusNone IntentStatus = iota
+	// The intent's nonce is still pending on L1
+	IntentStatusInFlight
+	// The intent's nonce has been used by a mined transaction
+	IntentStatusMined
+)
+
+type StakerIntentLog struct {
+	db ethdb.Database
+}
+
+func NewStakerIntentLog(db ethdb.Database) *StakerIntentLog {
+	return &StakerIntentLog{db: db}
+}
+
+// Pending returns the recorded intent, or nil if there isn't one.
+func (l *StakerIntentLog) Pending() (*StakerIntent, error) {
+	exists, err := l.db.Has(stakerPendingIntentKey)
+	if err != nil || !exists {
+		return nil, err
+	}
+	intentBytes, err := l.db.Get(stakerPendingIntentKey)
+	if err != nil {
+		return nil, err
+	}
+	var intent StakerIntent
+	err = rlp.DecodeBytes(intentBytes, &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (l *StakerIntentLog) Record(intent *StakerIntent) error {
+	encoded, err := rlp.EncodeToBytes(intent)
+	if err != nil {
+		return err
+	}
+	return l.db.Put(stakerPendingIntentKey, encoded)
+}
+
+func (l *StakerIntentLog) Clear() error {
+	return l.db.Delete(stakerPendingIntentKey)
+}
+
+// Status compares the recorded intent against the L1 nonces of the sending account.
+// Any pending transaction from the account is treated as the intent still being in flight,
+// as the nonce recorded before submission may have been consumed by wallet creation.
+func (l *StakerIntentLog) Status(ctx context.Context, l1Reader L1ReaderInterface, from common.Address) (*StakerIntent, IntentStatus, error) {
+	intent, err := l.Pending()
+	if err != nil || intent == nil {
+		return nil, IntentStatusNone, err
+	}
+	client := l1Reader.Client()
+	minedNonce, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return intent, IntentStatusNone, errors.WithStack(err)
+	}
+	pendingNonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return intent, IntentStatusNone, errors.WithStack(err)
+	}
+	if pendingNonce > minedNonce && pendingNonce > intent.Nonce {
+		return intent, IntentStatusInFlight, nil
+	}
+	if minedNonce > intent.Nonce {
+		return intent, IntentStatusMined, nil
+	}
+	return intent, IntentStatusNone, nil
+}
+
+// Reconcile resolves a leftover intent against L1. It returns true if an intent is still in flight,
+// in which case the staker must not act until it is mined or dropped.
+func (l *StakerIntentLog) Reconcile(ctx context.Context, l1Reader L1ReaderInterface, from common.Address) (bool, error) {
+	intent, status, err := l.Status(ctx, l1Reader, from)
+	if err != nil || intent == nil {
+		return false, err
+	}
+	switch status {
+	case IntentStatusInFlight:
+		log.Warn(
+			"staker transaction from previous run still pending on L1; waiting before acting",
+			"nonce", intent.Nonce,
+			"tx", intent.TxHash,
+			"age", time.Since(time.Unix(int64(intent.RecordedAt), 0)),
+		)
+		return true, nil
+	case IntentStatusMined:
+		if intent.TxHash != (common.Hash{}) {
+			receipt, err := l1Reader.Client().TransactionReceipt(ctx, intent.TxHash)
+			if err == nil && receipt != nil {
+				log.Info("staker transaction from previous run was mined", "tx", intent.TxHash, "status", receipt.Status)
+			} else {
+				log.Warn("staker nonce from previous run was used by a different transaction", "nonce", intent.Nonce, "tx", intent.TxHash)
+			}
+		}
+	default:
+		log.Info("staker transaction from previous run never reached L1; discarding", "nonce", intent.Nonce)
+	}
+	return false, l.Clear()
+}
@@ -0,0 +1,112 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestStakerIntentLogRoundTrip(t *testing.T) {
+	intentLog := NewStakerIntentLog(rawdb.NewMemoryDatabase())
+
+	intent, err := intentLog.Pending()
+	Require(t, err)
+	if intent != nil {
+		Fail(t, "unexpected intent in empty log", intent)
+	}
+
+	expected := StakerIntent{
+		Nonce:            7,
+		TxHash:           common.HexToHash("0x1234"),
+		ActionCount:      2,
+		LatestStakedNode: 42,
+		ChallengeIndex:   3,
+		RecordedAt:       1650000000,
+	}
+	Require(t, intentLog.Record(&expected))
+
+	intent, err = intentLog.Pending()
+	Require(t, err)
+	if intent == nil || *intent != expected {
+		Fail(t, "recorded intent", expected, "but read back", intent)
+	}
+
+	Require(t, intentLog.Clear())
+	intent, err = intentLog.Pending()
+	Require(t, err)
+	if intent != nil {
+		Fail(t, "intent still present after clear", intent)
+	}
+}
+
+// testIntentL1Client reports the staker account's nonces, and a receipt for mined.
+type testIntentL1Client struct {
+	arbutil.L1Interface
+	minedNonce   uint64
+	pendingNonce uint64
+	mined        common.Hash
+}
+
+func (c *testIntentL1Client) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return c.minedNonce, nil
+}
+
+func (c *testIntentL1Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.pendingNonce, nil
+}
+
+func (c *testIntentL1Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if txHash != c.mined {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash, Status: types.ReceiptStatusSuccessful}, nil
+}
+
+type testIntentL1Reader struct {
+	L1ReaderInterface
+	client *testIntentL1Client
+}
+
+func (r *testIntentL1Reader) Client() arbutil.L1Interface {
+	return r.client
+}
+
+func TestStakerIntentReconcileAfterRestart(t *testing.T) {
+	txHash := common.HexToHash("0x1234")
+	for _, test := range []struct {
+		name     string
+		client   testIntentL1Client
+		inFlight bool
+	}{
+		{"mined", testIntentL1Client{minedNonce: 8, pendingNonce: 8, mined: txHash}, false},
+		{"pending", testIntentL1Client{minedNonce: 7, pendingNonce: 8}, true},
+		{"dropped", testIntentL1Client{minedNonce: 7, pendingNonce: 7}, false},
+	} {
+		db := rawdb.NewMemoryDatabase()
+		Require(t, NewStakerIntentLog(db).Record(&StakerIntent{Nonce: 7, TxHash: txHash, RecordedAt: 1650000000}))
+
+		// The staker restarts with the intent it recorded before sending still in the database
+		intentLog := NewStakerIntentLog(db)
+		client := test.client
+		inFlight, err := intentLog.Reconcile(context.Background(), &testIntentL1Reader{client: &client}, common.Address{})
+		Require(t, err)
+		if inFlight != test.inFlight {
+			Fail(t, test.name, "intent reconciled as in flight", inFlight)
+		}
+		intent, err := intentLog.Pending()
+		Require(t, err)
+		if (intent != nil) != test.inFlight {
+			Fail(t, test.name, "intent kept", intent, "although in flight is", test.inFlight)
+		}
+	}
+}
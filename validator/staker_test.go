@@ -0,0 +1,23 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"testing"
+)
+
+func TestStakerRemoteBlocksStrategy(t *testing.T) {
+	for _, strategy := range []string{"Watchtower", "Defensive", "StakeLatest", "MakeNodes"} {
+		parsed, err := stakerStrategyFromString(strategy)
+		Require(t, err)
+		staker := &Staker{strategy: parsed, config: L1ValidatorConfig{Strategy: strategy}}
+		err = staker.SetRemoteBlocks(nil)
+		if parsed == WatchtowerStrategy && err != nil {
+			Fail(t, "watchtower rejected with remote execution:", err)
+		}
+		if parsed != WatchtowerStrategy && err == nil {
+			Fail(t, "strategy", strategy, "allowed with remote execution, though it can't play challenges")
+		}
+	}
+}
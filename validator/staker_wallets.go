@@ -0,0 +1,229 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/pkg/errors"
+)
+
+// StakerRole is a kind of action the staker takes on L1, each of which may be sent from its own wallet.
+type StakerRole uint8
+
+const (
+	// Placing, moving and withdrawing the stake, sent by the validator wallet's owner
+	StakeRole StakerRole = iota
+	// Moves in challenges the validator is in
+	ChallengeRole
+	// Confirming and rejecting nodes, and timing out challenges, so nodes are confirmed as soon as they can be
+	ConfirmationRole
+	stakerRoleCount
+)
+
+func (r StakerRole) String() string {
+	switch r {
+	case StakeRole:
+		return "stake"
+	case ChallengeRole:
+		return "challenge"
+	case ConfirmationRole:
+		return "confirmation"
+	default:
+		return "unknown"
+	}
+}
+
+var stakerWalletBalanceGauges [stakerRoleCount]metrics.Gauge
+
+func init() {
+	for role := StakerRole(0); role < stakerRoleCount; role++ {
+		stakerWalletBalanceGauges[role] = metrics.NewRegisteredGauge("arb/validator/wallet/"+role.String()+"/balance_gwei", nil)
+	}
+}
+
+type StakerWalletConfig struct {
+	PrivateKey      string  `koanf:"private-key"`
+	MaxGasPriceGwei float64 `koanf:"max-gas-price-gwei"`
+	GasTipCapGwei   float64 `koanf:"gas-tip-cap-gwei"`
+	MinBalance      float64 `koanf:"min-balance"`
+}
+
+var DefaultStakerWalletConfig = StakerWalletConfig{}
+
+// The policy of the challenge and confirmation roles only applies once they have their own key, as they're
+// otherwise sent along with the stake's transactions.
+func StakerWalletConfigAddOptions(prefix string, f *flag.FlagSet, role StakerRole) {
+	wallet := "the L1 wallet"
+	if role != StakeRole {
+		wallet = "the " + role.String() + " wallet"
+		usage := "hex private key of the wallet to send " + role.String() + " transactions from, "
+		if role == ConfirmationRole {
+			usage += "which sends them to the rollup directly and so must be an allowed validator of it"
+		} else {
+			usage += "which is made an executor of the validator wallet, able to call only the challenge manager"
+		}
+		f.String(prefix+".private-key", DefaultStakerWalletConfig.PrivateKey, usage+" (empty to send them from the L1 wallet)")
+	}
+	f.Float64(prefix+".max-gas-price-gwei", DefaultStakerWalletConfig.MaxGasPriceGwei, "don't send transactions from "+wallet+" while the L1 gas price is above this (0 for no limit)")
+	f.Float64(prefix+".gas-tip-cap-gwei", DefaultStakerWalletConfig.GasTipCapGwei, "tip to pay on transactions from "+wallet+" (0 to use the suggested tip)")
+	f.Float64(prefix+".min-balance", DefaultStakerWalletConfig.MinBalance, "log an error when the ETH balance of "+wallet+" is below this (0 to not check it)")
+}
+
+type StakerWalletsConfig struct {
+	Stake                StakerWalletConfig `koanf:"stake"`
+	Challenge            StakerWalletConfig `koanf:"challenge"`
+	Confirmation         StakerWalletConfig `koanf:"confirmation"`
+	BalanceCheckInterval time.Duration      `koanf:"balance-check-interval"`
+}
+
+var DefaultStakerWalletsConfig = StakerWalletsConfig{
+	BalanceCheckInterval: 10 * time.Minute,
+}
+
+func StakerWalletsConfigAddOptions(prefix string, f *flag.FlagSet) {
+	StakerWalletConfigAddOptions(prefix+".stake", f, StakeRole)
+	StakerWalletConfigAddOptions(prefix+".challenge", f, ChallengeRole)
+	StakerWalletConfigAddOptions(prefix+".confirmation", f, ConfirmationRole)
+	f.Duration(prefix+".balance-check-interval", DefaultStakerWalletsConfig.BalanceCheckInterval, "how often to check the balance of each staker wallet")
+}
+
+func (c *StakerWalletsConfig) forRole(role StakerRole) *StakerWalletConfig {
+	switch role {
+	case ChallengeRole:
+		return &c.Challenge
+	case ConfirmationRole:
+		return &c.Confirmation
+	default:
+		return &c.Stake
+	}
+}
+
+// StakerRoleTransactOpts opens the wallets configured for the challenge and confirmation roles, either of which
+// is nil if that role is left to the L1 wallet.
+func StakerRoleTransactOpts(ctx context.Context, client arbutil.L1Interface, config *StakerWalletsConfig) (challenge *bind.TransactOpts, confirmation *bind.TransactOpts, err error) {
+	if config.Challenge.PrivateKey == "" && config.Confirmation.PrivateKey == "" {
+		return nil, nil, nil
+	}
+	chainIdReader, ok := client.(interface {
+		ChainID(ctx context.Context) (*big.Int, error)
+	})
+	if !ok {
+		return nil, nil, errors.New("staker role wallets need an L1 client which reports its chain id")
+	}
+	chainId, err := chainIdReader.ChainID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	open := func(role StakerRole) (*bind.TransactOpts, error) {
+		key := config.forRole(role).PrivateKey
+		if key == "" {
+			return nil, nil
+		}
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(key, "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %v wallet key", role)
+		}
+		return bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	}
+	challenge, err = open(ChallengeRole)
+	if err != nil {
+		return nil, nil, err
+	}
+	confirmation, err = open(ConfirmationRole)
+	if err != nil {
+		return nil, nil, err
+	}
+	return challenge, confirmation, nil
+}
+
+// stakerRoleWallet sends a role's transactions under its gas policy.
+type stakerRoleWallet struct {
+	role     StakerRole
+	auth     *bind.TransactOpts
+	config   StakerWalletConfig
+	separate bool // whether it's a hot key rather than the validator wallet's owner
+}
+
+// transactOpts returns a copy of the wallet's auth for a transaction, so the gas policy doesn't leak into other
+// users of a shared key.
+func (w *stakerRoleWallet) transactOpts(ctx context.Context) *bind.TransactOpts {
+	opts := *w.auth
+	opts.Context = ctx
+	if w.config.GasTipCapGwei > 0 {
+		opts.GasTipCap = floatToBig(w.config.GasTipCapGwei * 1e9)
+	}
+	return &opts
+}
+
+func floatToBig(value float64) *big.Int {
+	result, _ := new(big.Float).SetFloat64(value).Int(nil)
+	return result
+}
+
+func gasPriceAllowed(config *StakerWalletConfig, gasPrice *big.Int) bool {
+	if config.MaxGasPriceGwei <= 0 {
+		return true
+	}
+	return gasPrice.Cmp(floatToBig(config.MaxGasPriceGwei*1e9)) <= 0
+}
+
+func balanceLow(config *StakerWalletConfig, balance *big.Int) bool {
+	if config.MinBalance <= 0 {
+		return false
+	}
+	return balance.Cmp(floatToBig(config.MinBalance*1e18)) < 0
+}
+
+// canSend returns whether the gas price is low enough to send the role's transactions.
+func (w *stakerRoleWallet) canSend(ctx context.Context, client arbutil.L1Interface) bool {
+	if w.config.MaxGasPriceGwei <= 0 {
+		return true
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		log.Warn("error getting gas price", "err", err)
+		return true
+	}
+	if !gasPriceAllowed(&w.config, gasPrice) {
+		log.Warn("not sending staker transactions as gas price is above the role's limit", "role", w.role, "gasPrice", gasPrice, "maxGasPriceGwei", w.config.MaxGasPriceGwei)
+		return false
+	}
+	return true
+}
+
+func (w *stakerRoleWallet) checkBalance(ctx context.Context, client arbutil.L1Interface) {
+	balance, err := client.BalanceAt(ctx, w.auth.From, nil)
+	if err != nil {
+		log.Warn("error getting staker wallet balance", "role", w.role, "address", w.auth.From, "err", err)
+		return
+	}
+	stakerWalletBalanceGauges[w.role].Update(new(big.Int).Div(balance, big.NewInt(1e9)).Int64())
+	if balanceLow(&w.config, balance) {
+		log.Error("staker wallet balance is low", "role", w.role, "address", w.auth.From, "balance", balance, "minBalance", w.config.MinBalance)
+	}
+}
+
+// executorAddresses returns the hot keys which must be executors of the validator wallet. Only challenge moves
+// are sent through it, as confirmations are sent to the rollup directly.
+func executorAddresses(wallets []*stakerRoleWallet) []common.Address {
+	var addrs []common.Address
+	for _, w := range wallets {
+		if w != nil && w.separate && w.role == ChallengeRole {
+			addrs = append(addrs, w.auth.From)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,52 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStakerWalletPolicy(t *testing.T) {
+	config := StakerWalletConfig{MaxGasPriceGwei: 50, MinBalance: 0.5}
+	if !gasPriceAllowed(&config, big.NewInt(50e9)) {
+		Fail(t, "gas price at the limit should be allowed")
+	}
+	if gasPriceAllowed(&config, big.NewInt(50e9+1)) {
+		Fail(t, "gas price above the limit should not be allowed")
+	}
+	if !gasPriceAllowed(&StakerWalletConfig{}, big.NewInt(1e15)) {
+		Fail(t, "gas price should be unlimited by default")
+	}
+	if !balanceLow(&config, big.NewInt(0.5e18-1)) {
+		Fail(t, "balance below the minimum should be low")
+	}
+	if balanceLow(&config, big.NewInt(0.5e18)) {
+		Fail(t, "balance at the minimum should not be low")
+	}
+	if balanceLow(&StakerWalletConfig{}, big.NewInt(0)) {
+		Fail(t, "balance should not be checked by default")
+	}
+
+	owner := &bind.TransactOpts{From: common.HexToAddress("0x01")}
+	wallet := &stakerRoleWallet{role: StakeRole, auth: owner, config: StakerWalletConfig{GasTipCapGwei: 2}}
+	opts := wallet.transactOpts(context.Background())
+	if opts.GasTipCap == nil || opts.GasTipCap.Cmp(big.NewInt(2e9)) != 0 {
+		Fail(t, "unexpected gas tip cap", opts.GasTipCap)
+	}
+	if owner.GasTipCap != nil {
+		Fail(t, "gas policy leaked into the shared key")
+	}
+
+	challenger := &stakerRoleWallet{role: ChallengeRole, auth: &bind.TransactOpts{From: common.HexToAddress("0x02")}, separate: true}
+	confirmer := &stakerRoleWallet{role: ConfirmationRole, auth: &bind.TransactOpts{From: common.HexToAddress("0x03")}, separate: true}
+	executors := executorAddresses([]*stakerRoleWallet{wallet, challenger, confirmer})
+	if len(executors) != 1 || executors[0] != challenger.auth.From {
+		Fail(t, "unexpected executors", executors)
+	}
+}
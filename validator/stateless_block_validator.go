@@ -6,21 +6,24 @@ package validator
 import (
 	"context"
 	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/offchainlabs/nitro/arbutil"
 
 	"github.com/ethereum/go-ethereum/arbitrum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/util/statehealer"
 	"github.com/pkg/errors"
 )
 
@@ -33,6 +36,13 @@ type StatelessBlockValidator struct {
 	db              ethdb.Database
 	daService       arbstate.DataAvailabilityReader
 	genesisBlockNum uint64
+	stateHealer     *statehealer.StateHealer
+
+	extraProvidersMutex sync.Mutex
+	extraProviders      []PreimageProvider
+
+	resultCache      *ValidationResultCache // nil if results aren't cached
+	machineSnapshots *MachineSnapshotStore  // nil if executions aren't snapshotted
 }
 
 type BlockValidatorRegistrer interface {
@@ -217,6 +227,30 @@ func NewStatelessBlockValidator(
 	return validator, nil
 }
 
+// SetStateHealer has validation fetch trie nodes and code missing from the database with the healer.
+func (v *StatelessBlockValidator) SetStateHealer(healer *statehealer.StateHealer) {
+	v.stateHealer = healer
+}
+
+// RegisterPreimageProvider has validation resolve preimages neither recorded nor in the database with the
+// provider, after those registered before it.
+func (v *StatelessBlockValidator) RegisterPreimageProvider(provider PreimageProvider) {
+	v.extraProvidersMutex.Lock()
+	defer v.extraProvidersMutex.Unlock()
+	v.extraProviders = append(v.extraProviders, provider)
+}
+
+func (v *StatelessBlockValidator) preimageProviders() []PreimageProvider {
+	v.extraProvidersMutex.Lock()
+	providers := append([]PreimageProvider{}, v.extraProviders...)
+	v.extraProvidersMutex.Unlock()
+	// The healer fetches over the network, so it's only asked as a last resort
+	if v.stateHealer != nil {
+		providers = append(providers, &healerPreimageProvider{v.stateHealer})
+	}
+	return providers
+}
+
 type BatchInfo struct {
 	Number uint64
 	Data   []byte
@@ -334,13 +368,8 @@ func BlockDataForValidation(ctx context.Context, blockchain *core.BlockChain, in
 	return
 }
 
-func SetMachinePreimageResolver(ctx context.Context, mach *ArbitratorMachine, preimages map[common.Hash][]byte, batchInfo []BatchInfo, bc *core.BlockChain, das arbstate.DataAvailabilityReader) error {
-	recordNewPreimages := true
-	if preimages == nil {
-		preimages = make(map[common.Hash][]byte)
-		recordNewPreimages = false
-	}
-
+// recordDasPreimages adds the preimages of the DAS payloads the batches refer to.
+func recordDasPreimages(ctx context.Context, batchInfo []BatchInfo, preimages map[common.Hash][]byte, bc *core.BlockChain, das arbstate.DataAvailabilityReader) error {
 	for _, batch := range batchInfo {
 		if len(batch.Data) >= 41 && arbstate.IsDASMessageHeaderByte(batch.Data[40]) {
 			if das == nil {
@@ -356,91 +385,207 @@ func SetMachinePreimageResolver(ctx context.Context, mach *ArbitratorMachine, pr
 			}
 		}
 	}
+	return nil
+}
+
+func SetMachinePreimageResolver(ctx context.Context, mach *ArbitratorMachine, preimages map[common.Hash][]byte, batchInfo []BatchInfo, bc *core.BlockChain, das arbstate.DataAvailabilityReader) error {
+	return setMachinePreimageResolver(ctx, mach, preimages, batchInfo, bc, das, nil, nil)
+}
 
-	db := bc.StateCache().TrieDB()
+// setMachinePreimageResolver is SetMachinePreimageResolver, additionally adding the size of each
+// preimage the machine resolves to resolvedBytes, if it isn't nil, and falling back to the given
+// providers for preimages neither recorded nor in the database.
+func setMachinePreimageResolver(ctx context.Context, mach *ArbitratorMachine, preimages map[common.Hash][]byte, batchInfo []BatchInfo, bc *core.BlockChain, das arbstate.DataAvailabilityReader, resolvedBytes *uint64, providers []PreimageProvider) error {
+	recordNewPreimages := true
+	if preimages == nil {
+		preimages = make(map[common.Hash][]byte)
+		recordNewPreimages = false
+	}
+
+	if err := recordDasPreimages(ctx, batchInfo, preimages, bc, das); err != nil {
+		return err
+	}
+
+	recorded := &MapPreimageProvider{Type: arbutil.Keccak256PreimageType, Preimages: preimages}
+	oracle := NewPreimageOracle(recorded, &blockchainPreimageProvider{bc})
+	for _, provider := range providers {
+		oracle.Register(provider)
+	}
 	return mach.SetPreimageResolver(func(hash common.Hash) ([]byte, error) {
-		// Check if it's a known preimage
-		if preimage, ok := preimages[hash]; ok {
-			return preimage, nil
-		}
-		// Check if it's part of the state trie
-		preimage, err := db.Node(hash)
+		_, known := preimages[hash]
+		// The machine only looks up preimages by their keccak hash
+		preimage, err := oracle.Resolve(ctx, arbutil.Keccak256PreimageType, hash)
 		if err != nil {
-			// Check if it's a code hash
-			codeKey := append([]byte{}, rawdb.CodePrefix...)
-			codeKey = append(codeKey, hash.Bytes()...)
-			preimage, err = db.DiskDB().Get(codeKey)
+			return nil, err
 		}
-		if err != nil {
-			// Check if it's a block hash
-			header := bc.GetHeaderByHash(hash)
-			if header != nil {
-				preimage, err = rlp.EncodeToBytes(header)
-			}
-		}
-		if err == nil && recordNewPreimages {
+		if !known && recordNewPreimages {
 			preimages[hash] = preimage
 		}
-		return preimage, err
+		if resolvedBytes != nil {
+			atomic.AddUint64(resolvedBytes, uint64(len(preimage)))
+		}
+		return preimage, nil
 	})
 }
 
-func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, error) {
-	start := entry.StartPosition
+// SetResultCache caches the end state of every block executed, reusing it instead of executing the same block again.
+func (v *StatelessBlockValidator) SetResultCache(cache *ValidationResultCache) {
+	v.resultCache = cache
+}
+
+func (v *StatelessBlockValidator) SetMachineSnapshots(store *MachineSnapshotStore) {
+	v.machineSnapshots = store
+}
+
+func (v *StatelessBlockValidator) readDelayedMsg(entry *validationEntry) ([]byte, error) {
+	if !entry.HasDelayedMsg {
+		return nil, nil
+	}
+	delayedMsg, err := v.inboxTracker.GetDelayedMessageBytes(entry.DelayedMsgNr)
+	if err != nil {
+		log.Error("error while trying to read delayed msg for proving", "err", err, "seq", entry.DelayedMsgNr, "blockNr", entry.BlockNumber)
+		return nil, errors.New("error while trying to read delayed msg for proving")
+	}
+	return delayedMsg, nil
+}
+
+// cachedResult returns the block's end state if it was already executed, and the batch hash to cache it under.
+func (v *StatelessBlockValidator) cachedResult(entry *validationEntry, moduleRoot common.Hash, delayedMsg []byte) (GoGlobalState, common.Hash, bool) {
+	if v.resultCache == nil {
+		return GoGlobalState{}, common.Hash{}, false
+	}
+	batchHash := validationBatchHash(entry.BatchInfo, entry.HasDelayedMsg, entry.DelayedMsgNr, delayedMsg)
+	gsEnd, found := v.resultCache.Get(moduleRoot, entry.start(), batchHash)
+	return gsEnd, batchHash, found
+}
+
+func (v *StatelessBlockValidator) cacheResult(entry *validationEntry, moduleRoot common.Hash, batchHash common.Hash, gsEnd GoGlobalState) {
+	if v.resultCache != nil {
+		v.resultCache.Put(moduleRoot, entry.start(), batchHash, gsEnd)
+	}
+}
+
+func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, ValidationCost, error) {
 	gsStart := entry.start()
+	start := time.Now()
+
+	delayedMsg, err := v.readDelayedMsg(entry)
+	if err != nil {
+		return GoGlobalState{}, nil, ValidationCost{}, err
+	}
+	gsEnd, batchHash, found := v.cachedResult(entry, moduleRoot, delayedMsg)
+	if found {
+		return gsEnd, delayedMsg, ValidationCost{WallTime: time.Since(start), Cached: true}, nil
+	}
 
 	basemachine, err := v.MachineLoader.GetMachine(ctx, moduleRoot, true)
 	if err != nil {
-		return GoGlobalState{}, nil, fmt.Errorf("unabled to get WASM machine: %w", err)
+		return GoGlobalState{}, nil, ValidationCost{}, fmt.Errorf("unabled to get WASM machine: %w", err)
 	}
 	mach := basemachine.Clone()
-	err = SetMachinePreimageResolver(ctx, mach, entry.Preimages, entry.BatchInfo, v.blockchain, v.daService)
+	var preimageBytes uint64
+	err = setMachinePreimageResolver(ctx, mach, entry.Preimages, entry.BatchInfo, v.blockchain, v.daService, &preimageBytes, v.preimageProviders())
 	if err != nil {
-		return GoGlobalState{}, nil, err
+		return GoGlobalState{}, nil, ValidationCost{}, err
+	}
+	var snapshots *machineSnapshots
+	if v.machineSnapshots != nil {
+		msgIndex := arbutil.BlockNumberToMessageCount(entry.BlockNumber, v.genesisBlockNum) - 1
+		batchHash := validationBatchHash(entry.BatchInfo, entry.HasDelayedMsg, entry.DelayedMsgNr, delayedMsg)
+		snapshots = v.machineSnapshots.execution(moduleRoot, gsStart, batchHash, v.machineSnapshots.snapshotMessage(msgIndex))
 	}
-	err = mach.SetGlobalState(gsStart)
+	gsEnd, err = runMachine(ctx, mach, moduleRoot, entry.BlockNumber, gsStart, entry.BatchInfo, entry.HasDelayedMsg, entry.DelayedMsgNr, delayedMsg, snapshots)
+	cost := ValidationCost{
+		WallTime:      time.Since(start),
+		Steps:         mach.GetStepCount(),
+		PreimageBytes: atomic.LoadUint64(&preimageBytes),
+	}
+	if err == nil {
+		v.cacheResult(entry, moduleRoot, batchHash, gsEnd)
+	}
+	return gsEnd, delayedMsg, cost, err
+}
+
+// runMachine executes the block, resuming from the nearest snapshot of its execution if snapshots isn't nil.
+func runMachine(ctx context.Context, mach *ArbitratorMachine, moduleRoot common.Hash, blockNumber uint64, gsStart GoGlobalState, batchInfo []BatchInfo, hasDelayedMsg bool, delayedMsgNr uint64, delayedMsg []byte, snapshots *machineSnapshots) (GoGlobalState, error) {
+	err := mach.SetGlobalState(gsStart)
 	if err != nil {
 		log.Error("error while setting global state for proving", "err", err, "gsStart", gsStart)
-		return GoGlobalState{}, nil, errors.New("error while setting global state for proving")
+		return GoGlobalState{}, errors.New("error while setting global state for proving")
 	}
-	for _, batch := range entry.BatchInfo {
+	for _, batch := range batchInfo {
 		err = mach.AddSequencerInboxMessage(batch.Number, batch.Data)
 		if err != nil {
-			log.Error("error while trying to add sequencer msg for proving", "err", err, "seq", start.BatchNumber, "blockNr", entry.BlockNumber)
-			return GoGlobalState{}, nil, errors.New("error while trying to add sequencer msg for proving")
+			log.Error("error while trying to add sequencer msg for proving", "err", err, "seq", batch.Number, "blockNr", blockNumber)
+			return GoGlobalState{}, errors.New("error while trying to add sequencer msg for proving")
 		}
 	}
-	var delayedMsg []byte
-	if entry.HasDelayedMsg {
-		delayedMsg, err = v.inboxTracker.GetDelayedMessageBytes(entry.DelayedMsgNr)
+	if hasDelayedMsg {
+		err = mach.AddDelayedInboxMessage(delayedMsgNr, delayedMsg)
 		if err != nil {
-			log.Error("error while trying to read delayed msg for proving", "err", err, "seq", entry.DelayedMsgNr, "blockNr", entry.BlockNumber)
-			return GoGlobalState{}, nil, errors.New("error while trying to read delayed msg for proving")
-		}
-		err = mach.AddDelayedInboxMessage(entry.DelayedMsgNr, delayedMsg)
-		if err != nil {
-			log.Error("error while trying to add delayed msg for proving", "err", err, "seq", entry.DelayedMsgNr, "blockNr", entry.BlockNumber)
-			return GoGlobalState{}, nil, errors.New("error while trying to add delayed msg for proving")
+			log.Error("error while trying to add delayed msg for proving", "err", err, "seq", delayedMsgNr, "blockNr", blockNumber)
+			return GoGlobalState{}, errors.New("error while trying to add delayed msg for proving")
 		}
 	}
 
-	var steps uint64
+	snapshots.restore(mach, math.MaxUint64)
 	for mach.IsRunning() {
-		var count uint64 = 500000000
+		steps := mach.GetStepCount()
+		count := snapshots.stepsBeforeSnapshot(steps, 500000000)
 		err = mach.Step(ctx, count)
 		if steps > 0 {
-			log.Debug("validation", "moduleRoot", moduleRoot, "block", entry.BlockNumber, "steps", steps)
+			log.Debug("validation", "moduleRoot", moduleRoot, "block", blockNumber, "steps", steps)
 		}
 		if err != nil {
-			return GoGlobalState{}, nil, fmt.Errorf("machine execution failed with error: %w", err)
+			return GoGlobalState{}, fmt.Errorf("machine execution failed with error: %w", err)
 		}
-		steps += count
+		snapshots.snapshot(mach)
 	}
 	if mach.IsErrored() {
-		log.Error("machine entered errored state during attempted validation", "block", entry.BlockNumber)
-		return GoGlobalState{}, nil, errors.New("machine entered errored state during attempted validation")
+		log.Error("machine entered errored state during attempted validation", "block", blockNumber)
+		return GoGlobalState{}, errors.New("machine entered errored state during attempted validation")
+	}
+	return mach.GetGlobalState(), nil
+}
+
+// ValidateArchivedBlock re-executes a block using only its archived witness, without
+// consulting the L1, the DAS, or the node's state.
+func (v *StatelessBlockValidator) ValidateArchivedBlock(ctx context.Context, entry *WitnessArchiveEntry, moduleRoot common.Hash) (bool, error) {
+	gsEnd, _, err := executeWitness(ctx, v.MachineLoader, entry, moduleRoot)
+	if err != nil {
+		return false, err
+	}
+	return gsEnd == entry.expectedEnd(), nil
+}
+
+// executeWitness runs a block's machine resolving preimages only from its witness.
+func executeWitness(ctx context.Context, loader *NitroMachineLoader, entry *WitnessArchiveEntry, moduleRoot common.Hash) (GoGlobalState, ValidationCost, error) {
+	start := time.Now()
+	basemachine, err := loader.GetMachine(ctx, moduleRoot, true)
+	if err != nil {
+		return GoGlobalState{}, ValidationCost{}, fmt.Errorf("unabled to get WASM machine: %w", err)
+	}
+	mach := basemachine.Clone()
+	oracle := NewPreimageOracle(entry.PreimageProviders()...)
+	var preimageBytes uint64
+	err = mach.SetPreimageResolver(func(hash common.Hash) ([]byte, error) {
+		preimage, err := oracle.Resolve(ctx, arbutil.Keccak256PreimageType, hash)
+		if err != nil {
+			return nil, fmt.Errorf("in witness of block %v: %w", entry.BlockNumber, err)
+		}
+		atomic.AddUint64(&preimageBytes, uint64(len(preimage)))
+		return preimage, nil
+	})
+	if err != nil {
+		return GoGlobalState{}, ValidationCost{}, err
+	}
+	gsEnd, err := runMachine(ctx, mach, moduleRoot, entry.BlockNumber, entry.start(), entry.Batches, entry.HasDelayedMsg, entry.DelayedMsgNr, entry.DelayedMsg, nil)
+	cost := ValidationCost{
+		WallTime:      time.Since(start),
+		Steps:         mach.GetStepCount(),
+		PreimageBytes: atomic.LoadUint64(&preimageBytes),
 	}
-	return mach.GetGlobalState(), delayedMsg, nil
+	return gsEnd, cost, err
 }
 
 func (v *StatelessBlockValidator) ValidateBlock(ctx context.Context, header *types.Header, moduleRoot common.Hash) (bool, error) {
@@ -492,7 +637,7 @@ func (v *StatelessBlockValidator) ValidateBlock(ctx context.Context, header *typ
 		Data:   seqMsg,
 	})
 
-	gsEnd, _, err := v.executeBlock(ctx, entry, moduleRoot)
+	gsEnd, _, _, err := v.executeBlock(ctx, entry, moduleRoot)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,131 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/util/openmetrics"
+)
+
+const validationWallTimeMetric = "arb/validator/block/walltime"
+
+var (
+	validationWallTimeHistogram      = metrics.NewRegisteredHistogram(validationWallTimeMetric, nil, metrics.NewExpDecaySample(1028, 0.015))
+	validationStepsHistogram         = metrics.NewRegisteredHistogram("arb/validator/block/steps", nil, metrics.NewExpDecaySample(1028, 0.015))
+	validationPreimageBytesHistogram = metrics.NewRegisteredHistogram("arb/validator/block/preimagebytes", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
+// ValidationCost is what validating one block against one module root took.
+type ValidationCost struct {
+	WallTime      time.Duration
+	Steps         uint64 // machine steps executed, the validator's equivalent of gas
+	PreimageBytes uint64 // total size of the preimages the machine resolved
+	Cached        bool   // the result came from the validation result cache, so nothing was executed
+}
+
+// How many of the most recent validations ValidationCosts summarizes
+const validationCostWindow = 1024
+
+// validationCostLog keeps the costs of the most recent validations, which the metrics histograms can't
+// provide as they discard everything unless metrics are enabled.
+type validationCostLog struct {
+	mutex  sync.Mutex
+	count  int64
+	recent []ValidationCost // a ring buffer of up to validationCostWindow validations
+	next   int
+}
+
+func (l *validationCostLog) record(cost ValidationCost) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.count++
+	if len(l.recent) < validationCostWindow {
+		l.recent = append(l.recent, cost)
+		return
+	}
+	l.recent[l.next] = cost
+	l.next = (l.next + 1) % validationCostWindow
+}
+
+func (v *BlockValidator) recordValidationCost(ctx context.Context, blockNumber uint64, moduleRoot common.Hash, cost ValidationCost) {
+	v.validationCosts.record(cost)
+	validationWallTimeHistogram.Update(cost.WallTime.Microseconds())
+	openmetrics.RecordExemplarInTrace(ctx, validationWallTimeMetric, float64(cost.WallTime.Microseconds()), map[string]string{
+		"block":       strconv.FormatUint(blockNumber, 10),
+		"module_root": moduleRoot.Hex()[:18],
+	})
+	validationStepsHistogram.Update(int64(cost.Steps))
+	validationPreimageBytesHistogram.Update(int64(cost.PreimageBytes))
+}
+
+// ValidationCostPercentiles summarizes one measure of validation cost over recently validated blocks.
+type ValidationCostPercentiles struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  int64   `json:"max"`
+}
+
+// ValidationCostStats summarizes the costs of recently validated blocks, for capacity planning.
+type ValidationCostStats struct {
+	Blocks        int64                     `json:"blocks"` // validations recorded since startup
+	WallTimeMicro ValidationCostPercentiles `json:"wallTimeMicroseconds"`
+	Steps         ValidationCostPercentiles `json:"steps"`
+	PreimageBytes ValidationCostPercentiles `json:"preimageBytes"`
+}
+
+func costPercentiles(values []int64) ValidationCostPercentiles {
+	if len(values) == 0 {
+		return ValidationCostPercentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	var sum float64
+	for _, value := range values {
+		sum += float64(value)
+	}
+	// the nearest rank percentile
+	percentile := func(p float64) float64 {
+		return float64(values[int(math.Ceil(p*float64(len(values))))-1])
+	}
+	return ValidationCostPercentiles{
+		Mean: sum / float64(len(values)),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		P99:  percentile(0.99),
+		Max:  values[len(values)-1],
+	}
+}
+
+// ValidationCosts returns percentiles of the wall time, machine steps and preimage bytes of
+// the most recent block validations.
+func (v *BlockValidator) ValidationCosts() ValidationCostStats {
+	costs := &v.validationCosts
+	costs.mutex.Lock()
+	wallTimes := make([]int64, len(costs.recent))
+	steps := make([]int64, len(costs.recent))
+	preimageBytes := make([]int64, len(costs.recent))
+	for i, cost := range costs.recent {
+		wallTimes[i] = cost.WallTime.Microseconds()
+		steps[i] = int64(cost.Steps)
+		preimageBytes[i] = int64(cost.PreimageBytes)
+	}
+	count := costs.count
+	costs.mutex.Unlock()
+	return ValidationCostStats{
+		Blocks:        count,
+		WallTimeMicro: costPercentiles(wallTimes),
+		Steps:         costPercentiles(steps),
+		PreimageBytes: costPercentiles(preimageBytes),
+	}
+}
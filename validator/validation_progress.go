@@ -0,0 +1,70 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// Forced so the validation status reports throughput even with metrics disabled
+var validatedMessagesMeter = metrics.NewRegisteredMeterForced("arb/validator/validated", nil)
+
+// ValidationProgress reports how far block validation has got and how quickly, so operators can alert on it
+// falling behind the chain.
+type ValidationProgress struct {
+	LastValidatedBlock   uint64        `json:"lastValidatedBlock"`
+	LastValidatedMessage uint64        `json:"lastValidatedMessage"`
+	LastValidatedBatch   uint64        `json:"lastValidatedBatch"`
+	HeadBlock            uint64        `json:"headBlock"`
+	BlocksBehind         uint64        `json:"blocksBehind"`
+	JobsInFlight         int32         `json:"jobsInFlight"`
+	MessagesPerSecond1m  float64       `json:"messagesPerSecond1m"`
+	MessagesPerSecond5m  float64       `json:"messagesPerSecond5m"`
+	ModuleRoots          []common.Hash `json:"moduleRoots"`
+}
+
+// ValidationStatus returns the validator's progress. Each block being one message, the throughput is also
+// blocks validated per second, averaged over the last one and five minutes.
+func (v *BlockValidator) ValidationStatus() (*ValidationProgress, error) {
+	var headBlock uint64
+	if head := v.blockchain.CurrentBlock(); head != nil {
+		headBlock = head.NumberU64()
+	}
+	return v.validationProgress(headBlock)
+}
+
+// validationProgress reports the validator's progress against a chain whose head is headBlock.
+func (v *BlockValidator) validationProgress(headBlock uint64) (*ValidationProgress, error) {
+	lastBlock, _, moduleRoots := v.LastBlockValidatedAndHash()
+	progress := &ValidationProgress{
+		LastValidatedBlock:  lastBlock,
+		HeadBlock:           headBlock,
+		JobsInFlight:        atomic.LoadInt32(&v.atomicValidationsRunning),
+		MessagesPerSecond1m: validatedMessagesMeter.Rate1(),
+		MessagesPerSecond5m: validatedMessagesMeter.Rate5(),
+		ModuleRoots:         moduleRoots,
+	}
+	if progress.HeadBlock > lastBlock {
+		progress.BlocksBehind = progress.HeadBlock - lastBlock
+	}
+	msgCount := arbutil.BlockNumberToMessageCount(lastBlock, v.genesisBlockNum)
+	if msgCount == 0 {
+		return progress, nil
+	}
+	progress.LastValidatedMessage = uint64(msgCount - 1)
+	batchCount, err := v.inboxTracker.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	batch, err := FindBatchContainingMessageIndex(v.inboxTracker, msgCount-1, batchCount)
+	if err != nil {
+		return nil, err
+	}
+	progress.LastValidatedBatch = batch
+	return progress, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// progressInboxTracker has batches of ten messages each
+type progressInboxTracker struct {
+	batchCount uint64
+}
+
+func (t *progressInboxTracker) SetBlockValidator(*BlockValidator) {}
+
+func (t *progressInboxTracker) GetDelayedMessageBytes(uint64) ([]byte, error) {
+	return nil, nil
+}
+
+func (t *progressInboxTracker) GetBatchMessageCount(seqNum uint64) (arbutil.MessageIndex, error) {
+	return arbutil.MessageIndex(seqNum+1) * 10, nil
+}
+
+func (t *progressInboxTracker) GetBatchAcc(seqNum uint64) (common.Hash, error) {
+	return common.Hash{}, nil
+}
+
+func (t *progressInboxTracker) GetBatchCount() (uint64, error) {
+	return t.batchCount, nil
+}
+
+func TestValidationProgress(t *testing.T) {
+	moduleRoot := common.HexToHash("0x01")
+	v := &BlockValidator{
+		StatelessBlockValidator: &StatelessBlockValidator{
+			inboxTracker:    &progressInboxTracker{batchCount: 5},
+			genesisBlockNum: 2,
+		},
+		currentWasmModuleRoot: moduleRoot,
+	}
+	v.lastBlockValidated = 27
+	v.atomicValidationsRunning = 3
+
+	progress, err := v.validationProgress(40)
+	Require(t, err)
+	if progress.LastValidatedBlock != 27 || progress.HeadBlock != 40 {
+		Fail(t, "unexpected blocks", progress.LastValidatedBlock, progress.HeadBlock)
+	}
+	if progress.BlocksBehind != 13 {
+		Fail(t, "expected 13 blocks behind, got", progress.BlocksBehind)
+	}
+	if progress.JobsInFlight != 3 {
+		Fail(t, "expected 3 jobs in flight, got", progress.JobsInFlight)
+	}
+	// Block 27 is message 25 after the genesis block, which is in the third batch of ten
+	if progress.LastValidatedMessage != 25 || progress.LastValidatedBatch != 2 {
+		Fail(t, "unexpected message or batch", progress.LastValidatedMessage, progress.LastValidatedBatch)
+	}
+	if len(progress.ModuleRoots) != 1 || progress.ModuleRoots[0] != moduleRoot {
+		Fail(t, "unexpected module roots", progress.ModuleRoots)
+	}
+
+	// A validator ahead of its view of the head isn't behind
+	progress, err = v.validationProgress(20)
+	Require(t, err)
+	if progress.BlocksBehind != 0 {
+		Fail(t, "expected no lag when ahead of the head, got", progress.BlocksBehind)
+	}
+
+	// Nothing past genesis validated yet
+	v.lastBlockValidated = 2
+	v.atomicValidationsRunning = 0
+	progress, err = v.validationProgress(40)
+	Require(t, err)
+	if progress.BlocksBehind != 38 || progress.JobsInFlight != 0 {
+		Fail(t, "unexpected lag or jobs at genesis", progress.BlocksBehind, progress.JobsInFlight)
+	}
+	if progress.LastValidatedMessage != 0 || progress.LastValidatedBatch != 0 {
+		Fail(t, "expected no validated message or batch", progress.LastValidatedMessage, progress.LastValidatedBatch)
+	}
+}
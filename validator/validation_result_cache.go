@@ -0,0 +1,95 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// ValidationResultCache remembers the end state of each block executed, so the same execution is never repeated,
+// be it after a restart or by another validator sharing the spawner. A block's execution is determined by the
+// module root, its start state and the messages it reads, so results are keyed by those and never invalidated.
+// Keys start with the batch the block starts in, so results behind the validated position can be pruned.
+type ValidationResultCache struct {
+	db ethdb.KeyValueStore
+}
+
+func NewValidationResultCache(db ethdb.KeyValueStore) *ValidationResultCache {
+	return &ValidationResultCache{db: db}
+}
+
+// validationBatchHash commits to the messages a block's execution reads.
+func validationBatchHash(batches []BatchInfo, hasDelayedMsg bool, delayedMsgNr uint64, delayedMsg []byte) common.Hash {
+	var data [][]byte
+	for _, batch := range batches {
+		data = append(data, arbmath.UintToBytes(batch.Number), crypto.Keccak256(batch.Data))
+	}
+	if hasDelayedMsg {
+		data = append(data, arbmath.UintToBytes(delayedMsgNr), crypto.Keccak256(delayedMsg))
+	}
+	return crypto.Keccak256Hash(data...)
+}
+
+func validationResultBatchPrefix(batch uint64) []byte {
+	key := make([]byte, len(validationResultPrefix)+8)
+	copy(key, validationResultPrefix)
+	binary.BigEndian.PutUint64(key[len(validationResultPrefix):], batch)
+	return key
+}
+
+func validationResultKey(moduleRoot common.Hash, start GoGlobalState, batchHash common.Hash) []byte {
+	key := validationResultBatchPrefix(start.Batch)
+	key = append(key, moduleRoot.Bytes()...)
+	key = append(key, start.Hash().Bytes()...)
+	return append(key, batchHash.Bytes()...)
+}
+
+// Get returns the end state cached for the execution, if any.
+func (c *ValidationResultCache) Get(moduleRoot common.Hash, start GoGlobalState, batchHash common.Hash) (GoGlobalState, bool) {
+	data, err := c.db.Get(validationResultKey(moduleRoot, start, batchHash))
+	if err != nil || len(data) == 0 {
+		return GoGlobalState{}, false
+	}
+	var end GoGlobalState
+	if err := rlp.DecodeBytes(data, &end); err != nil {
+		log.Warn("failed to decode cached validation result", "moduleRoot", moduleRoot, "err", err)
+		return GoGlobalState{}, false
+	}
+	return end, true
+}
+
+func (c *ValidationResultCache) Put(moduleRoot common.Hash, start GoGlobalState, batchHash common.Hash, end GoGlobalState) {
+	data, err := rlp.EncodeToBytes(end)
+	if err == nil {
+		err = c.db.Put(validationResultKey(moduleRoot, start, batchHash), data)
+	}
+	if err != nil {
+		log.Warn("failed to cache validation result", "moduleRoot", moduleRoot, "err", err)
+	}
+}
+
+// DeleteBeforeBatch removes the results of blocks starting before the batch, which won't be executed again.
+func (c *ValidationResultCache) DeleteBeforeBatch(batch uint64) error {
+	iter := c.db.NewIterator(validationResultPrefix, nil)
+	defer iter.Release()
+	end := validationResultBatchPrefix(batch)
+	dbBatch := c.db.NewBatch()
+	for iter.Next() && bytes.Compare(iter.Key(), end) < 0 {
+		if err := dbBatch.Delete(iter.Key()); err != nil {
+			return err
+		}
+	}
+	if iter.Error() != nil {
+		return iter.Error()
+	}
+	return dbBatch.Write()
+}
@@ -0,0 +1,49 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+func TestValidationResultCache(t *testing.T) {
+	cache := NewValidationResultCache(rawdb.NewMemoryDatabase())
+	moduleRoot := common.HexToHash("0x01")
+	start := GoGlobalState{BlockHash: common.HexToHash("0xaa"), Batch: 3}
+	end := GoGlobalState{BlockHash: common.HexToHash("0xbb"), Batch: 3, PosInBatch: 1}
+	batches := []BatchInfo{{Number: 3, Data: []byte("batch")}}
+	batchHash := validationBatchHash(batches, true, 7, []byte("delayed"))
+
+	if _, found := cache.Get(moduleRoot, start, batchHash); found {
+		Fail(t, "found a result before caching it")
+	}
+	cache.Put(moduleRoot, start, batchHash, end)
+	cached, found := cache.Get(moduleRoot, start, batchHash)
+	if !found || cached != end {
+		Fail(t, "cached", cached, "found", found, "expected", end)
+	}
+
+	if _, found := cache.Get(common.HexToHash("0x02"), start, batchHash); found {
+		Fail(t, "found the result under another module root")
+	}
+	if validationBatchHash(batches, true, 7, []byte("other delayed")) == batchHash {
+		Fail(t, "batch hash doesn't commit to the delayed message")
+	}
+	if validationBatchHash(batches, false, 0, nil) == batchHash {
+		Fail(t, "batch hash doesn't commit to reading a delayed message")
+	}
+
+	later := GoGlobalState{BlockHash: common.HexToHash("0xbb"), Batch: 4}
+	cache.Put(moduleRoot, later, batchHash, end)
+	Require(t, cache.DeleteBeforeBatch(4))
+	if _, found := cache.Get(moduleRoot, start, batchHash); found {
+		Fail(t, "result behind the batch not pruned")
+	}
+	if _, found := cache.Get(moduleRoot, later, batchHash); !found {
+		Fail(t, "result of the batch pruned")
+	}
+}
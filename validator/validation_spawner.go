@@ -0,0 +1,206 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ValidationSpawnerVersion is bumped whenever the validation RPC changes incompatibly.
+const ValidationSpawnerVersion = 2
+
+// ValidationSpawnerWitnessPath is where a spawner takes witnesses to validate. They're posted over plain HTTP
+// rather than RPC, as they're often larger than the RPC server will read.
+const ValidationSpawnerWitnessPath = "/validation/witness"
+
+// DefaultMaxWitnessSize is the largest witness a spawner accepts by default.
+const DefaultMaxWitnessSize = 1 << 30
+
+// SpawnerCapabilities is what a validation spawner offers block validators dispatching jobs to it.
+type SpawnerCapabilities struct {
+	Version         hexutil.Uint64 `json:"version"`
+	WasmModuleRoots []common.Hash  `json:"wasmModuleRoots"`
+	Workers         hexutil.Uint64 `json:"workers"`
+	MaxWitnessSize  hexutil.Uint64 `json:"maxWitnessSize"`
+}
+
+// RemoteValidationResult is the end state of a block a validation spawner executed.
+type RemoteValidationResult struct {
+	GlobalState   GoGlobalState  `json:"globalState"`
+	WallTimeMicro hexutil.Uint64 `json:"wallTimeMicroseconds"`
+	Steps         hexutil.Uint64 `json:"steps"`
+	PreimageBytes hexutil.Uint64 `json:"preimageBytes"`
+	Cached        bool           `json:"cached"`
+}
+
+// ValidationSpawner executes blocks for block validators on other machines. Each job carries the block's witness,
+// so the spawner needs nothing but the machines of the module roots it supports.
+type ValidationSpawner struct {
+	loader         *NitroMachineLoader
+	moduleRoots    []common.Hash
+	workers        chan struct{}
+	maxWitnessSize int64
+	resultCache    *ValidationResultCache // nil if results aren't cached
+}
+
+func NewValidationSpawner(loader *NitroMachineLoader, workers int, maxWitnessSize int64) (*ValidationSpawner, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	moduleRoots, err := loader.GetConfig().AvailableModuleRoots()
+	if err != nil {
+		return nil, err
+	}
+	if len(moduleRoots) == 0 {
+		return nil, fmt.Errorf("no machines found in %v", loader.GetConfig().RootPath)
+	}
+	for _, moduleRoot := range moduleRoots {
+		if err := loader.CreateMachine(moduleRoot, true); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("validation spawner ready", "moduleRoots", moduleRoots, "workers", workers)
+	return &ValidationSpawner{
+		loader:         loader,
+		moduleRoots:    moduleRoots,
+		workers:        make(chan struct{}, workers),
+		maxWitnessSize: maxWitnessSize,
+	}, nil
+}
+
+func (s *ValidationSpawner) Capabilities() *SpawnerCapabilities {
+	return &SpawnerCapabilities{
+		Version:         ValidationSpawnerVersion,
+		WasmModuleRoots: s.moduleRoots,
+		Workers:         hexutil.Uint64(cap(s.workers)),
+		MaxWitnessSize:  hexutil.Uint64(s.maxWitnessSize),
+	}
+}
+
+// SetResultCache caches the end state of every block executed, so validators sharing the spawner never have it
+// execute the same block twice.
+func (s *ValidationSpawner) SetResultCache(cache *ValidationResultCache) {
+	s.resultCache = cache
+}
+
+func (s *ValidationSpawner) supports(moduleRoot common.Hash) bool {
+	for _, supported := range s.moduleRoots {
+		if supported == moduleRoot {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate executes the block once one of the workers is free.
+func (s *ValidationSpawner) Validate(ctx context.Context, entry *WitnessArchiveEntry, moduleRoot common.Hash) (GoGlobalState, ValidationCost, error) {
+	if !s.supports(moduleRoot) {
+		return GoGlobalState{}, ValidationCost{}, fmt.Errorf("unsupported wasm module root %v", moduleRoot)
+	}
+	var batchHash common.Hash
+	if s.resultCache != nil {
+		batchHash = validationBatchHash(entry.Batches, entry.HasDelayedMsg, entry.DelayedMsgNr, entry.DelayedMsg)
+		if gsEnd, found := s.resultCache.Get(moduleRoot, entry.start(), batchHash); found {
+			return gsEnd, ValidationCost{Cached: true}, nil
+		}
+	}
+	select {
+	case s.workers <- struct{}{}:
+	case <-ctx.Done():
+		return GoGlobalState{}, ValidationCost{}, ctx.Err()
+	}
+	defer func() { <-s.workers }()
+	log.Info("validating block for remote validator", "blockNr", entry.BlockNumber, "moduleRoot", moduleRoot)
+	gsEnd, cost, err := executeWitness(ctx, s.loader, entry, moduleRoot)
+	if err == nil && s.resultCache != nil {
+		s.resultCache.Put(moduleRoot, entry.start(), batchHash, gsEnd)
+	}
+	return gsEnd, cost, err
+}
+
+// ValidationSpawnerAPI serves a ValidationSpawner under the "validation" RPC namespace.
+type ValidationSpawnerAPI struct {
+	spawner *ValidationSpawner
+}
+
+func NewValidationSpawnerAPI(spawner *ValidationSpawner) *ValidationSpawnerAPI {
+	return &ValidationSpawnerAPI{spawner}
+}
+
+func (a *ValidationSpawnerAPI) Capabilities(ctx context.Context) (*SpawnerCapabilities, error) {
+	return a.spawner.Capabilities(), nil
+}
+
+// WitnessHandler serves ValidationSpawnerWitnessPath, validating the witnesses posted to it.
+func (a *ValidationSpawnerAPI) WitnessHandler() http.Handler {
+	return newWitnessHandler(a.Validate, a.spawner.maxWitnessSize)
+}
+
+// newWitnessHandler takes the RLP encoded witness of a block as the body of a POST, and its module root as
+// the moduleRoot query parameter, and responds with the JSON encoded result.
+func newWitnessHandler(validate func(context.Context, hexutil.Bytes, common.Hash) (*RemoteValidationResult, error), maxWitnessSize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var moduleRoot common.Hash
+		if err := moduleRoot.UnmarshalText([]byte(r.URL.Query().Get("moduleRoot"))); err != nil {
+			http.Error(w, fmt.Sprintf("invalid module root: %v", err), http.StatusBadRequest)
+			return
+		}
+		witness, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWitnessSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("witness larger than %v bytes", maxWitnessSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		result, err := validate(r.Context(), witness, moduleRoot)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Warn("failed to send validation result", "err", err)
+		}
+	})
+}
+
+// Validate takes the RLP encoded witness of the block.
+func (a *ValidationSpawnerAPI) Validate(ctx context.Context, witness hexutil.Bytes, moduleRoot common.Hash) (*RemoteValidationResult, error) {
+	var entry WitnessArchiveEntry
+	if err := rlp.DecodeBytes(witness, &entry); err != nil {
+		return nil, fmt.Errorf("invalid witness: %w", err)
+	}
+	// Results are cached for every validator sharing the spawner, so a bad preimage mustn't be able to poison them
+	if err := entry.verifyPreimages(); err != nil {
+		return nil, fmt.Errorf("invalid witness: %w", err)
+	}
+	gsEnd, cost, err := a.spawner.Validate(ctx, &entry, moduleRoot)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		log.Warn("remote validation failed", "blockNr", entry.BlockNumber, "moduleRoot", moduleRoot, "err", err)
+		return nil, err
+	}
+	return &RemoteValidationResult{
+		GlobalState:   gsEnd,
+		WallTimeMicro: hexutil.Uint64(cost.WallTime.Microseconds()),
+		Steps:         hexutil.Uint64(cost.Steps),
+		PreimageBytes: hexutil.Uint64(cost.PreimageBytes),
+		Cached:        cost.Cached,
+	}, nil
+}
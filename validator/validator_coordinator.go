@@ -0,0 +1,335 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+const VALIDATOR_STAKER_KEY string = "validator.staker"         // Never overwritten. Expires or released only
+const VALIDATOR_CLAIM_KEY_PREFIX string = "validator.claim."   // Per range of blocks. Only written by the claimant. Expires
+const VALIDATOR_RESULT_KEY_PREFIX string = "validator.result." // Per block and module root. Only written by the claimant. Expires
+
+type ValidatorCoordinatorConfig struct {
+	Enable             bool          `koanf:"enable"`
+	RedisUrl           string        `koanf:"redis-url"`
+	MyId               string        `koanf:"my-id"`
+	SigningKey         string        `koanf:"signing-key"`
+	ClaimBlocks        uint64        `koanf:"claim-blocks"`
+	ClaimDuration      time.Duration `koanf:"claim-duration"`
+	ResultDuration     time.Duration `koanf:"result-duration"`
+	PollInterval       time.Duration `koanf:"poll-interval"`
+	StakerLockout      time.Duration `koanf:"staker-lockout"`
+	StakerLockoutSpare time.Duration `koanf:"staker-lockout-spare"`
+	UpdateInterval     time.Duration `koanf:"update-interval"`
+}
+
+func ValidatorCoordinatorConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultValidatorCoordinatorConfig.Enable, "coordinate with other instances of this validator through redis, splitting block validation between them and letting only one stake at a time")
+	f.String(prefix+".redis-url", DefaultValidatorCoordinatorConfig.RedisUrl, "url of the redis server the instances share")
+	f.String(prefix+".my-id", DefaultValidatorCoordinatorConfig.MyId, "id of this instance, unique among those coordinating")
+	f.String(prefix+".signing-key", DefaultValidatorCoordinatorConfig.SigningKey, "a 32-byte (64-character) hex string shared by the instances, used to sign the validation results they publish")
+	f.Uint64(prefix+".claim-blocks", DefaultValidatorCoordinatorConfig.ClaimBlocks, "number of consecutive blocks an instance claims to validate at once")
+	f.Duration(prefix+".claim-duration", DefaultValidatorCoordinatorConfig.ClaimDuration, "how long a claim lasts without progress, after which another instance takes over its blocks")
+	f.Duration(prefix+".result-duration", DefaultValidatorCoordinatorConfig.ResultDuration, "how long published validation results are kept")
+	f.Duration(prefix+".poll-interval", DefaultValidatorCoordinatorConfig.PollInterval, "how often to check for the result of a block another instance claimed")
+	f.Duration(prefix+".staker-lockout", DefaultValidatorCoordinatorConfig.StakerLockout, "how long the staker lock lasts without being renewed, after which another instance takes over staking")
+	f.Duration(prefix+".staker-lockout-spare", DefaultValidatorCoordinatorConfig.StakerLockoutSpare, "how long before the staker lock expires the holder stops acting on it")
+	f.Duration(prefix+".update-interval", DefaultValidatorCoordinatorConfig.UpdateInterval, "how often to take or renew the staker lock")
+}
+
+var DefaultValidatorCoordinatorConfig = ValidatorCoordinatorConfig{
+	Enable:             false,
+	RedisUrl:           "",
+	MyId:               "",
+	SigningKey:         "",
+	ClaimBlocks:        8,
+	ClaimDuration:      10 * time.Minute,
+	ResultDuration:     24 * time.Hour,
+	PollInterval:       time.Second,
+	StakerLockout:      time.Minute,
+	StakerLockoutSpare: 15 * time.Second,
+	UpdateInterval:     5 * time.Second,
+}
+
+var TestValidatorCoordinatorConfig = ValidatorCoordinatorConfig{
+	Enable:             true,
+	RedisUrl:           "redis://localhost:6379/0",
+	MyId:               "test",
+	SigningKey:         "b561f5d5d98debc783aa8a1472d67ec3bcd532a1c8d95e5cb23caa70c649f7c9",
+	ClaimBlocks:        2,
+	ClaimDuration:      time.Second,
+	ResultDuration:     time.Minute,
+	PollInterval:       time.Millisecond * 10,
+	StakerLockout:      time.Second,
+	StakerLockoutSpare: time.Millisecond * 100,
+	UpdateInterval:     time.Millisecond * 50,
+}
+
+var (
+	coordinatorClaimedBlocksCounter = metrics.NewRegisteredCounter("arb/validator/coordinator/claimed", nil)
+	coordinatorPeerBlocksCounter    = metrics.NewRegisteredCounter("arb/validator/coordinator/peer", nil)
+	coordinatorTakeoversCounter     = metrics.NewRegisteredCounter("arb/validator/coordinator/takeovers", nil)
+	coordinatorStakerGauge          = metrics.NewRegisteredGauge("arb/validator/coordinator/staker", nil)
+)
+
+// ValidatorCoordinator lets redundant instances of a validator share the work of validating blocks through redis,
+// each claiming ranges of blocks to validate and publishing their results for the others, and taking over the
+// blocks of an instance whose claim lapses. Only the instance holding the staker lock may act on L1, so the
+// instances, which share a wallet, never stake or move the stake concurrently.
+type ValidatorCoordinator struct {
+	stopwaiter.StopWaiter
+
+	client     redis.UniversalClient
+	config     ValidatorCoordinatorConfig
+	signingKey common.Hash
+
+	stakerUntil int64 // atomic, unix milliseconds until which this instance may act as the staker
+	redisErrors int   // only accessed from the update thread
+}
+
+func NewValidatorCoordinator(config *ValidatorCoordinatorConfig) (*ValidatorCoordinator, error) {
+	redisOptions, err := redis.ParseURL(config.RedisUrl)
+	if err != nil {
+		return nil, err
+	}
+	return newValidatorCoordinator(redis.NewClient(redisOptions), config)
+}
+
+func newValidatorCoordinator(client redis.UniversalClient, config *ValidatorCoordinatorConfig) (*ValidatorCoordinator, error) {
+	if config.MyId == "" {
+		return nil, errors.New("validator coordinator requires an id for this instance")
+	}
+	if config.ClaimBlocks == 0 {
+		return nil, errors.New("validator coordinator claim-blocks must be positive")
+	}
+	if config.StakerLockoutSpare >= config.StakerLockout {
+		return nil, errors.New("validator coordinator staker-lockout-spare must be less than staker-lockout")
+	}
+	signingKey := common.HexToHash(config.SigningKey)
+	if signingKey == (common.Hash{}) {
+		return nil, errors.New("validator coordinator requires a signing key")
+	}
+	return &ValidatorCoordinator{
+		client:     client,
+		config:     *config,
+		signingKey: signingKey,
+	}, nil
+}
+
+func validatorClaimKeyFor(claim uint64) string {
+	return fmt.Sprintf("%s%d", VALIDATOR_CLAIM_KEY_PREFIX, claim)
+}
+
+func validatorResultKeyFor(moduleRoot common.Hash, blockNumber uint64) string {
+	return fmt.Sprintf("%s%v.%d", VALIDATOR_RESULT_KEY_PREFIX, moduleRoot, blockNumber)
+}
+
+func (c *ValidatorCoordinator) signMessage(key string, msg []byte) []byte {
+	mac := hmac.New(sha3.NewLegacyKeccak256, c.signingKey[:])
+	mac.Write([]byte(key))
+	mac.Write(msg)
+	return append(mac.Sum(nil), msg...)
+}
+
+func (c *ValidatorCoordinator) verifyMessageSignature(key string, data []byte) ([]byte, error) {
+	if len(data) < 32 {
+		return nil, errors.New("data is too short to contain message signature")
+	}
+	msg := data[32:]
+	mac := hmac.New(sha3.NewLegacyKeccak256, c.signingKey[:])
+	mac.Write([]byte(key))
+	mac.Write(msg)
+	if !hmac.Equal(data[:32], mac.Sum(nil)) {
+		return nil, errors.New("HMAC signature doesn't match expected value")
+	}
+	return msg, nil
+}
+
+// claim returns whether this instance is to validate the block, claiming the range of blocks it's in unless another
+// instance holds the claim already. Claims are renewed with every block validated under them.
+func (c *ValidatorCoordinator) claim(ctx context.Context, blockNumber uint64) (bool, error) {
+	key := validatorClaimKeyFor(blockNumber / c.config.ClaimBlocks)
+	claimed, err := c.client.SetNX(ctx, key, c.config.MyId, c.config.ClaimDuration).Result()
+	if err != nil {
+		return false, err
+	}
+	if claimed {
+		log.Debug("claimed blocks to validate", "from", blockNumber-blockNumber%c.config.ClaimBlocks, "count", c.config.ClaimBlocks)
+		return true, nil
+	}
+	current, err := c.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		// The claim lapsed in between, it's up for grabs again
+		return c.claim(ctx, blockNumber)
+	}
+	if err != nil || current != c.config.MyId {
+		return false, err
+	}
+	return true, c.client.PExpire(ctx, key, c.config.ClaimDuration).Err()
+}
+
+// claimant returns the instance holding the claim on the block, or "" if none does.
+func (c *ValidatorCoordinator) claimant(ctx context.Context, blockNumber uint64) (string, error) {
+	current, err := c.client.Get(ctx, validatorClaimKeyFor(blockNumber/c.config.ClaimBlocks)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return current, err
+}
+
+type validatorResult struct {
+	Start GoGlobalState
+	End   GoGlobalState
+}
+
+func (c *ValidatorCoordinator) publishResult(ctx context.Context, moduleRoot common.Hash, blockNumber uint64, start GoGlobalState, end GoGlobalState) error {
+	data, err := rlp.EncodeToBytes(&validatorResult{start, end})
+	if err != nil {
+		return err
+	}
+	key := validatorResultKeyFor(moduleRoot, blockNumber)
+	return c.client.Set(ctx, key, c.signMessage(key, data), c.config.ResultDuration).Err()
+}
+
+// result returns the end state another instance published executing the block from start, if any.
+func (c *ValidatorCoordinator) result(ctx context.Context, moduleRoot common.Hash, blockNumber uint64, start GoGlobalState) (GoGlobalState, bool, error) {
+	key := validatorResultKeyFor(moduleRoot, blockNumber)
+	data, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return GoGlobalState{}, false, nil
+	}
+	if err != nil {
+		return GoGlobalState{}, false, err
+	}
+	data, err = c.verifyMessageSignature(key, data)
+	if err != nil {
+		return GoGlobalState{}, false, err
+	}
+	var result validatorResult
+	if err := rlp.DecodeBytes(data, &result); err != nil {
+		return GoGlobalState{}, false, err
+	}
+	if result.Start != start {
+		// Published for a different chain of blocks, e.g. before a reorg
+		return GoGlobalState{}, false, nil
+	}
+	return result.End, true, nil
+}
+
+// IsStaker returns whether this instance holds the staker lock, with enough of it left to act on L1.
+func (c *ValidatorCoordinator) IsStaker() bool {
+	return time.Now().UnixMilli() < atomic.LoadInt64(&c.stakerUntil)
+}
+
+// StakerId returns the instance currently holding the staker lock, or "" if none does.
+func (c *ValidatorCoordinator) StakerId(ctx context.Context) (string, error) {
+	current, err := c.client.Get(ctx, VALIDATOR_STAKER_KEY).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return current, err
+}
+
+// stakerLockUpdate takes the staker lock if no instance holds it, or renews it if this one does.
+func (c *ValidatorCoordinator) stakerLockUpdate(ctx context.Context) error {
+	lockoutUntil := time.Now().Add(c.config.StakerLockout)
+	err := c.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, VALIDATOR_STAKER_KEY).Result()
+		if errors.Is(err, redis.Nil) {
+			current = ""
+		} else if err != nil {
+			return err
+		}
+		if current != "" && current != c.config.MyId {
+			atomic.StoreInt64(&c.stakerUntil, 0)
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, VALIDATOR_STAKER_KEY, c.config.MyId, c.config.StakerLockout)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			log.Info("took over the staker lock", "id", c.config.MyId)
+			coordinatorTakeoversCounter.Inc(1)
+		}
+		atomic.StoreInt64(&c.stakerUntil, lockoutUntil.Add(-c.config.StakerLockoutSpare).UnixMilli())
+		return nil
+	}, VALIDATOR_STAKER_KEY)
+	if err != nil {
+		// Without knowing whether the lock was renewed, stop acting on it
+		atomic.StoreInt64(&c.stakerUntil, 0)
+	}
+	return err
+}
+
+func (c *ValidatorCoordinator) stakerLockRelease(ctx context.Context) error {
+	atomic.StoreInt64(&c.stakerUntil, 0)
+	return c.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, VALIDATOR_STAKER_KEY).Result()
+		if errors.Is(err, redis.Nil) || (err == nil && current != c.config.MyId) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, VALIDATOR_STAKER_KEY)
+			return nil
+		})
+		return err
+	}, VALIDATOR_STAKER_KEY)
+}
+
+func (c *ValidatorCoordinator) update(ctx context.Context) time.Duration {
+	err := c.stakerLockUpdate(ctx)
+	if c.IsStaker() {
+		coordinatorStakerGauge.Update(1)
+	} else {
+		coordinatorStakerGauge.Update(0)
+	}
+	if err != nil {
+		log.Warn("validator coordinator failed to update redis", "err", err)
+		c.redisErrors++
+		retryIn := c.config.PollInterval * time.Duration(c.redisErrors)
+		if retryIn > c.config.UpdateInterval {
+			retryIn = c.config.UpdateInterval
+		}
+		return retryIn
+	}
+	c.redisErrors = 0
+	return c.config.UpdateInterval
+}
+
+func (c *ValidatorCoordinator) Start(ctxIn context.Context) {
+	c.StopWaiter.Start(ctxIn)
+	c.CallIteratively(c.update)
+}
+
+func (c *ValidatorCoordinator) StopAndWait() {
+	c.StopWaiter.StopAndWait()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.stakerLockRelease(ctx); err != nil {
+		log.Warn("validator coordinator failed to release the staker lock", "err", err)
+	}
+}
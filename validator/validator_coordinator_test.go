@@ -0,0 +1,114 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newTestValidatorCoordinator(t *testing.T, server *miniredis.Miniredis, id string) *ValidatorCoordinator {
+	config := TestValidatorCoordinatorConfig
+	config.RedisUrl = "redis://" + server.Addr()
+	config.MyId = id
+	coordinator, err := NewValidatorCoordinator(&config)
+	Require(t, err)
+	return coordinator
+}
+
+func TestValidatorCoordinatorClaims(t *testing.T) {
+	ctx := context.Background()
+	server, err := miniredis.Run()
+	Require(t, err)
+	defer server.Close()
+	first := newTestValidatorCoordinator(t, server, "first")
+	second := newTestValidatorCoordinator(t, server, "second")
+
+	claimed, err := first.claim(ctx, 4)
+	Require(t, err)
+	if !claimed {
+		Fail(t, "first instance didn't claim a free range")
+	}
+	claimed, err = second.claim(ctx, 5)
+	Require(t, err)
+	if claimed {
+		Fail(t, "second instance claimed a block in the first's range")
+	}
+	claimed, err = second.claim(ctx, 6)
+	Require(t, err)
+	if !claimed {
+		Fail(t, "second instance didn't claim the next range")
+	}
+
+	moduleRoot := common.HexToHash("0x01")
+	start := GoGlobalState{Batch: 1, PosInBatch: 4}
+	end := GoGlobalState{Batch: 1, PosInBatch: 5, BlockHash: common.HexToHash("0x02")}
+	Require(t, first.publishResult(ctx, moduleRoot, 5, start, end))
+	result, found, err := second.result(ctx, moduleRoot, 5, start)
+	Require(t, err)
+	if !found || result != end {
+		Fail(t, "published result not found", found, result)
+	}
+	if _, found, _ := second.result(ctx, moduleRoot, 5, GoGlobalState{Batch: 1, PosInBatch: 3}); found {
+		Fail(t, "result used for a different start state")
+	}
+	if _, found, _ := second.result(ctx, common.HexToHash("0x03"), 5, start); found {
+		Fail(t, "result used for a different module root")
+	}
+
+	forger := newTestValidatorCoordinator(t, server, "forger")
+	forger.signingKey = common.HexToHash("0x04")
+	Require(t, forger.publishResult(ctx, moduleRoot, 5, start, GoGlobalState{}))
+	if _, _, err := second.result(ctx, moduleRoot, 5, start); err == nil {
+		Fail(t, "result with an invalid signature accepted")
+	}
+
+	server.FastForward(TestValidatorCoordinatorConfig.ClaimDuration + time.Millisecond)
+	claimed, err = second.claim(ctx, 5)
+	Require(t, err)
+	if !claimed {
+		Fail(t, "second instance didn't take over a lapsed claim")
+	}
+}
+
+func TestValidatorCoordinatorStakerLock(t *testing.T) {
+	ctx := context.Background()
+	server, err := miniredis.Run()
+	Require(t, err)
+	defer server.Close()
+	first := newTestValidatorCoordinator(t, server, "first")
+	second := newTestValidatorCoordinator(t, server, "second")
+
+	Require(t, first.stakerLockUpdate(ctx))
+	Require(t, second.stakerLockUpdate(ctx))
+	if !first.IsStaker() || second.IsStaker() {
+		Fail(t, "expected only the first instance to stake", first.IsStaker(), second.IsStaker())
+	}
+	staker, err := second.StakerId(ctx)
+	Require(t, err)
+	if staker != "first" {
+		Fail(t, "unexpected staker", staker)
+	}
+
+	// The first instance stops renewing the lock, so the second takes over once it lapses
+	server.FastForward(TestValidatorCoordinatorConfig.StakerLockout + time.Millisecond)
+	Require(t, second.stakerLockUpdate(ctx))
+	Require(t, first.stakerLockUpdate(ctx))
+	if first.IsStaker() || !second.IsStaker() {
+		Fail(t, "expected the second instance to take over staking", first.IsStaker(), second.IsStaker())
+	}
+
+	Require(t, second.stakerLockRelease(ctx))
+	if second.IsStaker() {
+		Fail(t, "still staking after releasing the lock")
+	}
+	Require(t, first.stakerLockUpdate(ctx))
+	if !first.IsStaker() {
+		Fail(t, "released lock wasn't taken")
+	}
+}
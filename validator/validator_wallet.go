@@ -83,12 +83,12 @@ func (v *ValidatorWallet) RollupAddress() common.Address {
 	return v.rollupAddress
 }
 
-func (v *ValidatorWallet) executeTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
-	oldAuthValue := v.auth.Value
-	v.auth.Value = tx.Value()
-	defer (func() { v.auth.Value = oldAuthValue })()
+func (v *ValidatorWallet) executeTransaction(ctx context.Context, auth *bind.TransactOpts, tx *types.Transaction) (*types.Transaction, error) {
+	oldAuthValue := auth.Value
+	auth.Value = tx.Value()
+	defer (func() { auth.Value = oldAuthValue })()
 
-	return v.con.ExecuteTransaction(v.auth, tx.Data(), *tx.To(), tx.Value())
+	return v.con.ExecuteTransaction(auth, tx.Data(), *tx.To(), tx.Value())
 }
 
 func (v *ValidatorWallet) createWalletIfNeeded(ctx context.Context) error {
@@ -130,6 +130,11 @@ func combineTxes(txes []*types.Transaction) ([][]byte, []common.Address, []*big.
 
 // Not thread safe! Don't call this from multiple threads at the same time.
 func (v *ValidatorWallet) ExecuteTransactions(ctx context.Context, builder *ValidatorTxBuilder) (*types.Transaction, error) {
+	return v.executeTransactionsAs(ctx, builder, v.auth)
+}
+
+// executeTransactionsAs sends the built transactions from auth, which must be the wallet's owner or one of its executors.
+func (v *ValidatorWallet) executeTransactionsAs(ctx context.Context, builder *ValidatorTxBuilder, auth *bind.TransactOpts) (*types.Transaction, error) {
 	txes := builder.transactions
 	if len(txes) == 0 {
 		return nil, nil
@@ -141,7 +146,7 @@ func (v *ValidatorWallet) ExecuteTransactions(ctx context.Context, builder *Vali
 	}
 
 	if len(txes) == 1 {
-		arbTx, err := v.executeTransaction(ctx, txes[0])
+		arbTx, err := v.executeTransaction(ctx, auth, txes[0])
 		if err != nil {
 			return nil, err
 		}
@@ -166,14 +171,14 @@ func (v *ValidatorWallet) ExecuteTransactions(ctx context.Context, builder *Vali
 		return nil, err
 	}
 
-	oldAuthValue := v.auth.Value
-	v.auth.Value = new(big.Int).Sub(totalAmount, balanceInContract)
-	if v.auth.Value.Sign() < 0 {
-		v.auth.Value.SetInt64(0)
+	oldAuthValue := auth.Value
+	auth.Value = new(big.Int).Sub(totalAmount, balanceInContract)
+	if auth.Value.Sign() < 0 {
+		auth.Value.SetInt64(0)
 	}
-	defer (func() { v.auth.Value = oldAuthValue })()
+	defer (func() { auth.Value = oldAuthValue })()
 
-	arbTx, err := v.con.ExecuteTransactions(v.auth, data, dest, amount)
+	arbTx, err := v.con.ExecuteTransactions(auth, data, dest, amount)
 	if err != nil {
 		return nil, err
 	}
@@ -185,6 +190,62 @@ func (v *ValidatorWallet) TimeoutChallenges(ctx context.Context, manager common.
 	return v.con.TimeoutChallenges(v.auth, manager, challenges)
 }
 
+// authorizeExecutors makes each executor able to call the given destinations through the wallet, sending the
+// owner transactions needed, and returns the last one sent (nil if it was already set up). Only the owner can
+// withdraw funds from the wallet, so executors can't move the stake out of it.
+func (v *ValidatorWallet) authorizeExecutors(ctx context.Context, executors []common.Address, dests []common.Address) (*types.Transaction, error) {
+	if err := v.createWalletIfNeeded(ctx); err != nil {
+		return nil, err
+	}
+	callOpts := &bind.CallOpts{Context: ctx}
+	var newExecutors []common.Address
+	for _, executor := range executors {
+		isExecutor, err := v.con.Executors(callOpts, executor)
+		if err != nil {
+			return nil, err
+		}
+		if !isExecutor {
+			newExecutors = append(newExecutors, executor)
+		}
+	}
+	var newDests []common.Address
+	for _, dest := range dests {
+		allowed, err := v.con.AllowedExecutorDestinations(callOpts, dest)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			newDests = append(newDests, dest)
+		}
+	}
+	var lastTx *types.Transaction
+	if len(newExecutors) > 0 {
+		log.Info("adding validator wallet executors", "executors", newExecutors)
+		isSet := make([]bool, len(newExecutors))
+		for i := range isSet {
+			isSet[i] = true
+		}
+		tx, err := v.con.SetExecutor(v.auth, newExecutors, isSet)
+		if err != nil {
+			return nil, err
+		}
+		lastTx = tx
+	}
+	if len(newDests) > 0 {
+		log.Info("allowing validator wallet executor destinations", "destinations", newDests)
+		isSet := make([]bool, len(newDests))
+		for i := range isSet {
+			isSet[i] = true
+		}
+		tx, err := v.con.SetAllowedExecutorDestinations(v.auth, newDests, isSet)
+		if err != nil {
+			return nil, err
+		}
+		lastTx = tx
+	}
+	return lastTx, nil
+}
+
 func CreateValidatorWallet(
 	ctx context.Context,
 	validatorWalletFactoryAddr common.Address,
@@ -0,0 +1,310 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+type PagerDutyAlertConfig struct {
+	RoutingKey string `koanf:"routing-key"`
+	URL        string `koanf:"url"`
+}
+
+type SlackAlertConfig struct {
+	WebhookURL string `koanf:"webhook-url"`
+}
+
+type SMTPAlertConfig struct {
+	Host     string   `koanf:"host"`
+	Port     uint64   `koanf:"port"`
+	Username string   `koanf:"username"`
+	Password string   `koanf:"password"`
+	From     string   `koanf:"from"`
+	To       []string `koanf:"to"`
+}
+
+type WebhookAlertConfig struct {
+	URL        string `koanf:"url"`
+	HMACSecret string `koanf:"hmac-secret"`
+}
+
+type WatchtowerAlertsConfig struct {
+	PagerDuty PagerDutyAlertConfig `koanf:"pagerduty"`
+	Slack     SlackAlertConfig     `koanf:"slack"`
+	SMTP      SMTPAlertConfig      `koanf:"smtp"`
+	Webhook   WebhookAlertConfig   `koanf:"webhook"`
+	Timeout   time.Duration        `koanf:"timeout"`
+}
+
+var DefaultWatchtowerAlertsConfig = WatchtowerAlertsConfig{
+	PagerDuty: PagerDutyAlertConfig{URL: "https://events.pagerduty.com/v2/enqueue"},
+	SMTP:      SMTPAlertConfig{Port: 587, To: []string{}},
+	Timeout:   10 * time.Second,
+}
+
+func WatchtowerAlertsConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".pagerduty.routing-key", DefaultWatchtowerAlertsConfig.PagerDuty.RoutingKey, "PagerDuty Events API v2 routing key to trigger incidents with (empty to not use PagerDuty)")
+	f.String(prefix+".pagerduty.url", DefaultWatchtowerAlertsConfig.PagerDuty.URL, "PagerDuty Events API v2 URL")
+	f.String(prefix+".slack.webhook-url", DefaultWatchtowerAlertsConfig.Slack.WebhookURL, "Slack incoming webhook URL to post alerts to (empty to not use Slack)")
+	f.String(prefix+".smtp.host", DefaultWatchtowerAlertsConfig.SMTP.Host, "SMTP server to email alerts through (empty to not email them)")
+	f.Uint64(prefix+".smtp.port", DefaultWatchtowerAlertsConfig.SMTP.Port, "SMTP server port")
+	f.String(prefix+".smtp.username", DefaultWatchtowerAlertsConfig.SMTP.Username, "SMTP username (empty to not authenticate)")
+	f.String(prefix+".smtp.password", DefaultWatchtowerAlertsConfig.SMTP.Password, "SMTP password")
+	f.String(prefix+".smtp.from", DefaultWatchtowerAlertsConfig.SMTP.From, "address to email alerts from")
+	f.StringSlice(prefix+".smtp.to", DefaultWatchtowerAlertsConfig.SMTP.To, "addresses to email alerts to")
+	f.String(prefix+".webhook.url", DefaultWatchtowerAlertsConfig.Webhook.URL, "URL to POST alerts to as JSON (empty to not use a webhook)")
+	f.String(prefix+".webhook.hmac-secret", DefaultWatchtowerAlertsConfig.Webhook.HMACSecret, "secret to sign webhook alerts with, the hex HMAC-SHA256 of the body being sent in the X-Nitro-Signature header (empty to not sign)")
+	f.Duration(prefix+".timeout", DefaultWatchtowerAlertsConfig.Timeout, "timeout of sending an alert to each sink")
+}
+
+// InvalidAssertionAlert describes an assertion the validator found incorrect, and what it computed instead.
+type InvalidAssertionAlert struct {
+	Rollup               common.Address `json:"rollup"`
+	Node                 uint64         `json:"node"`
+	NodeHash             common.Hash    `json:"nodeHash"`
+	ProposedAtL1Block    uint64         `json:"proposedAtL1Block"`
+	NumBlocks            uint64         `json:"numBlocks"`
+	BlockHash            common.Hash    `json:"blockHash"`
+	SendRoot             common.Hash    `json:"sendRoot"`
+	Batch                uint64         `json:"batch"`
+	PosInBatch           uint64         `json:"posInBatch"`
+	InboxPositionInvalid bool           `json:"inboxPositionInvalid"`
+	ExpectedBlockNumber  int64          `json:"expectedBlockNumber"`
+	ExpectedNumBlocks    uint64         `json:"expectedNumBlocks"`
+	ExpectedBlockHash    common.Hash    `json:"expectedBlockHash"`
+	ExpectedSendRoot     common.Hash    `json:"expectedSendRoot"`
+	TrustedNode          string         `json:"trustedNode,omitempty"` // reporting the expected state, if not computed locally
+}
+
+func (a *InvalidAssertionAlert) summary() string {
+	return fmt.Sprintf("Invalid assertion %v on rollup %v: block hash %v, expected %v", a.Node, a.Rollup, a.BlockHash, a.ExpectedBlockHash)
+}
+
+func (a *InvalidAssertionAlert) source() string { return a.Rollup.String() }
+
+func (a *InvalidAssertionAlert) dedupKey() string {
+	return fmt.Sprintf("nitro-invalid-assertion-%v-%v", a.Rollup, a.Node)
+}
+
+// InboxAccumulatorAlert describes a sequencer batch whose accumulator differs from the one on L1.
+type InboxAccumulatorAlert struct {
+	SequencerInbox   common.Address `json:"sequencerInbox"`
+	Batch            uint64         `json:"batch"`
+	LocalAccumulator common.Hash    `json:"localAccumulator"`
+	L1Accumulator    common.Hash    `json:"l1Accumulator"`
+	L1Block          uint64         `json:"l1Block"`
+}
+
+func (a *InboxAccumulatorAlert) summary() string {
+	return fmt.Sprintf("Inbox accumulator mismatch at batch %v of sequencer inbox %v: have %v, L1 has %v", a.Batch, a.SequencerInbox, a.LocalAccumulator, a.L1Accumulator)
+}
+
+func (a *InboxAccumulatorAlert) source() string { return a.SequencerInbox.String() }
+
+func (a *InboxAccumulatorAlert) dedupKey() string {
+	return fmt.Sprintf("nitro-inbox-accumulator-%v-%v", a.SequencerInbox, a.Batch)
+}
+
+// WatchtowerAlert is something wrong the watchtower found, sent as JSON along with its summary.
+type WatchtowerAlert interface {
+	summary() string
+	source() string
+	dedupKey() string
+}
+
+type alertSink interface {
+	name() string
+	send(ctx context.Context, alert WatchtowerAlert) error
+}
+
+// WatchtowerAlerter pushes invalid assertion alerts to every configured sink.
+type WatchtowerAlerter struct {
+	config WatchtowerAlertsConfig
+	sinks  []alertSink
+}
+
+func NewWatchtowerAlerter(config *WatchtowerAlertsConfig) *WatchtowerAlerter {
+	client := &http.Client{Timeout: config.Timeout}
+	var sinks []alertSink
+	if config.PagerDuty.RoutingKey != "" {
+		sinks = append(sinks, &pagerDutySink{config.PagerDuty, client})
+	}
+	if config.Slack.WebhookURL != "" {
+		sinks = append(sinks, &slackSink{config.Slack, client})
+	}
+	if config.SMTP.Host != "" {
+		sinks = append(sinks, &smtpSink{config.SMTP})
+	}
+	if config.Webhook.URL != "" {
+		sinks = append(sinks, &webhookSink{config.Webhook, client})
+	}
+	return &WatchtowerAlerter{config: *config, sinks: sinks}
+}
+
+// Enabled returns whether any sink is configured.
+func (a *WatchtowerAlerter) Enabled() bool {
+	return len(a.sinks) > 0
+}
+
+// Alert sends the alert to every sink, logging those it fails to reach.
+func (a *WatchtowerAlerter) Alert(ctx context.Context, alert WatchtowerAlert) {
+	for _, sink := range a.sinks {
+		sinkCtx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+		err := sink.send(sinkCtx, alert)
+		cancel()
+		if err != nil {
+			log.Error("failed to send watchtower alert", "sink", sink.name(), "alert", alert.dedupKey(), "err", err)
+		} else {
+			log.Info("sent watchtower alert", "sink", sink.name(), "alert", alert.dedupKey())
+		}
+	}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("returned status %v: %v", response.StatusCode, string(responseBody))
+	}
+	return nil
+}
+
+type pagerDutySink struct {
+	config PagerDutyAlertConfig
+	client *http.Client
+}
+
+func (s *pagerDutySink) name() string { return "pagerduty" }
+
+func (s *pagerDutySink) send(ctx context.Context, alert WatchtowerAlert) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"routing_key":  s.config.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.dedupKey(),
+		"payload": map[string]interface{}{
+			"summary":        alert.summary(),
+			"source":         alert.source(),
+			"severity":       "critical",
+			"custom_details": alert,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.config.URL, body, nil)
+}
+
+type slackSink struct {
+	config SlackAlertConfig
+	client *http.Client
+}
+
+func (s *slackSink) name() string { return "slack" }
+
+func (s *slackSink) send(ctx context.Context, alert WatchtowerAlert) error {
+	details, err := json.MarshalIndent(alert, "", "  ")
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{
+		"text": ":rotating_light: " + alert.summary() + "\n```" + string(details) + "```",
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.config.WebhookURL, body, nil)
+}
+
+type smtpSink struct {
+	config SMTPAlertConfig
+}
+
+func (s *smtpSink) name() string { return "smtp" }
+
+func (s *smtpSink) send(ctx context.Context, alert WatchtowerAlert) error {
+	if len(s.config.To) == 0 {
+		return fmt.Errorf("no recipients configured")
+	}
+	details, err := json.MarshalIndent(alert, "", "  ")
+	if err != nil {
+		return err
+	}
+	message := "From: " + s.config.From + "\r\n" +
+		"To: " + strings.Join(s.config.To, ", ") + "\r\n" +
+		"Subject: " + alert.summary() + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + string(details) + "\r\n"
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+	addr := net.JoinHostPort(s.config.Host, strconv.FormatUint(s.config.Port, 10))
+	// net/smtp doesn't take a context, so give up waiting on it once the context is done
+	result := make(chan error, 1)
+	go func() {
+		result <- smtp.SendMail(addr, auth, s.config.From, s.config.To, []byte(message))
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type webhookSink struct {
+	config WebhookAlertConfig
+	client *http.Client
+}
+
+func (s *webhookSink) name() string { return "webhook" }
+
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookSink) send(ctx context.Context, alert WatchtowerAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	var headers map[string]string
+	if s.config.HMACSecret != "" {
+		headers = map[string]string{"X-Nitro-Signature": webhookSignature(s.config.HMACSecret, body)}
+	}
+	return postJSON(ctx, s.client, s.config.URL, body, headers)
+}
@@ -0,0 +1,75 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWatchtowerAlerts(t *testing.T) {
+	var mutex sync.Mutex
+	received := make(map[string][]byte)
+	signatures := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		Require(t, err)
+		mutex.Lock()
+		received[r.URL.Path] = body
+		signatures[r.URL.Path] = r.Header.Get("X-Nitro-Signature")
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	config := DefaultWatchtowerAlertsConfig
+	config.PagerDuty.RoutingKey = "routing"
+	config.PagerDuty.URL = server.URL + "/pagerduty"
+	config.Slack.WebhookURL = server.URL + "/slack"
+	config.Webhook.URL = server.URL + "/webhook"
+	config.Webhook.HMACSecret = "secret"
+	alerter := NewWatchtowerAlerter(&config)
+	if !alerter.Enabled() || len(alerter.sinks) != 3 {
+		Fail(t, "expected 3 sinks, got", len(alerter.sinks))
+	}
+
+	alert := &InvalidAssertionAlert{
+		Node:              7,
+		BlockHash:         common.HexToHash("0xbad"),
+		ExpectedBlockHash: common.HexToHash("0x600d"),
+	}
+	alerter.Alert(context.Background(), alert)
+
+	var webhookAlert InvalidAssertionAlert
+	Require(t, json.Unmarshal(received["/webhook"], &webhookAlert))
+	if webhookAlert != *alert {
+		Fail(t, "webhook got", webhookAlert, "expected", *alert)
+	}
+	if signatures["/webhook"] != webhookSignature("secret", received["/webhook"]) {
+		Fail(t, "webhook alert not signed")
+	}
+
+	var pagerDutyEvent struct {
+		RoutingKey  string `json:"routing_key"`
+		EventAction string `json:"event_action"`
+		Payload     struct {
+			CustomDetails InvalidAssertionAlert `json:"custom_details"`
+		} `json:"payload"`
+	}
+	Require(t, json.Unmarshal(received["/pagerduty"], &pagerDutyEvent))
+	if pagerDutyEvent.RoutingKey != "routing" || pagerDutyEvent.EventAction != "trigger" || pagerDutyEvent.Payload.CustomDetails != *alert {
+		Fail(t, "unexpected PagerDuty event", string(received["/pagerduty"]))
+	}
+
+	if !strings.Contains(string(received["/slack"]), alert.ExpectedBlockHash.Hex()) {
+		Fail(t, "slack message missing the computed state", string(received["/slack"]))
+	}
+}
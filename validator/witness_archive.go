@@ -0,0 +1,247 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+type WitnessPreimage struct {
+	Hash common.Hash
+	Data []byte
+	Type arbutil.PreimageType `rlp:"optional"` // keccak256 for entries archived before preimages were typed
+}
+
+// WitnessArchiveEntry holds everything needed to re-execute a validated block in the
+// replay binary without access to the L1, the DAS, or the node's state.
+type WitnessArchiveEntry struct {
+	BlockNumber   uint64
+	BlockHash     common.Hash
+	PrevBlockHash common.Hash
+	SendRoot      common.Hash
+	PrevSendRoot  common.Hash
+	StartPosition GlobalStatePosition
+	EndPosition   GlobalStatePosition
+	Batches       []BatchInfo
+	HasDelayedMsg bool
+	DelayedMsgNr  uint64
+	DelayedMsg    []byte
+	Preimages     []WitnessPreimage // sorted by hash
+}
+
+// PreimageMap returns the keccak256 preimages, which are those the machine reads.
+func (e *WitnessArchiveEntry) PreimageMap() map[common.Hash][]byte {
+	return e.TypedPreimageMap()[arbutil.Keccak256PreimageType]
+}
+
+func (e *WitnessArchiveEntry) TypedPreimageMap() map[arbutil.PreimageType]map[common.Hash][]byte {
+	preimages := make(map[arbutil.PreimageType]map[common.Hash][]byte)
+	for _, preimage := range e.Preimages {
+		if preimages[preimage.Type] == nil {
+			preimages[preimage.Type] = make(map[common.Hash][]byte)
+		}
+		preimages[preimage.Type][preimage.Hash] = preimage.Data
+	}
+	return preimages
+}
+
+// PreimageProviders returns providers of the witness's preimages, one for each type.
+func (e *WitnessArchiveEntry) PreimageProviders() []PreimageProvider {
+	var providers []PreimageProvider
+	for ty, preimages := range e.TypedPreimageMap() {
+		providers = append(providers, &MapPreimageProvider{Type: ty, Preimages: preimages})
+	}
+	return providers
+}
+
+// verifyPreimages checks each preimage hashes to its key, as a witness from elsewhere can't be trusted to.
+func (e *WitnessArchiveEntry) verifyPreimages() error {
+	for _, preimage := range e.Preimages {
+		if !verifyPreimage(preimage.Type, preimage.Hash, preimage.Data) {
+			return errors.Errorf("bad %v preimage %v in witness of block %v", preimage.Type, preimage.Hash, e.BlockNumber)
+		}
+	}
+	return nil
+}
+
+func (e *WitnessArchiveEntry) start() GoGlobalState {
+	return GoGlobalState{
+		Batch:      e.StartPosition.BatchNumber,
+		PosInBatch: e.StartPosition.PosInBatch,
+		BlockHash:  e.PrevBlockHash,
+		SendRoot:   e.PrevSendRoot,
+	}
+}
+
+func (e *WitnessArchiveEntry) expectedEnd() GoGlobalState {
+	return GoGlobalState{
+		Batch:      e.EndPosition.BatchNumber,
+		PosInBatch: e.EndPosition.PosInBatch,
+		BlockHash:  e.BlockHash,
+		SendRoot:   e.SendRoot,
+	}
+}
+
+// WitnessArchive persists, grouped by batch, the witness of every block the block validator
+// successfully validates. Entries are brotli compressed RLP.
+type WitnessArchive struct {
+	db ethdb.Database
+}
+
+func NewWitnessArchive(db ethdb.Database) *WitnessArchive {
+	return &WitnessArchive{db: db}
+}
+
+func witnessArchiveBatchPrefix(batch uint64) []byte {
+	key := make([]byte, len(witnessArchivePrefix)+8)
+	copy(key, witnessArchivePrefix)
+	binary.BigEndian.PutUint64(key[len(witnessArchivePrefix):], batch)
+	return key
+}
+
+func witnessArchiveKey(batch uint64, blockNumber uint64) []byte {
+	key := witnessArchiveBatchPrefix(batch)
+	var blockNumberBytes [8]byte
+	binary.BigEndian.PutUint64(blockNumberBytes[:], blockNumber)
+	return append(key, blockNumberBytes[:]...)
+}
+
+func newWitnessArchiveEntry(entry *validationEntry, delayedMsg []byte) *WitnessArchiveEntry {
+	preimages := make([]WitnessPreimage, 0, len(entry.Preimages))
+	for hash, data := range entry.Preimages {
+		preimages = append(preimages, WitnessPreimage{hash, data, arbutil.Keccak256PreimageType})
+	}
+	sort.Slice(preimages, func(i, j int) bool {
+		return bytes.Compare(preimages[i].Hash[:], preimages[j].Hash[:]) < 0
+	})
+	return &WitnessArchiveEntry{
+		BlockNumber:   entry.BlockNumber,
+		BlockHash:     entry.BlockHash,
+		PrevBlockHash: entry.PrevBlockHash,
+		SendRoot:      entry.SendRoot,
+		PrevSendRoot:  entry.PrevSendRoot,
+		StartPosition: entry.StartPosition,
+		EndPosition:   entry.EndPosition,
+		Batches:       entry.BatchInfo,
+		HasDelayedMsg: entry.HasDelayedMsg,
+		DelayedMsgNr:  entry.DelayedMsgNr,
+		DelayedMsg:    delayedMsg,
+		Preimages:     preimages,
+	}
+}
+
+func (a *WitnessArchive) Store(entry *WitnessArchiveEntry) error {
+	encoded, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		return err
+	}
+	var compressed bytes.Buffer
+	writer := brotli.NewWriterLevel(&compressed, brotli.DefaultCompression)
+	_, err = writer.Write(encoded)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return a.db.Put(witnessArchiveKey(entry.StartPosition.BatchNumber, entry.BlockNumber), compressed.Bytes())
+}
+
+func decodeWitnessArchiveEntry(data []byte) (*WitnessArchiveEntry, error) {
+	decompressed, err := io.ReadAll(brotli.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	var entry WitnessArchiveEntry
+	err = rlp.DecodeBytes(decompressed, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := entry.verifyPreimages(); err != nil {
+		return nil, errors.Wrap(err, "corrupt witness archive entry")
+	}
+	return &entry, nil
+}
+
+// GetBatch returns the archived witnesses of the blocks starting in the given batch, ordered by block number.
+func (a *WitnessArchive) GetBatch(batch uint64) ([]*WitnessArchiveEntry, error) {
+	iter := a.db.NewIterator(witnessArchiveBatchPrefix(batch), nil)
+	defer iter.Release()
+	var entries []*WitnessArchiveEntry
+	for iter.Next() {
+		entry, err := decodeWitnessArchiveEntry(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, iter.Error()
+}
+
+func (a *WitnessArchive) GetBlock(batch uint64, blockNumber uint64) (*WitnessArchiveEntry, error) {
+	data, err := a.db.Get(witnessArchiveKey(batch, blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	return decodeWitnessArchiveEntry(data)
+}
+
+// DeleteFromBatch removes the witnesses of all batches from the given one onwards, which are no longer canonical after a reorg.
+func (a *WitnessArchive) DeleteFromBatch(batch uint64) error {
+	iter := a.db.NewIterator(witnessArchivePrefix, witnessArchiveBatchPrefix(batch)[len(witnessArchivePrefix):])
+	defer iter.Release()
+	dbBatch := a.db.NewBatch()
+	for iter.Next() {
+		err := dbBatch.Delete(iter.Key())
+		if err != nil {
+			return err
+		}
+	}
+	if iter.Error() != nil {
+		return iter.Error()
+	}
+	return dbBatch.Write()
+}
+
+// PruneConfirmed removes the witnesses of blocks before keepFromBlock in batches before confirmedBatch, which a
+// confirmed assertion covers, so no fraud proof will need them. It returns how many witnesses it removed.
+func (a *WitnessArchive) PruneConfirmed(confirmedBatch uint64, keepFromBlock uint64) (int, error) {
+	iter := a.db.NewIterator(witnessArchivePrefix, nil)
+	defer iter.Release()
+	dbBatch := a.db.NewBatch()
+	pruned := 0
+	for iter.Next() {
+		key := iter.Key()[len(witnessArchivePrefix):]
+		if len(key) != 16 {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[:8]) >= confirmedBatch {
+			break
+		}
+		if binary.BigEndian.Uint64(key[8:]) >= keepFromBlock {
+			continue
+		}
+		if err := dbBatch.Delete(iter.Key()); err != nil {
+			return 0, err
+		}
+		pruned++
+	}
+	if iter.Error() != nil {
+		return 0, iter.Error()
+	}
+	return pruned, dbBatch.Write()
+}
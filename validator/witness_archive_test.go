@@ -0,0 +1,93 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestWitnessArchive(t *testing.T) {
+	archive := NewWitnessArchive(rawdb.NewMemoryDatabase())
+
+	preimage := []byte("preimage data")
+	makeEntry := func(batch uint64, blockNumber uint64) *validationEntry {
+		return &validationEntry{
+			BlockNumber:   blockNumber,
+			BlockHash:     common.BigToHash(common.Big1),
+			HasDelayedMsg: true,
+			DelayedMsgNr:  3,
+			StartPosition: GlobalStatePosition{BatchNumber: batch},
+			EndPosition:   GlobalStatePosition{BatchNumber: batch, PosInBatch: 1},
+			Preimages:     map[common.Hash][]byte{crypto.Keccak256Hash(preimage): preimage},
+			BatchInfo:     []BatchInfo{{Number: batch, Data: []byte{1, 2, 3}}},
+		}
+	}
+	for _, pos := range [][2]uint64{{5, 10}, {5, 11}, {6, 12}, {7, 13}} {
+		err := archive.Store(newWitnessArchiveEntry(makeEntry(pos[0], pos[1]), []byte("delayed")))
+		Require(t, err)
+	}
+
+	entries, err := archive.GetBatch(5)
+	Require(t, err)
+	if len(entries) != 2 || entries[0].BlockNumber != 10 || entries[1].BlockNumber != 11 {
+		Fail(t, "unexpected entries for batch 5", entries)
+	}
+	entry := entries[0]
+	if !bytes.Equal(entry.DelayedMsg, []byte("delayed")) || entry.DelayedMsgNr != 3 || !entry.HasDelayedMsg {
+		Fail(t, "delayed message not archived", entry)
+	}
+	if !bytes.Equal(entry.PreimageMap()[crypto.Keccak256Hash(preimage)], preimage) {
+		Fail(t, "preimage not archived")
+	}
+	if len(entry.Batches) != 1 || !bytes.Equal(entry.Batches[0].Data, []byte{1, 2, 3}) {
+		Fail(t, "batch data not archived", entry.Batches)
+	}
+
+	Require(t, archive.DeleteFromBatch(6))
+	entries, err = archive.GetBatch(6)
+	Require(t, err)
+	if len(entries) != 0 {
+		Fail(t, "reorged batch still archived")
+	}
+	_, err = archive.GetBlock(5, 11)
+	Require(t, err)
+
+	// Once batch 7 is confirmed, only witnesses of earlier batches and blocks before the retained ones go
+	Require(t, archive.Store(newWitnessArchiveEntry(makeEntry(6, 12), nil)))
+	Require(t, archive.Store(newWitnessArchiveEntry(makeEntry(7, 13), nil)))
+	pruned, err := archive.PruneConfirmed(7, 11)
+	Require(t, err)
+	if pruned != 1 {
+		Fail(t, "pruned", pruned, "witnesses, expected 1")
+	}
+	if _, err := archive.GetBlock(5, 10); err == nil {
+		Fail(t, "confirmed witness outside the retention kept")
+	}
+	for _, pos := range [][2]uint64{{5, 11}, {6, 12}, {7, 13}} {
+		_, err := archive.GetBlock(pos[0], pos[1])
+		Require(t, err, "witness of block", pos[1], "pruned")
+	}
+	pruned, err = archive.PruneConfirmed(8, 100)
+	Require(t, err)
+	if pruned != 3 {
+		Fail(t, "pruned", pruned, "witnesses, expected 3")
+	}
+
+	// A witness sent to a spawner is rejected if a preimage doesn't match its hash, so it can't poison the result cache
+	tampered := newWitnessArchiveEntry(makeEntry(5, 10), nil)
+	tampered.Preimages[0].Data = []byte("other data")
+	witness, err := rlp.EncodeToBytes(tampered)
+	Require(t, err)
+	api := NewValidationSpawnerAPI(&ValidationSpawner{})
+	if _, err := api.Validate(context.Background(), witness, common.Hash{}); err == nil {
+		Fail(t, "witness with a bad preimage accepted")
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/golang-jwt/jwt/v4"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// BroadcasterAuthConfig requires clients to present a token to connect to the feed. Tokens are either JWTs signed
+// with HS256, whose claims may set the token's quotas, or HMAC tokens, which get the default quotas.
+type BroadcasterAuthConfig struct {
+	Enable            bool     `koanf:"enable"`
+	Secret            string   `koanf:"secret"`
+	MaxConnections    int      `koanf:"max-connections"`
+	MaxBytesPerSecond int      `koanf:"max-bytes-per-second"`
+	Revoked           []string `koanf:"revoked"`
+}
+
+func BroadcasterAuthConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBroadcasterAuthConfig.Enable, "require clients to present a token to connect")
+	f.String(prefix+".secret", DefaultBroadcasterAuthConfig.Secret, "secret shared with the token issuer, signing JWTs with HS256 and HMAC tokens with HMAC-SHA256")
+	f.Int(prefix+".max-connections", DefaultBroadcasterAuthConfig.MaxConnections, "connections allowed per token, unless its claims say otherwise (0 for unlimited)")
+	f.Int(prefix+".max-bytes-per-second", DefaultBroadcasterAuthConfig.MaxBytesPerSecond, "bandwidth allowed per token across its connections, unless its claims say otherwise (0 for unlimited)")
+	f.StringSlice(prefix+".revoked", DefaultBroadcasterAuthConfig.Revoked, "IDs of tokens which may no longer connect")
+}
+
+var DefaultBroadcasterAuthConfig = BroadcasterAuthConfig{
+	Enable:            false,
+	Secret:            "",
+	MaxConnections:    10,
+	MaxBytesPerSecond: 0,
+	Revoked:           []string{},
+}
+
+var (
+	ErrMissingFeedToken = errors.New("missing feed token")
+	ErrInvalidFeedToken = errors.New("invalid feed token")
+	ErrRevokedFeedToken = errors.New("feed token revoked")
+	ErrFeedTokenQuota   = errors.New("feed token connection quota exceeded")
+)
+
+// FeedToken is the access a client's token grants it.
+type FeedToken struct {
+	ID                string
+	MaxConnections    int
+	MaxBytesPerSecond int
+	Expiry            time.Time // zero if the token doesn't expire
+}
+
+type feedTokenClaims struct {
+	jwt.RegisteredClaims
+	MaxConnections    *int `json:"maxConnections,omitempty"`
+	MaxBytesPerSecond *int `json:"maxBytesPerSecond,omitempty"`
+}
+
+// NewHMACFeedToken returns an HMAC token for the given ID, for issuers and clients.
+func NewHMACFeedToken(secret string, id string) string {
+	return id + "." + hex.EncodeToString(feedTokenMAC(secret, id))
+}
+
+func feedTokenMAC(secret string, id string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	return mac.Sum(nil)
+}
+
+type feedTokenUsage struct {
+	connections int
+	windowStart time.Time
+	windowBytes int
+}
+
+// feedAuthenticator checks clients' tokens and tracks each token's usage against its quotas.
+type feedAuthenticator struct {
+	config  *BroadcasterAuthConfig
+	mutex   sync.Mutex
+	revoked map[string]bool
+	usage   map[string]*feedTokenUsage
+}
+
+func newFeedAuthenticator(config *BroadcasterAuthConfig) *feedAuthenticator {
+	revoked := make(map[string]bool)
+	for _, id := range config.Revoked {
+		revoked[id] = true
+	}
+	return &feedAuthenticator{
+		config:  config,
+		revoked: revoked,
+		usage:   make(map[string]*feedTokenUsage),
+	}
+}
+
+func (a *feedAuthenticator) parse(token string) (*FeedToken, error) {
+	if token == "" {
+		return nil, ErrMissingFeedToken
+	}
+	if strings.Count(token, ".") == 2 {
+		return a.parseJWT(token)
+	}
+	dot := strings.LastIndex(token, ".")
+	if dot <= 0 {
+		return nil, ErrInvalidFeedToken
+	}
+	id := token[:dot]
+	mac, err := hex.DecodeString(token[dot+1:])
+	if err != nil || !hmac.Equal(mac, feedTokenMAC(a.config.Secret, id)) {
+		return nil, ErrInvalidFeedToken
+	}
+	return &FeedToken{
+		ID:                id,
+		MaxConnections:    a.config.MaxConnections,
+		MaxBytesPerSecond: a.config.MaxBytesPerSecond,
+	}, nil
+}
+
+func (a *feedAuthenticator) parseJWT(token string) (*FeedToken, error) {
+	claims := &feedTokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(a.config.Secret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeedToken, err)
+	}
+	feedToken := &FeedToken{
+		ID:                claims.ID,
+		MaxConnections:    a.config.MaxConnections,
+		MaxBytesPerSecond: a.config.MaxBytesPerSecond,
+	}
+	if feedToken.ID == "" {
+		feedToken.ID = claims.Subject
+	}
+	if feedToken.ID == "" {
+		return nil, fmt.Errorf("%w: no jti or sub claim", ErrInvalidFeedToken)
+	}
+	if claims.MaxConnections != nil {
+		feedToken.MaxConnections = *claims.MaxConnections
+	}
+	if claims.MaxBytesPerSecond != nil {
+		feedToken.MaxBytesPerSecond = *claims.MaxBytesPerSecond
+	}
+	if claims.ExpiresAt != nil {
+		feedToken.Expiry = claims.ExpiresAt.Time
+	}
+	return feedToken, nil
+}
+
+// authenticate checks a connecting client's token and counts the connection against its quota.
+// Connections which are admitted must be released when they close.
+func (a *feedAuthenticator) authenticate(token string) (*FeedToken, error) {
+	feedToken, err := a.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.revoked[feedToken.ID] {
+		return nil, ErrRevokedFeedToken
+	}
+	usage := a.usage[feedToken.ID]
+	if usage == nil {
+		usage = &feedTokenUsage{}
+		a.usage[feedToken.ID] = usage
+	}
+	if feedToken.MaxConnections > 0 && usage.connections >= feedToken.MaxConnections {
+		return nil, ErrFeedTokenQuota
+	}
+	usage.connections++
+	return feedToken, nil
+}
+
+func (a *feedAuthenticator) release(feedToken *FeedToken) {
+	if feedToken == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	usage := a.usage[feedToken.ID]
+	if usage == nil {
+		return
+	}
+	usage.connections--
+	if usage.connections <= 0 {
+		delete(a.usage, feedToken.ID)
+	}
+}
+
+// consume counts bytes sent to a client against its token's bandwidth, returning false if it's exceeded.
+func (a *feedAuthenticator) consume(feedToken *FeedToken, bytes int, now time.Time) bool {
+	if feedToken == nil || feedToken.MaxBytesPerSecond <= 0 {
+		return true
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	usage := a.usage[feedToken.ID]
+	if usage == nil {
+		return true
+	}
+	if now.Sub(usage.windowStart) >= time.Second {
+		usage.windowStart = now
+		usage.windowBytes = 0
+	}
+	usage.windowBytes += bytes
+	return usage.windowBytes <= feedToken.MaxBytesPerSecond
+}
+
+// valid returns whether a connected client's token still grants it access.
+func (a *feedAuthenticator) valid(feedToken *FeedToken, now time.Time) bool {
+	if feedToken == nil {
+		return true
+	}
+	if !feedToken.Expiry.IsZero() && now.After(feedToken.Expiry) {
+		return false
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return !a.revoked[feedToken.ID]
+}
+
+func (a *feedAuthenticator) revoke(id string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.revoked[id] = true
+}
+
+// RequestedSeqNumHeader is the handshake header a reconnecting client sets to the sequence number of the first
+// message it missed, asking to be sent the messages from then on rather than just the unconfirmed ones.
+const RequestedSeqNumHeader = "Arbitrum-Requested-Sequence-Number"
+
+// handshakeToken collects the token a client presents while upgrading its connection, either as a bearer token
+// in the Authorization header, or, for clients which can't set headers, as the token query parameter. It also
+// collects the stream query parameter and the sequence number a reconnecting client asks to resume from.
+type handshakeToken struct {
+	token           string
+	requestedSeqNum *arbutil.MessageIndex
+	stream          Stream
+}
+
+func (h *handshakeToken) onRequest(uri []byte) error {
+	query := bytes.IndexByte(uri, '?')
+	if query < 0 {
+		return nil
+	}
+	values, err := url.ParseQuery(string(uri[query+1:]))
+	if err != nil {
+		return nil
+	}
+	if values.Get("token") != "" {
+		h.token = values.Get("token")
+	}
+	stream, err := ParseStream(values.Get("stream"))
+	if err != nil {
+		return ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason(err.Error()))
+	}
+	h.stream = stream
+	return nil
+}
+
+func (h *handshakeToken) onHeader(key, value []byte) error {
+	switch http.CanonicalHeaderKey(string(key)) {
+	case "Authorization":
+		if token := strings.TrimPrefix(string(value), "Bearer "); token != string(value) {
+			h.token = strings.TrimSpace(token)
+		}
+	case RequestedSeqNumHeader:
+		// an invalid sequence number is ignored, leaving the client to catch up as if it were new
+		if seqNum, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+			requested := arbutil.MessageIndex(seqNum)
+			h.requestedSeqNum = &requested
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestFeedTokens(t *testing.T) {
+	config := DefaultBroadcasterAuthConfig
+	config.Enable = true
+	config.Secret = "secret"
+	config.MaxConnections = 1
+	config.MaxBytesPerSecond = 100
+	config.Revoked = []string{"revoked"}
+	auth := newFeedAuthenticator(&config)
+
+	if _, err := auth.authenticate(""); !errors.Is(err, ErrMissingFeedToken) {
+		t.Fatal("accepted a missing token", err)
+	}
+	if _, err := auth.authenticate(NewHMACFeedToken("wrong", "client")); !errors.Is(err, ErrInvalidFeedToken) {
+		t.Fatal("accepted a token with the wrong secret", err)
+	}
+	if _, err := auth.authenticate(NewHMACFeedToken(config.Secret, "revoked")); !errors.Is(err, ErrRevokedFeedToken) {
+		t.Fatal("accepted a revoked token", err)
+	}
+
+	token, err := auth.authenticate(NewHMACFeedToken(config.Secret, "client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ID != "client" || token.MaxBytesPerSecond != 100 {
+		t.Fatal("unexpected token", token)
+	}
+	if _, err := auth.authenticate(NewHMACFeedToken(config.Secret, "client")); !errors.Is(err, ErrFeedTokenQuota) {
+		t.Fatal("accepted a connection over the token's quota", err)
+	}
+
+	now := time.Now()
+	if !auth.consume(token, 60, now) || auth.consume(token, 60, now) {
+		t.Fatal("bandwidth quota not enforced")
+	}
+	if !auth.consume(token, 60, now.Add(time.Second)) {
+		t.Fatal("bandwidth quota not reset")
+	}
+
+	auth.revoke("client")
+	if auth.valid(token, now) {
+		t.Fatal("revoked token still valid")
+	}
+	auth.release(token)
+	if _, err := auth.authenticate(NewHMACFeedToken(config.Secret, "client")); !errors.Is(err, ErrRevokedFeedToken) {
+		t.Fatal("accepted a token revoked after connecting", err)
+	}
+}
+
+func TestFeedJWT(t *testing.T) {
+	config := DefaultBroadcasterAuthConfig
+	config.Enable = true
+	config.Secret = "secret"
+	config.MaxConnections = 1
+	auth := newFeedAuthenticator(&config)
+
+	sign := func(claims *feedTokenClaims, method jwt.SigningMethod, key interface{}) string {
+		signed, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	connections := 2
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := &feedTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: "premium", ExpiresAt: jwt.NewNumericDate(expiry)},
+		MaxConnections:   &connections,
+	}
+
+	token, err := auth.authenticate(sign(claims, jwt.SigningMethodHS256, []byte(config.Secret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ID != "premium" || token.MaxConnections != 2 || !token.Expiry.Equal(expiry) {
+		t.Fatal("unexpected token", token)
+	}
+	if _, err := auth.authenticate(sign(claims, jwt.SigningMethodHS256, []byte(config.Secret))); err != nil {
+		t.Fatal("token's connection quota not taken from its claims", err)
+	}
+	if auth.valid(token, expiry.Add(time.Second)) {
+		t.Fatal("expired token still valid")
+	}
+
+	if _, err := auth.authenticate(sign(claims, jwt.SigningMethodHS256, []byte("wrong"))); !errors.Is(err, ErrInvalidFeedToken) {
+		t.Fatal("accepted a JWT with the wrong secret", err)
+	}
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	if _, err := auth.authenticate(sign(claims, jwt.SigningMethodHS256, []byte(config.Secret))); !errors.Is(err, ErrInvalidFeedToken) {
+		t.Fatal("accepted an expired JWT", err)
+	}
+	if _, err := auth.authenticate(sign(&feedTokenClaims{}, jwt.SigningMethodHS256, []byte(config.Secret))); !errors.Is(err, ErrInvalidFeedToken) {
+		t.Fatal("accepted a JWT without an ID", err)
+	}
+}
@@ -14,8 +14,8 @@ import (
 	"time"
 
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/mailru/easygo/netpoll"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 )
 
@@ -32,19 +32,52 @@ type ClientConnection struct {
 
 	lastHeardUnix int64
 	out           chan []byte
+	compression   Compression
+	token         *FeedToken // nil unless auth is enabled
+
+	requestedSeqNum *arbutil.MessageIndex // nil unless the client asked to be sent messages from it
+	stream          Stream
+	overflow        OverflowPolicy
+	overflowMetrics *clientOverflowMetrics
 }
 
-func NewClientConnection(conn net.Conn, desc *netpoll.Desc, clientManager *ClientManager) *ClientConnection {
+func NewClientConnection(conn net.Conn, desc *netpoll.Desc, clientManager *ClientManager, compression Compression, token *FeedToken, requestedSeqNum *arbutil.MessageIndex, stream Stream, overflow OverflowPolicy) *ClientConnection {
+	name := conn.RemoteAddr().String() + strconv.Itoa(rand.Intn(10))
 	return &ClientConnection{
 		conn:          conn,
 		desc:          desc,
-		Name:          conn.RemoteAddr().String() + strconv.Itoa(rand.Intn(10)),
+		Name:          name,
 		clientManager: clientManager,
 		lastHeardUnix: time.Now().Unix(),
-		out:           make(chan []byte, clientManager.settings.MaxSendQueue),
+		out:           make(chan []byte, sendQueueSize(clientManager.settings.MaxSendQueue, overflow)),
+		compression:   compression,
+		token:         token,
+
+		requestedSeqNum: requestedSeqNum,
+		stream:          stream,
+		overflow:        overflow,
+		overflowMetrics: newClientOverflowMetrics(name),
 	}
 }
 
+// RequestedSeqNum returns the sequence number the client asked to be sent messages from as it connected, if any.
+func (cc *ClientConnection) RequestedSeqNum() (arbutil.MessageIndex, bool) {
+	if cc.requestedSeqNum == nil {
+		return 0, false
+	}
+	return *cc.requestedSeqNum, true
+}
+
+// Stream returns the stream the client subscribed to.
+func (cc *ClientConnection) Stream() Stream {
+	return cc.stream
+}
+
+// Compression returns how messages to the client are compressed.
+func (cc *ClientConnection) Compression() Compression {
+	return cc.compression
+}
+
 func (cc *ClientConnection) Start(parentCtx context.Context) {
 	cc.StopWaiter.Start(parentCtx)
 	cc.LaunchThread(func(ctx context.Context) {
@@ -54,6 +87,9 @@ func (cc *ClientConnection) Start(parentCtx context.Context) {
 			case <-ctx.Done():
 				return
 			case data := <-cc.out:
+				if cc.dropTaken() {
+					continue
+				}
 				err := cc.writeRaw(data)
 				if err != nil {
 					logWarn(err, "error writing data to client")
@@ -103,21 +139,27 @@ func (cc *ClientConnection) readRequest(ctx context.Context, timeout time.Durati
 
 	atomic.StoreInt64(&cc.lastHeardUnix, time.Now().Unix())
 
-	return ReadData(ctx, cc.conn, nil, timeout, ws.StateServerSide)
+	return ReadData(ctx, cc.conn, nil, timeout, ws.StateServerSide, cc.compression)
 }
 
+// Write sends the client a message, as it's seen on the client's stream.
 func (cc *ClientConnection) Write(x interface{}) error {
-	writer := wsutil.NewWriter(cc.conn, ws.StateServerSide, ws.OpText)
-	encoder := json.NewEncoder(writer)
-
-	cc.ioMutex.Lock()
-	defer cc.ioMutex.Unlock()
-
-	if err := encoder.Encode(x); err != nil {
+	x, send := forStream(x, cc.stream)
+	if !send {
+		return nil
+	}
+	payload, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	// match json.Encoder's output, as sent by the broadcast
+	payload = append(payload, '\n')
+	frame, err := encodeFrame(payload, cc.compression, cc.clientManager.settings.CompressionMinSize)
+	if err != nil {
 		return err
 	}
 
-	return writer.Flush()
+	return cc.writeRaw(frame)
 }
 
 func (cc *ClientConnection) writeRaw(p []byte) error {
@@ -12,15 +12,22 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 	"github.com/pkg/errors"
 
-	"github.com/gobwas/ws"
 	"github.com/gobwas/ws-examples/src/gopool"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/mailru/easygo/netpoll"
 )
 
+// broadcastBytesCounters count the bytes broadcast to clients by their compression
+var broadcastBytesCounters = map[Compression]metrics.Counter{
+	CompressionNone:    metrics.NewRegisteredCounter("arb/feed/broadcast/sent/none", nil),
+	CompressionDeflate: metrics.NewRegisteredCounter("arb/feed/broadcast/sent/deflate", nil),
+	CompressionSnappy:  metrics.NewRegisteredCounter("arb/feed/broadcast/sent/snappy", nil),
+}
+
 /* Protocol-specific client catch-up logic can be injected using this interface. */
 type CatchupBuffer interface {
 	OnRegisterClient(context.Context, *ClientConnection) error
@@ -40,6 +47,7 @@ type ClientManager struct {
 	clientAction  chan ClientConnectionAction
 	settings      BroadcasterConfig
 	catchupBuffer CatchupBuffer
+	auth          *feedAuthenticator
 }
 
 type ClientConnectionAction struct {
@@ -47,7 +55,7 @@ type ClientConnectionAction struct {
 	create bool
 }
 
-func NewClientManager(poller netpoll.Poller, settings BroadcasterConfig, catchupBuffer CatchupBuffer) *ClientManager {
+func NewClientManager(poller netpoll.Poller, settings BroadcasterConfig, catchupBuffer CatchupBuffer, auth *feedAuthenticator) *ClientManager {
 	return &ClientManager{
 		poller:        poller,
 		pool:          gopool.NewPool(settings.Workers, settings.Queue, 1),
@@ -56,6 +64,7 @@ func NewClientManager(poller netpoll.Poller, settings BroadcasterConfig, catchup
 		clientAction:  make(chan ClientConnectionAction, 128),
 		settings:      settings,
 		catchupBuffer: catchupBuffer,
+		auth:          auth,
 	}
 }
 
@@ -72,9 +81,9 @@ func (cm *ClientManager) registerClient(ctx context.Context, clientConnection *C
 }
 
 // Register registers new connection as a Client.
-func (cm *ClientManager) Register(conn net.Conn, desc *netpoll.Desc) *ClientConnection {
+func (cm *ClientManager) Register(conn net.Conn, desc *netpoll.Desc, compression Compression, token *FeedToken, requestedSeqNum *arbutil.MessageIndex, stream Stream, overflow OverflowPolicy) *ClientConnection {
 	createClient := ClientConnectionAction{
-		NewClientConnection(conn, desc, cm),
+		NewClientConnection(conn, desc, cm, compression, token, requestedSeqNum, stream, overflow),
 		true,
 	}
 
@@ -104,6 +113,9 @@ func (cm *ClientManager) removeClientImpl(clientConnection *ClientConnection) {
 		log.Warn("Failed to close client connection", "err", err)
 	}
 
+	cm.auth.release(clientConnection.token)
+	clientConnection.overflowMetrics.unregister()
+
 	atomic.AddInt32(&cm.clientCount, -1)
 }
 
@@ -138,26 +150,58 @@ func (cm *ClientManager) doBroadcast(bm interface{}) ([]*ClientConnection, error
 		return nil, err
 	}
 
-	var buf bytes.Buffer
-	writer := wsutil.NewWriter(&buf, ws.StateServerSide, ws.OpText)
-	encoder := json.NewEncoder(writer)
-	if err := encoder.Encode(bm); err != nil {
-		return nil, errors.Wrap(err, "unable to encode message")
+	// Each stream is encoded and each compression done only once, however many clients use them
+	type streamFrame struct {
+		stream      Stream
+		compression Compression
 	}
-	if err := writer.Flush(); err != nil {
-		return nil, errors.Wrap(err, "unable to flush message")
-	}
-
+	payloads := make(map[Stream][]byte)
+	frames := make(map[streamFrame][]byte)
+	now := time.Now()
 	clientDeleteList := make([]*ClientConnection, 0, len(cm.clientPtrMap))
+	maxDepth := 0
 	for client := range cm.clientPtrMap {
-		if len(client.out) == cm.settings.MaxSendQueue {
-			// Queue for client too backed up, disconnect instead of blocking on channel send
-			log.Info("disconnecting because send queue too large", "client", client.Name, "size", len(client.out))
+		if depth := len(client.out); depth > maxDepth {
+			maxDepth = depth
+		}
+		if !cm.makeRoom(client) {
 			clientDeleteList = append(clientDeleteList, client)
-		} else {
-			client.out <- buf.Bytes()
+			continue
 		}
+		payload, ok := payloads[client.stream]
+		if !ok {
+			if x, send := forStream(bm, client.stream); send {
+				var buf bytes.Buffer
+				if err := json.NewEncoder(&buf).Encode(x); err != nil {
+					return nil, errors.Wrap(err, "unable to encode message")
+				}
+				payload = buf.Bytes()
+			}
+			payloads[client.stream] = payload
+		}
+		if payload == nil {
+			// nothing on the client's stream
+			continue
+		}
+		key := streamFrame{client.stream, client.compression}
+		frame, ok := frames[key]
+		if !ok {
+			var err error
+			frame, err = encodeFrame(payload, client.compression, cm.settings.CompressionMinSize)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to encode frame")
+			}
+			frames[key] = frame
+		}
+		if !cm.auth.consume(client.token, len(frame), now) {
+			log.Info("disconnecting because token bandwidth quota exceeded", "client", client.Name, "token", client.token.ID)
+			clientDeleteList = append(clientDeleteList, client)
+			continue
+		}
+		client.out <- frame
+		broadcastBytesCounters[client.compression].Inc(int64(len(frame)))
 	}
+	sendQueueMaxDepthGauge.Update(int64(maxDepth))
 
 	return clientDeleteList, nil
 }
@@ -171,11 +215,15 @@ func (cm *ClientManager) verifyClients() []*ClientConnection {
 
 	// Send ping to all connected clients
 	log.Debug("pinging clients", "count", len(cm.clientPtrMap))
+	now := time.Now()
 	for client := range cm.clientPtrMap {
-		diff := time.Since(client.GetLastHeard())
+		diff := now.Sub(client.GetLastHeard())
 		if diff > cm.settings.ClientTimeout {
 			log.Info("disconnecting because connection timed out", "client", client.Name)
 			clientDeleteList = append(clientDeleteList, client)
+		} else if !cm.auth.valid(client.token, now) {
+			log.Info("disconnecting because token was revoked or expired", "client", client.Name, "token", client.token.ID)
+			clientDeleteList = append(clientDeleteList, client)
 		} else {
 			err := client.Ping()
 			if err != nil {
@@ -0,0 +1,183 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/golang/snappy"
+)
+
+// Compression is how feed messages are compressed on a connection, as negotiated at the websocket handshake.
+// With deflate, compressed messages have the permessage-deflate RSV1 bit set. With snappy, compressed messages
+// are sent as binary frames. Either way, messages may still be sent uncompressed, e.g. if they're small.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionDeflate
+	CompressionSnappy
+)
+
+// SnappyExtensionName is the websocket extension clients offer to receive snappy compressed messages.
+const SnappyExtensionName = "x-arbitrum-snappy"
+
+// The server compresses each message once for all its clients, so it mustn't keep a compression context.
+var deflateParameters = wsflate.Parameters{
+	ServerNoContextTakeover: true,
+	ClientNoContextTakeover: true,
+}
+
+func ParseCompression(s string) (Compression, error) {
+	switch s {
+	case "", "none":
+		return CompressionNone, nil
+	case "deflate":
+		return CompressionDeflate, nil
+	case "snappy":
+		return CompressionSnappy, nil
+	}
+	return CompressionNone, fmt.Errorf("unknown feed compression %q", s)
+}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionDeflate:
+		return "deflate"
+	case CompressionSnappy:
+		return "snappy"
+	default:
+		return "none"
+	}
+}
+
+// Extensions returns the websocket extensions a client offers to receive messages compressed this way.
+func (c Compression) Extensions() []httphead.Option {
+	switch c {
+	case CompressionDeflate:
+		return []httphead.Option{deflateParameters.Option()}
+	case CompressionSnappy:
+		return []httphead.Option{httphead.NewOption(SnappyExtensionName, nil)}
+	default:
+		return nil
+	}
+}
+
+// NegotiatedCompression returns the compression the server accepted, from the extensions in its handshake response.
+func NegotiatedCompression(extensions []httphead.Option) Compression {
+	for _, extension := range extensions {
+		switch string(extension.Name) {
+		case string(wsflate.ExtensionNameBytes):
+			return CompressionDeflate
+		case SnappyExtensionName:
+			return CompressionSnappy
+		}
+	}
+	return CompressionNone
+}
+
+// compressionNegotiator accepts the first compression a connecting client offers, if compression is enabled.
+type compressionNegotiator struct {
+	enable      bool
+	deflate     wsflate.Extension
+	compression Compression
+}
+
+func newCompressionNegotiator(enable bool) *compressionNegotiator {
+	return &compressionNegotiator{
+		enable:  enable,
+		deflate: wsflate.Extension{Parameters: deflateParameters},
+	}
+}
+
+func (n *compressionNegotiator) negotiate(offer httphead.Option) (httphead.Option, error) {
+	if !n.enable || n.compression != CompressionNone {
+		return httphead.Option{}, nil
+	}
+	if string(offer.Name) == SnappyExtensionName {
+		n.compression = CompressionSnappy
+		return httphead.NewOption(SnappyExtensionName, nil), nil
+	}
+	accept, err := n.deflate.Negotiate(offer)
+	if err != nil {
+		return httphead.Option{}, err
+	}
+	if _, accepted := n.deflate.Accepted(); accepted {
+		n.compression = CompressionDeflate
+	}
+	return accept, nil
+}
+
+// encodeFrame returns the serialized server frame for a message, compressed if the connection negotiated it
+// and the message is at least minSize bytes long.
+func encodeFrame(payload []byte, compression Compression, minSize int) ([]byte, error) {
+	frame := ws.NewTextFrame(payload)
+	if len(payload) >= minSize {
+		var err error
+		switch compression {
+		case CompressionDeflate:
+			frame, err = deflateFrame(payload)
+		case CompressionSnappy:
+			frame = ws.NewBinaryFrame(snappy.Encode(nil, payload))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := ws.WriteFrame(&buf, frame); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deflateFrame compresses a text frame for permessage-deflate without context takeover.
+// The deflate stream is flushed rather than closed, and its sync marker trimmed, as RFC 7692 requires.
+func deflateFrame(payload []byte) (ws.Frame, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return ws.Frame{}, err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		return ws.Frame{}, err
+	}
+	if err := writer.Flush(); err != nil {
+		return ws.Frame{}, err
+	}
+	compressed := bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+	frame := ws.NewTextFrame(compressed)
+	frame.Header.Rsv = ws.Rsv(true, false, false)
+	return frame, nil
+}
+
+// decompressMessage reads the rest of a message, decompressing it if its header says it was compressed.
+func decompressMessage(reader io.Reader, opCode ws.OpCode, compressed bool, compression Compression) ([]byte, error) {
+	switch {
+	case compression == CompressionDeflate && compressed:
+		decompressor := wsflate.NewReader(reader, func(r io.Reader) wsflate.Decompressor {
+			return flate.NewReader(r)
+		})
+		data, err := ioutil.ReadAll(decompressor)
+		if err != nil {
+			return nil, err
+		}
+		return data, decompressor.Close()
+	case compression == CompressionSnappy && opCode == ws.OpBinary:
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		return snappy.Decode(nil, data)
+	default:
+		return ioutil.ReadAll(reader)
+	}
+}
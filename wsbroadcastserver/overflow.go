@@ -0,0 +1,130 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// OverflowPolicy is what's done with a client too slow to keep up with the feed, once it has max-send-queue
+// messages waiting to be sent. It's set by the operator for every client.
+type OverflowPolicy uint8
+
+const (
+	// OverflowDisconnect disconnects the client, which can reconnect and catch up.
+	OverflowDisconnect OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest messages waiting to be sent, leaving the client with a gap. As only the
+	// client's writer may take messages off its queue, the queue holds up to twice max-send-queue messages, and the
+	// writer drops those beyond it instead of sending them.
+	OverflowDropOldest
+	// OverflowHeaders moves the client to the headers stream once its queue is half full, which is much smaller,
+	// and disconnects it if the queue fills anyway.
+	OverflowHeaders
+)
+
+var overflowPolicyNames = []string{"disconnect", "drop-oldest", "headers"}
+
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	for policy, name := range overflowPolicyNames {
+		if s == name {
+			return OverflowPolicy(policy), nil
+		}
+	}
+	return OverflowDisconnect, fmt.Errorf("unknown send queue overflow policy %q", s)
+}
+
+func (p OverflowPolicy) String() string {
+	if int(p) < len(overflowPolicyNames) {
+		return overflowPolicyNames[p]
+	}
+	return fmt.Sprintf("overflow policy %d", p)
+}
+
+var (
+	sendQueueDepthHistogram = metrics.NewRegisteredHistogram("arb/feed/broadcast/queue/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
+	sendQueueMaxDepthGauge  = metrics.NewRegisteredGauge("arb/feed/broadcast/queue/maxdepth", nil)
+
+	overflowDisconnectCounter = metrics.NewRegisteredCounter("arb/feed/broadcast/overflow/disconnect", nil)
+	overflowDropCounter       = metrics.NewRegisteredCounter("arb/feed/broadcast/overflow/drop", nil)
+	overflowDowngradeCounter  = metrics.NewRegisteredCounter("arb/feed/broadcast/overflow/downgrade", nil)
+)
+
+var clientMetricNameUnsafe = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+// clientOverflowMetrics are a client's own send queue metrics, registered while it's connected.
+type clientOverflowMetrics struct {
+	prefix    string
+	depth     metrics.Gauge
+	drop      metrics.Counter
+	downgrade metrics.Counter
+}
+
+func newClientOverflowMetrics(name string) *clientOverflowMetrics {
+	prefix := "arb/feed/broadcast/client/" + clientMetricNameUnsafe.ReplaceAllString(name, "_") + "/"
+	return &clientOverflowMetrics{
+		prefix:    prefix,
+		depth:     metrics.NewRegisteredGauge(prefix+"queue/depth", nil),
+		drop:      metrics.NewRegisteredCounter(prefix+"overflow/drop", nil),
+		downgrade: metrics.NewRegisteredCounter(prefix+"overflow/downgrade", nil),
+	}
+}
+
+func (m *clientOverflowMetrics) unregister() {
+	for _, name := range []string{"queue/depth", "overflow/drop", "overflow/downgrade"} {
+		metrics.DefaultRegistry.Unregister(m.prefix + name)
+	}
+}
+
+// sendQueueSize is how many messages a client's queue holds under the policy.
+func sendQueueSize(maxSendQueue int, overflow OverflowPolicy) int {
+	if overflow == OverflowDropOldest {
+		return 2 * maxSendQueue
+	}
+	return maxSendQueue
+}
+
+// makeRoom applies the client's overflow policy before a message is queued for it, returning false if the client
+// is to be disconnected instead. It's called on the client manager's goroutine, which alone changes the client's
+// stream, and never takes messages off the queue, which is left to the client's writer.
+func (cm *ClientManager) makeRoom(client *ClientConnection) bool {
+	depth := len(client.out)
+	sendQueueDepthHistogram.Update(int64(depth))
+	client.overflowMetrics.depth.Update(int64(depth))
+	switch client.overflow {
+	case OverflowHeaders:
+		if client.stream == StreamFull && depth > 0 && 2*depth >= cm.settings.MaxSendQueue {
+			log.Info("moving slow client to the headers stream", "client", client.Name, "size", depth)
+			client.stream = StreamHeaders
+			overflowDowngradeCounter.Inc(1)
+			client.overflowMetrics.downgrade.Inc(1)
+		}
+	case OverflowDropOldest:
+		// the writer drops the messages beyond max-send-queue, unless it's stuck and the whole queue has filled
+		if depth < cap(client.out) {
+			return true
+		}
+	}
+	if depth >= cm.settings.MaxSendQueue {
+		// Queue for client too backed up, disconnect instead of blocking on channel send
+		log.Info("disconnecting because send queue too large", "client", client.Name, "size", depth)
+		overflowDisconnectCounter.Inc(1)
+		return false
+	}
+	return true
+}
+
+// dropTaken returns whether the writer should drop the message it just took off the client's queue rather than
+// send it, as it's one of the oldest beyond max-send-queue.
+func (cc *ClientConnection) dropTaken() bool {
+	if cc.overflow != OverflowDropOldest || len(cc.out) < cc.clientManager.settings.MaxSendQueue {
+		return false
+	}
+	overflowDropCounter.Inc(1)
+	cc.overflowMetrics.drop.Inc(1)
+	return true
+}
@@ -0,0 +1,86 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"testing"
+)
+
+func TestSendQueueOverflowPolicies(t *testing.T) {
+	settings := DefaultTestBroadcasterConfig
+	settings.MaxSendQueue = 4
+	cm := &ClientManager{settings: settings}
+	newClient := func(overflow OverflowPolicy) *ClientConnection {
+		client := &ClientConnection{
+			Name:            overflow.String(),
+			clientManager:   cm,
+			out:             make(chan []byte, sendQueueSize(settings.MaxSendQueue, overflow)),
+			overflow:        overflow,
+			overflowMetrics: newClientOverflowMetrics(overflow.String()),
+		}
+		t.Cleanup(client.overflowMetrics.unregister)
+		return client
+	}
+	// queues a frame for the client as the broadcast would, returning whether it's still connected
+	send := func(client *ClientConnection, frame byte) bool {
+		if !cm.makeRoom(client) {
+			return false
+		}
+		client.out <- []byte{frame}
+		return true
+	}
+
+	disconnecting := newClient(OverflowDisconnect)
+	for i := 0; i < settings.MaxSendQueue; i++ {
+		if !send(disconnecting, byte(i)) {
+			t.Fatal("disconnected before the queue was full")
+		}
+	}
+	if send(disconnecting, 4) {
+		t.Fatal("not disconnected with a full queue")
+	}
+
+	dropping := newClient(OverflowDropOldest)
+	for i := 0; i < 6; i++ {
+		if !send(dropping, byte(i)) {
+			t.Fatal("disconnected instead of dropping messages")
+		}
+	}
+	// the writer drops the oldest messages beyond the limit as it takes them
+	var written []byte
+	for len(dropping.out) > 0 {
+		frame := <-dropping.out
+		if !dropping.dropTaken() {
+			written = append(written, frame[0])
+		}
+	}
+	if len(written) != settings.MaxSendQueue || written[0] != 2 {
+		t.Fatal("expected the oldest messages to be dropped, but wrote", written)
+	}
+	// a writer which is stuck gets the client disconnected once the whole queue fills
+	for i := 0; i < 2*settings.MaxSendQueue; i++ {
+		send(dropping, byte(i))
+	}
+	if send(dropping, 0) {
+		t.Fatal("not disconnected with a stuck writer")
+	}
+
+	downgrading := newClient(OverflowHeaders)
+	for i := 0; i < 3; i++ {
+		if !send(downgrading, byte(i)) {
+			t.Fatal("disconnected before the queue was full")
+		}
+	}
+	if downgrading.stream != StreamHeaders {
+		t.Fatal("client with a half full queue still on the", downgrading.stream, "stream")
+	}
+	send(downgrading, 3)
+	if send(downgrading, 4) {
+		t.Fatal("not disconnected with a full queue on the headers stream")
+	}
+
+	if _, err := ParseOverflowPolicy("drop-newest"); err == nil {
+		t.Fatal("parsed an unknown overflow policy")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"fmt"
+)
+
+// Stream is the part of the feed a client subscribes to, chosen with the stream query parameter as it connects,
+// so lightweight consumers needn't receive whole messages.
+type Stream uint8
+
+const (
+	StreamFull Stream = iota
+	StreamHeaders
+	StreamConfirmations
+)
+
+var streamNames = []string{"full", "headers", "confirmations"}
+
+func ParseStream(s string) (Stream, error) {
+	if s == "" {
+		return StreamFull, nil
+	}
+	for stream, name := range streamNames {
+		if s == name {
+			return Stream(stream), nil
+		}
+	}
+	return StreamFull, fmt.Errorf("unknown feed stream %q", s)
+}
+
+func (s Stream) String() string {
+	if int(s) < len(streamNames) {
+		return streamNames[s]
+	}
+	return fmt.Sprintf("stream %d", s)
+}
+
+// StreamFilter is implemented by broadcast messages which differ by stream. ForStream returns the message to
+// send clients of the stream, and false if they're to be sent nothing.
+type StreamFilter interface {
+	ForStream(stream Stream) (interface{}, bool)
+}
+
+// forStream returns the message to send clients of the stream, and false if they're to be sent nothing.
+func forStream(x interface{}, stream Stream) (interface{}, bool) {
+	if filter, ok := x.(StreamFilter); ok {
+		return filter.ForStream(stream)
+	}
+	return x, true
+}
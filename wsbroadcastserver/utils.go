@@ -7,13 +7,13 @@ import (
 	"context"
 	"errors"
 	"io"
-	"io/ioutil"
 	"net"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 )
 
@@ -63,14 +63,22 @@ func (cr *chainedReader) add(r io.Reader) *chainedReader {
 	return cr
 }
 
-func ReadData(ctx context.Context, conn net.Conn, earlyFrameData io.Reader, idleTimeout time.Duration, state ws.State) ([]byte, ws.OpCode, error) {
+// ReadData reads the next data message from the connection, decompressing it if the connection negotiated compression.
+func ReadData(ctx context.Context, conn net.Conn, earlyFrameData io.Reader, idleTimeout time.Duration, state ws.State, compression Compression) ([]byte, ws.OpCode, error) {
 
 	controlHandler := wsutil.ControlFrameHandler(conn, state)
+	var messageState wsflate.MessageState
+	var extensions []wsutil.RecvExtension
+	if compression == CompressionDeflate {
+		state = state.Set(ws.StateExtended)
+		extensions = append(extensions, &messageState)
+	}
 	reader := wsutil.Reader{
 		Source:          (&chainedReader{}).add(earlyFrameData).add(conn),
 		State:           state,
-		CheckUTF8:       true,
+		CheckUTF8:       compression != CompressionDeflate, // compressed text isn't UTF-8 until it's inflated
 		SkipHeaderCheck: false,
+		Extensions:      extensions,
 		OnIntermediate:  controlHandler,
 	}
 
@@ -119,7 +127,7 @@ func ReadData(ctx context.Context, conn net.Conn, earlyFrameData io.Reader, idle
 			continue
 		}
 
-		data, err := ioutil.ReadAll(&reader)
+		data, err := decompressMessage(&reader, header.OpCode, messageState.IsCompressed(), compression)
 
 		return data, header.OpCode, err
 	}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -20,15 +21,21 @@ import (
 )
 
 type BroadcasterConfig struct {
-	Enable        bool          `koanf:"enable"`
-	Addr          string        `koanf:"addr"`
-	IOTimeout     time.Duration `koanf:"io-timeout"`
-	Port          string        `koanf:"port"`
-	Ping          time.Duration `koanf:"ping"`
-	ClientTimeout time.Duration `koanf:"client-timeout"`
-	Queue         int           `koanf:"queue"`
-	Workers       int           `koanf:"workers"`
-	MaxSendQueue  int           `koanf:"max-send-queue"`
+	Enable             bool                  `koanf:"enable"`
+	Addr               string                `koanf:"addr"`
+	IOTimeout          time.Duration         `koanf:"io-timeout"`
+	Port               string                `koanf:"port"`
+	Ping               time.Duration         `koanf:"ping"`
+	ClientTimeout      time.Duration         `koanf:"client-timeout"`
+	Queue              int                   `koanf:"queue"`
+	Workers            int                   `koanf:"workers"`
+	MaxSendQueue       int                   `koanf:"max-send-queue"`
+	OverflowPolicy     string                `koanf:"overflow-policy"`
+	EnableCompression  bool                  `koanf:"enable-compression"`
+	CompressionMinSize int                   `koanf:"compression-min-size"`
+	Backfill           int                   `koanf:"backfill"`
+	ArchiveURL         string                `koanf:"archive-url"`
+	Auth               BroadcasterAuthConfig `koanf:"auth"`
 }
 
 func BroadcasterConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -41,30 +48,48 @@ func BroadcasterConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Int(prefix+".queue", DefaultBroadcasterConfig.Queue, "queue size")
 	f.Int(prefix+".workers", DefaultBroadcasterConfig.Workers, "number of threads to reserve for HTTP to WS upgrade")
 	f.Int(prefix+".max-send-queue", DefaultBroadcasterConfig.MaxSendQueue, "maximum number of messages allowed to accumulate before client is disconnected")
+	f.String(prefix+".overflow-policy", DefaultBroadcasterConfig.OverflowPolicy, "what to do with a client whose send queue is full: disconnect, drop-oldest or headers (move it to the headers stream once half full)")
+	f.Bool(prefix+".enable-compression", DefaultBroadcasterConfig.EnableCompression, "let clients negotiate permessage-deflate or snappy compression of the feed")
+	f.Int(prefix+".compression-min-size", DefaultBroadcasterConfig.CompressionMinSize, "messages smaller than this many bytes are sent uncompressed even to clients using compression")
+	f.Int(prefix+".backfill", DefaultBroadcasterConfig.Backfill, "number of confirmed messages to keep for clients reconnecting after missing them")
+	f.String(prefix+".archive-url", DefaultBroadcasterConfig.ArchiveURL, "URL of an archive of feed messages, pointed to for reconnecting clients which missed messages no longer kept")
+	BroadcasterAuthConfigAddOptions(prefix+".auth", f)
 }
 
 var DefaultBroadcasterConfig = BroadcasterConfig{
-	Enable:        false,
-	Addr:          "",
-	IOTimeout:     5 * time.Second,
-	Port:          "9642",
-	Ping:          5 * time.Second,
-	ClientTimeout: 15 * time.Second,
-	Queue:         100,
-	Workers:       100,
-	MaxSendQueue:  4096,
+	Enable:             false,
+	Addr:               "",
+	IOTimeout:          5 * time.Second,
+	Port:               "9642",
+	Ping:               5 * time.Second,
+	ClientTimeout:      15 * time.Second,
+	Queue:              100,
+	Workers:            100,
+	MaxSendQueue:       4096,
+	OverflowPolicy:     "disconnect",
+	EnableCompression:  false,
+	CompressionMinSize: 256,
+	Backfill:           10_000,
+	ArchiveURL:         "",
+	Auth:               DefaultBroadcasterAuthConfig,
 }
 
 var DefaultTestBroadcasterConfig = BroadcasterConfig{
-	Enable:        false,
-	Addr:          "0.0.0.0",
-	IOTimeout:     2 * time.Second,
-	Port:          "0",
-	Ping:          5 * time.Second,
-	ClientTimeout: 15 * time.Second,
-	Queue:         1,
-	Workers:       100,
-	MaxSendQueue:  4096,
+	Enable:             false,
+	Addr:               "0.0.0.0",
+	IOTimeout:          2 * time.Second,
+	Port:               "0",
+	Ping:               5 * time.Second,
+	ClientTimeout:      15 * time.Second,
+	Queue:              1,
+	Workers:            100,
+	MaxSendQueue:       4096,
+	OverflowPolicy:     "disconnect",
+	EnableCompression:  false,
+	CompressionMinSize: 256,
+	Backfill:           10_000,
+	ArchiveURL:         "",
+	Auth:               DefaultBroadcasterAuthConfig,
 }
 
 type WSBroadcastServer struct {
@@ -76,15 +101,19 @@ type WSBroadcastServer struct {
 	started       bool
 	clientManager *ClientManager
 	catchupBuffer CatchupBuffer
+	auth          *feedAuthenticator
+	overflow      OverflowPolicy
 }
 
 func NewWSBroadcastServer(settings BroadcasterConfig, catchupBuffer CatchupBuffer) *WSBroadcastServer {
-	return &WSBroadcastServer{
+	s := &WSBroadcastServer{
 		startMutex:    &sync.Mutex{},
 		settings:      settings,
 		started:       false,
 		catchupBuffer: catchupBuffer,
 	}
+	s.auth = newFeedAuthenticator(&s.settings.Auth)
+	return s
 }
 
 func (s *WSBroadcastServer) Start(ctx context.Context) error {
@@ -93,8 +122,15 @@ func (s *WSBroadcastServer) Start(ctx context.Context) error {
 	if s.started {
 		return errors.New("broadcast server already started")
 	}
+	if s.settings.Auth.Enable && s.settings.Auth.Secret == "" {
+		return errors.New("broadcast server auth enabled without a secret")
+	}
+	overflow, err := ParseOverflowPolicy(s.settings.OverflowPolicy)
+	if err != nil {
+		return err
+	}
+	s.overflow = overflow
 
-	var err error
 	s.poller, err = netpoll.New(nil)
 	if err != nil {
 		log.Error("unable to initialize netpoll for monitoring client connection events", "err", err)
@@ -103,7 +139,7 @@ func (s *WSBroadcastServer) Start(ctx context.Context) error {
 
 	// Make pool of X size, Y sized work queue and one pre-spawned
 	// goroutine.
-	var clientManager = NewClientManager(s.poller, s.settings, s.catchupBuffer)
+	var clientManager = NewClientManager(s.poller, s.settings, s.catchupBuffer, s.auth)
 	clientManager.Start(ctx)
 
 	s.clientManager = clientManager // maintain the pointer in this instance... used for testing
@@ -117,26 +153,48 @@ func (s *WSBroadcastServer) Start(ctx context.Context) error {
 
 		safeConn := deadliner{conn, s.settings.IOTimeout}
 
-		// Zero-copy upgrade to WebSocket connection.
-		hs, err := ws.Upgrade(safeConn)
+		// Zero-copy upgrade to WebSocket connection, negotiating compression if the client offers it.
+		negotiator := newCompressionNegotiator(s.settings.EnableCompression)
+		upgrader := ws.Upgrader{Negotiate: negotiator.negotiate}
+		var token handshakeToken
+		var feedToken *FeedToken
+		upgrader.OnHeader = token.onHeader
+		upgrader.OnRequest = token.onRequest
+		if s.settings.Auth.Enable {
+			upgrader.OnBeforeUpgrade = func() (ws.HandshakeHeader, error) {
+				var err error
+				feedToken, err = s.auth.authenticate(token.token)
+				if err != nil {
+					status := http.StatusUnauthorized
+					if errors.Is(err, ErrFeedTokenQuota) {
+						status = http.StatusTooManyRequests
+					}
+					return nil, ws.RejectConnectionError(ws.RejectionStatus(status), ws.RejectionReason(err.Error()))
+				}
+				return nil, nil
+			}
+		}
+		hs, err := upgrader.Upgrade(safeConn)
 		if err != nil {
 			log.Warn("websocket upgrade error", "connection_name", nameConn(safeConn), "err", err)
+			s.auth.release(feedToken)
 			_ = safeConn.Close()
 			return
 		}
 
-		log.Info(fmt.Sprintf("established websocket connection: %+v", hs), "connection-name", nameConn(safeConn))
+		log.Info(fmt.Sprintf("established websocket connection: %+v", hs), "connection-name", nameConn(safeConn), "compression", negotiator.compression, "stream", token.stream)
 
 		// Create netpoll event descriptor to handle only read events.
 		desc, err := netpoll.HandleRead(conn)
 		if err != nil {
 			log.Warn("error in HandleRead", "connection-name", nameConn(safeConn), "err", err)
+			s.auth.release(feedToken)
 			_ = conn.Close()
 			return
 		}
 
 		// Register incoming client in clientManager.
-		client := clientManager.Register(safeConn, desc)
+		client := clientManager.Register(safeConn, desc, negotiator.compression, feedToken, token.requestedSeqNum, token.stream, s.overflow)
 
 		// Subscribe to events about conn.
 		err = s.poller.Start(desc, func(ev netpoll.Event) {
@@ -281,6 +339,11 @@ func (s *WSBroadcastServer) ClientCount() int32 {
 	return s.clientManager.ClientCount()
 }
 
+// RevokeToken stops the token with the given ID from connecting, and disconnects its clients at the next ping.
+func (s *WSBroadcastServer) RevokeToken(id string) {
+	s.auth.revoke(id)
+}
+
 // deadliner is a wrapper around net.Conn that sets read/write deadlines before
 // every Read() or Write() call.
 type deadliner struct {